@@ -20,6 +20,11 @@ include mk/golang.mk
 ifeq ($(TEST_NO_FUSE),1)
 	GOTAGS += nofuse
 endif
+# wazero, the runtime of 'ipfs experiment wasm-exec', is in its own module as
+# it needs a newer Go: the wasmexec build uses the workspace of both modules
+ifneq ($(filter wasmexec,$(MAKECMDGOALS)),)
+	export GOWORK = $(CURDIR)/wasmexec/go.work
+endif
 export IPFS_REUSEPORT=false
 
 # -------------------- #
@@ -92,6 +97,10 @@ nofuse: GOTAGS += nofuse
 nofuse: build
 .PHONY: nofuse
 
+wasmexec: GOTAGS += wasmexec
+wasmexec: build
+.PHONY: wasmexec
+
 install: cmd/ipfs-install
 .PHONY: install
 
@@ -118,6 +127,7 @@ help:
 	@echo '  all          - print this help message'
 	@echo '  build        - Build binary at ./cmd/ipfs/ipfs'
 	@echo '  nofuse       - Build binary with no fuse support'
+	@echo '  wasmexec     - Build binary with the WASM runtime of wasm-exec'
 	@echo '  install      - Build binary and install into $$GOPATH/bin'
 #	@echo '  dist_install - TODO: c.f. ./cmd/ipfs/dist/README.md'
 	@echo ''
@@ -0,0 +1,225 @@
+// Package accesscontrol restricts the peers the blocks of a node are served
+// to.
+//
+// An access list allows a set of peers to fetch a block; the blocks without
+// one are served to every peer. The lists are kept in memory and stored in
+// the datastore of the node. They are keyed by the multihash of the blocks,
+// so they apply to every CID of a block.
+//
+// The lists are only enforced by the node when serving the blocks: the
+// content is not encrypted, and any peer fetching it from another node gets
+// it.
+package accesscontrol
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var log = logging.Logger("accesscontrol")
+
+// DatastoreKey is where the access lists are stored.
+var DatastoreKey = ds.NewKey("/local/access-control")
+
+// Entry is the access list of a block.
+type Entry struct {
+	Cid     cid.Cid
+	Allowed []peer.ID
+}
+
+// storedEntry is how an entry is written to the datastore.
+type storedEntry struct {
+	Cid     string
+	Allowed []string
+}
+
+// List is the set of the access lists of the node.
+type List struct {
+	dstore ds.Datastore
+
+	mu sync.RWMutex
+	// keyed by the multihashes of the blocks
+	entries map[string]*listEntry
+}
+
+type listEntry struct {
+	cid     cid.Cid
+	allowed map[peer.ID]bool
+}
+
+// Load reads the access lists stored in dstore. The changes made to the list
+// are written back to it.
+func Load(dstore ds.Datastore) (*List, error) {
+	l := &List{dstore: dstore, entries: make(map[string]*listEntry)}
+	data, err := dstore.Get(DatastoreKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return l, nil
+	default:
+		return nil, err
+	}
+
+	var stored []storedEntry
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid access lists: %s", err)
+	}
+	for _, se := range stored {
+		c, err := cid.Decode(se.Cid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cid %q in the access lists: %s", se.Cid, err)
+		}
+		e := &listEntry{cid: c, allowed: make(map[peer.ID]bool)}
+		for _, id := range se.Allowed {
+			p, err := peer.IDB58Decode(id)
+			if err != nil {
+				return nil, fmt.Errorf("invalid peer id %q in the access lists: %s", id, err)
+			}
+			e.allowed[p] = true
+		}
+		l.entries[string(c.Hash())] = e
+	}
+	return l, nil
+}
+
+// Allow adds the peers to the access list of the block c, creating it if the
+// block doesn't have one.
+func (l *List) Allow(c cid.Cid, peers ...peer.ID) error {
+	return l.AllowAll([]cid.Cid{c}, peers...)
+}
+
+// AllowAll adds the peers to the access lists of the blocks cids, as Allow,
+// writing the lists to the datastore once.
+func (l *List) AllowAll(cids []cid.Cid, peers ...peer.ID) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, c := range cids {
+		e, ok := l.entries[string(c.Hash())]
+		if !ok {
+			e = &listEntry{cid: c, allowed: make(map[peer.ID]bool)}
+			l.entries[string(c.Hash())] = e
+		}
+		for _, p := range peers {
+			e.allowed[p] = true
+		}
+	}
+	return l.store()
+}
+
+// Revoke removes the peers from the access list of the block c. The list is
+// kept even if no peer is left in it: the block is then served to nobody. It
+// reports whether the block has an access list.
+func (l *List) Revoke(c cid.Cid, peers ...peer.ID) (bool, error) {
+	revoked, err := l.RevokeAll([]cid.Cid{c}, peers...)
+	return len(revoked) > 0, err
+}
+
+// RevokeAll removes the peers from the access lists of the blocks cids, as
+// Revoke, writing the lists to the datastore once. It returns the CIDs of
+// the blocks with an access list.
+func (l *List) RevokeAll(cids []cid.Cid, peers ...peer.ID) ([]cid.Cid, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var revoked []cid.Cid
+	for _, c := range cids {
+		e, ok := l.entries[string(c.Hash())]
+		if !ok {
+			continue
+		}
+		for _, p := range peers {
+			delete(e.allowed, p)
+		}
+		revoked = append(revoked, c)
+	}
+	if len(revoked) == 0 {
+		return nil, nil
+	}
+	return revoked, l.store()
+}
+
+// Remove deletes the access list of the block c, which is then served to
+// every peer. It reports whether the block had one.
+func (l *List) Remove(c cid.Cid) (bool, error) {
+	removed, err := l.RemoveAll([]cid.Cid{c})
+	return len(removed) > 0, err
+}
+
+// RemoveAll deletes the access lists of the blocks cids, as Remove, writing
+// the lists to the datastore once. It returns the CIDs of the blocks that
+// had one.
+func (l *List) RemoveAll(cids []cid.Cid) ([]cid.Cid, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var removed []cid.Cid
+	for _, c := range cids {
+		if _, ok := l.entries[string(c.Hash())]; !ok {
+			continue
+		}
+		delete(l.entries, string(c.Hash()))
+		removed = append(removed, c)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	return removed, l.store()
+}
+
+// Allowed returns whether the block c can be sent to the peer p.
+func (l *List) Allowed(c cid.Cid, p peer.ID) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	e, ok := l.entries[string(c.Hash())]
+	return !ok || e.allowed[p]
+}
+
+// Entries returns the access lists, sorted by CID.
+func (l *List) Entries() []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make([]Entry, 0, len(l.entries))
+	for _, e := range l.entries {
+		out = append(out, Entry{Cid: e.cid, Allowed: e.peers()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Cid.String() < out[j].Cid.String() })
+	return out
+}
+
+func (e *listEntry) peers() []peer.ID {
+	peers := make([]peer.ID, 0, len(e.allowed))
+	for p := range e.allowed {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+	return peers
+}
+
+// store writes the access lists to the datastore, l.mu is held.
+func (l *List) store() error {
+	stored := make([]storedEntry, 0, len(l.entries))
+	for _, e := range l.entries {
+		se := storedEntry{Cid: e.cid.String()}
+		for _, p := range e.peers() {
+			se.Allowed = append(se.Allowed, p.Pretty())
+		}
+		stored = append(stored, se)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Cid < stored[j].Cid })
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return l.dstore.Put(DatastoreKey, data)
+}
@@ -0,0 +1,160 @@
+package accesscontrol
+
+import (
+	"testing"
+
+	bsmsg "github.com/ipfs/go-bitswap/message"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	testutil "github.com/libp2p/go-testutil"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestList(t *testing.T) {
+	alice, bob := testutil.RandPeerIDFatal(t), testutil.RandPeerIDFatal(t)
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	l, err := Load(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := blocks.NewBlock([]byte("restricted"))
+	v1 := cid.NewCidV1(cid.Raw, b.Cid().Hash())
+	if !l.Allowed(b.Cid(), alice) {
+		t.Fatal("a block without access list is served to everyone")
+	}
+
+	if err := l.Allow(b.Cid(), alice); err != nil {
+		t.Fatal(err)
+	}
+	if !l.Allowed(b.Cid(), alice) || l.Allowed(b.Cid(), bob) {
+		t.Fatal("only alice should be allowed")
+	}
+	if l.Allowed(v1, bob) {
+		t.Fatal("the access list should apply to every CID of the block")
+	}
+
+	// the lists are stored
+	l, err = Load(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := l.Entries()
+	if len(entries) != 1 || !entries[0].Cid.Equals(b.Cid()) || len(entries[0].Allowed) != 1 || entries[0].Allowed[0] != alice {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+
+	if ok, err := l.Revoke(b.Cid(), alice); err != nil || !ok {
+		t.Fatalf("expected alice to be revoked: %v, %v", ok, err)
+	}
+	if l.Allowed(b.Cid(), alice) {
+		t.Fatal("an empty access list allows nobody")
+	}
+
+	ok, err := l.Remove(b.Cid())
+	if err != nil || !ok {
+		t.Fatalf("expected the access list to be removed: %v, %v", ok, err)
+	}
+	if !l.Allowed(b.Cid(), bob) {
+		t.Fatal("the block should be served to everyone again")
+	}
+	if ok, _ := l.Remove(b.Cid()); ok {
+		t.Fatal("the access list was already removed")
+	}
+}
+
+// countingDatastore counts the Puts.
+type countingDatastore struct {
+	ds.Datastore
+	puts int
+}
+
+func (d *countingDatastore) Put(k ds.Key, v []byte) error {
+	d.puts++
+	return d.Datastore.Put(k, v)
+}
+
+func TestListBatch(t *testing.T) {
+	alice := testutil.RandPeerIDFatal(t)
+	dstore := &countingDatastore{Datastore: ds.NewMapDatastore()}
+	l, err := Load(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cids []cid.Cid
+	for _, data := range []string{"a", "b", "c"} {
+		cids = append(cids, blocks.NewBlock([]byte(data)).Cid())
+	}
+	if err := l.AllowAll(cids[:2], alice); err != nil {
+		t.Fatal(err)
+	}
+	if dstore.puts != 1 {
+		t.Fatalf("expected the lists to be written once, got %d writes", dstore.puts)
+	}
+	if len(l.Entries()) != 2 || l.Allowed(cids[0], testutil.RandPeerIDFatal(t)) {
+		t.Fatalf("unexpected entries %v", l.Entries())
+	}
+
+	revoked, err := l.RevokeAll(cids, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revoked) != 2 || dstore.puts != 2 || l.Allowed(cids[1], alice) {
+		t.Fatalf("expected the 2 lists to be revoked at once, got %v after %d writes", revoked, dstore.puts)
+	}
+
+	removed, err := l.RemoveAll(cids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || dstore.puts != 3 || len(l.Entries()) != 0 {
+		t.Fatalf("expected the 2 lists to be removed at once, got %v after %d writes", removed, dstore.puts)
+	}
+	if removed, _ := l.RemoveAll(cids); len(removed) != 0 || dstore.puts != 3 {
+		t.Fatal("nothing should be written without a list to remove")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	alice, bob := testutil.RandPeerIDFatal(t), testutil.RandPeerIDFatal(t)
+	l, err := Load(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restricted := blocks.NewBlock([]byte("restricted"))
+	public := blocks.NewBlock([]byte("public"))
+	if err := l.Allow(restricted.Cid(), alice); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := bsmsg.New(false)
+	msg.AddBlock(restricted)
+	msg.AddBlock(public)
+	want, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: mh.SHA2_256, MhLength: -1}.Sum([]byte("wanted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.AddEntry(want, 1)
+
+	out, ok := l.filter(alice, msg)
+	if !ok || len(out.Blocks()) != 2 {
+		t.Fatal("alice should receive both blocks")
+	}
+
+	out, ok = l.filter(bob, msg)
+	if !ok || len(out.Blocks()) != 1 || !out.Blocks()[0].Cid().Equals(public.Cid()) {
+		t.Fatal("bob should only receive the public block")
+	}
+	if len(out.Wantlist()) != 1 {
+		t.Fatal("the wantlist should be kept")
+	}
+
+	only := bsmsg.New(false)
+	only.AddBlock(restricted)
+	if _, ok := l.filter(bob, only); ok {
+		t.Fatal("nothing should be left to send to bob")
+	}
+}
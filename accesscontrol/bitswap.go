@@ -0,0 +1,82 @@
+package accesscontrol
+
+import (
+	"context"
+
+	bsmsg "github.com/ipfs/go-bitswap/message"
+	bsnet "github.com/ipfs/go-bitswap/network"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// WrapBitswapNetwork returns a bitswap network which doesn't send the blocks
+// to the peers their access list doesn't allow.
+func (l *List) WrapBitswapNetwork(n bsnet.BitSwapNetwork) bsnet.BitSwapNetwork {
+	return &aclNetwork{BitSwapNetwork: n, list: l}
+}
+
+type aclNetwork struct {
+	bsnet.BitSwapNetwork
+	list *List
+}
+
+func (an *aclNetwork) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	msg, ok := an.list.filter(p, msg)
+	if !ok {
+		return nil
+	}
+	return an.BitSwapNetwork.SendMessage(ctx, p, msg)
+}
+
+func (an *aclNetwork) NewMessageSender(ctx context.Context, p peer.ID) (bsnet.MessageSender, error) {
+	s, err := an.BitSwapNetwork.NewMessageSender(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &aclSender{MessageSender: s, list: an.list, peer: p}, nil
+}
+
+type aclSender struct {
+	bsnet.MessageSender
+	list *List
+	peer peer.ID
+}
+
+func (as *aclSender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	msg, ok := as.list.filter(as.peer, msg)
+	if !ok {
+		return nil
+	}
+	return as.MessageSender.SendMsg(ctx, msg)
+}
+
+// filter returns msg without the blocks p is not allowed to receive, and
+// false if nothing is left to send.
+func (l *List) filter(p peer.ID, msg bsmsg.BitSwapMessage) (bsmsg.BitSwapMessage, bool) {
+	denied := false
+	for _, b := range msg.Blocks() {
+		if !l.Allowed(b.Cid(), p) {
+			denied = true
+			break
+		}
+	}
+	if !denied {
+		return msg, true
+	}
+
+	out := bsmsg.New(msg.Full())
+	for _, e := range msg.Wantlist() {
+		if e.Cancel {
+			out.Cancel(e.Cid)
+		} else {
+			out.AddEntry(e.Cid, e.Priority)
+		}
+	}
+	for _, b := range msg.Blocks() {
+		if l.Allowed(b.Cid(), p) {
+			out.AddBlock(b)
+		} else {
+			log.Debugf("not sending %s to %s: denied by its access list", b.Cid(), p)
+		}
+	}
+	return out, !out.Empty() || out.Full()
+}
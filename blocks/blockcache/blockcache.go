@@ -0,0 +1,132 @@
+// Package blockcache provides a blockstore wrapper keeping recently read
+// blocks in memory.
+package blockcache
+
+import (
+	"context"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru/simplelru"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	metrics "github.com/ipfs/go-metrics-interface"
+)
+
+// DefaultMaxBlocks is the default number of blocks kept in the cache.
+const DefaultMaxBlocks = 1000
+
+// DefaultMaxBytes is the default limit of the total size of the cached
+// blocks.
+const DefaultMaxBytes = 64 << 20
+
+// Blockstore is a blockstore caching the blocks returned by Get in an LRU
+// cache bounded both in number of blocks and in bytes.
+type Blockstore struct {
+	bstore.Blockstore
+
+	mu       sync.Mutex
+	cache    *lru.LRU
+	size     int
+	maxBytes int
+
+	hits   metrics.Counter
+	misses metrics.Counter
+}
+
+// New wraps bs with a cache of at most maxBlocks blocks totalling at most
+// maxBytes bytes. The metrics are registered in the scope of ctx.
+func New(ctx context.Context, bs bstore.Blockstore, maxBlocks, maxBytes int) (*Blockstore, error) {
+	c := &Blockstore{
+		Blockstore: bs,
+		maxBytes:   maxBytes,
+		hits:       metrics.NewCtx(ctx, "blockcache.hits_total", "Number of block cache hits").Counter(),
+		misses:     metrics.NewCtx(ctx, "blockcache.misses_total", "Number of block cache misses").Counter(),
+	}
+
+	cache, err := lru.NewLRU(maxBlocks, c.onEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.cache = cache
+	return c, nil
+}
+
+// onEvict is called by the LRU with mu held.
+func (c *Blockstore) onEvict(_ interface{}, v interface{}) {
+	c.size -= len(v.(blocks.Block).RawData())
+}
+
+func (c *Blockstore) get(k cid.Cid) (blocks.Block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.cache.Get(k)
+	if !ok {
+		return nil, false
+	}
+	return v.(blocks.Block), true
+}
+
+func (c *Blockstore) add(b blocks.Block) {
+	n := len(b.RawData())
+	if n > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cache.Contains(b.Cid()) {
+		return
+	}
+	for c.size+n > c.maxBytes {
+		c.cache.RemoveOldest()
+	}
+	c.cache.Add(b.Cid(), b)
+	c.size += n
+}
+
+func (c *Blockstore) remove(k cid.Cid) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Remove(k)
+}
+
+func (c *Blockstore) Get(k cid.Cid) (blocks.Block, error) {
+	if b, ok := c.get(k); ok {
+		c.hits.Inc()
+		return b, nil
+	}
+	c.misses.Inc()
+
+	b, err := c.Blockstore.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	c.add(b)
+	return b, nil
+}
+
+func (c *Blockstore) Has(k cid.Cid) (bool, error) {
+	if _, ok := c.get(k); ok {
+		return true, nil
+	}
+	return c.Blockstore.Has(k)
+}
+
+func (c *Blockstore) GetSize(k cid.Cid) (int, error) {
+	if b, ok := c.get(k); ok {
+		return len(b.RawData()), nil
+	}
+	return c.Blockstore.GetSize(k)
+}
+
+func (c *Blockstore) DeleteBlock(k cid.Cid) error {
+	// also evict after the delete, in case a concurrent Get cached the
+	// block in between.
+	c.remove(k)
+	err := c.Blockstore.DeleteBlock(k)
+	c.remove(k)
+	return err
+}
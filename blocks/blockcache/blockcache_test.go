@@ -0,0 +1,104 @@
+package blockcache
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+type countingBlockstore struct {
+	bstore.Blockstore
+	gets int
+}
+
+func (bs *countingBlockstore) Get(k cid.Cid) (blocks.Block, error) {
+	bs.gets++
+	return bs.Blockstore.Get(k)
+}
+
+func newTestCache(t *testing.T, maxBlocks, maxBytes int) (*Blockstore, *countingBlockstore) {
+	base := &countingBlockstore{
+		Blockstore: bstore.NewBlockstore(syncds.MutexWrap(ds.NewMapDatastore())),
+	}
+	c, err := New(context.Background(), base, maxBlocks, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, base
+}
+
+func TestCacheHit(t *testing.T) {
+	c, base := newTestCache(t, 10, 1024)
+
+	b := blocks.NewBlock([]byte("foo"))
+	if err := c.Put(b); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		out, err := c.Get(b.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out.RawData()) != "foo" {
+			t.Fatal("wrong block returned")
+		}
+	}
+	if base.gets != 1 {
+		t.Fatalf("expected 1 read from the blockstore, got %d", base.gets)
+	}
+
+	if err := c.DeleteBlock(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(b.Cid()); err != bstore.ErrNotFound {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestCacheLimits(t *testing.T) {
+	c, base := newTestCache(t, 2, 8)
+
+	a := blocks.NewBlock([]byte("aaaa"))
+	b := blocks.NewBlock([]byte("bbbb"))
+	big := blocks.NewBlock([]byte("too big to be cached"))
+	for _, blk := range []blocks.Block{a, b, big} {
+		if err := c.Put(blk); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Get(blk.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a and b fit exactly, big is never cached
+	if _, err := c.Get(big.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if base.gets != 4 {
+		t.Fatalf("expected 4 reads from the blockstore, got %d", base.gets)
+	}
+
+	// a third small block evicts the least recently used one
+	d := blocks.NewBlock([]byte("dd"))
+	if err := c.Put(d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(d.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(a.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if base.gets != 6 {
+		t.Fatalf("expected the oldest block to be evicted, got %d reads", base.gets)
+	}
+	if c.size > 8 {
+		t.Fatalf("cache holds %d bytes, more than its limit", c.size)
+	}
+}
@@ -0,0 +1,153 @@
+// Package blockquota provides a blockstore wrapper rejecting new blocks once
+// the repo reaches its storage quota.
+//
+// Walking the repo on each write would be too slow, so the usage is read
+// once from the repo when the quota is enforced, and then tracked from the sizes
+// of the blocks put and deleted. It ignores the overhead of the datastore
+// and the other data of the repo written in the meantime, which are counted
+// again at the next start.
+package blockquota
+
+import (
+	"errors"
+	"sync"
+
+	humanize "github.com/dustin/go-humanize"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("blockquota")
+
+// ErrStorageFull is returned when putting blocks would exceed the quota.
+var ErrStorageFull = errors.New("storage full: the repo reached its storage quota, run 'ipfs repo gc' or raise it with 'ipfs storage quota set'")
+
+// WarnRatio is the ratio of the quota above which a warning is logged.
+const WarnRatio = 0.9
+
+// Blockstore is a blockstore enforcing a maximum repo size.
+type Blockstore struct {
+	bstore.Blockstore
+
+	mu     sync.Mutex
+	usage  uint64
+	max    uint64
+	warned bool
+}
+
+// New wraps bs, the repo already using usage bytes. A max of 0 disables the
+// quota, the usage being tracked anyway.
+func New(bs bstore.Blockstore, usage, max uint64) *Blockstore {
+	return &Blockstore{Blockstore: bs, usage: usage, max: max}
+}
+
+// SetMax changes the quota, 0 disabling it.
+func (q *Blockstore) SetMax(max uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.max = max
+	q.warned = false
+	q.checkWarn()
+}
+
+// SetUsage resets the tracked size of the repo to usage bytes.
+func (q *Blockstore) SetUsage(usage uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.usage = usage
+	q.warned = false
+	q.checkWarn()
+}
+
+// Usage returns the tracked size of the repo and its quota.
+func (q *Blockstore) Usage() (usage, max uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.usage, q.max
+}
+
+// reserve adds n bytes to the usage, failing if they don't fit in the quota.
+func (q *Blockstore) reserve(n uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.max > 0 && q.usage+n > q.max {
+		return ErrStorageFull
+	}
+	q.usage += n
+	q.checkWarn()
+	return nil
+}
+
+// release removes n bytes from the usage.
+func (q *Blockstore) release(n uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n > q.usage {
+		n = q.usage
+	}
+	q.usage -= n
+	q.checkWarn()
+}
+
+// checkWarn logs a warning the first time the usage goes above WarnRatio of
+// the quota. It is called with mu held.
+func (q *Blockstore) checkWarn() {
+	above := q.max > 0 && float64(q.usage) >= WarnRatio*float64(q.max)
+	if above && !q.warned {
+		log.Warningf("the repo uses %s of its %s storage quota", humanize.Bytes(q.usage), humanize.Bytes(q.max))
+	}
+	q.warned = above
+}
+
+// newSize returns the size of the blocks of bs which are not stored yet.
+func (q *Blockstore) newSize(bs ...blocks.Block) (uint64, error) {
+	var n uint64
+	for _, b := range bs {
+		has, err := q.Blockstore.Has(b.Cid())
+		if err != nil {
+			return 0, err
+		}
+		if !has {
+			n += uint64(len(b.RawData()))
+		}
+	}
+	return n, nil
+}
+
+func (q *Blockstore) Put(b blocks.Block) error {
+	return q.PutMany([]blocks.Block{b})
+}
+
+func (q *Blockstore) PutMany(bs []blocks.Block) error {
+	n, err := q.newSize(bs...)
+	if err != nil {
+		return err
+	}
+	if err := q.reserve(n); err != nil {
+		return err
+	}
+	if len(bs) == 1 {
+		err = q.Blockstore.Put(bs[0])
+	} else {
+		err = q.Blockstore.PutMany(bs)
+	}
+	if err != nil {
+		q.release(n)
+	}
+	return err
+}
+
+func (q *Blockstore) DeleteBlock(k cid.Cid) error {
+	size, err := q.Blockstore.GetSize(k)
+	if err != nil {
+		// not stored, let the blockstore report it
+		return q.Blockstore.DeleteBlock(k)
+	}
+	if err := q.Blockstore.DeleteBlock(k); err != nil {
+		return err
+	}
+	q.release(uint64(size))
+	return nil
+}
@@ -0,0 +1,75 @@
+package blockquota
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func newTestQuota(usage, max uint64) *Blockstore {
+	return New(bstore.NewBlockstore(syncds.MutexWrap(ds.NewMapDatastore())), usage, max)
+}
+
+func TestQuota(t *testing.T) {
+	q := newTestQuota(2, 10)
+
+	a := blocks.NewBlock([]byte("aaaa"))
+	b := blocks.NewBlock([]byte("bbbb"))
+	c := blocks.NewBlock([]byte("c"))
+	if err := q.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	// stored blocks are not counted twice
+	if err := q.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := q.Usage(); usage != 6 {
+		t.Fatalf("usage %d, expected 6", usage)
+	}
+
+	if err := q.PutMany([]blocks.Block{b, c}); err != ErrStorageFull {
+		t.Fatalf("expected %v, got %v", ErrStorageFull, err)
+	}
+	if has, _ := q.Has(b.Cid()); has {
+		t.Fatal("no block of a rejected batch should be stored")
+	}
+	if err := q.Put(b); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := q.Usage(); usage != 10 {
+		t.Fatalf("usage %d, expected 10", usage)
+	}
+
+	if err := q.DeleteBlock(a.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Put(c); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := q.Usage(); usage != 7 {
+		t.Fatalf("usage %d, expected 7 after the delete", usage)
+	}
+}
+
+func TestNoQuota(t *testing.T) {
+	q := newTestQuota(100, 0)
+	if err := q.Put(blocks.NewBlock([]byte("unlimited"))); err != nil {
+		t.Fatal(err)
+	}
+	if usage, max := q.Usage(); usage != 109 || max != 0 {
+		t.Fatalf("unexpected usage %d / %d", usage, max)
+	}
+
+	q.SetMax(100)
+	if err := q.Put(blocks.NewBlock([]byte("full"))); err != ErrStorageFull {
+		t.Fatalf("expected %v, got %v", ErrStorageFull, err)
+	}
+
+	q.SetUsage(0)
+	if err := q.Put(blocks.NewBlock([]byte("fits"))); err != nil {
+		t.Fatal(err)
+	}
+}
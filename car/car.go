@@ -0,0 +1,246 @@
+// Package car reads and writes CAR (Content Addressable aRchive) files, the
+// format used to move blocks between IPFS implementations.
+//
+// A CARv1 file is a header listing the root CIDs, encoded as dag-cbor,
+// followed by the blocks. Every piece is prefixed by its length as an
+// unsigned varint, and each block is stored as its CID followed by its data.
+// A CARv2 file wraps a CARv1 payload with a fixed size header and an
+// optional index.
+package car
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+)
+
+// maxSectionSize bounds the size of a header or block section, so a corrupted
+// length prefix doesn't make readers allocate unbounded amounts of memory.
+const maxSectionSize = 32 << 20
+
+// Header is the header of a CARv1 file.
+type Header struct {
+	Roots   []cid.Cid `refmt:"roots"`
+	Version uint64    `refmt:"version"`
+}
+
+func init() {
+	cbor.RegisterCborType(Header{})
+}
+
+// v2Pragma is the header of version 2 that starts every CARv2 file, so that
+// CARv1 readers reject them.
+var v2Pragma = []byte{0x0a, 0xa1, 0x67, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x02}
+
+// v2HeaderSize is the size of the fixed CARv2 header following the pragma.
+const v2HeaderSize = 40
+
+// Reader reads the blocks of a CARv1 or CARv2 file.
+type Reader struct {
+	Header  Header
+	Version uint64
+
+	r *bufio.Reader
+}
+
+// NewReader reads the header of a CAR file of either version, and returns a
+// Reader positioned on its first block.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.Version {
+	case 1:
+		return &Reader{Header: *h, Version: 1, r: br}, nil
+	case 2:
+	default:
+		return nil, fmt.Errorf("unsupported CAR version %d", h.Version)
+	}
+
+	var v2h [v2HeaderSize]byte
+	if _, err := io.ReadFull(br, v2h[:]); err != nil {
+		return nil, fmt.Errorf("reading CARv2 header: %s", err)
+	}
+	dataOffset := binary.LittleEndian.Uint64(v2h[16:])
+	dataSize := binary.LittleEndian.Uint64(v2h[24:])
+
+	consumed := uint64(len(v2Pragma) + v2HeaderSize)
+	if dataOffset < consumed {
+		return nil, fmt.Errorf("invalid CARv2 data offset %d", dataOffset)
+	}
+	if _, err := io.CopyN(ioutil.Discard, br, int64(dataOffset-consumed)); err != nil {
+		return nil, fmt.Errorf("seeking to CARv2 data: %s", err)
+	}
+
+	// the index, if any, follows the payload
+	inner := bufio.NewReader(io.LimitReader(br, int64(dataSize)))
+	h, err = readHeader(inner)
+	if err != nil {
+		return nil, err
+	}
+	if h.Version != 1 {
+		return nil, fmt.Errorf("CARv2 payload has version %d, expected 1", h.Version)
+	}
+	return &Reader{Header: *h, Version: 2, r: inner}, nil
+}
+
+func readHeader(r *bufio.Reader) (*Header, error) {
+	data, err := readSection(r)
+	if err == io.EOF {
+		return nil, errors.New("empty CAR file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading CAR header: %s", err)
+	}
+
+	var h Header
+	if err := cbor.DecodeInto(data, &h); err != nil {
+		return nil, fmt.Errorf("invalid CAR header: %s", err)
+	}
+	return &h, nil
+}
+
+// Next returns the next block of the file, or io.EOF after the last one. The
+// data of each block is checked against its CID.
+func (cr *Reader) Next() (blocks.Block, error) {
+	data, err := readSection(cr.r)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := cidLen(data)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cid.Cast(data[:n])
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := c.Prefix().Sum(data[n:])
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("block %s is corrupted, its data hashes to %s", c, sum)
+	}
+	return blocks.NewBlockWithCid(data[n:], c)
+}
+
+// readSection reads a length prefixed section. It returns io.EOF only if
+// there is no section left.
+func readSection(r *bufio.Reader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading section length: %s", err)
+	}
+	if l == 0 || l > maxSectionSize {
+		return nil, fmt.Errorf("invalid section length %d", l)
+	}
+
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// cidLen returns the length of the binary CID at the start of buf.
+func cidLen(buf []byte) (int, error) {
+	// CIDv0 are bare sha2-256 multihashes
+	if len(buf) >= 34 && buf[0] == 0x12 && buf[1] == 0x20 {
+		return 34, nil
+	}
+
+	n := 0
+	// version, codec and multihash code
+	for i := 0; i < 3; i++ {
+		_, l := binary.Uvarint(buf[n:])
+		if l <= 0 {
+			return 0, errors.New("invalid CID in block section")
+		}
+		n += l
+	}
+	digestLen, l := binary.Uvarint(buf[n:])
+	if l <= 0 || uint64(len(buf)-n-l) < digestLen {
+		return 0, errors.New("invalid CID in block section")
+	}
+	return n + l + int(digestLen), nil
+}
+
+// Writer writes a CARv1 file.
+type Writer struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+	n   uint64
+	idx *Index
+}
+
+// NewWriter writes the header of a CARv1 file with the given roots and returns
+// a Writer to add the blocks.
+func NewWriter(w io.Writer, roots []cid.Cid) (*Writer, error) {
+	data, err := cbor.DumpObject(&Header{Roots: roots, Version: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	cw := &Writer{w: w, idx: newIndex()}
+	if err := cw.writeSection(data); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Put appends a block to the file.
+func (cw *Writer) Put(b blocks.Block) error {
+	if err := cw.idx.add(b.Cid().Hash(), cw.n); err != nil {
+		return err
+	}
+	return cw.writeSection(b.Cid().Bytes(), b.RawData())
+}
+
+// Size returns the number of bytes written so far.
+func (cw *Writer) Size() uint64 {
+	return cw.n
+}
+
+// Index returns the index of the blocks written so far, for WriteV2.
+func (cw *Writer) Index() *Index {
+	return cw.idx
+}
+
+func (cw *Writer) writeSection(parts ...[]byte) error {
+	l := 0
+	for _, p := range parts {
+		l += len(p)
+	}
+
+	n := binary.PutUvarint(cw.buf[:], uint64(l))
+	if _, err := cw.w.Write(cw.buf[:n]); err != nil {
+		return err
+	}
+	cw.n += uint64(n)
+	for _, p := range parts {
+		if _, err := cw.w.Write(p); err != nil {
+			return err
+		}
+		cw.n += uint64(len(p))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package car
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func testBlocks(t *testing.T) []blocks.Block {
+	raw, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: mh.SHA2_256, MhLength: -1}.Sum([]byte("raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawBlk, err := blocks.NewBlockWithCid([]byte("raw"), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []blocks.Block{
+		blocks.NewBlock([]byte("foo")),
+		blocks.NewBlock([]byte("bar")),
+		rawBlk,
+	}
+}
+
+func writeCar(t *testing.T, blks []blocks.Block) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []cid.Cid{blks[0].Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blks {
+		if err := w.Put(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.Size() != uint64(buf.Len()) {
+		t.Fatalf("writer reports %d bytes, wrote %d", w.Size(), buf.Len())
+	}
+	return buf.Bytes()
+}
+
+func checkRead(t *testing.T, data []byte, version uint64, blks []blocks.Block) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Version != version {
+		t.Fatalf("expected version %d, got %d", version, r.Version)
+	}
+	if len(r.Header.Roots) != 1 || !r.Header.Roots[0].Equals(blks[0].Cid()) {
+		t.Fatalf("unexpected roots: %v", r.Header.Roots)
+	}
+
+	for _, b := range blks {
+		out, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !out.Cid().Equals(b.Cid()) || !bytes.Equal(out.RawData(), b.RawData()) {
+			t.Fatalf("expected block %s, got %s", b.Cid(), out.Cid())
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF after the last block, got %v", err)
+	}
+}
+
+func TestRoundtrip(t *testing.T) {
+	blks := testBlocks(t)
+	checkRead(t, writeCar(t, blks), 1, blks)
+}
+
+func TestReadV2(t *testing.T) {
+	blks := testBlocks(t)
+	payload := writeCar(t, blks)
+
+	var buf bytes.Buffer
+	buf.Write(v2Pragma)
+	var h [v2HeaderSize]byte
+	dataOffset := uint64(len(v2Pragma) + v2HeaderSize + 5)
+	binary.LittleEndian.PutUint64(h[16:], dataOffset)
+	binary.LittleEndian.PutUint64(h[24:], uint64(len(payload)))
+	buf.Write(h[:])
+	buf.Write(make([]byte, 5)) // padding
+	buf.Write(payload)
+	buf.Write([]byte("trailing index"))
+
+	checkRead(t, buf.Bytes(), 2, blks)
+}
+
+func TestReadCorrupted(t *testing.T) {
+	blks := testBlocks(t)
+	data := writeCar(t, blks)
+	data[len(data)-1] ^= 0xff
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			t.Fatal("corrupted block not detected")
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if _, err := NewReader(bytes.NewReader(nil)); err == nil {
+		t.Fatal("expected an error reading an empty file")
+	}
+}
+
+func TestWriteV2(t *testing.T) {
+	blks := testBlocks(t)
+
+	var payload bytes.Buffer
+	w, err := NewWriter(&payload, []cid.Cid{blks[0].Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blks {
+		if err := w.Put(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteV2(&buf, bytes.NewReader(payload.Bytes()), w.Size(), w.Index()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	checkRead(t, data, 2, blks)
+
+	indexOffset := binary.LittleEndian.Uint64(data[len(v2Pragma)+32:])
+	codec, n := binary.Uvarint(data[indexOffset:])
+	if codec != indexSortedCodec {
+		t.Fatalf("unexpected index codec %x", codec)
+	}
+	buckets := binary.LittleEndian.Uint32(data[indexOffset+uint64(n):])
+	// all test blocks use sha2-256
+	if buckets != 1 {
+		t.Fatalf("expected a single index bucket, got %d", buckets)
+	}
+}
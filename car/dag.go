@@ -0,0 +1,113 @@
+package car
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// importBatchSize is the number of blocks passed to the add function of
+// Import at once.
+const importBatchSize = 256
+
+// Import reads the blocks of the CAR file r and passes them to add, in
+// batches. It returns the header of the file and the number of blocks read.
+func Import(r io.Reader, add func([]blocks.Block) error) (*Header, int, error) {
+	cr, err := NewReader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count := 0
+	batch := make([]blocks.Block, 0, importBatchSize)
+	for {
+		b, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, count, err
+		}
+
+		batch = append(batch, b)
+		if len(batch) == importBatchSize {
+			if err := add(batch); err != nil {
+				return nil, count, err
+			}
+			count += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := add(batch); err != nil {
+			return nil, count, err
+		}
+		count += len(batch)
+	}
+	return &cr.Header, count, nil
+}
+
+// Export writes the DAG rooted at root as a CAR file of the given version,
+// 1 or 2.
+func Export(ctx context.Context, ng ipld.NodeGetter, root cid.Cid, version int, w io.Writer) error {
+	if version == 1 {
+		cw, err := NewWriter(w, []cid.Cid{root})
+		if err != nil {
+			return err
+		}
+		return WriteDAG(ctx, ng, root, cid.NewSet(), cw)
+	}
+
+	// the CARv2 header holds the size of the payload and the index follows
+	// it, so the payload is written to a temporary file first
+	tmp, err := ioutil.TempFile("", "ipfs-export-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	cw, err := NewWriter(tmp, []cid.Cid{root})
+	if err != nil {
+		return err
+	}
+	if err := WriteDAG(ctx, ng, root, cid.NewSet(), cw); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return WriteV2(w, tmp, cw.Size(), cw.Index())
+}
+
+// WriteDAG adds the blocks of the DAG rooted at root to cw, depth first and
+// without duplicates. Blocks already in seen are skipped along with their
+// children.
+func WriteDAG(ctx context.Context, ng ipld.NodeGetter, root cid.Cid, seen *cid.Set, cw *Writer) error {
+	var walk func(c cid.Cid) error
+	walk = func(c cid.Cid) error {
+		if !seen.Visit(c) {
+			return nil
+		}
+
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := cw.Put(nd); err != nil {
+			return err
+		}
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root)
+}
@@ -0,0 +1,59 @@
+package car
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	leaf := dag.NewRawNode([]byte("leaf"))
+	mid := dag.NodeWithData([]byte("mid"))
+	if err := mid.AddNodeLink("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	root := dag.NodeWithData([]byte("root"))
+	// the leaf is linked twice, but is written once
+	if err := root.AddNodeLink("mid", mid); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLink("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.AddMany(ctx, []ipld.Node{leaf, mid, root}); err != nil {
+		t.Fatal(err)
+	}
+	// dag-pb links are sorted by name
+	expected := []blocks.Block{root, leaf, mid}
+
+	for _, version := range []int{1, 2} {
+		var buf bytes.Buffer
+		if err := Export(ctx, ds, root.Cid(), version, &buf); err != nil {
+			t.Fatal(err)
+		}
+		checkRead(t, buf.Bytes(), uint64(version), expected)
+
+		var got []blocks.Block
+		header, count, err := Import(bytes.NewReader(buf.Bytes()), func(blks []blocks.Block) error {
+			got = append(got, blks...)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != len(expected) || len(got) != len(expected) {
+			t.Fatalf("version %d: imported %d blocks, expected %d", version, count, len(expected))
+		}
+		if len(header.Roots) != 1 || !header.Roots[0].Equals(root.Cid()) {
+			t.Fatalf("version %d: unexpected roots %v", version, header.Roots)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package car
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	mh "github.com/multiformats/go-multihash"
+)
+
+// indexSortedCodec is the multicodec of the "IndexSorted" CARv2 index format.
+const indexSortedCodec = 0x0400
+
+// Index records the offset of every block within a CARv1 payload, keyed by
+// multihash digest. It is serialized in the IndexSorted format of CARv2.
+type Index struct {
+	// buckets holds the records of each width, each record being the
+	// digest followed by the offset as a little endian uint64
+	buckets map[uint32][][]byte
+}
+
+func newIndex() *Index {
+	return &Index{buckets: make(map[uint32][][]byte)}
+}
+
+func (idx *Index) add(hash mh.Multihash, offset uint64) error {
+	dh, err := mh.Decode(hash)
+	if err != nil {
+		return err
+	}
+
+	width := uint32(len(dh.Digest) + 8)
+	rec := make([]byte, width)
+	copy(rec, dh.Digest)
+	binary.LittleEndian.PutUint64(rec[len(dh.Digest):], offset)
+	idx.buckets[width] = append(idx.buckets[width], rec)
+	return nil
+}
+
+// marshal writes the index, prefixed by its codec.
+func (idx *Index) marshal(w io.Writer) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], indexSortedCodec)
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+
+	widths := make([]uint32, 0, len(idx.buckets))
+	for width := range idx.buckets {
+		widths = append(widths, width)
+	}
+	sort.Slice(widths, func(i, j int) bool { return widths[i] < widths[j] })
+
+	if err := binary.Write(w, binary.LittleEndian, int32(len(widths))); err != nil {
+		return err
+	}
+	for _, width := range widths {
+		recs := idx.buckets[width]
+		sort.Slice(recs, func(i, j int) bool { return bytes.Compare(recs[i], recs[j]) < 0 })
+
+		if err := binary.Write(w, binary.LittleEndian, width); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, int64(len(recs))*int64(width)); err != nil {
+			return err
+		}
+		for _, rec := range recs {
+			if _, err := w.Write(rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteV2 writes a CARv2 file wrapping the CARv1 payload read from payload,
+// of the given size, and followed by idx.
+func WriteV2(w io.Writer, payload io.Reader, size uint64, idx *Index) error {
+	if _, err := w.Write(v2Pragma); err != nil {
+		return err
+	}
+
+	dataOffset := uint64(len(v2Pragma) + v2HeaderSize)
+	var h [v2HeaderSize]byte
+	// the characteristics bitfield, in the first 16 bytes, is left empty
+	binary.LittleEndian.PutUint64(h[16:], dataOffset)
+	binary.LittleEndian.PutUint64(h[24:], size)
+	binary.LittleEndian.PutUint64(h[32:], dataOffset+size)
+	if _, err := w.Write(h[:]); err != nil {
+		return err
+	}
+
+	n, err := io.Copy(w, payload)
+	if err != nil {
+		return err
+	}
+	if uint64(n) != size {
+		return io.ErrUnexpectedEOF
+	}
+
+	return idx.marshal(w)
+}
@@ -0,0 +1,83 @@
+// Package chunkerprofiles defines chunker profiles, named presets of the chunker
+// option of 'ipfs add'.
+//
+// The built-in profiles are in Profiles. Custom ones can be defined in the
+// config, under Chunker.Profiles, as a map of names to chunker strings:
+//
+//	"Chunker": {
+//	  "Profiles": {
+//	    "small": "rabin-16384-65536-262144"
+//	  }
+//	}
+//
+// A custom profile takes precedence over a built-in one of the same name.
+package chunkerprofiles
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	gochunker "github.com/ipfs/go-ipfs-chunker"
+)
+
+// ProfilesConfigKey is the config section of the custom chunker profiles.
+const ProfilesConfigKey = "Chunker.Profiles"
+
+// Profiles are the built-in chunker profiles, by name.
+//
+// The FastCDC and buzhash chunkers aren't available yet, neither are chunks
+// over the 1MiB block size limit, so there are no profiles for large files or
+// text yet.
+var Profiles = map[string]string{
+	// default is the fixed size chunker used when no chunker is given.
+	"default": "size-262144",
+
+	// dedup-aggressive finds the duplicate data moved around between files
+	// or versions of a file, at the cost of more, smaller blocks.
+	"dedup-aggressive": "rabin-65536-262144-1048576",
+}
+
+// CustomProfiles returns the custom profiles defined in the config of r.
+func CustomProfiles(r repo.Repo) (map[string]string, error) {
+	var profiles map[string]string
+	if _, err := repo.ReadConfigKey(r, ProfilesConfigKey, &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// Lookup returns the chunker of the profile name, looking it up in custom
+// first, then in Profiles.
+func Lookup(name string, custom map[string]string) (string, error) {
+	chunker, ok := custom[name]
+	if !ok {
+		chunker, ok = Profiles[name]
+	}
+	if !ok {
+		return "", fmt.Errorf("unknown chunker profile %q, the profiles are: %s", name, strings.Join(names(custom), ", "))
+	}
+
+	// catch invalid custom profiles before adding anything
+	if _, err := gochunker.FromString(bytes.NewReader(nil), chunker); err != nil {
+		return "", fmt.Errorf("chunker profile %q: %s", name, err)
+	}
+	return chunker, nil
+}
+
+func names(custom map[string]string) []string {
+	var out []string
+	for name := range Profiles {
+		if _, ok := custom[name]; !ok {
+			out = append(out, name)
+		}
+	}
+	for name := range custom {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
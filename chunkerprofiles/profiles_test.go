@@ -0,0 +1,36 @@
+package chunkerprofiles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	custom := map[string]string{
+		"small":   "size-1024",
+		"default": "size-2048",
+		"broken":  "rabin-1-2",
+	}
+
+	for name, expected := range map[string]string{
+		"dedup-aggressive": Profiles["dedup-aggressive"],
+		"small":            "size-1024",
+		"default":          "size-2048",
+	} {
+		chunker, err := Lookup(name, custom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunker != expected {
+			t.Fatalf("profile %s: expected %s, got %s", name, expected, chunker)
+		}
+	}
+
+	if _, err := Lookup("broken", custom); err == nil {
+		t.Fatal("expected an invalid profile to fail")
+	}
+	_, err := Lookup("unknown", custom)
+	if err == nil || !strings.Contains(err.Error(), "broken, dedup-aggressive, default, small") {
+		t.Fatalf("expected the profiles to be listed, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	commands "github.com/ipfs/go-ipfs/core/commands"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+// expandAlias replaces the command of args, if it is an alias defined in the
+// config of the repo, with the command line the alias stands for. Aliases are
+// expanded once: an expansion naming another alias fails as an unknown
+// command.
+func expandAlias(root *cmds.Command, args []string) []string {
+	i, repoPath := commandIndex(root, args)
+	if i < 0 {
+		return args
+	}
+	if _, ok := root.Subcommands[args[i]]; ok {
+		return args
+	}
+
+	if repoPath == "" {
+		var err error
+		repoPath, err = fsrepo.BestKnownPath()
+		if err != nil {
+			return args
+		}
+	}
+	aliases, err := readAliases(repoPath)
+	if err != nil {
+		log.Debugf("failed to read the command aliases: %s", err)
+		return args
+	}
+	expansion, ok := aliases[args[i]]
+	if !ok {
+		return args
+	}
+
+	expanded := append([]string{}, args[:i]...)
+	expanded = append(expanded, strings.Fields(expansion)...)
+	return append(expanded, args[i+1:]...)
+}
+
+// commandIndex returns the index in args of the first command word, skipping
+// the global options and their values, and the value of the config option if
+// it is given. The index is -1 if there is no command.
+func commandIndex(root *cmds.Command, args []string) (int, string) {
+	valued := make(map[string]bool)
+	for _, opt := range root.Options {
+		if opt.Type() != cmdkit.String {
+			continue
+		}
+		for _, name := range opt.Names() {
+			valued[name] = true
+		}
+	}
+
+	var repoPath string
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return i, repoPath
+		}
+		name := strings.TrimLeft(arg, "-")
+		value, hasValue := "", false
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+		if !valued[name] {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if name == commands.ConfigOption || name == "c" {
+			repoPath = value
+		}
+	}
+	return -1, repoPath
+}
+
+// readAliases reads the aliases from the config file of the repo at repoPath.
+// The repo is not opened, as it may be locked by a running daemon.
+func readAliases(repoPath string) (map[string]string, error) {
+	fn, err := config.Filename(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	val, ok := cfg[commands.AliasesConfigKey]
+	if !ok {
+		return nil, nil
+	}
+	return commands.ParseAliases(val)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandAlias(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alias")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := `{"Aliases": {"add-nocopy": "add --nocopy --raw-leaves", "nested": "add-nocopy"}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "config"), []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args, expected string
+	}{
+		{"ipfs -c DIR add-nocopy -r foo", "ipfs -c DIR add --nocopy --raw-leaves -r foo"},
+		{"ipfs --config=DIR --api /ip4/127.0.0.1/tcp/5001 add-nocopy foo", "ipfs --config=DIR --api /ip4/127.0.0.1/tcp/5001 add --nocopy --raw-leaves foo"},
+		{"ipfs -c DIR -D add-nocopy", "ipfs -c DIR -D add --nocopy --raw-leaves"},
+		// commands are never expanded
+		{"ipfs -c DIR add add-nocopy", "ipfs -c DIR add add-nocopy"},
+		// aliases are not recursive
+		{"ipfs -c DIR nested foo", "ipfs -c DIR add-nocopy foo"},
+		{"ipfs -c DIR unknown", "ipfs -c DIR unknown"},
+		{"ipfs -c DIR", "ipfs -c DIR"},
+	} {
+		args := strings.Fields(strings.Replace(tc.args, "DIR", dir, -1))
+		expected := strings.Replace(tc.expected, "DIR", dir, -1)
+		if got := strings.Join(expandAlias(Root, args), " "); got != expected {
+			t.Errorf("expanding %q: got %q, expected %q", tc.args, got, expected)
+		}
+	}
+}
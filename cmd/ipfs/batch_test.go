@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	commands "github.com/ipfs/go-ipfs/core/commands"
+)
+
+func TestBatchRunsOnDaemon(t *testing.T) {
+	for _, name := range commands.BatchCommands() {
+		path := strings.Fields(name)
+		// the details of a command apply to its subcommands
+		for i := range path {
+			if details, ok := cmdDetailsMap[strings.Join(path[:i+1], "/")]; ok && details.cannotRunOnDaemon {
+				t.Errorf("'ipfs %s' can be batched but can't run on the daemon", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corecmds "github.com/ipfs/go-ipfs/core/commands"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// cidHistorySize is the number of CIDs kept in the history offered by the
+// shell completion scripts.
+const cidHistorySize = 100
+
+// recordCidHistory adds the CIDs among args to the CID history of the repo
+// at repoPath, most recent last. Nothing is written if the repo does not
+// exist.
+func recordCidHistory(repoPath string, args []string) error {
+	var used []string
+	for _, arg := range args {
+		if c, ok := argCid(arg); ok && !contains(used, c) {
+			used = append(used, c)
+		}
+	}
+	if len(used) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(repoPath); err != nil {
+		return nil
+	}
+
+	fn := filepath.Join(repoPath, corecmds.CidHistoryFile)
+	data, err := ioutil.ReadFile(fn)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" && !contains(used, line) {
+			history = append(history, line)
+		}
+	}
+	history = append(history, used...)
+	if len(history) > cidHistorySize {
+		history = history[len(history)-cidHistorySize:]
+	}
+	return ioutil.WriteFile(fn, []byte(strings.Join(history, "\n")+"\n"), 0644)
+}
+
+// argCid returns the CID of arg, if it is a CID or an /ipfs/ path.
+func argCid(arg string) (string, bool) {
+	p := strings.TrimPrefix(arg, "/ipfs/")
+	p = strings.SplitN(p, "/", 2)[0]
+	c, err := cid.Decode(p)
+	if err != nil {
+		return "", false
+	}
+	return c.String(), true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
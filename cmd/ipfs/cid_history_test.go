@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corecmds "github.com/ipfs/go-ipfs/core/commands"
+
+	blocks "github.com/ipfs/go-block-format"
+)
+
+func TestRecordCidHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cid-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const (
+		a = "QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN"
+		b = "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	)
+	read := func() string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, corecmds.CidHistoryFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	if err := recordCidHistory(dir, []string{a, "not-a-cid", "/ipfs/" + b + "/file"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != a+"\n"+b+"\n" {
+		t.Fatalf("unexpected history %q", got)
+	}
+
+	// a CID used again moves to the end
+	if err := recordCidHistory(dir, []string{a}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != b+"\n"+a+"\n" {
+		t.Fatalf("unexpected history %q", got)
+	}
+
+	// only the last CIDs are kept
+	var many []string
+	for i := 0; i < cidHistorySize+10; i++ {
+		many = append(many, blocks.NewBlock([]byte(fmt.Sprint(i))).Cid().String())
+	}
+	if err := recordCidHistory(dir, many); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(read()), "\n")
+	if len(lines) != cidHistorySize || lines[len(lines)-1] != many[len(many)-1] {
+		t.Fatalf("the history has %d lines, expected the last %d CIDs", len(lines), cidHistorySize)
+	}
+
+	// nothing is written outside of a repo
+	if err := recordCidHistory(filepath.Join(dir, "missing"), []string{a}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatal("expected the missing repo not to be created")
+	}
+}
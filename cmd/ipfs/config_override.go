@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/repo/common"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+const configOverrideOptionName = "config-override"
+
+// configOverrideOption is only listed in the help: the option can be
+// repeated, which the command line parser doesn't allow, so it is taken out
+// of the arguments before they are parsed.
+var configOverrideOption = cmdkit.StringOption(configOverrideOptionName, "Override a config value for this invocation, as key=value. Can be repeated.")
+
+// configOverrides are the config overrides given on the command line, as
+// key=value.
+var configOverrides []string
+
+// extractConfigOverrides returns args without the config overrides, and the
+// overrides.
+func extractConfigOverrides(args []string) ([]string, []string, error) {
+	var rest, overrides []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if arg == "--"+configOverrideOptionName {
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf("missing value for --%s", configOverrideOptionName)
+			}
+			i++
+			overrides = append(overrides, args[i])
+			continue
+		}
+		if v := strings.TrimPrefix(arg, "--"+configOverrideOptionName+"="); v != arg {
+			overrides = append(overrides, v)
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	for _, o := range overrides {
+		if _, _, err := parseConfigOverride(o); err != nil {
+			return nil, nil, err
+		}
+	}
+	return rest, overrides, nil
+}
+
+// parseConfigOverride returns the key and the value of a key=value override.
+// The value is a JSON scalar, or a string if it isn't valid JSON or the key
+// is a string.
+func parseConfigOverride(override string) (string, interface{}, error) {
+	kv := strings.SplitN(override, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return "", nil, fmt.Errorf("invalid config override %q, expected key=value", override)
+	}
+	key := kv[0]
+
+	t, err := configKeyType(reflect.TypeOf(config.Config{}), key)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid config override %q: %s", override, err)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(kv[1]), &value); err != nil {
+		return key, kv[1], nil
+	}
+	switch value.(type) {
+	case string:
+	case map[string]interface{}, []interface{}:
+		return "", nil, fmt.Errorf("invalid config override %q: the value must be a scalar", override)
+	default:
+		if t.Kind() == reflect.String {
+			return key, kv[1], nil
+		}
+	}
+	return key, value, nil
+}
+
+// configKeyType returns the type of the key, a dot separated path of fields,
+// in a config of type t.
+func configKeyType(t reflect.Type, key string) (reflect.Type, error) {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := configField(t, part)
+			if !ok {
+				return nil, fmt.Errorf("the config has no %s key", strings.Join(parts[:i+1], "."))
+			}
+			t = f.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("the %s key of the config has no fields", strings.Join(parts[:i], "."))
+		}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t, nil
+}
+
+// configField returns the field of t stored under name in the config file.
+func configField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+		if jsonName == "-" {
+			continue
+		}
+		if jsonName == name || (jsonName == "" && f.Name == name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// applyConfigOverrides returns a copy of cfg with the overrides applied.
+func applyConfigOverrides(cfg *config.Config, overrides []string) (*config.Config, error) {
+	if len(overrides) == 0 {
+		return cfg, nil
+	}
+	m, err := config.ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		key, value, err := parseConfigOverride(o)
+		if err != nil {
+			return nil, err
+		}
+		if err := common.MapSetKV(m, key, value); err != nil {
+			return nil, fmt.Errorf("invalid config override %q: %s", o, err)
+		}
+	}
+	cfg, err = config.FromMap(m)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config overrides: %s", err)
+	}
+	return cfg, nil
+}
+
+// overrideRepo is a repo whose config has the config overrides applied. The
+// config stored in the repo is left unchanged.
+type overrideRepo struct {
+	repo.Repo
+	overrides []string
+}
+
+func (r *overrideRepo) Config() (*config.Config, error) {
+	cfg, err := r.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	return applyConfigOverrides(cfg, r.overrides)
+}
+
+func (r *overrideRepo) GetConfigKey(key string) (interface{}, error) {
+	v, getErr := r.Repo.GetConfigKey(key)
+	root := map[string]interface{}{"v": copyConfigValue(v)}
+	applied := false
+	for _, o := range r.overrides {
+		k, value, err := parseConfigOverride(o)
+		if err != nil {
+			return nil, err
+		}
+		rel, ok := overrideRelKey(key, k)
+		if !ok {
+			continue
+		}
+		if err := common.MapSetKV(root, rel, value); err != nil {
+			return nil, fmt.Errorf("invalid config override %q: %s", o, err)
+		}
+		applied = true
+	}
+	if !applied {
+		return v, getErr
+	}
+	return root["v"], nil
+}
+
+// SetConfig writes cfg with the overridden keys still holding their override
+// set back to their stored value: a config read with the overrides applied
+// can be written back without storing them.
+func (r *overrideRepo) SetConfig(cfg *config.Config) error {
+	m, err := config.ToMap(cfg)
+	if err != nil {
+		return err
+	}
+	v, err := r.restoreOverrides("", m)
+	if err != nil {
+		return err
+	}
+	cfg, err = config.FromMap(v.(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	return r.Repo.SetConfig(cfg)
+}
+
+// SetConfigKey writes value like SetConfig, the overridden keys under key
+// still holding their override being set back to their stored value.
+func (r *overrideRepo) SetConfigKey(key string, value interface{}) error {
+	v, err := r.restoreOverrides(key, value)
+	if err != nil {
+		return err
+	}
+	return r.Repo.SetConfigKey(key, v)
+}
+
+// restoreOverrides returns a copy of value, the value of key, with the
+// overridden keys holding their override set back to their stored value. The
+// key "" is the whole config. A key changed from its override is kept.
+func (r *overrideRepo) restoreOverrides(key string, value interface{}) (interface{}, error) {
+	stored, err := r.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	storedMap, err := config.ToMap(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	root := map[string]interface{}{"v": copyConfigValue(value)}
+	for _, o := range r.overrides {
+		k, override, err := parseConfigOverride(o)
+		if err != nil {
+			return nil, err
+		}
+		rel, ok := overrideRelKey(key, k)
+		if !ok {
+			continue
+		}
+		if cur, err := common.MapGetKV(root, rel); err != nil || !reflect.DeepEqual(cur, override) {
+			continue
+		}
+		orig, err := common.MapGetKV(storedMap, k)
+		if err != nil {
+			return nil, err
+		}
+		if err := common.MapSetKV(root, rel, orig); err != nil {
+			return nil, err
+		}
+	}
+	return root["v"], nil
+}
+
+// overrideRelKey returns the path of the overridden key k in a map holding
+// the value of key under "v", and whether k is key or one of its sub-keys.
+// The key "" is the whole config.
+func overrideRelKey(key, k string) (string, bool) {
+	switch {
+	case key == "":
+		return "v." + k, true
+	case k == key:
+		return "v", true
+	case strings.HasPrefix(k, key+"."):
+		return "v." + strings.TrimPrefix(k, key+"."), true
+	}
+	return "", false
+}
+
+// copyConfigValue returns a deep copy of v, a generic config value, for the
+// overrides to be set without changing v.
+func copyConfigValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var c interface{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return v
+	}
+	return c
+}
+
+// withConfigOverrides returns r with the config overrides of the command line
+// applied to its config.
+func withConfigOverrides(r repo.Repo) repo.Repo {
+	if len(configOverrides) == 0 {
+		return r
+	}
+	return &overrideRepo{Repo: r, overrides: configOverrides}
+}
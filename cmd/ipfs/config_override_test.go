@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/repo/common"
+
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+func TestExtractConfigOverrides(t *testing.T) {
+	args := strings.Fields("ipfs --config-override Routing.Type=dht add --config-override=Datastore.StorageMax=1GB -r -- --config-override")
+	rest, overrides, err := extractConfigOverrides(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rest, " ") != "ipfs add -r -- --config-override" {
+		t.Fatalf("unexpected args %q", rest)
+	}
+	if strings.Join(overrides, " ") != "Routing.Type=dht Datastore.StorageMax=1GB" {
+		t.Fatalf("unexpected overrides %q", overrides)
+	}
+
+	for _, args := range []string{
+		"ipfs id --config-override",
+		"ipfs id --config-override Routing.Type",
+		"ipfs id --config-override Routing.Nope=dht",
+		"ipfs id --config-override Routing.Type.Foo=dht",
+		"ipfs id --config-override Bootstrap=[]",
+	} {
+		if _, _, err := extractConfigOverrides(strings.Fields(args)); err == nil {
+			t.Errorf("expected %q to fail", args)
+		}
+	}
+}
+
+func TestApplyConfigOverrides(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Routing.Type = "dht"
+	cfg.Datastore.StorageMax = "10GB"
+
+	overridden, err := applyConfigOverrides(cfg, []string{
+		"Routing.Type=none",
+		"Datastore.StorageMax=1GB",
+		"Datastore.GCPeriod=\"1h\"",
+		"Swarm.DisableNatPortMap=true",
+		"Datastore.StorageGCWatermark=50",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overridden.Routing.Type != "none" || overridden.Datastore.StorageMax != "1GB" ||
+		overridden.Datastore.GCPeriod != "1h" || !overridden.Swarm.DisableNatPortMap ||
+		overridden.Datastore.StorageGCWatermark != 50 {
+		t.Fatalf("the overrides were not applied: %+v", overridden)
+	}
+	if cfg.Routing.Type != "dht" {
+		t.Fatal("the original config was modified")
+	}
+
+	if _, err := applyConfigOverrides(cfg, []string{"Swarm.DisableNatPortMap=maybe"}); err == nil {
+		t.Fatal("expected a value of the wrong type to fail")
+	}
+}
+
+// keyRepo is a mock repo supporting SetConfigKey.
+type keyRepo struct {
+	repo.Mock
+}
+
+func (r *keyRepo) SetConfigKey(key string, value interface{}) error {
+	m, err := config.ToMap(&r.C)
+	if err != nil {
+		return err
+	}
+	if err := common.MapSetKV(m, key, value); err != nil {
+		return err
+	}
+	cfg, err := config.FromMap(m)
+	if err != nil {
+		return err
+	}
+	r.C = *cfg
+	return nil
+}
+
+func TestOverrideRepo(t *testing.T) {
+	stored := &keyRepo{}
+	stored.C.Routing.Type = "dht"
+	stored.C.Datastore.StorageMax = "10GB"
+	r := &overrideRepo{Repo: stored, overrides: []string{"Routing.Type=none", "Datastore.StorageMax=1GB"}}
+
+	v, err := r.GetConfigKey("Routing.Type")
+	if err != nil || v != "none" {
+		t.Fatalf("expected the overridden key, got %v, %v", v, err)
+	}
+	v, err = r.GetConfigKey("Datastore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds := v.(map[string]interface{}); ds["StorageMax"] != "1GB" {
+		t.Fatalf("expected the override applied to the section, got %v", ds)
+	}
+
+	// writing back the config read keeps the stored values of the overrides
+	cfg, err := r.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Datastore.GCPeriod = "1h"
+	cfg.Datastore.StorageMax = "5GB"
+	if err := r.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if stored.C.Routing.Type != "dht" || stored.C.Datastore.GCPeriod != "1h" || stored.C.Datastore.StorageMax != "5GB" {
+		t.Fatalf("expected only the changes to be stored, got %+v", stored.C)
+	}
+
+	v, err = r.GetConfigKey("Routing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetConfigKey("Routing", v); err != nil {
+		t.Fatal(err)
+	}
+	if stored.C.Routing.Type != "dht" {
+		t.Fatalf("expected the override not to be stored, got %q", stored.C.Routing.Type)
+	}
+	if err := r.SetConfigKey("Routing.Type", "dhtclient"); err != nil {
+		t.Fatal(err)
+	}
+	if stored.C.Routing.Type != "dhtclient" {
+		t.Fatalf("expected the new value to be stored, got %q", stored.C.Routing.Type)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"errors"
 	_ "expvar"
 	"fmt"
@@ -22,10 +23,12 @@ import (
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	nodeMount "github.com/ipfs/go-ipfs/fuse/node"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	configcrypt "github.com/ipfs/go-ipfs/repo/fsrepo/configcrypt"
 	migrate "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	config "github.com/ipfs/go-ipfs-config"
 	mprome "github.com/ipfs/go-metrics-prometheus"
 	goprocess "github.com/jbenet/goprocess"
 	ma "github.com/multiformats/go-multiaddr"
@@ -44,10 +47,14 @@ const (
 	mountKwd                  = "mount"
 	offlineKwd                = "offline" // global option
 	routingOptionKwd          = "routing"
+	routingBackendKwd         = "routing-backend"
 	routingOptionSupernodeKwd = "supernode"
 	routingOptionDHTClientKwd = "dhtclient"
 	routingOptionDHTKwd       = "dht"
+	routingOptionDelegatedKwd = "delegated"
+	routingOptionHybridKwd    = "hybrid"
 	routingOptionNoneKwd      = "none"
+	routingOptionOfflineKwd   = "offline"
 	routingOptionDefaultKwd   = "default"
 	unencryptTransportKwd     = "disable-transport-encryption"
 	unrestrictedApiAccessKwd  = "unrestricted-api"
@@ -55,6 +62,9 @@ const (
 	enablePubSubKwd           = "enable-pubsub-experiment"
 	enableIPNSPubSubKwd       = "enable-namesys-pubsub"
 	enableMultiplexKwd        = "enable-mplex-experiment"
+	noBlockCacheKwd           = "no-block-cache"
+	webdavAddrKwd             = "webdav-addr"
+	webdavAllowRemoteKwd      = "webdav-allow-remote"
 	// apiAddrKwd    = "address-api"
 	// swarmAddrKwd  = "address-swarm"
 )
@@ -139,6 +149,52 @@ can be enabled by running the daemon as:
 This will later be transitioned into a config option once it gets out of the
 'experimental' stage.
 
+The routing backend set by Routing.Type can be overridden for a single run,
+without changing the config, with '--routing-backend':
+
+  dht        Find and announce content on the DHT. The node keeps
+             connections to many peers and answers their queries, which
+             costs bandwidth, and its requests are seen by the DHT peers
+             it walks through.
+  delegated  Send all routing requests to the HTTP routing server set in
+             Routing.DelegatedEndpoint (https://delegated-ipfs.dev by
+             default). Lookups are a single HTTP request, usually much faster
+             than a DHT walk, and the node uses very few resources, but the
+             server sees every CID and name the node looks up, and the node
+             announces nothing: its content can only be found if the server
+             indexes it on its own.
+  hybrid     Query the DHT and the delegated server in parallel and use the
+             first answer, announcing content on the DHT. This combines the
+             speed of the delegated server with the coverage of the DHT, at
+             the cost of both in resources and privacy.
+  none       No content routing. Only peers already connected are asked for
+             blocks.
+
+WebDAV
+
+'--webdav-addr' serves the MFS root ('ipfs files') over WebDAV on the given
+address, so that it can be mounted as a network drive:
+
+  ipfs daemon --webdav-addr /ip4/127.0.0.1/tcp/8090
+
+The files can be read, written, moved and deleted, and the directories
+created. The content of IPFS is readable under /ipfs/<cid>, which hides any
+MFS directory named 'ipfs'. The sizes of the files are listed from UnixFS,
+which doesn't record modification times in this version: all files are
+listed as modified at the Unix epoch. The server has no authentication: it
+only listens on a loopback address and only serves the requests for a
+loopback host, unless '--webdav-allow-remote' is given. Like the API, it
+refuses the requests of the web pages of other origins.
+
+Offline mode
+
+Nodes used purely as local content-addressed storage can run without any
+networking with '--offline' or '--routing=offline' (the latter can be made
+permanent with 'ipfs config profile apply offline'). In offline mode no
+libp2p host is constructed, so the DHT, bitswap and pubsub never start and no
+swarm listeners are opened. The datastore, MFS, the HTTP API and the gateway
+keep working for content that is available locally.
+
 DEPRECATION NOTICE
 
 Previously, ipfs used an environment variable as seen below:
@@ -155,6 +211,7 @@ Headers.
 		cmdkit.BoolOption(initOptionKwd, "Initialize ipfs with default settings if not already initialized"),
 		cmdkit.StringOption(initProfileOptionKwd, "Configuration profiles to apply for --init. See ipfs init --help for more"),
 		cmdkit.StringOption(routingOptionKwd, "Overrides the routing option").WithDefault(routingOptionDefaultKwd),
+		cmdkit.StringOption(routingBackendKwd, "Overrides Routing.Type for this run: dht, delegated, hybrid or none."),
 		cmdkit.BoolOption(mountKwd, "Mounts IPFS to the filesystem"),
 		cmdkit.BoolOption(writableKwd, "Enable writing objects (with POST, PUT and DELETE)"),
 		cmdkit.StringOption(ipfsMountKwd, "Path to the mountpoint for IPFS (if using --mount). Defaults to config setting."),
@@ -167,6 +224,9 @@ Headers.
 		cmdkit.BoolOption(enablePubSubKwd, "Instantiate the ipfs daemon with the experimental pubsub feature enabled."),
 		cmdkit.BoolOption(enableIPNSPubSubKwd, "Enable IPNS record distribution through pubsub; enables pubsub."),
 		cmdkit.BoolOption(enableMultiplexKwd, "Add the experimental 'go-multiplex' stream muxer to libp2p on construction.").WithDefault(true),
+		cmdkit.BoolOption(noBlockCacheKwd, "Disable the in-memory block cache (for debugging)."),
+		cmdkit.StringOption(webdavAddrKwd, "Serve MFS over WebDAV on this multiaddr."),
+		cmdkit.BoolOption(webdavAllowRemoteKwd, "Serve WebDAV on a non-loopback address, without authentication."),
 
 		// TODO: add way to override addresses. tricky part: updating the config if also --init.
 		// cmdkit.StringOption(apiAddrKwd, "Address for the daemon rpc API (overrides config)"),
@@ -241,6 +301,11 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		}
 	}
 
+	// decrypt the config fields of 'ipfs config encrypt'
+	fsrepo.ConfigPassphrase = func() (string, error) {
+		return configcrypt.Passphrase("Enter the passphrase of the config: ")
+	}
+
 	// acquire the repo lock _before_ constructing a node. we need to make
 	// sure we are permitted to access the resources (datastore, etc.)
 	repo, err := fsrepo.Open(cctx.ConfigRoot)
@@ -261,7 +326,7 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 			return fmt.Errorf("fs-repo requires migration")
 		}
 
-		err = migrate.RunMigration(fsrepo.RepoVersion)
+		err = migrate.RunMigration(cctx.ConfigRoot, fsrepo.RepoVersion)
 		if err != nil {
 			fmt.Println("The migrations of fs-repo failed:")
 			fmt.Printf("  %s\n", err)
@@ -283,37 +348,54 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		return err
 	}
 
+	routingOption, _ := req.Options[routingOptionKwd].(string)
+	if backend, ok := req.Options[routingBackendKwd].(string); ok {
+		if routingOption != routingOptionDefaultKwd {
+			return fmt.Errorf("--%s and --%s cannot be used together", routingOptionKwd, routingBackendKwd)
+		}
+		switch backend {
+		case routingOptionDHTKwd, routingOptionDelegatedKwd, routingOptionHybridKwd, routingOptionNoneKwd:
+		default:
+			return fmt.Errorf("unrecognized routing backend: %s (expected dht, delegated, hybrid or none)", backend)
+		}
+		routingOption = backend
+	}
+	if routingOption == routingOptionDefaultKwd {
+		routingOption = cfg.Routing.Type
+		if routingOption == "" {
+			routingOption = routingOptionDHTKwd
+		}
+	}
+
 	offline, _ := req.Options[offlineKwd].(bool)
+	if routingOption == routingOptionOfflineKwd {
+		// no routing at all: don't even construct a libp2p host
+		offline = true
+	}
+
 	ipnsps, _ := req.Options[enableIPNSPubSubKwd].(bool)
 	pubsub, _ := req.Options[enablePubSubKwd].(bool)
 	mplex, _ := req.Options[enableMultiplexKwd].(bool)
+	noBlockCache, _ := req.Options[noBlockCacheKwd].(bool)
+	if offline && (pubsub || ipnsps) {
+		log.Warning("pubsub is not available in offline mode, ignoring --enable-pubsub-experiment and --enable-namesys-pubsub")
+	}
 
 	// Start assembling node config
 	ncfg := &core.BuildCfg{
-		Repo:                        repo,
+		Repo:                        withConfigOverrides(repo),
 		Permanent:                   true, // It is temporary way to signify that node is permanent
 		Online:                      !offline,
 		DisableEncryptedConnections: unencrypted,
 		ExtraOpts: map[string]bool{
-			"pubsub": pubsub,
-			"ipnsps": ipnsps,
-			"mplex":  mplex,
+			"pubsub":       pubsub,
+			"ipnsps":       ipnsps,
+			"mplex":        mplex,
+			"noblockcache": noBlockCache,
 		},
 		//TODO(Kubuxu): refactor Online vs Offline by adding Permanent vs Ephemeral
 	}
 
-	routingOption, _ := req.Options[routingOptionKwd].(string)
-	if routingOption == routingOptionDefaultKwd {
-		cfg, err := repo.Config()
-		if err != nil {
-			return err
-		}
-
-		routingOption = cfg.Routing.Type
-		if routingOption == "" {
-			routingOption = routingOptionDHTKwd
-		}
-	}
 	switch routingOption {
 	case routingOptionSupernodeKwd:
 		return errors.New("supernode routing was never fully implemented and has been removed")
@@ -321,7 +403,17 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		ncfg.Routing = core.DHTClientOption
 	case routingOptionDHTKwd:
 		ncfg.Routing = core.DHTOption
-	case routingOptionNoneKwd:
+	case routingOptionDelegatedKwd, routingOptionHybridKwd:
+		endpoint, err := core.DelegatedRoutingEndpoint(repo)
+		if err != nil {
+			return err
+		}
+		if routingOption == routingOptionDelegatedKwd {
+			ncfg.Routing = core.DelegatedRoutingOption(endpoint)
+		} else {
+			ncfg.Routing = core.HybridRoutingOption(endpoint)
+		}
+	case routingOptionNoneKwd, routingOptionOfflineKwd:
 		ncfg.Routing = core.NilRouterOption
 	default:
 		return fmt.Errorf("unrecognized routing option: %s", routingOption)
@@ -369,6 +461,19 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 	}
 	node.Process().AddChild(goprocess.WithTeardown(cctx.Plugins.Close))
 
+	// construct the gateway handler before the api, so that the api can
+	// reload its config
+	var gwReloader *corehttp.GatewayReloader
+	if len(cfg.Addresses.Gateway) > 0 {
+		gwReloader, err = corehttp.NewGatewayReloader(node, gatewayWritable(req, cfg))
+		if err != nil {
+			return err
+		}
+		cctx.ReloadGateway = func() ([]string, error) {
+			return reloadGatewayConfig(cctx.ConfigRoot, gwReloader)
+		}
+	}
+
 	// construct api endpoint - every time
 	apiErrc, err := serveHTTPApi(req, cctx)
 	if err != nil {
@@ -394,17 +499,31 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 
 	// construct http gateway - if it is set in the config
 	var gwErrc <-chan error
-	if len(cfg.Addresses.Gateway) > 0 {
+	if gwReloader != nil {
 		var err error
-		gwErrc, err = serveHTTPGateway(req, cctx)
+		gwErrc, err = serveHTTPGateway(req, cctx, gwReloader)
 		if err != nil {
 			return err
 		}
 	}
 
+	// construct webdav server - if the user provided the --webdav-addr flag
+	davErrc, err := serveWebDAV(req, cctx)
+	if err != nil {
+		return err
+	}
+
 	// initialize metrics collector
 	prometheus.MustRegister(&corehttp.IpfsNodeCollector{Node: node})
 
+	// a service misconfigured shouldn't keep the daemon from starting
+	if err := commands.StartRemotePinAutoSyncs(node); err != nil {
+		log.Errorf("starting the remote pin syncs: %s", err)
+	}
+	if err := commands.StartGCSchedule(node); err != nil {
+		log.Errorf("starting the scheduled GC: %s", err)
+	}
+
 	// The daemon is *finally* ready.
 	fmt.Printf("Daemon is ready\n")
 
@@ -417,7 +536,7 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 
 	// collect long-running errors and block for shutdown
 	// TODO(cryptix): our fuse currently doesnt follow this pattern for graceful shutdown
-	for err := range merge(apiErrc, gwErrc, gcErrc) {
+	for err := range merge(apiErrc, gwErrc, gcErrc, davErrc) {
 		if err != nil {
 			return err
 		}
@@ -546,17 +665,44 @@ func printSwarmAddrs(node *core.IpfsNode) {
 
 }
 
+// gatewayWritable returns whether the gateway should accept writes, the
+// daemon flag taking precedence over the config.
+func gatewayWritable(req *cmds.Request, cfg *config.Config) bool {
+	writable, writableOptionFound := req.Options[writableKwd].(bool)
+	if !writableOptionFound {
+		writable = cfg.Gateway.Writable
+	}
+	return writable
+}
+
+// reloadGatewayConfig reads the gateway section of the config file and
+// applies it to the running gateway.
+func reloadGatewayConfig(repoPath string, gw *corehttp.GatewayReloader) ([]string, error) {
+	cfg, err := fsrepo.ConfigAt(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := gw.Reload(cfg.Gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Gateway config reloaded (%d changes)\n", len(changes))
+	for _, c := range changes {
+		fmt.Printf("  %s\n", c)
+	}
+	return changes, nil
+}
+
 // serveHTTPGateway collects options, creates listener, prints status message and starts serving requests
-func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error) {
+func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context, gw *corehttp.GatewayReloader) (<-chan error, error) {
 	cfg, err := cctx.GetConfig()
 	if err != nil {
 		return nil, fmt.Errorf("serveHTTPGateway: GetConfig() failed: %s", err)
 	}
 
-	writable, writableOptionFound := req.Options[writableKwd].(bool)
-	if !writableOptionFound {
-		writable = cfg.Gateway.Writable
-	}
+	writable := gatewayWritable(req, cfg)
 
 	gatewayAddrs := cfg.Addresses.Gateway
 	listeners := make([]manet.Listener, 0, len(gatewayAddrs))
@@ -587,8 +733,9 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 
 	var opts = []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("gateway"),
+		gw.SubdomainOption(),
 		corehttp.IPNSHostnameOption(),
-		corehttp.GatewayOption(writable, "/ipfs", "/ipns"),
+		gw.Option("/ipfs", "/ipns"),
 		corehttp.VersionOption(),
 		corehttp.CheckVersionOption(),
 		corehttp.CommandsROOption(cmdctx),
@@ -607,13 +754,25 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		return nil, fmt.Errorf("serveHTTPGateway: ConstructNode() failed: %s", err)
 	}
 
+	tlsConfig, err := corehttp.GatewayTLSConfig(node.Repo, cctx.ConfigRoot)
+	if err != nil {
+		return nil, fmt.Errorf("serveHTTPGateway: %s", err)
+	}
+	if tlsConfig != nil {
+		fmt.Println("Gateway serving HTTPS with the certificates of Gateway.PublicGateways")
+	}
+
 	errc := make(chan error)
 	var wg sync.WaitGroup
 	for _, lis := range listeners {
 		wg.Add(1)
 		go func(lis manet.Listener) {
 			defer wg.Done()
-			errc <- corehttp.Serve(node, manet.NetListener(lis), opts...)
+			nl := manet.NetListener(lis)
+			if tlsConfig != nil {
+				nl = tls.NewListener(nl, tlsConfig)
+			}
+			errc <- corehttp.Serve(node, nl, opts...)
 		}(lis)
 	}
 
@@ -625,6 +784,42 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 	return errc, nil
 }
 
+// serveWebDAV starts the WebDAV server of MFS on the --webdav-addr address,
+// if set.
+func serveWebDAV(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error) {
+	addr, _ := req.Options[webdavAddrKwd].(string)
+	if addr == "" {
+		return nil, nil
+	}
+
+	davMaddr, err := ma.NewMultiaddr(addr)
+	if err != nil {
+		return nil, fmt.Errorf("serveWebDAV: invalid WebDAV address: %q (err: %s)", addr, err)
+	}
+	remote, _ := req.Options[webdavAllowRemoteKwd].(bool)
+	if !remote && !manet.IsIPLoopback(davMaddr) {
+		return nil, fmt.Errorf("serveWebDAV: refusing to serve WebDAV without authentication on the non-loopback address %s, give --%s to serve it anyway", davMaddr, webdavAllowRemoteKwd)
+	}
+	davLis, err := manet.Listen(davMaddr)
+	if err != nil {
+		return nil, fmt.Errorf("serveWebDAV: manet.Listen(%s) failed: %s", davMaddr, err)
+	}
+	// we might have listened to /tcp/0 - lets see what we are listing on
+	fmt.Printf("WebDAV server listening on %s\n", davLis.Multiaddr())
+
+	node, err := cctx.ConstructNode()
+	if err != nil {
+		return nil, fmt.Errorf("serveWebDAV: ConstructNode() failed: %s", err)
+	}
+
+	errc := make(chan error)
+	go func() {
+		errc <- corehttp.Serve(node, manet.NetListener(davLis), corehttp.WebDAVOption(remote))
+		close(errc)
+	}()
+	return errc, nil
+}
+
 //collects options and opens the fuse mountpoint
 func mountFuse(req *cmds.Request, cctx *oldcmds.Context) error {
 	cfg, err := cctx.GetConfig()
@@ -2,17 +2,21 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"reflect"
+	"sort"
 	"strings"
 
 	assets "github.com/ipfs/go-ipfs/assets"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	commands "github.com/ipfs/go-ipfs/core/commands"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
@@ -20,13 +24,18 @@ import (
 	"github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/go-ipfs-config"
 	"github.com/ipfs/go-ipfs-files"
+	ci "github.com/libp2p/go-libp2p-crypto"
+	peer "github.com/libp2p/go-libp2p-peer"
 )
 
 const (
 	nBitsForKeypairDefault = 2048
+	keyTypeDefault         = "ed25519"
 	bitsOptionName         = "bits"
+	keyTypeOptionName      = "key-type"
 	emptyRepoOptionName    = "empty-repo"
 	profileOptionName      = "profile"
+	fromConfigOptionName   = "from-config"
 )
 
 var initCmd = &cmds.Command{
@@ -38,8 +47,28 @@ Initializes ipfs configuration files and generates a new keypair.
 If you are going to run IPFS in server environment, you may want to
 initialize it using 'server' profile.
 
+Several profiles can be combined by separating them with commas, for example
+'--profile=server,lowpower'. They are applied in order, so a setting changed
+by more than one profile takes the value of the last one. All profiles are
+checked before any is applied, and the settings each of them changed are
+printed.
+
 For the list of available profiles see 'ipfs config profile --help'
 
+To share settings between many nodes, '--from-config=<file>' merges a
+partial config over the generated one: objects are merged key by key, any
+other value replaces the default one. It is applied after the profiles, so
+the values in the file take precedence. The file cannot contain an Identity,
+each node generates its own.
+
+The identity key of the node is an ed25519 key by default. Use
+'--key-type=rsa' to generate an RSA key instead, for example to stay
+compatible with older peers and tools; '--bits' sets its size, and implies
+'--key-type=rsa' when no type is given, like before ed25519 was the default. Existing
+nodes keep their key: the type only matters when a new repo is created.
+The Identity section of docs/config.md explains how to rotate the key of an
+existing node.
+
 ipfs uses a repository in the local file system. By default, the repo is
 located at ~/.ipfs. To change the repo location, set the $IPFS_PATH
 environment variable:
@@ -51,9 +80,11 @@ environment variable:
 		cmdkit.FileArg("default-config", false, false, "Initialize with the given configuration.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
-		cmdkit.IntOption(bitsOptionName, "b", "Number of bits to use in the generated RSA private key.").WithDefault(nBitsForKeypairDefault),
+		cmdkit.StringOption(keyTypeOptionName, "t", "Type of the generated identity key: ed25519 or rsa. Default: ed25519, or rsa with --bits."),
+		cmdkit.IntOption(bitsOptionName, "b", "Number of bits to use in the generated RSA private key. Default: 2048."),
 		cmdkit.BoolOption(emptyRepoOptionName, "e", "Don't add and pin help files to the local storage."),
 		cmdkit.StringOption(profileOptionName, "p", "Apply profile settings to config. Multiple profiles can be separated by ','"),
+		cmdkit.StringOption(fromConfigOptionName, "Merge the given partial config file over the generated config."),
 
 		// TODO need to decide whether to expose the override as a file or a
 		// directory. That is: should we allow the user to also specify the
@@ -79,7 +110,20 @@ environment variable:
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		cctx := env.(*oldcmds.Context)
 		empty, _ := req.Options[emptyRepoOptionName].(bool)
-		nBitsForKeypair, _ := req.Options[bitsOptionName].(int)
+		nBitsForKeypair, bitsSet := req.Options[bitsOptionName].(int)
+		keyType, keyTypeSet := req.Options[keyTypeOptionName].(string)
+		if !keyTypeSet {
+			keyType = keyTypeDefault
+			if bitsSet {
+				// --bits predates the key types, it still makes an RSA key
+				keyType = "rsa"
+			}
+		}
+		if !bitsSet {
+			nBitsForKeypair = nBitsForKeypairDefault
+		} else if !strings.EqualFold(keyType, "rsa") {
+			return cmdkit.Errorf(cmdkit.ErrClient, "--%s only applies to RSA keys, use it with --%s=rsa", bitsOptionName, keyTypeOptionName)
+		}
 
 		var conf *config.Config
 
@@ -103,14 +147,17 @@ environment variable:
 			}
 		}
 
-		profile, _ := req.Options[profileOptionName].(string)
-
-		var profiles []string
-		if profile != "" {
-			profiles = strings.Split(profile, ",")
+		var overlay map[string]interface{}
+		if fn, ok := req.Options[fromConfigOptionName].(string); ok {
+			var err error
+			overlay, err = readConfigOverlay(fn)
+			if err != nil {
+				return err
+			}
 		}
 
-		return doInit(os.Stdout, cctx.ConfigRoot, empty, nBitsForKeypair, profiles, conf)
+		profile, _ := req.Options[profileOptionName].(string)
+		return doInit(os.Stdout, cctx.ConfigRoot, empty, keyType, nBitsForKeypair, splitProfiles(profile), conf, overlay)
 	},
 }
 
@@ -119,15 +166,21 @@ Reinitializing would overwrite your keys.
 `)
 
 func initWithDefaults(out io.Writer, repoRoot string, profile string) error {
+	return doInit(out, repoRoot, false, keyTypeDefault, nBitsForKeypairDefault, splitProfiles(profile), nil, nil)
+}
+
+// splitProfiles splits a comma separated list of profile names.
+func splitProfiles(profile string) []string {
 	var profiles []string
-	if profile != "" {
-		profiles = strings.Split(profile, ",")
+	for _, p := range strings.Split(profile, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			profiles = append(profiles, p)
+		}
 	}
-
-	return doInit(out, repoRoot, false, nBitsForKeypairDefault, profiles, nil)
+	return profiles
 }
 
-func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, confProfiles []string, conf *config.Config) error {
+func doInit(out io.Writer, repoRoot string, empty bool, keyType string, nBitsForKeypair int, confProfiles []string, conf *config.Config, overlay map[string]interface{}) error {
 	if _, err := fmt.Fprintf(out, "initializing IPFS node at %s\n", repoRoot); err != nil {
 		return err
 	}
@@ -140,25 +193,58 @@ func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, con
 		return errRepoExists
 	}
 
+	// check all the profiles before generating the keys
+	transformers := make([]config.Transformer, 0, len(confProfiles))
+	for _, profile := range confProfiles {
+		transformer, ok := commands.ConfigProfile(profile)
+		if !ok {
+			return fmt.Errorf("invalid configuration profile: %s", profile)
+		}
+		transformers = append(transformers, transformer.Transform)
+	}
+
 	if conf == nil {
 		var err error
-		conf, err = config.Init(out, nBitsForKeypair)
+		conf, err = initConfig(out, keyType, nBitsForKeypair)
 		if err != nil {
 			return err
 		}
 	}
 
-	for _, profile := range confProfiles {
-		transformer, ok := config.Profiles[profile]
-		if !ok {
-			return fmt.Errorf("invalid configuration profile: %s", profile)
+	for i, transformer := range transformers {
+		before, err := config.ToMap(conf)
+		if err != nil {
+			return err
+		}
+		if err := transformer(conf); err != nil {
+			return err
+		}
+		after, err := config.ToMap(conf)
+		if err != nil {
+			return err
 		}
 
-		if err := transformer.Transform(conf); err != nil {
+		changed := "no changes"
+		if keys := changedConfigKeys(before, after); len(keys) > 0 {
+			changed = "changed " + strings.Join(keys, ", ")
+		}
+		if _, err := fmt.Fprintf(out, "applied profile %s: %s\n", confProfiles[i], changed); err != nil {
 			return err
 		}
 	}
 
+	if overlay != nil {
+		cfgMap, err := config.ToMap(conf)
+		if err != nil {
+			return err
+		}
+		mergeConfigMaps(cfgMap, overlay)
+		conf, err = config.FromMap(cfgMap)
+		if err != nil {
+			return fmt.Errorf("invalid config after merging --%s: %s", fromConfigOptionName, err)
+		}
+	}
+
 	if err := fsrepo.Init(repoRoot, conf); err != nil {
 		return err
 	}
@@ -172,6 +258,176 @@ func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, con
 	return initializeIpnsKeyspace(repoRoot)
 }
 
+// initConfig generates the default config with an identity key of the given
+// type.
+func initConfig(out io.Writer, keyType string, nBitsForKeypair int) (*config.Config, error) {
+	switch strings.ToLower(keyType) {
+	case "rsa":
+		return config.Init(out, nBitsForKeypair)
+	case "ed25519":
+	default:
+		return nil, fmt.Errorf("unknown key type: %s (expected ed25519 or rsa)", keyType)
+	}
+
+	fmt.Fprintf(out, "generating ED25519 keypair...")
+	sk, pk, err := ci.GenerateKeyPair(ci.Ed25519, -1)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(out, "done\n")
+
+	skbytes, err := sk.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	identity := config.Identity{
+		PeerID:  id.Pretty(),
+		PrivKey: base64.StdEncoding.EncodeToString(skbytes),
+	}
+	fmt.Fprintf(out, "peer identity: %s\n", identity.PeerID)
+	return defaultConfig(identity)
+}
+
+// defaultConfig returns the config config.Init generates, with the given
+// identity: config.Init only knows how to generate RSA keys.
+func defaultConfig(identity config.Identity) (*config.Config, error) {
+	bootstrapPeers, err := config.DefaultBootstrapPeers()
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.Config{
+		API: config.API{
+			HTTPHeaders: map[string][]string{},
+		},
+		Addresses: config.Addresses{
+			Swarm: []string{
+				"/ip4/0.0.0.0/tcp/4001",
+				"/ip6/::/tcp/4001",
+			},
+			Announce:   []string{},
+			NoAnnounce: []string{},
+			API:        config.Strings{"/ip4/127.0.0.1/tcp/5001"},
+			Gateway:    config.Strings{"/ip4/127.0.0.1/tcp/8080"},
+		},
+		Datastore: config.DefaultDatastoreConfig(),
+		Bootstrap: config.BootstrapPeerStrings(bootstrapPeers),
+		Identity:  identity,
+		Discovery: config.Discovery{
+			MDNS: config.MDNS{
+				Enabled:  true,
+				Interval: 10,
+			},
+		},
+		Routing: config.Routing{
+			Type: "dht",
+		},
+		Mounts: config.Mounts{
+			IPFS: "/ipfs",
+			IPNS: "/ipns",
+		},
+		Ipns: config.Ipns{
+			ResolveCacheSize: 128,
+		},
+		Gateway: config.Gateway{
+			PathPrefixes: []string{},
+			HTTPHeaders: map[string][]string{
+				"Access-Control-Allow-Origin":  {"*"},
+				"Access-Control-Allow-Methods": {"GET"},
+				"Access-Control-Allow-Headers": {"X-Requested-With", "Range", "User-Agent"},
+			},
+			APICommands: []string{},
+		},
+		Reprovider: config.Reprovider{
+			Interval: "12h",
+			Strategy: "all",
+		},
+		Swarm: config.SwarmConfig{
+			ConnMgr: config.ConnMgr{
+				LowWater:    config.DefaultConnMgrLowWater,
+				HighWater:   config.DefaultConnMgrHighWater,
+				GracePeriod: config.DefaultConnMgrGracePeriod.String(),
+				Type:        "basic",
+			},
+		},
+	}, nil
+}
+
+// readConfigOverlay reads a partial config file for --from-config.
+func readConfigOverlay(fn string) (map[string]interface{}, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var overlay map[string]interface{}
+	if err := json.NewDecoder(f).Decode(&overlay); err != nil {
+		return nil, fmt.Errorf("failure to decode %s: %s", fn, err)
+	}
+	for k := range overlay {
+		if strings.EqualFold(k, config.IdentityTag) {
+			return nil, fmt.Errorf("%s: the identity of a node cannot be set with --%s", fn, fromConfigOptionName)
+		}
+	}
+	return overlay, nil
+}
+
+// mergeConfigMaps merges overlay into dst. Nested objects are merged
+// recursively, other values are replaced. Keys are matched case
+// insensitively, like when decoding the config, but keep the spelling of dst.
+func mergeConfigMaps(dst, overlay map[string]interface{}) {
+	for k, v := range overlay {
+		for dk := range dst {
+			if strings.EqualFold(dk, k) {
+				k = dk
+				break
+			}
+		}
+
+		dm, dok := dst[k].(map[string]interface{})
+		om, ook := v.(map[string]interface{})
+		if dok && ook {
+			mergeConfigMaps(dm, om)
+		} else {
+			dst[k] = v
+		}
+	}
+}
+
+// changedConfigKeys returns the sorted paths of the config values that differ
+// between two configs, as given to 'ipfs config'.
+func changedConfigKeys(before, after map[string]interface{}) []string {
+	var keys []string
+	var walk func(prefix string, a, b map[string]interface{})
+	walk = func(prefix string, a, b map[string]interface{}) {
+		seen := make(map[string]bool)
+		for _, m := range []map[string]interface{}{a, b} {
+			for k := range m {
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+
+				am, aok := a[k].(map[string]interface{})
+				bm, bok := b[k].(map[string]interface{})
+				if aok && bok {
+					walk(prefix+k+".", am, bm)
+				} else if !reflect.DeepEqual(a[k], b[k]) {
+					keys = append(keys, prefix+k)
+				}
+			}
+		}
+	}
+	walk("", before, after)
+	sort.Strings(keys)
+	return keys
+}
+
 func checkWritable(dir string) error {
 	_, err := os.Stat(dir)
 	if err == nil {
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+func TestChangedConfigKeys(t *testing.T) {
+	before := map[string]interface{}{
+		"Same":    "a",
+		"Changed": "a",
+		"Removed": true,
+		"Nested": map[string]interface{}{
+			"Same": []interface{}{"x"},
+			"List": []interface{}{"x"},
+		},
+	}
+	after := map[string]interface{}{
+		"Same":    "a",
+		"Changed": "b",
+		"Added":   1,
+		"Nested": map[string]interface{}{
+			"Same": []interface{}{"x"},
+			"List": []interface{}{"x", "y"},
+		},
+	}
+
+	keys := changedConfigKeys(before, after)
+	expect := []string{"Added", "Changed", "Nested.List", "Removed"}
+	if !reflect.DeepEqual(keys, expect) {
+		t.Fatalf("expected %v, got %v", expect, keys)
+	}
+}
+
+func TestSplitProfiles(t *testing.T) {
+	profiles := splitProfiles(" server, ,lowpower")
+	if !reflect.DeepEqual(profiles, []string{"server", "lowpower"}) {
+		t.Fatalf("unexpected profiles: %v", profiles)
+	}
+	if splitProfiles("") != nil {
+		t.Fatal("expected no profiles")
+	}
+}
+
+func TestMergeConfigMaps(t *testing.T) {
+	dst := map[string]interface{}{
+		"Bootstrap": []interface{}{"a"},
+		"Gateway": map[string]interface{}{
+			"Writable":    false,
+			"HTTPHeaders": map[string]interface{}{"A": []interface{}{"1"}},
+		},
+	}
+	mergeConfigMaps(dst, map[string]interface{}{
+		"bootstrap": []interface{}{},
+		"Gateway": map[string]interface{}{
+			"HTTPHeaders": map[string]interface{}{"B": []interface{}{"2"}},
+		},
+	})
+
+	expect := map[string]interface{}{
+		"Bootstrap": []interface{}{},
+		"Gateway": map[string]interface{}{
+			"Writable": false,
+			"HTTPHeaders": map[string]interface{}{
+				"A": []interface{}{"1"},
+				"B": []interface{}{"2"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(dst, expect) {
+		t.Fatalf("expected %v, got %v", expect, dst)
+	}
+}
+
+func TestInitConfigEd25519(t *testing.T) {
+	conf, err := initConfig(ioutil.Discard, "ed25519", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Identity.PeerID == "" || conf.Identity.PrivKey == "" {
+		t.Fatal("no identity generated")
+	}
+
+	// the rest of the config must not drift from the one of config.Init
+	rsaConf, err := config.Init(ioutil.Discard, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Identity = rsaConf.Identity
+	if !reflect.DeepEqual(conf, rsaConf) {
+		t.Fatalf("the ed25519 config differs from the default one:\n%+v\n%+v", conf, rsaConf)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	commands "github.com/ipfs/go-ipfs/core/commands"
 
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 )
 
@@ -12,7 +13,7 @@ import (
 // Some subcommands (like 'ipfs daemon' or 'ipfs init') are only accessible here,
 // and can't be called through the HTTP API.
 var Root = &cmds.Command{
-	Options:  commands.Root.Options,
+	Options:  append(append([]cmdkit.Option{}, commands.Root.Options...), configOverrideOption),
 	Helptext: commands.Root.Helptext,
 }
 
@@ -84,13 +85,17 @@ func (d *cmdDetails) usesRepo() bool          { return !d.doesNotUseRepo }
 // properties so that other code can make decisions about whether to invoke a
 // command or return an error to the user.
 var cmdDetailsMap = map[string]cmdDetails{
-	"init":        {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
-	"daemon":      {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
-	"commands":    {doesNotUseRepo: true},
-	"version":     {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
-	"log":         {cannotRunOnClient: true},
-	"diag/cmds":   {cannotRunOnClient: true},
-	"repo/fsck":   {cannotRunOnDaemon: true},
-	"config/edit": {cannotRunOnDaemon: true, doesNotUseRepo: true},
-	"cid":         {doesNotUseRepo: true},
+	"init":               {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"daemon":             {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
+	"commands":           {doesNotUseRepo: true},
+	"version":            {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
+	"log":                {cannotRunOnClient: true},
+	"gateway":            {cannotRunOnClient: true},
+	"diag/cmds":          {cannotRunOnClient: true},
+	"repo/fsck":          {cannotRunOnDaemon: true},
+	"repo/shard-migrate": {cannotRunOnDaemon: true},
+	"datastore/migrate":  {cannotRunOnDaemon: true},
+	"config/edit":        {cannotRunOnDaemon: true, doesNotUseRepo: true},
+	"config/validate":    {doesNotUseRepo: true},
+	"cid":                {doesNotUseRepo: true},
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime/pprof"
@@ -16,6 +17,7 @@ import (
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	corecmds "github.com/ipfs/go-ipfs/core/commands"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
 	loader "github.com/ipfs/go-ipfs/plugin/loader"
 	repo "github.com/ipfs/go-ipfs/repo"
@@ -128,6 +130,16 @@ func mainRet() int {
 		}
 	}
 
+	// Handle `ipfs <alias> [args...]`
+	os.Args = expandAlias(Root, os.Args)
+
+	// Handle `--config-override key=value`, which can be repeated
+	os.Args, configOverrides, err = extractConfigOverrides(os.Args)
+	if err != nil {
+		printErr(err)
+		return 1
+	}
+
 	// output depends on executable name passed in os.Args
 	// so we need to make sure it's stable
 	os.Args[0] = "ipfs"
@@ -165,7 +177,7 @@ func mainRet() int {
 				// ok everything is good. set it on the invocation (for ownership)
 				// and return it.
 				n, err = core.NewNode(ctx, &core.BuildCfg{
-					Repo: r,
+					Repo: withConfigOverrides(r),
 				})
 				if err != nil {
 					return nil, err
@@ -204,12 +216,24 @@ func makeExecutor(req *cmds.Request, env interface{}) (cmds.Executor, error) {
 		return nil, err
 	}
 
+	if client != nil && len(configOverrides) > 0 {
+		return nil, fmt.Errorf("--%s can't be applied to the commands run by the daemon", configOverrideOptionName)
+	}
+
+	// the CIDs used are offered by the shell completion
+	if err := recordCidHistory(env.(*oldcmds.Context).ConfigRoot, req.Arguments); err != nil {
+		log.Debugf("failed to record the CID history: %s", err)
+	}
+
 	var exctr cmds.Executor
 	if client != nil && !req.Command.External {
 		exctr = client.(cmds.Executor)
 	} else {
 		exctr = cmds.NewExecutor(req.Root)
 	}
+	if timeout, ok := req.Options[cmds.TimeoutOpt].(string); ok {
+		exctr = &timeoutExecutor{Executor: exctr, timeout: timeout}
+	}
 
 	return exctr, nil
 }
@@ -267,7 +291,7 @@ func commandShouldRunOnDaemon(details cmdDetails, req *cmds.Request, cctx *oldcm
 	// did user specify an api to use for this command?
 	apiAddrStr, _ := req.Options[corecmds.ApiOption].(string)
 
-	client, err := getAPIClient(req.Context, cctx.ConfigRoot, apiAddrStr)
+	client, host, err := getAPIClient(req.Context, cctx.ConfigRoot, apiAddrStr)
 	if err == repo.ErrApiNotRunning {
 		if apiAddrStr != "" && req.Command != daemonCmd {
 			// if user SPECIFIED an api, and this cmd is not daemon
@@ -290,6 +314,7 @@ func commandShouldRunOnDaemon(details cmdDetails, req *cmds.Request, cctx *oldcm
 			return nil, nil
 		}
 
+		req.Context = cmdenv.WithAPIHost(req.Context, host)
 		return client, nil
 	}
 
@@ -314,7 +339,11 @@ func getRepoPath(req *cmds.Request) (string, error) {
 }
 
 func loadConfig(path string) (*config.Config, error) {
-	return fsrepo.ConfigAt(path)
+	cfg, err := fsrepo.ConfigAt(path)
+	if err != nil {
+		return nil, err
+	}
+	return applyConfigOverrides(cfg, configOverrides)
 }
 
 // startProfiling begins CPU profiling and returns a `stop` function to be
@@ -372,9 +401,9 @@ var checkIPFSUnixFmt = "Otherwise check:\n\tps aux | grep ipfs"
 var checkIPFSWinFmt = "Otherwise check:\n\ttasklist | findstr ipfs"
 
 // getAPIClient checks the repo, and the given options, checking for
-// a running API service. if there is one, it returns a client.
-// otherwise, it returns errApiNotRunning, or another error.
-func getAPIClient(ctx context.Context, repoPath, apiAddrStr string) (http.Client, error) {
+// a running API service. if there is one, it returns a client and the host
+// of the API. otherwise, it returns errApiNotRunning, or another error.
+func getAPIClient(ctx context.Context, repoPath, apiAddrStr string) (http.Client, string, error) {
 	var apiErrorFmt string
 	switch {
 	case osh.IsUnix():
@@ -390,39 +419,43 @@ func getAPIClient(ctx context.Context, repoPath, apiAddrStr string) (http.Client
 	if len(apiAddrStr) != 0 {
 		addr, err = ma.NewMultiaddr(apiAddrStr)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		if len(addr.Protocols()) == 0 {
-			return nil, fmt.Errorf("multiaddr doesn't provide any protocols")
+			return nil, "", fmt.Errorf("multiaddr doesn't provide any protocols")
 		}
 	} else {
 		addr, err = fsrepo.APIAddr(repoPath)
 		if err == repo.ErrApiNotRunning {
-			return nil, err
+			return nil, "", err
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf(apiErrorFmt, repoPath, err.Error())
+			return nil, "", fmt.Errorf(apiErrorFmt, repoPath, err.Error())
 		}
 	}
 	if len(addr.Protocols()) == 0 {
-		return nil, fmt.Errorf(apiErrorFmt, repoPath, "multiaddr doesn't provide any protocols")
+		return nil, "", fmt.Errorf(apiErrorFmt, repoPath, "multiaddr doesn't provide any protocols")
 	}
 	return apiClientForAddr(ctx, addr)
 }
 
-func apiClientForAddr(ctx context.Context, addr ma.Multiaddr) (http.Client, error) {
+func apiClientForAddr(ctx context.Context, addr ma.Multiaddr) (http.Client, string, error) {
 	addr, err := resolveAddr(ctx, addr)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	_, host, err := manet.DialArgs(addr)
+	_, hostport, err := manet.DialArgs(addr)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return http.NewClient(host, http.ClientWithAPIPrefix(corehttp.APIPath)), nil
+	return http.NewClient(hostport, http.ClientWithAPIPrefix(corehttp.APIPath)), host, nil
 }
 
 func resolveAddr(ctx context.Context, addr ma.Multiaddr) (ma.Multiaddr, error) {
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	cli "github.com/ipfs/go-ipfs-cmds/cli"
+)
+
+// timeoutExecutor reports the errors of the commands that ran out of the
+// time given with --timeout as such. The deadline itself is set on the
+// request context by cli.Run, and again by the daemon for the commands it
+// runs. Commands with their own timeouts derive them from the request
+// context, so the stricter one wins.
+type timeoutExecutor struct {
+	cmds.Executor
+	timeout string
+}
+
+func (x *timeoutExecutor) Execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+	if cre, ok := re.(cli.ResponseEmitter); ok {
+		re = &timeoutEmitter{ResponseEmitter: cre, req: req, timeout: x.timeout}
+	}
+	return timeoutError(req, x.timeout, x.Executor.Execute(req, re, env))
+}
+
+// timeoutEmitter is the cli.ResponseEmitter of a command run with
+// --timeout.
+type timeoutEmitter struct {
+	cli.ResponseEmitter
+	req     *cmds.Request
+	timeout string
+}
+
+func (re *timeoutEmitter) CloseWithError(err error) error {
+	return re.ResponseEmitter.CloseWithError(timeoutError(re.req, re.timeout, err))
+}
+
+// Type keeps the PostRun of the command for the CLI.
+func (re *timeoutEmitter) Type() cmds.PostRunType {
+	return cmds.CLI
+}
+
+// timeoutError returns the error of a command that failed because its
+// deadline passed, which may be wrapped by the HTTP client, or err.
+func timeoutError(req *cmds.Request, timeout string, err error) error {
+	if err == nil || req.Context.Err() != context.DeadlineExceeded {
+		return err
+	}
+	return fmt.Errorf("%s: the command did not complete within %s (--timeout)", context.DeadlineExceeded, timeout)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestTimeoutError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	req := &cmds.Request{Context: ctx}
+
+	failed := errors.New("failed")
+	if err := timeoutError(req, "1ms", failed); err != failed {
+		t.Fatalf("expected the error to be kept before the deadline, got %q", err)
+	}
+	if err := timeoutError(req, "1ms", nil); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	<-ctx.Done()
+	// the HTTP client wraps the context error
+	wrapped := fmt.Errorf("Post \"http://127.0.0.1:5001/api/v0/cat\": %s", context.DeadlineExceeded)
+	err := timeoutError(req, "1ms", wrapped)
+	if err == nil || err.Error() != "context deadline exceeded: the command did not complete within 1ms (--timeout)" {
+		t.Fatalf("unexpected error %q", err)
+	}
+}
@@ -33,6 +33,11 @@ type Context struct {
 	api           coreiface.CoreAPI
 	node          *core.IpfsNode
 	ConstructNode func() (*core.IpfsNode, error)
+
+	// ReloadGateway re-reads the gateway config of a running daemon and
+	// returns a description of the changes. It is nil if the daemon doesn't
+	// serve a gateway.
+	ReloadGateway func() ([]string, error)
 }
 
 // GetConfig returns the config of the current Command execution
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
+	repo "github.com/ipfs/go-ipfs/repo"
 	math2 "github.com/ipfs/go-ipfs/thirdparty/math2"
 	lgbl "github.com/libp2p/go-libp2p-loggables"
 
@@ -51,6 +53,11 @@ type BootstrapConfig struct {
 	// for the bootstrap process to use. This makes it possible for clients
 	// to control the peers the process uses at any moment.
 	BootstrapPeers func() []pstore.PeerInfo
+
+	// PreferredPeers, if set, returns the bootstrap peers to dial before the
+	// others, like the ones in the region of this node. It is called at
+	// every round too.
+	PreferredPeers func() map[peer.ID]bool
 }
 
 // DefaultBootstrapConfig specifies default sane parameters for bootstrapping.
@@ -147,8 +154,14 @@ func bootstrapRound(ctx context.Context, host host.Host, cfg BootstrapConfig) er
 		return ErrNotEnoughBootstrapPeers
 	}
 
-	// connect to a random susbset of bootstrap candidates
-	randSubset := randomSubsetOfPeers(notConnected, numToDial)
+	// connect to a random susbset of bootstrap candidates, picking the
+	// preferred ones first
+	var randSubset []pstore.PeerInfo
+	if cfg.PreferredPeers != nil {
+		randSubset = preferredSubsetOfPeers(notConnected, numToDial, cfg.PreferredPeers())
+	} else {
+		randSubset = randomSubsetOfPeers(notConnected, numToDial)
+	}
 
 	defer log.EventBegin(ctx, "bootstrapStart", id).Done()
 	log.Debugf("%s bootstrapping to %d nodes: %s", id, numToDial, randSubset)
@@ -236,3 +249,66 @@ func randomSubsetOfPeers(in []pstore.PeerInfo, max int) []pstore.PeerInfo {
 	}
 	return out
 }
+
+// preferredSubsetOfPeers returns a random subset of at most max peers, made of
+// the preferred peers first and completed with the others.
+func preferredSubsetOfPeers(in []pstore.PeerInfo, max int, preferred map[peer.ID]bool) []pstore.PeerInfo {
+	var first, rest []pstore.PeerInfo
+	for _, p := range in {
+		if preferred[p.ID] {
+			first = append(first, p)
+		} else {
+			rest = append(rest, p)
+		}
+	}
+
+	out := randomSubsetOfPeers(first, max)
+	if len(out) < max {
+		out = append(out, randomSubsetOfPeers(rest, max-len(out))...)
+	}
+	return out
+}
+
+// BootstrapRegionConfigKey is the config key of the region this node runs
+// in. The bootstrap peers are tagged with their region in the bootstrap list,
+// see repo.BootstrapEntry.
+const BootstrapRegionConfigKey = "BootstrapRegion"
+
+// LoadBootstrapEntries reads the entries of the bootstrap list of the config
+// of r, with their region.
+func LoadBootstrapEntries(r repo.Repo) ([]repo.BootstrapEntry, error) {
+	var entries []repo.BootstrapEntry
+	if _, err := repo.ReadConfigKey(r, repo.BootstrapConfigKey, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PreferredBootstrapPeers returns the bootstrap peers in the region of the
+// node of r, none if the node has no region.
+func PreferredBootstrapPeers(r repo.Repo) (map[peer.ID]bool, error) {
+	var local string
+	if _, err := repo.ReadConfigKey(r, BootstrapRegionConfigKey, &local); err != nil {
+		return nil, err
+	}
+	preferred := make(map[peer.ID]bool)
+	if local == "" {
+		return preferred, nil
+	}
+
+	entries, err := LoadBootstrapEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !strings.EqualFold(e.Region, local) {
+			continue
+		}
+		bp, err := config.ParseBootstrapPeer(e.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootstrap peer %q: %s", e.Addr, err)
+		}
+		preferred[bp.ID()] = true
+	}
+	return preferred, nil
+}
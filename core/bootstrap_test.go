@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	config "github.com/ipfs/go-ipfs-config"
+	peer "github.com/libp2p/go-libp2p-peer"
 	pstore "github.com/libp2p/go-libp2p-peerstore"
 	testutil "github.com/libp2p/go-testutil"
 )
@@ -54,3 +55,33 @@ func TestMultipleAddrsPerPeer(t *testing.T) {
 		t.Fatal("expected fewer peers")
 	}
 }
+
+func TestPreferredSubsetOfPeers(t *testing.T) {
+	var ps []pstore.PeerInfo
+	preferred := make(map[peer.ID]bool)
+	for i := 0; i < 10; i++ {
+		pid, err := testutil.RandPeerID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps = append(ps, pstore.PeerInfo{ID: pid})
+		if i%3 == 0 {
+			preferred[pid] = true
+		}
+	}
+
+	out := preferredSubsetOfPeers(ps, 2, preferred)
+	if len(out) != 2 || !preferred[out[0].ID] || !preferred[out[1].ID] {
+		t.Fatal("expected only preferred peers")
+	}
+
+	out = preferredSubsetOfPeers(ps, 6, preferred)
+	if len(out) != 6 {
+		t.Fatalf("expected 6 peers, got %d", len(out))
+	}
+	for i, p := range out {
+		if preferred[p.ID] != (i < len(preferred)) {
+			t.Fatal("preferred peers should come first")
+		}
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"os"
 	"syscall"
 	"time"
 
+	blockcache "github.com/ipfs/go-ipfs/blocks/blockcache"
+	blockquota "github.com/ipfs/go-ipfs/blocks/blockquota"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	pin "github.com/ipfs/go-ipfs/pin"
@@ -216,10 +219,38 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		}
 	}
 
+	if !cfg.NilRepo && !cfg.getOpt("noblockcache") {
+		size, err := blockCacheSize(n.Repo)
+		if err != nil {
+			return err
+		}
+		if size > 0 {
+			bs, err = blockcache.New(ctx, bs, size, blockcache.DefaultMaxBytes)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	bs = bstore.NewIdStore(bs)
 
 	bs = cidv0v1.NewBlockstore(bs)
 
+	quota, err := LoadStorageQuota(n.Repo)
+	if err != nil {
+		return err
+	}
+	// walking the repo is slow, the usage is only read to enforce a quota
+	var usage uint64
+	if quota > 0 {
+		usage, err = n.Repo.GetStorageUsage()
+		if err != nil {
+			return err
+		}
+	}
+	n.StorageQuota = blockquota.New(bs, usage, quota)
+	bs = n.StorageQuota
+
 	n.BaseBlocks = bs
 	n.GCLocker = bstore.NewGCLocker()
 	n.Blockstore = bstore.NewGCBlockstore(bs, n.GCLocker)
@@ -258,10 +289,19 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 	} else {
 		n.Exchange = offline.Exchange(n.Blockstore)
 		n.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.RecordValidator)
-		n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), 0)
+		if err := n.setupDNSLinkResolver(); err != nil {
+			return err
+		}
+		n.Namesys = namesys.NewNameSystemWithDNS(n.Routing, n.Repo.Datastore(), 0, n.DNSLink)
 	}
 
-	n.Blocks = bserv.New(n.Blockstore, n.Exchange)
+	exch := n.Exchange
+	if cfg.Online {
+		// bitswap stays n.Exchange, the block service fails in the offline
+		// mode
+		exch = &offlineModeExchange{Interface: n.Exchange, mode: &n.offline}
+	}
+	n.Blocks = bserv.New(n.Blockstore, exch)
 	n.DAG = dag.NewDAGService(n.Blocks)
 
 	internalDag := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
@@ -273,6 +313,7 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		// this is kinda sketchy and could cause data loss
 		n.Pinning = pin.NewPinner(n.Repo.Datastore(), n.DAG, internalDag)
 	}
+	n.Pinning = pin.NewTimedPinner(n.Pinning, n.Repo.Datastore())
 	n.Resolver = resolver.NewBasicResolver(n.DAG)
 
 	if cfg.Online {
@@ -283,3 +324,19 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 
 	return n.loadFilesRoot()
 }
+
+// blockCacheSizeConfigKey is the number of blocks the block cache holds.
+const blockCacheSizeConfigKey = "Datastore.BlockCacheSize"
+
+// blockCacheSize returns the number of blocks to keep in the block cache,
+// zero disabling it.
+func blockCacheSize(r repo.Repo) (int, error) {
+	size := blockcache.DefaultMaxBlocks
+	if _, err := repo.ReadConfigKey(r, blockCacheSizeConfigKey, &size); err != nil {
+		return 0, err
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("invalid value for %s: %d, must not be negative", blockCacheSizeConfigKey, size)
+	}
+	return size, nil
+}
@@ -0,0 +1,283 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	accesscontrol "github.com/ipfs/go-ipfs/accesscontrol"
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	dag "github.com/ipfs/go-merkledag"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+const (
+	accessControlAllowOptionName     = "allow"
+	accessControlRecursiveOptionName = "recursive"
+)
+
+// AccessListOutput is the access list of a CID.
+type AccessListOutput struct {
+	Cid     string
+	Allowed []string
+}
+
+var AccessControlCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Restrict the peers the blocks are served to.",
+		ShortDescription: `
+'ipfs access-control' manages the access lists of the blocks of the node.
+A block with an access list is only sent over bitswap to the peers of the
+list; the other blocks are sent to every peer. The lists apply to every CID
+of a block, as they are keyed by its multihash.
+
+The access lists are only enforced by this node when it serves the blocks:
+the content is not encrypted, and a peer can still fetch it from another
+node that has it.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add":    accessControlAddCmd,
+		"list":   accessControlListCmd,
+		"remove": accessControlRemoveCmd,
+	},
+}
+
+var accessControlAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Allow peers to fetch a block.",
+		ShortDescription: `
+'ipfs access-control add' adds the peers of --allow, a comma separated list
+of peer IDs, to the access list of <cid>, which is created if <cid> doesn't
+have one. From then on, <cid> is only served to the peers of its list.
+
+With --recursive, the peers are added to the access lists of all the blocks
+of the DAG <cid>, which must be stored locally: pin it first.
+
+  > ipfs access-control add -r --allow=QmPeer1,QmPeer2 QmRootOfTheDAG
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The CID to restrict."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(accessControlAllowOptionName, "The comma separated IDs of the peers to allow."),
+		cmdkit.BoolOption(accessControlRecursiveOptionName, "r", "Restrict all the blocks of the DAG."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		peers, err := accessControlPeers(req)
+		if err != nil {
+			return err
+		}
+		if len(peers) == 0 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the --%s option is required", accessControlAllowOptionName)
+		}
+		acl, cids, err := accessControlTargets(req, n)
+		if err != nil {
+			return err
+		}
+
+		if err := acl.AllowAll(cids, peers...); err != nil {
+			return err
+		}
+		return emitAccessLists(req, res, acl, cids)
+	},
+	Encoders: accessListEncoders,
+	Type:     AccessListOutput{},
+}
+
+var accessControlRemoveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove the access list of a block.",
+		ShortDescription: `
+'ipfs access-control remove' deletes the access list of <cid>, which is then
+served to every peer again. With --allow, only the peers of this comma
+separated list are removed from the access list: the block is not served to
+any peer once the last one is removed.
+
+With --recursive, the access lists of all the blocks of the DAG <cid> are
+changed.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The CID to stop restricting."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(accessControlAllowOptionName, "The comma separated IDs of the peers to remove."),
+		cmdkit.BoolOption(accessControlRecursiveOptionName, "r", "Change all the blocks of the DAG."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		peers, err := accessControlPeers(req)
+		if err != nil {
+			return err
+		}
+		acl, cids, err := accessControlTargets(req, n)
+		if err != nil {
+			return err
+		}
+
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+		ids := make([]string, len(peers))
+		for i, p := range peers {
+			ids[i] = p.Pretty()
+		}
+		var out stringList
+		if len(peers) > 0 {
+			revoked, err := acl.RevokeAll(cids, peers...)
+			if err != nil {
+				return err
+			}
+			for _, c := range revoked {
+				out.Strings = append(out.Strings, fmt.Sprintf("removed %s from the access list of %s", strings.Join(ids, " "), enc.Encode(c)))
+			}
+			return cmds.EmitOnce(res, &out)
+		}
+		removed, err := acl.RemoveAll(cids)
+		if err != nil {
+			return err
+		}
+		for _, c := range removed {
+			out.Strings = append(out.Strings, fmt.Sprintf("removed the access list of %s", enc.Encode(c)))
+		}
+		return cmds.EmitOnce(res, &out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(stringListEncoder),
+	},
+	Type: stringList{},
+}
+
+var accessControlListCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the access lists.",
+		ShortDescription: `
+'ipfs access-control list' prints the CIDs with an access list, each
+followed by the peers allowed to fetch it.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		acl, err := loadAccessControl(n)
+		if err != nil {
+			return err
+		}
+		var cids []cid.Cid
+		for _, e := range acl.Entries() {
+			cids = append(cids, e.Cid)
+		}
+		return emitAccessLists(req, res, acl, cids)
+	},
+	Encoders: accessListEncoders,
+	Type:     AccessListOutput{},
+}
+
+// accessListEncoders write a CID by line, followed by the allowed peers.
+var accessListEncoders = cmds.EncoderMap{
+	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *AccessListOutput) error {
+		_, err := fmt.Fprintln(w, strings.Join(append([]string{out.Cid}, out.Allowed...), " "))
+		return err
+	}),
+}
+
+// loadAccessControl returns the access lists of the node, read from its
+// datastore if it is offline.
+func loadAccessControl(n *core.IpfsNode) (*accesscontrol.List, error) {
+	if n.AccessCtrl != nil {
+		return n.AccessCtrl, nil
+	}
+	return accesscontrol.Load(n.Repo.Datastore())
+}
+
+// accessControlPeers parses the peer IDs of the --allow option.
+func accessControlPeers(req *cmds.Request) ([]peer.ID, error) {
+	allow, _ := req.Options[accessControlAllowOptionName].(string)
+	var peers []peer.ID
+	for _, id := range strings.Split(allow, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		p, err := peer.IDB58Decode(id)
+		if err != nil {
+			return nil, cmdkit.Errorf(cmdkit.ErrClient, "invalid peer id %q: %s", id, err)
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+// accessControlTargets returns the access lists of the node and the CIDs
+// the command applies to: the CID argument, and with --recursive all the
+// blocks of its DAG.
+func accessControlTargets(req *cmds.Request, n *core.IpfsNode) (*accesscontrol.List, []cid.Cid, error) {
+	c, err := cid.Decode(req.Arguments[0])
+	if err != nil {
+		return nil, nil, cmdkit.Errorf(cmdkit.ErrClient, "invalid cid %q: %s", req.Arguments[0], err)
+	}
+	acl, err := loadAccessControl(n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cids := []cid.Cid{c}
+	if recursive, _ := req.Options[accessControlRecursiveOptionName].(bool); recursive {
+		// only the local blocks are walked
+		bs := n.Blocks.Blockstore()
+		ng := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+		set := cid.NewSet()
+		set.Add(c)
+		if err := dag.EnumerateChildren(req.Context, dag.GetLinksWithDAG(ng), c, set.Visit); err != nil {
+			return nil, nil, err
+		}
+		cids = set.Keys()
+		sort.Slice(cids, func(i, j int) bool { return cids[i].String() < cids[j].String() })
+	}
+	return acl, cids, nil
+}
+
+func emitAccessLists(req *cmds.Request, res cmds.ResponseEmitter, acl *accesscontrol.List, cids []cid.Cid) error {
+	enc, err := cmdenv.GetCidEncoder(req)
+	if err != nil {
+		return err
+	}
+	lists := make(map[string][]peer.ID)
+	for _, e := range acl.Entries() {
+		lists[string(e.Cid.Hash())] = e.Allowed
+	}
+	for _, c := range cids {
+		allowed, ok := lists[string(c.Hash())]
+		if !ok {
+			continue
+		}
+		out := &AccessListOutput{Cid: enc.Encode(c), Allowed: []string{}}
+		for _, p := range allowed {
+			out.Allowed = append(out.Allowed, p.Pretty())
+		}
+		if err := res.Emit(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,16 +1,26 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	gopath "path"
+	"path/filepath"
 	"strings"
 
+	"github.com/ipfs/go-ipfs/chunkerprofiles"
 	"github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/core/coreapi"
+	"github.com/ipfs/go-ipfs/core/coreunix"
 
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	mfs "github.com/ipfs/go-mfs"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	options "github.com/ipfs/interface-go-ipfs-core/options"
 	mh "github.com/multiformats/go-multihash"
@@ -22,30 +32,39 @@ var ErrDepthLimitExceeded = fmt.Errorf("depth limit exceeded")
 
 type AddEvent struct {
 	Name  string
-	Hash  string `json:",omitempty"`
-	Bytes int64  `json:",omitempty"`
-	Size  string `json:",omitempty"`
+	Hash  string                  `json:",omitempty"`
+	Bytes int64                   `json:",omitempty"`
+	Size  string                  `json:",omitempty"`
+	Dedup *coreunix.DedupEstimate `json:",omitempty"`
+	// Checkpoint are the chunks to append to the checkpoint of a
+	// resumable add
+	Checkpoint *coreunix.CheckpointUpdate `json:",omitempty"`
 }
 
 const (
-	quietOptionName       = "quiet"
-	quieterOptionName     = "quieter"
-	silentOptionName      = "silent"
-	progressOptionName    = "progress"
-	trickleOptionName     = "trickle"
-	wrapOptionName        = "wrap-with-directory"
-	stdinPathName         = "stdin-name"
-	hiddenOptionName      = "hidden"
-	onlyHashOptionName    = "only-hash"
-	chunkerOptionName     = "chunker"
-	pinOptionName         = "pin"
-	rawLeavesOptionName   = "raw-leaves"
-	noCopyOptionName      = "nocopy"
-	fstoreCacheOptionName = "fscache"
-	cidVersionOptionName  = "cid-version"
-	hashOptionName        = "hash"
-	inlineOptionName      = "inline"
-	inlineLimitOptionName = "inline-limit"
+	quietOptionName          = "quiet"
+	quieterOptionName        = "quieter"
+	silentOptionName         = "silent"
+	progressOptionName       = "progress"
+	trickleOptionName        = "trickle"
+	wrapOptionName           = "wrap-with-directory"
+	stdinPathName            = "stdin-name"
+	hiddenOptionName         = "hidden"
+	onlyHashOptionName       = "only-hash"
+	chunkerOptionName        = "chunker"
+	chunkerProfileOptionName = "chunker-profile"
+	pinOptionName            = "pin"
+	rawLeavesOptionName      = "raw-leaves"
+	noCopyOptionName         = "nocopy"
+	fstoreCacheOptionName    = "fscache"
+	cidVersionOptionName     = "cid-version"
+	hashOptionName           = "hash"
+	inlineOptionName         = "inline"
+	inlineLimitOptionName    = "inline-limit"
+	checkpointOptionName     = "checkpoint"
+	estimateDedupOptionName  = "estimate-dedup"
+	metadataOptionName       = "metadata"
+	toMfsOptionName          = "to-mfs"
 )
 
 const adderOutChanSize = 8
@@ -103,6 +122,66 @@ You can now check what blocks have been created by:
   QmY6yj1GsermExDXoosVE3aSPxdMNYr6aKuw3nA8LoWPRS 2059
   QmerURi9k4XzKCaaPbsK6BL5pMEjF7PGphjDvkkjDtsVf3 868
   QmQB28iwSriSUSMqG2nXDTLtdPHgWb4rebBrU7Q1j4vxPv 338
+
+The chunker profile option, '--chunker-profile', selects a named chunker
+instead. The built-in profiles are 'default' (size-262144) and
+'dedup-aggressive' (rabin-65536-262144-1048576). More can be defined in the
+config, as a map of profile names to chunkers:
+
+  > ipfs config --json Chunker.Profiles '{"small": "size-16384"}'
+  > ipfs add --chunker-profile=small ipfs-logo.svg
+
+The checkpoint option, '--checkpoint', makes the add of a single file
+resumable. The progress is recorded in the given file every 8MiB of data
+and when the add fails, and an add interrupted by a crash or Ctrl-C resumes
+from it when run again with the same checkpoint and options. The chunks already added are
+taken from the blockstore: the file is skipped up to the checkpoint when it
+can be seeked, and checked against it otherwise. The checkpoint is removed
+once the file is added. It is read and written by the client: it is sent to
+the daemon first in the body of the request, and the daemon sends back the
+chunks to append to it as 'Checkpoint' events.
+
+  > ipfs add --checkpoint=big.ckpt big.iso
+  ^C
+  > ipfs add --checkpoint=big.ckpt big.iso
+  added QmPTQx4Lahyx27EH4PcUzyHE6izxJ8YjsmhnYmYcV1FQn8 big.iso
+
+Chunks of an interrupted add are not pinned, a garbage collection between the
+two runs makes the add start over.
+
+The estimate dedup option, '--estimate-dedup', reports how much of the data
+is already in the blockstore instead of adding it. The data is chunked with
+the given chunker options, and nothing is written. Compare the estimates of
+different chunkers to find the one deduplicating the data best:
+
+  > ipfs add -r --estimate-dedup --chunker-profile=dedup-aggressive photos
+  total bytes:    52428800
+  new bytes:      12582912
+  existing bytes: 39845888
+  dedup ratio:    76.0%
+
+The metadata option, '--metadata', attaches custom metadata, given as JSON, to
+the file added. The file is wrapped in a UnixFS metadata node linking to the
+file and to the metadata, which reads the same as the file. The metadata is
+shown with 'ipfs cat --show-metadata':
+
+  > ipfs add --metadata='{"author":"alice"}' report.pdf
+  added QmSTx3Y4HZpKat3R3Ak1URf7RDT8VZDYFraZWd7UbWg9Ax report.pdf
+  > ipfs cat --show-metadata QmSTx3Y4HZpKat3R3Ak1URf7RDT8VZDYFraZWd7UbWg9Ax
+  {"author":"alice"}
+
+The to-mfs option, '--to-mfs', links the result of the add into the files
+API (see 'ipfs files --help') at the given path, replacing the entry already
+there. When the path is a directory, the entry is named after the file added,
+or after its hash if it has no name:
+
+  > ipfs add --to-mfs=/docs/ report.pdf
+  added QmSTx3Y4HZpKat3R3Ak1URf7RDT8VZDYFraZWd7UbWg9Ax report.pdf
+  > ipfs files ls /docs
+  report.pdf
+
+The path is checked before the add starts. If linking fails anyway, the add
+fails, and the data added is left in the blockstore.
 `,
 	},
 
@@ -121,7 +200,8 @@ You can now check what blocks have been created by:
 		cmdkit.BoolOption(wrapOptionName, "w", "Wrap files with a directory object."),
 		cmdkit.StringOption(stdinPathName, "Assign a name if the file source is stdin."),
 		cmdkit.BoolOption(hiddenOptionName, "H", "Include files that are hidden. Only takes effect on recursive add."),
-		cmdkit.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes] or rabin-[min]-[avg]-[max]").WithDefault("size-262144"),
+		cmdkit.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes] or rabin-[min]-[avg]-[max]. Default: size-262144."),
+		cmdkit.StringOption(chunkerProfileOptionName, "Use the chunking algorithm of this profile, see the chunker section above."),
 		cmdkit.BoolOption(pinOptionName, "Pin this object when adding.").WithDefault(true),
 		cmdkit.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes. (experimental)"),
 		cmdkit.BoolOption(noCopyOptionName, "Add the file using filestore. Implies raw-leaves. (experimental)"),
@@ -130,8 +210,16 @@ You can now check what blocks have been created by:
 		cmdkit.StringOption(hashOptionName, "Hash function to use. Implies CIDv1 if not sha2-256. (experimental)").WithDefault("sha2-256"),
 		cmdkit.BoolOption(inlineOptionName, "Inline small blocks into CIDs. (experimental)"),
 		cmdkit.IntOption(inlineLimitOptionName, "Maximum block size to inline. (experimental)").WithDefault(32),
+		cmdkit.StringOption(checkpointOptionName, "Record the progress of the add in this file, and resume from it if it exists. Adds a single file only."),
+		cmdkit.BoolOption(estimateDedupOptionName, "Report how much of the data is already in the blockstore, without adding it."),
+		cmdkit.StringOption(metadataOptionName, "Attach this JSON as custom metadata to the file added. Adds a single file only."),
+		cmdkit.StringOption(toMfsOptionName, "Link the result in the files API at this path, or in this directory."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		if err := sendCheckpoint(req); err != nil {
+			return err
+		}
+
 		quiet, _ := req.Options[quietOptionName].(bool)
 		quieter, _ := req.Options[quieterOptionName].(bool)
 		quiet = quiet || quieter
@@ -151,102 +239,7 @@ You can now check what blocks have been created by:
 		return nil
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		api, err := cmdenv.GetApi(env, req)
-		if err != nil {
-			return err
-		}
-
-		progress, _ := req.Options[progressOptionName].(bool)
-		trickle, _ := req.Options[trickleOptionName].(bool)
-		wrap, _ := req.Options[wrapOptionName].(bool)
-		hash, _ := req.Options[onlyHashOptionName].(bool)
-		hidden, _ := req.Options[hiddenOptionName].(bool)
-		silent, _ := req.Options[silentOptionName].(bool)
-		chunker, _ := req.Options[chunkerOptionName].(string)
-		dopin, _ := req.Options[pinOptionName].(bool)
-		rawblks, rbset := req.Options[rawLeavesOptionName].(bool)
-		nocopy, _ := req.Options[noCopyOptionName].(bool)
-		fscache, _ := req.Options[fstoreCacheOptionName].(bool)
-		cidVer, cidVerSet := req.Options[cidVersionOptionName].(int)
-		hashFunStr, _ := req.Options[hashOptionName].(string)
-		inline, _ := req.Options[inlineOptionName].(bool)
-		inlineLimit, _ := req.Options[inlineLimitOptionName].(int)
-		pathName, _ := req.Options[stdinPathName].(string)
-
-		hashFunCode, ok := mh.Names[strings.ToLower(hashFunStr)]
-		if !ok {
-			return fmt.Errorf("unrecognized hash function: %s", strings.ToLower(hashFunStr))
-		}
-
-		enc, err := cmdenv.GetCidEncoder(req)
-		if err != nil {
-			return err
-		}
-
-		events := make(chan interface{}, adderOutChanSize)
-
-		opts := []options.UnixfsAddOption{
-			options.Unixfs.Hash(hashFunCode),
-
-			options.Unixfs.Inline(inline),
-			options.Unixfs.InlineLimit(inlineLimit),
-
-			options.Unixfs.Chunker(chunker),
-
-			options.Unixfs.Pin(dopin),
-			options.Unixfs.HashOnly(hash),
-			options.Unixfs.FsCache(fscache),
-			options.Unixfs.Nocopy(nocopy),
-
-			options.Unixfs.Wrap(wrap),
-			options.Unixfs.Hidden(hidden),
-			options.Unixfs.StdinName(pathName),
-
-			options.Unixfs.Progress(progress),
-			options.Unixfs.Silent(silent),
-			options.Unixfs.Events(events),
-		}
-
-		if cidVerSet {
-			opts = append(opts, options.Unixfs.CidVersion(cidVer))
-		}
-
-		if rbset {
-			opts = append(opts, options.Unixfs.RawLeaves(rawblks))
-		}
-
-		if trickle {
-			opts = append(opts, options.Unixfs.Layout(options.TrickleLayout))
-		}
-
-		errCh := make(chan error)
-		go func() {
-			var err error
-			defer func() { errCh <- err }()
-			defer close(events)
-			_, err = api.Unixfs().Add(req.Context, req.Files, opts...)
-		}()
-
-		for event := range events {
-			output, ok := event.(*coreiface.AddEvent)
-			if !ok {
-				return errors.New("unknown event type")
-			}
-
-			h := ""
-			if output.Path != nil {
-				h = enc.Encode(output.Path.Cid())
-			}
-
-			res.Emit(&AddEvent{
-				Name:  output.Name,
-				Hash:  h,
-				Bytes: output.Bytes,
-				Size:  output.Size,
-			})
-		}
-
-		return <-errCh
+		return runAdd(req, res, env, nil)
 	},
 	PostRun: cmds.PostRunMap{
 		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
@@ -301,6 +294,16 @@ You can now check what blocks have been created by:
 							break LOOP
 						}
 						output := out.(*AddEvent)
+						if output.Dedup != nil {
+							if progress {
+								fmt.Fprintf(os.Stderr, "\033[2K\r")
+							}
+							fmt.Fprintf(os.Stdout, "total bytes:    %d\n", output.Dedup.TotalBytes)
+							fmt.Fprintf(os.Stdout, "new bytes:      %d\n", output.Dedup.NewBytes)
+							fmt.Fprintf(os.Stdout, "existing bytes: %d\n", output.Dedup.ExistingBytes)
+							fmt.Fprintf(os.Stdout, "dedup ratio:    %.1f%%\n", 100*output.Dedup.Ratio())
+							continue
+						}
 						if len(output.Hash) > 0 {
 							lastHash = output.Hash
 							if quieter {
@@ -364,6 +367,18 @@ You can now check what blocks have been created by:
 				return e
 			}
 
+			// the checkpoint is written here, the daemon sends the chunks
+			var cf *coreunix.CheckpointFile
+			if checkpoint, ok := req.Options[checkpointOptionName].(string); ok {
+				var err error
+				cf, err = coreunix.OpenCheckpointFile(checkpoint)
+				if err != nil {
+					close(outChan)
+					return err
+				}
+				defer cf.Close()
+			}
+
 			wait := make(chan struct{})
 			go progressBar(wait)
 
@@ -374,11 +389,23 @@ You can now check what blocks have been created by:
 				v, err := res.Next()
 				if err != nil {
 					if err == io.EOF {
+						if cf != nil {
+							return cf.Remove()
+						}
 						return nil
 					}
 
 					return err
 				}
+				if out, ok := v.(*AddEvent); ok && out.Checkpoint != nil {
+					if cf == nil {
+						continue
+					}
+					if err := cf.Append(out.Checkpoint); err != nil {
+						return fmt.Errorf("writing the checkpoint: %s", err)
+					}
+					continue
+				}
 
 				select {
 				case outChan <- v:
@@ -390,3 +417,345 @@ You can now check what blocks have been created by:
 	},
 	Type: AddEvent{},
 }
+
+// checkpointFileName is the name of the checkpoint, the first file of the
+// request of a resumable add.
+const checkpointFileName = "checkpoint"
+
+// sendCheckpoint puts the checkpoint of a resumable add before the files of
+// the request: the daemon doesn't read it from the path, which is only known
+// to the client.
+func sendCheckpoint(req *cmds.Request) error {
+	checkpoint, ok := req.Options[checkpointOptionName].(string)
+	if !ok || req.Files == nil {
+		return nil
+	}
+
+	var cp files.Node
+	f, err := os.Open(checkpoint)
+	switch {
+	case os.IsNotExist(err):
+		// it is created on the first write, fail before adding if it can't
+		if _, err := os.Stat(filepath.Dir(checkpoint)); err != nil {
+			return err
+		}
+		cp = files.NewBytesFile(nil)
+	case err != nil:
+		return err
+	default:
+		cp = files.NewReaderFile(f)
+	}
+
+	entries := []files.DirEntry{files.FileEntry(checkpointFileName, cp)}
+	it := req.Files.Entries()
+	for it.Next() {
+		entries = append(entries, files.FileEntry(it.Name(), it.Node()))
+	}
+	if it.Err() != nil {
+		return it.Err()
+	}
+	req.Files = files.NewSliceDirectory(entries)
+	return nil
+}
+
+// receiveCheckpoint reads the checkpoint sent first in the files of req,
+// and returns the files to add.
+func receiveCheckpoint(req *cmds.Request) (*coreunix.Checkpoint, files.Directory, error) {
+	if req.Files == nil {
+		return nil, nil, errNoCheckpoint
+	}
+	it := req.Files.Entries()
+	if !it.Next() || it.Name() != checkpointFileName {
+		if it.Err() != nil {
+			return nil, nil, it.Err()
+		}
+		return nil, nil, errNoCheckpoint
+	}
+	f := files.FileFromEntry(it)
+	if f == nil {
+		return nil, nil, errNoCheckpoint
+	}
+	cp, err := coreunix.ReadCheckpoint(f)
+	if err != nil {
+		return nil, nil, cmdkit.Errorf(cmdkit.ErrClient, "%s", err)
+	}
+	return cp, &remainingDirectory{Directory: req.Files, it: it}, nil
+}
+
+var errNoCheckpoint = cmdkit.Errorf(cmdkit.ErrClient, "--%s needs the checkpoint as the first file of the request, named %q", checkpointOptionName, checkpointFileName)
+
+// remainingDirectory is a directory whose entries are the ones left in it.
+type remainingDirectory struct {
+	files.Directory
+	it files.DirIterator
+}
+
+func (d *remainingDirectory) Entries() files.DirIterator {
+	return d.it
+}
+
+// runAdd runs 'ipfs add'. When the result is linked into mfs and mfsPath
+// isn't nil, the path of the entry is stored in mfsPath.
+func runAdd(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment, mfsPath *string) error {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return err
+	}
+	// the options of the core API don't cover all the add options
+	unixfs, ok := api.Unixfs().(*coreapi.UnixfsAPI)
+	if !ok {
+		return fmt.Errorf("unexpected unixfs API %T", api.Unixfs())
+	}
+
+	progress, _ := req.Options[progressOptionName].(bool)
+	trickle, _ := req.Options[trickleOptionName].(bool)
+	wrap, _ := req.Options[wrapOptionName].(bool)
+	hash, _ := req.Options[onlyHashOptionName].(bool)
+	hidden, _ := req.Options[hiddenOptionName].(bool)
+	silent, _ := req.Options[silentOptionName].(bool)
+	chunker, chunkerSet := req.Options[chunkerOptionName].(string)
+	dopin, _ := req.Options[pinOptionName].(bool)
+	rawblks, rbset := req.Options[rawLeavesOptionName].(bool)
+	nocopy, _ := req.Options[noCopyOptionName].(bool)
+	fscache, _ := req.Options[fstoreCacheOptionName].(bool)
+	cidVer, cidVerSet := req.Options[cidVersionOptionName].(int)
+	hashFunStr, _ := req.Options[hashOptionName].(string)
+	inline, _ := req.Options[inlineOptionName].(bool)
+	inlineLimit, _ := req.Options[inlineLimitOptionName].(int)
+	pathName, _ := req.Options[stdinPathName].(string)
+	checkpoint, _ := req.Options[checkpointOptionName].(string)
+	estimate, _ := req.Options[estimateDedupOptionName].(bool)
+	metadata, metadataSet := req.Options[metadataOptionName].(string)
+	toMfs, toMfsSet := req.Options[toMfsOptionName].(string)
+
+	hashFunCode, ok := mh.Names[strings.ToLower(hashFunStr)]
+	if !ok {
+		return fmt.Errorf("unrecognized hash function: %s", strings.ToLower(hashFunStr))
+	}
+
+	enc, err := cmdenv.GetCidEncoder(req)
+	if err != nil {
+		return err
+	}
+
+	if profile, ok := req.Options[chunkerProfileOptionName].(string); ok {
+		if chunkerSet {
+			return cmdkit.Errorf(cmdkit.ErrClient, "--%s can't be used with --%s", chunkerProfileOptionName, chunkerOptionName)
+		}
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		custom, err := chunkerprofiles.CustomProfiles(n.Repo)
+		if err != nil {
+			return err
+		}
+		chunker, err = chunkerprofiles.Lookup(profile, custom)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "%s", err)
+		}
+	} else if !chunkerSet {
+		chunker = chunkerprofiles.Profiles["default"]
+	}
+
+	events := make(chan interface{}, adderOutChanSize)
+
+	var extra coreapi.UnixfsAddExtra
+	var toAdd files.Node = req.Files
+	if checkpoint != "" {
+		if hash {
+			return cmdkit.Errorf(cmdkit.ErrClient, "--%s can't be used with --%s", checkpointOptionName, onlyHashOptionName)
+		}
+		cp, dir, err := receiveCheckpoint(req)
+		if err != nil {
+			return err
+		}
+		cp.Write = func(u *coreunix.CheckpointUpdate) error {
+			select {
+			case events <- u:
+				return nil
+			case <-req.Context.Done():
+				return req.Context.Err()
+			}
+		}
+		extra.Checkpoint = cp
+		toAdd = dir
+	}
+	if metadataSet {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(metadata)); err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid --%s: %s", metadataOptionName, err)
+		}
+		extra.Metadata = buf.Bytes()
+	}
+	var dedup *coreunix.DedupEstimate
+	if estimate {
+		if checkpoint != "" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "--%s can't be used with --%s", estimateDedupOptionName, checkpointOptionName)
+		}
+		// the files added aren't reported, only the estimate
+		silent = true
+		dedup = new(coreunix.DedupEstimate)
+		extra.DedupEstimate = dedup
+	}
+
+	var mfsDir *mfs.Directory
+	var mfsName string
+	if toMfsSet {
+		if hash || estimate {
+			return cmdkit.Errorf(cmdkit.ErrClient, "--%s only works when the data is added", toMfsOptionName)
+		}
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		// checked before the add, it shouldn't fail once the data is added
+		mfsDir, mfsName, err = mfsAddTarget(n.FilesRoot, toMfs)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid mfs path: %s", err)
+		}
+	}
+
+	opts := []options.UnixfsAddOption{
+		options.Unixfs.Hash(hashFunCode),
+
+		options.Unixfs.Inline(inline),
+		options.Unixfs.InlineLimit(inlineLimit),
+
+		options.Unixfs.Chunker(chunker),
+
+		options.Unixfs.Pin(dopin),
+		options.Unixfs.HashOnly(hash),
+		options.Unixfs.FsCache(fscache),
+		options.Unixfs.Nocopy(nocopy),
+
+		options.Unixfs.Wrap(wrap),
+		options.Unixfs.Hidden(hidden),
+		options.Unixfs.StdinName(pathName),
+
+		options.Unixfs.Progress(progress),
+		options.Unixfs.Silent(silent),
+		options.Unixfs.Events(events),
+	}
+
+	if cidVerSet {
+		opts = append(opts, options.Unixfs.CidVersion(cidVer))
+	}
+
+	if rbset {
+		opts = append(opts, options.Unixfs.RawLeaves(rawblks))
+	}
+
+	if trickle {
+		opts = append(opts, options.Unixfs.Layout(options.TrickleLayout))
+	}
+
+	var root coreiface.ResolvedPath
+	errCh := make(chan error)
+	go func() {
+		var err error
+		defer func() { errCh <- err }()
+		defer close(events)
+		root, err = unixfs.AddExtra(req.Context, toAdd, extra, opts...)
+	}()
+
+	// the root is reported last, unless it wraps the files added
+	var last *coreiface.AddEvent
+
+	for event := range events {
+		if u, ok := event.(*coreunix.CheckpointUpdate); ok {
+			res.Emit(&AddEvent{Checkpoint: u})
+			continue
+		}
+		output, ok := event.(*coreiface.AddEvent)
+		if !ok {
+			return errors.New("unknown event type")
+		}
+
+		h := ""
+		if output.Path != nil {
+			h = enc.Encode(output.Path.Cid())
+			last = output
+		}
+
+		res.Emit(&AddEvent{
+			Name:  output.Name,
+			Hash:  h,
+			Bytes: output.Bytes,
+			Size:  output.Size,
+		})
+	}
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+	if mfsDir != nil {
+		if mfsName == "" {
+			mfsName = enc.Encode(root.Cid())
+			if last != nil && last.Path.Cid().Equals(root.Cid()) && last.Name != "" {
+				mfsName = gopath.Base(last.Name)
+			}
+		}
+		nd, err := api.ResolveNode(req.Context, root)
+		if err != nil {
+			return err
+		}
+		if err := mfsAddPut(mfsDir, mfsName, nd); err != nil {
+			return fmt.Errorf("cannot link %s into mfs: %s", enc.Encode(root.Cid()), err)
+		}
+		if mfsPath != nil {
+			*mfsPath = gopath.Join(mfsDir.Path(), mfsName)
+		}
+	}
+	if dedup == nil {
+		return nil
+	}
+	return res.Emit(&AddEvent{Dedup: dedup})
+}
+
+// mfsAddTarget returns the directory in which 'ipfs add --to-mfs' links the
+// result of the add, and the name of the entry, which is empty when the entry
+// is named after the file added.
+func mfsAddTarget(r *mfs.Root, p string) (*mfs.Directory, string, error) {
+	p, err := checkPath(p)
+	if err != nil {
+		return nil, "", err
+	}
+
+	fsn, err := mfs.Lookup(r, p)
+	switch {
+	case err == nil:
+		if dir, ok := fsn.(*mfs.Directory); ok {
+			return dir, "", nil
+		}
+	case err != os.ErrNotExist:
+		return nil, "", err
+	}
+	if strings.HasSuffix(p, "/") {
+		return nil, "", fmt.Errorf("%s is not a directory", p)
+	}
+
+	dirp, name := gopath.Dir(p), gopath.Base(p)
+	pfsn, err := mfs.Lookup(r, dirp)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %s", dirp, err)
+	}
+	dir, ok := pfsn.(*mfs.Directory)
+	if !ok {
+		return nil, "", fmt.Errorf("%s is not a directory", dirp)
+	}
+	return dir, name, nil
+}
+
+// mfsAddPut links nd in dir under name, replacing the entry already there.
+func mfsAddPut(dir *mfs.Directory, name string, nd ipld.Node) error {
+	if _, err := dir.Child(name); err == nil {
+		if err := dir.Unlink(name); err != nil {
+			return err
+		}
+	}
+	if err := dir.AddChild(name, nd); err != nil {
+		return err
+	}
+	return dir.Flush()
+}
@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	repo "github.com/ipfs/go-ipfs/repo"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// AliasesConfigKey is the config section of the command aliases, a map of
+// alias names to the command lines they stand for.
+const AliasesConfigKey = "Aliases"
+
+// Alias is a command alias, as listed by 'ipfs alias list'.
+type Alias struct {
+	Name      string
+	Expansion string
+}
+
+// AliasList is the output of 'ipfs alias list'.
+type AliasList struct {
+	Aliases []Alias
+}
+
+var AliasCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage command aliases.",
+		ShortDescription: `
+An alias is a name standing for a command and its flags. Running
+'ipfs <alias> [args...]' runs the command with the arguments appended:
+
+  > ipfs alias add add-nocopy "add --nocopy --raw-leaves"
+  > ipfs add-nocopy -r ./dataset
+
+Aliases are stored in the Aliases section of the config. They can't shadow
+commands, and can't refer to other aliases.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add":  aliasAddCmd,
+		"list": aliasListCmd,
+	},
+}
+
+var aliasAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add or replace a command alias.",
+		ShortDescription: `
+'ipfs alias add' makes <name> stand for <expansion>, a command of ipfs
+followed by its flags, split on whitespace.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "The name of the alias."),
+		cmdkit.StringArg("expansion", true, false, "The command the alias stands for, without 'ipfs'."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		name, expansion := req.Arguments[0], strings.TrimSpace(req.Arguments[1])
+		if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t.") {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid alias name %q", name)
+		}
+		if _, ok := Root.Subcommands[name]; ok || isLocalCommand(name) {
+			return cmdkit.Errorf(cmdkit.ErrClient, "%q is a command, it can't be an alias", name)
+		}
+		fields := strings.Fields(expansion)
+		if len(fields) == 0 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "empty alias expansion")
+		}
+		if fields[0] == "ipfs" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the expansion of an alias doesn't start with 'ipfs'")
+		}
+
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+		r, err := fsrepo.Open(cfgRoot)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		aliases, err := Aliases(r)
+		if err != nil {
+			return err
+		}
+		if _, ok := aliases[fields[0]]; ok {
+			return cmdkit.Errorf(cmdkit.ErrClient, "%q is an alias, aliases can't refer to aliases", fields[0])
+		}
+		if _, ok := Root.Subcommands[fields[0]]; !ok && !isLocalCommand(fields[0]) {
+			return cmdkit.Errorf(cmdkit.ErrClient, "unknown command %q", fields[0])
+		}
+		if aliases == nil {
+			aliases = make(map[string]string)
+		}
+		aliases[name] = expansion
+		if err := r.SetConfigKey(AliasesConfigKey, aliases); err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &Alias{Name: name, Expansion: expansion})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *Alias) error {
+			fmt.Fprintf(w, "ipfs %s: ipfs %s\n", out.Name, out.Expansion)
+			return nil
+		}),
+	},
+	Type: Alias{},
+}
+
+var aliasListCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the command aliases.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+		r, err := fsrepo.Open(cfgRoot)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		aliases, err := Aliases(r)
+		if err != nil {
+			return err
+		}
+		out := &AliasList{Aliases: []Alias{}}
+		for name, expansion := range aliases {
+			out.Aliases = append(out.Aliases, Alias{Name: name, Expansion: expansion})
+		}
+		sort.Slice(out.Aliases, func(i, j int) bool {
+			return out.Aliases[i].Name < out.Aliases[j].Name
+		})
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *AliasList) error {
+			for _, a := range out.Aliases {
+				fmt.Fprintf(w, "%s\t%s\n", a.Name, a.Expansion)
+			}
+			return nil
+		}),
+	},
+	Type: AliasList{},
+}
+
+// isLocalCommand returns whether name is one of the commands only available
+// from the CLI, which are not in Root.
+func isLocalCommand(name string) bool {
+	return name == "init" || name == "daemon" || name == "help"
+}
+
+// Aliases returns the command aliases defined in the config of r.
+func Aliases(r repo.Repo) (map[string]string, error) {
+	var aliases map[string]string
+	if _, err := repo.ReadConfigKey(r, AliasesConfigKey, &aliases); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// ParseAliases returns the aliases of the Aliases config section val, as
+// decoded from JSON.
+func ParseAliases(val interface{}) (map[string]string, error) {
+	// go through json to get the map out of the generic value
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	var aliases map[string]string
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("invalid %s config: %s", AliasesConfigKey, err)
+	}
+	return aliases, nil
+}
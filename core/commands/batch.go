@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// BatchCall is a command call of a batch.
+type BatchCall struct {
+	Cmd  string                 `json:"cmd"`
+	Args []string               `json:"args"`
+	Opts map[string]interface{} `json:"opts"`
+}
+
+// BatchResult is the result of a command call of a batch. Output holds the
+// values emitted by the command, Error is set if it failed.
+type BatchResult struct {
+	Cmd    string
+	Output []interface{}
+	Error  string
+}
+
+// batchCommands are the commands 'ipfs batch' runs, with the options they
+// can't be batched with. They all emit a bounded output, as the results are
+// only sent once the batch is done, and run on the daemon: the commands
+// streaming their output, like 'ipfs cat', taking files, or refusing to run
+// on the daemon, like 'ipfs repo fsck', are left out.
+var batchCommands = map[string][]string{
+	"bitswap stat":     nil,
+	"bitswap wantlist": nil,
+	"block rm":         nil,
+	"block stat":       nil,
+	"bootstrap list":   nil,
+	"config":           nil,
+	"config show":      nil,
+	"dag get":          nil,
+	"dag resolve":      nil,
+	"diag sys":         nil,
+	"dns":              nil,
+	"files cp":         nil,
+	"files flush":      nil,
+	"files ls":         nil,
+	"files mkdir":      nil,
+	"files mv":         nil,
+	"files rm":         nil,
+	"files stat":       nil,
+	"id":               nil,
+	"key list":         nil,
+	"ls":               nil,
+	"name publish":     nil,
+	"name resolve":     nil,
+	"object get":       nil,
+	"object links":     nil,
+	"object stat":      nil,
+	"pin add":          nil,
+	"pin ls":           nil,
+	"pin rm":           nil,
+	"pin update":       nil,
+	"repo stat":        nil,
+	"repo version":     nil,
+	"resolve":          nil,
+	"stats bitswap":    nil,
+	"stats bw":         {"poll"},
+	"stats repo":       nil,
+	"swarm addrs":      nil,
+	"swarm connect":    nil,
+	"swarm disconnect": nil,
+	"swarm peers":      nil,
+	"version":          nil,
+}
+
+// batchMaxRawOutput is the size of the raw output a batched command can
+// emit: it is kept in memory until the batch is done.
+const batchMaxRawOutput = 1 << 20
+
+// BatchCommands returns the paths of the commands 'ipfs batch' runs,
+// separated by spaces.
+func BatchCommands() []string {
+	names := make([]string, 0, len(batchCommands))
+	for name := range batchCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var BatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run several commands in one API call.",
+		ShortDescription: `
+'ipfs batch' runs the commands listed in a JSON file in sequence, in a single
+API request when the daemon is running, and outputs a JSON array of their
+results. The file holds an array of calls:
+
+  [
+    {"cmd": "pin add", "args": ["<cid>"]},
+    {"cmd": "pin ls", "opts": {"type": "recursive"}}
+  ]
+
+A failed call doesn't stop the batch, its error is set in its result.
+Only the commands with a bounded output the daemon runs can be batched, like
+'ipfs id', 'ipfs pin add' or 'ipfs swarm peers': the commands streaming their
+output, like 'ipfs cat', taking files, like 'ipfs add', or refusing to run on
+the daemon, like 'ipfs repo fsck', can't. The options take JSON values of
+their type, or strings like in the query string of an API call.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "The JSON file listing the calls.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		var calls []BatchCall
+		dec := json.NewDecoder(file)
+		// the numbers are parsed with the type of their option
+		dec.UseNumber()
+		if err := dec.Decode(&calls); err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid batch: %s", err)
+		}
+
+		results := make([]BatchResult, 0, len(calls))
+		for _, call := range calls {
+			out, err := runBatchCall(req, env, call)
+			result := BatchResult{Cmd: call.Cmd, Output: out}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+		}
+		return cmds.EmitOnce(res, results)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out []BatchResult) error {
+			data, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "%s\n", data)
+			return err
+		}),
+	},
+	Type: []BatchResult{},
+}
+
+// runBatchCall runs call with the environment of the batch request, and
+// returns the values emitted by the command.
+func runBatchCall(breq *cmds.Request, env cmds.Environment, call BatchCall) ([]interface{}, error) {
+	path := strings.Fields(call.Cmd)
+	if len(path) == 0 {
+		return nil, fmt.Errorf("missing command")
+	}
+
+	if cmd, err := Root.Get(path); err != nil || cmd.Run == nil {
+		return nil, fmt.Errorf("unknown command %q", call.Cmd)
+	}
+	name := strings.Join(path, " ")
+	refused, ok := batchCommands[name]
+	if !ok {
+		return nil, fmt.Errorf("'ipfs %s' can't be batched", name)
+	}
+
+	optDefs, err := Root.GetOptions(path)
+	if err != nil {
+		return nil, err
+	}
+	opts := make(cmdkit.OptMap)
+	for k, v := range call.Opts {
+		opt, ok := optDefs[k]
+		if !ok {
+			return nil, fmt.Errorf("unknown option %q", k)
+		}
+		for _, r := range refused {
+			if opt.Name() == r {
+				return nil, fmt.Errorf("'ipfs %s' can't be batched with --%s", name, r)
+			}
+		}
+		val, err := batchOptionValue(opt, v)
+		if err != nil {
+			return nil, err
+		}
+		opts[opt.Name()] = val
+	}
+
+	req, err := cmds.NewRequest(breq.Context, path, opts, call.Args, nil, Root)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.FillDefaults(); err != nil {
+		return nil, err
+	}
+	if err := req.Command.CheckArguments(req); err != nil {
+		return nil, err
+	}
+
+	// Call skips the PreRun and PostRun of the command, which are for the
+	// client
+	re, res := cmds.NewChanResponsePair(req)
+	go Root.Call(req, re, env)
+
+	var out []interface{}
+	for {
+		v, err := res.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return out, err
+		}
+		if r, ok := v.(io.Reader); ok {
+			data, err := ioutil.ReadAll(io.LimitReader(r, batchMaxRawOutput+1))
+			if err != nil {
+				return out, err
+			}
+			if len(data) > batchMaxRawOutput {
+				return out, fmt.Errorf("the output is larger than %d bytes", batchMaxRawOutput)
+			}
+			v = data
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// batchOptionValue returns the value of opt given in a batch: strings are
+// converted when the request is made, like in the query string of an API
+// call, the other JSON values must be of the type of the option.
+func batchOptionValue(opt cmdkit.Option, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		if opt.Type() != reflect.String && opt.Type() != reflect.Bool {
+			val, err := opt.Parse(v.String())
+			if err == nil {
+				return val, nil
+			}
+		}
+	case bool:
+		if opt.Type() == reflect.Bool {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("option %q takes a %s, got %v", opt.Name(), opt.Type(), v)
+}
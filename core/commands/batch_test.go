@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+)
+
+func TestBatchCommands(t *testing.T) {
+	for name, refused := range batchCommands {
+		path := strings.Fields(name)
+		cmd, err := Root.Get(path)
+		if err != nil || cmd.Run == nil {
+			t.Errorf("'ipfs %s' is not a command", name)
+			continue
+		}
+		optDefs, err := Root.GetOptions(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opt := range refused {
+			if _, ok := optDefs[opt]; !ok {
+				t.Errorf("'ipfs %s' has no option --%s", name, opt)
+			}
+		}
+	}
+}
+
+func TestBatchOptionValue(t *testing.T) {
+	for _, c := range []struct {
+		opt      cmdkit.Option
+		v        interface{}
+		expected interface{}
+	}{
+		{cmdkit.StringOption("s", ""), "a", "a"},
+		{cmdkit.BoolOption("b", ""), true, true},
+		{cmdkit.BoolOption("b", ""), "true", "true"},
+		{cmdkit.IntOption("i", ""), json.Number("12"), 12},
+		{cmdkit.Int64Option("i", ""), json.Number("9007199254740993"), int64(9007199254740993)},
+		{cmdkit.FloatOption("f", ""), json.Number("1.5"), 1.5},
+		{cmdkit.StringOption("s", ""), []interface{}{"a", "b"}, nil},
+		{cmdkit.StringOption("s", ""), json.Number("1"), nil},
+		{cmdkit.IntOption("i", ""), json.Number("1.5"), nil},
+		{cmdkit.IntOption("i", ""), true, nil},
+	} {
+		val, err := batchOptionValue(c.opt, c.v)
+		if c.expected == nil {
+			if err == nil {
+				t.Errorf("%s %v: expected an error, got %v", c.opt.Type(), c.v, val)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %v: %s", c.opt.Type(), c.v, err)
+		} else if val != c.expected {
+			t.Errorf("%s %v: expected %v, got %v", c.opt.Type(), c.v, c.expected, val)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	util "github.com/ipfs/go-ipfs/blocks/blockstoreutil"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
@@ -95,12 +98,31 @@ var blockGetCmd = &cmds.Command{
 		ShortDescription: `
 'ipfs block get' is a plumbing command for retrieving raw IPFS blocks.
 It outputs to stdout, and <key> is a base58 encoded multihash.
+`,
+		LongDescription: `
+'ipfs block get' is a plumbing command for retrieving raw IPFS blocks.
+It outputs to stdout, and <key> is a base58 encoded multihash.
+
+With --decode=<codec>, the block is decoded and pretty-printed as JSON
+instead. The supported codecs are:
+
+	* dag-pb: an object with the Data of the node, in base64, and its Links.
+	* dag-cbor: the node, with its links as {"/": "<cid>"}.
+	* dag-json: the node, indented.
+	* raw: the data of the block, as a base64 string.
+
+--decode is meant for debugging: its output may change between versions
+and should not be parsed. Use 'ipfs dag get' or 'ipfs object get' to read
+nodes from a program.
 `,
 	},
 
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("key", true, false, "The base58 multihash of an existing block to get.").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(blockDecodeOptionName, "Decode the block with this codec and print it as JSON."),
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
@@ -117,6 +139,18 @@ It outputs to stdout, and <key> is a base58 encoded multihash.
 			return err
 		}
 
+		if codec, ok := req.Options[blockDecodeOptionName].(string); ok {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			decoded, err := decodeBlock(codec, data)
+			if err != nil {
+				return err
+			}
+			r = bytes.NewReader(decoded)
+		}
+
 		return res.Emit(r)
 	},
 }
@@ -206,6 +240,7 @@ than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
 const (
 	forceOptionName      = "force"
 	blockQuietOptionName = "quiet"
+	stdinCidsOptionName  = "stdin-cids"
 )
 
 var blockRmCmd = &cmds.Command{
@@ -214,14 +249,23 @@ var blockRmCmd = &cmds.Command{
 		ShortDescription: `
 'ipfs block rm' is a plumbing command for removing raw ipfs blocks.
 It takes a list of base58 encoded multihashes to remove.
+
+With --stdin-cids, the blocks are read from stdin, one per line, so a list
+kept in a file can be removed at once:
+
+  > ipfs block rm --force --stdin-cids < cids.txt
+
+--force ignores the blocks that don't exist, like 'rm -f'. The command still
+fails if an existing block can't be removed.
 `,
 	},
 	Arguments: []cmdkit.Argument{
-		cmdkit.StringArg("hash", true, true, "Bash58 encoded multihash of block(s) to remove."),
+		cmdkit.StringArg("hash", true, true, "Bash58 encoded multihash of block(s) to remove.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption(forceOptionName, "f", "Ignore nonexistent blocks."),
 		cmdkit.BoolOption(blockQuietOptionName, "q", "Write minimal output."),
+		cmdkit.BoolOption(stdinCidsOptionName, "Read the blocks to remove from stdin, one per line."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -231,9 +275,24 @@ It takes a list of base58 encoded multihashes to remove.
 
 		force, _ := req.Options[forceOptionName].(bool)
 		quiet, _ := req.Options[blockQuietOptionName].(bool)
+		stdinCids, _ := req.Options[stdinCidsOptionName].(bool)
+
+		// only read stdin when asked to, a forgotten argument must not
+		// remove whatever is piped in
+		if stdinCids {
+			if err := req.ParseBodyArgs(); err != nil {
+				return err
+			}
+		} else if req.BodyArgs() != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "argument \"hash\" is required, use --%s to read it from stdin", stdinCidsOptionName)
+		}
 
 		// TODO: use batching coreapi when done
 		for _, b := range req.Arguments {
+			b = strings.TrimSpace(b)
+			if b == "" {
+				continue
+			}
 			p, err := coreiface.ParsePath(b)
 			if err != nil {
 				return err
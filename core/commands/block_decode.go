@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	ipldcbor "github.com/ipfs/go-ipld-cbor"
+	dag "github.com/ipfs/go-merkledag"
+	mh "github.com/multiformats/go-multihash"
+)
+
+const blockDecodeOptionName = "decode"
+
+// decodedPBNode is the JSON form of a dag-pb block printed by 'ipfs block get
+// --decode=dag-pb'.
+type decodedPBNode struct {
+	Data  []byte
+	Links []decodedPBLink
+}
+
+type decodedPBLink struct {
+	Name string
+	Hash string
+	Size uint64
+}
+
+// decodeBlock decodes data as a block of the given codec, and returns it as
+// indented JSON.
+func decodeBlock(codec string, data []byte) ([]byte, error) {
+	var out []byte
+	var err error
+	switch codec {
+	case "dag-pb":
+		var nd *dag.ProtoNode
+		nd, err = dag.DecodeProtobuf(data)
+		if err != nil {
+			break
+		}
+		pb := decodedPBNode{Data: nd.Data(), Links: make([]decodedPBLink, len(nd.Links()))}
+		for i, l := range nd.Links() {
+			pb.Links[i] = decodedPBLink{Name: l.Name, Hash: l.Cid.String(), Size: l.Size}
+		}
+		out, err = json.Marshal(pb)
+	case "dag-cbor":
+		var nd *ipldcbor.Node
+		nd, err = ipldcbor.Decode(data, mh.SHA2_256, -1)
+		if err != nil {
+			break
+		}
+		out, err = nd.MarshalJSON()
+	case "dag-json":
+		// the block is JSON already, with links as {"/": "<cid>"}
+		if !json.Valid(data) {
+			err = fmt.Errorf("invalid JSON")
+		}
+		out = data
+	case "raw":
+		out, err = json.Marshal(data)
+	default:
+		return nil, fmt.Errorf("unsupported codec %q, expected dag-pb, dag-cbor, dag-json or raw", codec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding the block as %s: %s", codec, err)
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, out, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
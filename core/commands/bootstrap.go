@@ -1,17 +1,30 @@
 package commands
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	repo "github.com/ipfs/go-ipfs/repo"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
+	ds "github.com/ipfs/go-datastore"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	config "github.com/ipfs/go-ipfs-config"
+	host "github.com/libp2p/go-libp2p-host"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	swarm "github.com/libp2p/go-libp2p-swarm"
+	ping "github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 type BootstrapOutput struct {
@@ -36,11 +49,13 @@ Running 'ipfs bootstrap' with no arguments will run 'ipfs bootstrap list'.
 		"list": bootstrapListCmd,
 		"add":  bootstrapAddCmd,
 		"rm":   bootstrapRemoveCmd,
+		"test": bootstrapTestCmd,
 	},
 }
 
 const (
-	defaultOptionName = "default"
+	defaultOptionName         = "default"
+	bootstrapRegionOptionName = "region"
 )
 
 var bootstrapAddCmd = &cmds.Command{
@@ -48,6 +63,11 @@ var bootstrapAddCmd = &cmds.Command{
 		Tagline: "Add peers to the bootstrap list.",
 		ShortDescription: `Outputs a list of peers that were added (that weren't already
 in the bootstrap list).
+
+With --region, the peers are tagged with the given region: their entry in the
+bootstrap list of the config becomes {"addr": "<peer>", "region": "<region>"}.
+When the region of the node is set with 'ipfs config BootstrapRegion <region>',
+the daemon dials the bootstrap peers of its region first.
 ` + bootstrapSecurityWarning,
 	},
 
@@ -57,6 +77,7 @@ in the bootstrap list).
 
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption(defaultOptionName, "Add default bootstrap nodes. (Deprecated, use 'default' subcommand instead)"),
+		cmdkit.StringOption(bootstrapRegionOptionName, "Tag the peers with a region, like 'us-east-1'."),
 	},
 	Subcommands: map[string]*cmds.Command{
 		"default": bootstrapAddDefaultCmd,
@@ -111,6 +132,12 @@ in the bootstrap list).
 			return err
 		}
 
+		if region, ok := req.Options[bootstrapRegionOptionName].(string); ok {
+			if err := bootstrapTagRegion(r, inputPeers, region); err != nil {
+				return err
+			}
+		}
+
 		return cmds.EmitOnce(res, &BootstrapOutput{config.BootstrapPeerStrings(added)})
 	},
 	Type: BootstrapOutput{},
@@ -220,7 +247,6 @@ var bootstrapRemoveCmd = &cmds.Command{
 		if err != nil {
 			return err
 		}
-
 		return cmds.EmitOnce(res, &BootstrapOutput{config.BootstrapPeerStrings(removed)})
 	},
 	Type: BootstrapOutput{},
@@ -257,7 +283,6 @@ var bootstrapRemoveAllCmd = &cmds.Command{
 		if err != nil {
 			return err
 		}
-
 		return cmds.EmitOnce(res, &BootstrapOutput{config.BootstrapPeerStrings(removed)})
 	},
 	Type: BootstrapOutput{},
@@ -270,8 +295,14 @@ var bootstrapRemoveAllCmd = &cmds.Command{
 
 var bootstrapListCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline:          "Show peers in the bootstrap list.",
-		ShortDescription: "Peers are output in the format '<multiaddr>/<peerID>'.",
+		Tagline: "Show peers in the bootstrap list.",
+		ShortDescription: `
+Peers are output in the format '<multiaddr>/<peerID>'. With --region, only
+the peers tagged with the given region are shown.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(bootstrapRegionOptionName, "Only show the peers of the given region."),
 	},
 
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -295,6 +326,25 @@ var bootstrapListCmd = &cmds.Command{
 			return err
 		}
 
+		if region, ok := req.Options[bootstrapRegionOptionName].(string); ok {
+			entries, err := core.LoadBootstrapEntries(r)
+			if err != nil {
+				return err
+			}
+			var inRegion []config.BootstrapPeer
+			for _, e := range entries {
+				if !strings.EqualFold(e.Region, region) {
+					continue
+				}
+				p, err := config.ParseBootstrapPeer(e.Addr)
+				if err != nil {
+					return err
+				}
+				inRegion = append(inRegion, p)
+			}
+			peers = inRegion
+		}
+
 		return cmds.EmitOnce(res, &BootstrapOutput{config.BootstrapPeerStrings(peers)})
 	},
 	Type: BootstrapOutput{},
@@ -305,6 +355,205 @@ var bootstrapListCmd = &cmds.Command{
 	},
 }
 
+// BootstrapTestResult is the outcome of dialing one bootstrap peer.
+type BootstrapTestResult struct {
+	Peer      string
+	Reachable bool
+	Latency   time.Duration
+	Error     string `json:",omitempty"`
+	// Failures is the number of consecutive tests the peer failed, this one
+	// included.
+	Failures int
+}
+
+type BootstrapTestOutput struct {
+	Peers   []BootstrapTestResult
+	Removed []string
+}
+
+const (
+	bootstrapRemoveUnreachableOptionName = "remove-unreachable"
+	bootstrapThresholdOptionName         = "threshold"
+)
+
+// bootstrapDialTimeout bounds the time spent dialing each bootstrap peer.
+const bootstrapDialTimeout = 10 * time.Second
+
+// bootstrapFailuresKey is the datastore key of the consecutive failure counts
+// recorded by 'ipfs bootstrap test'.
+var bootstrapFailuresKey = ds.NewKey("/local/bootstrap/failures")
+
+var bootstrapTestCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Test the connectivity to the bootstrap peers.",
+		ShortDescription: `
+'ipfs bootstrap test' dials every peer of the bootstrap list and reports
+whether it is reachable, and the round-trip latency to it. Unreachable peers
+are marked with a warning sign.
+`,
+		LongDescription: `
+'ipfs bootstrap test' dials every peer of the bootstrap list and reports
+whether it is reachable, and the round-trip latency to it. Unreachable peers
+are marked with a warning sign. The daemon must be running.
+
+The number of consecutive tests each peer failed is kept in the repo. With
+--remove-unreachable, the peers that failed at least --threshold tests in a
+row are removed from the bootstrap list:
+
+    ipfs bootstrap test --remove-unreachable --threshold=3
+` + bootstrapSecurityWarning,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(bootstrapRemoveUnreachableOptionName, "Remove the peers unreachable for --threshold consecutive tests."),
+		cmdkit.IntOption(bootstrapThresholdOptionName, "Number of consecutive failed tests before a peer is removed.").WithDefault(3),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		removeUnreachable, _ := req.Options[bootstrapRemoveUnreachableOptionName].(bool)
+		threshold, _ := req.Options[bootstrapThresholdOptionName].(int)
+		if threshold < 1 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "--%s must be at least 1, was %d", bootstrapThresholdOptionName, threshold)
+		}
+
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+		peers, err := cfg.BootstrapPeers()
+		if err != nil {
+			return err
+		}
+
+		out := &BootstrapTestOutput{Peers: make([]BootstrapTestResult, len(peers))}
+		var wg sync.WaitGroup
+		for i, p := range peers {
+			wg.Add(1)
+			go func(i int, p config.BootstrapPeer) {
+				defer wg.Done()
+				out.Peers[i] = bootstrapTestPeer(req.Context, n.PeerHost, p)
+			}(i, p)
+		}
+		wg.Wait()
+
+		dstore := n.Repo.Datastore()
+		failures, err := loadBootstrapFailures(dstore)
+		if err != nil {
+			return err
+		}
+		counts := make(map[string]int, len(peers))
+		var unreachable []config.BootstrapPeer
+		for i := range out.Peers {
+			r := &out.Peers[i]
+			if !r.Reachable {
+				r.Failures = failures[r.Peer] + 1
+				counts[r.Peer] = r.Failures
+				if r.Failures >= threshold {
+					unreachable = append(unreachable, peers[i])
+				}
+			}
+		}
+
+		if removeUnreachable && len(unreachable) > 0 {
+			removed, err := bootstrapRemove(n.Repo, cfg, unreachable)
+			if err != nil {
+				return err
+			}
+			for _, p := range removed {
+				delete(counts, p.String())
+			}
+			out.Removed = config.BootstrapPeerStrings(removed)
+		}
+
+		// only the peers still in the list are kept, a peer that succeeded
+		// starts over
+		if err := storeBootstrapFailures(dstore, counts); err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, out)
+	},
+	Type: BootstrapTestOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *BootstrapTestOutput) error {
+			reachable := 0
+			for _, r := range out.Peers {
+				if r.Reachable {
+					reachable++
+					fmt.Fprintf(w, "✓ %s %.2f ms\n", r.Peer, r.Latency.Seconds()*1000)
+					continue
+				}
+				fmt.Fprintf(w, "⚠ %s unreachable (%d consecutive failures): %s\n", r.Peer, r.Failures, r.Error)
+			}
+			fmt.Fprintf(w, "%d of %d bootstrap peers reachable\n", reachable, len(out.Peers))
+			return bootstrapWritePeers(w, "removed ", out.Removed)
+		}),
+	},
+}
+
+// bootstrapTestPeer dials a bootstrap peer and measures the latency to it.
+func bootstrapTestPeer(ctx context.Context, h host.Host, p config.BootstrapPeer) BootstrapTestResult {
+	res := BootstrapTestResult{Peer: p.String()}
+
+	ctx, cancel := context.WithTimeout(ctx, bootstrapDialTimeout)
+	defer cancel()
+
+	// report the outcome of an actual dial, not the backoff of an earlier one
+	if swrm, ok := h.Network().(*swarm.Swarm); ok {
+		swrm.Backoff().Clear(p.ID())
+	}
+
+	start := time.Now()
+	pi := pstore.PeerInfo{ID: p.ID(), Addrs: []ma.Multiaddr{p.Transport()}}
+	if err := h.Connect(ctx, pi); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Reachable = true
+	res.Latency = time.Since(start)
+
+	// the dial may have reused an open connection, prefer the ping round
+	// trip when the peer answers it
+	pings, err := ping.Ping(ctx, h, p.ID())
+	if err != nil {
+		return res
+	}
+	if t, ok := <-pings; ok {
+		res.Latency = t
+	}
+	return res
+}
+
+func loadBootstrapFailures(dstore ds.Datastore) (map[string]int, error) {
+	failures := make(map[string]int)
+	data, err := dstore.Get(bootstrapFailuresKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return failures, nil
+	default:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &failures); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap failure counts: %s", err)
+	}
+	return failures, nil
+}
+
+func storeBootstrapFailures(dstore ds.Datastore, failures map[string]int) error {
+	data, err := json.Marshal(failures)
+	if err != nil {
+		return err
+	}
+	return dstore.Put(bootstrapFailuresKey, data)
+}
+
 func bootstrapWritePeers(w io.Writer, prefix string, peers []string) error {
 	sort.Stable(sort.StringSlice(peers))
 	for _, peer := range peers {
@@ -385,6 +634,34 @@ func bootstrapRemove(r repo.Repo, cfg *config.Config, toRemove []config.Bootstra
 	return removed, nil
 }
 
+// bootstrapTagRegion records the region of the given peers in their entry
+// of the bootstrap list, the empty region removing it.
+func bootstrapTagRegion(r repo.Repo, peers []config.BootstrapPeer, region string) error {
+	entries, err := core.LoadBootstrapEntries(r)
+	if err != nil {
+		return err
+	}
+	tag := make(map[string]bool, len(peers))
+	for _, p := range peers {
+		tag[p.String()] = true
+	}
+	for i, e := range entries {
+		p, err := config.ParseBootstrapPeer(e.Addr)
+		if err != nil {
+			return err
+		}
+		if tag[p.String()] {
+			entries[i].Region = region
+		}
+	}
+
+	v, err := repo.BootstrapEntriesValue(entries)
+	if err != nil {
+		return err
+	}
+	return r.SetConfigKey(repo.BootstrapConfigKey, v)
+}
+
 func bootstrapRemoveAll(r repo.Repo, cfg *config.Config) ([]config.BootstrapPeer, error) {
 	removed, err := cfg.BootstrapPeers()
 	if err != nil {
@@ -1,12 +1,14 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/core/coreunix"
 
 	"github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -18,6 +20,8 @@ const (
 	progressBarMinSize = 1024 * 1024 * 8 // show progress bar for outputs > 8MiB
 	offsetOptionName   = "offset"
 	lengthOptionName   = "length"
+
+	showMetadataOptionName = "show-metadata"
 )
 
 var CatCmd = &cmds.Command{
@@ -32,6 +36,7 @@ var CatCmd = &cmds.Command{
 	Options: []cmdkit.Option{
 		cmdkit.Int64Option(offsetOptionName, "o", "Byte offset to begin reading from."),
 		cmdkit.Int64Option(lengthOptionName, "l", "Maximum number of bytes to read."),
+		cmdkit.BoolOption(showMetadataOptionName, "Show the custom metadata attached with 'ipfs add --metadata' instead of the data."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -58,6 +63,10 @@ var CatCmd = &cmds.Command{
 			return err
 		}
 
+		if show, _ := req.Options[showMetadataOptionName].(bool); show {
+			return catMetadata(req, res, env, api)
+		}
+
 		readers, length, err := cat(req.Context, api, req.Arguments, int64(offset), int64(max))
 		if err != nil {
 			return err
@@ -111,6 +120,39 @@ var CatCmd = &cmds.Command{
 	},
 }
 
+// catMetadata emits the custom metadata of the files at the paths given, one
+// per line.
+func catMetadata(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment, api iface.CoreAPI) error {
+	n, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, p := range req.Arguments {
+		fpath, err := iface.ParsePath(p)
+		if err != nil {
+			return err
+		}
+		nd, err := api.ResolveNode(req.Context, fpath)
+		if err != nil {
+			return err
+		}
+		meta, err := coreunix.CustomMetadata(req.Context, n.DAG, nd)
+		if err == coreunix.ErrNoCustomMetadata {
+			return fmt.Errorf("%s has no custom metadata", p)
+		}
+		if err != nil {
+			return err
+		}
+		buf.Write(meta)
+		buf.WriteByte('\n')
+	}
+
+	res.SetLength(uint64(buf.Len()))
+	return res.Emit(&buf)
+}
+
 func cat(ctx context.Context, api iface.CoreAPI, paths []string, offset int64, max int64) ([]io.Reader, uint64, error) {
 	readers := make([]io.Reader, 0, len(paths))
 	length := uint64(0)
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	mfs "github.com/ipfs/go-mfs"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+)
+
+const (
+	checkpointKeyOptionName          = "key"
+	checkpointAllowOfflineOptionName = "allow-offline"
+)
+
+// CheckpointOutput is the IPNS name an MFS path was published to, and its
+// CID.
+type CheckpointOutput struct {
+	Name string
+	Cid  string
+}
+
+var CheckpointCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Publish a snapshot of an MFS path to IPNS.",
+		ShortDescription: `
+'ipfs checkpoint' flushes <mfs-path> and publishes its CID to the IPNS name
+of --key, like 'ipfs files flush' followed by 'ipfs name publish'. It prints
+the name and the CID, to be run from a backup script:
+
+    > ipfs checkpoint --key=backups /documents
+    Published /ipfs/QmPPtZNzdCmEMonZvHvTT1yBAa7iiNwn2vmC9N2RnEKzYd to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
+
+The snapshot isn't pinned: the blocks stay in the repo as long as they are
+referenced from MFS, pin the printed CID to keep them after later changes.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("mfs-path", true, false, "The MFS path to publish."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(checkpointKeyOptionName, "k", "Name of the key to publish with, as listed by 'ipfs key list'.").WithDefault("self"),
+		cmdkit.BoolOption(checkpointAllowOfflineOptionName, "When offline, save the IPNS record to the local datastore without broadcasting it."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		path, err := checkPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		if err := mfs.FlushPath(n.FilesRoot, path); err != nil {
+			return err
+		}
+		fsn, err := mfs.Lookup(n.FilesRoot, path)
+		if err != nil {
+			return err
+		}
+		nd, err := fsn.GetNode()
+		if err != nil {
+			return err
+		}
+
+		key, _ := req.Options[checkpointKeyOptionName].(string)
+		allowOffline, _ := req.Options[checkpointAllowOfflineOptionName].(bool)
+		entry, err := api.Name().Publish(req.Context, iface.IpfsPath(nd.Cid()),
+			options.Name.Key(key),
+			options.Name.AllowOffline(allowOffline))
+		if err != nil {
+			if err == iface.ErrOffline {
+				err = errors.New("can't publish while offline: pass `--allow-offline` to override")
+			}
+			return err
+		}
+
+		return cmds.EmitOnce(res, &CheckpointOutput{
+			Name: entry.Name(),
+			Cid:  enc.Encode(nd.Cid()),
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *CheckpointOutput) error {
+			_, err := fmt.Fprintf(w, "Published /ipfs/%s to %s\n", out.Cid, out.Name)
+			return err
+		}),
+	},
+	Type: CheckpointOutput{},
+}
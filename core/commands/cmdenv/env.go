@@ -1,6 +1,7 @@
 package cmdenv
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -70,3 +71,18 @@ func GetConfigRoot(env cmds.Environment) (string, error) {
 
 	return ctx.ConfigRoot, nil
 }
+
+type apiHostKey struct{}
+
+// WithAPIHost returns ctx with the host of the API a command is sent to,
+// for the PostRuns connecting to the daemon.
+func WithAPIHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, apiHostKey{}, host)
+}
+
+// GetAPIHost returns the host of the API the command was sent to, or "" if
+// the command wasn't sent to a daemon.
+func GetAPIHost(ctx context.Context) string {
+	host, _ := ctx.Value(apiHostKey{}).(string)
+	return host
+}
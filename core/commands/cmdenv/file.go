@@ -3,6 +3,7 @@ package cmdenv
 import (
 	"fmt"
 
+	cmds "github.com/ipfs/go-ipfs-cmds"
 	files "github.com/ipfs/go-ipfs-files"
 )
 
@@ -21,3 +22,10 @@ func GetFileArg(it files.DirIterator) (files.File, error) {
 	}
 	return file, nil
 }
+
+// SetFileArg makes f, under name, the file of req, for the PreRun of the
+// commands sending data of the client to the daemon: the files go in the
+// body of the request rather than in its URL.
+func SetFileArg(req *cmds.Request, name string, f files.Node) {
+	req.Files = files.NewMapDirectory(map[string]files.Node{name: f})
+}
@@ -22,6 +22,7 @@ func TestROCommands(t *testing.T) {
 		"/block/stat",
 		"/cat",
 		"/commands",
+		"/commands/completion",
 		"/dag",
 		"/dag/get",
 		"/dag/resolve",
@@ -68,7 +69,15 @@ func TestROCommands(t *testing.T) {
 }
 func TestCommands(t *testing.T) {
 	list := []string{
+		"/access-control",
+		"/access-control/add",
+		"/access-control/list",
+		"/access-control/remove",
 		"/add",
+		"/alias",
+		"/alias/add",
+		"/alias/list",
+		"/batch",
 		"/bitswap",
 		"/bitswap/ledger",
 		"/bitswap/reprovide",
@@ -85,18 +94,35 @@ func TestCommands(t *testing.T) {
 		"/bootstrap/list",
 		"/bootstrap/rm",
 		"/bootstrap/rm/all",
+		"/bootstrap/test",
 		"/cat",
+		"/checkpoint",
 		"/commands",
+		"/commands/completion",
 		"/config",
+		"/config/decrypt",
+		"/config/encrypt",
 		"/config/edit",
 		"/config/replace",
 		"/config/show",
 		"/config/profile",
 		"/config/profile/apply",
+		"/config/validate",
+		"/content",
+		"/content/verify",
+		"/content-id",
+		"/content-id/decode",
+		"/content-id/verify",
+		"/content-type",
+		"/content-type/detect",
 		"/dag",
+		"/dag/export",
 		"/dag/get",
+		"/dag/import",
 		"/dag/put",
 		"/dag/resolve",
+		"/datastore",
+		"/datastore/migrate",
 		"/dht",
 		"/dht/findpeer",
 		"/dht/findprovs",
@@ -109,49 +135,80 @@ func TestCommands(t *testing.T) {
 		"/diag/cmds/clear",
 		"/diag/cmds/set-time",
 		"/diag/sys",
+		"/diff",
 		"/dns",
+		"/experiment",
+		"/experiment/wasm-exec",
+		"/export-state",
 		"/file",
 		"/file/ls",
 		"/files",
 		"/files/chcid",
 		"/files/cp",
+		"/files/diff",
+		"/files/find",
 		"/files/flush",
+		"/files/import",
 		"/files/ls",
 		"/files/mkdir",
+		"/files/move",
 		"/files/mv",
 		"/files/read",
 		"/files/rm",
 		"/files/stat",
+		"/files/symlink",
 		"/filestore",
 		"/filestore/dups",
 		"/filestore/ls",
 		"/filestore/verify",
 		"/files/write",
+		"/gateway",
+		"/gateway/reload",
+		"/gateway/dnslink",
+		"/gateway/dnslink/resolve",
+		"/gc",
+		"/gc/schedule",
+		"/gc/schedule/disable",
+		"/gc/schedule/show",
 		"/get",
+		"/graph",
+		"/graph/walk",
 		"/id",
+		"/inspect",
 		"/key",
+		"/key/derive",
 		"/key/gen",
 		"/key/list",
 		"/key/rename",
 		"/key/rm",
+		"/key/sign",
+		"/key/verify",
 		"/log",
 		"/log/level",
+		"/log/level-regex",
 		"/log/ls",
+		"/log/output",
 		"/log/tail",
 		"/ls",
+		"/mirror",
 		"/mount",
 		"/name",
+		"/name/cancel",
+		"/name/list",
 		"/name/publish",
 		"/name/pubsub",
 		"/name/pubsub/state",
 		"/name/pubsub/subs",
 		"/name/pubsub/cancel",
 		"/name/resolve",
+		"/network",
+		"/network/simulate",
 		"/object",
 		"/object/data",
 		"/object/diff",
 		"/object/get",
 		"/object/links",
+		"/object/merge",
 		"/object/new",
 		"/object/patch",
 		"/object/patch/add-link",
@@ -160,6 +217,9 @@ func TestCommands(t *testing.T) {
 		"/object/patch/set-data",
 		"/object/put",
 		"/object/stat",
+		"/offline-mode",
+		"/offline-mode/disable",
+		"/offline-mode/enable",
 		"/p2p",
 		"/p2p/close",
 		"/p2p/forward",
@@ -168,11 +228,22 @@ func TestCommands(t *testing.T) {
 		"/p2p/stream",
 		"/p2p/stream/close",
 		"/p2p/stream/ls",
+		"/pack",
+		"/perf",
+		"/perf/benchmark",
 		"/pin",
 		"/pin/add",
 		"/ping",
 		"/pin/ls",
 		"/pin/rm",
+		"/pin/remote",
+		"/pin/remote/add",
+		"/pin/remote/ls",
+		"/pin/remote/service",
+		"/pin/remote/service/add",
+		"/pin/remote/service/ls",
+		"/pin/remote/service/rm",
+		"/pin/remote/sync",
 		"/pin/update",
 		"/pin/verify",
 		"/pubsub",
@@ -180,20 +251,45 @@ func TestCommands(t *testing.T) {
 		"/pubsub/peers",
 		"/pubsub/pub",
 		"/pubsub/sub",
+		"/reachability",
+		"/reachability/test",
 		"/refs",
 		"/refs/local",
 		"/repo",
+		"/repo/export",
 		"/repo/fsck",
 		"/repo/gc",
+		"/repo/import",
+		"/repo/pack",
+		"/repo/shard-migrate",
 		"/repo/stat",
 		"/repo/verify",
 		"/repo/version",
 		"/resolve",
+		"/routing",
+		"/routing/http",
+		"/routing/http/add",
+		"/routing/http/find",
+		"/search",
+		"/search/local",
+		"/secure-channel",
+		"/secure-channel/accept",
+		"/secure-channel/open",
+		"/serve",
+		"/serve/http",
 		"/shutdown",
 		"/stats",
 		"/stats/bitswap",
 		"/stats/bw",
 		"/stats/repo",
+		"/storage",
+		"/storage/quota",
+		"/storage/quota/get",
+		"/storage/quota/set",
+		"/storage/quota/unset",
+		"/store-and-forward",
+		"/store-and-forward/publish",
+		"/store-and-forward/subscribe",
 		"/swarm",
 		"/swarm/addrs",
 		"/swarm/addrs/listen",
@@ -203,16 +299,35 @@ func TestCommands(t *testing.T) {
 		"/swarm/filters",
 		"/swarm/filters/add",
 		"/swarm/filters/rm",
+		"/swarm/nat",
+		"/swarm/nat/disable",
+		"/swarm/nat/refresh",
+		"/swarm/nat/status",
 		"/swarm/peers",
+		"/swarm/relay",
+		"/swarm/relay/ls",
+		"/swarm/unban",
 		"/tar",
 		"/tar/add",
 		"/tar/cat",
+		"/topology",
+		"/trace",
+		"/trace/route",
+		"/transfer",
+		"/transfer/push",
+		"/transfer/receive",
+		"/trust",
+		"/trust/add",
+		"/trust/list",
+		"/trust/remove",
 		"/update",
 		"/urlstore",
 		"/urlstore/add",
 		"/version",
+		"/watch",
 		"/cid",
 		"/cid/format",
+		"/cid/explain",
 		"/cid/base32",
 		"/cid/codecs",
 		"/cid/bases",
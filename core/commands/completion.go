@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// CidHistoryFile is the file of the repo listing the CIDs recently passed to
+// the CLI, one per line, offered by the shell completion scripts.
+const CidHistoryFile = "cid-history"
+
+// completionNode is a command of the tree the completion scripts are
+// generated from.
+type completionNode struct {
+	path    string
+	subs    []string
+	flags   []string
+	cidArgs bool
+}
+
+func completionCmd(root *cmds.Command) *cmds.Command {
+	return &cmds.Command{
+		Helptext: cmdkit.HelpText{
+			Tagline: "Generate shell completion scripts.",
+			ShortDescription: `
+'ipfs commands completion' prints a script completing the ipfs subcommands
+and flags in the given shell: bash, zsh or fish.
+
+The arguments taking a CID or an IPFS path are completed with the CIDs
+recently passed to ipfs, listed in the cid-history file of the repo.
+
+To enable it in bash, add to your ~/.bashrc:
+
+  source <(ipfs commands completion bash)
+
+In zsh, save the script as _ipfs in a directory of your $fpath. In fish,
+save it as ~/.config/fish/completions/ipfs.fish.
+`,
+		},
+		Arguments: []cmdkit.Argument{
+			cmdkit.StringArg("shell", true, false, "The shell to generate the script for: bash, zsh or fish."),
+		},
+		Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			var gen func(io.Writer, []completionNode)
+			switch req.Arguments[0] {
+			case "bash":
+				gen = bashCompletion
+			case "zsh":
+				gen = zshCompletion
+			case "fish":
+				gen = fishCompletion
+			default:
+				return cmdkit.Errorf(cmdkit.ErrClient, "unsupported shell %q, expected bash, zsh or fish", req.Arguments[0])
+			}
+
+			var buf bytes.Buffer
+			gen(&buf, completionTree(root))
+			return res.Emit(&buf)
+		},
+	}
+}
+
+// completionTree returns the commands under root, sorted by path. The options
+// of root are global, they are added to every command.
+func completionTree(root *cmds.Command) []completionNode {
+	var nodes []completionNode
+	var walk func(path string, cmd *cmds.Command)
+	walk = func(path string, cmd *cmds.Command) {
+		nd := completionNode{path: path}
+		for name := range cmd.Subcommands {
+			nd.subs = append(nd.subs, name)
+		}
+		sort.Strings(nd.subs)
+
+		opts := cmd.Options
+		if cmd != root {
+			opts = append(append([]cmdkit.Option{}, root.Options...), opts...)
+		}
+		for _, opt := range opts {
+			for _, name := range opt.Names() {
+				if len(name) == 1 {
+					nd.flags = append(nd.flags, "-"+name)
+				} else {
+					nd.flags = append(nd.flags, "--"+name)
+				}
+			}
+		}
+		sort.Strings(nd.flags)
+
+		for _, arg := range cmd.Arguments {
+			if isCidArg(arg) {
+				nd.cidArgs = true
+			}
+		}
+		nodes = append(nodes, nd)
+
+		for _, name := range nd.subs {
+			walk(strings.TrimPrefix(path+" "+name, " "), cmd.Subcommands[name])
+		}
+	}
+	walk("", root)
+	return nodes
+}
+
+// isCidArg returns whether arg takes a CID or an IPFS path.
+func isCidArg(arg cmdkit.Argument) bool {
+	if arg.Type != cmdkit.ArgString {
+		return false
+	}
+	switch arg.Name {
+	case "ipfs-path", "cid", "hash", "ref", "root", "obj":
+		return true
+	}
+	// object and block keys
+	return strings.Contains(arg.Description, "multihash")
+}
+
+// completionCases writes a case branch, formatted with format, for each
+// command for which list returns words.
+func completionCases(w io.Writer, nodes []completionNode, list func(completionNode) []string, format string) {
+	for _, nd := range nodes {
+		if words := list(nd); len(words) > 0 {
+			fmt.Fprintf(w, format, nd.path, strings.Join(words, " "))
+		}
+	}
+}
+
+func subcommandWords(nd completionNode) []string { return nd.subs }
+func flagWords(nd completionNode) []string       { return nd.flags }
+
+func cidArgPaths(nodes []completionNode) []string {
+	var paths []string
+	for _, nd := range nodes {
+		if nd.cidArgs {
+			paths = append(paths, `"`+nd.path+`"`)
+		}
+	}
+	return paths
+}
+
+// the POSIX functions shared by the bash and zsh scripts
+func posixCompletionFuncs(w io.Writer, nodes []completionNode) {
+	fmt.Fprintln(w, `_ipfs_subcommands()
+{
+    case "$1" in`)
+	completionCases(w, nodes, subcommandWords, "    \"%s\") echo \"%s\" ;;\n")
+	fmt.Fprintln(w, `    esac
+}
+
+_ipfs_flags()
+{
+    case "$1" in`)
+	completionCases(w, nodes, flagWords, "    \"%s\") echo \"%s\" ;;\n")
+	fmt.Fprintf(w, `    esac
+}
+
+_ipfs_cid_args()
+{
+    case "$1" in
+    %s) return 0 ;;
+    esac
+    return 1
+}
+
+_ipfs_cid_history()
+{
+    local cidfile="${IPFS_PATH:-$HOME/.ipfs}/%s"
+    [ -f "$cidfile" ] && cat "$cidfile"
+}
+`, strings.Join(cidArgPaths(nodes), "|"), CidHistoryFile)
+}
+
+func bashCompletion(w io.Writer, nodes []completionNode) {
+	fmt.Fprintln(w, "# bash completion for ipfs, generated by 'ipfs commands completion bash'")
+	fmt.Fprintln(w)
+	posixCompletionFuncs(w, nodes)
+	fmt.Fprint(w, `
+_ipfs()
+{
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local cmdpath="" word i
+    for (( i=1; i < COMP_CWORD; i++ )); do
+        word="${COMP_WORDS[i]}"
+        [[ "$word" == -* ]] && continue
+        if [[ " $(_ipfs_subcommands "$cmdpath") " == *" $word "* ]]; then
+            cmdpath="${cmdpath:+$cmdpath }$word"
+        else
+            break
+        fi
+    done
+
+    local subs
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=( $(compgen -W "$(_ipfs_flags "$cmdpath")" -- "$cur") )
+    elif subs="$(_ipfs_subcommands "$cmdpath")" && [[ -n "$subs" ]]; then
+        COMPREPLY=( $(compgen -W "$subs" -- "$cur") )
+    elif _ipfs_cid_args "$cmdpath"; then
+        COMPREPLY=( $(compgen -W "$(_ipfs_cid_history)" -- "$cur") )
+    fi
+}
+
+complete -o default -F _ipfs ipfs
+`)
+}
+
+func zshCompletion(w io.Writer, nodes []completionNode) {
+	fmt.Fprintln(w, "#compdef ipfs")
+	fmt.Fprintln(w, "# zsh completion for ipfs, generated by 'ipfs commands completion zsh'")
+	fmt.Fprintln(w)
+	posixCompletionFuncs(w, nodes)
+	fmt.Fprint(w, `
+_ipfs()
+{
+    local cmdpath="" word i
+    local -a subs
+    for (( i=2; i < CURRENT; i++ )); do
+        word="${words[i]}"
+        [[ "$word" == -* ]] && continue
+        subs=(${=$(_ipfs_subcommands "$cmdpath")})
+        if (( ${subs[(Ie)$word]} )); then
+            cmdpath="${cmdpath:+$cmdpath }$word"
+        else
+            break
+        fi
+    done
+
+    subs=(${=$(_ipfs_subcommands "$cmdpath")})
+    if [[ "$PREFIX" == -* ]]; then
+        compadd -- ${=$(_ipfs_flags "$cmdpath")}
+    elif (( ${#subs} )); then
+        compadd -- $subs
+    elif _ipfs_cid_args "$cmdpath"; then
+        compadd -- ${(f)"$(_ipfs_cid_history)"}
+    else
+        _files
+    fi
+}
+
+_ipfs "$@"
+`)
+}
+
+func fishCompletion(w io.Writer, nodes []completionNode) {
+	fmt.Fprintln(w, "# fish completion for ipfs, generated by 'ipfs commands completion fish'")
+	fmt.Fprint(w, `
+function __ipfs_subcommands
+    switch "$argv[1]"
+`)
+	completionCases(w, nodes, subcommandWords, "    case '%s'\n        printf '%%s\\n' %s\n")
+	fmt.Fprint(w, `    end
+end
+
+function __ipfs_flags
+    switch "$argv[1]"
+`)
+	completionCases(w, nodes, flagWords, "    case '%s'\n        string join -- \\n %s\n")
+	fmt.Fprintf(w, `    end
+end
+
+function __ipfs_cid_args
+    contains -- "$argv[1]" %s
+end
+
+function __ipfs_cid_history
+    set -l repo $IPFS_PATH
+    test -n "$repo"; or set repo ~/.ipfs
+    test -f $repo/%s; and cat $repo/%s
+end
+
+function __ipfs_complete
+    set -l cmdpath ''
+    for word in (commandline -opc)[2..-1]
+        string match -q -- '-*' $word; and continue
+        if contains -- $word (__ipfs_subcommands "$cmdpath")
+            set cmdpath (string trim -- "$cmdpath $word")
+        else
+            break
+        end
+    end
+
+    set -l cur (commandline -ct)
+    set -l subs (__ipfs_subcommands "$cmdpath")
+    if string match -q -- '-*' $cur
+        __ipfs_flags "$cmdpath"
+    else if test (count $subs) -gt 0
+        printf '%%s\n' $subs
+    else if __ipfs_cid_args "$cmdpath"
+        __ipfs_cid_history
+    else
+        __fish_complete_path $cur
+    end
+end
+
+complete -c ipfs -f -a '(__ipfs_complete)'
+`, strings.Join(cidArgPaths(nodes), " "), CidHistoryFile, CidHistoryFile)
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestCompletionTree(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmdkit.Option{cmdkit.BoolOption("debug", "D", "")},
+		Subcommands: map[string]*cmds.Command{
+			"pin": {
+				Subcommands: map[string]*cmds.Command{
+					"add": {
+						Arguments: []cmdkit.Argument{cmdkit.StringArg("ipfs-path", true, true, "")},
+						Options:   []cmdkit.Option{cmdkit.BoolOption("recursive", "r", "")},
+					},
+				},
+			},
+			"config": {
+				Arguments: []cmdkit.Argument{cmdkit.StringArg("key", true, false, "The key of the config entry.")},
+			},
+		},
+	}
+
+	nodes := completionTree(root)
+	var paths []string
+	for _, nd := range nodes {
+		paths = append(paths, nd.path)
+	}
+	if strings.Join(paths, ",") != ",config,pin,pin add" {
+		t.Fatalf("unexpected paths %q", paths)
+	}
+	add := nodes[3]
+	if strings.Join(add.flags, " ") != "--debug --recursive -D -r" {
+		t.Fatalf("unexpected flags %q", add.flags)
+	}
+	if !add.cidArgs || nodes[1].cidArgs {
+		t.Fatal("only 'pin add' takes a CID")
+	}
+
+	for name, gen := range map[string]func(io.Writer, []completionNode){
+		"bash": bashCompletion,
+		"zsh":  zshCompletion,
+		"fish": fishCompletion,
+	} {
+		var buf bytes.Buffer
+		gen(&buf, nodes)
+		script := buf.String()
+		if !strings.Contains(script, "config pin") || !strings.Contains(script, "--debug --recursive -D -r") {
+			t.Fatalf("%s: the script misses the commands or flags:\n%s", name, script)
+		}
+		if !strings.Contains(script, `"pin add"`) || !strings.Contains(script, CidHistoryFile) {
+			t.Fatalf("%s: the script misses the CID completion:\n%s", name, script)
+		}
+	}
+}
@@ -9,15 +9,24 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	"github.com/ipfs/go-ipfs/repo"
+	common "github.com/ipfs/go-ipfs/repo/common"
 	"github.com/ipfs/go-ipfs/repo/fsrepo"
+	"github.com/ipfs/go-ipfs/repo/fsrepo/configcrypt"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/elgris/jsondiff"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/go-ipfs-config"
+	serialize "github.com/ipfs/go-ipfs-config/serialize"
+	files "github.com/ipfs/go-ipfs-files"
+	peer "github.com/libp2p/go-libp2p-peer"
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 // ConfigUpdateOutput is config profile apply command's output
@@ -35,6 +44,7 @@ const (
 	configBoolOptionName   = "bool"
 	configJSONOptionName   = "json"
 	configDryRunOptionName = "dry-run"
+	configPassphraseOption = "passphrase"
 )
 
 var ConfigCmd = &cmds.Command{
@@ -61,10 +71,13 @@ Set the value of the 'Datastore.Path' key:
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"show":    configShowCmd,
-		"edit":    configEditCmd,
-		"replace": configReplaceCmd,
-		"profile": configProfileCmd,
+		"show":     configShowCmd,
+		"edit":     configEditCmd,
+		"replace":  configReplaceCmd,
+		"profile":  configProfileCmd,
+		"validate": configValidateCmd,
+		"encrypt":  configEncryptCmd,
+		"decrypt":  configDecryptCmd,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("key", true, false, "The key of the config entry (e.g. \"Addresses.API\")."),
@@ -145,7 +158,8 @@ var configShowCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Output config file contents.",
 		ShortDescription: `
-NOTE: For security reasons, this command will omit your private key. If you would like to make a full backup of your config (private key included), you must copy the config file from your repo.
+NOTE: For security reasons, this command will omit your private key, and
+redact the API keys of the remote pinning services. If you would like to make a full backup of your config (private key included), you must copy the config file from your repo.
 `,
 	},
 	Type: map[string]interface{}{},
@@ -175,6 +189,7 @@ NOTE: For security reasons, this command will omit your private key. If you woul
 		if err != nil {
 			return err
 		}
+		redactPinningServiceKeys(cfg)
 
 		return cmds.EmitOnce(res, &cfg)
 	},
@@ -192,6 +207,23 @@ NOTE: For security reasons, this command will omit your private key. If you woul
 	},
 }
 
+// redactPinningServiceKeys replaces the API keys of the remote pinning
+// services of cfg.
+func redactPinningServiceKeys(cfg map[string]interface{}) {
+	v, err := common.MapGetKV(cfg, RemoteServicesConfigKey)
+	if err != nil {
+		return
+	}
+	services, _ := v.(map[string]interface{})
+	for _, svc := range services {
+		svc, _ := svc.(map[string]interface{})
+		api, _ := svc["API"].(map[string]interface{})
+		if key, _ := api["Key"].(string); key != "" {
+			api["Key"] = redactedValue
+		}
+	}
+}
+
 func scrubValue(m map[string]interface{}, key []string) error {
 	find := func(m map[string]interface{}, k string) (string, interface{}, bool) {
 		lckey := strings.ToLower(k)
@@ -291,13 +323,249 @@ can't be undone.
 	},
 }
 
+var configValidateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check the config file for errors.",
+		ShortDescription: `
+'ipfs config validate' reads the config file and checks that its values make
+sense, without starting a node. All errors found are reported with the path
+of the offending field, and the command fails if there are any.
+`,
+		LongDescription: `
+'ipfs config validate' reads the config file and checks that its values make
+sense, without starting a node. All errors found are reported with the path
+of the offending field, and the command fails if there are any.
+
+The following is checked:
+
+  - the entries of Addresses, Bootstrap and Swarm.AddrFilters are valid
+    multiaddrs
+  - durations, such as Ipns.RepublishPeriod or Datastore.GCPeriod, can be
+    parsed
+  - numeric values, such as Swarm.ConnMgr.LowWater, are not negative
+  - fields with a fixed set of values, such as Routing.Type or
+    Reprovider.Strategy, hold one of them
+  - Datastore.Spec only uses known datastore types
+
+Example:
+
+  > ipfs config validate
+  Error: config is invalid:
+    Addresses.Swarm[1]: invalid multiaddr "/ip4/0.0.0.0/tcp/foo": ...
+    Ipns.RepublishPeriod: invalid duration "1d": ...
+`,
+	},
+
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+
+		fname, err := config.Filename(cfgRoot)
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+
+		// the raw config holds the keys go-ipfs reads outside of the config
+		// struct
+		var raw map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("failure to decode config: %s", err)
+		}
+		cfg, err := fsrepo.ConfigFromFileMap(raw)
+		if err != nil {
+			return err
+		}
+
+		errs := append(validateConfig(cfg), validateConfigKeys(raw)...)
+		if len(errs) > 0 {
+			return fmt.Errorf("config is invalid:\n  %s", strings.Join(errs, "\n  "))
+		}
+
+		return cmds.EmitOnce(res, &MessageOutput{"Config is valid.\n"})
+	},
+	Type: MessageOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *MessageOutput) error {
+			_, err := fmt.Fprint(w, out.Message)
+			return err
+		}),
+	},
+}
+
+var configEncryptCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Encrypt a config field with a passphrase.",
+		ShortDescription: `
+'ipfs config encrypt' encrypts the string value of the config field <key>, like
+an API token or the credentials of a datastore, so that it is not stored in
+plaintext. The field then holds "enc:" followed by the encrypted value.
+
+The daemon decrypts the fields when it starts, with the passphrase of
+$IPFS_CONFIG_PASSPHRASE or prompting for it: all the fields must be
+encrypted with the same passphrase. The commands run by the daemon see the
+decrypted values, the others the encrypted ones. The passphrase is read the
+same way when --passphrase is not given.
+
+When the daemon is running, the passphrase is sent to it in the body of the
+API request, not in its URL with the options. Callers of the HTTP API send
+it as the file of the request.
+
+Like for 'ipfs config', <key> can't address the entries of arrays.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("key", true, false, "The key of the config entry (e.g. \"API.AuthToken\")."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(configPassphraseOption, "The passphrase to encrypt the field with."),
+	},
+	PreRun: readConfigPassphrase,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		passphrase, err := configPassphrase(req)
+		if err != nil {
+			return err
+		}
+		key := req.Arguments[0]
+		return updateConfigField(env, key, func(v interface{}) (string, error) {
+			s, ok := v.(string)
+			if !ok {
+				return "", cmdkit.Errorf(cmdkit.ErrClient, "%s is not a string, only strings can be encrypted", key)
+			}
+			if configcrypt.IsEncrypted(s) {
+				return "", cmdkit.Errorf(cmdkit.ErrClient, "%s is already encrypted", key)
+			}
+			return configcrypt.Encrypt(s, passphrase)
+		})
+	},
+}
+
+var configDecryptCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Decrypt a config field encrypted by 'ipfs config encrypt'.",
+		ShortDescription: `
+'ipfs config decrypt' stores the value of the encrypted config field <key> in
+plaintext again. The passphrase is read from $IPFS_CONFIG_PASSPHRASE or
+prompted for when --passphrase is not given. Like for 'ipfs config encrypt',
+it is sent to the daemon in the body of the API request.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("key", true, false, "The key of the config entry (e.g. \"API.AuthToken\")."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(configPassphraseOption, "The passphrase the field is encrypted with."),
+	},
+	PreRun: readConfigPassphrase,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		passphrase, err := configPassphrase(req)
+		if err != nil {
+			return err
+		}
+		key := req.Arguments[0]
+		return updateConfigField(env, key, func(v interface{}) (string, error) {
+			if !configcrypt.IsEncrypted(v) {
+				return "", cmdkit.Errorf(cmdkit.ErrClient, "%s is not encrypted", key)
+			}
+			return configcrypt.Decrypt(v.(string), passphrase)
+		})
+	},
+}
+
+// readConfigPassphrase reads the passphrase of the client when it is not
+// given as an option, and moves it to the body of the request: the options
+// are sent in the URL.
+func readConfigPassphrase(req *cmds.Request, env cmds.Environment) error {
+	passphrase, ok := req.Options[configPassphraseOption].(string)
+	if !ok {
+		var err error
+		passphrase, err = configcrypt.Passphrase("Passphrase: ")
+		if err != nil {
+			return err
+		}
+	}
+	delete(req.Options, configPassphraseOption)
+	cmdenv.SetFileArg(req, "passphrase", files.NewBytesFile([]byte(passphrase)))
+	return nil
+}
+
+// configPassphraseMaxSize bounds the passphrase read from the request.
+const configPassphraseMaxSize = 4 << 10
+
+// configPassphrase returns the passphrase in the body of the request, where
+// readConfigPassphrase puts it.
+func configPassphrase(req *cmds.Request) (string, error) {
+	if req.Files == nil {
+		return "", cmdkit.Errorf(cmdkit.ErrClient, "no passphrase, send it in the body of the request")
+	}
+	f, err := cmdenv.GetFileArg(req.Files.Entries())
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(f, configPassphraseMaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > configPassphraseMaxSize {
+		return "", cmdkit.Errorf(cmdkit.ErrClient, "the passphrase is longer than %d bytes", configPassphraseMaxSize)
+	}
+	return string(b), nil
+}
+
+// updateConfigField sets the config field key to the value returned by
+// update from its current value.
+func updateConfigField(env cmds.Environment, key string, update func(interface{}) (string, error)) error {
+	switch strings.ToLower(key) {
+	case "identity", "identity.privkey":
+		return errors.New("cannot show or change private key through API")
+	default:
+	}
+
+	cfgRoot, err := cmdenv.GetConfigRoot(env)
+	if err != nil {
+		return err
+	}
+	r, err := fsrepo.Open(cfgRoot)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// the value stored in the config file, GetConfigKey decrypting the fields
+	// when the daemon runs
+	filename, err := config.Filename(cfgRoot)
+	if err != nil {
+		return err
+	}
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(filename, &mapconf); err != nil {
+		return err
+	}
+	v, err := common.MapGetKV(mapconf, key)
+	if err != nil {
+		return err
+	}
+	updated, err := update(v)
+	if err != nil {
+		return err
+	}
+	return r.SetConfigKey(key, updated)
+}
+
 var configProfileCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Apply profiles to config.",
 		ShortDescription: fmt.Sprintf(`
 Available profiles:
 %s
-`, buildProfileHelp()),
+`, buildProfileHelp(allProfiles())),
 	},
 
 	Subcommands: map[string]*cmds.Command{
@@ -316,7 +584,7 @@ var configProfileApplyCmd = &cmds.Command{
 		cmdkit.StringArg("profile", true, false, "The profile to apply to the config."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		profile, ok := config.Profiles[req.Arguments[0]]
+		profile, ok := ConfigProfile(req.Arguments[0])
 		if !ok {
 			return fmt.Errorf("%s is not a profile", req.Arguments[0])
 		}
@@ -360,10 +628,49 @@ var configProfileApplyCmd = &cmds.Command{
 	Type: ConfigUpdateOutput{},
 }
 
-func buildProfileHelp() string {
+// extraProfiles are the go-ipfs specific config profiles, which come on top
+// of the ones of go-ipfs-config.
+var extraProfiles = map[string]config.Profile{
+	"offline": {
+		Description: `Disables all networking. The daemon will not start the
+DHT, bitswap or pubsub and will not listen on swarm addresses,
+only serving content available locally.`,
+
+		Transform: func(c *config.Config) error {
+			c.Routing.Type = "offline"
+			c.Discovery.MDNS.Enabled = false
+			return nil
+		},
+	},
+}
+
+// ConfigProfile returns the config profile name, for 'ipfs init --profile'
+// and 'ipfs config profile apply'. The go-ipfs specific profiles are looked
+// up before the ones of go-ipfs-config.
+func ConfigProfile(name string) (config.Profile, bool) {
+	if p, ok := extraProfiles[name]; ok {
+		return p, true
+	}
+	p, ok := config.Profiles[name]
+	return p, ok
+}
+
+// allProfiles returns every available config profile by name.
+func allProfiles() map[string]config.Profile {
+	all := make(map[string]config.Profile, len(config.Profiles)+len(extraProfiles))
+	for name, p := range config.Profiles {
+		all[name] = p
+	}
+	for name, p := range extraProfiles {
+		all[name] = p
+	}
+	return all
+}
+
+func buildProfileHelp(profiles map[string]config.Profile) string {
 	var out string
 
-	for name, profile := range config.Profiles {
+	for name, profile := range profiles {
 		dlines := strings.Split(profile.Description, "\n")
 		for i := range dlines {
 			dlines[i] = "    " + dlines[i]
@@ -464,8 +771,12 @@ func editConfig(filename string) error {
 }
 
 func replaceConfig(r repo.Repo, file io.Reader) error {
-	var cfg config.Config
-	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
+	var mapconf map[string]interface{}
+	if err := json.NewDecoder(file).Decode(&mapconf); err != nil {
+		return errors.New("failed to decode file as config")
+	}
+	cfg, err := fsrepo.ConfigFromFileMap(mapconf)
+	if err != nil {
 		return errors.New("failed to decode file as config")
 	}
 	if len(cfg.Identity.PrivKey) != 0 {
@@ -484,5 +795,111 @@ func replaceConfig(r repo.Repo, file io.Reader) error {
 
 	cfg.Identity.PrivKey = pkstr
 
-	return r.SetConfig(&cfg)
+	if err := r.SetConfig(cfg); err != nil {
+		return err
+	}
+	// the config struct only has the addresses of the bootstrap entries
+	if bootstrap, ok := mapconf[repo.BootstrapConfigKey]; ok && bootstrap != nil {
+		return r.SetConfigKey(repo.BootstrapConfigKey, bootstrap)
+	}
+	return nil
+}
+
+// validateConfig runs the semantic checks of 'ipfs config validate' and
+// returns the errors found, each prefixed with the path of the field.
+func validateConfig(cfg *config.Config) []string {
+	var errs []string
+	fail := func(path, format string, args ...interface{}) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	addrs := func(path string, addrs []string) {
+		for i, a := range addrs {
+			if _, err := ma.NewMultiaddr(a); err != nil {
+				fail(fmt.Sprintf("%s[%d]", path, i), "invalid multiaddr %q: %s", a, err)
+			}
+		}
+	}
+	addrs("Addresses.Swarm", cfg.Addresses.Swarm)
+	addrs("Addresses.Announce", cfg.Addresses.Announce)
+	addrs("Addresses.NoAnnounce", cfg.Addresses.NoAnnounce)
+	addrs("Addresses.API", cfg.Addresses.API)
+	addrs("Addresses.Gateway", cfg.Addresses.Gateway)
+	addrs("Bootstrap", cfg.Bootstrap)
+	addrs("Swarm.AddrFilters", cfg.Swarm.AddrFilters)
+
+	// empty durations select the default
+	duration := func(path, d string) {
+		if d == "" {
+			return
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			fail(path, "invalid duration %q: %s", d, err)
+		}
+	}
+	duration("Datastore.GCPeriod", cfg.Datastore.GCPeriod)
+	duration("Ipns.RepublishPeriod", cfg.Ipns.RepublishPeriod)
+	duration("Ipns.RecordLifetime", cfg.Ipns.RecordLifetime)
+	duration("Reprovider.Interval", cfg.Reprovider.Interval)
+	duration("Swarm.ConnMgr.GracePeriod", cfg.Swarm.ConnMgr.GracePeriod)
+
+	positive := func(path string, v int64) {
+		if v < 0 {
+			fail(path, "must not be negative, got %d", v)
+		}
+	}
+	positive("Datastore.StorageGCWatermark", cfg.Datastore.StorageGCWatermark)
+	positive("Datastore.BloomFilterSize", int64(cfg.Datastore.BloomFilterSize))
+	positive("Discovery.MDNS.Interval", int64(cfg.Discovery.MDNS.Interval))
+	positive("Ipns.ResolveCacheSize", int64(cfg.Ipns.ResolveCacheSize))
+	positive("Swarm.ConnMgr.LowWater", int64(cfg.Swarm.ConnMgr.LowWater))
+	positive("Swarm.ConnMgr.HighWater", int64(cfg.Swarm.ConnMgr.HighWater))
+	if cfg.Swarm.ConnMgr.LowWater > cfg.Swarm.ConnMgr.HighWater {
+		fail("Swarm.ConnMgr.LowWater", "must not be greater than HighWater (%d)", cfg.Swarm.ConnMgr.HighWater)
+	}
+
+	if cfg.Datastore.StorageMax != "" {
+		if _, err := humanize.ParseBytes(cfg.Datastore.StorageMax); err != nil {
+			fail("Datastore.StorageMax", "invalid size %q: %s", cfg.Datastore.StorageMax, err)
+		}
+	}
+
+	oneOf := func(path, v string, values ...string) {
+		for _, ok := range values {
+			if v == ok {
+				return
+			}
+		}
+		fail(path, "unknown value %q, expected one of %q", v, values)
+	}
+	oneOf("Routing.Type", cfg.Routing.Type, "", "dht", "dhtclient", "delegated", "hybrid", "none", "offline")
+	oneOf("Reprovider.Strategy", cfg.Reprovider.Strategy, "", "all", "pinned", "roots")
+	oneOf("Pubsub.Router", cfg.Pubsub.Router, "", "floodsub", "gossipsub")
+	oneOf("Swarm.ConnMgr.Type", cfg.Swarm.ConnMgr.Type, "", "none", "basic")
+
+	if cfg.Identity.PeerID != "" {
+		if _, err := peer.IDB58Decode(cfg.Identity.PeerID); err != nil {
+			fail("Identity.PeerID", "invalid peer ID %q: %s", cfg.Identity.PeerID, err)
+		}
+	}
+
+	if _, err := fsrepo.AnyDatastoreConfig(cfg.Datastore.Spec); err != nil {
+		fail("Datastore.Spec", "%s", err)
+	}
+
+	return errs
+}
+
+// validateConfigKeys checks the values of the config file cfg which are not
+// part of the config struct, like validateConfig.
+func validateConfigKeys(cfg map[string]interface{}) []string {
+	var errs []string
+
+	if v, err := common.MapGetKV(cfg, core.BootstrapRegionConfigKey); err == nil && v != nil {
+		if _, ok := v.(string); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected a region name, got %v", core.BootstrapRegionConfigKey, v))
+		}
+	}
+
+	return errs
 }
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+func TestValidateConfig(t *testing.T) {
+	cfg := &config.Config{
+		Addresses: config.Addresses{
+			Swarm: []string{"/ip4/0.0.0.0/tcp/4001"},
+			API:   config.Strings{"/ip4/127.0.0.1/tcp/5001"},
+		},
+		Datastore: config.Datastore{
+			StorageMax: "10GB",
+			GCPeriod:   "1h",
+			Spec:       map[string]interface{}{"type": "mem"},
+		},
+		Routing: config.Routing{Type: "dht"},
+	}
+	if errs := validateConfig(cfg); len(errs) != 0 {
+		t.Fatalf("unexpected errors for a valid config: %v", errs)
+	}
+
+	cfg.Addresses.Swarm = append(cfg.Addresses.Swarm, "/ip4/0.0.0.0/tcp/foo")
+	cfg.Datastore.GCPeriod = "1d"
+	cfg.Swarm.ConnMgr.LowWater = -1
+	cfg.Routing.Type = "dth"
+	cfg.Datastore.Spec = map[string]interface{}{"type": "nope"}
+
+	errs := validateConfig(cfg)
+	expect := []string{
+		"Addresses.Swarm[1]:",
+		"Datastore.GCPeriod:",
+		"Swarm.ConnMgr.LowWater:",
+		"Routing.Type:",
+		"Datastore.Spec:",
+	}
+	if len(errs) != len(expect) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expect), len(errs), errs)
+	}
+	for _, prefix := range expect {
+		found := false
+		for _, e := range errs {
+			if strings.HasPrefix(e, prefix) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no error reported for %s: %v", prefix, errs)
+		}
+	}
+}
+
+func TestValidateConfigKeys(t *testing.T) {
+	cfg := map[string]interface{}{"BootstrapRegion": "us-east-1"}
+	if errs := validateConfigKeys(cfg); len(errs) != 0 {
+		t.Fatalf("unexpected errors for a valid config: %v", errs)
+	}
+	if errs := validateConfigKeys(map[string]interface{}{}); len(errs) != 0 {
+		t.Fatalf("unexpected errors for unset keys: %v", errs)
+	}
+
+	cfg["BootstrapRegion"] = 42
+	if errs := validateConfigKeys(cfg); len(errs) != 1 || !strings.HasPrefix(errs[0], "BootstrapRegion:") {
+		t.Errorf("expected a BootstrapRegion error, got %v", errs)
+	}
+}
+
+func TestConfigProfile(t *testing.T) {
+	if _, ok := ConfigProfile("offline"); !ok {
+		t.Fatal("the offline profile is missing")
+	}
+	if _, ok := config.Profiles["offline"]; ok {
+		t.Fatal("the offline profile was added to the go-ipfs-config profiles")
+	}
+	if _, ok := ConfigProfile("server"); !ok {
+		t.Fatal("the profiles of go-ipfs-config are missing")
+	}
+	if _, ok := ConfigProfile("nope"); ok {
+		t.Fatal("found an unknown profile")
+	}
+	if _, ok := allProfiles()["offline"]; !ok {
+		t.Fatal("the offline profile is not listed")
+	}
+}
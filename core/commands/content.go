@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+const (
+	contentPinSetOptionName = "pin-set"
+)
+
+// PinSetEntry is a CID expected to be pinned, as listed in the pin set file
+// of 'ipfs content verify'.
+type PinSetEntry struct {
+	Cid string `json:"cid"`
+	// Size is the total size of the blocks of the DAG, not checked if 0.
+	Size        uint64 `json:"size,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ContentVerifyRes is the result of the verification of a CID of the pin
+// set by 'ipfs content verify'.
+type ContentVerifyRes struct {
+	Cid         string
+	Description string
+	Ok          bool
+	Blocks      int
+	Size        uint64
+	Error       string `json:",omitempty"`
+}
+
+var ContentCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Audit the content stored by the node.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"verify": contentVerifyCmd,
+	},
+}
+
+var contentVerifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Verify that a set of CIDs is pinned and intact.",
+		ShortDescription: `
+'ipfs content verify' checks the CIDs of the pin set file given with
+--pin-set, a JSON array of objects:
+
+  [
+    {"cid": "QmRootOfABackup", "size": 1048576, "description": "backup"}
+  ]
+
+Each CID passes if it is pinned, directly, recursively or indirectly, and all
+the blocks of its DAG are stored locally and hash to their CID, so that the
+root CID can be recomputed from the leaves. The size, if given, is the total
+size of the blocks of the DAG; 'ipfs content verify' prints it for each CID.
+The blocks are only read from the local blockstore.
+
+A table of the results is printed, and the command fails if a CID doesn't
+pass.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(contentPinSetOptionName, "The JSON file listing the CIDs to verify."),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		fn, _ := req.Options[contentPinSetOptionName].(string)
+		if fn == "" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the --%s option is required", contentPinSetOptionName)
+		}
+		// the pin set file is read here and sent to the node
+		f, err := os.Open(fn)
+		if err != nil {
+			return err
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		rf, err := files.NewReaderPathFile(fn, f, stat)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		req.Files = files.NewSliceDirectory([]files.DirEntry{files.FileEntry("pin-set.json", rf)})
+		return nil
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if req.Files == nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "no pin set given")
+		}
+		it := req.Files.Entries()
+		if !it.Next() {
+			if it.Err() != nil {
+				return it.Err()
+			}
+			return cmdkit.Errorf(cmdkit.ErrClient, "no pin set given")
+		}
+		f := files.ToFile(it.Node())
+		if f == nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the pin set is not a file")
+		}
+		var set []PinSetEntry
+		if err := json.NewDecoder(f).Decode(&set); err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid pin set: %s", err)
+		}
+
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+		for _, entry := range set {
+			r := verifyPinSetEntry(req.Context, n, entry)
+			if c, err := cid.Decode(r.Cid); err == nil {
+				r.Cid = enc.Encode(c)
+			}
+			if err := res.Emit(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			tw := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "CID\tStatus\tBlocks\tSize\tDescription")
+			total, failed := 0, 0
+			for {
+				v, err := res.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					tw.Flush()
+					return err
+				}
+				r, ok := v.(*ContentVerifyRes)
+				if !ok {
+					tw.Flush()
+					return e.TypeErr(r, v)
+				}
+
+				total++
+				status := "pass"
+				if !r.Ok {
+					failed++
+					status = "fail: " + r.Error
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", r.Cid, status, r.Blocks, r.Size, r.Description)
+			}
+			tw.Flush()
+			if failed > 0 {
+				return fmt.Errorf("%d of %d CIDs failed the verification", failed, total)
+			}
+			return nil
+		},
+	},
+	Type: ContentVerifyRes{},
+}
+
+// verifyPinSetEntry checks that the CID of entry is pinned, and that its DAG
+// is stored locally and intact.
+func verifyPinSetEntry(ctx context.Context, n *core.IpfsNode, entry PinSetEntry) *ContentVerifyRes {
+	r := &ContentVerifyRes{Cid: entry.Cid, Description: entry.Description}
+	c, err := cid.Decode(entry.Cid)
+	if err != nil {
+		r.Error = fmt.Sprintf("invalid cid: %s", err)
+		return r
+	}
+
+	if _, pinned, err := n.Pinning.IsPinned(c); err != nil {
+		r.Error = err.Error()
+		return r
+	} else if !pinned {
+		r.Error = "not pinned"
+		return r
+	}
+
+	// every block is hashed again, the leaves included, so that a corrupted
+	// block anywhere in the DAG is found
+	bs := n.Blocks.Blockstore()
+	visited := cid.NewSet()
+	var walk func(c cid.Cid) error
+	walk = func(c cid.Cid) error {
+		if !visited.Visit(c) {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		blk, err := bs.Get(c)
+		if err == bstore.ErrNotFound {
+			return fmt.Errorf("block %s missing", c)
+		}
+		if err != nil {
+			return fmt.Errorf("block %s: %s", c, err)
+		}
+		sum, err := c.Prefix().Sum(blk.RawData())
+		if err != nil {
+			return fmt.Errorf("block %s: %s", c, err)
+		}
+		if !sum.Equals(c) {
+			return fmt.Errorf("block %s corrupted", c)
+		}
+		r.Blocks++
+		r.Size += uint64(len(blk.RawData()))
+
+		nd, err := ipld.Decode(blk)
+		if err != nil {
+			return fmt.Errorf("block %s: %s", c, err)
+		}
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(c); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+
+	if entry.Size != 0 && r.Size != entry.Size {
+		r.Error = fmt.Sprintf("size is %d, expected %d", r.Size, entry.Size)
+		return r
+	}
+	r.Ok = true
+	return r
+}
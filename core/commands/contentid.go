@@ -0,0 +1,304 @@
+package commands
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cid "github.com/ipfs/go-cid"
+	chunk "github.com/ipfs/go-ipfs-chunker"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+	mbase "github.com/multiformats/go-multibase"
+	mhash "github.com/multiformats/go-multihash"
+)
+
+const (
+	contentIDJSONOptionName = "json"
+)
+
+// ContentIDVerifyOutput is the result of 'ipfs content-id verify'.
+type ContentIDVerifyOutput struct {
+	Match bool
+	// Cid is the CID computed for the file.
+	Cid string
+}
+
+var ContentIDCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check content against CIDs.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"decode": contentIDDecodeCmd,
+		"verify": contentIDVerifyCmd,
+	},
+}
+
+// CidExplanation is the breakdown of a CID by 'ipfs content-id decode'.
+type CidExplanation struct {
+	Cid       string
+	Version   uint64
+	Multibase CodeAndName
+	Codec     CodeAndName
+	Multihash CodeAndName
+	// Digest is the hex encoded digest of the multihash.
+	Digest string
+	// Implied is true for CIDv0s, whose version, multibase and codec are
+	// not written in the CID.
+	Implied bool
+}
+
+var contentIDDecodeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Explain the components of a CID.",
+		ShortDescription: `
+'ipfs content-id decode' prints the version, the multibase, the codec, the
+hash function and the digest of <cid>. A CIDv0 is a bare base58btc sha2-256
+multihash: its version, multibase and protobuf (dag-pb) codec are implied.
+
+'ipfs cid explain' is the same command.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The CID to explain."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(contentIDJSONOptionName, "Print the breakdown as JSON."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		c, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid cid %q: %s", req.Arguments[0], err)
+		}
+		base, err := cid.ExtractEncoding(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid cid %q: %s", req.Arguments[0], err)
+		}
+		dmh, err := mhash.Decode(c.Hash())
+		if err != nil {
+			return err
+		}
+
+		prefix := c.Prefix()
+		return cmds.EmitOnce(res, &CidExplanation{
+			Cid:       req.Arguments[0],
+			Version:   prefix.Version,
+			Multibase: CodeAndName{int(base), mbase.EncodingToStr[base]},
+			Codec:     CodeAndName{int(prefix.Codec), cidCodecName(prefix.Codec)},
+			Multihash: CodeAndName{int(dmh.Code), dmh.Name},
+			Digest:    hex.EncodeToString(dmh.Digest),
+			Implied:   prefix.Version == 0,
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *CidExplanation) error {
+			if asJSON, _ := req.Options[contentIDJSONOptionName].(bool); asJSON {
+				return json.NewEncoder(w).Encode(out)
+			}
+
+			implied := ""
+			if out.Implied {
+				implied = " (implied)"
+			}
+			fmt.Fprintf(w, "CID:        %s\n", out.Cid)
+			fmt.Fprintf(w, "Version:    %d%s\n", out.Version, implied)
+			if out.Implied {
+				fmt.Fprintf(w, "Multibase:  %s%s\n", out.Multibase.Name, implied)
+			} else {
+				fmt.Fprintf(w, "Multibase:  %s (prefix %c)\n", out.Multibase.Name, out.Multibase.Code)
+			}
+			fmt.Fprintf(w, "Codec:      %s (0x%x)%s\n", out.Codec.Name, out.Codec.Code, implied)
+			fmt.Fprintf(w, "Multihash:  %s (0x%x)\n", out.Multihash.Name, out.Multihash.Code)
+			if out.Digest == "" {
+				fmt.Fprintln(w, "Digest:     0 bytes")
+			} else {
+				fmt.Fprintf(w, "Digest:     %d bytes, %s\n", len(out.Digest)/2, out.Digest)
+			}
+			if out.Implied {
+				fmt.Fprintln(w, "A CIDv0 is a base58btc sha2-256 multihash of a protobuf (dag-pb) node.")
+			}
+			return nil
+		}),
+	},
+	Type: CidExplanation{},
+}
+
+// cidCodecName returns the name of the CID codec code.
+func cidCodecName(code uint64) string {
+	if name, ok := cid.CodecToStr[code]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+var contentIDVerifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check that a file matches a CID.",
+		ShortDescription: `
+'ipfs content-id verify' computes the CID of <file> the way the file of <cid>
+was added, and prints MATCH if it is <cid>, or MISMATCH and the computed CID.
+Nothing is added to the repo.
+`,
+		LongDescription: `
+'ipfs content-id verify' computes the CID of <file> the way the file of <cid>
+was added, and prints MATCH if it is <cid>, or MISMATCH and the computed CID.
+Nothing is added to the repo.
+
+The CID version, the hash function, the use of raw leaves, the size of the
+chunks and the layout of the DAG are found from <cid>, its first child and
+its last child, which are fetched if needed. Files chunked with rabin or
+added with a different inline limit can't be verified.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The CID of the file."),
+		cmdkit.FileArg("file", true, false, "The file to check.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		c, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid cid %q: %s", req.Arguments[0], err)
+		}
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+
+		opts, err := contentIDAddOptions(req.Context, api.Dag(), c)
+		if err != nil {
+			return err
+		}
+		p, err := api.Unixfs().Add(req.Context, file, opts...)
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &ContentIDVerifyOutput{
+			Match: p.Cid().Equals(c),
+			Cid:   enc.Encode(p.Cid()),
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ContentIDVerifyOutput) error {
+			if out.Match {
+				fmt.Fprintln(w, "MATCH")
+			} else {
+				fmt.Fprintf(w, "MISMATCH %s\n", out.Cid)
+			}
+			return nil
+		}),
+	},
+	Type: ContentIDVerifyOutput{},
+}
+
+// contentIDAddOptions returns the options adding the file of c again, found
+// from the CID of c and the structure of its DAG.
+func contentIDAddOptions(ctx context.Context, dserv ipld.DAGService, c cid.Cid) ([]options.UnixfsAddOption, error) {
+	prefix := c.Prefix()
+	opts := []options.UnixfsAddOption{
+		options.Unixfs.HashOnly(true),
+		options.Unixfs.Pin(false),
+		options.Unixfs.CidVersion(int(prefix.Version)),
+		options.Unixfs.Hash(prefix.MhType),
+	}
+
+	root, err := dserv.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	// a single block is chunked with its size, so that the file is chunked
+	// the same way if it has the same size
+	chunkSize, err := contentIDLeafSize(root)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := root.(*dag.RawNode); ok || len(root.Links()) == 0 {
+		return append(opts,
+			options.Unixfs.RawLeaves(prefix.Codec == cid.Raw),
+			options.Unixfs.Chunker(fmt.Sprintf("size-%d", contentIDChunkSize(chunkSize)))), nil
+	}
+
+	first, err := root.Links()[0].GetNode(ctx, dserv)
+	if err != nil {
+		return nil, err
+	}
+	last, err := root.Links()[len(root.Links())-1].GetNode(ctx, dserv)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first chunk of a file is a full one
+	leaf := first
+	for len(leaf.Links()) > 0 {
+		if leaf, err = leaf.Links()[0].GetNode(ctx, dserv); err != nil {
+			return nil, err
+		}
+	}
+	if chunkSize, err = contentIDLeafSize(leaf); err != nil {
+		return nil, err
+	}
+
+	// The leaves of a balanced DAG are all at the same depth, while the root
+	// of a trickle DAG has leaves, and then subtrees. The trickle DAGs also
+	// have a root above a single chunk, and leaves of the raw unixfs type
+	// when they aren't raw blocks.
+	layout := options.BalancedLayout
+	if (len(first.Links()) == 0 && len(last.Links()) > 0) || len(root.Links()) == 1 {
+		layout = options.TrickleLayout
+	}
+	if pbLeaf, ok := leaf.(*dag.ProtoNode); ok {
+		if fsn, err := ft.FSNodeFromBytes(pbLeaf.Data()); err == nil && fsn.Type() == ft.TRaw {
+			layout = options.TrickleLayout
+		}
+	}
+	return append(opts,
+		options.Unixfs.Layout(layout),
+		options.Unixfs.RawLeaves(leaf.Cid().Prefix().Codec == cid.Raw),
+		options.Unixfs.Chunker(fmt.Sprintf("size-%d", contentIDChunkSize(chunkSize)))), nil
+}
+
+// contentIDLeafSize returns the size of the data in the leaf nd, or in the
+// file node nd.
+func contentIDLeafSize(nd ipld.Node) (int, error) {
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return len(nd.RawData()), nil
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return 0, err
+		}
+		if t := fsn.Type(); t != ft.TFile && t != ft.TRaw {
+			return 0, cmdkit.Errorf(cmdkit.ErrClient, "%s is not a file", nd.Cid())
+		}
+		return len(fsn.Data()), nil
+	default:
+		return 0, cmdkit.Errorf(cmdkit.ErrClient, "%s is not a unixfs file", nd.Cid())
+	}
+}
+
+// contentIDChunkSize returns the chunk size for chunks of size bytes, the
+// default one for empty chunks.
+func contentIDChunkSize(size int) int {
+	if size == 0 {
+		return int(chunk.DefaultBlockSize)
+	}
+	return size
+}
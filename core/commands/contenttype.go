@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+)
+
+// contentTypeSniffLen is the number of bytes http.DetectContentType looks at.
+const contentTypeSniffLen = 512
+
+// ContentTypeOutput is the MIME type of the content at a path.
+type ContentTypeOutput struct {
+	Type string
+}
+
+var ContentTypeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Find the MIME type of content.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"detect": contentTypeDetectCmd,
+	},
+}
+
+var contentTypeDetectCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Detect the MIME type of a file from its first bytes.",
+		ShortDescription: `
+'ipfs content-type detect' reads the first 512 bytes of the file at
+<ipfs-path>, and prints its MIME type as found by the content sniffing of
+net/http. Only the blocks of these bytes are fetched.
+
+The unixfs directories are 'inode/directory', the symlinks 'inode/symlink'
+and the dag-cbor nodes 'application/cbor'.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, false, "The path or CID of the content."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		p, err := coreiface.ParsePath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		nd, err := api.ResolveNode(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		var head []byte
+		switch nd := nd.(type) {
+		case *dag.RawNode:
+			head = nd.RawData()
+		case *dag.ProtoNode:
+			fsn, err := ft.FSNodeFromBytes(nd.Data())
+			if err != nil {
+				return cmds.EmitOnce(res, &ContentTypeOutput{"application/octet-stream"})
+			}
+			switch fsn.Type() {
+			case ft.TDirectory, ft.THAMTShard:
+				return cmds.EmitOnce(res, &ContentTypeOutput{"inode/directory"})
+			case ft.TSymlink:
+				return cmds.EmitOnce(res, &ContentTypeOutput{"inode/symlink"})
+			}
+
+			f, err := api.Unixfs().Get(req.Context, coreiface.IpfsPath(nd.Cid()))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			file, ok := f.(files.File)
+			if !ok {
+				return fmt.Errorf("%s is not a file", nd.Cid())
+			}
+			head = make([]byte, contentTypeSniffLen)
+			n, err := io.ReadFull(file, head)
+			if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+				return err
+			}
+			head = head[:n]
+		default:
+			if nd.Cid().Prefix().Codec == cid.DagCBOR {
+				return cmds.EmitOnce(res, &ContentTypeOutput{"application/cbor"})
+			}
+			return cmds.EmitOnce(res, &ContentTypeOutput{"application/octet-stream"})
+		}
+
+		if len(head) > contentTypeSniffLen {
+			head = head[:contentTypeSniffLen]
+		}
+		return cmds.EmitOnce(res, &ContentTypeOutput{http.DetectContentType(head)})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ContentTypeOutput) error {
+			_, err := fmt.Fprintln(w, out.Type)
+			return err
+		}),
+	},
+	Type: ContentTypeOutput{},
+}
@@ -0,0 +1,121 @@
+package dagcmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-ipfs/car"
+	"github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+)
+
+const carVersionOptionName = "version"
+
+// ImportResult is the output type of 'dag import' command
+type ImportResult struct {
+	Roots  []string
+	Blocks int
+}
+
+// DagImportCmd imports the blocks of a CAR file
+var DagImportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import the blocks of a CAR file.",
+		ShortDescription: `
+'ipfs dag import' reads a CAR (Content Addressable aRchive) file and stores
+its blocks. Both CARv1 and CARv2 files are supported.
+
+Nothing is pinned: use 'ipfs pin add' on the roots printed to keep the
+blocks from the next garbage collection, or 'ipfs repo import' to import
+and pin them at once.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("path", true, false, "The CAR file to import.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		header, count, err := car.Import(file, n.Blocks.AddBlocks)
+		if err != nil {
+			return err
+		}
+
+		out := &ImportResult{Blocks: count}
+		for _, c := range header.Roots {
+			out.Roots = append(out.Roots, c.String())
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: ImportResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ImportResult) error {
+			fmt.Fprintf(w, "imported %d blocks\n", out.Blocks)
+			for _, r := range out.Roots {
+				fmt.Fprintf(w, "root %s\n", r)
+			}
+			return nil
+		}),
+	},
+}
+
+// DagExportCmd writes a DAG as a CAR file
+var DagExportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Export a DAG as a CAR file.",
+		ShortDescription: `
+'ipfs dag export' writes the DAG rooted at <root> to the standard output as a
+CAR (Content Addressable aRchive) file, with the root CID in its header.
+Blocks that are not in the repo are fetched from the network.
+
+A CARv1 file is written unless '--version=2' is given. The file can be read
+back by 'ipfs dag import' or by other IPFS implementations:
+
+  > ipfs dag export QmWATWQ7fVPP2EFGu71UkfnqhYXDYH566qy47CnJDgvs8u > dump.car
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("root", true, false, "The root of the DAG to export.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption(carVersionOptionName, "CAR format version, 1 or 2.").WithDefault(1),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		version, _ := req.Options[carVersionOptionName].(int)
+		if version != 1 && version != 2 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "unsupported CAR version %d, expected 1 or 2", version)
+		}
+
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		p, err := iface.ParsePath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(car.Export(req.Context, api.Dag(), rp.Cid(), version, pw))
+		}()
+		return res.Emit(pr)
+	},
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+var DatastoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the datastore of the repo.",
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"migrate": datastoreMigrateCmd,
+	},
+}
+
+const (
+	datastoreFromOptionName = "from"
+	datastoreToOptionName   = "to"
+)
+
+var datastoreMigrateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Move the blocks to a different datastore.",
+		ShortDescription: `
+'ipfs datastore migrate' copies all blocks from the current blocks datastore
+to a new one and updates the datastore configuration of the repo to use it.
+Only migrating from flatfs to badger is currently supported. This command can
+only run when no ipfs daemons are running.
+`,
+		LongDescription: `
+'ipfs datastore migrate' copies all blocks from the current blocks datastore
+to a new one and updates the datastore configuration of the repo to use it.
+Only migrating from flatfs to badger is currently supported. This command can
+only run when no ipfs daemons are running.
+
+Every block is checked against its CID while it is copied; the migration
+stops on the first corrupted block. Blocks are copied one flatfs shard at a
+time and the completed shards are recorded in the repo: if the migration is
+interrupted, running the command again resumes it.
+
+The flatfs directory is not removed. Once the node has been checked to work
+with the new datastore, reclaim the space with:
+
+  rm -r $IPFS_PATH/blocks
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(datastoreFromOptionName, "Type of the current blocks datastore.").WithDefault("flatfs"),
+		cmdkit.StringOption(datastoreToOptionName, "Type of the new blocks datastore.").WithDefault("badger"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		from, _ := req.Options[datastoreFromOptionName].(string)
+		to, _ := req.Options[datastoreToOptionName].(string)
+		if from != "flatfs" || to != "badger" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "migrating from %s to %s is not supported, only from flatfs to badger", from, to)
+		}
+
+		configRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+
+		var emitErr error
+		err = fsrepo.MigrateBlocksToBadger(configRoot, func(p *fsrepo.BlocksMigrateProgress) {
+			if emitErr == nil {
+				out := *p
+				emitErr = res.Emit(&out)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return emitErr
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, p *fsrepo.BlocksMigrateProgress) error {
+			_, err := fmt.Fprintf(w, "shard %s: %d/%d shards, %d blocks copied, %s remaining\n",
+				p.Shard, p.ShardsDone, p.ShardsTotal, p.Blocks, p.Remaining.Round(time.Second))
+			return err
+		}),
+	},
+	Type: fsrepo.BlocksMigrateProgress{},
+}
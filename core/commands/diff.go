@@ -0,0 +1,213 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	dagutils "github.com/ipfs/go-ipfs/dagutils"
+
+	humanize "github.com/dustin/go-humanize"
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+)
+
+// DiffEntry is a path changed between the two DAGs compared by 'ipfs diff'.
+// The sizes are the sizes of the files, they are not set for directories.
+type DiffEntry struct {
+	Path       string
+	Type       string
+	Before     string `json:",omitempty"`
+	After      string `json:",omitempty"`
+	Dir        bool
+	SizeBefore int64
+	SizeAfter  int64
+}
+
+// DiffOutput is the result of 'ipfs diff'.
+type DiffOutput struct {
+	Changes  []DiffEntry
+	Added    int
+	Removed  int
+	Modified int
+	// SizeDelta is the change in the size of the files.
+	SizeDelta int64
+}
+
+var DiffCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the changes between two DAGs.",
+		ShortDescription: `
+'ipfs diff' compares the unixfs trees of <a> and <b>, and prints the paths
+added, removed and modified in <b>, with the change in the size of the files,
+like 'git diff --stat':
+
+    > ipfs diff QmOld QmNew
+     docs/new.txt   | added, 120 B
+     docs/readme.md | modified, 300 B -> 350 B (+50 B)
+     old.txt        | removed, 40 B
+    3 paths changed: 1 added, 1 removed, 1 modified (+130 B)
+
+The subtrees with the same CID on both sides are skipped without being
+fetched, and the files aren't downloaded: only their root block is needed
+for their size. A directory added or removed is reported as a single path.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("a", true, false, "The CID or path of the old DAG."),
+		cmdkit.StringArg("b", true, false, "The CID or path of the new DAG."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		var nodes [2]ipld.Node
+		for i, arg := range req.Arguments[:2] {
+			p, err := diffPath(arg)
+			if err != nil {
+				return err
+			}
+			nodes[i], err = getNodeFromPath(req.Context, nd, api, p)
+			if err != nil {
+				return fmt.Errorf("diff: cannot get node from path %s: %s", arg, err)
+			}
+		}
+
+		var changes []*dagutils.Change
+		switch {
+		case nodes[0].Cid().Equals(nodes[1].Cid()):
+		case dagutils.IsDirectory(nodes[0]) && dagutils.IsDirectory(nodes[1]):
+			changes, err = dagutils.DiffDirectories(req.Context, nd.DAG, nodes[0], nodes[1], true)
+			if err != nil {
+				return err
+			}
+		default:
+			// two files, or a file and a directory
+			changes = []*dagutils.Change{{
+				Type:   dagutils.Mod,
+				Path:   ".",
+				Before: nodes[0].Cid(),
+				After:  nodes[1].Cid(),
+			}}
+		}
+
+		out := &DiffOutput{Changes: make([]DiffEntry, 0, len(changes))}
+		for _, c := range changes {
+			entry := DiffEntry{Path: c.Path}
+			if c.Before.Defined() {
+				entry.Before = enc.Encode(c.Before)
+				if entry.SizeBefore, entry.Dir, err = diffFileSize(req.Context, nd.DAG, c.Before); err != nil {
+					return err
+				}
+			}
+			if c.After.Defined() {
+				entry.After = enc.Encode(c.After)
+				dir := false
+				if entry.SizeAfter, dir, err = diffFileSize(req.Context, nd.DAG, c.After); err != nil {
+					return err
+				}
+				entry.Dir = entry.Dir || dir
+			}
+
+			switch c.Type {
+			case dagutils.Add:
+				entry.Type = "added"
+				out.Added++
+			case dagutils.Remove:
+				entry.Type = "removed"
+				out.Removed++
+			default:
+				entry.Type = "modified"
+				out.Modified++
+			}
+			out.SizeDelta += entry.SizeAfter - entry.SizeBefore
+			out.Changes = append(out.Changes, entry)
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DiffOutput) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+			for _, c := range out.Changes {
+				var size string
+				switch {
+				case c.Dir:
+					size = "directory"
+				case c.Type == "added":
+					size = humanize.Bytes(uint64(c.SizeAfter))
+				case c.Type == "removed":
+					size = humanize.Bytes(uint64(c.SizeBefore))
+				default:
+					size = fmt.Sprintf("%s -> %s (%s)", humanize.Bytes(uint64(c.SizeBefore)),
+						humanize.Bytes(uint64(c.SizeAfter)), diffSizeDelta(c.SizeAfter-c.SizeBefore))
+				}
+				fmt.Fprintf(tw, " %s\t| %s, %s\n", c.Path, c.Type, size)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			if len(out.Changes) == 0 {
+				fmt.Fprintln(w, "no changes")
+				return nil
+			}
+			paths := "paths"
+			if len(out.Changes) == 1 {
+				paths = "path"
+			}
+			_, err := fmt.Fprintf(w, "%d %s changed: %d added, %d removed, %d modified (%s)\n",
+				len(out.Changes), paths, out.Added, out.Removed, out.Modified, diffSizeDelta(out.SizeDelta))
+			return err
+		}),
+	},
+	Type: DiffOutput{},
+}
+
+// diffFileSize returns the size of the file c, or true if it is a directory.
+// Only the root block of the file is fetched.
+func diffFileSize(ctx context.Context, ng ipld.NodeGetter, c cid.Cid) (int64, bool, error) {
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return 0, false, err
+	}
+	if dagutils.IsDirectory(nd) {
+		return 0, true, nil
+	}
+
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return int64(len(nd.RawData())), false, nil
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return 0, false, err
+		}
+		return int64(fsn.FileSize()), false, nil
+	default:
+		return 0, false, fmt.Errorf("%s is not a unixfs node", c)
+	}
+}
+
+// diffSizeDelta formats a change in size with its sign.
+func diffSizeDelta(d int64) string {
+	if d < 0 {
+		return "-" + humanize.Bytes(uint64(-d))
+	}
+	return "+" + humanize.Bytes(uint64(d))
+}
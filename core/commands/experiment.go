@@ -0,0 +1,199 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+)
+
+// WasmExecConfigKey enables 'ipfs experiment wasm-exec'.
+const WasmExecConfigKey = "Experimental.WasmExec"
+
+var errWasmExecNotEnabled = errors.New("wasm-exec is not enabled, set " + WasmExecConfigKey + " to true in the config to enable it")
+
+var errWasmExecNotBuilt = errors.New("wasm-exec is not built in this ipfs, build it with 'make wasmexec'")
+
+// wasmModule is a compiled WASI command module, see wasmexec.Module.
+type wasmModule interface {
+	Run(ctx context.Context, name string, args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+	Close(ctx context.Context) error
+}
+
+// WasmExecOutput is a chunk of the outputs of a WASM module.
+type WasmExecOutput struct {
+	Stdout []byte `json:",omitempty"`
+	Stderr []byte `json:",omitempty"`
+}
+
+var ExperimentCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run experimental features.",
+		ShortDescription: `
+The features of 'ipfs experiment' may change or be removed in the future.
+Each of them must be enabled in the Experimental section of the config.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"wasm-exec": experimentWasmExecCmd,
+	},
+}
+
+var experimentWasmExecCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run a WASM module stored in IPFS.",
+		ShortDescription: `
+'ipfs experiment wasm-exec' runs the WASI command module <ipfs-path> with the
+arguments <args>, and prints its stdout and stderr. The command fails if the
+module exits with a non-zero code.
+
+  > ipfs add -Q hello.wasm
+  QmModule
+  > ipfs experiment wasm-exec QmModule -- --name world
+  hello world
+
+The module runs in a sandbox: it doesn't see the files, the environment or
+the network, its stdin is empty and its clocks and random source are fake,
+so a module run with the same arguments prints the same output. Its memory
+is limited to 256MiB; set --timeout to stop the modules which don't exit.
+
+The command has to be enabled with:
+
+  > ipfs config --json Experimental.WasmExec true
+
+The WASM runtime, wazero, is only built in ipfs with 'make wasmexec', as it
+needs a newer Go than the rest of ipfs.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, false, "The path to the WASM module."),
+		cmdkit.StringArg("args", false, true, "The arguments of the module."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		if err := checkWasmExec(env); err != nil {
+			return err
+		}
+		bin, err := readWasmModule(req, env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		m, err := compileWasmModule(req.Context, bin)
+		if err != nil {
+			return err
+		}
+		defer m.Close(req.Context)
+
+		stdout := &wasmExecWriter{res: res}
+		stderr := &wasmExecWriter{res: res, stderr: true}
+		code, err := m.Run(req.Context, req.Arguments[0], req.Arguments[1:], nil, stdout, stderr)
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return fmt.Errorf("the module exited with code %d", code)
+		}
+		return nil
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			for {
+				v, err := res.Next()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				out, ok := v.(*WasmExecOutput)
+				if !ok {
+					return e.TypeErr(out, v)
+				}
+				os.Stdout.Write(out.Stdout)
+				os.Stderr.Write(out.Stderr)
+			}
+		},
+	},
+	Type: WasmExecOutput{},
+}
+
+// wasmExecWriter emits the writes of a module as they happen.
+type wasmExecWriter struct {
+	res    cmds.ResponseEmitter
+	stderr bool
+}
+
+func (w *wasmExecWriter) Write(p []byte) (int, error) {
+	buf := append([]byte(nil), p...)
+	out := &WasmExecOutput{Stdout: buf}
+	if w.stderr {
+		out = &WasmExecOutput{Stderr: buf}
+	}
+	if err := w.res.Emit(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// checkWasmExec returns an error if Experimental.WasmExec is not enabled, or
+// if the WASM runtime is not built in.
+func checkWasmExec(env cmds.Environment) error {
+	n, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+	var enabled bool
+	if _, err := repo.ReadConfigKey(n.Repo, WasmExecConfigKey, &enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		return errWasmExecNotEnabled
+	}
+	if !wasmExecBuilt {
+		return errWasmExecNotBuilt
+	}
+	return nil
+}
+
+// wasmExecMaxModuleSize is the size of the largest WASM module read.
+const wasmExecMaxModuleSize = 64 << 20
+
+// readWasmModule reads the WASM module stored at the unixfs path p.
+func readWasmModule(req *cmds.Request, env cmds.Environment, p string) ([]byte, error) {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return nil, err
+	}
+	fpath, err := iface.ParsePath(p)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := api.Unixfs().Get(req.Context, fpath)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := nd.(files.File)
+	if !ok {
+		return nil, iface.ErrIsDir
+	}
+	defer f.Close()
+	bin, err := ioutil.ReadAll(io.LimitReader(f, wasmExecMaxModuleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(bin) > wasmExecMaxModuleSize {
+		return nil, fmt.Errorf("the module is larger than %d bytes", wasmExecMaxModuleSize)
+	}
+	return bin, nil
+}
@@ -0,0 +1,314 @@
+package commands
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// exportStateLogLines is the number of lines of the daemon log exported.
+const exportStateLogLines = 1000
+
+// exportStateCalls are the commands whose output is exported, by file name.
+var exportStateCalls = []struct {
+	File string
+	Call BatchCall
+}{
+	{"id.json", BatchCall{Cmd: "id"}},
+	{"config.json", BatchCall{Cmd: "config show"}},
+	{"swarm-peers.json", BatchCall{Cmd: "swarm peers"}},
+	{"bitswap-stat.json", BatchCall{Cmd: "bitswap stat"}},
+	{"stats-bw.json", BatchCall{Cmd: "stats bw"}},
+	{"repo-stat.json", BatchCall{Cmd: "repo stat"}},
+	{"diag-sys.json", BatchCall{Cmd: "diag sys"}},
+}
+
+// exportStateSecretKey matches the names of the config fields holding
+// credentials, like the keys of the remote pinning services.
+var exportStateSecretKey = regexp.MustCompile(`(?i)(key|token|secret|password)$`)
+
+// ExportStateOutput lists the files written by 'ipfs export-state'.
+type ExportStateOutput struct {
+	Dir    string
+	Files  []string
+	Bundle string
+}
+
+var ExportStateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Write a snapshot of the state of the node, for debugging.",
+		ShortDescription: `
+'ipfs export-state' writes the output of 'ipfs id', 'ipfs config show',
+'ipfs swarm peers', 'ipfs bitswap stat', 'ipfs stats bw', 'ipfs repo stat'
+and 'ipfs diag sys' as JSON files in <dir>, with the last lines of the daemon
+log, and bundles them in <dir>.tar.gz to attach to a bug report.
+`,
+		LongDescription: `
+'ipfs export-state' writes the output of 'ipfs id', 'ipfs config show',
+'ipfs swarm peers', 'ipfs bitswap stat', 'ipfs stats bw', 'ipfs repo stat'
+and 'ipfs diag sys' as JSON files in <dir>, with the last lines of the daemon
+log, and bundles them in <dir>.tar.gz to attach to a bug report.
+
+The private key and the credentials of the config, like the keys of the
+remote pinning services, are redacted. A command failing, for example
+'ipfs swarm peers' when the node is offline, doesn't stop the export: its
+file holds the error.
+
+The log is only available when it is redirected to a file with
+'ipfs log output'. The daemon streams the bundle, which is saved and
+extracted on the machine 'ipfs export-state' runs on; over the HTTP API,
+the response is the bundle.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("dir", true, false, "The directory to write the files to."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		var files []exportStateFile
+		for _, c := range exportStateCalls {
+			v, err := exportStateRun(req, env, c.Call)
+			if err != nil {
+				v = map[string]string{"Error": err.Error()}
+			}
+			data, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				return err
+			}
+			files = append(files, exportStateFile{c.File, append(data, '\n')})
+		}
+
+		data, err := exportStateLog()
+		if err != nil {
+			return err
+		}
+		files = append(files, exportStateFile{"daemon.log", data})
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeExportStateBundle(pw, filepath.Base(req.Arguments[0]), files))
+		}()
+		return res.Emit(pr)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+			r, ok := v.(io.Reader)
+			if !ok {
+				return e.New(e.TypeErr(r, v))
+			}
+
+			dir, err := filepath.Abs(res.Request().Arguments[0])
+			if err != nil {
+				return err
+			}
+			out, err := extractExportState(r, dir)
+			if err != nil {
+				return err
+			}
+			return re.Emit(out)
+		},
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ExportStateOutput) error {
+			for _, f := range out.Files {
+				fmt.Fprintln(w, filepath.Join(out.Dir, f))
+			}
+			fmt.Fprintf(w, "Bundle: %s\n", out.Bundle)
+			return nil
+		}),
+	},
+}
+
+// exportStateFile is a file of the bundle of 'ipfs export-state'.
+type exportStateFile struct {
+	Name string
+	Data []byte
+}
+
+// exportStateRun runs call and returns its output, with the credentials of
+// the config redacted.
+func exportStateRun(req *cmds.Request, env cmds.Environment, call BatchCall) (interface{}, error) {
+	out, err := runBatchCall(req, env, call)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{} = out
+	if len(out) == 1 {
+		v = out[0]
+	}
+	if call.Cmd != "config show" {
+		return v, nil
+	}
+
+	// the config is emitted as a pointer to a map
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	redactSecrets(cfg)
+	return cfg, nil
+}
+
+// redactedValue replaces the credentials of the config shown.
+const redactedValue = "REDACTED"
+
+// redactSecrets replaces the string values of the credential fields of m,
+// recursively.
+func redactSecrets(m map[string]interface{}) {
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			if v != "" && exportStateSecretKey.MatchString(k) {
+				m[k] = redactedValue
+			}
+		case map[string]interface{}:
+			redactSecrets(v)
+		case []interface{}:
+			for _, e := range v {
+				if em, ok := e.(map[string]interface{}); ok {
+					redactSecrets(em)
+				}
+			}
+		}
+	}
+}
+
+// exportStateLog returns the last lines of the daemon log.
+func exportStateLog() ([]byte, error) {
+	logPath := logOutputPath()
+	if logPath == "" {
+		return []byte("The daemon logs to stderr. Redirect its log to a file with 'ipfs log output' to export it.\n"), nil
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make([]string, 0, exportStateLogLines)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(lines) == exportStateLogLines {
+			lines = lines[1:]
+		}
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, l := range lines {
+		fmt.Fprintln(&buf, l)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeExportStateBundle writes files to w as a tar.gz archive, in a
+// directory named base.
+func writeExportStateBundle(w io.Writer, base string, files []exportStateFile) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	now := time.Now()
+	for _, f := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    path.Join(base, f.Name),
+			Mode:    0644,
+			Size:    int64(len(f.Data)),
+			ModTime: now,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// extractExportState saves the bundle r streamed by the daemon to
+// <dir>.tar.gz, and extracts its files in dir. Only the files export-state
+// writes are accepted.
+func extractExportState(r io.Reader, dir string) (*ExportStateOutput, error) {
+	expected := map[string]bool{"daemon.log": true}
+	for _, c := range exportStateCalls {
+		expected[c.File] = true
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	out := &ExportStateOutput{Dir: dir, Bundle: dir + ".tar.gz"}
+	bundle, err := os.Create(out.Bundle)
+	if err != nil {
+		return nil, err
+	}
+	defer bundle.Close()
+
+	tee := io.TeeReader(r, bundle)
+	gzr, err := gzip.NewReader(tee)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !expected[name] || hdr.Name != path.Join(filepath.Base(dir), name) {
+			return nil, fmt.Errorf("unexpected entry %q in the bundle", hdr.Name)
+		}
+		delete(expected, name)
+
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+		out.Files = append(out.Files, name)
+	}
+	// the end of the gzip stream, for the bundle to be complete
+	if _, err := io.Copy(ioutil.Discard, tee); err != nil {
+		return nil, err
+	}
+	return out, bundle.Close()
+}
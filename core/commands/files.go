@@ -7,11 +7,13 @@ import (
 	"io"
 	"os"
 	gopath "path"
+	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/dagutils"
 
 	"github.com/dustin/go-humanize"
 	bservice "github.com/ipfs/go-blockservice"
@@ -53,16 +55,21 @@ operations.
 		cmdkit.BoolOption(filesFlushOptionName, "f", "Flush target and ancestors after write.").WithDefault(true),
 	},
 	Subcommands: map[string]*cmds.Command{
-		"read":  filesReadCmd,
-		"write": filesWriteCmd,
-		"mv":    filesMvCmd,
-		"cp":    filesCpCmd,
-		"ls":    filesLsCmd,
-		"mkdir": filesMkdirCmd,
-		"stat":  filesStatCmd,
-		"rm":    filesRmCmd,
-		"flush": filesFlushCmd,
-		"chcid": filesChcidCmd,
+		"read":    filesReadCmd,
+		"write":   filesWriteCmd,
+		"mv":      filesMvCmd,
+		"move":    filesMoveCmd,
+		"cp":      filesCpCmd,
+		"ls":      filesLsCmd,
+		"mkdir":   filesMkdirCmd,
+		"stat":    filesStatCmd,
+		"rm":      filesRmCmd,
+		"flush":   filesFlushCmd,
+		"find":    filesFindCmd,
+		"diff":    filesDiffCmd,
+		"chcid":   filesChcidCmd,
+		"symlink": filesSymlinkCmd,
+		"import":  filesImportCmd,
 	},
 }
 
@@ -244,6 +251,8 @@ func statNode(nd ipld.Node, enc cidenc.Encoder) (*statOutput, error) {
 			ndtype = "directory"
 		case ft.TFile, ft.TMetadata, ft.TRaw:
 			ndtype = "file"
+		case ft.TSymlink:
+			ndtype = "symlink"
 		default:
 			return nil, fmt.Errorf("unrecognized node type: %s", d.Type())
 		}
@@ -613,12 +622,17 @@ func (crw *contextReaderWrapper) Read(b []byte) (int, error) {
 	return crw.R.CtxReadFull(crw.ctx, b)
 }
 
+const filesNoOverwriteOptionName = "no-overwrite"
+
 var filesMvCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Move files.",
 		ShortDescription: `
 Move files around. Just like traditional unix mv.
 
+By default an existing destination file is overwritten. Use '--no-overwrite'
+to fail instead, like 'mv -n'.
+
 Example:
 
     $ ipfs files mv /myfs/a/b/c /myfs/foo/newc
@@ -630,29 +644,98 @@ Example:
 		cmdkit.StringArg("source", true, false, "Source file to move."),
 		cmdkit.StringArg("dest", true, false, "Destination path for file to be moved to."),
 	},
-	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		nd, err := cmdenv.GetNode(env)
-		if err != nil {
-			return err
-		}
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(filesNoOverwriteOptionName, "n", "Do not overwrite an existing destination."),
+	},
+	Run: filesMvRun,
+}
 
-		flush, _ := req.Options[filesFlushOptionName].(bool)
+var filesMoveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Move files without overwriting existing ones.",
+		ShortDescription: `
+Move files around, refusing to overwrite an existing destination. This is an
+alias for 'ipfs files mv --no-overwrite'. Pass '--no-overwrite=false' to allow
+overwriting.
 
-		src, err := checkPath(req.Arguments[0])
+Example:
+
+    $ ipfs files move /myfs/a/b/c /myfs/foo/newc
+
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("source", true, false, "Source file to move."),
+		cmdkit.StringArg("dest", true, false, "Destination path for file to be moved to."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(filesNoOverwriteOptionName, "n", "Do not overwrite an existing destination.").WithDefault(true),
+	},
+	Run: filesMvRun,
+}
+
+func filesMvRun(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+
+	flush, _ := req.Options[filesFlushOptionName].(bool)
+	noOverwrite, _ := req.Options[filesNoOverwriteOptionName].(bool)
+
+	src, err := checkPath(req.Arguments[0])
+	if err != nil {
+		return err
+	}
+	dst, err := checkPath(req.Arguments[1])
+	if err != nil {
+		return err
+	}
+
+	if noOverwrite {
+		target, err := mvTarget(nd.FilesRoot, src, dst)
 		if err != nil {
 			return err
 		}
-		dst, err := checkPath(req.Arguments[1])
-		if err != nil {
+
+		_, err = mfs.Lookup(nd.FilesRoot, target)
+		switch err {
+		case nil:
+			return fmt.Errorf("mv: destination %s already exists", target)
+		case os.ErrNotExist:
+		default:
 			return err
 		}
+	}
 
-		err = mfs.Mv(nd.FilesRoot, src, dst)
-		if err == nil && flush {
-			err = mfs.FlushPath(nd.FilesRoot, "/")
+	err = mfs.Mv(nd.FilesRoot, src, dst)
+	if err == nil && flush {
+		err = mfs.FlushPath(nd.FilesRoot, "/")
+	}
+	return err
+}
+
+// mvTarget returns the path mfs.Mv will move src to when asked to move it to
+// dst. Moving onto an existing directory moves src into that directory.
+func mvTarget(r *mfs.Root, src, dst string) (string, error) {
+	name := gopath.Base(src)
+	if dst[len(dst)-1] == '/' {
+		return dst + name, nil
+	}
+
+	fsn, err := mfs.Lookup(r, dst)
+	switch err {
+	case nil:
+		if _, ok := fsn.(*mfs.Directory); ok {
+			return gopath.Join(dst, name), nil
 		}
-		return err
-	},
+		return dst, nil
+	case os.ErrNotExist:
+		return dst, nil
+	default:
+		return "", err
+	}
 }
 
 const (
@@ -856,30 +939,339 @@ Examples:
 	},
 }
 
+const filesAllOptionName = "all"
+
+type flushRes struct {
+	Cid string
+}
+
 var filesFlushCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Flush a given path's data to disk.",
 		ShortDescription: `
 Flush a given path to disk. This is only useful when other commands
 are run with the '--flush=false'.
+
+Use '--all' to flush every pending change in the whole tree. The CID of the
+mfs root after the flush is printed.
 `,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("path", false, false, "Path to flush. Default: '/'."),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(filesAllOptionName, "a", "Flush the entire mfs tree."),
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		nd, err := cmdenv.GetNode(env)
 		if err != nil {
 			return err
 		}
 
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
 		path := "/"
 		if len(req.Arguments) > 0 {
 			path = req.Arguments[0]
 		}
 
-		return mfs.FlushPath(nd.FilesRoot, path)
+		// flushing the root propagates down through the entire tree
+		all, _ := req.Options[filesAllOptionName].(bool)
+		if all && path != "/" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "cannot flush a path and --all at the same time")
+		}
+
+		err = mfs.FlushPath(nd.FilesRoot, path)
+		if err != nil {
+			return err
+		}
+
+		root, err := nd.FilesRoot.GetDirectory().GetNode()
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &flushRes{enc.Encode(root.Cid())})
 	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *flushRes) error {
+			_, err := fmt.Fprintln(w, out.Cid)
+			return err
+		}),
+	},
+	Type: flushRes{},
+}
+
+const (
+	filesNameOptionName = "name"
+	filesCidOptionName  = "cid"
+	filesTypeOptionName = "type"
+)
+
+type filesFindOutput struct {
+	Path string
+	Hash string
+	Type string
+}
+
+var filesFindCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Search for files in the local mutable namespace.",
+		ShortDescription: `
+Walk the directory tree below <root-path> depth-first and print the path of
+every entry matching all of the given filters. Just like traditional unix find.
+
+'--name' matches the entry name against a shell pattern, using the syntax of
+Go's filepath.Match. '--cid' matches entries whose node has the given CID,
+which is useful to find all paths linking to the same content. '--type'
+restricts the results to entries of type 'file' or 'dir'.
+
+Use '--enc=json' for structured output.
+
+Examples:
+
+    $ ipfs files find /myfs --name '*.jpg'
+    /myfs/holiday/beach.jpg
+    /myfs/cat.jpg
+
+    $ ipfs files find / --cid QmcuN9e3NM1QpcEEawEkKCrTLdQz9tWFW4un7pLKYjbrQ6
+    /myfs/cat.jpg
+    /backup/cat.jpg
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("root-path", false, false, "Path to start searching from. Defaults to '/'."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(filesNameOptionName, "Only match entries whose name matches this pattern."),
+		cmdkit.StringOption(filesCidOptionName, "Only match entries with this CID."),
+		cmdkit.StringOption(filesTypeOptionName, "Only match entries of this type (file or dir)."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		root := "/"
+		if len(req.Arguments) > 0 {
+			root = req.Arguments[0]
+		}
+		root, err = checkPath(root)
+		if err != nil {
+			return err
+		}
+		if root != "/" {
+			root = strings.TrimRight(root, "/")
+		}
+
+		name, _ := req.Options[filesNameOptionName].(string)
+		if name != "" {
+			// catch malformed patterns before walking the whole tree
+			if _, err := filepath.Match(name, ""); err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid name pattern: %s", err)
+			}
+		}
+
+		var match cid.Cid
+		if cidStr, ok := req.Options[filesCidOptionName].(string); ok {
+			match, err = cid.Decode(cidStr)
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid cid: %s", err)
+			}
+		}
+
+		typ, _ := req.Options[filesTypeOptionName].(string)
+		switch typ {
+		case "", "file":
+		case "dir", "directory":
+			typ = "directory"
+		default:
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid type %q, must be 'file' or 'dir'", typ)
+		}
+
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		fsn, err := mfs.Lookup(nd.FilesRoot, root)
+		if err != nil {
+			return err
+		}
+
+		return walkMfs(req.Context, root, fsn, func(p string, fsn mfs.FSNode) error {
+			ntyp := "file"
+			if fsn.Type() == mfs.TDir {
+				ntyp = "directory"
+			}
+			if typ != "" && typ != ntyp {
+				return nil
+			}
+
+			if name != "" {
+				ok, _ := filepath.Match(name, gopath.Base(p))
+				if !ok {
+					return nil
+				}
+			}
+
+			n, err := fsn.GetNode()
+			if err != nil {
+				return err
+			}
+			if match.Defined() && !match.Equals(n.Cid()) {
+				return nil
+			}
+
+			return res.Emit(&filesFindOutput{
+				Path: p,
+				Hash: enc.Encode(n.Cid()),
+				Type: ntyp,
+			})
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *filesFindOutput) error {
+			_, err := fmt.Fprintln(w, out.Path)
+			return err
+		}),
+	},
+	Type: filesFindOutput{},
+}
+
+// walkMfs calls f for fsn and, if it is a directory, every node below it in
+// depth-first order. Entries of a directory are visited sorted by name.
+func walkMfs(ctx context.Context, p string, fsn mfs.FSNode, f func(string, mfs.FSNode) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := f(p, fsn); err != nil {
+		return err
+	}
+
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return nil
+	}
+
+	names, err := dir.ListNames(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child, err := dir.Child(name)
+		if err != nil {
+			return err
+		}
+
+		if err := walkMfs(ctx, gopath.Join(p, name), child, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var filesDiffCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the differences between two directory trees.",
+		ShortDescription: `
+Compare two unixfs directories and print one line per changed entry:
+
+    +path    entry only exists in the second directory
+    -path    entry only exists in the first directory
+    Mpath    entry exists in both but its CID differs
+
+Both arguments may be mfs paths, /ipfs/ paths or plain CIDs. By default only
+the immediate entries are compared; '--recursive' descends into directories
+present on both sides. Subtrees with identical CIDs are skipped without being
+fetched.
+
+Example:
+
+    $ ipfs files diff -r /snapshots/monday /snapshots/tuesday
+    +docs/new.txt
+    Mdocs/readme.md
+    -old.txt
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("a", true, false, "Directory to compare against."),
+		cmdkit.StringArg("b", true, false, "Directory to compare."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(recursiveOptionName, "r", "Recursively compare subdirectories."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		recursive, _ := req.Options[recursiveOptionName].(bool)
+
+		var nodes [2]ipld.Node
+		for i, arg := range req.Arguments[:2] {
+			p, err := diffPath(arg)
+			if err != nil {
+				return err
+			}
+
+			nodes[i], err = getNodeFromPath(req.Context, nd, api, p)
+			if err != nil {
+				return fmt.Errorf("diff: cannot get node from path %s: %s", arg, err)
+			}
+		}
+
+		changes, err := dagutils.DiffDirectories(req.Context, nd.DAG, nodes[0], nodes[1], recursive)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range changes {
+			if err := res.Emit(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *dagutils.Change) error {
+			var prefix string
+			switch out.Type {
+			case dagutils.Add:
+				prefix = "+"
+			case dagutils.Remove:
+				prefix = "-"
+			case dagutils.Mod:
+				prefix = "M"
+			}
+			_, err := fmt.Fprintf(w, "%s%s\n", prefix, out.Path)
+			return err
+		}),
+	},
+	Type: dagutils.Change{},
+}
+
+// diffPath accepts a plain CID in addition to the paths checkPath accepts.
+func diffPath(p string) (string, error) {
+	if !strings.HasPrefix(p, "/") {
+		if _, err := cid.Decode(p); err == nil {
+			return "/ipfs/" + p, nil
+		}
+	}
+	return checkPath(p)
 }
 
 var filesChcidCmd = &cmds.Command{
@@ -1032,6 +1424,189 @@ Remove files or directories.
 	},
 }
 
+const filesAllowDanglingOptionName = "allow-dangling"
+
+var filesSymlinkCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create a symbolic link.",
+		ShortDescription: `
+Create a unixfs symlink at <link-path> pointing to <target>. Just like
+traditional unix 'ln -s'.
+
+Relative targets are resolved against the directory containing <link-path>.
+The target must exist unless '--allow-dangling' is passed.
+
+Examples:
+
+    $ ipfs files symlink /myfs/a/b/c /myfs/c
+    $ ipfs files symlink ../c /myfs/d/c
+    $ ipfs files symlink --allow-dangling /does/not/exist /myfs/broken
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("target", true, false, "Path the symlink will point to."),
+		cmdkit.StringArg("link-path", true, false, "Path of the symlink to create."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(filesAllowDanglingOptionName, "Create the symlink even if the target does not exist."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		flush, _ := req.Options[filesFlushOptionName].(bool)
+		allowDangling, _ := req.Options[filesAllowDanglingOptionName].(bool)
+
+		target := req.Arguments[0]
+		if target == "" {
+			return fmt.Errorf("symlink: target must not be empty")
+		}
+
+		dst, err := checkPath(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		dst = strings.TrimRight(dst, "/")
+		if dst == "" {
+			return fmt.Errorf("symlink: cannot replace root")
+		}
+
+		if !allowDangling {
+			resolved := target
+			if !strings.HasPrefix(resolved, "/") {
+				resolved = gopath.Join(gopath.Dir(dst), resolved)
+			}
+
+			_, err := getNodeFromPath(req.Context, nd, api, resolved)
+			if err != nil {
+				return fmt.Errorf("symlink: target %s does not exist, use --%s to create it anyway", target, filesAllowDanglingOptionName)
+			}
+		}
+
+		data, err := ft.SymlinkData(target)
+		if err != nil {
+			return err
+		}
+
+		pdir, err := mfs.Lookup(nd.FilesRoot, gopath.Dir(dst))
+		if err != nil {
+			return fmt.Errorf("symlink: parent lookup: %s", err)
+		}
+		dir, ok := pdir.(*mfs.Directory)
+		if !ok {
+			return fmt.Errorf("symlink: %s is not a directory", gopath.Dir(dst))
+		}
+
+		link := dag.NodeWithData(data)
+		link.SetCidBuilder(dir.GetCidBuilder())
+
+		err = mfs.PutNode(nd.FilesRoot, dst, link)
+		if err != nil {
+			return fmt.Errorf("symlink: cannot create symlink %s: %s", dst, err)
+		}
+
+		// mfs cannot open symlinks, flush the containing directory instead
+		if flush {
+			err := mfs.FlushPath(nd.FilesRoot, gopath.Dir(dst))
+			if err != nil {
+				return fmt.Errorf("symlink: cannot flush the created symlink %s: %s", dst, err)
+			}
+		}
+
+		return nil
+	},
+}
+
+const filesMfsDestOptionName = "mfs-dest"
+
+type filesImportOutput struct {
+	Path string
+}
+
+var filesImportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add a local file or directory and link it into mfs.",
+		ShortDescription: `
+'ipfs files import' adds <path> to ipfs like 'ipfs add', and links the result
+into mfs at the path given with --mfs-dest, replacing the entry already there.
+When --mfs-dest is a directory, the entry is named after <path>. It defaults
+to /<name of path>. The path of the entry is printed.
+
+The options of 'ipfs add' that change how the data is added, like --chunker
+or --pin, are accepted as well.
+
+  > ipfs files import -r photos --mfs-dest=/backups/
+  /backups/photos
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("path", true, false, "The path to a local file or directory to import.").EnableRecursive(),
+	},
+	Options: append([]cmdkit.Option{
+		cmdkit.StringOption(filesMfsDestOptionName, "Path or directory of the mfs entry to create. Defaults to /<name of path>."),
+	}, filesImportAddOptions()...),
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		dest, ok := req.Options[filesMfsDestOptionName].(string)
+		if !ok {
+			dest = "/"
+		}
+		req.Options[toMfsOptionName] = dest
+
+		var p string
+		if err := runAdd(req, discardEmitter{res}, env, &p); err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &filesImportOutput{Path: p})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *filesImportOutput) error {
+			_, err := fmt.Fprintln(w, out.Path)
+			return err
+		}),
+	},
+	Type: filesImportOutput{},
+}
+
+// filesImportAddOptions returns the options of 'ipfs add' that 'ipfs files
+// import' accepts: the ones changing how the data is added. --checkpoint is
+// left out, the progress events written to the checkpoint are not emitted.
+func filesImportAddOptions() []cmdkit.Option {
+	skip := map[string]bool{
+		quietOptionName:         true,
+		quieterOptionName:       true,
+		silentOptionName:        true,
+		progressOptionName:      true,
+		onlyHashOptionName:      true,
+		estimateDedupOptionName: true,
+		checkpointOptionName:    true,
+		stdinPathName:           true,
+		toMfsOptionName:         true,
+	}
+	var opts []cmdkit.Option
+	for _, opt := range AddCmd.Options {
+		if !skip[opt.Name()] {
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
+
+// discardEmitter drops the values emitted.
+type discardEmitter struct {
+	cmds.ResponseEmitter
+}
+
+func (discardEmitter) Emit(interface{}) error {
+	return nil
+}
+
 func getPrefixNew(req *cmds.Request) (cid.Builder, error) {
 	cidVer, cidVerSet := req.Options[filesCidVersionOptionName].(int)
 	hashFunStr, hashFunSet := req.Options[filesHashOptionName].(string)
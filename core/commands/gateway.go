@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	oldcmds "github.com/ipfs/go-ipfs/commands"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+var GatewayCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the HTTP gateway of the running daemon.",
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"reload":  gatewayReloadCmd,
+		"dnslink": gatewayDNSLinkCmd,
+	},
+}
+
+// DNSLinkOutput is the DNSLink record of a domain, as the gateway resolves
+// it.
+type DNSLinkOutput struct {
+	Domain   string
+	Record   string
+	Link     string
+	TTL      time.Duration
+	Cached   bool
+	Resolved string `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+var gatewayDNSLinkCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the DNSLink records the gateway resolves.",
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"resolve": gatewayDNSLinkResolveCmd,
+	},
+}
+
+var gatewayDNSLinkResolveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show how the gateway resolves the DNSLink of a domain.",
+		ShortDescription: `
+'ipfs gateway dnslink resolve' looks up the DNSLink record of <domain> the way
+the gateway does, and prints the TXT record it was found in, the link, how
+long it is cached for and whether it came from the cache. A link to an IPNS
+name is then resolved to the path the gateway serves.
+
+The records are cached according to Gateway.DNSLink.Cache: the records of up
+to Size domains are kept for their TTL, at most Cache.TTL.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("domain", true, false, "The domain to resolve."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		domain := req.Arguments[0]
+		resolver := n.DNSLink
+		if resolver == nil {
+			resolver = namesys.NewDNSResolver()
+		}
+		link, err := resolver.LookupDNSLink(req.Context, domain)
+		if err != nil {
+			return fmt.Errorf("no DNSLink record for %s: %s", domain, err)
+		}
+
+		out := &DNSLinkOutput{
+			Domain: domain,
+			Record: link.Record,
+			Link:   link.Path.String(),
+			TTL:    link.TTL,
+			Cached: link.Cached,
+		}
+		// as the gateway, fall back to IPNS for the links to IPNS names
+		resolved, err := n.Namesys.Resolve(req.Context, out.Link)
+		if err != nil {
+			out.Error = err.Error()
+		} else if resolved.String() != out.Link {
+			out.Resolved = resolved.String()
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DNSLinkOutput) error {
+			cached := ""
+			if out.Cached {
+				cached = ", cached"
+			}
+			fmt.Fprintf(w, "%s: %s (TTL %s%s)\n", out.Record, out.Link, out.TTL, cached)
+			if out.Resolved != "" {
+				fmt.Fprintf(w, "resolved to %s\n", out.Resolved)
+			}
+			if out.Error != "" {
+				fmt.Fprintf(w, "could not resolve %s: %s\n", out.Link, out.Error)
+			}
+			return nil
+		}),
+	},
+	Type: DNSLinkOutput{},
+}
+
+var gatewayReloadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Reload the gateway configuration without restarting the daemon.",
+		ShortDescription: `
+Re-read the 'Gateway' section of the config file and apply it to the gateway
+of the running daemon, then list what changed. Requests already being served
+finish with the previous configuration.
+
+Gateway.HTTPHeaders, Gateway.PathPrefixes and Gateway.NoFetch are reloaded.
+Changes to Addresses.Gateway, Gateway.Writable and Gateway.RootRedirect still
+require a restart of the daemon.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		ctx := env.(*oldcmds.Context)
+		if ctx.ReloadGateway == nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the daemon is not serving a gateway")
+		}
+
+		changes, err := ctx.ReloadGateway()
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &stringList{changes})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, list *stringList) error {
+			if len(list.Strings) == 0 {
+				_, err := fmt.Fprintln(w, "Gateway config reloaded, nothing changed")
+				return err
+			}
+			for _, s := range list.Strings {
+				fmt.Fprintln(w, s)
+			}
+			return nil
+		}),
+	},
+	Type: stringList{},
+}
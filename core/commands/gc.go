@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const (
+	gcIntervalOptionName = "interval"
+	gcAtOptionName       = "at"
+	gcMinFreedOptionName = "min-freed"
+)
+
+// GCScheduleOutput is the GC schedule, if any, and the time of the next GC
+// when the daemon runs it.
+type GCScheduleOutput struct {
+	Schedule *corerepo.GCSchedule
+	Next     string `json:",omitempty"`
+}
+
+var GcCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the garbage collection of the repo.",
+		ShortDescription: `
+'ipfs repo gc' runs a GC now, 'ipfs gc schedule' makes the daemon run them
+on a schedule.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"schedule": gcScheduleCmd,
+	},
+}
+
+var gcScheduleCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run the garbage collection on a schedule.",
+		ShortDescription: `
+'ipfs gc schedule' sets the schedule of the GCs run by the daemon in the
+config, and starts it when the daemon is running:
+
+    > ipfs gc schedule --interval=24h --at=02:00 --min-freed=1GiB
+
+With --at, the GCs run at that local time of day, and every --interval
+after it. With --min-freed, a GC is skipped when it would free less space,
+which takes marking the blocks to keep like a GC does.
+
+The schedule is independent of 'ipfs daemon --enable-gc', which runs a GC
+every Datastore.GCPeriod once the repo is over its Datastore.StorageMax
+watermark.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(gcIntervalOptionName, "Time between two GCs.").WithDefault("24h"),
+		cmdkit.StringOption(gcAtOptionName, "Local time of day of the GCs, as HH:MM."),
+		cmdkit.StringOption(gcMinFreedOptionName, "Skip the GCs freeing less space than this, like 1GiB."),
+	},
+	Subcommands: map[string]*cmds.Command{
+		"show":    gcScheduleShowCmd,
+		"disable": gcScheduleDisableCmd,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		s := &corerepo.GCSchedule{}
+		s.Interval, _ = req.Options[gcIntervalOptionName].(string)
+		s.At, _ = req.Options[gcAtOptionName].(string)
+		s.MinFreed, _ = req.Options[gcMinFreedOptionName].(string)
+		if err := s.Validate(); err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "%s", err)
+		}
+
+		r, err := openConfigRepo(env)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if err := r.SetConfigKey(corerepo.GCScheduleConfigKey, s); err != nil {
+			return err
+		}
+
+		out := &GCScheduleOutput{Schedule: s}
+		if n.IsDaemon {
+			startGCSchedule(n, *s)
+			next, _ := s.Next(time.Now())
+			out.Next = next.Format(time.RFC3339)
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *GCScheduleOutput) error {
+			return printGCSchedule(w, out)
+		}),
+	},
+	Type: GCScheduleOutput{},
+}
+
+var gcScheduleShowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the GC schedule.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		r, err := openConfigRepo(env)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		s, err := corerepo.ReadGCSchedule(r)
+		if err != nil {
+			return err
+		}
+
+		out := &GCScheduleOutput{Schedule: s}
+		if s != nil && n.IsDaemon && gcScheduleRunning() {
+			if next, err := s.Next(time.Now()); err == nil {
+				out.Next = next.Format(time.RFC3339)
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *GCScheduleOutput) error {
+			return printGCSchedule(w, out)
+		}),
+	},
+	Type: GCScheduleOutput{},
+}
+
+var gcScheduleDisableCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove the GC schedule.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		r, err := openConfigRepo(env)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		stopGCSchedule()
+		return r.SetConfigKey(corerepo.GCScheduleConfigKey, nil)
+	},
+}
+
+func printGCSchedule(w io.Writer, out *GCScheduleOutput) error {
+	if out.Schedule == nil {
+		_, err := fmt.Fprintln(w, "No GC schedule")
+		return err
+	}
+	fmt.Fprintf(w, "Interval:  %s\n", out.Schedule.Interval)
+	if out.Schedule.At != "" {
+		fmt.Fprintf(w, "At:        %s\n", out.Schedule.At)
+	}
+	if out.Schedule.MinFreed != "" {
+		fmt.Fprintf(w, "Min freed: %s\n", out.Schedule.MinFreed)
+	}
+	if out.Next != "" {
+		fmt.Fprintf(w, "Next GC:   %s\n", out.Next)
+	}
+	return nil
+}
+
+// gcSchedule is the scheduled GC running in this process.
+var gcSchedule struct {
+	sync.Mutex
+	cancel context.CancelFunc
+}
+
+// StartGCSchedule starts the scheduled GC configured for n, which runs as a
+// daemon.
+func StartGCSchedule(n *core.IpfsNode) error {
+	s, err := corerepo.ReadGCSchedule(n.Repo)
+	if err != nil || s == nil {
+		return err
+	}
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	startGCSchedule(n, *s)
+	return nil
+}
+
+// startGCSchedule replaces the scheduled GC of n by s, until n closes.
+func startGCSchedule(n *core.IpfsNode, s corerepo.GCSchedule) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	gcSchedule.Lock()
+	if gcSchedule.cancel != nil {
+		gcSchedule.cancel()
+	}
+	gcSchedule.cancel = cancel
+	gcSchedule.Unlock()
+
+	go func() {
+		if err := corerepo.ScheduledGC(ctx, n, s); err != nil {
+			log.Errorf("scheduled GC: %s", err)
+		}
+	}()
+	go func() {
+		select {
+		case <-n.Process().Closing():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// stopGCSchedule stops the scheduled GC, if it runs.
+func stopGCSchedule() {
+	gcSchedule.Lock()
+	defer gcSchedule.Unlock()
+	if gcSchedule.cancel != nil {
+		gcSchedule.cancel()
+		gcSchedule.cancel = nil
+	}
+}
+
+func gcScheduleRunning() bool {
+	gcSchedule.Lock()
+	defer gcSchedule.Unlock()
+	return gcSchedule.cancel != nil
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"bufio"
 	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	"github.com/ipfs/go-ipfs/core/commands/e"
+	"github.com/ipfs/go-ipfs/core/coreunix"
 
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -29,6 +31,7 @@ const (
 	archiveOptionName          = "archive"
 	compressOptionName         = "compress"
 	compressionLevelOptionName = "compression-level"
+	resumeOptionName           = "resume"
 )
 
 var GetCmd = &cmds.Command{
@@ -44,6 +47,19 @@ To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
 
 To compress the output with GZIP compression, use '--compress' or '-C'. You
 may also specify the level of compression by specifying '-l=<1-9>'.
+
+To resume an interrupted download, use '--resume=<path>' with the output path
+of the interrupted one. The files already written there are checked against
+the object, and the partial ones are completed, fetching only the blocks they
+miss. The data of a partial file is kept up to the last block it fully holds,
+and the whole file is checked once completed. The symlinks found in the
+output path are never followed. The paths and sizes of the files already
+written are sent to the daemon in the body of the request, as a JSON map:
+the path to get must be given as an argument rather than on stdin.
+
+  > ipfs get QmHash -o QmHash
+  ^C
+  > ipfs get QmHash --resume QmHash
 `,
 	},
 
@@ -55,10 +71,40 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		cmdkit.BoolOption(archiveOptionName, "a", "Output a TAR archive."),
 		cmdkit.BoolOption(compressOptionName, "C", "Compress the output with GZIP compression."),
 		cmdkit.IntOption(compressionLevelOptionName, "l", "The level of compression (1-9)."),
+		cmdkit.StringOption(resumeOptionName, "Resume an interrupted download into the given output path."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
 		_, err := getCompressOptions(req)
-		return err
+		if err != nil {
+			return err
+		}
+
+		if resume, ok := req.Options[resumeOptionName].(string); ok {
+			archive, _ := req.Options[archiveOptionName].(bool)
+			cmprs, _ := req.Options[compressOptionName].(bool)
+			_, output := req.Options[outputOptionName]
+			if archive || cmprs || output {
+				return fmt.Errorf("--%s can't be used with --%s, --%s or --%s", resumeOptionName, outputOptionName, archiveOptionName, compressOptionName)
+			}
+
+			// the body of the request holds the local files, not stdin
+			if len(req.Arguments) == 0 {
+				return fmt.Errorf("--%s needs the path as an argument, not on stdin", resumeOptionName)
+			}
+
+			// the files are written here: the daemon is sent the local
+			// files to only send what they miss
+			local, err := coreunix.LocalFiles(resume)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(local)
+			if err != nil {
+				return err
+			}
+			cmdenv.SetFileArg(req, "local", files.NewBytesFile(data))
+		}
+		return nil
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		cmplvl, err := getCompressOptions(req)
@@ -76,6 +122,10 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			return err
 		}
 
+		if _, ok := req.Options[resumeOptionName].(string); ok {
+			return resumeGet(req, res, api, p)
+		}
+
 		file, err := api.Unixfs().Get(req.Context, p)
 		if err != nil {
 			return err
@@ -110,6 +160,24 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 				return e.New(e.TypeErr(outReader, v))
 			}
 
+			if resume, ok := req.Options[resumeOptionName].(string); ok {
+				fmt.Fprintf(os.Stdout, "Resuming file(s) in %s\n", resume)
+				return coreunix.ExtractResumeArchive(outReader, resume, func(f coreunix.ResumedFile) {
+					name := f.Path
+					if name == "" {
+						name = filepath.Base(resume)
+					}
+					switch f.Offset {
+					case f.Size:
+						fmt.Fprintf(os.Stdout, "%s: complete\n", name)
+					case 0:
+						fmt.Fprintf(os.Stdout, "%s: downloading %d bytes\n", name, f.Size)
+					default:
+						fmt.Fprintf(os.Stdout, "%s: resuming at byte %d of %d\n", name, f.Offset, f.Size)
+					}
+				})
+			}
+
 			outPath := getOutPath(req)
 
 			cmplvl, err := getCompressOptions(req)
@@ -132,6 +200,40 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 	},
 }
 
+// resumeGet emits the archive completing the local files of an interrupted
+// get to the object at p.
+func resumeGet(req *cmds.Request, res cmds.ResponseEmitter, api iface.CoreAPI, p iface.Path) error {
+	// the ipfs-path argument reads stdin: through the API, the body of the
+	// request is taken as the body arguments rather than as its files
+	var body io.Reader
+	if req.Files != nil {
+		f, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		body = f
+	} else if args := req.BodyArgs(); args != nil {
+		body = args
+	} else {
+		return cmdkit.Errorf(cmdkit.ErrClient, "--%s needs the files already written, in the body of the request", resumeOptionName)
+	}
+	var local map[string]int64
+	if err := json.NewDecoder(body).Decode(&local); err != nil {
+		return cmdkit.Errorf(cmdkit.ErrClient, "invalid files already written: %s", err)
+	}
+
+	nd, err := api.ResolveNode(req.Context, p)
+	if err != nil {
+		return err
+	}
+
+	piper, pipew := io.Pipe()
+	go func() {
+		pipew.CloseWithError(coreunix.WriteResumeArchive(req.Context, api.Dag(), nd, local, pipew))
+	}()
+	return res.Emit(piper)
+}
+
 type clearlineReader struct {
 	io.Reader
 	out io.Writer
@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+const graphVisitorOptionName = "visitor"
+
+// GraphWalkPair is a key emitted by the visitor of 'ipfs graph walk', with
+// all its values.
+type GraphWalkPair struct {
+	Key    string
+	Values []string
+}
+
+// GraphWalkOutput is the aggregated result of 'ipfs graph walk'.
+type GraphWalkOutput struct {
+	// Nodes is the number of nodes visited.
+	Nodes int
+	Pairs []GraphWalkPair
+}
+
+var GraphCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Process DAGs with WASM modules.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"walk": graphWalkCmd,
+	},
+}
+
+var graphWalkCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run a WASM visitor on every node of a DAG.",
+		ShortDescription: `
+'ipfs graph walk' traverses the DAG <cid> depth first and runs the WASI
+command module --visitor on each of its nodes, with the raw bytes of the node
+as stdin and its CID as argument. Each line the visitor prints is a key and
+a value separated by a tab, the value being empty if the line has no tab.
+
+Once the walk is done, the keys are printed in order, each followed by all
+the values emitted for it in the order of the walk. With a visitor printing
+"size", a tab and the length of its input:
+
+  > ipfs graph walk --visitor=QmSizeVisitor QmRootOfTheDAG
+  size	108	262158	262158	1244
+
+The walk fails if the visitor exits with a non-zero code. The visitor runs
+in the sandbox of 'ipfs experiment wasm-exec', which must be enabled with
+Experimental.WasmExec in the config.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The root of the DAG to walk."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(graphVisitorOptionName, "The path to the WASM visitor."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		if err := checkWasmExec(env); err != nil {
+			return err
+		}
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		root, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid cid %q: %s", req.Arguments[0], err)
+		}
+		visitor, _ := req.Options[graphVisitorOptionName].(string)
+		if visitor == "" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the --%s option is required", graphVisitorOptionName)
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		bin, err := readWasmModule(req, env, visitor)
+		if err != nil {
+			return err
+		}
+		m, err := compileWasmModule(req.Context, bin)
+		if err != nil {
+			return fmt.Errorf("invalid visitor: %s", err)
+		}
+		defer m.Close(req.Context)
+
+		values := make(map[string][]string)
+		visited := 0
+		visit := func(nd ipld.Node) error {
+			visited++
+			c := enc.Encode(nd.Cid())
+			var stdout, stderr bytes.Buffer
+			code, err := m.Run(req.Context, visitor, []string{c}, bytes.NewReader(nd.RawData()), &stdout, &stderr)
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				msg := strings.TrimSpace(stderr.String())
+				if msg == "" {
+					return fmt.Errorf("the visitor exited with code %d on %s", code, c)
+				}
+				return fmt.Errorf("the visitor exited with code %d on %s: %s", code, c, msg)
+			}
+
+			s := bufio.NewScanner(&stdout)
+			for s.Scan() {
+				line := s.Text()
+				if line == "" {
+					continue
+				}
+				kv := strings.SplitN(line, "\t", 2)
+				if len(kv) == 1 {
+					kv = append(kv, "")
+				}
+				values[kv[0]] = append(values[kv[0]], kv[1])
+			}
+			return s.Err()
+		}
+
+		ng := dag.NewSession(req.Context, n.DAG)
+		seen := cid.NewSet()
+		var walk func(c cid.Cid) error
+		walk = func(c cid.Cid) error {
+			if !seen.Visit(c) {
+				return nil
+			}
+			nd, err := ng.Get(req.Context, c)
+			if err != nil {
+				return err
+			}
+			if err := visit(nd); err != nil {
+				return err
+			}
+			for _, l := range nd.Links() {
+				if err := walk(l.Cid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := walk(root); err != nil {
+			return err
+		}
+
+		out := &GraphWalkOutput{Nodes: visited, Pairs: []GraphWalkPair{}}
+		for k, vs := range values {
+			out.Pairs = append(out.Pairs, GraphWalkPair{Key: k, Values: vs})
+		}
+		sort.Slice(out.Pairs, func(i, j int) bool { return out.Pairs[i].Key < out.Pairs[j].Key })
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *GraphWalkOutput) error {
+			for _, p := range out.Pairs {
+				if _, err := fmt.Fprintln(w, strings.Join(append([]string{p.Key}, p.Values...), "\t")); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+	Type: GraphWalkOutput{},
+}
@@ -35,6 +35,11 @@ type IdOutput struct {
 	Addresses       []string
 	AgentVersion    string
 	ProtocolVersion string
+
+	// Set for the local node only, once the daemon has been running long
+	// enough for AutoNAT to complete.
+	NATStatus         string   `json:",omitempty"`
+	ExternalAddresses []string `json:",omitempty"`
 }
 
 const (
@@ -54,6 +59,19 @@ If no peer is specified, prints out information for local peers.
 <pver>: Protocol version.
 <pubkey>: Public key.
 <addrs>: Addresses (newline delimited).
+<nat>: NAT status of the local node.
+<extaddrs>: External addresses of the local node (newline delimited).
+
+For the local node, the output also shows the NAT status and the addresses
+other peers can reach the node at, once the daemon has been running for 30
+seconds. The NAT status is one of:
+
+  none      the node is reachable directly
+  static    the node is behind a NAT, reachable through UPnP or NAT-PMP port
+            mappings
+  dynamic   the node is behind a NAT and couldn't map ports, peers can only
+            reach it through hole punching or relays
+  failed    AutoNAT could not find out whether the node is reachable
 
 EXAMPLE:
 
@@ -120,6 +138,8 @@ EXAMPLE:
 				output = strings.Replace(output, "<pver>", out.ProtocolVersion, -1)
 				output = strings.Replace(output, "<pubkey>", out.PublicKey, -1)
 				output = strings.Replace(output, "<addrs>", strings.Join(out.Addresses, "\n"), -1)
+				output = strings.Replace(output, "<nat>", out.NATStatus, -1)
+				output = strings.Replace(output, "<extaddrs>", strings.Join(out.ExternalAddresses, "\n"), -1)
 				output = strings.Replace(output, "\\n", "\n", -1)
 				output = strings.Replace(output, "\\t", "\t", -1)
 				fmt.Fprint(w, output)
@@ -191,5 +211,12 @@ func printSelf(node *core.IpfsNode) (interface{}, error) {
 	}
 	info.ProtocolVersion = identify.LibP2PVersion
 	info.AgentVersion = identify.ClientVersion
+
+	if status, external, ok := node.NATStatus(); ok {
+		info.NATStatus = status
+		for _, a := range external {
+			info.ExternalAddresses = append(info.ExternalAddresses, a.String())
+		}
+	}
 	return info, nil
 }
@@ -0,0 +1,248 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipldcbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	ipldgit "github.com/ipfs/go-ipld-git"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// InspectOutput is the summary of a block printed by 'ipfs inspect'.
+type InspectOutput struct {
+	Cid       string
+	Version   uint64
+	Codec     string
+	Multihash string
+	Size      int
+	Links     []InspectLink
+	UnixFS    *InspectUnixFS  `json:",omitempty"`
+	Node      json.RawMessage `json:",omitempty"`
+	Hex       string          `json:",omitempty"`
+}
+
+// InspectLink is a link of an inspected block.
+type InspectLink struct {
+	Name string
+	Cid  string
+	Size uint64
+}
+
+// InspectUnixFS is the UnixFS metadata of an inspected dag-pb block.
+type InspectUnixFS struct {
+	Type       string
+	FileSize   uint64
+	BlockSizes []uint64
+	MimeType   string          `json:",omitempty"`
+	Metadata   json.RawMessage `json:",omitempty"`
+}
+
+var InspectCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print a human-readable summary of a block.",
+		ShortDescription: `
+'ipfs inspect' reads a block and prints its CID, codec, multihash, size and
+links, decoded according to the codec of its CID:
+
+  * dag-pb: the links, and the UnixFS metadata of files and directories.
+  * dag-cbor, dag-json and git-raw: the node, as JSON.
+  * raw, git blobs and the other codecs: a hex dump of the block.
+
+Its output is meant for humans and may change between versions.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The CID or path of the block to inspect.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		p, err := coreiface.ParsePath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+		r, err := api.Block().Get(req.Context, rp)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		c := rp.Cid()
+		prefix := c.Prefix()
+		out := &InspectOutput{
+			Cid:       c.String(),
+			Version:   prefix.Version,
+			Codec:     codecName(prefix.Codec),
+			Multihash: mh.Codes[prefix.MhType],
+			Size:      len(data),
+			Links:     []InspectLink{},
+		}
+
+		var nd ipld.Node
+		switch prefix.Codec {
+		case cid.DagProtobuf:
+			pbnd, err := dag.DecodeProtobuf(data)
+			if err != nil {
+				return err
+			}
+			nd = pbnd
+			if out.UnixFS, err = inspectUnixFS(req, api, pbnd); err != nil {
+				return err
+			}
+		case cid.DagCBOR:
+			cnd, err := ipldcbor.Decode(data, prefix.MhType, -1)
+			if err != nil {
+				return err
+			}
+			nd = cnd
+			if out.Node, err = cnd.MarshalJSON(); err != nil {
+				return err
+			}
+		case cid.GitRaw:
+			if nd, err = ipldgit.ParseObjectFromBuffer(data); err != nil {
+				return err
+			}
+			if _, ok := nd.(*ipldgit.Blob); ok {
+				out.Hex = hex.EncodeToString(data)
+				break
+			}
+			if out.Node, err = json.Marshal(nd); err != nil {
+				return err
+			}
+		case dagJSONCodec:
+			if !json.Valid(data) {
+				return fmt.Errorf("invalid dag-json block")
+			}
+			out.Node = data
+		default:
+			out.Hex = hex.EncodeToString(data)
+		}
+
+		if nd != nil {
+			for _, l := range nd.Links() {
+				out.Links = append(out.Links, InspectLink{Name: l.Name, Cid: l.Cid.String(), Size: l.Size})
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *InspectOutput) error {
+			fmt.Fprintf(w, "CID:        %s\n", out.Cid)
+			fmt.Fprintf(w, "Version:    %d\n", out.Version)
+			fmt.Fprintf(w, "Codec:      %s\n", out.Codec)
+			fmt.Fprintf(w, "Multihash:  %s\n", out.Multihash)
+			fmt.Fprintf(w, "Size:       %d bytes\n", out.Size)
+
+			if fs := out.UnixFS; fs != nil {
+				fmt.Fprintf(w, "UnixFS:     %s, %d bytes", strings.ToLower(fs.Type), fs.FileSize)
+				if len(fs.BlockSizes) > 0 {
+					fmt.Fprintf(w, " in %d blocks", len(fs.BlockSizes))
+				}
+				fmt.Fprintln(w)
+				if fs.MimeType != "" {
+					fmt.Fprintf(w, "MimeType:   %s\n", fs.MimeType)
+				}
+				if len(fs.Metadata) > 0 {
+					fmt.Fprintf(w, "Metadata:   %s\n", fs.Metadata)
+				}
+			}
+
+			fmt.Fprintf(w, "Links:      %d\n", len(out.Links))
+			for _, l := range out.Links {
+				fmt.Fprintf(w, "  %s %d %s\n", l.Cid, l.Size, l.Name)
+			}
+
+			if len(out.Node) > 0 {
+				var buf bytes.Buffer
+				if err := json.Indent(&buf, out.Node, "  ", "  "); err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "Node:\n  %s\n", buf.Bytes())
+			}
+			if out.Hex != "" {
+				data, err := hex.DecodeString(out.Hex)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "Data:\n%s", hex.Dump(data))
+			}
+			return nil
+		}),
+	},
+	Type: InspectOutput{},
+}
+
+// dagJSONCodec is the multicodec of dag-json, which go-cid doesn't define.
+const dagJSONCodec = 0x0129
+
+// codecNames are the multicodec names of the codecs for which go-cid uses
+// older names.
+var codecNames = map[uint64]string{
+	cid.DagProtobuf: "dag-pb",
+	cid.DagCBOR:     "dag-cbor",
+	dagJSONCodec:    "dag-json",
+}
+
+// codecName returns the name of the multicodec c, or its hex code if it is
+// unknown.
+func codecName(c uint64) string {
+	if name, ok := codecNames[c]; ok {
+		return name
+	}
+	if name, ok := cid.CodecToStr[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", c)
+}
+
+// inspectUnixFS returns the UnixFS metadata of nd, or nil if nd is not a
+// UnixFS node.
+func inspectUnixFS(req *cmds.Request, api coreiface.CoreAPI, nd *dag.ProtoNode) (*InspectUnixFS, error) {
+	fsn, err := ft.FSNodeFromBytes(nd.Data())
+	if err != nil {
+		return nil, nil
+	}
+	out := &InspectUnixFS{
+		Type:       fsn.Type().String(),
+		FileSize:   fsn.FileSize(),
+		BlockSizes: fsn.BlockSizes(),
+	}
+	if md, err := ft.MetadataFromBytes(nd.Data()); err == nil {
+		out.MimeType = md.MimeType
+		custom, err := coreunix.CustomMetadata(req.Context, api.Dag(), nd)
+		switch err {
+		case nil:
+			out.Metadata = custom
+		case coreunix.ErrNoCustomMetadata:
+		default:
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+
+	ds "github.com/ipfs/go-datastore"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ci "github.com/libp2p/go-libp2p-crypto"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+const (
+	keyDeriveParentOptionName = "parent"
+	keyDerivePathOptionName   = "path"
+	keyDeriveListOptionName   = "list"
+)
+
+// keyDerivationsKey is the datastore key of the parents and paths of the
+// derived keys, which the keystore doesn't record.
+var keyDerivationsKey = ds.NewKey("/local/keystore/derivations")
+
+// KeyDerivation is how a key was derived by 'ipfs key derive'.
+type KeyDerivation struct {
+	Parent string
+	Path   string
+}
+
+// DerivedKey is a key derived by 'ipfs key derive'.
+type DerivedKey struct {
+	Name   string
+	Id     string
+	Parent string
+	Path   string
+}
+
+// DerivedKeyList is the output of 'ipfs key derive': the derived key, or
+// with --list, all of them.
+type DerivedKeyList struct {
+	Keys []DerivedKey
+}
+
+var keyDeriveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Derive a keypair from another one",
+		ShortDescription: `
+'ipfs key derive' derives the ed25519 key <name> from the ed25519 key
+--parent, 'self' by default, and the BIP32 derivation path --path, following
+SLIP-0010, and stores it in the keystore:
+
+  > ipfs key gen --type=ed25519 master
+  > ipfs key derive --parent=master --path="m/44'/501'/0'" site0
+
+The seed of the parent key is the SLIP-0010 seed. The same parent and path
+always derive the same key, so the derived keys can be recreated from the
+parent alone. Only hardened derivations are defined for ed25519 keys: each
+index of the path ends with ' or h.
+
+'ipfs key derive --list' shows the tree of the derived keys of the keystore,
+with the paths they were derived at.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", false, false, "name of the key to create"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(keyDeriveParentOptionName, "name of the key to derive from").WithDefault("self"),
+		cmdkit.StringOption(keyDerivePathOptionName, "derivation path, such as m/44'/501'/0'"),
+		cmdkit.BoolOption(keyDeriveListOptionName, "list the tree of the derived keys"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		dstore := n.Repo.Datastore()
+		derivations, err := loadKeyDerivations(dstore)
+		if err != nil {
+			return err
+		}
+
+		if list, _ := req.Options[keyDeriveListOptionName].(bool); list {
+			out, err := listDerivedKeys(n, derivations)
+			if err != nil {
+				return err
+			}
+			return cmds.EmitOnce(res, out)
+		}
+
+		if len(req.Arguments) == 0 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the name of the key to create is required")
+		}
+		name := req.Arguments[0]
+		if name == "self" {
+			return fmt.Errorf("cannot create key with name 'self'")
+		}
+		path, _ := req.Options[keyDerivePathOptionName].(string)
+		if path == "" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the --%s option is required", keyDerivePathOptionName)
+		}
+		parentName, _ := req.Options[keyDeriveParentOptionName].(string)
+		parent, err := keystoreKey(n, parentName)
+		if err != nil {
+			return err
+		}
+
+		sk, err := keystore.DeriveEd25519(parent, path)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "%s", err)
+		}
+		if err := n.Repo.Keystore().Put(name, sk); err != nil {
+			return err
+		}
+		derivations[name] = KeyDerivation{Parent: parentName, Path: path}
+		if err := storeKeyDerivations(dstore, derivations); err != nil {
+			return err
+		}
+
+		id, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &DerivedKeyList{Keys: []DerivedKey{
+			{Name: name, Id: id.Pretty(), Parent: parentName, Path: path},
+		}})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DerivedKeyList) error {
+			if list, _ := req.Options[keyDeriveListOptionName].(bool); list {
+				writeDerivationTree(w, out.Keys)
+				return nil
+			}
+			for _, k := range out.Keys {
+				if _, err := fmt.Fprintln(w, k.Id); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+	Type: DerivedKeyList{},
+}
+
+// keystoreKey returns the private key name, 'self' for the key of the node.
+func keystoreKey(n *core.IpfsNode, name string) (ci.PrivKey, error) {
+	if name == "self" {
+		if n.PrivateKey == nil {
+			return nil, fmt.Errorf("the key of the node is not loaded")
+		}
+		return n.PrivateKey, nil
+	}
+	return n.Repo.Keystore().Get(name)
+}
+
+// listDerivedKeys returns the derived keys still in the keystore, sorted by
+// name.
+func listDerivedKeys(n *core.IpfsNode, derivations map[string]KeyDerivation) (*DerivedKeyList, error) {
+	out := &DerivedKeyList{Keys: []DerivedKey{}}
+	for name, d := range derivations {
+		sk, err := n.Repo.Keystore().Get(name)
+		if err == keystore.ErrNoSuchKey {
+			// removed since
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		id, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			return nil, err
+		}
+		out.Keys = append(out.Keys, DerivedKey{Name: name, Id: id.Pretty(), Parent: d.Parent, Path: d.Path})
+	}
+	sort.Slice(out.Keys, func(i, j int) bool { return out.Keys[i].Name < out.Keys[j].Name })
+	return out, nil
+}
+
+// writeDerivationTree writes the keys under their parent, indented by level.
+// The parents which weren't derived are the roots of the tree.
+func writeDerivationTree(w io.Writer, keys []DerivedKey) {
+	children := make(map[string][]DerivedKey)
+	derived := make(map[string]bool)
+	for _, k := range keys {
+		children[k.Parent] = append(children[k.Parent], k)
+		derived[k.Name] = true
+	}
+
+	var write func(parent string, depth int)
+	write = func(parent string, depth int) {
+		for _, k := range children[parent] {
+			fmt.Fprintf(w, "%s%s %s %s\n", strings.Repeat("  ", depth), k.Name, k.Path, k.Id)
+			write(k.Name, depth+1)
+		}
+	}
+
+	roots := make([]string, 0, len(children))
+	for parent := range children {
+		if !derived[parent] {
+			roots = append(roots, parent)
+		}
+	}
+	sort.Strings(roots)
+	for _, root := range roots {
+		fmt.Fprintln(w, root)
+		write(root, 1)
+	}
+}
+
+func loadKeyDerivations(dstore ds.Datastore) (map[string]KeyDerivation, error) {
+	derivations := make(map[string]KeyDerivation)
+	data, err := dstore.Get(keyDerivationsKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return derivations, nil
+	default:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &derivations); err != nil {
+		return nil, fmt.Errorf("invalid key derivations: %s", err)
+	}
+	return derivations, nil
+}
+
+func storeKeyDerivations(dstore ds.Datastore, derivations map[string]KeyDerivation) error {
+	data, err := json.Marshal(derivations)
+	if err != nil {
+		return err
+	}
+	return dstore.Put(keyDerivationsKey, data)
+}
@@ -1,15 +1,24 @@
 package commands
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"text/tabwriter"
 
+	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	options "github.com/ipfs/interface-go-ipfs-core/options"
+	ci "github.com/libp2p/go-libp2p-crypto"
+	pb "github.com/libp2p/go-libp2p-crypto/pb"
+	peer "github.com/libp2p/go-libp2p-peer"
+	routing "github.com/libp2p/go-libp2p-routing"
+	mbase "github.com/multiformats/go-multibase"
 )
 
 var KeyCmd = &cmds.Command{
@@ -27,13 +36,29 @@ publish'.
   > ipfs key list
   self
   mykey
+
+'ipfs key sign' signs data with a key, and 'ipfs key verify' checks the
+signature with the ID of the key.
+
+  > ipfs key sign mykey statement.txt
+  m7QG...
+  > ipfs key verify QmMyKeyId statement.txt m7QG...
+
+'ipfs key derive' derives an ed25519 key from another one at a SLIP-0010
+path.
+
+  > ipfs key gen --type=ed25519 edkey
+  > ipfs key derive --parent=edkey --path="m/0'/1'" subkey
 		`,
 	},
 	Subcommands: map[string]*cmds.Command{
+		"derive": keyDeriveCmd,
 		"gen":    keyGenCmd,
 		"list":   keyListCmd,
 		"rename": keyRenameCmd,
 		"rm":     keyRmCmd,
+		"sign":   keySignCmd,
+		"verify": keyVerifyCmd,
 	},
 }
 
@@ -231,6 +256,237 @@ var keyRmCmd = &cmds.Command{
 	Type: KeyOutputList{},
 }
 
+// KeySignOutput is the signature made by 'ipfs key sign'.
+type KeySignOutput struct {
+	Key       KeyOutput
+	Signature string
+}
+
+// KeyVerifyOutput is the result of 'ipfs key verify'.
+type KeyVerifyOutput struct {
+	Key            string
+	SignatureValid bool
+}
+
+// The multicodec codes of the public key types, prefixing the signatures.
+const (
+	ed25519PubCodec   = 0xed
+	secp256k1PubCodec = 0xe7
+	rsaPubCodec       = 0x1205
+)
+
+// keySignPrefix prefixes the data signed by 'ipfs key sign', so that its
+// signatures can't be taken for the signatures of other messages of the key,
+// like the IPNS records.
+const keySignPrefix = "libp2p-key signed message:"
+
+// keySignedData returns the message signed for data.
+func keySignedData(data []byte) []byte {
+	return append([]byte(keySignPrefix), data...)
+}
+
+var keySignCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sign data with a keypair",
+		ShortDescription: `
+'ipfs key sign' signs <data> with the private key <name>, 'self' for the key
+of the node, and prints the signature. The signature is the multicodec code
+of the type of the key, as a varint, followed by the raw signature, encoded
+in base64 multibase. 'ipfs key verify' checks it.
+
+The message signed is <data> prefixed with "libp2p-key signed message:", so
+that the signatures can't be used as the signatures of other messages of the
+key, like the IPNS records. To check a signature with another tool, verify it
+against the prefixed data.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "name of the key to sign with"),
+		cmdkit.FileArg("data", true, false, "data to sign").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		name := req.Arguments[0]
+		var sk ci.PrivKey
+		if name == "self" {
+			sk = n.PrivateKey
+		} else {
+			if sk, err = n.Repo.Keystore().Get(name); err != nil {
+				return err
+			}
+		}
+		if sk == nil {
+			return fmt.Errorf("no private key named %q", name)
+		}
+		codec, err := keyTypeCodec(sk.Type())
+		if err != nil {
+			return err
+		}
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		sig, err := sk.Sign(keySignedData(data))
+		if err != nil {
+			return err
+		}
+
+		id, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			return err
+		}
+		buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(sig))
+		buf = append(buf[:binary.PutUvarint(buf, codec)], sig...)
+		encoded, err := mbase.Encode(mbase.Base64, buf)
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &KeySignOutput{
+			Key:       KeyOutput{Name: name, Id: id.Pretty()},
+			Signature: encoded,
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *KeySignOutput) error {
+			_, err := fmt.Fprintln(w, out.Signature)
+			return err
+		}),
+	},
+	Type: KeySignOutput{},
+}
+
+var keyVerifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Verify a signature made with 'ipfs key sign'",
+		ShortDescription: `
+'ipfs key verify' checks that <signature> is a signature of <data> made with
+the key of the peer ID <key-id> by 'ipfs key sign', and fails if it isn't.
+Give '-' as <data> to read it from the standard input. The message checked is
+<data> prefixed with "libp2p-key signed message:", like 'ipfs key sign' signs.
+
+The public key is found in the peer ID itself for the ed25519 keys, and for
+the RSA keys in the keys of the node, in the keys of the known peers, or in
+the routing system when the node is online.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("key-id", true, false, "peer ID of the key which made the signature"),
+		cmdkit.FileArg("data", true, false, "data which was signed"),
+		cmdkit.StringArg("signature", true, false, "signature printed by 'ipfs key sign'"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		id, err := peer.IDB58Decode(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid peer id %q: %s", req.Arguments[0], err)
+		}
+		_, buf, err := mbase.Decode(req.Arguments[1])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid signature: %s", err)
+		}
+		codec, l := binary.Uvarint(buf)
+		if l <= 0 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid signature: no key type")
+		}
+		sig := buf[l:]
+
+		pk, err := lookupPublicKey(req.Context, n, id)
+		if err != nil {
+			return err
+		}
+		expected, err := keyTypeCodec(pk.Type())
+		if err != nil {
+			return err
+		}
+		if codec != expected {
+			return fmt.Errorf("the signature was not made with a key of the type of %s", id.Pretty())
+		}
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		valid, err := pk.Verify(keySignedData(data), sig)
+		if err != nil || !valid {
+			return fmt.Errorf("the signature is not valid for %s", id.Pretty())
+		}
+
+		return cmds.EmitOnce(res, &KeyVerifyOutput{Key: id.Pretty(), SignatureValid: true})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *KeyVerifyOutput) error {
+			_, err := fmt.Fprintf(w, "signature valid for %s\n", out.Key)
+			return err
+		}),
+	},
+	Type: KeyVerifyOutput{},
+}
+
+// keyTypeCodec returns the multicodec code of the public keys of type t.
+func keyTypeCodec(t pb.KeyType) (uint64, error) {
+	switch t {
+	case pb.KeyType_Ed25519:
+		return ed25519PubCodec, nil
+	case pb.KeyType_Secp256k1:
+		return secp256k1PubCodec, nil
+	case pb.KeyType_RSA:
+		return rsaPubCodec, nil
+	default:
+		return 0, fmt.Errorf("signing with %s keys is not supported", t)
+	}
+}
+
+// lookupPublicKey returns the public key of id, extracted from id if it is
+// inlined, or found locally or in the routing system.
+func lookupPublicKey(ctx context.Context, n *core.IpfsNode, id peer.ID) (ci.PubKey, error) {
+	if pk, err := id.ExtractPublicKey(); err == nil {
+		return pk, nil
+	} else if err != peer.ErrNoPublicKey {
+		return nil, err
+	}
+
+	if pk := n.Peerstore.PubKey(id); pk != nil {
+		return pk, nil
+	}
+
+	names, err := n.Repo.Keystore().List()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		sk, err := n.Repo.Keystore().Get(name)
+		if err != nil {
+			return nil, err
+		}
+		if id.MatchesPrivateKey(sk) {
+			return sk.GetPublic(), nil
+		}
+	}
+
+	if n.Routing == nil || !n.IsOnline {
+		return nil, fmt.Errorf("public key of %s not found, it may be found online", id.Pretty())
+	}
+	return routing.GetPublicKey(n.Routing, ctx, id)
+}
+
 func keyOutputListEncoders() cmds.EncoderFunc {
 	return cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, list *KeyOutputList) error {
 		withID, _ := req.Options["l"].(bool)
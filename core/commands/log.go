@@ -3,11 +3,17 @@ package commands
 import (
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
 
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	logging "github.com/ipfs/go-log"
 	lwriter "github.com/ipfs/go-log/writer"
+	gologging "github.com/whyrusleeping/go-logging"
 )
 
 // Golang os.Args overrides * and replaces the character argument with
@@ -31,9 +37,11 @@ system (not just for the daemon logs, but all commands):
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"level": logLevelCmd,
-		"ls":    logLsCmd,
-		"tail":  logTailCmd,
+		"level":       logLevelCmd,
+		"level-regex": logLevelRegexCmd,
+		"ls":          logLsCmd,
+		"output":      logOutputCmd,
+		"tail":        logTailCmd,
 	},
 }
 
@@ -80,6 +88,62 @@ the event log.
 	Type: MessageOutput{},
 }
 
+var logLevelRegexCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Change the logging level of all subsystems matching a pattern.",
+		ShortDescription: `
+Change the verbosity of every subsystem whose name matches the given regular
+expression and list the subsystems that were changed. The pattern uses Go
+regexp syntax and is not anchored: use '^dht$' to match 'dht' only.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("pattern", true, false, "Regular expression matched against subsystem names."),
+		cmdkit.StringArg("level", true, false, `The log level, with 'debug' the most verbose and 'critical' the least verbose.
+			One of: debug, info, warning, error, critical.
+		`),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		pattern, level := req.Arguments[0], req.Arguments[1]
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid pattern: %s", err)
+		}
+		if _, err := gologging.LogLevel(level); err != nil {
+			return err
+		}
+
+		subsystems := logging.GetSubsystems()
+		sort.Strings(subsystems)
+
+		changed := []string{}
+		for _, s := range subsystems {
+			if !re.MatchString(s) {
+				continue
+			}
+			if err := logging.SetLogLevel(s, level); err != nil {
+				return err
+			}
+			changed = append(changed, s)
+		}
+
+		log.Infof("Changed log level of %d subsystems matching '%s' to '%s'", len(changed), pattern, level)
+
+		return cmds.EmitOnce(res, &stringList{changed})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, list *stringList) error {
+			for _, s := range list.Strings {
+				fmt.Fprintln(w, s)
+			}
+			return nil
+		}),
+	},
+	Type: stringList{},
+}
+
 var logLsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List the logging subsystems.",
@@ -121,3 +185,242 @@ Outputs event log messages (not other log messages) as they are generated.
 		return res.Emit(r)
 	},
 }
+
+const (
+	logRotateSizeOptionName  = "rotate-size"
+	logRotateCountOptionName = "rotate-count"
+)
+
+// logStderrKeyword restores logging to the standard error of the daemon.
+var logStderrKeyword = "stderr"
+
+var logOutputCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Redirect the log output to a file.",
+		ShortDescription: `
+Instruct the daemon to write its log output to the given file instead of
+standard error. The file is created if needed and opened in append mode.
+Pass 'stderr' to go back to writing to standard error.
+`,
+		LongDescription: `
+Instruct the daemon to write its log output to the given file instead of
+standard error. The file is created if needed and opened in append mode.
+Pass 'stderr' to go back to writing to standard error.
+
+With '--rotate-size', the file is rotated once writing to it would make it
+larger than the given number of bytes: 'file' is renamed to 'file.1',
+'file.1' to 'file.2' and so on, keeping at most '--rotate-count' old files.
+
+This only affects the log output, not the event log read by 'ipfs log tail'.
+Log levels set with 'ipfs log level' are preserved.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("file", true, false, fmt.Sprintf("The file to write the log to. Use '%s' for standard error.", logStderrKeyword)),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Int64Option(logRotateSizeOptionName, "Rotate the file once it would grow over this many bytes. 0 disables rotation.").WithDefault(int64(0)),
+		cmdkit.IntOption(logRotateCountOptionName, "Number of rotated files to keep.").WithDefault(5),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		// the daemon may run in a different working directory, resolve
+		// relative paths on the client side
+		if req.Arguments[0] == logStderrKeyword {
+			return nil
+		}
+		file, err := filepath.Abs(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		req.Arguments[0] = file
+		return nil
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		file := req.Arguments[0]
+		rotateSize, _ := req.Options[logRotateSizeOptionName].(int64)
+		rotateCount, _ := req.Options[logRotateCountOptionName].(int)
+		if rotateSize < 0 {
+			return fmt.Errorf("%s must not be negative", logRotateSizeOptionName)
+		}
+		if rotateCount < 0 {
+			return fmt.Errorf("%s must not be negative", logRotateCountOptionName)
+		}
+
+		if file == logStderrKeyword {
+			if err := setLogOutput(nil); err != nil {
+				return err
+			}
+			return cmds.EmitOnce(res, &MessageOutput{"Logging to stderr\n"})
+		}
+
+		rf, err := openRotatingFile(file, rotateSize, rotateCount)
+		if err != nil {
+			return err
+		}
+		if err := setLogOutput(rf); err != nil {
+			rf.Close()
+			return err
+		}
+
+		return cmds.EmitOnce(res, &MessageOutput{fmt.Sprintf("Logging to '%s'\n", file)})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *MessageOutput) error {
+			fmt.Fprint(w, out.Message)
+			return nil
+		}),
+	},
+	Type: MessageOutput{},
+}
+
+// logOutput is the file the log is currently redirected to, if any.
+var logOutput struct {
+	sync.Mutex
+	f io.Closer
+}
+
+// logOutputPath returns the path of the file the log is redirected to, or ""
+// if it goes to stderr.
+func logOutputPath() string {
+	logOutput.Lock()
+	defer logOutput.Unlock()
+
+	if rf, ok := logOutput.f.(*rotatingFile); ok {
+		return rf.path
+	}
+	return ""
+}
+
+// setLogOutput replaces the backend of the logging system with one writing
+// to w, or to stderr if w is nil. The previous output file is closed.
+func setLogOutput(w io.WriteCloser) error {
+	logOutput.Lock()
+	defer logOutput.Unlock()
+
+	// replacing the backend resets the levels, carry them over
+	subsystems := logging.GetSubsystems()
+	levels := make(map[string]gologging.Level, len(subsystems))
+	for _, s := range subsystems {
+		levels[s] = gologging.GetLevel(s)
+	}
+	defLevel := gologging.GetLevel("")
+
+	if w == nil {
+		gologging.SetBackend(gologging.NewLogBackend(os.Stderr, "", 0))
+	} else {
+		// colors make no sense in a file
+		fmtr, err := gologging.NewStringFormatter(logging.LogFormats["nocolor"])
+		if err != nil {
+			return err
+		}
+		gologging.SetBackend(gologging.NewBackendFormatter(gologging.NewLogBackend(w, "", 0), fmtr))
+	}
+
+	gologging.SetLevel(defLevel, "")
+	for s, l := range levels {
+		gologging.SetLevel(l, s)
+	}
+
+	var err error
+	if logOutput.f != nil {
+		err = logOutput.f.Close()
+	}
+	logOutput.f = w
+	return err
+}
+
+// rotatingFile is an append-only file that is rotated once it would grow over
+// maxSize bytes, keeping up to keep old copies named path.1 to path.<keep>.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	size    int64
+	maxSize int64
+	keep    int
+}
+
+func openRotatingFile(path string, maxSize int64, keep int) (*rotatingFile, error) {
+	rf := &rotatingFile{
+		path:    path,
+		maxSize: maxSize,
+		keep:    keep,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f = f
+	rf.size = fi.Size()
+	return nil
+}
+
+func (rf *rotatingFile) rotate() error {
+	err := rf.f.Close()
+	rf.f = nil
+	if err != nil {
+		return err
+	}
+
+	if rf.keep == 0 {
+		if err := os.Remove(rf.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		for i := rf.keep - 1; i > 0; i-- {
+			err := os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(rf.path, rf.path+".1"); err != nil {
+			return err
+		}
+	}
+
+	return rf.open()
+}
+
+func (rf *rotatingFile) Write(b []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(b)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.f.Write(b)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
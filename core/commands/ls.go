@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -8,9 +9,12 @@ import (
 	"text/tabwriter"
 
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 
+	cid "github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
 	unixfs "github.com/ipfs/go-unixfs"
 	unixfs_pb "github.com/ipfs/go-unixfs/pb"
 	iface "github.com/ipfs/interface-go-ipfs-core"
@@ -23,6 +27,13 @@ type LsLink struct {
 	Size       uint64
 	Type       unixfs_pb.Data_DataType
 	Target     string
+
+	// Mtime and Mode are not recorded by this version of UnixFS, and are
+	// always null. Metadata is the custom metadata of the file, set with
+	// 'ipfs add --metadata'. They are only filled with --show-metadata.
+	Mtime    *int64
+	Mode     *uint32
+	Metadata json.RawMessage
 }
 
 // LsObject is an element of LsOutput
@@ -39,10 +50,11 @@ type LsOutput struct {
 }
 
 const (
-	lsHeadersOptionNameTime = "headers"
-	lsResolveTypeOptionName = "resolve-type"
-	lsSizeOptionName        = "size"
-	lsStreamOptionName      = "stream"
+	lsHeadersOptionNameTime  = "headers"
+	lsResolveTypeOptionName  = "resolve-type"
+	lsSizeOptionName         = "size"
+	lsStreamOptionName       = "stream"
+	lsShowMetadataOptionName = "show-metadata"
 )
 
 var LsCmd = &cmds.Command{
@@ -55,6 +67,10 @@ the following format:
   <link base58 hash> <link size in bytes> <link name>
 
 The JSON output contains type information.
+
+With --show-metadata, the Mtime, Mode and Metadata columns are printed before
+the link name, blank for the links without metadata. Metadata is the custom
+metadata attached with 'ipfs add --metadata'.
 `,
 	},
 
@@ -66,6 +82,7 @@ The JSON output contains type information.
 		cmdkit.BoolOption(lsResolveTypeOptionName, "Resolve linked objects to find out their types.").WithDefault(true),
 		cmdkit.BoolOption(lsSizeOptionName, "Resolve linked objects to find out their file size.").WithDefault(true),
 		cmdkit.BoolOption(lsStreamOptionName, "s", "Enable exprimental streaming of directory entries as they are traversed."),
+		cmdkit.BoolOption(lsShowMetadataOptionName, "Print the metadata of the linked objects."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -76,6 +93,7 @@ The JSON output contains type information.
 		resolveType, _ := req.Options[lsResolveTypeOptionName].(bool)
 		resolveSize, _ := req.Options[lsSizeOptionName].(bool)
 		stream, _ := req.Options[lsStreamOptionName].(bool)
+		showMetadata, _ := req.Options[lsShowMetadataOptionName].(bool)
 
 		err = req.ParseBodyArgs()
 		if err != nil {
@@ -164,6 +182,12 @@ The JSON output contains type information.
 					Type:   ftype,
 					Target: link.Target,
 				}
+				if showMetadata {
+					lsLink.Metadata, err = lsMetadata(req, api.Dag(), link)
+					if err != nil {
+						return err
+					}
+				}
 				if err := processLink(paths[i], lsLink); err != nil {
 					return err
 				}
@@ -203,10 +227,32 @@ The JSON output contains type information.
 	Type: LsOutput{},
 }
 
+// lsMetadata returns the custom metadata of the object link points to, if
+// any.
+func lsMetadata(req *cmds.Request, ng ipld.NodeGetter, link iface.DirEntry) (json.RawMessage, error) {
+	// only metadata nodes are protobuf nodes
+	if link.Cid.Type() != cid.DagProtobuf {
+		return nil, nil
+	}
+	nd, err := ng.Get(req.Context, link.Cid)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := coreunix.CustomMetadata(req.Context, ng, nd)
+	if err == coreunix.ErrNoCustomMetadata {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(meta), nil
+}
+
 func tabularOutput(req *cmds.Request, w io.Writer, out *LsOutput, lastObjectHash string, ignoreBreaks bool) string {
 	headers, _ := req.Options[lsHeadersOptionNameTime].(bool)
 	stream, _ := req.Options[lsStreamOptionName].(bool)
 	size, _ := req.Options[lsSizeOptionName].(bool)
+	showMetadata, _ := req.Options[lsShowMetadataOptionName].(bool)
 	// in streaming mode we can't automatically align the tabs
 	// so we take a best guess
 	var minTabWidth int
@@ -230,11 +276,14 @@ func tabularOutput(req *cmds.Request, w io.Writer, out *LsOutput, lastObjectHash
 				fmt.Fprintf(tw, "%s:\n", object.Hash)
 			}
 			if headers {
-				s := "Hash\tName"
+				s := "Hash\t"
 				if size {
-					s = "Hash\tSize\tName"
+					s += "Size\t"
 				}
-				fmt.Fprintln(tw, s)
+				if showMetadata {
+					s += "Mtime\tMode\tMetadata\t"
+				}
+				fmt.Fprintln(tw, s+"Name")
 			}
 			lastObjectHash = object.Hash
 		}
@@ -256,9 +305,27 @@ func tabularOutput(req *cmds.Request, w io.Writer, out *LsOutput, lastObjectHash
 				}
 			}
 
-			fmt.Fprintf(tw, s, link.Hash, link.Size, link.Name)
+			name := link.Name
+			if showMetadata {
+				// the metadata columns go before the name
+				name = metadataColumns(link) + name
+			}
+			fmt.Fprintf(tw, s, link.Hash, link.Size, name)
 		}
 	}
 	tw.Flush()
 	return lastObjectHash
 }
+
+// metadataColumns returns the Mtime, Mode and Metadata columns of link, blank
+// when not set.
+func metadataColumns(link LsLink) string {
+	var mtime, mode string
+	if link.Mtime != nil {
+		mtime = fmt.Sprint(*link.Mtime)
+	}
+	if link.Mode != nil {
+		mode = fmt.Sprintf("%04o", *link.Mode)
+	}
+	return mtime + "\t" + mode + "\t" + string(link.Metadata) + "\t"
+}
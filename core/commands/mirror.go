@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	transfer "github.com/ipfs/go-ipfs/transfer"
+
+	humanize "github.com/dustin/go-humanize"
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+const (
+	mirrorRecursiveOptionName = "recursive"
+	mirrorPinOptionName       = "pin"
+	mirrorMaxSizeOptionName   = "max-size"
+)
+
+// MirrorOutput is a DAG mirrored by 'ipfs mirror'.
+type MirrorOutput struct {
+	Peer   string
+	Cid    string
+	Blocks int
+	Size   uint64
+	Pinned bool
+}
+
+var MirrorCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Fetch a DAG directly from a peer which has it.",
+		ShortDescription: `
+'ipfs mirror' fetches the DAG <cid> from the peer <peer-id> over a dedicated
+libp2p protocol: the peer streams all the blocks it has, instead of waiting
+for the node to ask for each level of the DAG as with bitswap. This saves
+round-trips when the peer is known to have the content. The blocks are
+checked against their CIDs, and the command fails if the peer doesn't have
+the whole DAG, keeping the blocks received.
+
+With --recursive=false, only the block <cid> is fetched. --pin pins the DAG
+once mirrored, recursively unless --recursive=false. The peers are served
+the blocks their access lists allow, see 'ipfs access-control'.
+
+Like for 'ipfs transfer receive', the blocks not linked from <cid> are
+dropped, and the mirror fails once the peer sent more than --max-size of
+blocks, like '10GiB'. Default: 1GiB.
+
+The daemon must be running.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("peer-id", true, false, "The peer to mirror from."),
+		cmdkit.StringArg("cid", true, false, "The root of the DAG to mirror."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(mirrorRecursiveOptionName, "r", "Mirror the whole DAG under the root.").WithDefault(true),
+		cmdkit.BoolOption(mirrorPinOptionName, "Pin the DAG once mirrored."),
+		cmdkit.StringOption(mirrorMaxSizeOptionName, "The largest size of the blocks of the mirror."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		addr, pid, err := ParsePeerParam(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "failed to parse peer address '%s': %s", req.Arguments[0], err)
+		}
+		if pid == n.Identity {
+			return cmdkit.Errorf(cmdkit.ErrClient, "cannot mirror from self")
+		}
+		root, err := cid.Decode(req.Arguments[1])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid cid %q: %s", req.Arguments[1], err)
+		}
+		recursive, _ := req.Options[mirrorRecursiveOptionName].(bool)
+		doPin, _ := req.Options[mirrorPinOptionName].(bool)
+		var maxSize uint64
+		if s, _ := req.Options[mirrorMaxSizeOptionName].(string); s != "" {
+			maxSize, err = humanize.ParseBytes(s)
+			if err != nil || maxSize == 0 {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid --%s %q", mirrorMaxSizeOptionName, s)
+			}
+		}
+
+		if err := addPeerAddrs(req.Context, n, addr, pid); err != nil {
+			return err
+		}
+		r, err := transfer.Mirror(req.Context, n.PeerHost, n.Blockstore, pid, root, recursive, maxSize)
+		if err != nil {
+			return err
+		}
+
+		out := &MirrorOutput{
+			Peer:   r.Peer.Pretty(),
+			Blocks: r.Blocks,
+			Size:   r.Size,
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+		out.Cid = enc.Encode(r.Root)
+		if doPin {
+			if err := pinMirror(req.Context, n, root, recursive); err != nil {
+				return err
+			}
+			out.Pinned = true
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *MirrorOutput) error {
+			_, err := fmt.Fprintf(w, "mirrored %s from %s: %d blocks, %s\n", out.Cid, out.Peer, out.Blocks, humanize.Bytes(out.Size))
+			if err == nil && out.Pinned {
+				_, err = fmt.Fprintf(w, "pinned %s\n", out.Cid)
+			}
+			return err
+		}),
+	},
+	Type: MirrorOutput{},
+}
+
+// pinMirror pins the DAG root once mirrored. The blocks can be collected
+// until the pin lock is taken, the DAG is then incomplete: it is only read
+// from the local blocks, not to fetch the missing ones with the lock held.
+func pinMirror(ctx context.Context, n *core.IpfsNode, root cid.Cid, recursive bool) error {
+	defer n.Blockstore.PinLock().Unlock()
+
+	ds := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+	nd, err := ds.Get(ctx, root)
+	if err != nil {
+		return fmt.Errorf("incomplete mirror: %s", err)
+	}
+	if recursive {
+		if err := dag.EnumerateChildren(ctx, dag.GetLinksWithDAG(ds), root, cid.NewSet().Visit); err != nil {
+			return fmt.Errorf("incomplete mirror: %s", err)
+		}
+	}
+	if err := n.Pinning.Pin(ctx, nd, recursive); err != nil {
+		return err
+	}
+	return n.Pinning.Flush()
+}
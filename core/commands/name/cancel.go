@@ -0,0 +1,100 @@
+package name
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+)
+
+var CancelCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Retract the value published under an IPNS name.",
+		ShortDescription: `
+Publish a tombstone record for the given key. The tombstone points to the
+empty unixfs directory and has a higher sequence number than the current
+record, so resolvers that see it will prefer it over the retracted value.
+`,
+		LongDescription: `
+Publish a tombstone record for the given key. The tombstone points to the
+empty unixfs directory and has a higher sequence number than the current
+record, so resolvers that see it will prefer it over the retracted value.
+
+A retraction is best effort: DHT nodes and resolvers that cached the previous
+record may keep serving it until its own lifetime or TTL runs out. For the
+same reason the tombstone cannot have a zero lifetime; an expired record is
+rejected by the IPNS validator and would never reach the network. Give it a
+lifetime at least as long as the lifetime of the record being retracted, so
+the old value cannot resurface once the tombstone expires.
+
+Example:
+
+  > ipfs name cancel mykey
+  Published to QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd: /ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("key", true, false, "Name of the key to retract or a valid PeerID, as listed by 'ipfs key list -l'."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(lifeTimeOptionName, "t",
+			`Time duration that the tombstone will be valid for. <<default>>
+    This accepts durations such as "300s", "1.5h" or "2h45m".`).WithDefault("24h"),
+		cmdkit.BoolOption(allowOfflineOptionName, "When offline, save the tombstone to the local datastore without broadcasting to the network instead of simply failing."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		kname := req.Arguments[0]
+		allowOffline, _ := req.Options[allowOfflineOptionName].(bool)
+
+		validTimeOpt, _ := req.Options[lifeTimeOptionName].(string)
+		validTime, err := time.ParseDuration(validTimeOpt)
+		if err != nil {
+			return fmt.Errorf("error parsing lifetime option: %s", err)
+		}
+		if validTime <= 0 {
+			return fmt.Errorf("lifetime must be positive, an expired tombstone would be rejected by the network")
+		}
+
+		// the empty directory is the sentinel value; make sure we can serve it
+		empty, err := api.Object().New(req.Context, options.Object.Type("unixfs-dir"))
+		if err != nil {
+			return err
+		}
+
+		out, err := api.Name().Publish(req.Context, iface.IpfsPath(empty.Cid()),
+			options.Name.AllowOffline(allowOffline),
+			options.Name.Key(kname),
+			options.Name.ValidTime(validTime),
+		)
+		if err != nil {
+			if err == iface.ErrOffline {
+				err = errAllowOffline
+			}
+			return err
+		}
+
+		return cmds.EmitOnce(res, &IpnsEntry{
+			Name:  out.Name(),
+			Value: out.Value().String(),
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, ie *IpnsEntry) error {
+			_, err := fmt.Fprintf(w, "Published to %s: %s\n", ie.Name, ie.Value)
+			return err
+		}),
+	},
+	Type: IpnsEntry{},
+}
@@ -0,0 +1,117 @@
+package name
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+	nsopts "github.com/ipfs/interface-go-ipfs-core/options/namesys"
+)
+
+type NameListEntry struct {
+	Name  string
+	Id    string
+	Value string `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+// resolveTimeoutOptionName is the timeout of the resolution of a key. It
+// can't be --timeout, the global timeout of the command: the commands can't
+// define an option of the same name as a global one. The same goes for
+// --local, the global --local and --offline already restricting the
+// resolution to the local records.
+const resolveTimeoutOptionName = "resolve-timeout"
+
+var ListCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List all IPNS keys and their published values.",
+		ShortDescription: `
+List every key in the keystore together with the value currently published
+under it. Keys with no resolvable record are listed with an empty value.
+`,
+		LongDescription: `
+List every key in the keystore together with the value currently published
+under it. Keys with no resolvable record are listed with an empty value.
+
+Each name is resolved one level deep, like 'ipfs name resolve' without
+'--recursive'. '--resolve-timeout' bounds the time spent resolving each key,
+while the global '--timeout' bounds the whole listing. Pass the global
+'--offline', or the deprecated '--local', to only consult the records stored
+in the local datastore, without querying the network.
+
+Example:
+
+  > ipfs name list
+  self	QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n	/ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  mykey	QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(resolveTimeoutOptionName, "Max time to spend resolving each name, eg \"30s\".").WithDefault("1m"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		timeoutStr, _ := req.Options[resolveTimeoutOptionName].(string)
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("error parsing resolve-timeout option: %s", err)
+		}
+		if timeout <= 0 {
+			return errors.New("resolve timeout value must be > 0")
+		}
+
+		keys, err := api.Key().List(req.Context)
+		if err != nil {
+			return err
+		}
+
+		opts := []options.NameResolveOption{
+			options.Name.ResolveOption(nsopts.Depth(1)),
+		}
+
+		for _, k := range keys {
+			entry := &NameListEntry{
+				Name: k.Name(),
+				Id:   k.ID().Pretty(),
+			}
+
+			ctx, cancel := context.WithTimeout(req.Context, timeout)
+			p, err := api.Name().Resolve(ctx, "/ipns/"+entry.Id, opts...)
+			cancel()
+			if err != nil {
+				// ipns records expire, an unresolvable name is a normal state
+				// and must not abort the listing.
+				if req.Context.Err() != nil {
+					return req.Context.Err()
+				}
+				entry.Error = err.Error()
+			} else {
+				entry.Value = p.String()
+			}
+
+			if err := res.Emit(entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, e *NameListEntry) error {
+			_, err := fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, e.Id, e.Value)
+			return err
+		}),
+	},
+	Type: NameListEntry{},
+}
@@ -63,5 +63,7 @@ Resolve the value of a dnslink:
 		"publish": PublishCmd,
 		"resolve": IpnsCmd,
 		"pubsub":  IpnsPubsubCmd,
+		"list":    ListCmd,
+		"cancel":  CancelCmd,
 	},
 }
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	netsim "github.com/ipfs/go-ipfs/netsim"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const (
+	networkLatencyOptionName   = "latency"
+	networkBandwidthOptionName = "bandwidth"
+)
+
+var errNetSimNotBuilt = errors.New("ipfs was built without the network simulation, rebuild it with 'go build -tags netsim'")
+
+// NetworkSimulateOutput is the simulation started for the command.
+type NetworkSimulateOutput struct {
+	Latency time.Duration
+	// Bandwidth is in bytes per second, 0 if it is not limited.
+	Bandwidth float64
+}
+
+var NetworkCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Test the node on simulated networks.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"simulate": networkSimulateCmd,
+	},
+}
+
+var networkSimulateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run an ipfs command on a simulated constrained network.",
+		ShortDescription: `
+'ipfs network simulate' adds --latency to the data the daemon sends and limits
+its bandwidth to --bandwidth while the ipfs command given after '--' runs:
+
+  ipfs network simulate --latency 50ms --bandwidth 10Mbps -- get <cid>
+
+The bandwidth is in bits per second, like '512kbps' or '10Mbps', and is
+shared by all the connections, in each direction. The simulation applies to
+all the traffic of the daemon, not only the command's, and stops when the
+command exits. Only one simulation runs at a time. The global options given
+to 'ipfs network simulate', like --api or --config, are passed to the command.
+
+The simulation is meant for local testing: it needs a daemon built with the
+netsim tag, 'go build -tags netsim ./cmd/ipfs'. The connections of such a
+daemon go through the simulation even when none runs, which makes them
+slower. QUIC connections are not shaped.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("command", true, true, "The ipfs command to run, and its arguments."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(networkLatencyOptionName, "The latency added to the data sent, like '50ms'.").WithDefault("0s"),
+		cmdkit.StringOption(networkBandwidthOptionName, "The bandwidth in bits per second, like '10Mbps'. Default: unlimited."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.NetSim == nil {
+			return errNetSimNotBuilt
+		}
+		if !n.IsDaemon {
+			return ErrNotOnline
+		}
+
+		out := &NetworkSimulateOutput{}
+		s, _ := req.Options[networkLatencyOptionName].(string)
+		out.Latency, err = time.ParseDuration(s)
+		if err != nil || out.Latency < 0 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid latency %q", s)
+		}
+		if s, ok := req.Options[networkBandwidthOptionName].(string); ok {
+			out.Bandwidth, err = netsim.ParseBandwidth(s)
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "%s", err)
+			}
+		}
+
+		if err := n.NetSim.Start(out.Latency, out.Bandwidth); err != nil {
+			return err
+		}
+		defer n.NetSim.Stop()
+		if err := res.Emit(out); err != nil {
+			return err
+		}
+		// the client runs the command, and closes the request when it exits
+		<-req.Context.Done()
+		return nil
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+			if out, ok := v.(*NetworkSimulateOutput); !ok {
+				return e.TypeErr(out, v)
+			}
+
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			req := res.Request()
+			cmd := exec.Command(exe, append(globalOptionArgs(req), req.Arguments...)...)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("the command failed: %s", err)
+			}
+			return nil
+		},
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *NetworkSimulateOutput) error {
+			_, err := fmt.Fprintf(w, "latency: %s, bandwidth: %.0f bytes/s\n", out.Latency, out.Bandwidth)
+			return err
+		}),
+	},
+	Type: NetworkSimulateOutput{},
+}
+
+// globalOptionArgs returns the global options of req set to other values than
+// their defaults, like --api or --config, as arguments for another ipfs
+// command.
+func globalOptionArgs(req *cmds.Request) []string {
+	var args []string
+	for _, opt := range req.Root.Options {
+		name := opt.Name()
+		if name == cmds.OptLongHelp || name == cmds.OptShortHelp {
+			continue
+		}
+		v, ok := req.Options[name]
+		if !ok || v == opt.Default() {
+			continue
+		}
+		args = append(args, fmt.Sprintf("--%s=%v", name, v))
+	}
+	return args
+}
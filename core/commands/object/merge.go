@@ -0,0 +1,91 @@
+package objectcmd
+
+import (
+	"fmt"
+	"io"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/dagutils"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+)
+
+const (
+	recursiveOptionName = "recursive"
+)
+
+var ObjectMergeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Merge the links of two dag-pb objects.",
+		ShortDescription: `
+'ipfs object merge' creates an object with the links of <obj_a> and <obj_b>,
+and prints its hash. On a name present in both, the link of <obj_b> is kept.
+The data of the new object is the one of <obj_a>.
+`,
+		LongDescription: `
+'ipfs object merge' creates an object with the links of <obj_a> and <obj_b>,
+and prints its hash. On a name present in both, the link of <obj_b> is kept.
+The data of the new object is the one of <obj_a>.
+
+With --recursive, a name linking to a unixfs directory in both objects links
+to the merge of the two directories instead:
+
+   > ipfs object merge -r $DIR_A $DIR_B
+
+Sharded directories can't be merged.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("obj_a", true, false, "The object to merge into."),
+		cmdkit.StringArg("obj_b", true, false, "The object whose links are merged, overriding the ones of obj_a."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(recursiveOptionName, "r", "Merge the directories linked under the same name in both objects."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		enc, err := cmdenv.GetLowLevelCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		pa, err := coreiface.ParsePath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		pb, err := coreiface.ParsePath(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+
+		a, err := api.ResolveNode(req.Context, pa)
+		if err != nil {
+			return err
+		}
+		b, err := api.ResolveNode(req.Context, pb)
+		if err != nil {
+			return err
+		}
+
+		recursive, _ := req.Options[recursiveOptionName].(bool)
+		nd, err := dagutils.MergeNodes(req.Context, api.Dag(), a, b, recursive)
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &Object{Hash: enc.Encode(nd.Cid())})
+	},
+	Type: Object{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *Object) error {
+			fmt.Fprintln(w, out.Hash)
+			return nil
+		}),
+	},
+}
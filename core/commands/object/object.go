@@ -1,11 +1,14 @@
 package objectcmd
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/ipfs/go-ipfs/core/commands/cmdenv"
@@ -13,10 +16,12 @@ import (
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
+	ipldcbor "github.com/ipfs/go-ipld-cbor"
 	ipld "github.com/ipfs/go-ipld-format"
 	dag "github.com/ipfs/go-merkledag"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/ipfs/interface-go-ipfs-core/options"
+	mh "github.com/multiformats/go-multihash"
 )
 
 type Node struct {
@@ -43,6 +48,8 @@ const (
 	datafieldencOptionName = "datafieldenc"
 	pinOptionName          = "pin"
 	quietOptionName        = "quiet"
+	typeOptionName         = "type"
+	dataOptionName         = "data"
 )
 
 var ObjectCmd = &cmds.Command{
@@ -58,6 +65,7 @@ directly.`,
 		"diff":  ObjectDiffCmd,
 		"get":   ObjectGetCmd,
 		"links": ObjectLinksCmd,
+		"merge": ObjectMergeCmd,
 		"new":   ObjectNewCmd,
 		"patch": ObjectPatchCmd,
 		"put":   ObjectPutCmd,
@@ -113,6 +121,9 @@ var ObjectLinksCmd = &cmds.Command{
 'ipfs object links' is a plumbing command for retrieving the links from
 a DAG node. It outputs to stdout, and <key> is a base58 encoded
 multihash.
+
+The links of a DAG-CBOR node are its CID values, named after their path in
+the node, like "key" or "key/0".
 `,
 	},
 
@@ -143,7 +154,15 @@ multihash.
 			return err
 		}
 
-		links, err := api.Object().Links(req.Context, rp)
+		var links []*ipld.Link
+		switch rp.Cid().Type() {
+		case cid.DagProtobuf, cid.Raw:
+			links, err = api.Object().Links(req.Context, rp)
+		case cid.DagCBOR:
+			links, err = cborLinks(req.Context, api.Dag(), rp.Cid())
+		default:
+			return fmt.Errorf("'ipfs object links' does not support %s nodes, use 'ipfs dag get' to inspect them", cid.CodecToStr[rp.Cid().Type()])
+		}
 		if err != nil {
 			return err
 		}
@@ -482,11 +501,22 @@ node.
 
 Available templates:
 	* unixfs-dir
+
+With --type dag-cbor, it creates a DAG-CBOR node instead, holding an empty
+map, or the JSON given with --data:
+
+  > ipfs object new --type dag-cbor --data '{"key":"value"}'
+
+Like with 'ipfs dag put', {"/": "<cid>"} values in --data are links.
 `,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("template", false, false, "Template to use. Optional."),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(typeOptionName, "Type of the node to create: dag-pb or dag-cbor.").WithDefault("dag-pb"),
+		cmdkit.StringOption(dataOptionName, "JSON data of the dag-cbor node to create."),
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
@@ -498,6 +528,32 @@ Available templates:
 			return err
 		}
 
+		ntype, _ := req.Options[typeOptionName].(string)
+		data, hasData := req.Options[dataOptionName].(string)
+		switch ntype {
+		case "dag-pb":
+			if hasData {
+				return fmt.Errorf("--%s requires --%s dag-cbor", dataOptionName, typeOptionName)
+			}
+		case "dag-cbor":
+			if len(req.Arguments) == 1 {
+				return errors.New("templates are not supported with dag-cbor")
+			}
+			if !hasData {
+				data = "{}"
+			}
+			nd, err := ipldcbor.FromJSON(strings.NewReader(data), mh.SHA2_256, -1)
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid --%s: %s", dataOptionName, err)
+			}
+			if err := api.Dag().Add(req.Context, nd); err != nil {
+				return err
+			}
+			return cmds.EmitOnce(res, &Object{Hash: enc.Encode(nd.Cid())})
+		default:
+			return fmt.Errorf("unsupported node type %q, use dag-pb or dag-cbor", ntype)
+		}
+
 		template := "empty"
 		if len(req.Arguments) == 1 {
 			template = req.Arguments[0]
@@ -519,6 +575,33 @@ Available templates:
 	Type: Object{},
 }
 
+// cborLinks returns the links of the DAG-CBOR node c, named after the path
+// of the CID values in the node, in path order.
+func cborLinks(ctx context.Context, ng ipld.NodeGetter, c cid.Cid) ([]*ipld.Link, error) {
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	cnd, ok := nd.(*ipldcbor.Node)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a DAG-CBOR node", c)
+	}
+
+	paths := cnd.Tree("", -1)
+	sort.Strings(paths)
+	var links []*ipld.Link
+	for _, p := range paths {
+		v, rest, err := cnd.Resolve(strings.Split(p, "/"))
+		if err != nil {
+			return nil, err
+		}
+		if l, ok := v.(*ipld.Link); ok && len(rest) == 0 {
+			links = append(links, &ipld.Link{Name: p, Cid: l.Cid})
+		}
+	}
+	return links, nil
+}
+
 // converts the Node object into a real dag.ProtoNode
 func deserializeNode(nd *Node, dataFieldEncoding string) (*dag.ProtoNode, error) {
 	dagnode := new(dag.ProtoNode)
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+var OfflineModeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pause and resume all network activity of the daemon.",
+		ShortDescription: `
+'ipfs offline-mode enable' closes all the connections of the daemon, relayed
+ones included, and blocks new ones, incoming or outgoing, until 'ipfs
+offline-mode disable'. The DHT and Bitswap are paused: the lookups and the
+fetches of content that isn't local fail at once, and nothing is provided.
+The API, the gateway and the mounts keep working with the local content, and
+the caches are kept.
+
+The offline mode is not persisted, it ends when the daemon stops.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"enable":  offlineModeEnableCmd,
+		"disable": offlineModeDisableCmd,
+	},
+}
+
+var offlineModeEnableCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Close all connections and block new ones.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := offlineModeNode(env)
+		if err != nil {
+			return err
+		}
+		return n.SetOfflineMode(true)
+	},
+}
+
+var offlineModeDisableCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Allow connections again, and reconnect to the bootstrap peers.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := offlineModeNode(env)
+		if err != nil {
+			return err
+		}
+		return n.SetOfflineMode(false)
+	},
+}
+
+func offlineModeNode(env cmds.Environment) (*core.IpfsNode, error) {
+	n, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+	if !n.IsOnline {
+		return nil, ErrNotOnline
+	}
+	return n, nil
+}
@@ -118,7 +118,7 @@ Example:
 			return err
 		}
 
-		targets, err := parseIpfsAddr(targetOpt)
+		targets, err := parseIpfsAddr(req.Context, targetOpt)
 		if err != nil {
 			return err
 		}
@@ -134,7 +134,7 @@ Example:
 }
 
 // parseIpfsAddr is a function that takes in addr string and return ipfsAddrs
-func parseIpfsAddr(addr string) ([]ipfsaddr.IPFSAddr, error) {
+func parseIpfsAddr(ctx context.Context, addr string) ([]ipfsaddr.IPFSAddr, error) {
 	mutiladdr, err := ma.NewMultiaddr(addr)
 	if err != nil {
 		return nil, err
@@ -148,7 +148,7 @@ func parseIpfsAddr(addr string) ([]ipfsaddr.IPFSAddr, error) {
 		return iaddrs, nil
 	}
 	// resolve mutiladdr whose protocol is not ma.P_IPFS
-	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
 	addrs, err := madns.Resolve(ctx, mutiladdr)
 	cancel()
 	if len(addrs) == 0 {
@@ -0,0 +1,418 @@
+package commands
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strings"
+
+	car "github.com/ipfs/go-ipfs/car"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+)
+
+const (
+	packStrategyOptionName = "strategy"
+)
+
+// ipfsIgnoreFile is the file listing the paths of a directory 'ipfs pack'
+// leaves out, in the .gitignore syntax.
+const ipfsIgnoreFile = ".ipfsignore"
+
+// PackOutput is the archive written by 'ipfs pack'.
+type PackOutput struct {
+	Cid  string
+	Path string
+}
+
+var PackCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add a directory and write it to an archive.",
+		ShortDescription: `
+'ipfs pack' adds the local directory <dir> to IPFS and pins it, then writes
+it to an archive, by default './<dir>.<strategy>'. The --strategy is one of:
+
+  car  A CARv2 file of all the blocks of the directory, added with CIDv1,
+       with an index of the blocks. 'ipfs dag import' reads it back.
+  tar  A tar archive of the content of the directory.
+  zip  A zip archive of the content of the directory.
+
+The paths matching the patterns of the .ipfsignore file at the root of <dir>
+are left out, as in a .gitignore file: a pattern with a slash matches the
+paths from the root of <dir>, one without it matches the names at any depth,
+a trailing slash only matches the directories, and a leading '!' includes a
+path an earlier pattern left out. The hidden files are left out unless
+--hidden is given.
+
+The node streams the archive, which is written on the machine 'ipfs pack'
+runs on; over the HTTP API, the response is the archive. The root CID of
+the directory is the root of the CAR file, the IPFS.root record of the PAX
+global header of the tar archive, and the comment of the zip archive.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("dir", true, false, "The local directory to pack."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(packStrategyOptionName, "The archive format: car, tar or zip.").WithDefault("car"),
+		cmdkit.StringOption(outputOptionName, "o", "The path of the archive."),
+		cmdkit.BoolOption(hiddenOptionName, "H", "Include the hidden files."),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		strategy, _ := req.Options[packStrategyOptionName].(string)
+		switch strategy {
+		case "car", "tar", "zip":
+		default:
+			return cmdkit.Errorf(cmdkit.ErrClient, "unknown strategy %q, expected car, tar or zip", strategy)
+		}
+
+		dir := filepath.Clean(req.Arguments[0])
+		stat, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !stat.IsDir() {
+			return cmdkit.Errorf(cmdkit.ErrClient, "%s is not a directory", dir)
+		}
+
+		// the directory is read here and sent the node, which streams the
+		// archive back
+		out, _ := req.Options[outputOptionName].(string)
+		if out == "" {
+			abs, err := filepath.Abs(dir)
+			if err != nil {
+				return err
+			}
+			out = filepath.Base(abs) + "." + strategy
+		}
+		if out, err = filepath.Abs(out); err != nil {
+			return err
+		}
+		req.Options[outputOptionName] = out
+
+		rules, err := readIgnoreRules(filepath.Join(dir, ipfsIgnoreFile))
+		if err != nil {
+			return err
+		}
+		// the hidden files are listed to read .ipfsignore, and filtered
+		// with the rules
+		nd, err := files.NewSerialFile(dir, true, stat)
+		if err != nil {
+			return err
+		}
+		hidden, _ := req.Options[hiddenOptionName].(bool)
+		req.Files = files.NewSliceDirectory([]files.DirEntry{
+			files.FileEntry(filepath.Base(dir), &ignoreDir{
+				Directory: nd.(files.Directory),
+				rules:     rules,
+				hidden:    hidden,
+			}),
+		})
+		return nil
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		if req.Files == nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "no directory to pack")
+		}
+		name := filepath.Base(filepath.Clean(req.Arguments[0]))
+
+		strategy, _ := req.Options[packStrategyOptionName].(string)
+		opts := []options.UnixfsAddOption{options.Unixfs.Pin(true)}
+		if strategy == "car" {
+			opts = append(opts, options.Unixfs.CidVersion(1))
+		}
+		root, err := api.Unixfs().Add(req.Context, req.Files, opts...)
+		if err != nil {
+			return err
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			var err error
+			switch strategy {
+			case "car":
+				err = car.Export(req.Context, api.Dag(), root.Cid(), 2, pw)
+			default:
+				var nd files.Node
+				nd, err = api.Unixfs().Get(req.Context, root)
+				if err != nil {
+					break
+				}
+				if strategy == "tar" {
+					err = writePackTar(pw, name, root.Cid(), nd)
+				} else {
+					err = writePackZip(pw, name, root.Cid(), nd)
+				}
+			}
+			pw.CloseWithError(err)
+		}()
+		return res.Emit(pr)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			req := res.Request()
+
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+			r, ok := v.(io.Reader)
+			if !ok {
+				return e.New(e.TypeErr(r, v))
+			}
+
+			out, _ := req.Options[outputOptionName].(string)
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, r)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(out)
+				return err
+			}
+
+			strategy, _ := req.Options[packStrategyOptionName].(string)
+			root, err := packRoot(out, strategy)
+			if err != nil {
+				return err
+			}
+			enc, err := cmdenv.GetCidEncoder(req)
+			if err != nil {
+				return err
+			}
+			return re.Emit(&PackOutput{Cid: enc.Encode(root), Path: out})
+		},
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PackOutput) error {
+			_, err := fmt.Fprintf(w, "packed %s to %s\n", out.Cid, out.Path)
+			return err
+		}),
+	},
+}
+
+// packRootRecord is the PAX record of the global header of the tar archives
+// holding the root CID.
+const packRootRecord = "IPFS.root"
+
+// packRoot returns the root CID of the archive fn: the root of the CAR file,
+// the packRootRecord of the tar archive or the comment of the zip archive.
+func packRoot(fn, strategy string) (cid.Cid, error) {
+	switch strategy {
+	case "zip":
+		zr, err := zip.OpenReader(fn)
+		if err != nil {
+			return cid.Cid{}, err
+		}
+		defer zr.Close()
+		return cid.Decode(zr.Comment)
+	}
+
+	f, err := os.Open(fn)
+	if err != nil {
+		return cid.Cid{}, err
+	}
+	defer f.Close()
+	if strategy == "car" {
+		cr, err := car.NewReader(f)
+		if err != nil {
+			return cid.Cid{}, err
+		}
+		if len(cr.Header.Roots) != 1 {
+			return cid.Cid{}, fmt.Errorf("expected one root in %s, got %d", fn, len(cr.Header.Roots))
+		}
+		return cr.Header.Roots[0], nil
+	}
+	hdr, err := tar.NewReader(f).Next()
+	if err != nil {
+		return cid.Cid{}, err
+	}
+	if hdr.Typeflag != tar.TypeXGlobalHeader {
+		return cid.Cid{}, fmt.Errorf("no %s record in %s", packRootRecord, fn)
+	}
+	return cid.Decode(hdr.PAXRecords[packRootRecord])
+}
+
+func writePackTar(w io.Writer, name string, root cid.Cid, nd files.Node) error {
+	tw, err := files.NewTarWriter(w)
+	if err != nil {
+		return err
+	}
+	err = tw.TarW.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: map[string]string{packRootRecord: root.String()},
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteFile(nd, name); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func writePackZip(w io.Writer, name string, root cid.Cid, nd files.Node) error {
+	zw := zip.NewWriter(w)
+	if err := zw.SetComment(root.String()); err != nil {
+		return err
+	}
+	var walk func(p string, nd files.Node) error
+	walk = func(p string, nd files.Node) error {
+		switch nd := nd.(type) {
+		case *files.Symlink:
+			hdr := &zip.FileHeader{Name: p, Method: zip.Store}
+			hdr.SetMode(os.ModeSymlink | 0777)
+			fw, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(fw, nd.Target)
+			return err
+		case files.File:
+			fw, err := zw.Create(p)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(fw, nd)
+			return err
+		case files.Directory:
+			if _, err := zw.Create(p + "/"); err != nil {
+				return err
+			}
+			it := nd.Entries()
+			for it.Next() {
+				if err := walk(gopath.Join(p, it.Name()), it.Node()); err != nil {
+					return err
+				}
+			}
+			return it.Err()
+		default:
+			return fmt.Errorf("%s: unsupported file type", p)
+		}
+	}
+	if err := walk(name, nd); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ignoreRule is a pattern of a .ipfsignore file.
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// readIgnoreRules reads the rules of the .ipfsignore file fn, none if it
+// doesn't exist.
+func readIgnoreRules(fn string) ([]ignoreRule, error) {
+	data, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []ignoreRule
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate, line = true, line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		r.anchored = strings.Contains(line, "/")
+		r.pattern = strings.TrimPrefix(line, "/")
+		if _, err := gopath.Match(r.pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", fn, i+1, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// ignored returns whether the path rel, relative to the root of the packed
+// directory, is left out by rules. The last matching rule wins.
+func ignored(rules []ignoreRule, rel string, dir bool) bool {
+	ign := false
+	for _, r := range rules {
+		if r.dirOnly && !dir {
+			continue
+		}
+		name := rel
+		if !r.anchored {
+			name = gopath.Base(rel)
+		}
+		if ok, _ := gopath.Match(r.pattern, name); ok {
+			ign = !r.negate
+		}
+	}
+	return ign
+}
+
+// ignoreDir is a directory without the entries left out by the rules of a
+// .ipfsignore file.
+type ignoreDir struct {
+	files.Directory
+	rules  []ignoreRule
+	hidden bool
+	rel    string
+}
+
+func (d *ignoreDir) Entries() files.DirIterator {
+	return &ignoreIterator{DirIterator: d.Directory.Entries(), dir: d}
+}
+
+type ignoreIterator struct {
+	files.DirIterator
+	dir  *ignoreDir
+	node files.Node
+}
+
+func (it *ignoreIterator) Next() bool {
+	for it.DirIterator.Next() {
+		rel := gopath.Join(it.dir.rel, it.Name())
+		nd := it.DirIterator.Node()
+		sub, isDir := nd.(files.Directory)
+		if (!it.dir.hidden && strings.HasPrefix(it.Name(), ".")) || ignored(it.dir.rules, rel, isDir) {
+			nd.Close()
+			continue
+		}
+		if isDir {
+			nd = &ignoreDir{Directory: sub, rules: it.dir.rules, hidden: it.dir.hidden, rel: rel}
+		}
+		it.node = nd
+		return true
+	}
+	return false
+}
+
+func (it *ignoreIterator) Node() files.Node {
+	return it.node
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	version "github.com/ipfs/go-ipfs"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	coreperf "github.com/ipfs/go-ipfs/core/coreperf"
+
+	humanize "github.com/dustin/go-humanize"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const (
+	perfSuiteOptionName = "suite"
+	perfSizeOptionName  = "size"
+)
+
+// PerfBenchmarkOutput is the result of a benchmark suite.
+type PerfBenchmarkOutput struct {
+	Suite   string
+	Version string
+	Commit  string
+	// System is the hardware information of 'ipfs diag sys'.
+	System     map[string]interface{}
+	Size       uint64
+	Time       time.Time
+	Operations []coreperf.Operation
+}
+
+var PerfCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Measure the performance of the node.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"benchmark": perfBenchmarkCmd,
+	},
+}
+
+var perfBenchmarkCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run a standard benchmark suite.",
+		ShortDescription: `
+'ipfs perf benchmark' runs the workload --suite on synthetic random data, and
+prints its results as JSON: the version of ipfs, the hardware information of
+'ipfs diag sys' and the duration and throughput of each operation, in bytes
+per second. The suites are:
+
+` + perfSuitesHelp() + `
+--size changes the size of the synthetic data. The blocks added to the repo
+are removed at the end, and the garbage collection waits for the benchmark
+to finish. The network suites need a running daemon.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(perfSuiteOptionName, "The suite to run."),
+		cmdkit.StringOption(perfSizeOptionName, "The size of the synthetic data, like '100MiB'. Default: the size of the suite."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		name, _ := req.Options[perfSuiteOptionName].(string)
+		if name == "" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the --%s option is required", perfSuiteOptionName)
+		}
+		suite := coreperf.FindSuite(name)
+		if suite == nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "unknown suite %q", name)
+		}
+		if suite.Online && !n.IsOnline {
+			return ErrNotOnline
+		}
+		size := suite.DefaultSize
+		if s, ok := req.Options[perfSizeOptionName].(string); ok {
+			size, err = humanize.ParseBytes(s)
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid size %q: %s", s, err)
+			}
+		}
+
+		out := &PerfBenchmarkOutput{
+			Suite:   suite.Name,
+			Version: version.CurrentVersionNumber,
+			Commit:  version.CurrentCommit,
+			System:  make(map[string]interface{}),
+			Size:    size,
+			Time:    time.Now(),
+		}
+		for _, info := range []func(map[string]interface{}) error{runtimeInfo, memInfo, diskSpaceInfo} {
+			if err := info(out.System); err != nil {
+				return err
+			}
+		}
+		out.Operations, err = suite.Run(req.Context, n, size)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PerfBenchmarkOutput) error {
+			buf, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "%s\n", buf)
+			return err
+		}),
+	},
+	Type: PerfBenchmarkOutput{},
+}
+
+func perfSuitesHelp() string {
+	var b strings.Builder
+	for _, s := range coreperf.Suites {
+		fmt.Fprintf(&b, "  %-14s %s (%s)\n", s.Name, s.Description, humanize.IBytes(s.DefaultSize))
+	}
+	return b.String()
+}
@@ -35,6 +35,7 @@ var PinCmd = &cmds.Command{
 		"ls":     listPinCmd,
 		"verify": verifyPinCmd,
 		"update": updatePinCmd,
+		"remote": remotePinCmd,
 	},
 }
 
@@ -67,6 +68,10 @@ var addPinCmd = &cmds.Command{
 	},
 	Type: AddPinOutput{},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
 			return err
@@ -90,6 +95,7 @@ var addPinCmd = &cmds.Command{
 			if err != nil {
 				return err
 			}
+			updateSearchIndex(req, n)
 
 			return cmds.EmitOnce(res, &AddPinOutput{Pins: added})
 		}
@@ -117,6 +123,7 @@ var addPinCmd = &cmds.Command{
 				if val.err != nil {
 					return val.err
 				}
+				updateSearchIndex(req, n)
 
 				if pv := v.Value(); pv != 0 {
 					if err := res.Emit(&AddPinOutput{Progress: v.Value()}); err != nil {
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/core/commands/e"
 
 	iaddr "github.com/ipfs/go-ipfs-addr"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
@@ -28,7 +31,13 @@ type PingResult struct {
 }
 
 const (
-	pingCountOptionName = "count"
+	pingCountOptionName      = "count"
+	pingTimeoutOptionName    = "ping-timeout"
+	pingQuietOptionName      = "quiet"
+	pingIntervalOptionName   = "interval"
+	pingPersistentOptionName = "persistent"
+	pingOutputOptionName     = "output"
+	pingNoRotateOptionName   = "no-rotate"
 )
 
 // ErrPingSelf is returned when the user attempts to ping themself.
@@ -42,12 +51,48 @@ var PingCmd = &cmds.Command{
 via the routing system, sends pings, waits for pongs, and prints out round-
 trip latency information.
 		`,
+		LongDescription: `
+'ipfs ping' is a tool to test sending data to other nodes. It finds nodes
+via the routing system, sends pings, waits for pongs, and prints out round-
+trip latency information.
+
+It sends --count pings, one per second, or pings until it is interrupted
+with a count of -1. A ping without a pong within --ping-timeout is lost.
+Once done, it prints the number of pings lost and the minimum, average and
+maximum latencies. With --quiet, only this summary is printed.
+
+With --persistent, it pings every --interval until interrupted, and writes
+one 'timestamp,latency_ms,success' row per ping to the CSV file --output,
+for monitoring the latency of a peer over time:
+
+    > ipfs ping --persistent --output=latency.csv --interval=30s <peer ID>
+
+The file is renamed after the day of its rows at midnight, latency.csv
+becoming latency-2006-01-02.csv, unless --no-rotate is given.
+		`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("peer ID", true, true, "ID of peer to be pinged.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
-		cmdkit.IntOption(pingCountOptionName, "n", "Number of ping messages to send.").WithDefault(10),
+		cmdkit.IntOption(pingCountOptionName, "n", "Number of ping messages to send, -1 to ping until interrupted.").WithDefault(10),
+		cmdkit.StringOption(pingTimeoutOptionName, "Time to wait for each pong.").WithDefault("5s"),
+		cmdkit.BoolOption(pingQuietOptionName, "q", "Only print the summary."),
+		cmdkit.StringOption(pingIntervalOptionName, "Time between two pings.").WithDefault("1s"),
+		cmdkit.BoolOption(pingPersistentOptionName, "Ping until interrupted, writing the pings to --output."),
+		cmdkit.StringOption(pingOutputOptionName, "o", "The CSV file the pings are written to, with --persistent."),
+		cmdkit.BoolOption(pingNoRotateOptionName, "Do not rotate the --output file at midnight."),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		persistent, _ := req.Options[pingPersistentOptionName].(bool)
+		output, _ := req.Options[pingOutputOptionName].(string)
+		if persistent && output == "" {
+			return fmt.Errorf("error: --persistent needs an --output file")
+		}
+		if !persistent && output != "" {
+			return fmt.Errorf("error: --output can only be used with --persistent")
+		}
+		return nil
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := cmdenv.GetNode(env)
@@ -73,14 +118,41 @@ trip latency information.
 			n.Peerstore.AddAddr(pid, addr, pstore.TempAddrTTL) // temporary
 		}
 
+		persistent, _ := req.Options[pingPersistentOptionName].(bool)
 		numPings, _ := req.Options[pingCountOptionName].(int)
-		if numPings <= 0 {
-			return fmt.Errorf("error: ping count must be greater than 0, was %d", numPings)
+		if persistent {
+			numPings = -1
+		}
+		if numPings <= 0 && numPings != -1 {
+			return fmt.Errorf("error: ping count must be greater than 0 or -1, was %d", numPings)
+		}
+
+		intervalStr, _ := req.Options[pingIntervalOptionName].(string)
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("error: invalid ping interval '%s'", intervalStr)
+		}
+
+		timeoutStr, _ := req.Options[pingTimeoutOptionName].(string)
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("error: invalid ping timeout '%s'", timeoutStr)
+		}
+
+		quiet, _ := req.Options[pingQuietOptionName].(bool)
+		// emit writes the progress of the pings, which --quiet hides. The
+		// pings of --persistent are always emitted, for the CSV file.
+		quiet = quiet && !persistent
+		emit := func(r *PingResult) error {
+			if quiet {
+				return nil
+			}
+			return res.Emit(r)
 		}
 
 		if len(n.Peerstore.Addrs(pid)) == 0 {
 			// Make sure we can find the node in question
-			if err := res.Emit(&PingResult{
+			if err := emit(&PingResult{
 				Text:    fmt.Sprintf("Looking up peer %s", pid.Pretty()),
 				Success: true,
 			}); err != nil {
@@ -96,53 +168,152 @@ trip latency information.
 			n.Peerstore.AddAddrs(p.ID, p.Addrs, pstore.TempAddrTTL)
 		}
 
-		if err := res.Emit(&PingResult{
+		if err := emit(&PingResult{
 			Text:    fmt.Sprintf("PING %s.", pid.Pretty()),
 			Success: true,
 		}); err != nil {
 			return err
 		}
 
-		ctx, cancel := context.WithTimeout(req.Context, kPingTimeout*time.Duration(numPings))
-		defer cancel()
-		pings, err := ping.Ping(ctx, n.PeerHost, pid)
-		if err != nil {
+		// The pings are sent on a stream, which is opened again after a lost
+		// ping so that a late pong isn't taken for the next one.
+		var pings <-chan time.Duration
+		cancelPings := func() {}
+		defer func() { cancelPings() }()
+		startPings := func() error {
+			var ctx context.Context
+			ctx, cancelPings = context.WithCancel(req.Context)
+			pings, err = ping.Ping(ctx, n.PeerHost, pid)
+			return err
+		}
+		if err := startPings(); err != nil {
 			return res.Emit(&PingResult{
 				Success: false,
 				Text:    fmt.Sprintf("Ping error: %s", err),
 			})
 		}
 
-		var total time.Duration
-		ticker := time.NewTicker(time.Second)
+		var sent, received int
+		var total, min, max time.Duration
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
-		for i := 0; i < numPings; i++ {
-			t, ok := <-pings
-			if !ok {
-				break
+		for ; numPings < 0 || sent < numPings; sent++ {
+			if sent > 0 {
+				select {
+				case <-ticker.C:
+				case <-req.Context.Done():
+					return req.Context.Err()
+				}
 			}
 
-			if err := res.Emit(&PingResult{
-				Success: true,
-				Time:    t,
-			}); err != nil {
-				return err
+			if pings == nil {
+				if err := startPings(); err != nil {
+					log.Debugf("ping error: %s", err)
+					if err := emit(&PingResult{Success: false}); err != nil {
+						return err
+					}
+					continue
+				}
 			}
 
-			total += t
-
+			timer := time.NewTimer(timeout)
 			select {
-			case <-ticker.C:
-			case <-ctx.Done():
-				return ctx.Err()
+			case t, ok := <-pings:
+				timer.Stop()
+				if !ok {
+					// the stream failed
+					if err := emit(&PingResult{Success: false}); err != nil {
+						return err
+					}
+					break
+				}
+
+				if err := emit(&PingResult{
+					Success: true,
+					Time:    t,
+				}); err != nil {
+					return err
+				}
+
+				received++
+				total += t
+				if min == 0 || t < min {
+					min = t
+				}
+				if t > max {
+					max = t
+				}
+				continue
+			case <-timer.C:
+				if err := emit(&PingResult{
+					Success: false,
+					Time:    timeout,
+				}); err != nil {
+					return err
+				}
+			case <-req.Context.Done():
+				timer.Stop()
+				return req.Context.Err()
 			}
+
+			cancelPings()
+			pings = nil
+		}
+
+		summary := fmt.Sprintf("%d pings sent, %d received, %.0f%% packet loss",
+			sent, received, float64(sent-received)*100/float64(sent))
+		if received > 0 {
+			averagems := total.Seconds() * 1000 / float64(received)
+			summary += fmt.Sprintf("\nAverage latency: %.2fms (min %.2fms, max %.2fms)",
+				averagems, min.Seconds()*1000, max.Seconds()*1000)
 		}
-		averagems := total.Seconds() * 1000 / float64(numPings)
 		return res.Emit(&PingResult{
-			Success: true,
-			Text:    fmt.Sprintf("Average latency: %.2fms", averagems),
+			Success: received > 0,
+			Text:    summary,
 		})
 	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			req := res.Request()
+			output, _ := req.Options[pingOutputOptionName].(string)
+			if output == "" {
+				return cmds.Copy(re, res)
+			}
+
+			noRotate, _ := req.Options[pingNoRotateOptionName].(bool)
+			w, err := openPingCSV(output, !noRotate, time.Now())
+			if err != nil {
+				return err
+			}
+			defer w.Close()
+
+			for {
+				v, err := res.Next()
+				if err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+
+				r, ok := v.(*PingResult)
+				if !ok {
+					return e.TypeErr(r, v)
+				}
+				// the results without text are the pings, the others are
+				// printed
+				if r.Text != "" {
+					if err := re.Emit(r); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := w.Write(time.Now(), r); err != nil {
+					return err
+				}
+			}
+		},
+	},
 	Type: PingResult{},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PingResult) error {
@@ -150,6 +321,8 @@ trip latency information.
 				fmt.Fprintln(w, out.Text)
 			} else if out.Success {
 				fmt.Fprintf(w, "Pong received: time=%.2f ms\n", out.Time.Seconds()*1000)
+			} else if out.Time > 0 {
+				fmt.Fprintf(w, "Ping timeout after %s\n", out.Time)
 			} else {
 				fmt.Fprintf(w, "Pong failed\n")
 			}
@@ -158,6 +331,76 @@ trip latency information.
 	},
 }
 
+// pingCSV is the CSV file of the pings of 'ipfs ping --persistent'.
+type pingCSV struct {
+	path   string
+	rotate bool
+	f      *os.File
+	// day is the date of the rows of f
+	day string
+}
+
+const pingCSVDayFormat = "2006-01-02"
+
+// openPingCSV opens the CSV file at path, appending to it if it exists. If
+// rotate is true, the file is renamed after its day when a row of another day
+// is written.
+func openPingCSV(path string, rotate bool, now time.Time) (*pingCSV, error) {
+	c := &pingCSV{path: path, rotate: rotate}
+	if err := c.open(now); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *pingCSV) open(now time.Time) error {
+	f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	c.f, c.day = f, now.Format(pingCSVDayFormat)
+	if fi.Size() > 0 {
+		// the rows already there are of the day the file was last written
+		c.day = fi.ModTime().Format(pingCSVDayFormat)
+		return nil
+	}
+	_, err = fmt.Fprintln(f, "timestamp,latency_ms,success")
+	return err
+}
+
+// Write writes the row of the ping r, received at t.
+func (c *pingCSV) Write(t time.Time, r *PingResult) error {
+	if day := t.Format(pingCSVDayFormat); c.rotate && day != c.day {
+		if err := c.f.Close(); err != nil {
+			return err
+		}
+		ext := filepath.Ext(c.path)
+		if err := os.Rename(c.path, strings.TrimSuffix(c.path, ext)+"-"+c.day+ext); err != nil {
+			return err
+		}
+		if err := c.open(t); err != nil {
+			return err
+		}
+	}
+
+	latency := ""
+	if r.Success {
+		latency = fmt.Sprintf("%.3f", r.Time.Seconds()*1000)
+	}
+	_, err := fmt.Fprintf(c.f, "%s,%s,%t\n", t.Format(time.RFC3339), latency, r.Success)
+	return err
+}
+
+func (c *pingCSV) Close() error {
+	return c.f.Close()
+}
+
 func ParsePeerParam(text string) (ma.Multiaddr, peer.ID, error) {
 	// Multiaddr
 	if strings.HasPrefix(text, "/") {
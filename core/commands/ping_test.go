@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPingCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ping-csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "latency.csv")
+	day1 := time.Now()
+	day2 := day1.Add(24 * time.Hour)
+
+	w, err := openPingCSV(path, true, day1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(day1, &PingResult{Success: true, Time: 1500 * time.Microsecond}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(day1, &PingResult{Success: false, Time: 5 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(day2, &PingResult{Success: true, Time: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := ioutil.ReadFile(filepath.Join(dir, "latency-"+day1.Format(pingCSVDayFormat)+".csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "timestamp,latency_ms,success\n" +
+		day1.Format(time.RFC3339) + ",1.500,true\n" +
+		day1.Format(time.RFC3339) + ",,false\n"
+	if string(rotated) != expected {
+		t.Fatalf("unexpected rotated file:\n%s", rotated)
+	}
+
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "timestamp,latency_ms,success\n" + day2.Format(time.RFC3339) + ",1.000,true\n"
+	if string(current) != expected {
+		t.Fatalf("unexpected current file:\n%s", current)
+	}
+
+	// without rotation, the rows are appended to the existing file
+	w, err = openPingCSV(path, false, day2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(day2.Add(24*time.Hour), &PingResult{Success: true, Time: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	current, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected += day2.Add(24*time.Hour).Format(time.RFC3339) + ",1.000,true\n"
+	if string(current) != expected {
+		t.Fatalf("unexpected appended file:\n%s", current)
+	}
+}
@@ -0,0 +1,645 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	pin "github.com/ipfs/go-ipfs/pin"
+	remote "github.com/ipfs/go-ipfs/pin/remote"
+	repo "github.com/ipfs/go-ipfs/repo"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+)
+
+// RemoteServicesConfigKey is the config section of the remote pinning
+// services, a map of service names to RemotePinningService.
+const RemoteServicesConfigKey = "Pinning.RemoteServices"
+
+// RemotePinningService is a remote pinning service, as stored in the config.
+type RemotePinningService struct {
+	API struct {
+		Endpoint string
+		Key      string
+	}
+	// AutoSync, if set, makes the daemon sync the local pins to the service.
+	AutoSync *RemoteAutoSync `json:",omitempty"`
+}
+
+// RemoteAutoSync configures the automatic sync of the local pins to a
+// remote pinning service.
+type RemoteAutoSync struct {
+	// Interval is the time between two syncs, a Go duration.
+	Interval string
+}
+
+// RemotePinOutput is a remote pin, as listed by 'ipfs pin remote ls'.
+type RemotePinOutput struct {
+	RequestID string
+	Status    string
+	Cid       string
+	Name      string
+}
+
+// RemoteServiceOutput is a remote pinning service, as listed by 'ipfs pin
+// remote service ls'. The access token is left out.
+type RemoteServiceOutput struct {
+	Name     string
+	Endpoint string
+}
+
+// RemoteSyncOutput is the output of 'ipfs pin remote sync'.
+type RemoteSyncOutput struct {
+	Added    []string
+	Interval string `json:",omitempty"`
+	Running  bool   `json:",omitempty"`
+}
+
+// RemoteServiceList is the output of 'ipfs pin remote service ls'.
+type RemoteServiceList struct {
+	Services []RemoteServiceOutput
+}
+
+const (
+	pinServiceOptionName    = "service"
+	pinNameOptionName       = "name"
+	pinBackgroundOptionName = "background"
+	pinCidOptionName        = "cid"
+	pinStatusOptionName     = "status"
+	pinAutoOptionName       = "auto"
+	pinIntervalOptionName   = "interval"
+)
+
+// remotePinPollInterval is the interval at which 'ipfs pin remote add' asks
+// the service whether a pin completed.
+var remotePinPollInterval = time.Second
+
+var remotePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin objects to remote pinning services.",
+		ShortDescription: `
+'ipfs pin remote' asks third-party pinning services speaking the IPFS Pinning
+Service API to pin objects. The services are registered with
+'ipfs pin remote service add' first:
+
+  > ipfs pin remote service add mysrv https://pinning.example.com/psa <token>
+  > ipfs pin remote add --service=mysrv --name=photos QmPhotos...
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add":     addRemotePinCmd,
+		"ls":      listRemotePinCmd,
+		"sync":    syncRemotePinCmd,
+		"service": remotePinServiceCmd,
+	},
+}
+
+var addRemotePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin an object to a remote pinning service.",
+		ShortDescription: `
+'ipfs pin remote add' asks the service to pin the object, and waits until it
+is pinned. The service fetches the object from the network, this node's
+addresses are given to it to start with. With --background, the command
+returns as soon as the service accepted the request; follow it with
+'ipfs pin remote ls --status=queued,pinning,pinned,failed'.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, false, "Path to the object to be pinned."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(pinServiceOptionName, "Name of the remote pinning service to use."),
+		cmdkit.StringOption(pinNameOptionName, "An optional name for the pin."),
+		cmdkit.BoolOption(pinBackgroundOptionName, "Don't wait for the pin to complete."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		c, err := remotePinClient(req, env)
+		if err != nil {
+			return err
+		}
+		name, _ := req.Options[pinNameOptionName].(string)
+		background, _ := req.Options[pinBackgroundOptionName].(bool)
+
+		p, err := coreiface.ParsePath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		st, err := c.Add(req.Context, remote.Pin{
+			Cid:     rp.Cid().String(),
+			Name:    name,
+			Origins: remotePinOrigins(n),
+		})
+		if err != nil {
+			return err
+		}
+
+		// the service tells which of its peers fetch the object
+		if pis, err := peersWithAddresses(st.Delegates); err != nil {
+			log.Debugf("pin remote: invalid delegates: %s", err)
+		} else {
+			for _, pi := range pis {
+				if err := api.Swarm().Connect(req.Context, pi); err != nil {
+					log.Debugf("pin remote: connecting to delegate %s: %s", pi.ID.Pretty(), err)
+				}
+			}
+		}
+
+		for !background && st.Status != remote.Pinned {
+			if st.Status == remote.Failed {
+				return fmt.Errorf("remote pinning of %s failed", st.Pin.Cid)
+			}
+			select {
+			case <-time.After(remotePinPollInterval):
+			case <-req.Context.Done():
+				return req.Context.Err()
+			}
+			if st, err = c.Get(req.Context, st.RequestID); err != nil {
+				return err
+			}
+		}
+		return cmds.EmitOnce(res, remotePinOutput(st))
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RemotePinOutput) error {
+			return writeRemotePin(w, out)
+		}),
+	},
+	Type: RemotePinOutput{},
+}
+
+var listRemotePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the objects pinned to a remote pinning service.",
+		ShortDescription: `
+'ipfs pin remote ls' lists the pins of the service, the most recent first.
+Only the pinned objects are listed by default, use --status to list the pin
+requests in progress or failed.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(pinServiceOptionName, "Name of the remote pinning service to use."),
+		cmdkit.StringOption(pinNameOptionName, "List the pins with this name only."),
+		cmdkit.StringOption(pinCidOptionName, "List the pins of these CIDs only, separated by commas."),
+		cmdkit.StringOption(pinStatusOptionName, "List the pins with these statuses, separated by commas: queued, pinning, pinned or failed.").WithDefault("pinned"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		c, err := remotePinClient(req, env)
+		if err != nil {
+			return err
+		}
+
+		opts := remote.LsOptions{}
+		opts.Name, _ = req.Options[pinNameOptionName].(string)
+		if cids, _ := req.Options[pinCidOptionName].(string); cids != "" {
+			opts.Cids = strings.Split(cids, ",")
+		}
+		statuses, _ := req.Options[pinStatusOptionName].(string)
+		for _, s := range strings.Split(statuses, ",") {
+			switch status := remote.Status(s); status {
+			case remote.Queued, remote.Pinning, remote.Pinned, remote.Failed:
+				opts.Status = append(opts.Status, status)
+			default:
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid pin status %q", s)
+			}
+		}
+
+		pins, err := c.Ls(req.Context, opts)
+		if err != nil {
+			return err
+		}
+		for i := range pins {
+			if err := res.Emit(remotePinOutput(&pins[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RemotePinOutput) error {
+			return writeRemotePin(w, out)
+		}),
+	},
+	Type: RemotePinOutput{},
+}
+
+var syncRemotePinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin the local recursive pins to a remote pinning service.",
+		ShortDescription: `
+'ipfs pin remote sync' asks the service to pin the local recursive pins it
+doesn't have, queued, in progress or pinned. It doesn't wait for the pins to
+complete, the requests that fail are made again by the next sync.
+
+With --auto, the daemon syncs the pins every --interval and when it starts,
+until 'ipfs pin remote sync --auto=false'. The recursive pins created in
+between are synced a few seconds after they are, together. The setting is stored in the config of the service.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(pinServiceOptionName, "Name of the remote pinning service to use."),
+		cmdkit.BoolOption(pinAutoOptionName, "Sync the pins automatically from the daemon."),
+		cmdkit.StringOption(pinIntervalOptionName, "Time between two automatic syncs.").WithDefault("1h"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		c, err := remotePinClient(req, env)
+		if err != nil {
+			return err
+		}
+		name, _ := req.Options[pinServiceOptionName].(string)
+		auto, autoSet := req.Options[pinAutoOptionName].(bool)
+		interval, _ := req.Options[pinIntervalOptionName].(string)
+
+		if !autoSet {
+			added, err := remote.Sync(req.Context, c, n.Pinning, remotePinOrigins(n))
+			out := &RemoteSyncOutput{Added: make([]string, len(added))}
+			for i, k := range added {
+				out.Added[i] = k.String()
+			}
+			if err != nil {
+				if len(added) == 0 {
+					return err
+				}
+				// report the pins requested before the failure
+				if err := res.Emit(out); err != nil {
+					return err
+				}
+				return err
+			}
+			return cmds.EmitOnce(res, out)
+		}
+
+		out := &RemoteSyncOutput{Added: []string{}}
+		if auto {
+			d, err := time.ParseDuration(interval)
+			if err != nil || d <= 0 {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid --%s %q", pinIntervalOptionName, interval)
+			}
+			out.Interval = interval
+		}
+		err = editRemotePinningService(env, name, func(svc *RemotePinningService) {
+			svc.AutoSync = nil
+			if auto {
+				svc.AutoSync = &RemoteAutoSync{Interval: interval}
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		stopRemotePinAutoSync(name)
+		if auto && n.IsDaemon {
+			if err := startRemotePinAutoSync(n, name, c, out.Interval); err != nil {
+				return err
+			}
+			out.Running = true
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RemoteSyncOutput) error {
+			for _, k := range out.Added {
+				fmt.Fprintf(w, "requested %s\n", k)
+			}
+			if _, ok := req.Options[pinAutoOptionName].(bool); !ok {
+				return nil
+			}
+			switch {
+			case out.Interval == "":
+				fmt.Fprintln(w, "automatic sync disabled")
+			case out.Running:
+				fmt.Fprintf(w, "automatic sync every %s enabled\n", out.Interval)
+			default:
+				fmt.Fprintf(w, "automatic sync every %s enabled, it starts with the daemon\n", out.Interval)
+			}
+			return nil
+		}),
+	},
+	Type: RemoteSyncOutput{},
+}
+
+var remotePinServiceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the remote pinning services.",
+		ShortDescription: `
+The remote pinning services are stored in the Pinning.RemoteServices section
+of the config, with their access tokens.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add": addRemotePinServiceCmd,
+		"ls":  listRemotePinServiceCmd,
+		"rm":  rmRemotePinServiceCmd,
+	},
+}
+
+var addRemotePinServiceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add or replace a remote pinning service.",
+		ShortDescription: `
+'ipfs pin remote service add' registers the service at <endpoint>, the URL of
+its Pinning Service API without the /pins path, under <name>. <key> is the
+access token given by the service.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "The name of the service."),
+		cmdkit.StringArg("endpoint", true, false, "The URL of the API of the service."),
+		cmdkit.StringArg("key", true, false, "The access token of the service."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		name, endpoint, key := req.Arguments[0], req.Arguments[1], req.Arguments[2]
+		if name == "" || strings.ContainsAny(name, ". \t") {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid service name %q", name)
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid service endpoint %q, expected an http or https URL", endpoint)
+		}
+		if key == "" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "empty service key")
+		}
+
+		// the automatic sync of a replaced service is kept
+		var svc RemotePinningService
+		err = editRemotePinningService(env, name, func(s *RemotePinningService) {
+			s.API.Endpoint = strings.TrimRight(endpoint, "/")
+			s.API.Key = key
+			svc = *s
+		})
+		if err != nil {
+			return err
+		}
+		if stopRemotePinAutoSync(name) {
+			n, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			c := remote.NewClient(svc.API.Endpoint, svc.API.Key)
+			return startRemotePinAutoSync(n, name, c, svc.AutoSync.Interval)
+		}
+		return nil
+	},
+}
+
+var listRemotePinServiceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the remote pinning services.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		r, err := openConfigRepo(env)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		services, err := RemotePinningServices(r)
+		if err != nil {
+			return err
+		}
+		out := &RemoteServiceList{Services: []RemoteServiceOutput{}}
+		for name, svc := range services {
+			out.Services = append(out.Services, RemoteServiceOutput{Name: name, Endpoint: svc.API.Endpoint})
+		}
+		sort.Slice(out.Services, func(i, j int) bool {
+			return out.Services[i].Name < out.Services[j].Name
+		})
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RemoteServiceList) error {
+			for _, svc := range out.Services {
+				fmt.Fprintf(w, "%s\t%s\n", svc.Name, svc.Endpoint)
+			}
+			return nil
+		}),
+	},
+	Type: RemoteServiceList{},
+}
+
+var rmRemotePinServiceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove a remote pinning service.",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "The name of the service."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		r, err := openConfigRepo(env)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		services, err := RemotePinningServices(r)
+		if err != nil {
+			return err
+		}
+		name := req.Arguments[0]
+		if _, ok := services[name]; !ok {
+			return cmdkit.Errorf(cmdkit.ErrClient, "unknown remote pinning service %q", name)
+		}
+		delete(services, name)
+		if err := r.SetConfigKey(RemoteServicesConfigKey, services); err != nil {
+			return err
+		}
+		stopRemotePinAutoSync(name)
+		return nil
+	},
+}
+
+// openConfigRepo opens the repo to edit its config.
+func openConfigRepo(env cmds.Environment) (repo.Repo, error) {
+	cfgRoot, err := cmdenv.GetConfigRoot(env)
+	if err != nil {
+		return nil, err
+	}
+	return fsrepo.Open(cfgRoot)
+}
+
+// RemotePinningServices returns the remote pinning services defined in the
+// config of r.
+func RemotePinningServices(r repo.Repo) (map[string]RemotePinningService, error) {
+	services := make(map[string]RemotePinningService)
+	if _, err := repo.ReadConfigKey(r, RemoteServicesConfigKey, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// editRemotePinningService applies edit to the service name in the config,
+// creating it if needed.
+func editRemotePinningService(env cmds.Environment, name string, edit func(*RemotePinningService)) error {
+	r, err := openConfigRepo(env)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	services, err := RemotePinningServices(r)
+	if err != nil {
+		return err
+	}
+	svc := services[name]
+	edit(&svc)
+	services[name] = svc
+	return r.SetConfigKey(RemoteServicesConfigKey, services)
+}
+
+// remotePinSyncs are the automatic syncs running in this process, by service
+// name.
+var remotePinSyncs = struct {
+	sync.Mutex
+	cancels map[string]context.CancelFunc
+}{cancels: make(map[string]context.CancelFunc)}
+
+// StartRemotePinAutoSyncs starts the automatic syncs of the remote pinning
+// services configured for n, which runs as a daemon.
+func StartRemotePinAutoSyncs(n *core.IpfsNode) error {
+	services, err := RemotePinningServices(n.Repo)
+	if err != nil {
+		return err
+	}
+	for name, svc := range services {
+		if svc.AutoSync == nil {
+			continue
+		}
+		c := remote.NewClient(svc.API.Endpoint, svc.API.Key)
+		if err := startRemotePinAutoSync(n, name, c, svc.AutoSync.Interval); err != nil {
+			return fmt.Errorf("remote pinning service %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// startRemotePinAutoSync starts the automatic sync of the pins of n to the
+// service name, until n closes.
+func startRemotePinAutoSync(n *core.IpfsNode, name string, c *remote.Client, interval string) error {
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return fmt.Errorf("invalid sync interval %q: %s", interval, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("invalid sync interval %q: must be positive", interval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wake <-chan struct{}
+	unsubscribe := func() {}
+	if tp, ok := n.Pinning.(*pin.TimedPinner); ok {
+		wake, unsubscribe = tp.Subscribe()
+	}
+
+	remotePinSyncs.Lock()
+	if prev, ok := remotePinSyncs.cancels[name]; ok {
+		prev()
+	}
+	remotePinSyncs.cancels[name] = cancel
+	remotePinSyncs.Unlock()
+
+	go func() {
+		defer unsubscribe()
+		remote.AutoSync(ctx, c, n.Pinning, func() []string { return remotePinOrigins(n) }, d, wake)
+	}()
+	go func() {
+		select {
+		case <-n.Process().Closing():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return nil
+}
+
+// stopRemotePinAutoSync stops the automatic sync to the service name, and
+// returns whether it was running.
+func stopRemotePinAutoSync(name string) bool {
+	remotePinSyncs.Lock()
+	defer remotePinSyncs.Unlock()
+	cancel, ok := remotePinSyncs.cancels[name]
+	if ok {
+		cancel()
+		delete(remotePinSyncs.cancels, name)
+	}
+	return ok
+}
+
+// remotePinClient returns a client of the service given with --service.
+func remotePinClient(req *cmds.Request, env cmds.Environment) (*remote.Client, error) {
+	name, ok := req.Options[pinServiceOptionName].(string)
+	if !ok {
+		return nil, cmdkit.Errorf(cmdkit.ErrClient, "missing --%s", pinServiceOptionName)
+	}
+
+	r, err := openConfigRepo(env)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	services, err := RemotePinningServices(r)
+	if err != nil {
+		return nil, err
+	}
+	svc, ok := services[name]
+	if !ok {
+		return nil, cmdkit.Errorf(cmdkit.ErrClient, "unknown remote pinning service %q, see 'ipfs pin remote service add'", name)
+	}
+	return remote.NewClient(svc.API.Endpoint, svc.API.Key), nil
+}
+
+// remotePinOrigins returns the addresses at which the services can fetch the
+// objects from n.
+func remotePinOrigins(n *core.IpfsNode) []string {
+	if n.PeerHost == nil {
+		// offline
+		return nil
+	}
+	addrs := n.PeerHost.Addrs()
+	origins := make([]string, len(addrs))
+	for i, a := range addrs {
+		origins[i] = fmt.Sprintf("%s/ipfs/%s", a, n.Identity.Pretty())
+	}
+	return origins
+}
+
+func remotePinOutput(st *remote.PinStatus) *RemotePinOutput {
+	return &RemotePinOutput{
+		RequestID: st.RequestID,
+		Status:    string(st.Status),
+		Cid:       st.Pin.Cid,
+		Name:      st.Pin.Name,
+	}
+}
+
+func writeRemotePin(w io.Writer, out *RemotePinOutput) error {
+	_, err := fmt.Fprintf(w, "%s\t%s\t%s\n", out.Cid, out.Status, out.Name)
+	return err
+}
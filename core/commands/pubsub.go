@@ -2,14 +2,17 @@ package commands
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"net/http"
 	"sort"
+	"time"
 
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 
+	lru "github.com/hashicorp/golang-lru"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	options "github.com/ipfs/interface-go-ipfs-core/options"
@@ -37,7 +40,20 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 }
 
 const (
-	pubsubDiscoverOptionName = "discover"
+	pubsubDiscoverOptionName      = "discover"
+	pubsubBase64OptionName        = "base64"
+	pubsubTopicEncodingOptionName = "topic-encoding"
+	pubsubCountOptionName         = "count"
+	pubsubDedupWindowOptionName   = "dedup-window"
+)
+
+// pubsubDedupCacheSize bounds the number of message IDs remembered by
+// 'pubsub sub --dedup-window'.
+const pubsubDedupCacheSize = 8192
+
+var (
+	pubsubBase64Option        = cmdkit.BoolOption(pubsubBase64OptionName, "Encode message payloads as base64.")
+	pubsubTopicEncodingOption = cmdkit.StringOption(pubsubTopicEncodingOptionName, "Encoding of topic names: utf8 or base64.").WithDefault("utf8")
 )
 
 type pubsubMessage struct {
@@ -69,6 +85,25 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 This command outputs data in the following encodings:
   * "json"
 (Specified by the "--encoding" or "--enc" flag)
+
+The JSON output always carries message payloads base64 encoded. Pass
+'--base64' to also base64 encode the payloads written by the other encodings,
+so binary messages can be safely printed to a terminal.
+
+Topic names are plain utf8 strings by default. With '--topic-encoding=base64'
+the topic argument is decoded from base64 and the topics of each message are
+base64 encoded in the output.
+
+'--count' makes the command exit once the given number of messages has been
+received. Combined with the global '--timeout' flag the command fails if less
+messages arrive in time:
+
+  > ipfs pubsub sub --count 1 --timeout 30s mytopic
+
+'--dedup-window' suppresses messages whose ID (sender and sequence number)
+was already delivered within the given duration. The pubsub router already
+drops duplicates seen within the last two minutes; use this to extend that
+window. Messages are counted by '--count' only once.
 `,
 	},
 	Arguments: []cmdkit.Argument{
@@ -76,6 +111,10 @@ This command outputs data in the following encodings:
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption(pubsubDiscoverOptionName, "try to discover other peers subscribed to the same topic"),
+		pubsubBase64Option,
+		pubsubTopicEncodingOption,
+		cmdkit.IntOption(pubsubCountOptionName, "Exit after receiving this many messages."),
+		cmdkit.StringOption(pubsubDedupWindowOptionName, "Suppress duplicate messages seen within this duration, eg \"10m\".").WithDefault("0"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -83,9 +122,27 @@ This command outputs data in the following encodings:
 			return err
 		}
 
-		topic := req.Arguments[0]
+		topic, err := pubsubDecodeTopic(req, req.Arguments[0])
+		if err != nil {
+			return err
+		}
 		discover, _ := req.Options[pubsubDiscoverOptionName].(bool)
 
+		count, _ := req.Options[pubsubCountOptionName].(int)
+		if count < 0 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "message count must not be negative")
+		}
+
+		windowStr, _ := req.Options[pubsubDedupWindowOptionName].(string)
+		window, err := time.ParseDuration(windowStr)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "error parsing dedup-window option: %s", err)
+		}
+		dedup, err := newPubsubDedup(window)
+		if err != nil {
+			return err
+		}
+
 		sub, err := api.PubSub().Subscribe(req.Context, topic, options.PubSub.Discover(discover))
 		if err != nil {
 			return err
@@ -96,39 +153,54 @@ This command outputs data in the following encodings:
 			f.Flush()
 		}
 
-		for {
+		for received := 0; count == 0 || received < count; {
 			msg, err := sub.Next(req.Context)
 			if err == io.EOF || err == context.Canceled {
 				return nil
+			} else if err == context.DeadlineExceeded && count > 0 {
+				return fmt.Errorf("timed out after receiving %d of %d messages", received, count)
 			} else if err != nil {
 				return err
 			}
 
+			if dedup.seen(string(msg.From())+string(msg.Seq()), time.Now()) {
+				continue
+			}
+			received++
+
+			topics, err := pubsubEncodeTopics(req, msg.Topics())
+			if err != nil {
+				return err
+			}
+
 			if err := res.Emit(&pubsubMessage{
 				Data:     msg.Data(),
 				From:     []byte(msg.From()),
 				Seqno:    msg.Seq(),
-				TopicIDs: msg.Topics(),
+				TopicIDs: topics,
 			}); err != nil {
 				return err
 			}
 		}
+
+		return nil
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, psm *pubsubMessage) error {
-			_, err := w.Write(psm.Data)
+			_, err := w.Write(pubsubPayload(req, psm))
 			return err
 		}),
 		"ndpayload": cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, psm *pubsubMessage) error {
-			psm.Data = append(psm.Data, '\n')
-			_, err := w.Write(psm.Data)
+			data := append(pubsubPayload(req, psm), '\n')
+			_, err := w.Write(data)
 			return err
 		}),
 		"lenpayload": cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, psm *pubsubMessage) error {
-			buf := make([]byte, 8, len(psm.Data)+8)
+			data := pubsubPayload(req, psm)
+			buf := make([]byte, 8, len(data)+8)
 
-			n := binary.PutUvarint(buf, uint64(len(psm.Data)))
-			buf = append(buf[:n], psm.Data...)
+			n := binary.PutUvarint(buf, uint64(len(data)))
+			buf = append(buf[:n], data...)
 			_, err := w.Write(buf)
 			return err
 		}),
@@ -146,27 +218,48 @@ This is an experimental feature. It is not intended in its current state
 to be used in a production environment.
 
 To use, the daemon must be run with '--enable-pubsub-experiment'.
+
+Pass '--base64' to publish binary payloads: every data argument is decoded
+from base64 before being published. Likewise '--topic-encoding=base64'
+decodes the topic argument from base64.
 `,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("topic", true, false, "Topic to publish to."),
 		cmdkit.StringArg("data", true, true, "Payload of message to publish.").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		pubsubBase64Option,
+		pubsubTopicEncodingOption,
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
 			return err
 		}
 
-		topic := req.Arguments[0]
+		topic, err := pubsubDecodeTopic(req, req.Arguments[0])
+		if err != nil {
+			return err
+		}
 
 		err = req.ParseBodyArgs()
 		if err != nil {
 			return err
 		}
 
+		b64, _ := req.Options[pubsubBase64OptionName].(bool)
+
 		for _, data := range req.Arguments[1:] {
-			if err := api.PubSub().Publish(req.Context, topic, []byte(data)); err != nil {
+			payload := []byte(data)
+			if b64 {
+				payload, err = base64.StdEncoding.DecodeString(data)
+				if err != nil {
+					return cmdkit.Errorf(cmdkit.ErrClient, "invalid base64 payload: %s", err)
+				}
+			}
+
+			if err := api.PubSub().Publish(req.Context, topic, payload); err != nil {
 				return err
 			}
 		}
@@ -175,6 +268,90 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 	},
 }
 
+// pubsubDedup remembers recently delivered message IDs. A zero window
+// disables deduplication.
+type pubsubDedup struct {
+	window time.Duration
+	cache  *lru.Cache
+}
+
+func newPubsubDedup(window time.Duration) (*pubsubDedup, error) {
+	if window < 0 {
+		return nil, cmdkit.Errorf(cmdkit.ErrClient, "dedup window must not be negative")
+	}
+
+	d := &pubsubDedup{window: window}
+	if window > 0 {
+		cache, err := lru.New(pubsubDedupCacheSize)
+		if err != nil {
+			return nil, err
+		}
+		d.cache = cache
+	}
+	return d, nil
+}
+
+// seen records id as delivered at now and reports whether it was already
+// delivered within the window.
+func (d *pubsubDedup) seen(id string, now time.Time) bool {
+	if d.cache == nil {
+		return false
+	}
+
+	if last, ok := d.cache.Get(id); ok && now.Sub(last.(time.Time)) < d.window {
+		return true
+	}
+	d.cache.Add(id, now)
+	return false
+}
+
+// pubsubPayload returns the payload of psm the way the text encoders print
+// it, honoring the base64 option.
+func pubsubPayload(req *cmds.Request, psm *pubsubMessage) []byte {
+	if b64, _ := req.Options[pubsubBase64OptionName].(bool); b64 {
+		return []byte(base64.StdEncoding.EncodeToString(psm.Data))
+	}
+	return psm.Data
+}
+
+func pubsubTopicBase64(req *cmds.Request) (bool, error) {
+	enc, _ := req.Options[pubsubTopicEncodingOptionName].(string)
+	switch enc {
+	case "", "utf8":
+		return false, nil
+	case "base64":
+		return true, nil
+	default:
+		return false, cmdkit.Errorf(cmdkit.ErrClient, "unknown topic encoding %q, must be utf8 or base64", enc)
+	}
+}
+
+func pubsubDecodeTopic(req *cmds.Request, topic string) (string, error) {
+	b64, err := pubsubTopicBase64(req)
+	if err != nil || !b64 {
+		return topic, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(topic)
+	if err != nil {
+		return "", cmdkit.Errorf(cmdkit.ErrClient, "invalid base64 topic: %s", err)
+	}
+	return string(raw), nil
+}
+
+func pubsubEncodeTopics(req *cmds.Request, topics []string) ([]string, error) {
+	b64, err := pubsubTopicBase64(req)
+	if err != nil || !b64 {
+		return topics, err
+	}
+
+	out := make([]string, len(topics))
+	for i, t := range topics {
+		out[i] = base64.StdEncoding.EncodeToString([]byte(t))
+	}
+	return out, nil
+}
+
 var PubsubLsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List subscribed topics by name.",
@@ -187,6 +364,9 @@ to be used in a production environment.
 To use, the daemon must be run with '--enable-pubsub-experiment'.
 `,
 	},
+	Options: []cmdkit.Option{
+		pubsubTopicEncodingOption,
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
@@ -198,6 +378,11 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 			return err
 		}
 
+		l, err = pubsubEncodeTopics(req, l)
+		if err != nil {
+			return err
+		}
+
 		return cmds.EmitOnce(res, stringList{l})
 	},
 	Type: stringList{},
@@ -233,6 +418,9 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("topic", false, false, "topic to list connected peers of"),
 	},
+	Options: []cmdkit.Option{
+		pubsubTopicEncodingOption,
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
@@ -241,7 +429,10 @@ To use, the daemon must be run with '--enable-pubsub-experiment'.
 
 		var topic string
 		if len(req.Arguments) == 1 {
-			topic = req.Arguments[0]
+			topic, err = pubsubDecodeTopic(req, req.Arguments[0])
+			if err != nil {
+				return err
+			}
 		}
 
 		peers, err := api.PubSub().Peers(req.Context, options.PubSub.Topic(topic))
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestPubsubDedup(t *testing.T) {
+	d, err := newPubsubDedup(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if d.seen("a", now) {
+		t.Fatal("first message reported as duplicate")
+	}
+	if !d.seen("a", now.Add(30*time.Second)) {
+		t.Fatal("duplicate within window not reported")
+	}
+	if d.seen("b", now.Add(30*time.Second)) {
+		t.Fatal("different message reported as duplicate")
+	}
+	if d.seen("a", now.Add(2*time.Minute)) {
+		t.Fatal("message outside of window reported as duplicate")
+	}
+}
+
+func TestPubsubDedupDisabled(t *testing.T) {
+	d, err := newPubsubDedup(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if d.seen("a", now) {
+			t.Fatal("duplicate reported with deduplication disabled")
+		}
+	}
+
+	if _, err := newPubsubDedup(-time.Second); err == nil {
+		t.Fatal("expected an error for a negative window")
+	}
+}
+
+func TestPubsubPayload(t *testing.T) {
+	psm := &pubsubMessage{Data: []byte{0xff, 0x00, 'a'}}
+
+	req := &cmds.Request{Options: cmdkit.OptMap{}}
+	if !bytes.Equal(pubsubPayload(req, psm), psm.Data) {
+		t.Fatal("expected the raw payload without --base64")
+	}
+
+	req.Options[pubsubBase64OptionName] = true
+	if out := string(pubsubPayload(req, psm)); out != "/wBh" {
+		t.Fatalf("expected the base64 payload /wBh, got %q", out)
+	}
+}
+
+func TestPubsubTopicEncoding(t *testing.T) {
+	req := &cmds.Request{Options: cmdkit.OptMap{}}
+	if topic, err := pubsubDecodeTopic(req, "plain"); err != nil || topic != "plain" {
+		t.Fatalf("expected the utf8 topic to be kept, got %q, %v", topic, err)
+	}
+
+	req.Options[pubsubTopicEncodingOptionName] = "base64"
+	topic, err := pubsubDecodeTopic(req, "/wBh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic != "\xff\x00a" {
+		t.Fatalf("unexpected decoded topic %q", topic)
+	}
+	topics, err := pubsubEncodeTopics(req, []string{topic, "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topics) != 2 || topics[0] != "/wBh" || topics[1] != "Yg==" {
+		t.Fatalf("unexpected encoded topics %v", topics)
+	}
+	if _, err := pubsubDecodeTopic(req, "not base64!"); err == nil {
+		t.Fatal("expected an error for an invalid base64 topic")
+	}
+
+	req.Options[pubsubTopicEncodingOptionName] = "hex"
+	if _, err := pubsubEncodeTopics(req, []string{"a"}); err == nil {
+		t.Fatal("expected an error for an unknown topic encoding")
+	}
+}
@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	autonat "github.com/libp2p/go-libp2p-autonat"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+const reachabilityExpectedAddrOptionName = "expected-addr"
+
+// reachabilityTimeout bounds a dial back, the checkers waiting 42s on a
+// failed dial.
+const reachabilityTimeout = time.Minute
+
+// ReachabilityOutput is the reachability of an address of the node, as
+// checked by a peer.
+type ReachabilityOutput struct {
+	Addr      string
+	Reachable bool
+	// Dialed is the address the checker reached the node on, which may not
+	// be Addr as it also dials the address the node connected from.
+	Dialed  string `json:",omitempty"`
+	Checker string `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+var ReachabilityCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Diagnose whether the node can be dialed from the internet.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"test": reachabilityTestCmd,
+	},
+}
+
+var reachabilityTestCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check which addresses of the node peers can dial.",
+		ShortDescription: `
+'ipfs reachability test' asks peers to dial the node back on each address it
+announces, and prints whether they could. An address no peer can dial is
+usually behind a NAT or a firewall. With --expected-addr, only that address
+is checked, like the public address a port is forwarded from:
+
+  ipfs reachability test --expected-addr /ip4/203.0.113.7/tcp/4001
+
+The peers checking the addresses run the AutoNAT service, which the nodes
+enable with Swarm.EnableAutoNATService. The peer <multiaddr> is asked when
+given, otherwise the bootstrap peers and the connected peers running the
+service are. The checkers only dial public addresses, and each one checks
+at most 3 addresses of a node a minute, so the addresses are spread over
+them. A checker also dials the address the node connected to it from: an
+address is only reported reachable when the checker reached the node on it.
+
+A failed dial takes up to a minute to be reported. The daemon must be
+running.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("multiaddr", false, false, "The address of the peer checking the reachability, ending with /ipfs/<peer-id>."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(reachabilityExpectedAddrOptionName, "Check only this address of the node."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		addrs := n.PeerHost.Addrs()
+		if s, _ := req.Options[reachabilityExpectedAddrOptionName].(string); s != "" {
+			addr, err := ma.NewMultiaddr(s)
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid --%s %q: %s", reachabilityExpectedAddrOptionName, s, err)
+			}
+			addrs = []ma.Multiaddr{addr}
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("the node announces no address")
+		}
+
+		var checkers []peer.ID
+		if len(req.Arguments) > 0 {
+			addr, pid, err := ParsePeerParam(req.Arguments[0])
+			if err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "failed to parse peer address '%s': %s", req.Arguments[0], err)
+			}
+			if pid == n.Identity {
+				return cmdkit.Errorf(cmdkit.ErrClient, "cannot check the reachability with self")
+			}
+			if err := addPeerAddrs(req.Context, n, addr, pid); err != nil {
+				return err
+			}
+			checkers = []peer.ID{pid}
+		} else {
+			checkers, err = reachabilityCheckers(req.Context, n)
+			if err != nil {
+				return err
+			}
+			if len(checkers) == 0 {
+				return fmt.Errorf("no peer runs the AutoNAT service to check the reachability, give one with <multiaddr>")
+			}
+		}
+
+		// the addresses are checked in parallel, a failed dial taking long
+		results := make(chan *ReachabilityOutput)
+		for i, addr := range addrs {
+			go func(i int, addr ma.Multiaddr) {
+				results <- checkReachability(req.Context, n, addr, checkers, i)
+			}(i, addr)
+		}
+		for range addrs {
+			if err := res.Emit(<-results); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ReachabilityOutput) error {
+			var err error
+			switch {
+			case out.Reachable:
+				_, err = fmt.Fprintf(w, "reachable   %s (checked by %s)\n", out.Addr, out.Checker)
+			case out.Checker == "":
+				_, err = fmt.Fprintf(w, "unknown     %s: %s\n", out.Addr, out.Error)
+			case out.Dialed != "":
+				_, err = fmt.Fprintf(w, "unknown     %s: reached on %s instead (checked by %s)\n", out.Addr, out.Dialed, out.Checker)
+			default:
+				_, err = fmt.Fprintf(w, "unreachable %s: %s (checked by %s)\n", out.Addr, out.Error, out.Checker)
+			}
+			return err
+		}),
+	},
+	Type: ReachabilityOutput{},
+}
+
+// reachabilityCheckers returns the peers running the AutoNAT service, the
+// bootstrap peers first. The bootstrap peers are connected to, to find out
+// whether they run it.
+func reachabilityCheckers(ctx context.Context, n *core.IpfsNode) ([]peer.ID, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	bootstrap, err := cfg.BootstrapPeers()
+	if err != nil {
+		return nil, err
+	}
+
+	var checkers []peer.ID
+	seen := make(map[peer.ID]bool)
+	add := func(p peer.ID) {
+		if seen[p] || p == n.Identity {
+			return
+		}
+		seen[p] = true
+		if protos, err := n.Peerstore.SupportsProtocols(p, autonat.AutoNATProto); err == nil && len(protos) > 0 {
+			checkers = append(checkers, p)
+		}
+	}
+	for _, bp := range bootstrap {
+		n.Peerstore.AddAddr(bp.ID(), bp.Transport(), pstore.TempAddrTTL)
+	}
+	for _, bp := range bootstrap {
+		if seen[bp.ID()] {
+			continue
+		}
+		if n.PeerHost.Network().Connectedness(bp.ID()) != inet.Connected {
+			cctx, cancel := context.WithTimeout(ctx, kPingTimeout)
+			err := n.PeerHost.Connect(cctx, n.Peerstore.PeerInfo(bp.ID()))
+			cancel()
+			if err != nil {
+				log.Debugf("connecting to the bootstrap peer %s: %s", bp.ID().Pretty(), err)
+				continue
+			}
+		}
+		add(bp.ID())
+	}
+	for _, p := range n.PeerHost.Network().Peers() {
+		add(p)
+	}
+	return checkers, nil
+}
+
+// checkReachability asks the checkers to dial the node back on addr, from
+// the checker first, until one of them is not refusing to.
+func checkReachability(ctx context.Context, n *core.IpfsNode, addr ma.Multiaddr, checkers []peer.ID, first int) *ReachabilityOutput {
+	out := &ReachabilityOutput{Addr: addr.String()}
+	if !manet.IsPublicAddr(addr) {
+		// the checkers would not dial it
+		out.Error = "not a public address, not checked"
+		return out
+	}
+	client := autonat.NewAutoNATClient(n.PeerHost, func() []ma.Multiaddr {
+		return []ma.Multiaddr{addr}
+	})
+	for i := range checkers {
+		p := checkers[(first+i)%len(checkers)]
+		cctx, cancel := context.WithTimeout(ctx, reachabilityTimeout)
+		dialed, err := client.DialBack(cctx, p)
+		cancel()
+		switch {
+		case err == nil:
+			out.Checker = p.Pretty()
+			out.Dialed = dialed.String()
+			out.Reachable = dialed.Equal(addr)
+			return out
+		case autonat.IsDialError(err):
+			out.Checker = p.Pretty()
+			out.Error = err.(autonat.AutoNATError).Text
+			return out
+		case ctx.Err() != nil:
+			out.Error = ctx.Err().Error()
+			return out
+		}
+		log.Debugf("checking the reachability of %s with %s: %s", addr, p.Pretty(), err)
+		out.Error = fmt.Sprintf("no checker could dial the address, the last one failed with: %s", err)
+	}
+	return out
+}
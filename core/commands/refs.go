@@ -1,11 +1,13 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"text/template"
 
 	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
@@ -35,11 +37,12 @@ type KeyList struct {
 }
 
 const (
-	refsFormatOptionName    = "format"
-	refsEdgesOptionName     = "edges"
-	refsUniqueOptionName    = "unique"
-	refsRecursiveOptionName = "recursive"
-	refsMaxDepthOptionName  = "max-depth"
+	refsFormatOptionName      = "format"
+	refsEdgesOptionName       = "edges"
+	refsUniqueOptionName      = "unique"
+	refsRecursiveOptionName   = "recursive"
+	refsMaxDepthOptionName    = "max-depth"
+	refsIncludeRootOptionName = "include-root"
 )
 
 // RefsCmd is the `ipfs refs` command
@@ -52,7 +55,9 @@ with the following format:
 
   <link base58 hash>
 
-NOTE: List all references recursively by using the flag '-r'.
+NOTE: List all references recursively by using the flag '-r'. Use
+'--include-root' to also list the hashes of the objects themselves, so that
+'ipfs refs -r --include-root' lists all the hashes of a DAG.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
@@ -62,11 +67,13 @@ NOTE: List all references recursively by using the flag '-r'.
 		cmdkit.StringArg("ipfs-path", true, true, "Path to the object(s) to list refs from.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
-		cmdkit.StringOption(refsFormatOptionName, "Emit edges with given format. Available tokens: <src> <dst> <linkname>.").WithDefault("<dst>"),
+		// no default, 'ipfs refs local' inherits the option with its own
+		cmdkit.StringOption(refsFormatOptionName, "Emit edges with given format. Available tokens: <src> <dst> <linkname>. Default: <dst>."),
 		cmdkit.BoolOption(refsEdgesOptionName, "e", "Emit edge format: `<from> -> <to>`."),
 		cmdkit.BoolOption(refsUniqueOptionName, "u", "Omit duplicate refs from output."),
 		cmdkit.BoolOption(refsRecursiveOptionName, "r", "Recursively list links of child nodes."),
 		cmdkit.IntOption(refsMaxDepthOptionName, "Only for recursive refs, limits fetch and listing to the given depth").WithDefault(-1),
+		cmdkit.BoolOption(refsIncludeRootOptionName, "Emit the hash of each object before its refs."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		err := req.ParseBodyArgs()
@@ -90,6 +97,10 @@ NOTE: List all references recursively by using the flag '-r'.
 		maxDepth, _ := req.Options[refsMaxDepthOptionName].(int)
 		edges, _ := req.Options[refsEdgesOptionName].(bool)
 		format, _ := req.Options[refsFormatOptionName].(string)
+		if format == "" {
+			format = "<dst>"
+		}
+		includeRoot, _ := req.Options[refsIncludeRootOptionName].(bool)
 
 		if !recursive {
 			maxDepth = 1 // write only direct refs
@@ -103,18 +114,23 @@ NOTE: List all references recursively by using the flag '-r'.
 			format = "<src> -> <dst>"
 		}
 
+		if includeRoot && format != "<dst>" {
+			return errors.New("using include-root argument with format or edges is not allowed")
+		}
+
 		objs, err := objectsForPaths(ctx, n, req.Arguments)
 		if err != nil {
 			return err
 		}
 
 		rw := RefWriter{
-			res:      res,
-			DAG:      n.DAG,
-			Ctx:      ctx,
-			Unique:   unique,
-			PrintFmt: format,
-			MaxDepth: maxDepth,
+			res:         res,
+			DAG:         n.DAG,
+			Ctx:         ctx,
+			Unique:      unique,
+			PrintFmt:    format,
+			MaxDepth:    maxDepth,
+			IncludeRoot: includeRoot,
 		}
 
 		for _, o := range objs {
@@ -137,8 +153,21 @@ var RefsLocalCmd = &cmds.Command{
 		ShortDescription: `
 Displays the hashes of all local objects.
 `,
-	},
+		LongDescription: `
+Displays the hashes of all local objects, one per line.
+
+The lines can be changed with --format, a Go template with the fields
+(default: {{.Ref}}):
 
+    {{.Ref}}      the hash of the object
+    {{.Size}}     the size of its block in bytes
+    {{.PinType}}  how it is pinned: direct, recursive, indirect or none
+
+For example, to list the size of the blocks that aren't pinned:
+
+    > ipfs refs local --format='{{.PinType}} {{.Size}} {{.Ref}}' | grep ^none
+`,
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		ctx := req.Context
 		n, err := cmdenv.GetNode(env)
@@ -146,14 +175,29 @@ Displays the hashes of all local objects.
 			return err
 		}
 
+		format, _ := req.Options[refsFormatOptionName].(string)
+		if format == "" {
+			format = "{{.Ref}}"
+		}
+		tmpl, err := template.New("ref").Parse(format)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid format: %s", err)
+		}
+
 		// todo: make async
 		allKeys, err := n.Blockstore.AllKeysChan(ctx)
 		if err != nil {
 			return err
 		}
 
+		pins := &localPinTypes{ctx: ctx, n: n}
+		var buf bytes.Buffer
 		for k := range allKeys {
-			err := res.Emit(&RefWrapper{Ref: k.String()})
+			buf.Reset()
+			if err := tmpl.Execute(&buf, &localRef{k: k, n: n, pins: pins}); err != nil {
+				return err
+			}
+			err := res.Emit(&RefWrapper{Ref: buf.String()})
 			if err != nil {
 				return err
 			}
@@ -165,6 +209,48 @@ Displays the hashes of all local objects.
 	Type:     RefWrapper{},
 }
 
+// localRef is the object given to the template of 'ipfs refs local'. The
+// size and pin type of the ref are only looked up when the template uses
+// them.
+type localRef struct {
+	k    cid.Cid
+	n    *core.IpfsNode
+	pins *localPinTypes
+}
+
+func (r *localRef) Ref() string {
+	return r.k.String()
+}
+
+func (r *localRef) Size() (int, error) {
+	return r.n.Blockstore.GetSize(r.k)
+}
+
+func (r *localRef) PinType() (string, error) {
+	return r.pins.get(r.k)
+}
+
+// localPinTypes lists the pins once, on the first pin type asked for.
+type localPinTypes struct {
+	ctx   context.Context
+	n     *core.IpfsNode
+	types map[cid.Cid]RefKeyObject
+}
+
+func (p *localPinTypes) get(k cid.Cid) (string, error) {
+	if p.types == nil {
+		types, err := pinLsAll(p.ctx, "all", p.n)
+		if err != nil {
+			return "", err
+		}
+		p.types = types
+	}
+	if o, ok := p.types[k]; ok {
+		return o.Type, nil
+	}
+	return "none", nil
+}
+
 func objectsForPaths(ctx context.Context, n *core.IpfsNode, paths []string) ([]ipld.Node, error) {
 	objects := make([]ipld.Node, len(paths))
 	for i, sp := range paths {
@@ -192,16 +278,28 @@ type RefWriter struct {
 	DAG ipld.DAGService
 	Ctx context.Context
 
-	Unique   bool
-	MaxDepth int
-	PrintFmt string
+	Unique      bool
+	MaxDepth    int
+	PrintFmt    string
+	IncludeRoot bool
 
 	seen map[string]int
 }
 
 // WriteRefs writes refs of the given object to the underlying writer.
 func (rw *RefWriter) WriteRefs(n ipld.Node, enc cidenc.Encoder) (int, error) {
-	return rw.writeRefsRecursive(n, 0, enc)
+	var count int
+	if rw.IncludeRoot {
+		// the root is at depth 0, so that Unique doesn't print it twice
+		if _, shouldWrite := rw.visit(n.Cid(), 0); shouldWrite {
+			if err := rw.res.Emit(&RefWrapper{Ref: enc.Encode(n.Cid())}); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	c, err := rw.writeRefsRecursive(n, 0, enc)
+	return count + c, err
 }
 
 func (rw *RefWriter) writeRefsRecursive(n ipld.Node, depth int, enc cidenc.Encoder) (int, error) {
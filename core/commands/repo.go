@@ -36,11 +36,15 @@ var RepoCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"stat":    repoStatCmd,
-		"gc":      repoGcCmd,
-		"fsck":    repoFsckCmd,
-		"version": repoVersionCmd,
-		"verify":  repoVerifyCmd,
+		"stat":          repoStatCmd,
+		"gc":            repoGcCmd,
+		"fsck":          repoFsckCmd,
+		"version":       repoVersionCmd,
+		"verify":        repoVerifyCmd,
+		"shard-migrate": repoShardMigrateCmd,
+		"import":        repoImportCmd,
+		"export":        repoExportCmd,
+		"pack":          repoPackCmd,
 	},
 }
 
@@ -262,6 +266,78 @@ daemons are running.
 	},
 }
 
+const (
+	repoShardFuncOptionName = "shard-func"
+)
+
+var repoShardMigrateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Change the sharding function of the flatfs blockstore.",
+		ShortDescription: `
+'ipfs repo shard-migrate' moves the blocks stored in flatfs to the directory
+layout of a different sharding function, and updates the datastore config of
+the repo to use it. This command can only run when no ipfs daemons are
+running.
+`,
+		LongDescription: `
+'ipfs repo shard-migrate' moves the blocks stored in flatfs to the directory
+layout of a different sharding function, and updates the datastore config of
+the repo to use it. This command can only run when no ipfs daemons are
+running.
+
+The sharding function is given with or without the '/repo/flatfs/shard/v1/'
+prefix. The available functions are:
+
+  prefix/<n>        shard on the first n characters of the key
+  suffix/<n>        shard on the last n characters of the key
+  next-to-last/<n>  shard on the n characters before the last one (default,
+                    with n=2)
+
+Each block is hard linked into its new shard directory before being removed
+from the old one. The migration is verified before the sharding function of
+the datastore is switched. If it is interrupted, run the command again with
+the same sharding function to resume it; the repo must not be used until the
+migration has completed.
+
+Example:
+
+  > ipfs repo shard-migrate --shard-func prefix/3
+  Moved 1022 of 1045 blocks from /repo/flatfs/shard/v1/next-to-last/2 to /repo/flatfs/shard/v1/prefix/3
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(repoShardFuncOptionName, "Sharding function to migrate to, eg \"next-to-last/3\"."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		funStr, ok := req.Options[repoShardFuncOptionName].(string)
+		if !ok {
+			return cmdkit.Errorf(cmdkit.ErrClient, "missing --%s option", repoShardFuncOptionName)
+		}
+		fun, err := fsrepo.ParseShardFunc(funStr)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid sharding function: %s", err)
+		}
+
+		configRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+
+		out, err := fsrepo.MigrateBlocksShardFunc(configRoot, fun)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *fsrepo.ShardMigrateResult) error {
+			_, err := fmt.Fprintf(w, "Moved %d of %d blocks from %s to %s\n", out.Moved, out.Blocks, out.From, out.To)
+			return err
+		}),
+	},
+	Type: fsrepo.ShardMigrateResult{},
+}
+
 type VerifyProgress struct {
 	Msg      string
 	Progress int
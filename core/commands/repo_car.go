@@ -0,0 +1,439 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	car "github.com/ipfs/go-ipfs/car"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	humanize "github.com/dustin/go-humanize"
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	zstd "github.com/klauspost/compress/zstd"
+)
+
+// RepoImportResult is the output of the "repo import" command.
+type RepoImportResult struct {
+	Roots  []string
+	Blocks int
+	Pinned bool
+}
+
+const (
+	repoNoPinOptionName        = "no-pin"
+	repoCarVersionOptionName   = "version"
+	repoExcludeLocalOptionName = "exclude-already-local"
+)
+
+// zstdMagic starts the bundles of 'ipfs repo pack --compress'.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+var repoImportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import the blocks of a CAR file.",
+		ShortDescription: `
+'ipfs repo import' reads a CAR (Content Addressable aRchive) file, stores its
+blocks in the repo and pins the roots listed in its header recursively.
+Both CARv1 and CARv2 files are supported.
+`,
+		LongDescription: `
+'ipfs repo import' reads a CAR (Content Addressable aRchive) file, stores its
+blocks in the repo and pins the roots listed in its header recursively.
+Both CARv1 and CARv2 files are supported, as well as the files compressed
+with zstd by 'ipfs repo pack --compress'.
+
+Every block is checked against its CID before being stored. Pass '--no-pin'
+to only store the blocks; they may then be removed by the next garbage
+collection. Pinning a root whose DAG is not entirely contained in the file
+fetches the missing blocks from the network.
+
+Example:
+
+  > ipfs repo import dump.car
+  imported 4 blocks
+  pinned root QmWATWQ7fVPP2EFGu71UkfnqhYXDYH566qy47CnJDgvs8u
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "The CAR file to import.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(repoNoPinOptionName, "Do not pin the roots of the file."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		noPin, _ := req.Options[repoNoPinOptionName].(bool)
+
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		// keep the garbage collector away until the roots are pinned
+		defer n.Blockstore.PinLock().Unlock()
+
+		var r io.Reader = bufio.NewReader(file)
+		if magic, _ := r.(*bufio.Reader).Peek(len(zstdMagic)); bytes.Equal(magic, zstdMagic) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+			r = zr
+		}
+
+		header, count, err := car.Import(r, n.Blocks.AddBlocks)
+		if err != nil {
+			return err
+		}
+
+		out := &RepoImportResult{Blocks: count, Pinned: !noPin}
+		for _, c := range header.Roots {
+			out.Roots = append(out.Roots, enc.Encode(c))
+			if noPin {
+				continue
+			}
+
+			nd, err := n.DAG.Get(req.Context, c)
+			if err != nil {
+				return fmt.Errorf("pinning root %s: %s", c, err)
+			}
+			if err := n.Pinning.Pin(req.Context, nd, true); err != nil {
+				return fmt.Errorf("pinning root %s: %s", c, err)
+			}
+		}
+		if !noPin {
+			if err := n.Pinning.Flush(); err != nil {
+				return err
+			}
+		}
+
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RepoImportResult) error {
+			fmt.Fprintf(w, "imported %d blocks\n", out.Blocks)
+			verb := "root"
+			if out.Pinned {
+				verb = "pinned root"
+			}
+			for _, r := range out.Roots {
+				fmt.Fprintf(w, "%s %s\n", verb, r)
+			}
+			return nil
+		}),
+	},
+	Type: RepoImportResult{},
+}
+
+var repoExportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Export a DAG as a CAR file.",
+		ShortDescription: `
+'ipfs repo export' writes the DAG rooted at <ipfs-path> to a CAR (Content
+Addressable aRchive) file, with its root CID in the header. The file can be
+imported by 'ipfs repo import' or by other IPFS implementations.
+`,
+		LongDescription: `
+'ipfs repo export' writes the DAG rooted at <ipfs-path> to a CAR (Content
+Addressable aRchive) file, with its root CID in the header. The file can be
+imported by 'ipfs repo import' or by other IPFS implementations.
+
+By default, the file is saved as './<ipfs-path>.car', an alternate path can
+be specified with '--output=<path>' or '-o=<path>'. Use '--output=-' to write
+the file to the standard output.
+
+A CARv1 file is written unless '--version=2' is given. CARv2 files are
+followed by an index of their blocks, so that readers can access a block
+without scanning the whole file. Blocks that are not in the repo are fetched
+from the network.
+
+Example:
+
+  > ipfs repo export QmWATWQ7fVPP2EFGu71UkfnqhYXDYH566qy47CnJDgvs8u -o dump.car
+  Saving CAR file to dump.car
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, false, "The path of the root of the DAG to export."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(outputOptionName, "o", "The path where the CAR file should be stored, '-' for stdout."),
+		cmdkit.IntOption(repoCarVersionOptionName, "CAR format version, 1 or 2.").WithDefault(1),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		_, err := getCarVersion(req)
+		return err
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		version, err := getCarVersion(req)
+		if err != nil {
+			return err
+		}
+
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		p, err := iface.ParsePath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(car.Export(req.Context, api.Dag(), rp.Cid(), version, pw))
+		}()
+		return res.Emit(pr)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			req := res.Request()
+
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+			outReader, ok := v.(io.Reader)
+			if !ok {
+				return e.New(e.TypeErr(outReader, v))
+			}
+
+			return writeCarOutput(outReader, getCarOutPath(req))
+		},
+	},
+}
+
+func getCarVersion(req *cmds.Request) (int, error) {
+	version, _ := req.Options[repoCarVersionOptionName].(int)
+	if version != 1 && version != 2 {
+		return 0, cmdkit.Errorf(cmdkit.ErrClient, "unsupported CAR version %d, expected 1 or 2", version)
+	}
+	return version, nil
+}
+
+func getCarOutPath(req *cmds.Request) string {
+	outPath, _ := req.Options[outputOptionName].(string)
+	if outPath == "" {
+		trimmed := strings.TrimRight(req.Arguments[0], "/")
+		_, outPath = filepath.Split(trimmed)
+		outPath = filepath.Clean(outPath) + ".car"
+	}
+	return outPath
+}
+
+// writeCarOutput saves a CAR file streamed from the daemon to outPath, or to
+// the standard output if outPath is "-".
+func writeCarOutput(r io.Reader, outPath string) error {
+	if outPath == "-" {
+		_, err := io.Copy(os.Stdout, r)
+		return err
+	}
+
+	fmt.Printf("Saving CAR file to %s\n", outPath)
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(outPath)
+		return err
+	}
+	return f.Close()
+}
+
+var repoPackCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pack a DAG into a CAR file for offline transfer.",
+		ShortDescription: `
+'ipfs repo pack' writes the blocks reachable from <ipfs-path> to a CAR file,
+each block once, so the DAG can be carried to a machine without network
+access and loaded there with 'ipfs repo import'.
+`,
+		LongDescription: `
+'ipfs repo pack' writes the blocks reachable from <ipfs-path> to a CAR file,
+each block once, so the DAG can be carried to a machine without network
+access and loaded there with 'ipfs repo import'.
+
+To make the bundle smaller, '--exclude-already-local=<manifest>' omits the
+DAGs already pinned on the destination. The manifest lists the recursive pins
+of the destination repo, one CID per line, as printed by:
+
+  ipfs pin ls --type=recursive -q > manifest
+
+The manifest is read by the client and sent in the body of the request:
+callers of the HTTP API send it as the file of the request, with any value
+for the option.
+
+'--compress' compresses the file with zstd; 'ipfs repo import' decompresses
+it transparently. While the file is written, the progress is shown against
+the size of the whole DAG, which is an estimate when blocks are excluded.
+
+Example:
+
+  > ipfs repo pack --compress QmWATWQ7fVPP2EFGu71UkfnqhYXDYH566qy47CnJDgvs8u bundle.car.zst
+  Packing DAG (estimated size 51 MiB) to bundle.car.zst
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, false, "The path of the root of the DAG to pack."),
+		cmdkit.StringArg("output", true, false, "The path where the CAR file should be stored."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(repoExcludeLocalOptionName, "Manifest of the DAGs pinned on the destination, to leave out."),
+		cmdkit.BoolOption(compressOptionName, "C", "Compress the output with zstd."),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		// the daemon may run on another machine, and must not read files
+		// by path on behalf of the caller
+		manifest, ok := req.Options[repoExcludeLocalOptionName].(string)
+		if !ok {
+			return nil
+		}
+		f, err := os.Open(manifest)
+		if err != nil {
+			return err
+		}
+		cmdenv.SetFileArg(req, "manifest", files.NewReaderFile(f))
+		return nil
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		p, err := iface.ParsePath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		rp, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		seen := cid.NewSet()
+		if _, ok := req.Options[repoExcludeLocalOptionName].(string); ok {
+			if req.Files == nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "--%s needs the manifest as the file of the request", repoExcludeLocalOptionName)
+			}
+			manifest, err := cmdenv.GetFileArg(req.Files.Entries())
+			if err != nil {
+				return err
+			}
+			err = readPackManifest(manifest, seen)
+			manifest.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+		root, err := api.Dag().Get(req.Context, rp.Cid())
+		if err != nil {
+			return err
+		}
+		size, err := root.Size()
+		if err != nil {
+			return err
+		}
+		res.SetLength(size)
+
+		pr, pw := io.Pipe()
+		go func() {
+			cw, err := car.NewWriter(pw, []cid.Cid{rp.Cid()})
+			if err == nil {
+				err = car.WriteDAG(req.Context, api.Dag(), rp.Cid(), seen, cw)
+			}
+			pw.CloseWithError(err)
+		}()
+		return res.Emit(pr)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			req := res.Request()
+
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+			outReader, ok := v.(io.Reader)
+			if !ok {
+				return e.New(e.TypeErr(outReader, v))
+			}
+
+			outPath := req.Arguments[1]
+			file, err := os.Create(outPath)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			var w io.WriteCloser = &identityWriteCloser{file}
+			if compress, _ := req.Options[compressOptionName].(bool); compress {
+				w, err = zstd.NewWriter(file)
+				if err != nil {
+					return err
+				}
+			}
+
+			fmt.Fprintf(os.Stdout, "Packing DAG (estimated size %s) to %s\n", humanize.IBytes(res.Length()), outPath)
+			bar, barR := progressBarForReader(os.Stderr, outReader, int64(res.Length()))
+			bar.Start()
+			_, err = io.Copy(w, barR)
+			bar.Finish()
+			if err != nil {
+				os.Remove(outPath)
+				return err
+			}
+			return w.Close()
+		},
+	},
+}
+
+// readPackManifest adds the CIDs listed in a manifest to set. Only the first
+// field of each line is used, so the output of 'ipfs pin ls' works with or
+// without '-q'.
+func readPackManifest(r io.Reader, set *cid.Set) error {
+	scanner := bufio.NewScanner(r)
+	for i := 1; scanner.Scan(); i++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		c, err := cid.Decode(fields[0])
+		if err != nil {
+			return fmt.Errorf("manifest line %d: %s", i, err)
+		}
+		set.Add(c)
+	}
+	return scanner.Err()
+}
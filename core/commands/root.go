@@ -38,33 +38,57 @@ BASIC COMMANDS
   get <ref>     Download IPFS objects
   ls <ref>      List links from an object
   refs <ref>    List hashes of links from an object
+  pack <dir>    Add a directory and write it to an archive
+  search        Search content by name
 
 DATA STRUCTURE COMMANDS
   block         Interact with raw blocks in the datastore
   object        Interact with raw dag nodes
   files         Interact with objects as if they were a unix filesystem
   dag           Interact with IPLD documents (experimental)
+  diff          Show the changes between two DAGs
+  graph         Process DAGs with WASM modules (experimental)
 
 ADVANCED COMMANDS
   daemon        Start a long-running daemon process
   mount         Mount an IPFS read-only mountpoint
   resolve       Resolve any type of name
   name          Publish and resolve IPNS names
+  checkpoint    Publish a snapshot of an MFS path to IPNS
   key           Create and list IPNS name keypairs
   dns           Resolve DNS links
   pin           Pin objects to local storage
   repo          Manipulate the IPFS repository
+  storage       Limit the size of the repository
+  gc            Manage the garbage collection of the repo
+  datastore     Manage the datastore of the repository
   stats         Various operational stats
+  perf          Run the standard benchmarks
   p2p           Libp2p stream mounting
   filestore     Manage the filestore (experimental)
+  experiment    Run experimental features
 
 NETWORK COMMANDS
   id            Show info about IPFS peers
   bootstrap     Add or remove bootstrap peers
   swarm         Manage connections to the p2p network
+  topology      Print the graph of the peers of the node
+  network       Test the node on simulated networks
+  reachability  Check whether peers can dial the node
+  trust         Manage the trusted peers
+  access-control
+                Restrict the peers the blocks are served to
+  offline-mode  Pause and resume all network activity
   dht           Query the DHT for values or peers
+  routing       Manage and query the content routers
   ping          Measure the latency of a connection
+  trace         Trace the fetching of content
+  transfer      Push content directly to a peer
+  mirror        Fetch content directly from a peer
+  secure-channel
+                Open end-to-end encrypted channels to peers
   diag          Print diagnostics
+  export-state  Write a snapshot of the state of the node
 
 TOOL COMMANDS
   config        Manage configuration
@@ -72,7 +96,13 @@ TOOL COMMANDS
   update        Download and apply go-ipfs updates
   commands      List all available commands
   cid           Convert and discover properties of CIDs
+  content       Audit the content stored by the node
+  content-id    Check content against CIDs
+  content-type  Find the MIME type of content
   log           Manage and show logs of running daemon
+  watch         Run a command when the content of a path changes
+  gateway       Manage the HTTP gateway of running daemon
+  serve         Serve local content for development
 
 Use 'ipfs <command> --help' to learn more about each command.
 
@@ -113,43 +143,75 @@ The CLI will exit with one of the following values:
 var CommandsDaemonCmd = CommandsCmd(Root)
 
 var rootSubcommands = map[string]*cmds.Command{
-	"add":       AddCmd,
-	"bitswap":   BitswapCmd,
-	"block":     BlockCmd,
-	"cat":       CatCmd,
-	"commands":  CommandsDaemonCmd,
-	"files":     FilesCmd,
-	"filestore": FileStoreCmd,
-	"get":       GetCmd,
-	"pubsub":    PubsubCmd,
-	"repo":      RepoCmd,
-	"stats":     StatsCmd,
-	"bootstrap": BootstrapCmd,
-	"config":    ConfigCmd,
-	"dag":       dag.DagCmd,
-	"dht":       DhtCmd,
-	"diag":      DiagCmd,
-	"dns":       DNSCmd,
-	"id":        IDCmd,
-	"key":       KeyCmd,
-	"log":       LogCmd,
-	"ls":        LsCmd,
-	"mount":     MountCmd,
-	"name":      name.NameCmd,
-	"object":    ocmd.ObjectCmd,
-	"pin":       PinCmd,
-	"ping":      PingCmd,
-	"p2p":       P2PCmd,
-	"refs":      RefsCmd,
-	"resolve":   ResolveCmd,
-	"swarm":     SwarmCmd,
-	"tar":       TarCmd,
-	"file":      unixfs.UnixFSCmd,
-	"update":    ExternalBinary(),
-	"urlstore":  urlStoreCmd,
-	"version":   VersionCmd,
-	"shutdown":  daemonShutdownCmd,
-	"cid":       CidCmd,
+	"access-control":    AccessControlCmd,
+	"add":               AddCmd,
+	"alias":             AliasCmd,
+	"batch":             BatchCmd,
+	"bitswap":           BitswapCmd,
+	"block":             BlockCmd,
+	"cat":               CatCmd,
+	"checkpoint":        CheckpointCmd,
+	"commands":          CommandsDaemonCmd,
+	"files":             FilesCmd,
+	"filestore":         FileStoreCmd,
+	"get":               GetCmd,
+	"graph":             GraphCmd,
+	"pubsub":            PubsubCmd,
+	"repo":              RepoCmd,
+	"stats":             StatsCmd,
+	"storage":           StorageCmd,
+	"store-and-forward": StoreForwardCmd,
+	"bootstrap":         BootstrapCmd,
+	"config":            ConfigCmd,
+	"content":           ContentCmd,
+	"content-id":        ContentIDCmd,
+	"content-type":      ContentTypeCmd,
+	"dag":               dag.DagCmd,
+	"datastore":         DatastoreCmd,
+	"dht":               DhtCmd,
+	"diag":              DiagCmd,
+	"diff":              DiffCmd,
+	"dns":               DNSCmd,
+	"experiment":        ExperimentCmd,
+	"export-state":      ExportStateCmd,
+	"id":                IDCmd,
+	"inspect":           InspectCmd,
+	"key":               KeyCmd,
+	"log":               LogCmd,
+	"ls":                LsCmd,
+	"mirror":            MirrorCmd,
+	"mount":             MountCmd,
+	"name":              name.NameCmd,
+	"network":           NetworkCmd,
+	"object":            ocmd.ObjectCmd,
+	"offline-mode":      OfflineModeCmd,
+	"pin":               PinCmd,
+	"ping":              PingCmd,
+	"p2p":               P2PCmd,
+	"pack":              PackCmd,
+	"perf":              PerfCmd,
+	"reachability":      ReachabilityCmd,
+	"refs":              RefsCmd,
+	"resolve":           ResolveCmd,
+	"routing":           RoutingCmd,
+	"search":            SearchCmd,
+	"secure-channel":    SecureChannelCmd,
+	"serve":             ServeCmd,
+	"swarm":             SwarmCmd,
+	"tar":               TarCmd,
+	"topology":          TopologyCmd,
+	"trace":             TraceCmd,
+	"transfer":          TransferCmd,
+	"trust":             TrustCmd,
+	"file":              unixfs.UnixFSCmd,
+	"gateway":           GatewayCmd,
+	"gc":                GcCmd,
+	"update":            ExternalBinary(),
+	"urlstore":          urlStoreCmd,
+	"version":           VersionCmd,
+	"watch":             WatchCmd,
+	"shutdown":          daemonShutdownCmd,
+	"cid":               CidCmd,
 }
 
 // RootRO is the readonly version of Root
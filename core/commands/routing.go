@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	delegated "github.com/ipfs/go-ipfs/routing/delegated"
+
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	routing "github.com/libp2p/go-libp2p-routing"
+)
+
+const (
+	routingEndpointOptionName = "endpoint"
+)
+
+// RoutingProvider is a provider returned by a delegated routing server.
+type RoutingProvider struct {
+	ID    string
+	Addrs []string
+}
+
+var RoutingCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage and query the content routers.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"http": routingHTTPCmd,
+	},
+}
+
+var routingHTTPCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage and query the delegated routing server.",
+		ShortDescription: `
+The delegated routing server answers the content routing requests of the node
+through the HTTP routing API (/routing/v1), so that the node doesn't have to
+run the DHT. It is used when Routing.Type is 'delegated', or 'hybrid' to merge
+its results with the ones of the DHT.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add":  addRoutingHTTPCmd,
+		"find": findRoutingHTTPCmd,
+	},
+}
+
+var addRoutingHTTPCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Set the delegated routing server.",
+		ShortDescription: `
+'ipfs routing http add' sets Routing.DelegatedEndpoint to <endpoint>, the URL
+of a server implementing the HTTP routing API without the /routing/v1 path.
+The daemon uses it after a restart, when Routing.Type is 'delegated' or
+'hybrid':
+
+    > ipfs routing http add https://cid.contact
+    > ipfs config Routing.Type hybrid
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("endpoint", true, false, "The URL of the routing server."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		endpoint := req.Arguments[0]
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid routing endpoint %q, expected an http or https URL", endpoint)
+		}
+
+		r, err := openConfigRepo(env)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return r.SetConfigKey(core.DelegatedEndpointConfigKey, strings.TrimRight(endpoint, "/"))
+	},
+}
+
+var findRoutingHTTPCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Find the providers of a CID on the delegated routing server.",
+		ShortDescription: `
+'ipfs routing http find' asks the delegated routing server, Routing.DelegatedEndpoint
+unless --endpoint is given, for the providers of <cid>. The DHT is not
+queried, see 'ipfs dht findprovs' for that.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The CID to find providers for."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(routingEndpointOptionName, "The URL of the routing server to query instead of the configured one."),
+		cmdkit.IntOption(numProvidersOptionName, "n", "The number of providers to find.").WithDefault(20),
+		cmdkit.BoolOption(verboseOptionName, "v", "Print the addresses of the providers."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		numProviders, _ := req.Options[numProvidersOptionName].(int)
+		if numProviders < 1 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "number of providers must be greater than 0")
+		}
+		c, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid cid %q: %s", req.Arguments[0], err)
+		}
+
+		endpoint, _ := req.Options[routingEndpointOptionName].(string)
+		if endpoint == "" {
+			endpoint, err = core.DelegatedRoutingEndpoint(n.Repo)
+			if err != nil {
+				return err
+			}
+		}
+
+		pis, err := delegated.New(endpoint, n.RecordValidator).FindProviders(req.Context, c)
+		if err != nil && err != routing.ErrNotFound {
+			return err
+		}
+		for i, pi := range pis {
+			if i >= numProviders {
+				break
+			}
+			out := &RoutingProvider{ID: pi.ID.Pretty(), Addrs: make([]string, len(pi.Addrs))}
+			for j, a := range pi.Addrs {
+				out.Addrs[j] = a.String()
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RoutingProvider) error {
+			fmt.Fprintln(w, out.ID)
+			if verbose, _ := req.Options[verboseOptionName].(bool); verbose {
+				for _, a := range out.Addrs {
+					fmt.Fprintf(w, "\t%s\n", a)
+				}
+			}
+			return nil
+		}),
+	},
+	Type: RoutingProvider{},
+}
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	search "github.com/ipfs/go-ipfs/search"
+
+	bserv "github.com/ipfs/go-blockservice"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+// SearchResult is a file or directory found by 'ipfs search local'.
+type SearchResult struct {
+	Cid   string
+	Path  string
+	Score int
+}
+
+var SearchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Search content by name.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"local": searchLocalCmd,
+	},
+}
+
+var searchLocalCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Search the names of the files of the pinned directories.",
+		ShortDescription: `
+'ipfs search local' prints the files and directories of the recursively
+pinned directories with words of <query> in their names, the names matching
+the most words first, as '<cid> <path> <score>'. The words are split at
+spaces and punctuation, and compared case-insensitively:
+
+    > ipfs search local "holiday photos"
+    QmVxtpthpCGyiGMwYz5TCkp9YWq3NDNkpvW5jJKu3fzwXx  /ipfs/Qm.../photos/holiday-photos.jpg  2
+    QmPjr4ZAu5PX4VyMzEbsJiqFoKVPaWzeRdLiNTw1AuYzCe  /ipfs/Qm.../photos/holiday.txt  1
+
+The index is built in the datastore at the first search, and updated with
+the pins added and removed since at each search and 'ipfs pin add'. The
+content of the files isn't indexed.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("query", true, false, "The words to search for."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		results, err := searchIndex(n).Search(req.Context, n.Pinning, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if err := res.Emit(&SearchResult{Cid: enc.Encode(r.Cid), Path: r.Path, Score: r.Score}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SearchResult) error {
+			_, err := fmt.Fprintf(w, "%s  %s  %d\n", out.Cid, out.Path, out.Score)
+			return err
+		}),
+	},
+	Type: SearchResult{},
+}
+
+// searchIndex returns the search index of n. It only reads the local blocks.
+func searchIndex(n *core.IpfsNode) *search.Index {
+	bs := n.Blocks.Blockstore()
+	return search.NewIndex(n.Repo.Datastore(), dag.NewDAGService(bserv.New(bs, offline.Exchange(bs))))
+}
+
+// updateSearchIndex indexes the new pins of n, if the search index has been
+// built.
+func updateSearchIndex(req *cmds.Request, n *core.IpfsNode) {
+	ix := searchIndex(n)
+	if built, err := ix.Built(); err != nil || !built {
+		return
+	}
+	if err := ix.Update(req.Context, n.Pinning); err != nil {
+		log.Errorf("updating the search index: %s", err)
+	}
+}
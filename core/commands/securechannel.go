@@ -0,0 +1,380 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	securechannel "github.com/ipfs/go-ipfs/securechannel"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+// SecureChannelOutput is a channel opened by 'ipfs secure-channel open' or
+// accepted by 'ipfs secure-channel accept', relayed by the node through the
+// local TCP address Addr. The connection to Addr is only relayed once it
+// has sent Secret.
+type SecureChannelOutput struct {
+	Peer   string
+	Addr   string
+	Secret string
+}
+
+const secureChannelAllowOptionName = "allow"
+
+// secureChannelSecretTimeout bounds the wait for the secret of a connection
+// to the relay.
+const secureChannelSecretTimeout = 10 * time.Second
+
+var errSecureChannelAccepting = errors.New("already accepting a secure channel")
+
+// secureChannelAccepting is set while 'ipfs secure-channel accept' runs,
+// since the node has a single handler of the secure channel protocol.
+var secureChannelAccepting = struct {
+	sync.Mutex
+	running bool
+}{}
+
+var SecureChannelCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "End-to-end encrypted channels to peers.",
+		ShortDescription: `
+'ipfs secure-channel' opens a channel to a peer, encrypted between the two
+nodes only with a Noise handshake, over the /ipfs/secure-channel/1.0.0
+protocol, and forwards stdin and stdout through it. A relay between two
+peers, which can see the libp2p traffic it forwards, only sees the
+encrypted messages of the channel. One end runs 'ipfs secure-channel accept'
+and the other 'ipfs secure-channel open'.
+
+The channel is relayed by the daemon through a TCP port, which the command
+connects to: a port of the loopback interface, or of the interface of the
+API if it doesn't listen on the loopback one, for the clients of a remote
+daemon. The connection must start with the one-time secret the daemon
+returns with the port, so no other process can take the channel over. As
+the API, the connection between the client and the daemon isn't
+encrypted. The daemon must be running.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"accept": secureChannelAcceptCmd,
+		"open":   secureChannelOpenCmd,
+	},
+}
+
+var secureChannelOpenCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Open a secure channel to a peer.",
+		ShortDescription: `
+'ipfs secure-channel open' opens a secure channel to <peer-id>, which must
+be running 'ipfs secure-channel accept', and forwards stdin to the peer and
+the data of the peer to stdout, until the peer closes the channel:
+
+  peer A> ipfs secure-channel accept > received.txt
+  peer B> ipfs secure-channel open QmPeerA < message.txt
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("peer-id", true, false, "The peer to open the channel to."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		addr, pid, err := ParsePeerParam(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "failed to parse peer address '%s': %s", req.Arguments[0], err)
+		}
+		if pid == n.Identity {
+			return cmdkit.Errorf(cmdkit.ErrClient, "cannot open a channel to self")
+		}
+		if addr != nil {
+			n.Peerstore.AddAddr(pid, addr, pstore.TempAddrTTL)
+		}
+		if len(n.Peerstore.Addrs(pid)) == 0 {
+			ctx, cancel := context.WithTimeout(req.Context, kPingTimeout)
+			pi, err := n.Routing.FindPeer(ctx, pid)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("peer lookup error: %s", err)
+			}
+			n.Peerstore.AddAddrs(pi.ID, pi.Addrs, pstore.TempAddrTTL)
+		}
+
+		c, err := securechannel.Open(req.Context, n.PeerHost, n.PrivateKey, pid)
+		if err != nil {
+			return fmt.Errorf("failed to open a secure channel to %s: %s", pid.Pretty(), err)
+		}
+		return relaySecureChannel(req.Context, res, env, c)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: secureChannelPostRun,
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SecureChannelOutput) error {
+			_, err := fmt.Fprintf(w, "secure channel with %s at %s\n", out.Peer, out.Addr)
+			return err
+		}),
+	},
+	Type: SecureChannelOutput{},
+}
+
+var secureChannelAcceptCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Accept a secure channel from a peer.",
+		ShortDescription: `
+'ipfs secure-channel accept' waits for a peer to open a secure channel with
+'ipfs secure-channel open', then forwards stdin to the peer and the data of
+the peer to stdout, until the peer closes the channel. A single channel is
+accepted. With --allow, a comma separated list of peer IDs, the channels of
+the other peers are refused.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(secureChannelAllowOptionName, "Only accept a channel from these peers, comma separated."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		var allow map[peer.ID]bool
+		if s, _ := req.Options[secureChannelAllowOptionName].(string); s != "" {
+			allow = make(map[peer.ID]bool)
+			for _, id := range strings.Split(s, ",") {
+				p, err := peer.IDB58Decode(strings.TrimSpace(id))
+				if err != nil {
+					return cmdkit.Errorf(cmdkit.ErrClient, "invalid peer ID %q in --%s: %s", id, secureChannelAllowOptionName, err)
+				}
+				allow[p] = true
+			}
+		}
+
+		secureChannelAccepting.Lock()
+		if secureChannelAccepting.running {
+			secureChannelAccepting.Unlock()
+			return errSecureChannelAccepting
+		}
+		secureChannelAccepting.running = true
+		secureChannelAccepting.Unlock()
+		defer func() {
+			secureChannelAccepting.Lock()
+			secureChannelAccepting.running = false
+			secureChannelAccepting.Unlock()
+		}()
+
+		l := securechannel.Listen(n.PeerHost, n.PrivateKey)
+		var c *securechannel.Channel
+		for {
+			c, err = l.Accept(req.Context)
+			if err != nil || allow == nil || allow[c.RemotePeer()] {
+				break
+			}
+			log.Debugf("refusing a secure channel from %s, not allowed", c.RemotePeer().Pretty())
+			c.Reset()
+		}
+		l.Close()
+		if err != nil {
+			return err
+		}
+		return relaySecureChannel(req.Context, res, env, c)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: secureChannelPostRun,
+	},
+	Encoders: secureChannelOpenCmd.Encoders,
+	Type:     SecureChannelOutput{},
+}
+
+// relaySecureChannel relays the channel c through a new TCP port, and emits
+// its address with a new secret. The first connection to the port sending
+// the secret is relayed until both ends are closed.
+func relaySecureChannel(ctx context.Context, res cmds.ResponseEmitter, env cmds.Environment, c *securechannel.Channel) error {
+	var secret [16]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		c.Reset()
+		return err
+	}
+	host, err := secureChannelRelayHost(env)
+	if err != nil {
+		c.Reset()
+		return err
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		c.Reset()
+		return err
+	}
+	out := &SecureChannelOutput{
+		Peer:   c.RemotePeer().Pretty(),
+		Addr:   ln.Addr().String(),
+		Secret: hex.EncodeToString(secret[:]),
+	}
+	if err := res.Emit(out); err != nil {
+		ln.Close()
+		c.Reset()
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	conn, err := acceptSecureChannelConn(ln, out.Secret)
+	ln.Close()
+	if err != nil {
+		c.Reset()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	defer conn.Close()
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(c, conn)
+		c.Close()
+		errs <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, c)
+		conn.(*net.TCPConn).CloseWrite()
+		errs <- err
+	}()
+
+	var relayErr error
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if relayErr == nil {
+				relayErr = err
+			}
+		case <-ctx.Done():
+			c.Reset()
+			return ctx.Err()
+		}
+	}
+	return relayErr
+}
+
+// secureChannelRelayHost returns the host the relays of the channels listen
+// on: the loopback interface, unless the API listens on another one, for the
+// clients of a remote daemon.
+func secureChannelRelayHost(env cmds.Environment) (string, error) {
+	cfg, err := cmdenv.GetConfig(env)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range cfg.Addresses.API {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			continue
+		}
+		na, err := manet.ToNetAddr(a)
+		if err != nil {
+			continue
+		}
+		if tcp, ok := na.(*net.TCPAddr); ok && !tcp.IP.IsLoopback() {
+			return tcp.IP.String(), nil
+		}
+	}
+	return "127.0.0.1", nil
+}
+
+// acceptSecureChannelConn returns the first connection to ln sending secret,
+// closing the others.
+func acceptSecureChannelConn(ln net.Listener, secret string) (net.Conn, error) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, len(secret))
+		conn.SetReadDeadline(time.Now().Add(secureChannelSecretTimeout))
+		_, err = io.ReadFull(conn, buf)
+		conn.SetReadDeadline(time.Time{})
+		if err == nil && subtle.ConstantTimeCompare(buf, []byte(secret)) == 1 {
+			return conn, nil
+		}
+		log.Debugf("closing a connection to the secure channel relay without the secret")
+		conn.Close()
+	}
+}
+
+// secureChannelPostRun connects to the relay of the channel, at the host of
+// the API, and forwards stdin and stdout through it until the peer closes
+// the channel.
+func secureChannelPostRun(res cmds.Response, re cmds.ResponseEmitter) error {
+	v, err := res.Next()
+	if err != nil {
+		return err
+	}
+	out, ok := v.(*SecureChannelOutput)
+	if !ok {
+		return e.TypeErr(out, v)
+	}
+
+	addr := out.Addr
+	if host := cmdenv.GetAPIHost(res.Request().Context); host != "" {
+		_, port, err := net.SplitHostPort(out.Addr)
+		if err != nil {
+			return err
+		}
+		addr = net.JoinHostPort(host, port)
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, out.Secret); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "secure channel with %s open\n", out.Peer)
+
+	go func() {
+		io.Copy(conn, os.Stdin)
+		conn.(*net.TCPConn).CloseWrite()
+	}()
+	if _, err := io.Copy(os.Stdout, conn); err != nil {
+		return err
+	}
+	conn.Close()
+
+	// the error of the relay, if any
+	for {
+		if _, err := res.Next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
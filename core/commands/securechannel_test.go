@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestAcceptSecureChannelConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	const secret = "0123456789abcdef"
+	dial := func(s string) net.Conn {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(conn, s); err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	wrong := dial("fedcba9876543210")
+	defer wrong.Close()
+	right := dial(secret + "data")
+	defer right.Close()
+
+	conn, err := acceptSecureChannelConn(ln, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the connection without the secret is closed
+	if _, err := ioutil.ReadAll(wrong); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "data" {
+		t.Fatalf("expected the data after the secret, got %q, %v", buf, err)
+	}
+}
@@ -0,0 +1,265 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	gopath "path"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	mfs "github.com/ipfs/go-mfs"
+	path "github.com/ipfs/go-path"
+	resolver "github.com/ipfs/go-path/resolver"
+	unixfile "github.com/ipfs/go-unixfs/file"
+	uio "github.com/ipfs/go-unixfs/io"
+)
+
+const (
+	serveAddrOptionName = "addr"
+)
+
+// ServeOutput is the address 'ipfs serve http' serves its content at.
+type ServeOutput struct {
+	Address string
+	Path    string
+}
+
+var ServeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Serve local content for development.",
+		ShortDescription: `
+'ipfs serve' serves content stored locally, to test it before publishing.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"http": serveHTTPCmd,
+	},
+}
+
+var serveHTTPCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Serve a UnixFS directory over HTTP.",
+		ShortDescription: `
+'ipfs serve http' serves the UnixFS directory <path> at --addr until it is
+interrupted. <path> is a CID, an /ipfs/ path, or an MFS path; the latter is
+looked up on each request, so that the changes made with 'ipfs files' are
+served at once.
+
+The directories are served with their index.html if they have one, listed
+otherwise, and the files with the MIME type of their extension or content.
+
+This is a development tool, not a gateway: the content is only read from the
+local blockstore, and there is no DNSLink, IPNS resolution or CORS headers.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", true, false, "The CID, /ipfs/ path or MFS path of the directory to serve."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(serveAddrOptionName, "The address to listen on.").WithDefault("127.0.0.1:3000"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		// only the local blocks are served
+		bs := n.Blocks.Blockstore()
+		ng := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+		root, err := serveRoot(req.Context, n, ng, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		if _, err := root(); err != nil {
+			return err
+		}
+
+		addr, _ := req.Options[serveAddrOptionName].(string)
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "%s", err)
+		}
+		srv := &http.Server{Handler: http.FileServer(&unixfsHTTPFS{ctx: req.Context, ng: ng, root: root})}
+		errc := make(chan error, 1)
+		go func() {
+			errc <- srv.Serve(lis)
+		}()
+		defer srv.Close()
+
+		if err := res.Emit(&ServeOutput{Address: "http://" + lis.Addr().String(), Path: req.Arguments[0]}); err != nil {
+			return err
+		}
+		select {
+		case err := <-errc:
+			return err
+		case <-req.Context.Done():
+			return nil
+		}
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ServeOutput) error {
+			_, err := fmt.Fprintf(w, "Serving %s at %s\n", out.Path, out.Address)
+			return err
+		}),
+	},
+	Type: ServeOutput{},
+}
+
+// serveRoot returns the function returning the current root node of p: a CID
+// or /ipfs/ path resolved once, or an MFS path looked up on each call.
+func serveRoot(ctx context.Context, n *core.IpfsNode, ng ipld.DAGService, p string) (func() (ipld.Node, error), error) {
+	if strings.HasPrefix(p, "/ipns/") {
+		return nil, cmdkit.Errorf(cmdkit.ErrClient, "IPNS names are not resolved, serve the path they resolve to")
+	}
+
+	if _, err := cid.Decode(p); err == nil || strings.HasPrefix(p, "/ipfs/") {
+		pth, err := path.ParsePath(p)
+		if err != nil {
+			return nil, cmdkit.Errorf(cmdkit.ErrClient, "%s", err)
+		}
+		r := &resolver.Resolver{DAG: ng, ResolveOnce: uio.ResolveUnixfsOnce}
+		nd, err := r.ResolvePath(ctx, pth)
+		if err != nil {
+			return nil, err
+		}
+		return func() (ipld.Node, error) { return nd, nil }, nil
+	}
+
+	p, err := checkPath(p)
+	if err != nil {
+		return nil, cmdkit.Errorf(cmdkit.ErrClient, "%s", err)
+	}
+	return func() (ipld.Node, error) {
+		fsn, err := mfs.Lookup(n.FilesRoot, p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err)
+		}
+		return fsn.GetNode()
+	}, nil
+}
+
+// unixfsHTTPFS is the http.FileSystem of a UnixFS directory.
+type unixfsHTTPFS struct {
+	ctx  context.Context
+	ng   ipld.DAGService
+	root func() (ipld.Node, error)
+}
+
+func (fs *unixfsHTTPFS) Open(name string) (http.File, error) {
+	nd, err := fs.root()
+	if err != nil {
+		return nil, err
+	}
+	name = gopath.Clean("/" + name)
+	if name != "/" {
+		r := &resolver.Resolver{DAG: fs.ng, ResolveOnce: uio.ResolveUnixfsOnce}
+		nd, err = r.ResolvePath(fs.ctx, path.Path(path.FromCid(nd.Cid()).String()+name))
+		if err != nil {
+			return nil, os.ErrNotExist
+		}
+	}
+
+	f, err := unixfile.NewUnixfsFile(fs.ctx, fs.ng, nd)
+	if err != nil {
+		return nil, err
+	}
+	return &unixfsHTTPFile{Node: f, name: gopath.Base(name)}, nil
+}
+
+// unixfsHTTPFile is a UnixFS file or directory opened by unixfsHTTPFS.
+type unixfsHTTPFile struct {
+	files.Node
+	name string
+}
+
+var errServeNotFile = errors.New("not a file")
+
+func (f *unixfsHTTPFile) Read(p []byte) (int, error) {
+	file, ok := f.Node.(files.File)
+	if !ok {
+		return 0, errServeNotFile
+	}
+	return file.Read(p)
+}
+
+func (f *unixfsHTTPFile) Seek(offset int64, whence int) (int64, error) {
+	file, ok := f.Node.(files.File)
+	if !ok {
+		return 0, errServeNotFile
+	}
+	return file.Seek(offset, whence)
+}
+
+func (f *unixfsHTTPFile) Readdir(count int) ([]os.FileInfo, error) {
+	dir, ok := f.Node.(files.Directory)
+	if !ok {
+		return nil, errors.New("not a directory")
+	}
+	var infos []os.FileInfo
+	it := dir.Entries()
+	for (count <= 0 || len(infos) < count) && it.Next() {
+		info, err := unixfsFileInfo(it.Name(), it.Node())
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+	if count > 0 && len(infos) == 0 {
+		return nil, io.EOF
+	}
+	return infos, nil
+}
+
+func (f *unixfsHTTPFile) Stat() (os.FileInfo, error) {
+	return unixfsFileInfo(f.name, f.Node)
+}
+
+// serveFileInfo is the os.FileInfo of a UnixFS node. UnixFS doesn't record
+// the modification times, so no Last-Modified header is sent.
+type serveFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func unixfsFileInfo(name string, nd files.Node) (os.FileInfo, error) {
+	size, err := nd.Size()
+	if err != nil {
+		return nil, err
+	}
+	_, dir := nd.(files.Directory)
+	return &serveFileInfo{name: name, size: size, dir: dir}, nil
+}
+
+func (fi *serveFileInfo) Name() string       { return fi.name }
+func (fi *serveFileInfo) Size() int64        { return fi.size }
+func (fi *serveFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *serveFileInfo) IsDir() bool        { return fi.dir }
+func (fi *serveFileInfo) Sys() interface{}   { return nil }
+
+func (fi *serveFileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	humanize "github.com/dustin/go-humanize"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// StorageQuotaOutput is the storage quota of the repo and its usage.
+type StorageQuotaOutput struct {
+	// StorageMax is the quota in bytes, 0 if it is not enforced.
+	StorageMax uint64
+	Usage      uint64
+}
+
+var StorageCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the storage of the repo.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"quota": storageQuotaCmd,
+	},
+}
+
+var storageQuotaCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Limit the size of the repo.",
+		ShortDescription: `
+'ipfs storage quota' enforces Datastore.StorageMax as a hard limit of the
+size of the repo. Once the repo reaches it, the new blocks are rejected, and
+'ipfs add' and the other writes fail until the repo is garbage collected
+or the quota raised. A warning is logged when the repo reaches 90% of the
+quota.
+
+The quota is checked against the size of the repo when it was enforced,
+updated with the sizes of the blocks added and removed since: it doesn't
+count the overhead of the datastore.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"get":   storageQuotaGetCmd,
+		"set":   storageQuotaSetCmd,
+		"unset": storageQuotaUnsetCmd,
+	},
+}
+
+var storageQuotaGetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the storage quota and the size of the repo.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		return emitStorageQuota(res, n)
+	},
+	Encoders: storageQuotaEncoders,
+	Type:     StorageQuotaOutput{},
+}
+
+var storageQuotaSetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Set the maximum size of the repo.",
+		ShortDescription: `
+'ipfs storage quota set' sets Datastore.StorageMax to <size>, either a
+number of bytes or a size like '50GB', and enforces it. The quota applies to
+the running daemon at once.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("size", true, false, "The maximum size of the repo."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		max, err := humanize.ParseBytes(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid size %q: %s", req.Arguments[0], err)
+		}
+		if max == 0 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "the quota must not be zero, use 'ipfs storage quota unset' to disable it")
+		}
+
+		if err := n.Repo.SetConfigKey("Datastore.StorageMax", req.Arguments[0]); err != nil {
+			return err
+		}
+		if err := n.Repo.SetConfigKey(core.StorageQuotaConfigKey, true); err != nil {
+			return err
+		}
+		if _, prev := n.StorageQuota.Usage(); prev == 0 {
+			// the usage is not read from the repo without a quota
+			usage, err := n.Repo.GetStorageUsage()
+			if err != nil {
+				return err
+			}
+			n.StorageQuota.SetUsage(usage)
+		}
+		n.StorageQuota.SetMax(max)
+		return emitStorageQuota(res, n)
+	},
+	Encoders: storageQuotaEncoders,
+	Type:     StorageQuotaOutput{},
+}
+
+var storageQuotaUnsetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stop limiting the size of the repo.",
+		ShortDescription: `
+'ipfs storage quota unset' stops enforcing Datastore.StorageMax, which is
+kept as the watermark of the automatic garbage collection.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if err := n.Repo.SetConfigKey(core.StorageQuotaConfigKey, false); err != nil {
+			return err
+		}
+		n.StorageQuota.SetMax(0)
+		return emitStorageQuota(res, n)
+	},
+	Encoders: storageQuotaEncoders,
+	Type:     StorageQuotaOutput{},
+}
+
+func emitStorageQuota(res cmds.ResponseEmitter, n *core.IpfsNode) error {
+	usage, max := n.StorageQuota.Usage()
+	if max == 0 {
+		// not tracked without a quota
+		var err error
+		usage, err = n.Repo.GetStorageUsage()
+		if err != nil {
+			return err
+		}
+	}
+	return cmds.EmitOnce(res, &StorageQuotaOutput{StorageMax: max, Usage: usage})
+}
+
+var storageQuotaEncoders = cmds.EncoderMap{
+	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *StorageQuotaOutput) error {
+		if out.StorageMax == 0 {
+			_, err := fmt.Fprintf(w, "usage: %s (no quota)\n", humanize.Bytes(out.Usage))
+			return err
+		}
+		_, err := fmt.Fprintf(w, "usage: %s / %s (%.1f%%)\n", humanize.Bytes(out.Usage), humanize.Bytes(out.StorageMax), 100*float64(out.Usage)/float64(out.StorageMax))
+		return err
+	}),
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+)
+
+// storeForwardLedgerKey is the datastore key under which 'ipfs
+// store-and-forward subscribe' records the messages it processed, by topic.
+var storeForwardLedgerKey = ds.NewKey("/local/store-and-forward")
+
+// StoreForwardPublished is the output of 'ipfs store-and-forward publish'.
+type StoreForwardPublished struct {
+	Cid string
+}
+
+// StoreForwardMessage is a message received by 'ipfs store-and-forward
+// subscribe'.
+type StoreForwardMessage struct {
+	Cid  string
+	From string
+	Data []byte
+}
+
+var StoreForwardCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Deliver pubsub messages through stored blocks.",
+		ShortDescription: `
+'ipfs store-and-forward' sends messages as pinned blocks, and only their CIDs
+over pubsub. Subscribers fetch the messages from the network, so a message
+can be delivered as long as a node providing its block is reachable, and
+record the messages they processed so each one is shown once.
+
+A subscriber doesn't receive the CIDs published while it was offline:
+publish a message again to deliver it to them. Republishing a message
+yields the same CID, which the subscribers that processed it skip.
+
+The daemon must be run with '--enable-pubsub-experiment'.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"publish":   storeForwardPublishCmd,
+		"subscribe": storeForwardSubscribeCmd,
+	},
+}
+
+var storeForwardPublishCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Store a message and publish its CID to a topic.",
+		ShortDescription: `
+'ipfs store-and-forward publish' stores the message as a pinned raw block,
+then publishes its CID to the pubsub topic.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("topic", true, false, "Topic to publish to."),
+		cmdkit.StringArg("message", true, false, "The message to publish.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		topic, message := req.Arguments[0], req.Arguments[1]
+		stat, err := api.Block().Put(req.Context, bytes.NewReader([]byte(message)),
+			options.Block.Format("raw"), options.Block.Pin(true))
+		if err != nil {
+			return err
+		}
+		c := stat.Path().Cid()
+
+		if err := api.PubSub().Publish(req.Context, topic, []byte(c.String())); err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &StoreForwardPublished{Cid: c.String()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *StoreForwardPublished) error {
+			_, err := fmt.Fprintf(w, "published %s\n", out.Cid)
+			return err
+		}),
+	},
+	Type: StoreForwardPublished{},
+}
+
+var storeForwardSubscribeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Receive the messages published to a topic.",
+		ShortDescription: `
+'ipfs store-and-forward subscribe' listens for CIDs on the pubsub topic,
+fetches the messages they name and prints them. The messages printed are
+recorded in the datastore of the node, and skipped when their CID is
+received again, including by later subscriptions.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("topic", true, false, "Topic to subscribe to."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(pubsubDiscoverOptionName, "try to discover other peers subscribed to the same topic"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		topic := req.Arguments[0]
+		discover, _ := req.Options[pubsubDiscoverOptionName].(bool)
+
+		sub, err := api.PubSub().Subscribe(req.Context, topic, options.PubSub.Discover(discover))
+		if err != nil {
+			return err
+		}
+		defer sub.Close()
+
+		if f, ok := res.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		dstore := n.Repo.Datastore()
+		for {
+			msg, err := sub.Next(req.Context)
+			if err != nil {
+				// like 'pubsub sub', the subscription ends with the request
+				if err == io.EOF || req.Context.Err() != nil {
+					return nil
+				}
+				return err
+			}
+
+			c, err := cid.Decode(string(msg.Data()))
+			if err != nil {
+				log.Debugf("store-and-forward: ignoring message %q: %s", msg.Data(), err)
+				continue
+			}
+			key := storeForwardKey(topic, c)
+			if processed, err := dstore.Has(key); err != nil {
+				return err
+			} else if processed {
+				continue
+			}
+
+			data, err := storeForwardFetch(req, api, c)
+			if err != nil {
+				return err
+			}
+			if err := res.Emit(&StoreForwardMessage{
+				Cid:  c.String(),
+				From: msg.From().Pretty(),
+				Data: data,
+			}); err != nil {
+				return err
+			}
+			if err := dstore.Put(key, []byte{}); err != nil {
+				return err
+			}
+		}
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, msg *StoreForwardMessage) error {
+			data := msg.Data
+			if !bytes.HasSuffix(data, []byte("\n")) {
+				data = append(data, '\n')
+			}
+			_, err := w.Write(data)
+			return err
+		}),
+	},
+	Type: StoreForwardMessage{},
+}
+
+// storeForwardKey is the ledger key of the message c received on topic.
+func storeForwardKey(topic string, c cid.Cid) ds.Key {
+	// topics can hold slashes, which separate the key namespaces
+	return storeForwardLedgerKey.ChildString(base64.RawURLEncoding.EncodeToString([]byte(topic))).ChildString(c.String())
+}
+
+func storeForwardFetch(req *cmds.Request, api coreiface.CoreAPI, c cid.Cid) ([]byte, error) {
+	r, err := api.Block().Get(req.Context, coreiface.IpfsPath(c))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch message %s: %s", c, err)
+	}
+	return ioutil.ReadAll(r)
+}
@@ -8,6 +8,7 @@ import (
 	"sort"
 
 	commands "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	repo "github.com/ipfs/go-ipfs/repo"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
@@ -16,6 +17,7 @@ import (
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	config "github.com/ipfs/go-ipfs-config"
+	autonat "github.com/libp2p/go-libp2p-autonat"
 	inet "github.com/libp2p/go-libp2p-net"
 	peer "github.com/libp2p/go-libp2p-peer"
 	pstore "github.com/libp2p/go-libp2p-peerstore"
@@ -46,7 +48,10 @@ ipfs peers in the internet.
 		"connect":    swarmConnectCmd,
 		"disconnect": swarmDisconnectCmd,
 		"filters":    swarmFiltersCmd,
+		"nat":        swarmNatCmd,
 		"peers":      swarmPeersCmd,
+		"relay":      swarmRelayCmd,
+		"unban":      swarmUnbanCmd,
 	},
 }
 
@@ -55,6 +60,7 @@ const (
 	swarmStreamsOptionName   = "streams"
 	swarmLatencyOptionName   = "latency"
 	swarmDirectionOptionName = "direction"
+	swarmScoresOptionName    = "scores"
 )
 
 var swarmPeersCmd = &cmds.Command{
@@ -62,6 +68,12 @@ var swarmPeersCmd = &cmds.Command{
 		Tagline: "List peers with open connections.",
 		ShortDescription: `
 'ipfs swarm peers' lists the set of peers this node is connected to.
+
+With --scores, the reputation score of each peer is shown too. Peers start
+with a score of 100, lose 10 points for each block they send that wasn't asked
+for, and win 1 point, up to 100, for each block they send that was. The blocks
+the node already has don't count. A peer whose score goes below zero is
+banned, see 'ipfs swarm unban'.
 `,
 	},
 	Options: []cmdkit.Option{
@@ -69,17 +81,23 @@ var swarmPeersCmd = &cmds.Command{
 		cmdkit.BoolOption(swarmStreamsOptionName, "Also list information about open streams for each peer"),
 		cmdkit.BoolOption(swarmLatencyOptionName, "Also list information about latency to each peer"),
 		cmdkit.BoolOption(swarmDirectionOptionName, "Also list information about the direction of connection"),
+		cmdkit.BoolOption(swarmScoresOptionName, "Also list the reputation score of each peer"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
 			return err
 		}
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
 
 		verbose, _ := req.Options[swarmVerboseOptionName].(bool)
 		latency, _ := req.Options[swarmLatencyOptionName].(bool)
 		streams, _ := req.Options[swarmStreamsOptionName].(bool)
 		direction, _ := req.Options[swarmDirectionOptionName].(bool)
+		scores, _ := req.Options[swarmScoresOptionName].(bool)
 
 		conns, err := api.Swarm().Peers(req.Context)
 		if err != nil {
@@ -110,6 +128,9 @@ var swarmPeersCmd = &cmds.Command{
 					ci.Latency = lat.String()
 				}
 			}
+			if (verbose || scores) && nd.Reputation != nil {
+				ci.Score = nd.Reputation.Score(c.ID())
+			}
 			if verbose || streams {
 				strs, err := c.Streams()
 				if err != nil {
@@ -129,12 +150,17 @@ var swarmPeersCmd = &cmds.Command{
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, ci *connInfos) error {
+			verbose, _ := req.Options[swarmVerboseOptionName].(bool)
+			scores, _ := req.Options[swarmScoresOptionName].(bool)
 			pipfs := ma.ProtocolWithCode(ma.P_IPFS).Name
 			for _, info := range ci.Peers {
 				fmt.Fprintf(w, "%s/%s/%s", info.Addr, pipfs, info.Peer)
 				if info.Latency != "" {
 					fmt.Fprintf(w, " %s", info.Latency)
 				}
+				if verbose || scores {
+					fmt.Fprintf(w, " score=%d", info.Score)
+				}
 
 				if info.Direction != inet.DirUnknown {
 					fmt.Fprintf(w, " %s", directionString(info.Direction))
@@ -166,6 +192,7 @@ type connInfo struct {
 	Latency   string
 	Muxer     string
 	Direction inet.Direction
+	Score     int
 	Streams   []streamInfo
 }
 
@@ -431,6 +458,54 @@ it will reconnect.
 	Type: stringList{},
 }
 
+var swarmUnbanCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Lift the ban of a peer and reset its reputation score.",
+		ShortDescription: `
+'ipfs swarm unban' resets the reputation score of the given peers. Peers
+sending invalid blocks lose points, and are refused connections for a while
+when their score goes below zero, each ban lasting twice as long as the
+previous one. Unbanning a peer also resets its ban history.
+
+  > ipfs swarm unban QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+  unban QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ success
+
+Scores are shown by 'ipfs swarm peers --scores'.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("peer-id", true, true, "ID of the peer to unban.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !n.IsOnline || n.Reputation == nil {
+			return ErrNotOnline
+		}
+
+		ids := make([]peer.ID, len(req.Arguments))
+		for i, arg := range req.Arguments {
+			if ids[i], err = peer.IDB58Decode(arg); err != nil {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid peer id %q: %s", arg, err)
+			}
+		}
+
+		output := make([]string, len(ids))
+		for i, id := range ids {
+			n.Reputation.Unban(id)
+			output[i] = "unban " + id.Pretty() + " success"
+		}
+		return cmds.EmitOnce(res, &stringList{output})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(stringListEncoder),
+	},
+	Type: stringList{},
+}
+
 // parseAddresses is a function that takes in a slice of string peer addresses
 // (multiaddr + peerid) and returns slices of multiaddrs and peerids.
 func parseAddresses(addrs []string) (iaddrs []iaddr.IPFSAddr, err error) {
@@ -739,3 +814,267 @@ func filtersRemove(r repo.Repo, cfg *config.Config, toRemoveFilters []string) ([
 
 	return removed, nil
 }
+
+var swarmNatCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and manage NAT traversal.",
+		ShortDescription: `
+'ipfs swarm nat' shows whether the node is reachable from the internet, and
+manages the port mappings made on the NAT device with UPnP or NAT-PMP.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status":  swarmNatStatusCmd,
+		"refresh": swarmNatRefreshCmd,
+		"disable": swarmNatDisableCmd,
+	},
+}
+
+type natMapping struct {
+	Protocol        string
+	InternalAddress string
+	ExternalAddress string
+}
+
+type natStatusOutput struct {
+	AutoNAT           string
+	PublicAddress     string `json:",omitempty"`
+	PortMapping       string
+	Mappings          []natMapping
+	ObservedAddresses []string
+}
+
+func natStatusString(s autonat.NATStatus) string {
+	switch s {
+	case autonat.NATStatusPublic:
+		return "public"
+	case autonat.NATStatusPrivate:
+		return "private"
+	default:
+		return "unknown"
+	}
+}
+
+func emitNatStatus(res cmds.ResponseEmitter, n *core.IpfsNode) error {
+	st, err := n.NATState()
+	if err != nil {
+		return err
+	}
+
+	out := &natStatusOutput{
+		AutoNAT:     natStatusString(st.AutoNAT),
+		PortMapping: st.PortMapping,
+	}
+	if st.PublicAddr != nil {
+		out.PublicAddress = st.PublicAddr.String()
+	}
+	for _, m := range st.Mappings {
+		nm := natMapping{Protocol: m.Protocol(), InternalAddress: m.InternalAddr().String()}
+		if ext, err := m.ExternalAddr(); err == nil {
+			nm.ExternalAddress = ext.String()
+		}
+		out.Mappings = append(out.Mappings, nm)
+	}
+	for _, a := range st.Observed {
+		out.ObservedAddresses = append(out.ObservedAddresses, a.String())
+	}
+	return cmds.EmitOnce(res, out)
+}
+
+var natStatusEncoders = cmds.EncoderMap{
+	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *natStatusOutput) error {
+		fmt.Fprintf(w, "AutoNAT: %s", out.AutoNAT)
+		if out.PublicAddress != "" {
+			fmt.Fprintf(w, " (%s)", out.PublicAddress)
+		}
+		fmt.Fprintln(w)
+
+		fmt.Fprintf(w, "Port mapping: %s\n", out.PortMapping)
+		for _, m := range out.Mappings {
+			ext := m.ExternalAddress
+			if ext == "" {
+				ext = "not established"
+			}
+			fmt.Fprintf(w, "  %s %s -> %s\n", m.Protocol, m.InternalAddress, ext)
+		}
+
+		if len(out.ObservedAddresses) > 0 {
+			fmt.Fprintln(w, "Observed addresses:")
+			for _, a := range out.ObservedAddresses {
+				fmt.Fprintf(w, "  %s\n", a)
+			}
+		}
+		return nil
+	}),
+}
+
+var swarmNatStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the state of NAT traversal.",
+		ShortDescription: `
+'ipfs swarm nat status' shows:
+
+  - the reachability of the node found by AutoNAT: public, private, or
+    unknown until a peer running the AutoNAT service dialed the node back,
+  - the state of the UPnP/NAT-PMP port mapper: disabled (by
+    Swarm.DisableNatPortMap), discovering the NAT device, unavailable (no
+    device found) or active, with the port mappings made,
+  - the external addresses peers observed the node at.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		return emitNatStatus(res, n)
+	},
+	Encoders: natStatusEncoders,
+	Type:     natStatusOutput{},
+}
+
+var swarmNatRefreshCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Probe the NAT again and renew the port mappings.",
+		ShortDescription: `
+'ipfs swarm nat refresh' renews the port mappings of the listen addresses on
+the NAT device, mapping them again after 'ipfs swarm nat disable', and asks
+the connected peers running the AutoNAT service to dial the node back. It
+then shows the new state, as 'ipfs swarm nat status' does.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		if _, err := n.RefreshNAT(req.Context); err != nil {
+			return fmt.Errorf("AutoNAT probe failed: %s", err)
+		}
+
+		return emitNatStatus(res, n)
+	},
+	Encoders: natStatusEncoders,
+	Type:     natStatusOutput{},
+}
+
+var swarmNatDisableCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove the port mappings from the NAT device.",
+		ShortDescription: `
+'ipfs swarm nat disable' removes the port mappings made by the node with UPnP
+or NAT-PMP, for instance before taking the node offline without stopping the
+daemon. Addresses the node starts listening on later are mapped again, and
+'ipfs swarm nat refresh' maps the current ones again.
+
+To disable port mapping for good, set Swarm.DisableNatPortMap in the config.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		removed, err := n.DisableNATPortMapping()
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &stringList{[]string{fmt.Sprintf("removed %d port mappings", removed)}})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(stringListEncoder),
+	},
+	Type: stringList{},
+}
+
+var swarmRelayCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect relay circuits.",
+		ShortDescription: `
+'ipfs swarm relay' is a tool to inspect the connections relayed through other
+peers with the p2p-circuit transport.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls": swarmRelayLsCmd,
+	},
+}
+
+type relayCircuitInfo struct {
+	Relay     string
+	Peer      string
+	Direction inet.Direction
+}
+
+type relayCircuits struct {
+	Circuits []relayCircuitInfo
+}
+
+var swarmRelayLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List open relay circuits.",
+		ShortDescription: `
+'ipfs swarm relay ls' lists the relay circuits open on the node, with the
+relay and the peer at the other end of each circuit.
+
+The number of circuits is limited by Swarm.RelayClient.MaxCircuits (16 by
+default), and the number of circuits through a single relay by
+Swarm.RelayClient.MaxCircuitsPerRelay (4 by default). Circuits over the limits
+are closed as soon as they are opened.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		out := &relayCircuits{Circuits: []relayCircuitInfo{}}
+		for _, c := range core.RelayCircuits(n.PeerHost.Network()) {
+			out.Circuits = append(out.Circuits, relayCircuitInfo{
+				Relay:     c.Relay.Pretty(),
+				Peer:      c.Peer.Pretty(),
+				Direction: c.Direction,
+			})
+		}
+		sort.Slice(out.Circuits, func(i, j int) bool {
+			if out.Circuits[i].Relay != out.Circuits[j].Relay {
+				return out.Circuits[i].Relay < out.Circuits[j].Relay
+			}
+			return out.Circuits[i].Peer < out.Circuits[j].Peer
+		})
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *relayCircuits) error {
+			for _, c := range out.Circuits {
+				fmt.Fprintf(w, "%s %s", c.Relay, c.Peer)
+				if d := directionString(c.Direction); d != "" {
+					fmt.Fprintf(w, " %s", d)
+				}
+				fmt.Fprintln(w)
+			}
+			return nil
+		}),
+	},
+	Type: relayCircuits{},
+}
@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	bitswap "github.com/ipfs/go-bitswap"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	peer "github.com/libp2p/go-libp2p-peer"
+	identify "github.com/libp2p/go-libp2p/p2p/protocol/identify"
+)
+
+const topologyDotOptionName = "dot"
+
+// The relations between the node and a peer in the topology graph.
+const (
+	topologySwarm   = "swarm"
+	topologyDHT     = "dht"
+	topologyBitswap = "bitswap"
+)
+
+// TopologyGraph is the overlay topology seen by the node. The field names
+// follow the graph format of D3.
+type TopologyGraph struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
+
+// TopologyNode is a peer of the topology graph.
+type TopologyNode struct {
+	ID              string `json:"id"`
+	Self            bool   `json:"self,omitempty"`
+	AgentVersion    string `json:"agentVersion,omitempty"`
+	ProtocolVersion string `json:"protocolVersion,omitempty"`
+}
+
+// TopologyEdge links the node to a peer it knows.
+type TopologyEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// Relations are how the node knows the peer: "swarm" if it is
+	// connected, "dht" if it is in the DHT routing table, "bitswap" if it is
+	// a bitswap partner.
+	Relations []string `json:"relations"`
+	// Latency is the average latency to the peer in milliseconds, 0 if it
+	// is not known.
+	Latency float64 `json:"latency,omitempty"`
+	// Protocols are the protocols of the streams open with the peer.
+	Protocols []string `json:"protocols,omitempty"`
+}
+
+var TopologyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the graph of the peers of the node.",
+		ShortDescription: `
+'ipfs topology' prints the peers the node knows as a JSON graph of the form
+{"nodes":[...], "edges":[...]}, which can be rendered with D3. The peers are
+those of the swarm, of the DHT routing table and the bitswap partners. The
+nodes have the agent and protocol versions of the peers, and the edges from
+the node to its peers have:
+
+  relations  how the node knows the peer: swarm, dht or bitswap
+  latency    the average latency to the peer, in milliseconds
+  protocols  the protocols of the streams open with the peer
+
+With --dot, the graph is printed in the DOT language of Graphviz:
+
+  > ipfs topology --dot | dot -Tsvg > topology.svg
+
+Only the links of the node are known: the peers of its peers are not
+queried.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(topologyDotOptionName, "Print the graph in the DOT language of Graphviz."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		relations := make(map[peer.ID][]string)
+		for _, p := range n.PeerHost.Network().Peers() {
+			relations[p] = append(relations[p], topologySwarm)
+		}
+		if n.DHT != nil {
+			for _, p := range n.DHT.RoutingTable().ListPeers() {
+				relations[p] = append(relations[p], topologyDHT)
+			}
+		}
+		if bs, ok := n.Exchange.(*bitswap.Bitswap); ok {
+			st, err := bs.Stat()
+			if err != nil {
+				return err
+			}
+			for _, s := range st.Peers {
+				p, err := peer.IDB58Decode(s)
+				if err != nil {
+					return err
+				}
+				relations[p] = append(relations[p], topologyBitswap)
+			}
+		}
+
+		self := n.Identity.Pretty()
+		out := &TopologyGraph{
+			Nodes: []TopologyNode{{
+				ID:              self,
+				Self:            true,
+				AgentVersion:    identify.ClientVersion,
+				ProtocolVersion: identify.LibP2PVersion,
+			}},
+			Edges: []TopologyEdge{},
+		}
+		peers := make([]peer.ID, 0, len(relations))
+		for p := range relations {
+			if p != n.Identity {
+				peers = append(peers, p)
+			}
+		}
+		sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+		for _, p := range peers {
+			out.Nodes = append(out.Nodes, topologyNode(n, p))
+			out.Edges = append(out.Edges, TopologyEdge{
+				Source:    self,
+				Target:    p.Pretty(),
+				Relations: relations[p],
+				Latency:   float64(n.Peerstore.LatencyEWMA(p)) / float64(time.Millisecond),
+				Protocols: topologyProtocols(n, p),
+			})
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, g *TopologyGraph) error {
+			if dot, _ := req.Options[topologyDotOptionName].(bool); dot {
+				return writeTopologyDot(w, g)
+			}
+			buf, err := json.MarshalIndent(g, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "%s\n", buf)
+			return err
+		}),
+	},
+	Type: TopologyGraph{},
+}
+
+func topologyNode(n *core.IpfsNode, p peer.ID) TopologyNode {
+	out := TopologyNode{ID: p.Pretty()}
+	if v, err := n.Peerstore.Get(p, "AgentVersion"); err == nil {
+		out.AgentVersion, _ = v.(string)
+	}
+	if v, err := n.Peerstore.Get(p, "ProtocolVersion"); err == nil {
+		out.ProtocolVersion, _ = v.(string)
+	}
+	return out
+}
+
+// topologyProtocols returns the sorted protocols of the streams open with p.
+func topologyProtocols(n *core.IpfsNode, p peer.ID) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, c := range n.PeerHost.Network().ConnsToPeer(p) {
+		for _, s := range c.GetStreams() {
+			proto := string(s.Protocol())
+			if proto == "" || seen[proto] {
+				continue
+			}
+			seen[proto] = true
+			out = append(out, proto)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// writeTopologyDot writes g as a Graphviz digraph. The peers the node is not
+// connected to are dashed.
+func writeTopologyDot(w io.Writer, g *TopologyGraph) error {
+	fmt.Fprintln(w, "digraph topology {")
+	for _, nd := range g.Nodes {
+		label := nd.ID
+		if nd.AgentVersion != "" {
+			label += "\n" + nd.AgentVersion
+		}
+		attrs := fmt.Sprintf("label=%q", label)
+		if nd.Self {
+			attrs += ", style=bold"
+		}
+		fmt.Fprintf(w, "  %q [%s];\n", nd.ID, attrs)
+	}
+	for _, e := range g.Edges {
+		label := strings.Join(e.Relations, ",")
+		if e.Latency > 0 {
+			label += fmt.Sprintf("\n%.3fms", e.Latency)
+		}
+		attrs := fmt.Sprintf("label=%q", label)
+		connected := false
+		for _, r := range e.Relations {
+			if r == topologySwarm {
+				connected = true
+			}
+		}
+		if !connected {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(w, "  %q -> %q [%s];\n", e.Source, e.Target, attrs)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTopologyDot(t *testing.T) {
+	g := &TopologyGraph{
+		Nodes: []TopologyNode{
+			{ID: "QmSelf", Self: true, AgentVersion: "go-ipfs/0.4.19"},
+			{ID: "QmConnected"},
+			{ID: "QmRouting"},
+		},
+		Edges: []TopologyEdge{
+			{Source: "QmSelf", Target: "QmConnected", Relations: []string{topologySwarm, topologyBitswap}, Latency: 1.5},
+			{Source: "QmSelf", Target: "QmRouting", Relations: []string{topologyDHT}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := writeTopologyDot(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph topology {
+  "QmSelf" [label="QmSelf\ngo-ipfs/0.4.19", style=bold];
+  "QmConnected" [label="QmConnected"];
+  "QmRouting" [label="QmRouting"];
+  "QmSelf" -> "QmConnected" [label="swarm,bitswap\n1.500ms"];
+  "QmSelf" -> "QmRouting" [label="dht", style=dashed];
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected dot output:\n%s", buf.String())
+	}
+}
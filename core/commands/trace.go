@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	bstrace "github.com/ipfs/go-ipfs/exchange/trace"
+
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+// TraceRouteBlock is a block fetched by 'ipfs trace route'.
+type TraceRouteBlock struct {
+	Cid string
+	// Time is when the block was fetched, since the start of the trace.
+	Time time.Duration
+	// Peer is the peer the block came from, empty if it was stored
+	// locally or the peer is not known.
+	Peer       string `json:",omitempty"`
+	Local      bool
+	Latency    time.Duration
+	Size       int
+	Duplicates []string `json:",omitempty"`
+	Error      string   `json:",omitempty"`
+}
+
+var TraceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Trace the fetching of content.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"route": traceRouteCmd,
+	},
+}
+
+var traceRouteCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show which peers provided the blocks of a DAG.",
+		ShortDescription: `
+'ipfs trace route' fetches the DAG <cid> with a bitswap session, and records
+for each block the peer it came from, the latency of the request, and
+whether it was stored locally. The DAG is fetched level by level: the
+latency of a block is the time between the request of its level and its
+arrival. The other peers which also sent the block are counted as
+duplicates.
+
+A table of the blocks, sorted by the time they were fetched, is printed. The
+command fails if a block can't be fetched: set --timeout to give up on the
+blocks no peer provides.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The root of the DAG to fetch."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		root, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid cid %q: %s", req.Arguments[0], err)
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+		var tr *bstrace.Trace
+		if n.BlockTracer != nil {
+			tr = n.BlockTracer.Start()
+			defer tr.Stop()
+		}
+		ng := dag.NewSession(req.Context, n.DAG)
+
+		var out []*TraceRouteBlock
+		seen := cid.NewSet()
+		seen.Add(root)
+		level := []cid.Cid{root}
+		for len(level) > 0 {
+			requested := time.Now()
+			local := make(map[cid.Cid]bool)
+			for _, c := range level {
+				if has, _ := n.Blockstore.Has(c); has {
+					local[c] = true
+				}
+			}
+
+			fetched := cid.NewSet()
+			var next []cid.Cid
+			var ferr error
+			for opt := range ng.GetMany(req.Context, level) {
+				if opt.Err != nil {
+					ferr = opt.Err
+					break
+				}
+				now := time.Now()
+				nd := opt.Node
+				c := nd.Cid()
+				fetched.Add(c)
+				b := &TraceRouteBlock{
+					Cid:     enc.Encode(c),
+					Time:    now.Sub(start),
+					Local:   local[c],
+					Latency: now.Sub(requested),
+					Size:    len(nd.RawData()),
+				}
+				if b.Local {
+					b.Latency = 0
+				} else if tr != nil {
+					if r, ok := tr.Received(c); ok {
+						b.Peer = r.Peer.Pretty()
+						b.Time = r.Time.Sub(start)
+						b.Latency = r.Time.Sub(requested)
+						for _, p := range r.Duplicates {
+							b.Duplicates = append(b.Duplicates, p.Pretty())
+						}
+					}
+				}
+				out = append(out, b)
+
+				for _, l := range nd.Links() {
+					if seen.Visit(l.Cid) {
+						next = append(next, l.Cid)
+					}
+				}
+			}
+			if ferr == nil {
+				ferr = req.Context.Err()
+			}
+
+			// the blocks of the level which didn't come
+			failed := false
+			for _, c := range level {
+				if fetched.Has(c) {
+					continue
+				}
+				failed = true
+				msg := "not fetched"
+				if ferr != nil {
+					msg = ferr.Error()
+				}
+				out = append(out, &TraceRouteBlock{
+					Cid:   enc.Encode(c),
+					Time:  time.Since(start),
+					Error: msg,
+				})
+			}
+			if failed {
+				// the children of the missing blocks are not known
+				break
+			}
+			level = next
+		}
+
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Time < out[j].Time })
+		for _, b := range out {
+			if err := res.Emit(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			tw := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "Time\tCID\tSource\tLatency\tSize\tDuplicates")
+			failed := 0
+			for {
+				v, err := res.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					tw.Flush()
+					return err
+				}
+				b, ok := v.(*TraceRouteBlock)
+				if !ok {
+					tw.Flush()
+					return e.TypeErr(b, v)
+				}
+
+				source := b.Peer
+				switch {
+				case b.Error != "":
+					failed++
+					source = "failed: " + b.Error
+				case b.Local:
+					source = "local"
+				case source == "":
+					source = "unknown"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\n", roundDuration(b.Time), b.Cid, source, roundDuration(b.Latency), b.Size, len(b.Duplicates))
+			}
+			tw.Flush()
+			if failed > 0 {
+				return fmt.Errorf("%d blocks could not be fetched", failed)
+			}
+			return nil
+		},
+	},
+	Type: TraceRouteBlock{},
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d > time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
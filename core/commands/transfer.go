@@ -0,0 +1,280 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	transfer "github.com/ipfs/go-ipfs/transfer"
+
+	humanize "github.com/dustin/go-humanize"
+	bserv "github.com/ipfs/go-blockservice"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	dag "github.com/ipfs/go-merkledag"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const (
+	transferAuthOptionName        = "auth"
+	transferRequireAuthOptionName = "require-auth"
+	transferAllowOptionName       = "allow"
+	transferMaxSizeOptionName     = "max-size"
+)
+
+// TransferOutput is a transfer pushed by 'ipfs transfer push', or received
+// by 'ipfs transfer receive'.
+type TransferOutput struct {
+	Peer   string
+	Cid    string
+	Blocks int
+	Size   uint64
+	Error  string `json:",omitempty"`
+}
+
+var errTransferReceiving = errors.New("already receiving transfers")
+
+// transferReceiving is set while 'ipfs transfer receive' runs, since the
+// node has a single handler of the transfer protocol.
+var transferReceiving = struct {
+	sync.Mutex
+	running bool
+}{}
+
+var TransferCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Push content directly to a peer.",
+		ShortDescription: `
+'ipfs transfer' sends all the blocks of a DAG to a peer over a dedicated
+libp2p protocol, instead of having the peer request them with bitswap. The
+peer must be running 'ipfs transfer receive', which pins the DAGs pushed.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"push":    transferPushCmd,
+		"receive": transferReceiveCmd,
+	},
+}
+
+var transferPushCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Send the blocks of a DAG to a peer.",
+		ShortDescription: `
+'ipfs transfer push' sends all the blocks of the DAG <ipfs-path> to the peer
+<peer-id>, which pins it once it has received them all. The blocks must be
+stored locally. --auth gives the secret the peer requires with
+--require-auth, which is not sent itself: only an HMAC of the transfer is.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("peer-id", true, false, "The peer to push to."),
+		cmdkit.StringArg("ipfs-path", true, false, "The path of the DAG to push."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(transferAuthOptionName, "The secret the peer requires."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		addr, pid, err := ParsePeerParam(req.Arguments[0])
+		if err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "failed to parse peer address '%s': %s", req.Arguments[0], err)
+		}
+		if pid == n.Identity {
+			return cmdkit.Errorf(cmdkit.ErrClient, "cannot push to self")
+		}
+		p, err := coreiface.ParsePath(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		rp, err := api.ResolvePath(req.Context, p)
+		if err != nil {
+			return err
+		}
+
+		if err := addPeerAddrs(req.Context, n, addr, pid); err != nil {
+			return err
+		}
+
+		// only the local blocks are pushed
+		bs := n.Blocks.Blockstore()
+		ng := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+		secret, _ := req.Options[transferAuthOptionName].(string)
+		r, err := transfer.Push(req.Context, n.PeerHost, ng, pid, rp.Cid(), secret)
+		if err != nil {
+			return err
+		}
+
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &TransferOutput{
+			Peer:   r.Peer.Pretty(),
+			Cid:    enc.Encode(r.Root),
+			Blocks: r.Blocks,
+			Size:   r.Size,
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *TransferOutput) error {
+			_, err := fmt.Fprintf(w, "pushed %s to %s: %d blocks, %s\n", out.Cid, out.Peer, out.Blocks, humanize.Bytes(out.Size))
+			return err
+		}),
+	},
+	Type: TransferOutput{},
+}
+
+// addPeerAddrs adds the address of the peer pid, if any, to the peerstore,
+// or looks the peer up if the peerstore has no address of it.
+func addPeerAddrs(ctx context.Context, n *core.IpfsNode, addr ma.Multiaddr, pid peer.ID) error {
+	if addr != nil {
+		n.Peerstore.AddAddr(pid, addr, pstore.TempAddrTTL)
+	}
+	if len(n.Peerstore.Addrs(pid)) == 0 {
+		ctx, cancel := context.WithTimeout(ctx, kPingTimeout)
+		pi, err := n.Routing.FindPeer(ctx, pid)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("peer lookup error: %s", err)
+		}
+		n.Peerstore.AddAddrs(pi.ID, pi.Addrs, pstore.TempAddrTTL)
+	}
+	return nil
+}
+
+var transferReceiveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Receive and pin the DAGs pushed by peers.",
+		ShortDescription: `
+'ipfs transfer receive' accepts the DAGs pushed with 'ipfs transfer push'
+until it is interrupted, and prints them as they are received. The blocks of
+a DAG are checked against their CIDs, and the DAG is pinned recursively once
+complete.
+
+The transfers are only accepted with --require-auth, from the peers that
+authenticate them with the same secret given to --auth, or from the peers
+given to --allow, a comma separated list of peer IDs. With both, a transfer
+must pass both checks. The blocks of a transfer are limited to --max-size,
+like '10GiB'. Default: 1GiB.
+
+The daemon must be running.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(transferRequireAuthOptionName, "Only accept the transfers with this secret."),
+		cmdkit.StringOption(transferAllowOptionName, "Only accept the transfers from these peers, comma separated."),
+		cmdkit.StringOption(transferMaxSizeOptionName, "The largest size of the blocks of a transfer."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !n.IsOnline {
+			return ErrNotOnline
+		}
+
+		var cfg transfer.ReceiverConfig
+		cfg.Secret, _ = req.Options[transferRequireAuthOptionName].(string)
+		if allow, _ := req.Options[transferAllowOptionName].(string); allow != "" {
+			for _, s := range strings.Split(allow, ",") {
+				p, err := peer.IDB58Decode(strings.TrimSpace(s))
+				if err != nil {
+					return cmdkit.Errorf(cmdkit.ErrClient, "invalid peer ID %q in --%s: %s", s, transferAllowOptionName, err)
+				}
+				cfg.Allow = append(cfg.Allow, p)
+			}
+		}
+		if maxSize, _ := req.Options[transferMaxSizeOptionName].(string); maxSize != "" {
+			cfg.MaxSize, err = humanize.ParseBytes(maxSize)
+			if err != nil || cfg.MaxSize == 0 {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid --%s %q", transferMaxSizeOptionName, maxSize)
+			}
+		}
+		if cfg.Secret == "" && len(cfg.Allow) == 0 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "refusing to accept the transfers of any peer, give --%s or --%s", transferRequireAuthOptionName, transferAllowOptionName)
+		}
+
+		transferReceiving.Lock()
+		if transferReceiving.running {
+			transferReceiving.Unlock()
+			return errTransferReceiving
+		}
+		transferReceiving.running = true
+		transferReceiving.Unlock()
+		defer func() {
+			transferReceiving.Lock()
+			transferReceiving.running = false
+			transferReceiving.Unlock()
+		}()
+
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		results := make(chan *transfer.Result)
+		rcv, err := transfer.NewReceiver(n.PeerHost, n.Blockstore, n.Pinning, cfg, func(r *transfer.Result) {
+			select {
+			case results <- r:
+			case <-req.Context.Done():
+			}
+		})
+		if err != nil {
+			return err
+		}
+		rcv.Start()
+		defer rcv.Close()
+
+		for {
+			select {
+			case r := <-results:
+				out := &TransferOutput{
+					Peer:   r.Peer.Pretty(),
+					Cid:    enc.Encode(r.Root),
+					Blocks: r.Blocks,
+					Size:   r.Size,
+				}
+				if r.Error != nil {
+					out.Error = r.Error.Error()
+				}
+				if err := res.Emit(out); err != nil {
+					return err
+				}
+			case <-req.Context.Done():
+				return nil
+			}
+		}
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *TransferOutput) error {
+			if out.Error != "" {
+				_, err := fmt.Fprintf(w, "rejected %s from %s: %s\n", out.Cid, out.Peer, out.Error)
+				return err
+			}
+			_, err := fmt.Fprintf(w, "received %s from %s: %d blocks, %s\n", out.Cid, out.Peer, out.Blocks, humanize.Bytes(out.Size))
+			return err
+		}),
+	},
+	Type: TransferOutput{},
+}
@@ -0,0 +1,158 @@
+package commands
+
+import (
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var TrustCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the trusted peers.",
+		ShortDescription: `
+The trusted peers are known-good providers, such as the other nodes of a
+private cluster. They are listed in the Trusted.Peers section of the config.
+
+The trusted peers are not scored by their behaviour: they are never banned
+for sending blocks the node didn't ask for, and their connections are kept
+by the connection manager when it trims the connections of the node.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add":    trustAddCmd,
+		"remove": trustRemoveCmd,
+		"list":   trustListCmd,
+	},
+}
+
+var trustAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Trust peers.",
+		ShortDescription: `
+'ipfs trust add' adds the given peers to Trusted.Peers, and lifts their ban
+if any. A running daemon trusts them at once.
+
+  > ipfs trust add QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+  trust QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ success
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("peer-id", true, true, "ID of the peer to trust.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		return updateTrustedPeers(req, res, env, true)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(stringListEncoder),
+	},
+	Type: stringList{},
+}
+
+var trustRemoveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stop trusting peers.",
+		ShortDescription: `
+'ipfs trust remove' removes the given peers from Trusted.Peers. A running
+daemon scores them again at once, from the initial score.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("peer-id", true, true, "ID of the peer to stop trusting.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		return updateTrustedPeers(req, res, env, false)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(stringListEncoder),
+	},
+	Type: stringList{},
+}
+
+var trustListCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the trusted peers.",
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		peers, err := core.LoadTrustedPeers(n.Repo)
+		if err != nil {
+			return err
+		}
+		list := make([]string, len(peers))
+		for i, p := range peers {
+			list[i] = p.Pretty()
+		}
+		return cmds.EmitOnce(res, &stringList{list})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(stringListEncoder),
+	},
+	Type: stringList{},
+}
+
+// updateTrustedPeers adds the peers of the arguments of req to the trusted
+// peers, or removes them, in the config and in the running node.
+func updateTrustedPeers(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment, trust bool) error {
+	n, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]peer.ID, len(req.Arguments))
+	for i, arg := range req.Arguments {
+		if ids[i], err = peer.IDB58Decode(arg); err != nil {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid peer id %q: %s", arg, err)
+		}
+	}
+
+	peers, err := core.LoadTrustedPeers(n.Repo)
+	if err != nil {
+		return err
+	}
+	set := make(map[peer.ID]bool, len(peers))
+	for _, p := range peers {
+		set[p] = true
+	}
+	action := "trust "
+	if !trust {
+		action = "untrust "
+	}
+	output := make([]string, len(ids))
+	for i, id := range ids {
+		output[i] = action + id.Pretty() + " success"
+		if set[id] == trust {
+			continue
+		}
+		if trust {
+			peers = append(peers, id)
+		} else {
+			for j, p := range peers {
+				if p == id {
+					peers = append(peers[:j], peers[j+1:]...)
+					break
+				}
+			}
+		}
+		set[id] = trust
+	}
+	if err := core.StoreTrustedPeers(n.Repo, peers); err != nil {
+		return err
+	}
+
+	if n.Reputation != nil {
+		for _, id := range ids {
+			if trust {
+				n.Reputation.Trust(id)
+			} else {
+				n.Reputation.Untrust(id)
+			}
+		}
+	}
+	return cmds.EmitOnce(res, &stringList{output})
+}
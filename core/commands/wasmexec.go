@@ -0,0 +1,23 @@
+//go:build wasmexec
+// +build wasmexec
+
+package commands
+
+import (
+	"context"
+
+	"github.com/ipfs/go-ipfs/wasmexec"
+)
+
+// wasmExecBuilt tells whether the WASM runtime is built in ipfs.
+const wasmExecBuilt = true
+
+// compileWasmModule compiles the WASI command module bin, see
+// wasmexec.Compile.
+func compileWasmModule(ctx context.Context, bin []byte) (wasmModule, error) {
+	m, err := wasmexec.Compile(ctx, bin)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
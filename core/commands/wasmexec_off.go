@@ -0,0 +1,15 @@
+//go:build !wasmexec
+// +build !wasmexec
+
+package commands
+
+import (
+	"context"
+)
+
+// wasmExecBuilt is false: the WASM runtime needs the wasmexec build tag.
+const wasmExecBuilt = false
+
+func compileWasmModule(ctx context.Context, bin []byte) (wasmModule, error) {
+	return nil, errWasmExecNotBuilt
+}
@@ -0,0 +1,264 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cid "github.com/ipfs/go-cid"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	mfs "github.com/ipfs/go-mfs"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+)
+
+const (
+	watchIntervalOptionName = "interval"
+	watchDebounceOptionName = "debounce"
+)
+
+// WatchEvent is a CID of the watched path. Changed is false for the first
+// one, and Error is set when the path couldn't be resolved.
+type WatchEvent struct {
+	Cid     string
+	Changed bool
+	Error   string `json:",omitempty"`
+}
+
+var WatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run a command when the content of a path changes.",
+		ShortDescription: `
+'ipfs watch' resolves <path> every --interval, and runs <command> each time
+its CID changes, with the new CID in the IPFS_CID environment variable:
+
+    > ipfs watch /ipns/example.com -- sh -c 'ipfs pin add $IPFS_CID'
+
+Without <command>, the CIDs are printed.
+`,
+		LongDescription: `
+'ipfs watch' resolves <path> every --interval, and runs <command> each time
+its CID changes, with the new CID in the IPFS_CID environment variable:
+
+    > ipfs watch /ipns/example.com -- sh -c 'ipfs pin add $IPFS_CID'
+
+Without <command>, the CIDs are printed.
+
+<path> is an /ipns/ path, resolved without the cache of the name system, an
+MFS path like /docs, or an /ipfs/ path. The command is run once the CID has
+stopped changing for --debounce, and not while the previous run of the
+command is still going: the changes made meanwhile are merged in one run.
+A failing command doesn't stop the watch.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", true, false, "The IPNS, MFS or IPFS path to watch."),
+		cmdkit.StringArg("command", false, true, "The command to run, and its arguments, after '--'."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(watchIntervalOptionName, "Time between two resolutions of the path.").WithDefault("10s"),
+		cmdkit.StringOption(watchDebounceOptionName, "Time the CID must stay the same before the command is run.").WithDefault("0s"),
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		for _, name := range []string{watchIntervalOptionName, watchDebounceOptionName} {
+			s, _ := req.Options[name].(string)
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 || (d == 0 && name == watchIntervalOptionName) {
+				return cmdkit.Errorf(cmdkit.ErrClient, "invalid %s %q", name, s)
+			}
+		}
+		return nil
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		intervalStr, _ := req.Options[watchIntervalOptionName].(string)
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval <= 0 {
+			return cmdkit.Errorf(cmdkit.ErrClient, "invalid %s %q", watchIntervalOptionName, intervalStr)
+		}
+
+		path := req.Arguments[0]
+		last, err := watchResolve(req.Context, n, api, path)
+		if err != nil {
+			return err
+		}
+		if err := res.Emit(&WatchEvent{Cid: enc.Encode(last)}); err != nil {
+			return err
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-req.Context.Done():
+				return nil
+			}
+
+			c, err := watchResolve(req.Context, n, api, path)
+			if err != nil {
+				if req.Context.Err() != nil {
+					return nil
+				}
+				// the path may not be resolvable for a while, an IPNS
+				// record can't be found when the network is down
+				err = res.Emit(&WatchEvent{Cid: enc.Encode(last), Error: err.Error()})
+			} else if !c.Equals(last) {
+				last = c
+				err = res.Emit(&WatchEvent{Cid: enc.Encode(c), Changed: true})
+			}
+			if err != nil {
+				return err
+			}
+		}
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			req := res.Request()
+			if len(req.Arguments) < 2 {
+				return cmds.Copy(re, res)
+			}
+			command := req.Arguments[1:]
+			debounceStr, _ := req.Options[watchDebounceOptionName].(string)
+			debounce, err := time.ParseDuration(debounceStr)
+			if err != nil {
+				return err
+			}
+
+			events := make(chan *WatchEvent)
+			errs := make(chan error, 1)
+			go func() {
+				defer close(events)
+				for {
+					v, err := res.Next()
+					if err != nil {
+						if err != io.EOF {
+							errs <- err
+						}
+						return
+					}
+					ev, ok := v.(*WatchEvent)
+					if !ok {
+						errs <- e.TypeErr(ev, v)
+						return
+					}
+					events <- ev
+				}
+			}()
+
+			// pending is the CID the command is to be run with once the
+			// timer fires
+			var pending string
+			timer := time.NewTimer(0)
+			<-timer.C
+			for {
+				select {
+				case ev, ok := <-events:
+					if !ok {
+						select {
+						case err := <-errs:
+							return err
+						default:
+							return nil
+						}
+					}
+					switch {
+					case ev.Error != "":
+						fmt.Fprintf(os.Stderr, "watch: %s\n", ev.Error)
+					case ev.Changed:
+						pending = ev.Cid
+						timer.Stop()
+						select {
+						case <-timer.C:
+						default:
+						}
+						timer.Reset(debounce)
+					}
+				case <-timer.C:
+					if err := runWatchCommand(command, pending); err != nil {
+						fmt.Fprintf(os.Stderr, "watch: %s: %s\n", strings.Join(command, " "), err)
+					}
+				}
+			}
+		},
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *WatchEvent) error {
+			if out.Error != "" {
+				fmt.Fprintf(w, "error: %s\n", out.Error)
+				return nil
+			}
+			fmt.Fprintln(w, out.Cid)
+			return nil
+		}),
+	},
+	Type: WatchEvent{},
+}
+
+// watchResolve returns the CID of the IPNS, MFS or IPFS path p.
+func watchResolve(ctx context.Context, n *core.IpfsNode, api coreiface.CoreAPI, p string) (cid.Cid, error) {
+	switch {
+	case strings.HasPrefix(p, "/ipns/"):
+		// the cache would hide the updates until the record expires
+		rp, err := api.Name().Resolve(ctx, p, options.Name.Cache(false))
+		if err != nil {
+			return cid.Undef, err
+		}
+		resolved, err := api.ResolvePath(ctx, rp)
+		if err != nil {
+			return cid.Undef, err
+		}
+		return resolved.Cid(), nil
+	case strings.HasPrefix(p, "/ipfs/") || !strings.HasPrefix(p, "/"):
+		ip, err := coreiface.ParsePath(p)
+		if err != nil {
+			return cid.Undef, err
+		}
+		resolved, err := api.ResolvePath(ctx, ip)
+		if err != nil {
+			return cid.Undef, err
+		}
+		return resolved.Cid(), nil
+	default:
+		fsn, err := mfs.Lookup(n.FilesRoot, p)
+		if err != nil {
+			return cid.Undef, err
+		}
+		nd, err := fsn.GetNode()
+		if err != nil {
+			return cid.Undef, err
+		}
+		return nd.Cid(), nil
+	}
+}
+
+// runWatchCommand runs command with c in IPFS_CID, and waits for it.
+func runWatchCommand(command []string, c string) error {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), "IPFS_CID="+c)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
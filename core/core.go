@@ -21,14 +21,23 @@ import (
 	"time"
 
 	version "github.com/ipfs/go-ipfs"
+	accesscontrol "github.com/ipfs/go-ipfs/accesscontrol"
+	blockquota "github.com/ipfs/go-ipfs/blocks/blockquota"
 	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
+	bstrace "github.com/ipfs/go-ipfs/exchange/trace"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	ipnsrp "github.com/ipfs/go-ipfs/namesys/republisher"
+	netsim "github.com/ipfs/go-ipfs/netsim"
 	p2p "github.com/ipfs/go-ipfs/p2p"
 	pin "github.com/ipfs/go-ipfs/pin"
 	repo "github.com/ipfs/go-ipfs/repo"
+	reputation "github.com/ipfs/go-ipfs/reputation"
+	iroute "github.com/ipfs/go-ipfs/routing"
+	delegated "github.com/ipfs/go-ipfs/routing/delegated"
+	provcache "github.com/ipfs/go-ipfs/routing/provcache"
+	transfer "github.com/ipfs/go-ipfs/transfer"
 
 	bitswap "github.com/ipfs/go-bitswap"
 	bsnet "github.com/ipfs/go-bitswap/network"
@@ -38,6 +47,7 @@ import (
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	config "github.com/ipfs/go-ipfs-config"
 	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
 	nilrouting "github.com/ipfs/go-ipfs-routing/none"
 	u "github.com/ipfs/go-ipfs-util"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -48,6 +58,7 @@ import (
 	ft "github.com/ipfs/go-unixfs"
 	goprocess "github.com/jbenet/goprocess"
 	libp2p "github.com/libp2p/go-libp2p"
+	autonatc "github.com/libp2p/go-libp2p-autonat"
 	autonat "github.com/libp2p/go-libp2p-autonat-svc"
 	circuit "github.com/libp2p/go-libp2p-circuit"
 	connmgr "github.com/libp2p/go-libp2p-connmgr"
@@ -57,6 +68,7 @@ import (
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	dhtopts "github.com/libp2p/go-libp2p-kad-dht/opts"
 	metrics "github.com/libp2p/go-libp2p-metrics"
+	inet "github.com/libp2p/go-libp2p-net"
 	peer "github.com/libp2p/go-libp2p-peer"
 	pstore "github.com/libp2p/go-libp2p-peerstore"
 	pnet "github.com/libp2p/go-libp2p-pnet"
@@ -105,26 +117,34 @@ type IpfsNode struct {
 	PNetFingerprint []byte     // fingerprint of private network
 
 	// Services
-	Peerstore       pstore.Peerstore     // storage for other Peer instances
-	Blockstore      bstore.GCBlockstore  // the block store (lower level)
-	Filestore       *filestore.Filestore // the filestore blockstore
-	BaseBlocks      bstore.Blockstore    // the raw blockstore, no filestore wrapping
-	GCLocker        bstore.GCLocker      // the locker used to protect the blockstore during gc
-	Blocks          bserv.BlockService   // the block service, get/add blocks.
-	DAG             ipld.DAGService      // the merkle dag service, get/add objects.
-	Resolver        *resolver.Resolver   // the path resolution system
+	Peerstore       pstore.Peerstore       // storage for other Peer instances
+	Blockstore      bstore.GCBlockstore    // the block store (lower level)
+	Filestore       *filestore.Filestore   // the filestore blockstore
+	BaseBlocks      bstore.Blockstore      // the raw blockstore, no filestore wrapping
+	StorageQuota    *blockquota.Blockstore // the maximum size of the repo
+	GCLocker        bstore.GCLocker        // the locker used to protect the blockstore during gc
+	Blocks          bserv.BlockService     // the block service, get/add blocks.
+	DAG             ipld.DAGService        // the merkle dag service, get/add objects.
+	Resolver        *resolver.Resolver     // the path resolution system
 	Reporter        metrics.Reporter
 	Discovery       discovery.Service
 	FilesRoot       *mfs.Root
 	RecordValidator record.Validator
 
 	// Online
-	PeerHost     p2phost.Host        // the network host (server+client)
-	Bootstrapper io.Closer           // the periodic bootstrapper
-	Routing      routing.IpfsRouting // the routing system. recommend ipfs-dht
-	Exchange     exchange.Interface  // the block exchange + strategy (bitswap)
-	Namesys      namesys.NameSystem  // the name system, resolves paths to hashes
-	Reprovider   *rp.Reprovider      // the value reprovider system
+	PeerHost     p2phost.Host             // the network host (server+client)
+	Bootstrapper io.Closer                // the periodic bootstrapper
+	Routing      routing.IpfsRouting      // the routing system. recommend ipfs-dht
+	Exchange     exchange.Interface       // the block exchange + strategy (bitswap)
+	Namesys      namesys.NameSystem       // the name system, resolves paths to hashes
+	DNSLink      *namesys.DNSResolver     // the DNSLink resolver of the name system
+	Reprovider   *rp.Reprovider           // the value reprovider system
+	ProvCache    *provcache.Cache         // the persisted provider records, if enabled
+	Reputation   *reputation.Tracker      // the scores of the peers
+	AccessCtrl   *accesscontrol.List      // the peers the blocks are served to
+	Mirror       *transfer.MirrorProvider // serves the DAGs mirrored by the peers
+	BlockTracer  *bstrace.Recorder        // the sources of the blocks received
+	NetSim       *netsim.Shaper           // the network simulation, nil unless built with the netsim tag
 	IpnsRepub    *ipnsrp.Republisher
 
 	AutoNAT  *autonat.AutoNATService
@@ -136,6 +156,11 @@ type IpfsNode struct {
 	proc goprocess.Process
 	ctx  context.Context
 
+	natManager  p2pbhost.NATManager // the NAT port mapper, if enabled
+	autoNAT     *natDetector        // detects whether the node is reachable
+	onlineSince time.Time
+	offline     offlineMode // the pause of the network activity
+
 	// Flags
 	IsOnline bool // Online is set when networking is enabled.
 	IsDaemon bool // Daemon is set when running on a long-running daemon.
@@ -227,10 +252,15 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 	}
 	libp2pOpts = append(libp2pOpts, libp2p.ConnectionManager(connm))
 
-	libp2pOpts = append(libp2pOpts, makeSmuxTransportOption(mplex))
+	n.NetSim = newNetSim()
+	libp2pOpts = append(libp2pOpts, makeSmuxTransportOption(mplex, n.NetSim))
 
 	if !cfg.Swarm.DisableNatPortMap {
-		libp2pOpts = append(libp2pOpts, libp2p.NATPortMap())
+		// same as libp2p.NATPortMap, keeping the manager around for reporting
+		libp2pOpts = append(libp2pOpts, libp2p.NATManager(func(net inet.Network) p2pbhost.NATManager {
+			n.natManager = p2pbhost.NewNATManager(net)
+			return n.natManager
+		}))
 	}
 
 	// disable the default listen addrs
@@ -274,6 +304,14 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 
 	n.PeerHost = peerhost
 
+	if !cfg.Swarm.DisableRelay {
+		limits, err := LoadRelayClientLimits(n.Repo)
+		if err != nil {
+			return err
+		}
+		n.PeerHost.Network().Notify(newCircuitLimiter(limits))
+	}
+
 	if err := n.startOnlineServicesWithHost(ctx, routingOption, pubsub, ipnsps); err != nil {
 		return err
 	}
@@ -299,6 +337,12 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 	return n.Bootstrap(DefaultBootstrapConfig)
 }
 
+// hasBlock returns whether the block c is in the blockstore.
+func (n *IpfsNode) hasBlock(c cid.Cid) bool {
+	has, err := n.Blockstore.Has(c)
+	return err == nil && has
+}
+
 func constructConnMgr(cfg config.ConnMgr) (ifconnmgr.ConnManager, error) {
 	switch cfg.Type {
 	case "":
@@ -338,7 +382,18 @@ func (n *IpfsNode) startLateOnlineServices(ctx context.Context) error {
 	default:
 		return fmt.Errorf("unknown reprovider strategy '%s'", cfg.Reprovider.Strategy)
 	}
-	n.Reprovider = rp.NewReprovider(ctx, n.Routing, keyProvider)
+	n.Reprovider = rp.NewReprovider(ctx, n.contentRouting(), keyProvider)
+
+	filter, err := iroute.LoadAnnounceFilter(n.Repo)
+	if err != nil {
+		return err
+	}
+	if filter != nil {
+		filter.Pinner = n.Pinning
+		filter.PinTimes, _ = n.Pinning.(iroute.PinTimes)
+		filter.DAG = merkledag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+		n.Reprovider.SetFilter(filter.NewRun)
+	}
 
 	reproviderInterval := kReprovideFrequency
 	if cfg.Reprovider.Interval != "" {
@@ -352,9 +407,80 @@ func (n *IpfsNode) startLateOnlineServices(ctx context.Context) error {
 
 	go n.Reprovider.Run(reproviderInterval)
 
+	if n.ProvCache != nil && n.ProvCache.Len() > 0 {
+		go n.republishProviderCache(ctx)
+	}
+
+	return nil
+}
+
+const (
+	// the size and the TTL of the provider cache
+	provCacheSizeConfigKey = "Routing.ProviderCacheSize"
+	provCacheTTLConfigKey  = "Routing.ProviderCacheTTL"
+
+	// provCacheRepublishDelay leaves time to the node to connect to the
+	// network before the cached provider records are announced again.
+	provCacheRepublishDelay = 10 * time.Second
+)
+
+// setupProviderCache loads the provider records persisted by an earlier run,
+// unless the cache is disabled by a zero Routing.ProviderCacheSize.
+func (n *IpfsNode) setupProviderCache() error {
+	size := provcache.DefaultSize
+	if _, err := repo.ReadConfigKey(n.Repo, provCacheSizeConfigKey, &size); err != nil {
+		return err
+	}
+	if size < 0 {
+		return fmt.Errorf("invalid value for %s: %d, expected a number of records", provCacheSizeConfigKey, size)
+	}
+	if size == 0 {
+		return nil
+	}
+
+	ttl := provcache.DefaultTTL
+	var ttlStr string
+	if ok, err := repo.ReadConfigKey(n.Repo, provCacheTTLConfigKey, &ttlStr); err != nil {
+		return err
+	} else if ok {
+		d, err := time.ParseDuration(ttlStr)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid value for %s: %q, expected a duration", provCacheTTLConfigKey, ttlStr)
+		}
+		ttl = d
+	}
+
+	c, err := provcache.New(n.Repo.Datastore(), n.Identity, size, ttl)
+	if err != nil {
+		return fmt.Errorf("loading the provider cache: %s", err)
+	}
+	n.ProvCache = c
 	return nil
 }
 
+// contentRouting returns the content router announcements go through,
+// recording them in the provider cache.
+func (n *IpfsNode) contentRouting() routing.ContentRouting {
+	if n.ProvCache == nil {
+		return n.Routing
+	}
+	return n.ProvCache.Wrap(n.Routing)
+}
+
+func (n *IpfsNode) republishProviderCache(ctx context.Context) {
+	select {
+	case <-time.After(provCacheRepublishDelay):
+	case <-ctx.Done():
+		return
+	}
+
+	count, err := n.ProvCache.Republish(ctx, n.Routing)
+	if err != nil {
+		log.Warningf("republishing cached provider records: %s", err)
+	}
+	log.Infof("republished %d cached provider records", count)
+}
+
 func makeAddrsFactory(cfg config.Addresses) (p2pbhost.AddrsFactory, error) {
 	var annAddrs []ma.Multiaddr
 	for _, addr := range cfg.Announce {
@@ -401,7 +527,7 @@ func makeAddrsFactory(cfg config.Addresses) (p2pbhost.AddrsFactory, error) {
 	}, nil
 }
 
-func makeSmuxTransportOption(mplexExp bool) libp2p.Option {
+func makeSmuxTransportOption(mplexExp bool, shaper *netsim.Shaper) libp2p.Option {
 	const yamuxID = "/yamux/1.0.0"
 	const mplexID = "/mplex/6.7.0"
 
@@ -437,6 +563,9 @@ func makeSmuxTransportOption(mplexExp bool) libp2p.Option {
 			continue
 		}
 		delete(muxers, id)
+		if shaper != nil {
+			tpt = shaper.WrapMuxer(tpt)
+		}
 		opts = append(opts, libp2p.Muxer(id, tpt))
 	}
 
@@ -487,6 +616,12 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, routingOptio
 		n.AutoNAT = svc
 	}
 
+	n.autoNAT = &natDetector{
+		AutoNAT: autonatc.NewAutoNAT(ctx, n.PeerHost, nil),
+		host:    n.PeerHost,
+	}
+	n.onlineSince = time.Now()
+
 	if enablePubsub || enableIpnsps {
 		var service *pubsub.PubSub
 
@@ -542,8 +677,16 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, routingOptio
 	//    PSRouter case below.
 	// 3. Introduce some kind of service manager? (my personal favorite but
 	//    that requires a fair amount of work).
-	if dht, ok := n.Routing.(*dht.IpfsDHT); ok {
-		n.DHT = dht
+	switch r := n.Routing.(type) {
+	case *dht.IpfsDHT:
+		n.DHT = r
+	case rhelpers.Parallel:
+		// hybrid routing
+		for _, ri := range r.Routers {
+			if dht, ok := ri.(*dht.IpfsDHT); ok {
+				n.DHT = dht
+			}
+		}
 	}
 
 	if enableIpnsps {
@@ -569,9 +712,32 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, routingOptio
 		}
 	}
 
+	// everything below routes through the offline mode
+	n.Routing = &offlineModeRouting{IpfsRouting: n.Routing, mode: &n.offline}
+	n.PeerHost.Network().Notify(offlineModeNotifiee{&n.offline})
+
+	if err := n.setupProviderCache(); err != nil {
+		return err
+	}
+
 	// setup exchange service
-	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Routing)
+	if err := n.setupReputation(); err != nil {
+		return err
+	}
+	n.AccessCtrl, err = accesscontrol.Load(n.Repo.Datastore())
+	if err != nil {
+		return err
+	}
+	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.contentRouting())
+	bitswapNetwork = n.Reputation.WrapBitswapNetwork(bitswapNetwork, n.hasBlock)
+	// after the reputation, the blocks of the banned peers are not traced
+	n.BlockTracer = bstrace.NewRecorder()
+	bitswapNetwork = n.BlockTracer.WrapBitswapNetwork(bitswapNetwork)
+	bitswapNetwork = n.AccessCtrl.WrapBitswapNetwork(bitswapNetwork)
 	n.Exchange = bitswap.New(ctx, bitswapNetwork, n.Blockstore)
+	// the access lists apply to the mirrors as to bitswap
+	n.Mirror = transfer.NewMirrorProvider(ctx, n.PeerHost, n.Blockstore, n.AccessCtrl.Allowed)
+	n.Mirror.Start()
 
 	size, err := n.getCacheSize()
 	if err != nil {
@@ -579,7 +745,10 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, routingOptio
 	}
 
 	// setup name system
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size)
+	if err := n.setupDNSLinkResolver(); err != nil {
+		return err
+	}
+	n.Namesys = namesys.NewNameSystemWithDNS(n.Routing, n.Repo.Datastore(), size, n.DNSLink)
 
 	// setup ipns republishing
 	return n.setupIpnsRepublisher()
@@ -736,6 +905,21 @@ func (n *IpfsNode) Bootstrap(cfg BootstrapConfig) error {
 			return ps
 		}
 	}
+	if cfg.PreferredPeers == nil {
+		// refuse a broken bootstrap list or region right away, the later
+		// reads of the config only log the errors
+		if _, err := PreferredBootstrapPeers(n.Repo); err != nil {
+			return err
+		}
+		cfg.PreferredPeers = func() map[peer.ID]bool {
+			preferred, err := PreferredBootstrapPeers(n.Repo)
+			if err != nil {
+				log.Warningf("failed to load the bootstrap regions from config: %s", err)
+				return nil
+			}
+			return preferred
+		}
+	}
 
 	var err error
 	n.Bootstrapper, err = Bootstrap(n, cfg)
@@ -987,3 +1171,42 @@ type DiscoveryOption func(context.Context, p2phost.Host) (discovery.Service, err
 var DHTOption RoutingOption = constructDHTRouting
 var DHTClientOption RoutingOption = constructClientDHTRouting
 var NilRouterOption RoutingOption = nilrouting.ConstructNilRouting
+
+// DelegatedEndpointConfigKey is the URL of the delegated routing server.
+const DelegatedEndpointConfigKey = "Routing.DelegatedEndpoint"
+
+// DelegatedRoutingEndpoint returns the URL of the delegated routing server
+// configured in r.
+func DelegatedRoutingEndpoint(r repo.Repo) (string, error) {
+	endpoint := delegated.DefaultEndpoint
+	if _, err := repo.ReadConfigKey(r, DelegatedEndpointConfigKey, &endpoint); err != nil {
+		return "", err
+	}
+	if endpoint == "" {
+		return "", fmt.Errorf("invalid value for %s: expected a URL", DelegatedEndpointConfigKey)
+	}
+	return endpoint, nil
+}
+
+// DelegatedRoutingOption routes through the delegated routing server at
+// endpoint only.
+func DelegatedRoutingOption(endpoint string) RoutingOption {
+	return func(ctx context.Context, host p2phost.Host, dstore ds.Batching, validator record.Validator) (routing.IpfsRouting, error) {
+		return delegated.New(endpoint, validator), nil
+	}
+}
+
+// HybridRoutingOption queries both the DHT and the delegated routing server
+// at endpoint, and announces content on the DHT.
+func HybridRoutingOption(endpoint string) RoutingOption {
+	return func(ctx context.Context, host p2phost.Host, dstore ds.Batching, validator record.Validator) (routing.IpfsRouting, error) {
+		d, err := constructDHTRouting(ctx, host, dstore, validator)
+		if err != nil {
+			return nil, err
+		}
+		return rhelpers.Parallel{
+			Routers:   []routing.IpfsRouting{d, delegated.New(endpoint, validator)},
+			Validator: validator,
+		}, nil
+	}
+}
@@ -28,9 +28,27 @@ import (
 
 type UnixfsAPI CoreAPI
 
+// UnixfsAddExtra are the settings of the adds of go-ipfs the options of the
+// core API have no equivalent for.
+type UnixfsAddExtra struct {
+	// Checkpoint makes the add of a file resumable, recording its progress
+	// in it.
+	Checkpoint *coreunix.Checkpoint
+	// Metadata is the custom metadata attached to the file added.
+	Metadata []byte
+	// DedupEstimate, if not nil, receives the estimate of the data of the
+	// add already stored, and nothing is added.
+	DedupEstimate *coreunix.DedupEstimate
+}
+
 // Add builds a merkledag node from a reader, adds it to the blockstore,
 // and returns the key representing that node.
 func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options.UnixfsAddOption) (coreiface.ResolvedPath, error) {
+	return api.AddExtra(ctx, files, UnixfsAddExtra{}, opts...)
+}
+
+// AddExtra is Add, with the settings of extra.
+func (api *UnixfsAPI) AddExtra(ctx context.Context, files files.Node, extra UnixfsAddExtra, opts ...options.UnixfsAddOption) (coreiface.ResolvedPath, error) {
 	settings, prefix, err := options.UnixfsAddOptions(opts...)
 	if err != nil {
 		return nil, err
@@ -53,6 +71,12 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 		return nil, filestore.ErrFilestoreNotEnabled
 	}
 
+	// estimating the deduplication writes nothing, like hashing only
+	dedup := extra.DedupEstimate
+	if dedup != nil {
+		settings.OnlyHash = true
+	}
+
 	addblockstore := api.blockstore
 	if !(settings.FsCache || settings.NoCopy) {
 		addblockstore = bstore.NewGCBlockstore(api.baseBlocks, api.blockstore)
@@ -75,7 +99,10 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 	}
 
 	bserv := blockservice.New(addblockstore, exch) // hash security 001
-	dserv := dag.NewDAGService(bserv)
+	var dserv ipld.DAGService = dag.NewDAGService(bserv)
+	if dedup != nil {
+		dserv = coreunix.NewDedupDAG(dserv, api.blockstore, dedup)
+	}
 
 	fileAdder, err := coreunix.NewAdder(ctx, pinning, addblockstore, dserv)
 	if err != nil {
@@ -95,6 +122,8 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 	fileAdder.NoCopy = settings.NoCopy
 	fileAdder.Name = settings.StdinName
 	fileAdder.CidBuilder = prefix
+	fileAdder.Checkpoint = extra.Checkpoint
+	fileAdder.Metadata = extra.Metadata
 
 	switch settings.Layout {
 	case options.BalancedLayout:
@@ -4,20 +4,55 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"reflect"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	version "github.com/ipfs/go-ipfs"
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	repo "github.com/ipfs/go-ipfs/repo"
 
+	config "github.com/ipfs/go-ipfs-config"
 	options "github.com/ipfs/interface-go-ipfs-core/options"
 	id "github.com/libp2p/go-libp2p/p2p/protocol/identify"
 )
 
+// The config keys of the gateway options go-ipfs-config doesn't have.
+const (
+	// GatewayNoRedirectConfigKey is the config key of whether the gateway
+	// serves the directories with an index.html at their URL without a
+	// trailing slash, instead of redirecting to it.
+	GatewayNoRedirectConfigKey = "Gateway.NoRedirect"
+
+	// GatewayPublicGatewaysConfigKey is the config section of the options
+	// of the gateway for each hostname it is reached with.
+	GatewayPublicGatewaysConfigKey = "Gateway.PublicGateways"
+)
+
+// PublicGateway is the configuration of the gateway for the requests to a
+// hostname. The options not set are the ones of the gateway.
+type PublicGateway struct {
+	NoRedirect *bool `json:",omitempty"`
+
+	// UseSubdomains serves the content at <cid>.ipfs.<hostname>, see
+	// SubdomainOption.
+	UseSubdomains bool `json:",omitempty"`
+
+	// TLSCertFile and TLSKeyFile are the PEM files of the certificate
+	// served for <hostname> and its subdomains, see GatewayTLSConfig.
+	TLSCertFile string `json:",omitempty"`
+	TLSKeyFile  string `json:",omitempty"`
+}
+
 type GatewayConfig struct {
-	Headers      map[string][]string
-	Writable     bool
-	PathPrefixes []string
+	Headers        map[string][]string
+	Writable       bool
+	PathPrefixes   []string
+	NoRedirect     bool
+	PublicGateways map[string]PublicGateway
 }
 
 // A helper function to clean up a set of headers:
@@ -41,65 +76,200 @@ func cleanHeaderSet(headers []string) []string {
 }
 
 func GatewayOption(writable bool, paths ...string) ServeOption {
-	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
-		cfg, err := n.Repo.Config()
+	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		gr, err := NewGatewayReloader(n, writable)
 		if err != nil {
 			return nil, err
 		}
+		return gr.Option(paths...)(n, l, mux)
+	}
+}
 
-		api, err := coreapi.NewCoreAPI(n, options.Api.FetchBlocks(!cfg.Gateway.NoFetch))
-		if err != nil {
-			return nil, err
-		}
+// GatewayReloader is a gateway handler whose configuration can be replaced
+// while it is serving requests. Requests in flight keep using the
+// configuration they started with.
+type GatewayReloader struct {
+	node     *core.IpfsNode
+	writable bool
 
-		headers := make(map[string][]string, len(cfg.Gateway.HTTPHeaders))
-		for h, v := range cfg.Gateway.HTTPHeaders {
-			headers[http.CanonicalHeaderKey(h)] = v
-		}
+	handler atomic.Value // *gatewayHandler
 
-		// Hard-coded headers.
-		const ACAHeadersName = "Access-Control-Allow-Headers"
-		const ACEHeadersName = "Access-Control-Expose-Headers"
-		const ACAOriginName = "Access-Control-Allow-Origin"
-		const ACAMethodsName = "Access-Control-Allow-Methods"
+	mu  sync.Mutex // serializes reloads
+	cfg config.Gateway
+}
 
-		if _, ok := headers[ACAOriginName]; !ok {
-			// Default to *all*
-			headers[ACAOriginName] = []string{"*"}
-		}
-		if _, ok := headers[ACAMethodsName]; !ok {
-			// Default to GET
-			headers[ACAMethodsName] = []string{"GET"}
-		}
+// NewGatewayReloader constructs a GatewayReloader serving the gateway
+// configured in the node's repo config.
+func NewGatewayReloader(n *core.IpfsNode, writable bool) (*GatewayReloader, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := newGatewayHandlerFromConfig(n, cfg.Gateway, writable)
+	if err != nil {
+		return nil, err
+	}
 
-		headers[ACAHeadersName] = cleanHeaderSet(
-			append([]string{
-				"Content-Type",
-				"User-Agent",
-				"Range",
-				"X-Requested-With",
-			}, headers[ACAHeadersName]...))
-
-		headers[ACEHeadersName] = cleanHeaderSet(
-			append([]string{
-				"Content-Range",
-				"X-Chunked-Output",
-				"X-Stream-Output",
-			}, headers[ACEHeadersName]...))
-
-		gateway := newGatewayHandler(n, GatewayConfig{
-			Headers:      headers,
-			Writable:     writable,
-			PathPrefixes: cfg.Gateway.PathPrefixes,
-		}, api)
+	gr := &GatewayReloader{
+		node:     n,
+		writable: writable,
+		cfg:      cfg.Gateway,
+	}
+	gr.handler.Store(h)
+	return gr, nil
+}
 
+// Option returns a ServeOption mounting the gateway under the given paths.
+func (gr *GatewayReloader) Option(paths ...string) ServeOption {
+	return func(_ *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 		for _, p := range paths {
-			mux.Handle(p+"/", gateway)
+			mux.Handle(p+"/", gr)
 		}
 		return mux, nil
 	}
 }
 
+func (gr *GatewayReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gr.handler.Load().(*gatewayHandler).ServeHTTP(w, r)
+}
+
+// Reload rebuilds the gateway handler from cfg and swaps it in. It returns a
+// human readable description of what changed. Whether the gateway is
+// writable, its addresses and its root redirect are fixed at startup.
+func (gr *GatewayReloader) Reload(cfg config.Gateway) ([]string, error) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+
+	h, err := newGatewayHandlerFromConfig(gr.node, cfg, gr.writable)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := gatewayConfigChanges(gr.cfg, cfg)
+	gr.handler.Store(h)
+	gr.cfg = cfg
+
+	log.Infof("gateway config reloaded: %v", changes)
+	return changes, nil
+}
+
+// gatewayConfigChanges describes the differences between two gateway
+// configurations.
+func gatewayConfigChanges(before, after config.Gateway) []string {
+	var added, removed, updated []string
+	for h, v := range after.HTTPHeaders {
+		old, ok := before.HTTPHeaders[h]
+		switch {
+		case !ok:
+			added = append(added, h)
+		case !reflect.DeepEqual(old, v):
+			updated = append(updated, h)
+		}
+	}
+	for h := range before.HTTPHeaders {
+		if _, ok := after.HTTPHeaders[h]; !ok {
+			removed = append(removed, h)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(updated)
+
+	changes := []string{}
+	for _, h := range added {
+		changes = append(changes, "added header "+h)
+	}
+	for _, h := range removed {
+		changes = append(changes, "removed header "+h)
+	}
+	for _, h := range updated {
+		changes = append(changes, "updated header "+h)
+	}
+	if !reflect.DeepEqual(before.PathPrefixes, after.PathPrefixes) {
+		changes = append(changes, fmt.Sprintf("path prefixes %v -> %v", before.PathPrefixes, after.PathPrefixes))
+	}
+	if before.NoFetch != after.NoFetch {
+		changes = append(changes, fmt.Sprintf("NoFetch %t -> %t", before.NoFetch, after.NoFetch))
+	}
+	return changes
+}
+
+func newGatewayHandlerFromConfig(n *core.IpfsNode, cfg config.Gateway, writable bool) (*gatewayHandler, error) {
+	api, err := coreapi.NewCoreAPI(n, options.Api.FetchBlocks(!cfg.NoFetch))
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string][]string, len(cfg.HTTPHeaders))
+	for h, v := range cfg.HTTPHeaders {
+		headers[http.CanonicalHeaderKey(h)] = v
+	}
+
+	// Hard-coded headers.
+	const ACAHeadersName = "Access-Control-Allow-Headers"
+	const ACEHeadersName = "Access-Control-Expose-Headers"
+	const ACAOriginName = "Access-Control-Allow-Origin"
+	const ACAMethodsName = "Access-Control-Allow-Methods"
+
+	if _, ok := headers[ACAOriginName]; !ok {
+		// Default to *all*
+		headers[ACAOriginName] = []string{"*"}
+	}
+	if _, ok := headers[ACAMethodsName]; !ok {
+		// Default to GET
+		headers[ACAMethodsName] = []string{"GET"}
+	}
+
+	headers[ACAHeadersName] = cleanHeaderSet(
+		append([]string{
+			"Content-Type",
+			"User-Agent",
+			"Range",
+			"X-Requested-With",
+			"X-IPFS-No-Redirect",
+		}, headers[ACAHeadersName]...))
+
+	headers[ACEHeadersName] = cleanHeaderSet(
+		append([]string{
+			"Content-Range",
+			"X-Chunked-Output",
+			"X-Stream-Output",
+		}, headers[ACEHeadersName]...))
+
+	noRedirect, publicGateways, err := loadGatewayRedirectConfig(n.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGatewayHandler(n, GatewayConfig{
+		Headers:        headers,
+		Writable:       writable,
+		PathPrefixes:   cfg.PathPrefixes,
+		NoRedirect:     noRedirect,
+		PublicGateways: publicGateways,
+	}, api), nil
+}
+
+// loadGatewayRedirectConfig reads Gateway.NoRedirect and
+// Gateway.PublicGateways from the config of r.
+func loadGatewayRedirectConfig(r repo.Repo) (bool, map[string]PublicGateway, error) {
+	var noRedirect bool
+	if _, err := repo.ReadConfigKey(r, GatewayNoRedirectConfigKey, &noRedirect); err != nil {
+		return false, nil, err
+	}
+
+	var publicGateways map[string]PublicGateway
+	if ok, err := repo.ReadConfigKey(r, GatewayPublicGatewaysConfigKey, &publicGateways); err != nil || !ok {
+		return noRedirect, nil, err
+	}
+	gateways := make(map[string]PublicGateway, len(publicGateways))
+	for host, gw := range publicGateways {
+		gateways[strings.ToLower(host)] = gw
+	}
+	return noRedirect, gateways, nil
+}
+
 func VersionOption() ServeOption {
 	return func(_ *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 		mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
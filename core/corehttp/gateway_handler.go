@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	gopath "path"
@@ -25,6 +26,7 @@ import (
 	"github.com/ipfs/go-path/resolver"
 	ft "github.com/ipfs/go-unixfs"
 	"github.com/ipfs/go-unixfs/importer"
+	uio "github.com/ipfs/go-unixfs/io"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/libp2p/go-libp2p-routing"
 	"github.com/multiformats/go-multibase"
@@ -163,23 +165,55 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
-	dr, err := i.api.Unixfs().Get(ctx, resolvedPath)
+	// The immutable path and the CIDs of its segments, for the caches to
+	// invalidate the responses precisely.
+	roots, err := i.pathRoots(ctx, resolvedPath)
 	if err != nil {
-		webError(w, "ipfs cat "+escapedURLPath, err, http.StatusNotFound)
+		webError(w, "ipfs resolve -r "+escapedURLPath, err, http.StatusNotFound)
 		return
 	}
+	setPathHeaders := func() {
+		w.Header().Set("X-IPFS-Path", urlPath)
+		w.Header().Set("IPFS-Path", resolvedPath.String())
+		w.Header().Set("IPFS-Roots", strings.Join(roots, ","))
+	}
 
-	defer dr.Close()
+	// The IPLD encodings of the node are served instead of its unixfs
+	// content to the clients accepting them.
+	mediaType := ipldMediaType(r)
+	w.Header().Set("Vary", "Accept")
 
-	// Check etag send back to us
+	// Check etag send back to us, before fetching the content
 	etag := "\"" + resolvedPath.Cid().String() + "\""
-	if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-None-Match") == "W/"+etag {
+	if mediaType != "" {
+		etag = ipldETag(resolvedPath.Cid(), mediaType)
+	}
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		i.addUserHeaders(w)
+		setPathHeaders()
+		w.Header().Set("Etag", etag)
 		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
+	if mediaType != "" {
+		i.addUserHeaders(w)
+		setPathHeaders()
+		w.Header().Set("Etag", etag)
+		i.serveIPLD(w, r, resolvedPath, mediaType)
+		return
+	}
+
+	dr, err := i.api.Unixfs().Get(ctx, resolvedPath)
+	if err != nil {
+		webError(w, "ipfs cat "+escapedURLPath, err, http.StatusNotFound)
+		return
+	}
+
+	defer dr.Close()
+
 	i.addUserHeaders(w) // ok, _now_ write user's headers.
-	w.Header().Set("X-IPFS-Path", urlPath)
+	setPathHeaders()
 	w.Header().Set("Etag", etag)
 
 	// Suborigin header, sandboxes apps from each other in the browser (even
@@ -223,7 +257,7 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 
 	if f, ok := dr.(files.File); ok {
 		if strings.HasPrefix(urlPath, ipfsPathPrefix) {
-			w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+			w.Header().Set("Cache-Control", "public, immutable, max-age=31536000")
 
 			// set modtime to a really long time ago, since files are immutable and should stay cached
 			modtime = time.Unix(1, 0)
@@ -251,7 +285,7 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	case nil:
 		dirwithoutslash := urlPath[len(urlPath)-1] != '/'
 		goget := r.URL.Query().Get("go-get") == "1"
-		if dirwithoutslash && !goget {
+		if dirwithoutslash && !goget && !i.noRedirect(r) {
 			// See comment above where originalUrlPath is declared.
 			http.Redirect(w, r, originalUrlPath+"/", 302)
 			return
@@ -610,3 +644,50 @@ func getFilename(s string) string {
 	}
 	return gopath.Base(s)
 }
+
+// pathRoots returns the CIDs of the nodes p goes through, from its root to
+// the node it resolves to.
+func (i *gatewayHandler) pathRoots(ctx context.Context, p coreiface.ResolvedPath) ([]string, error) {
+	r := &resolver.Resolver{
+		DAG:         i.node.DAG,
+		ResolveOnce: uio.ResolveUnixfsOnce,
+	}
+	nodes, err := r.ResolvePathComponents(ctx, path.Path(p.String()))
+	if err != nil {
+		return nil, err
+	}
+	roots := make([]string, len(nodes))
+	for j, nd := range nodes {
+		roots[j] = nd.Cid().String()
+	}
+	return roots, nil
+}
+
+// noRedirect returns whether r must be served at its URL rather than
+// redirected: the client asks it with the X-IPFS-No-Redirect header, or the
+// gateway is configured so for the hostname of r or all of them.
+func (i *gatewayHandler) noRedirect(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get("X-IPFS-No-Redirect"), "true") {
+		return true
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if gw, ok := i.config.PublicGateways[strings.ToLower(host)]; ok && gw.NoRedirect != nil {
+		return *gw.NoRedirect
+	}
+	return i.config.NoRedirect
+}
+
+// etagMatch returns whether the If-None-Match header lists etag, weakly or
+// not, or is "*".
+func etagMatch(ifNoneMatch string, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
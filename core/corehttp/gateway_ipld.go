@@ -0,0 +1,111 @@
+package corehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	car "github.com/ipfs/go-ipfs/car"
+
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// The media types of the IPLD encodings of the resolved DAGs the gateway
+// serves instead of their unixfs content, when the client accepts them.
+const (
+	dagJSONMediaType = "application/vnd.ipld.dag-json"
+	dagCBORMediaType = "application/vnd.ipld.dag-cbor"
+	carMediaType     = "application/vnd.ipld.car"
+)
+
+// ipldMediaType returns the IPLD media type listed in the Accept header of
+// r, the first one if there are several, or "" for none.
+func ipldMediaType(r *http.Request) string {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case dagJSONMediaType, dagCBORMediaType, carMediaType:
+			return mt
+		}
+	}
+	return ""
+}
+
+// ipldETag is the ETag of the encoding in the media type mt of the node c,
+// which differs from the one of its unixfs content.
+func ipldETag(c cid.Cid, mt string) string {
+	return "\"" + c.String() + "." + strings.TrimPrefix(mt, "application/vnd.ipld.") + "\""
+}
+
+// serveIPLD writes the node p resolves to in the IPLD media type mt: the
+// dag-json or dag-cbor encoding of its block, or the CAR file of its DAG.
+func (i *gatewayHandler) serveIPLD(w http.ResponseWriter, r *http.Request, p coreiface.ResolvedPath, mt string) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", mt)
+	if strings.HasPrefix(p.String(), ipfsPathPrefix) {
+		w.Header().Set("Cache-Control", "public, immutable, max-age=31536000")
+	}
+
+	if mt == carMediaType {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.car\"", p.Cid()))
+		if r.Method == "HEAD" {
+			return
+		}
+		// the status is sent with the first block, so an error after
+		// it can only cut the file short
+		if err := car.Export(ctx, i.api.Dag(), p.Cid(), 1, w); err != nil {
+			log.Warningf("exporting %s as a CAR file: %s", p.Cid(), err)
+		}
+		return
+	}
+
+	nd, err := i.api.Dag().Get(ctx, p.Cid())
+	if err != nil {
+		webError(w, "ipfs dag get "+p.String(), err, http.StatusNotFound)
+		return
+	}
+	var data []byte
+	switch mt {
+	case dagJSONMediaType:
+		data, err = json.Marshal(nd)
+	case dagCBORMediaType:
+		data, err = dagCBOR(nd)
+	}
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	if r.Method == "HEAD" {
+		return
+	}
+	w.Write(data)
+}
+
+// dagCBOR returns the dag-cbor encoding of nd: its block if it already is
+// one, or its JSON form converted to dag-cbor.
+func dagCBOR(nd ipld.Node) ([]byte, error) {
+	if nd.Cid().Type() == cid.DagCBOR {
+		return nd.RawData(), nil
+	}
+	data, err := json.Marshal(nd)
+	if err != nil {
+		return nil, err
+	}
+	cn, err := cbor.FromJSON(bytes.NewReader(data), mh.SHA2_256, -1)
+	if err != nil {
+		return nil, err
+	}
+	return cn.RawData(), nil
+}
@@ -1,7 +1,9 @@
 package corehttp
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
@@ -11,6 +13,7 @@ import (
 	"time"
 
 	version "github.com/ipfs/go-ipfs"
+	car "github.com/ipfs/go-ipfs/car"
 	core "github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/core/coreapi"
 	namesys "github.com/ipfs/go-ipfs/namesys"
@@ -20,12 +23,14 @@ import (
 	syncds "github.com/ipfs/go-datastore/sync"
 	config "github.com/ipfs/go-ipfs-config"
 	files "github.com/ipfs/go-ipfs-files"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	path "github.com/ipfs/go-path"
 	iface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/ipfs/interface-go-ipfs-core/options"
 	nsopts "github.com/ipfs/interface-go-ipfs-core/options/namesys"
 	ci "github.com/libp2p/go-libp2p-crypto"
 	id "github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	mh "github.com/multiformats/go-multihash"
 )
 
 // `ipfs object new unixfs-dir`
@@ -566,6 +571,106 @@ func TestCacheControlImmutable(t *testing.T) {
 	}
 }
 
+func TestGatewayETag(t *testing.T) {
+	ts, api, ctx := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	k, err := api.Unixfs().Add(ctx, files.NewBytesFile([]byte("fnord")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := "\"" + k.Cid().String() + "\""
+
+	for i, test := range []struct {
+		ifNoneMatch string
+		status      int
+	}{
+		{"", http.StatusOK},
+		{etag, http.StatusNotModified},
+		{"W/" + etag, http.StatusNotModified},
+		{"\"QmOther\", " + etag, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+		{"\"QmOther\"", http.StatusOK},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+k.String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", test.ifNoneMatch)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != test.status {
+			t.Errorf("(%d) If-None-Match %q: got status %d, expected %d", i, test.ifNoneMatch, res.StatusCode, test.status)
+		}
+		if hdr := res.Header.Get("Etag"); hdr != etag {
+			t.Errorf("(%d) got Etag %q, expected %q", i, hdr, etag)
+		}
+		if test.status == http.StatusOK {
+			if hdr := res.Header.Get("Cache-Control"); hdr != "public, immutable, max-age=31536000" {
+				t.Errorf("(%d) unexpected Cache-Control: %q", i, hdr)
+			}
+		}
+	}
+}
+
+func TestGatewayPathHeaders(t *testing.T) {
+	ns := mockNamesys{}
+	ts, api, ctx := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"sub": files.NewMapDirectory(map[string]files.Node{
+			"file.txt": files.NewBytesFile([]byte("fnord")),
+		}),
+	})
+	k, err := api.Unixfs().Add(ctx, dir, options.Unixfs.Wrap(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := api.ResolvePath(ctx, iface.Join(k, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := api.ResolvePath(ctx, iface.Join(k, "sub/file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns["/ipns/example.net"] = path.FromString(k.String())
+
+	ipfsPath := k.String() + "/sub/file.txt"
+	roots := strings.Join([]string{k.Cid().String(), sub.Cid().String(), file.Cid().String()}, ",")
+	for _, urlPath := range []string{ipfsPath, "/ipns/example.net/sub/file.txt"} {
+		req, err := http.NewRequest("GET", ts.URL+urlPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: got status %d", urlPath, res.StatusCode)
+		}
+		if hdr := res.Header.Get("IPFS-Path"); hdr != ipfsPath {
+			t.Errorf("%s: got IPFS-Path %q, expected %q", urlPath, hdr, ipfsPath)
+		}
+		if hdr := res.Header.Get("IPFS-Roots"); hdr != roots {
+			t.Errorf("%s: got IPFS-Roots %q, expected %q", urlPath, hdr, roots)
+		}
+		if hdr := res.Header.Get("X-IPFS-Path"); hdr != urlPath {
+			t.Errorf("%s: got X-IPFS-Path %q", urlPath, hdr)
+		}
+	}
+}
+
 func TestGoGetSupport(t *testing.T) {
 	ts, _, _ := newTestServerAndNode(t, nil)
 	t.Logf("test server url: %s", ts.URL)
@@ -587,6 +692,151 @@ func TestGoGetSupport(t *testing.T) {
 	}
 }
 
+func TestNoRedirect(t *testing.T) {
+	ts, api, ctx := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"index.html": files.NewBytesFile([]byte("<html></html>")),
+	})
+	k, err := api.Unixfs().Add(ctx, dir, options.Unixfs.Wrap(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusFound},
+		{"true", http.StatusOK},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+k.String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.header != "" {
+			req.Header.Set("X-IPFS-No-Redirect", test.header)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != test.status {
+			t.Errorf("X-IPFS-No-Redirect %q: status is %d, expected %d", test.header, res.StatusCode, test.status)
+		}
+	}
+
+	yes, no := true, false
+	i := &gatewayHandler{config: GatewayConfig{
+		NoRedirect: true,
+		PublicGateways: map[string]PublicGateway{
+			"redirect.example.com":   {NoRedirect: &no},
+			"noredirect.example.com": {NoRedirect: &yes},
+			"default.example.com":    {},
+		},
+	}}
+	for host, noRedirect := range map[string]bool{
+		"redirect.example.com":       false,
+		"Redirect.Example.com:8080":  false,
+		"noredirect.example.com":     true,
+		"default.example.com":        true,
+		"other.example.com":          true,
+		"redirect.example.com.other": true,
+	} {
+		r := httptest.NewRequest("GET", k.String(), nil)
+		r.Host = host
+		if got := i.noRedirect(r); got != noRedirect {
+			t.Errorf("%s: noRedirect is %t, expected %t", host, got, noRedirect)
+		}
+	}
+}
+
+func TestGatewayIPLD(t *testing.T) {
+	ts, api, ctx := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	k, err := api.Unixfs().Add(ctx, files.NewBytesFile([]byte("fnord")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := api.Dag().Get(ctx, k.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileJSON, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := cbor.WrapObject(map[string]interface{}{"file": k.Cid()}, mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Dag().Add(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(p, accept, mediaType string) (*http.Response, []byte) {
+		req, err := http.NewRequest("GET", ts.URL+p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", accept)
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s as %s: status is %d: %s", p, accept, res.StatusCode, body)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != mediaType {
+			t.Fatalf("%s as %s: got Content-Type %s", p, accept, ct)
+		}
+		return res, body
+	}
+
+	res, body := get(k.String(), "application/vnd.ipld.dag-json", "application/vnd.ipld.dag-json")
+	if !bytes.Equal(body, fileJSON) {
+		t.Errorf("unexpected dag-json of a unixfs file: %s", body)
+	}
+	if etag := res.Header.Get("Etag"); etag != "\""+k.Cid().String()+".dag-json\"" {
+		t.Errorf("unexpected Etag %s", etag)
+	}
+
+	_, body = get("/ipfs/"+obj.Cid().String(), "application/vnd.ipld.dag-cbor", "application/vnd.ipld.dag-cbor")
+	if !bytes.Equal(body, obj.RawData()) {
+		t.Errorf("unexpected dag-cbor of a dag-cbor node: %x", body)
+	}
+
+	// a unixfs file converted to dag-cbor keeps its links
+	_, body = get(k.String(), "application/vnd.ipld.dag-cbor", "application/vnd.ipld.dag-cbor")
+	if _, err := cbor.Decode(body, mh.SHA2_256, -1); err != nil {
+		t.Errorf("invalid dag-cbor of a unixfs file: %s", err)
+	}
+
+	_, body = get("/ipfs/"+obj.Cid().String()+"/file", "text/html, application/vnd.ipld.car;version=1", "application/vnd.ipld.car")
+	cr, err := car.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cr.Header.Roots) != 1 || !cr.Header.Roots[0].Equals(k.Cid()) {
+		t.Fatalf("unexpected roots of the CAR file: %v", cr.Header.Roots)
+	}
+	blk, err := cr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blk.Cid().Equals(k.Cid()) {
+		t.Errorf("unexpected block %s in the CAR file", blk.Cid())
+	}
+}
+
 func TestVersion(t *testing.T) {
 	version.CurrentCommit = "theshortcommithash"
 
@@ -622,3 +872,55 @@ func TestVersion(t *testing.T) {
 		t.Fatalf("response doesn't contain protocol version:\n%s", s)
 	}
 }
+
+func TestGatewayReload(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw, err := NewGatewayReloader(n, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+
+	dh.Handler, err = makeHandler(n, ts.Listener, gw.Option("/ipfs", "/ipns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getHeader := func() string {
+		req, err := http.NewRequest("GET", ts.URL+emptyDir+"/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.Header.Get("X-Test")
+	}
+
+	if h := getHeader(); h != "" {
+		t.Fatalf("unexpected X-Test header before reload: %q", h)
+	}
+
+	changes, err := gw.Reload(config.Gateway{
+		HTTPHeaders: map[string][]string{"X-Test": {"reloaded"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0] != "added header X-Test" {
+		t.Fatalf("unexpected changes: %v", changes)
+	}
+
+	if h := getHeader(); h != "reloaded" {
+		t.Fatalf("expected X-Test header after reload, got %q", h)
+	}
+}
@@ -0,0 +1,84 @@
+package corehttp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+)
+
+// GatewayTLSConfig returns the TLS config of the gateway serving the
+// certificates of Gateway.PublicGateways, or nil if no public gateway has a
+// certificate. The relative paths of the certificates are relative to root,
+// the directory of the repo.
+func GatewayTLSConfig(r repo.Repo, root string) (*tls.Config, error) {
+	_, gateways, err := loadGatewayRedirectConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	return gatewayTLSConfig(gateways, root)
+}
+
+func gatewayTLSConfig(gateways map[string]PublicGateway, root string) (*tls.Config, error) {
+	gc := &gatewayCertificates{certs: make(map[string]*tls.Certificate)}
+	for host, gw := range gateways {
+		if gw.TLSCertFile == "" && gw.TLSKeyFile == "" {
+			continue
+		}
+		if gw.TLSCertFile == "" || gw.TLSKeyFile == "" {
+			return nil, fmt.Errorf("public gateway %s: TLSCertFile and TLSKeyFile go together", host)
+		}
+		cert, err := tls.LoadX509KeyPair(repoPath(root, gw.TLSCertFile), repoPath(root, gw.TLSKeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("public gateway %s: %s", host, err)
+		}
+		gc.certs[host] = &cert
+		gc.hosts = append(gc.hosts, host)
+	}
+	if len(gc.hosts) == 0 {
+		return nil, nil
+	}
+
+	sort.Strings(gc.hosts)
+	gc.fallback = gc.certs[gc.hosts[0]]
+	// the most specific hostname wins: a.example.com over example.com
+	sort.SliceStable(gc.hosts, func(i, j int) bool {
+		return len(gc.hosts[i]) > len(gc.hosts[j])
+	})
+	return &tls.Config{
+		GetCertificate: gc.certificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}, nil
+}
+
+func repoPath(root string, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(root, p)
+}
+
+// gatewayCertificates selects the certificate of the public gateway of the
+// hostname the clients ask for with SNI: the one of <hostname> for
+// <hostname> and its subdomains, such as <cid>.ipfs.<hostname>.
+type gatewayCertificates struct {
+	hosts    []string // the longest first
+	certs    map[string]*tls.Certificate
+	fallback *tls.Certificate
+}
+
+// certificate returns the certificate of the public gateway of the SNI of
+// hello, or the one of the first hostname in alphabetical order for the
+// clients without SNI or asking for another hostname.
+func (gc *gatewayCertificates) certificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	for _, host := range gc.hosts {
+		if name == host || strings.HasSuffix(name, "."+host) {
+			return gc.certs[host], nil
+		}
+	}
+	return gc.fallback, nil
+}
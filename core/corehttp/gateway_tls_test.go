@@ -0,0 +1,110 @@
+package corehttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate for names to
+// <name>.crt and <name>.key in dir.
+func writeTestCertificate(t *testing.T, dir string, name string, names ...string) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &sk.PublicKey, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGatewayTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestCertificate(t, dir, "dweb", "dweb.example.com", "*.ipfs.dweb.example.com", "*.ipns.dweb.example.com")
+	writeTestCertificate(t, dir, "example", "example.com")
+
+	conf, err := gatewayTLSConfig(map[string]PublicGateway{
+		"dweb.example.com":  {UseSubdomains: true, TLSCertFile: "dweb.crt", TLSKeyFile: "dweb.key"},
+		"example.com":       {TLSCertFile: filepath.Join(dir, "example.crt"), TLSKeyFile: filepath.Join(dir, "example.key")},
+		"plain.example.com": {},
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf == nil {
+		t.Fatal("no TLS config with certificates")
+	}
+
+	for _, test := range []struct {
+		sni    string
+		expect string
+	}{
+		{"dweb.example.com", "dweb.example.com"},
+		{"bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi.ipfs.dweb.example.com", "dweb.example.com"},
+		{"DWEB.example.com.", "dweb.example.com"},
+		{"example.com", "example.com"},
+		{"plain.example.com", "example.com"},
+		{"", "dweb.example.com"},
+		{"other.org", "dweb.example.com"},
+	} {
+		cert, err := conf.GetCertificate(&tls.ClientHelloInfo{ServerName: test.sni})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if leaf.Subject.CommonName != test.expect {
+			t.Errorf("SNI %q: expected the certificate of %s, got %s", test.sni, test.expect, leaf.Subject.CommonName)
+		}
+	}
+
+	conf, err = gatewayTLSConfig(map[string]PublicGateway{"example.com": {}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf != nil {
+		t.Fatal("TLS config without certificates")
+	}
+
+	_, err = gatewayTLSConfig(map[string]PublicGateway{"example.com": {TLSCertFile: "example.crt"}}, dir)
+	if err == nil {
+		t.Fatal("expected an error for a certificate without key")
+	}
+}
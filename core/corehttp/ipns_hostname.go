@@ -23,8 +23,10 @@ func IPNSHostnameOption() ServeOption {
 			ctx, cancel := context.WithCancel(n.Context())
 			defer cancel()
 
+			// the subdomain gateways already rewrote their requests
+			rewritten := r.Header.Get("X-Ipns-Original-Path") != ""
 			host := strings.SplitN(r.Host, ":", 2)[0]
-			if len(host) > 0 && isd.IsDomain(host) {
+			if !rewritten && len(host) > 0 && isd.IsDomain(host) {
 				name := "/ipns/" + host
 				_, err := n.Namesys.Resolve(ctx, name, nsopts.Depth(1))
 				if err == nil || err == namesys.ErrResolveRecursion {
@@ -0,0 +1,135 @@
+package corehttp
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	core "github.com/ipfs/go-ipfs/core"
+
+	cid "github.com/ipfs/go-cid"
+	mbase "github.com/multiformats/go-multibase"
+)
+
+// SubdomainOption serves the content of the public gateways with
+// UseSubdomains at <cid>.ipfs.<hostname> and <name>.ipns.<hostname>, rather
+// than at <hostname>/ipfs/<cid>, so that each root has its own origin in the
+// browsers. The requests to the paths of these gateways are redirected to
+// their subdomain, and the CIDs are redirected to their base32 CIDv1, since
+// the hostnames are case-insensitive.
+//
+// For HTTPS, the certificate of the gateway, see GatewayTLSConfig, or of its
+// reverse proxy must cover *.ipfs.<hostname> and *.ipns.<hostname>.
+func (gr *GatewayReloader) SubdomainOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		childMux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			gateways := gr.handler.Load().(*gatewayHandler).config.PublicGateways
+			if serveSubdomain(w, r, gateways) {
+				return
+			}
+			childMux.ServeHTTP(w, r)
+		})
+		return childMux, nil
+	}
+}
+
+// serveSubdomain rewrites r to the path of the content of its subdomain, or
+// redirects it. It returns true if it has replied to r.
+func serveSubdomain(w http.ResponseWriter, r *http.Request, gateways map[string]PublicGateway) bool {
+	host, port := r.Host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, ":"+p
+	}
+
+	// <hostname>/ipfs/<cid>/... is redirected to <cid>.ipfs.<hostname>/...
+	if gw, ok := gateways[strings.ToLower(host)]; ok && gw.UseSubdomains {
+		parts := strings.SplitN(r.URL.Path, "/", 4)
+		if len(parts) < 3 || (parts[1] != "ipfs" && parts[1] != "ipns") || parts[2] == "" {
+			return false
+		}
+		label, err := subdomainLabel(parts[1], parts[2])
+		if err != nil {
+			webError(w, "invalid subdomain gateway path", err, http.StatusBadRequest)
+			return true
+		}
+		if label == "" {
+			// a DNSLink name with dots can't be a label, it stays a path
+			return false
+		}
+		rest := "/"
+		if len(parts) == 4 {
+			rest += parts[3]
+		}
+		redirectSubdomain(w, r, label+"."+parts[1]+"."+host+port, rest)
+		return true
+	}
+
+	// <label>.<ns>.<hostname>, the label keeping its case for the CIDv0
+	// sent by the clients not lowering the hostnames
+	labels := strings.SplitN(host, ".", 3)
+	if len(labels) < 3 {
+		return false
+	}
+	labels[1], labels[2] = strings.ToLower(labels[1]), strings.ToLower(labels[2])
+	if labels[1] != "ipfs" && labels[1] != "ipns" {
+		return false
+	}
+	if gw, ok := gateways[labels[2]]; !ok || !gw.UseSubdomains {
+		return false
+	}
+	label, err := subdomainLabel(labels[1], labels[0])
+	if err != nil {
+		webError(w, "invalid subdomain", err, http.StatusBadRequest)
+		return true
+	}
+	if label != labels[0] {
+		redirectSubdomain(w, r, label+"."+labels[1]+"."+labels[2]+port, r.URL.Path)
+		return true
+	}
+
+	root := labels[0]
+	if c, err := cid.Decode(root); err == nil && labels[1] == "ipns" {
+		// the IPNS names are resolved from the peer IDs
+		root = c.Hash().B58String()
+	}
+
+	// The links and redirects of the gateway handler are made from the
+	// original path, as for the IPNS hostnames.
+	r.Header.Set("X-Ipns-Original-Path", r.URL.Path)
+	r.URL.Path = "/" + labels[1] + "/" + root + r.URL.Path
+	return false
+}
+
+// subdomainLabel returns the subdomain label of the root of the namespace ns:
+// the base32 CIDv1 of a CID, which is the only encoding of a CID that both
+// fits in a label and is case-insensitive. It returns "" for an IPNS name
+// that isn't a peer ID and contains dots.
+func subdomainLabel(ns string, root string) (string, error) {
+	c, err := cid.Decode(root)
+	if err != nil {
+		if ns == "ipns" {
+			if strings.Contains(root, ".") {
+				return "", nil
+			}
+			return strings.ToLower(root), nil
+		}
+		return "", err
+	}
+	return cid.NewCidV1(c.Type(), c.Hash()).StringOfBase(mbase.Base32)
+}
+
+func redirectSubdomain(w http.ResponseWriter, r *http.Request, host string, p string) {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     host,
+		Path:     p,
+		RawQuery: r.URL.RawQuery,
+	}
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
@@ -0,0 +1,107 @@
+package corehttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+	mbase "github.com/multiformats/go-multibase"
+)
+
+func TestSubdomainGateway(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := coreapi.NewCoreAPI(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gr, err := NewGatewayReloader(n, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr.handler.Load().(*gatewayHandler).config.PublicGateways = map[string]PublicGateway{
+		"dweb.example.com": {UseSubdomains: true},
+	}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener,
+		gr.SubdomainOption(),
+		IPNSHostnameOption(),
+		gr.Option("/ipfs", "/ipns"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"file.txt": files.NewBytesFile([]byte("fnord")),
+	})
+	k, err := api.Unixfs().Add(n.Context(), dir, options.Unixfs.Wrap(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v0 := k.Cid().String()
+	v1, err := cid.NewCidV1(cid.DagProtobuf, k.Cid().Hash()).StringOfBase(mbase.Base32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		host     string
+		path     string
+		status   int
+		location string
+		body     string
+	}{
+		// the paths are redirected to the subdomains, in base32 CIDv1
+		{"dweb.example.com", "/ipfs/" + v0 + "/file.txt?a=b", http.StatusMovedPermanently, "http://" + v1 + ".ipfs.dweb.example.com/file.txt?a=b", ""},
+		{"dweb.example.com:8080", "/ipfs/" + v1, http.StatusMovedPermanently, "http://" + v1 + ".ipfs.dweb.example.com:8080/", ""},
+		// the CIDv0 subdomains are redirected to CIDv1
+		{v0 + ".ipfs.dweb.example.com", "/file.txt", http.StatusMovedPermanently, "http://" + v1 + ".ipfs.dweb.example.com/file.txt", ""},
+		{v1 + ".ipfs.dweb.example.com", "/file.txt", http.StatusOK, "", "fnord"},
+		{"notacid.ipfs.dweb.example.com", "/", http.StatusBadRequest, "", ""},
+		// the other hostnames serve the paths
+		{"other.example.com", "/ipfs/" + v0 + "/file.txt", http.StatusOK, "", "fnord"},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = test.host
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the body of a redirect is closed by doWithoutRedirect
+		var body []byte
+		if test.location == "" {
+			body, err = ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		res.Body.Close()
+
+		if res.StatusCode != test.status {
+			t.Errorf("%s%s: status is %d, expected %d: %s", test.host, test.path, res.StatusCode, test.status, body)
+			continue
+		}
+		if loc := res.Header.Get("Location"); loc != test.location {
+			t.Errorf("%s%s: redirected to %q, expected %q", test.host, test.path, loc, test.location)
+		}
+		if test.body != "" && string(body) != test.body {
+			t.Errorf("%s%s: unexpected body %q", test.host, test.path, body)
+		}
+	}
+}
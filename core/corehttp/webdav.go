@@ -0,0 +1,481 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	gopath "path"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	mfs "github.com/ipfs/go-mfs"
+	ft "github.com/ipfs/go-unixfs"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	"golang.org/x/net/webdav"
+)
+
+// davModTime is the modification time of all the files served over WebDAV:
+// this version of UnixFS doesn't record the modification times.
+var davModTime = time.Unix(0, 0)
+
+var errDAVNotDirectory = errors.New("not a directory")
+
+// WebDAVOption serves MFS over WebDAV, so that it can be mounted as a
+// network drive. The paths under /ipfs/ are served read-only from IPFS
+// instead, and hide the MFS directory of this name.
+//
+// Like the API, the server has no authentication and refuses the requests
+// of the web pages of other origins. Unless remote, the requests must also
+// be for a loopback host, so that a web page can't reach the server by
+// pointing its own domain to 127.0.0.1.
+func WebDAVOption(remote bool) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		api, err := coreapi.NewCoreAPI(n)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle("/", davCheckHost(remote, &webdav.Handler{
+			FileSystem: &davFS{root: n.FilesRoot, api: api},
+			LockSystem: webdav.NewMemLS(),
+			Logger: func(r *http.Request, err error) {
+				if err != nil {
+					log.Debugf("webdav %s %s: %s", r.Method, r.URL.Path, err)
+				}
+			},
+		}))
+		return mux, nil
+	}
+}
+
+// davCheckHost serves the requests with next if they are for a loopback
+// host, or any host if remote, and have no Origin or Referer of another
+// host.
+func davCheckHost(remote bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !remote && !isLoopbackHost(r.Host) {
+			http.Error(w, "403 - Forbidden: not a loopback host", http.StatusForbidden)
+			return
+		}
+		for _, h := range []string{"Origin", "Referer"} {
+			v := r.Header.Get(h)
+			if v == "" {
+				continue
+			}
+			if u, err := url.Parse(v); err != nil || u.Host != r.Host {
+				http.Error(w, "403 - Forbidden: cross-origin request", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost returns whether host, with or without a port, is localhost
+// or a loopback IP.
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// davFS is the webdav.FileSystem of MFS, with IPFS mounted read-only at
+// /ipfs.
+type davFS struct {
+	root *mfs.Root
+	api  coreiface.CoreAPI
+}
+
+// davPath cleans name, and returns whether it is an IPFS path.
+func davPath(name string) (string, bool) {
+	name = gopath.Clean("/" + name)
+	return name, name == "/ipfs" || strings.HasPrefix(name, "/ipfs/")
+}
+
+func (fs *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	name, ipfs := davPath(name)
+	if ipfs {
+		return os.ErrPermission
+	}
+	if _, err := mfs.Lookup(fs.root, name); err == nil {
+		return os.ErrExist
+	}
+	return mfs.Mkdir(fs.root, name, mfs.MkdirOpts{Flush: true})
+}
+
+func (fs *davFS) RemoveAll(ctx context.Context, name string) error {
+	name, ipfs := davPath(name)
+	if ipfs || name == "/" {
+		return os.ErrPermission
+	}
+	dir, base := gopath.Split(name)
+	parent, err := mfs.Lookup(fs.root, dir)
+	if err != nil {
+		return err
+	}
+	pdir, ok := parent.(*mfs.Directory)
+	if !ok {
+		return os.ErrNotExist
+	}
+	if err := pdir.Unlink(base); err != nil {
+		return err
+	}
+	return pdir.Flush()
+}
+
+func (fs *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, oldIPFS := davPath(oldName)
+	newName, newIPFS := davPath(newName)
+	if oldIPFS || newIPFS || oldName == "/" {
+		return os.ErrPermission
+	}
+	if err := mfs.Mv(fs.root, oldName, newName); err != nil {
+		return err
+	}
+	return mfs.FlushPath(fs.root, "/")
+}
+
+func (fs *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	name, ipfs := davPath(name)
+	if ipfs {
+		return fs.statIPFS(ctx, name)
+	}
+	fsn, err := mfs.Lookup(fs.root, name)
+	if err != nil {
+		return nil, err
+	}
+	return mfsFileInfo(gopath.Base(name), fsn)
+}
+
+func (fs *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	name, ipfs := davPath(name)
+	if ipfs {
+		return fs.openIPFS(ctx, name, flag)
+	}
+
+	fsn, err := mfs.Lookup(fs.root, name)
+	switch {
+	case err == os.ErrNotExist && flag&os.O_CREATE != 0:
+		fsn, err = fs.create(name)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, os.ErrExist
+	}
+
+	info, err := mfsFileInfo(gopath.Base(name), fsn)
+	if err != nil {
+		return nil, err
+	}
+	write := flag&(os.O_WRONLY|os.O_RDWR) != 0
+
+	switch fsn := fsn.(type) {
+	case *mfs.Directory:
+		if write {
+			return nil, os.ErrPermission
+		}
+		return &davDir{info: info, list: func() ([]os.FileInfo, error) {
+			return mfsReaddir(ctx, fsn)
+		}}, nil
+	case *mfs.File:
+		fd, err := fsn.Open(mfs.Flags{Read: flag&os.O_WRONLY == 0, Write: write, Sync: true})
+		if err != nil {
+			return nil, err
+		}
+		if write && flag&os.O_TRUNC != 0 {
+			if err := fd.Truncate(0); err != nil {
+				fd.Close()
+				return nil, err
+			}
+		}
+		if flag&os.O_APPEND != 0 {
+			if _, err := fd.Seek(0, io.SeekEnd); err != nil {
+				fd.Close()
+				return nil, err
+			}
+		}
+		return &davMFSFile{FileDescriptor: fd, file: fsn, name: info.name, write: write}, nil
+	default:
+		return nil, os.ErrInvalid
+	}
+}
+
+// create adds an empty file at name to MFS, as 'ipfs files write --create'.
+func (fs *davFS) create(name string) (mfs.FSNode, error) {
+	dir, base := gopath.Split(name)
+	parent, err := mfs.Lookup(fs.root, dir)
+	if err != nil {
+		return nil, err
+	}
+	pdir, ok := parent.(*mfs.Directory)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	nd := dag.NodeWithData(ft.FilePBData(nil, 0))
+	nd.SetCidBuilder(pdir.GetCidBuilder())
+	if err := pdir.AddChild(base, nd); err != nil {
+		return nil, err
+	}
+	return pdir.Child(base)
+}
+
+func (fs *davFS) statIPFS(ctx context.Context, name string) (*davFileInfo, error) {
+	if name == "/ipfs" {
+		return &davFileInfo{name: "ipfs", dir: true, readOnly: true}, nil
+	}
+	p, err := coreiface.ParsePath(name)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	nd, err := fs.api.ResolveNode(ctx, p)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	return ipfsFileInfo(gopath.Base(name), nd)
+}
+
+func (fs *davFS) openIPFS(ctx context.Context, name string, flag int) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, os.ErrPermission
+	}
+	info, err := fs.statIPFS(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if name == "/ipfs" {
+		// the CIDs can't be listed
+		return &davDir{info: info, list: func() ([]os.FileInfo, error) {
+			return nil, nil
+		}}, nil
+	}
+
+	p, err := coreiface.ParsePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.dir {
+		return &davDir{info: info, list: func() ([]os.FileInfo, error) {
+			return fs.readdirIPFS(ctx, p)
+		}}, nil
+	}
+	nd, err := fs.api.Unixfs().Get(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := nd.(files.File)
+	if !ok {
+		return nil, os.ErrInvalid
+	}
+	return &davIPFSFile{File: f, info: info}, nil
+}
+
+func (fs *davFS) readdirIPFS(ctx context.Context, p coreiface.Path) ([]os.FileInfo, error) {
+	entries, err := fs.api.Unixfs().Ls(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	var infos []os.FileInfo
+	for e := range entries {
+		if e.Err != nil {
+			return nil, e.Err
+		}
+		infos = append(infos, &davFileInfo{
+			name:     e.Name,
+			size:     int64(e.Size),
+			dir:      e.Type == coreiface.TDirectory,
+			readOnly: true,
+			cid:      e.Cid,
+		})
+	}
+	return infos, nil
+}
+
+// mfsReaddir lists the MFS directory d.
+func mfsReaddir(ctx context.Context, d *mfs.Directory) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	err := d.ForEachEntry(ctx, func(l mfs.NodeListing) error {
+		c, err := cid.Decode(l.Hash)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, &davFileInfo{
+			name: l.Name,
+			size: l.Size,
+			dir:  l.Type == int(mfs.TDir),
+			cid:  c,
+		})
+		return nil
+	})
+	return infos, err
+}
+
+// davFileInfo is the os.FileInfo of a UnixFS file or directory.
+type davFileInfo struct {
+	name     string
+	size     int64
+	dir      bool
+	readOnly bool
+	cid      cid.Cid
+}
+
+func mfsFileInfo(name string, fsn mfs.FSNode) (*davFileInfo, error) {
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	info := &davFileInfo{name: name, cid: nd.Cid()}
+	switch fsn := fsn.(type) {
+	case *mfs.Directory:
+		info.dir = true
+	case *mfs.File:
+		if info.size, err = fsn.Size(); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+func ipfsFileInfo(name string, nd ipld.Node) (*davFileInfo, error) {
+	info := &davFileInfo{name: name, readOnly: true, cid: nd.Cid()}
+	switch nd := nd.(type) {
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return nil, os.ErrNotExist
+		}
+		switch fsn.Type() {
+		case ft.TDirectory, ft.THAMTShard:
+			info.dir = true
+		default:
+			info.size = int64(fsn.FileSize())
+		}
+	case *dag.RawNode:
+		info.size = int64(len(nd.RawData()))
+	default:
+		return nil, os.ErrNotExist
+	}
+	return info, nil
+}
+
+func (fi *davFileInfo) Name() string       { return fi.name }
+func (fi *davFileInfo) Size() int64        { return fi.size }
+func (fi *davFileInfo) ModTime() time.Time { return davModTime }
+func (fi *davFileInfo) IsDir() bool        { return fi.dir }
+func (fi *davFileInfo) Sys() interface{}   { return nil }
+
+func (fi *davFileInfo) Mode() os.FileMode {
+	mode := os.FileMode(0644)
+	if fi.readOnly {
+		mode = 0444
+	}
+	if fi.dir {
+		mode |= os.ModeDir | 0111
+	}
+	return mode
+}
+
+// ETag implements webdav.ETager: the content of a file changes with its CID,
+// not with its modification time.
+func (fi *davFileInfo) ETag(ctx context.Context) (string, error) {
+	if !fi.cid.Defined() {
+		return "", webdav.ErrNotImplemented
+	}
+	return "\"" + fi.cid.String() + "\"", nil
+}
+
+// davDir is an open directory, listed on the first Readdir.
+type davDir struct {
+	info    *davFileInfo
+	list    func() ([]os.FileInfo, error)
+	entries []os.FileInfo
+	listed  bool
+}
+
+func (d *davDir) Close() error                                 { return nil }
+func (d *davDir) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *davDir) Write(p []byte) (int, error)                  { return 0, os.ErrPermission }
+func (d *davDir) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (d *davDir) Stat() (os.FileInfo, error)                   { return d.info, nil }
+
+func (d *davDir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		entries, err := d.list()
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+// davMFSFile is an open MFS file.
+type davMFSFile struct {
+	mfs.FileDescriptor
+	file  *mfs.File
+	name  string
+	write bool
+}
+
+func (f *davMFSFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, errDAVNotDirectory
+}
+
+func (f *davMFSFile) Stat() (os.FileInfo, error) {
+	if f.write {
+		// the CID is only updated once the writes are flushed
+		if err := f.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return mfsFileInfo(f.name, f.file)
+}
+
+// davIPFSFile is an open IPFS file.
+type davIPFSFile struct {
+	files.File
+	info *davFileInfo
+}
+
+func (f *davIPFSFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *davIPFSFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, errDAVNotDirectory
+}
+
+func (f *davIPFSFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
@@ -0,0 +1,92 @@
+package corehttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mfs "github.com/ipfs/go-mfs"
+)
+
+func TestWebDAV(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, WebDAVOption(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, p string, body string, header map[string]string, status int) string {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+p, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			if k == "Host" {
+				req.Host = v
+				continue
+			}
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != status {
+			t.Fatalf("%s %s: status is %d, expected %d: %s", method, p, res.StatusCode, status, data)
+		}
+		return string(data)
+	}
+
+	// a page rebinding its domain, or of another origin, is refused
+	do("GET", "/", "", map[string]string{"Host": "evil.example.com"}, http.StatusForbidden)
+	do("GET", "/", "", map[string]string{"Origin": "http://evil.example.com"}, http.StatusForbidden)
+	do("PROPFIND", "/", "", map[string]string{"Depth": "0", "Referer": ts.URL + "/page"}, http.StatusMultiStatus)
+
+	do("MKCOL", "/dir", "", nil, http.StatusCreated)
+	do("PUT", "/dir/file.txt", "fnord", nil, http.StatusCreated)
+	if body := do("GET", "/dir/file.txt", "", nil, http.StatusOK); body != "fnord" {
+		t.Fatalf("unexpected content %q", body)
+	}
+
+	props := do("PROPFIND", "/dir/", "", map[string]string{"Depth": "1"}, http.StatusMultiStatus)
+	if !strings.Contains(props, "<D:href>/dir/file.txt</D:href>") || !strings.Contains(props, "<D:getcontentlength>5</D:getcontentlength>") {
+		t.Fatalf("file missing from the listing: %s", props)
+	}
+
+	do("MOVE", "/dir/file.txt", "", map[string]string{"Destination": ts.URL + "/dir/moved.txt"}, http.StatusCreated)
+	if _, err := mfs.Lookup(n.FilesRoot, "/dir/moved.txt"); err != nil {
+		t.Fatalf("file not moved in MFS: %s", err)
+	}
+
+	// the IPFS paths are read-only
+	nd, err := n.FilesRoot.GetDirectory().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := "/ipfs/" + nd.Cid().String()
+	if body := do("GET", root+"/dir/moved.txt", "", nil, http.StatusOK); body != "fnord" {
+		t.Fatalf("unexpected content %q", body)
+	}
+	do("PUT", root+"/dir/other.txt", "fnord", nil, http.StatusNotFound)
+	do("DELETE", root+"/dir", "", nil, http.StatusMethodNotAllowed)
+
+	do("DELETE", "/dir", "", nil, http.StatusNoContent)
+	if _, err := mfs.Lookup(n.FilesRoot, "/dir"); err == nil {
+		t.Fatal("directory not deleted from MFS")
+	}
+}
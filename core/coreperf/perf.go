@@ -0,0 +1,274 @@
+// Package coreperf runs the standard benchmarks of 'ipfs perf benchmark'.
+//
+// The suites work on synthetic random data, so that the results of two
+// versions or configurations can be compared. The blocks they add to the
+// repo are removed at the end.
+package coreperf
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	config "github.com/ipfs/go-ipfs-config"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	importer "github.com/ipfs/go-unixfs/importer"
+	uio "github.com/ipfs/go-unixfs/io"
+	ci "github.com/libp2p/go-libp2p-crypto"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+)
+
+// Operation is the measure of a step of a suite.
+type Operation struct {
+	Name     string
+	Bytes    uint64
+	Blocks   int `json:",omitempty"`
+	Duration time.Duration
+	// Throughput is in bytes per second.
+	Throughput float64
+}
+
+func measure(name string, bytes uint64, blocks int, start time.Time) Operation {
+	d := time.Since(start)
+	return Operation{
+		Name:       name,
+		Bytes:      bytes,
+		Blocks:     blocks,
+		Duration:   d,
+		Throughput: float64(bytes) / d.Seconds(),
+	}
+}
+
+// Suite is a benchmark workload.
+type Suite struct {
+	Name        string
+	Description string
+	// DefaultSize is the size of the synthetic data, in bytes.
+	DefaultSize uint64
+	// Online is set for the suites which need the network.
+	Online bool
+
+	run func(ctx context.Context, n *core.IpfsNode, size uint64) ([]Operation, error)
+}
+
+// Run runs the suite on n with size bytes of synthetic data. The garbage
+// collection of n waits for the end of the run, as the blocks added are not
+// pinned.
+func (s *Suite) Run(ctx context.Context, n *core.IpfsNode, size uint64) ([]Operation, error) {
+	defer n.Blockstore.PinLock().Unlock()
+	return s.run(ctx, n, size)
+}
+
+const (
+	mib = 1 << 20
+	gib = 1 << 30
+)
+
+// Suites are the benchmarks, by name.
+var Suites = []*Suite{
+	{
+		Name:        "add-cpu",
+		Description: "chunk and hash data without storing it",
+		DefaultSize: 256 * mib,
+		run:         runAddCPU,
+	},
+	{
+		Name:        "add-disk",
+		Description: "add data to the repo",
+		DefaultSize: 256 * mib,
+		run:         runAddDisk,
+	},
+	{
+		Name:        "bitswap-1g",
+		Description: "the get-network workload",
+		DefaultSize: gib,
+		Online:      true,
+		run:         runGetNetwork,
+	},
+	{
+		Name:        "dag-traversal",
+		Description: "walk a DAG of 4KiB blocks stored in the repo",
+		DefaultSize: 64 * mib,
+		run:         runDagTraversal,
+	},
+	{
+		Name:        "get-network",
+		Description: "fetch data from a second local node over bitswap",
+		DefaultSize: 256 * mib,
+		Online:      true,
+		run:         runGetNetwork,
+	},
+}
+
+// FindSuite returns the suite called name, nil if there is none.
+func FindSuite(name string) *Suite {
+	for _, s := range Suites {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// syntheticData returns size random bytes. They are different on each call
+// so that the blocks of a previous run are not deduplicated.
+func syntheticData(size uint64) io.Reader {
+	return io.LimitReader(mrand.New(mrand.NewSource(time.Now().UnixNano())), int64(size))
+}
+
+// add adds size bytes of synthetic data to ng, in blocks of chunkSize bytes.
+func add(ng ipld.DAGService, size uint64, chunkSize int64) (ipld.Node, Operation, error) {
+	start := time.Now()
+	nd, err := importer.BuildDagFromReader(ng, chunker.NewSizeSplitter(syntheticData(size), chunkSize))
+	if err != nil {
+		return nil, Operation{}, err
+	}
+	return nd, measure("add", size, 0, start), nil
+}
+
+// remove deletes the blocks of the DAG root from the repo of n.
+func remove(ctx context.Context, n *core.IpfsNode, root cid.Cid) error {
+	ng := offlineDAG(n)
+	set := cid.NewSet()
+	set.Add(root)
+	if err := dag.EnumerateChildren(ctx, dag.GetLinksWithDAG(ng), root, set.Visit); err != nil {
+		return err
+	}
+	return set.ForEach(n.Blockstore.DeleteBlock)
+}
+
+func offlineDAG(n *core.IpfsNode) ipld.DAGService {
+	return dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+}
+
+func runAddCPU(ctx context.Context, n *core.IpfsNode, size uint64) ([]Operation, error) {
+	bs := bstore.NewBlockstore(dsync.MutexWrap(ds.NewNullDatastore()))
+	_, op, err := add(dag.NewDAGService(bserv.New(bs, offline.Exchange(bs))), size, chunker.DefaultBlockSize)
+	if err != nil {
+		return nil, err
+	}
+	return []Operation{op}, nil
+}
+
+func runAddDisk(ctx context.Context, n *core.IpfsNode, size uint64) ([]Operation, error) {
+	nd, op, err := add(offlineDAG(n), size, chunker.DefaultBlockSize)
+	if err != nil {
+		return nil, err
+	}
+	return []Operation{op}, remove(ctx, n, nd.Cid())
+}
+
+func runDagTraversal(ctx context.Context, n *core.IpfsNode, size uint64) ([]Operation, error) {
+	ng := offlineDAG(n)
+	nd, addOp, err := add(ng, size, 4096)
+	if err != nil {
+		return nil, err
+	}
+	defer remove(ctx, n, nd.Cid())
+
+	start := time.Now()
+	var (
+		mu     sync.Mutex
+		bytes  uint64
+		blocks int
+	)
+	err = dag.EnumerateChildrenAsync(ctx, func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		bytes += uint64(len(nd.RawData()))
+		blocks++
+		mu.Unlock()
+		return nd.Links(), nil
+	}, nd.Cid(), cid.NewSet().Visit)
+	if err != nil {
+		return nil, err
+	}
+	return []Operation{addOp, measure("traverse", bytes, blocks, start)}, nil
+}
+
+func runGetNetwork(ctx context.Context, n *core.IpfsNode, size uint64) ([]Operation, error) {
+	if !n.IsOnline {
+		return nil, fmt.Errorf("the suite needs the node to be online")
+	}
+	nd, addOp, err := add(offlineDAG(n), size, chunker.DefaultBlockSize)
+	if err != nil {
+		return nil, err
+	}
+	defer remove(ctx, n, nd.Cid())
+
+	fetcher, err := newFetcher(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer fetcher.Close()
+	start := time.Now()
+	if err := fetcher.PeerHost.Connect(ctx, pstore.PeerInfo{ID: n.Identity, Addrs: n.PeerHost.Addrs()}); err != nil {
+		return nil, fmt.Errorf("the second node failed to connect: %s", err)
+	}
+	connectOp := measure("connect", 0, 0, start)
+
+	start = time.Now()
+	r, err := uio.NewDagReader(ctx, nd, dag.NewSession(ctx, fetcher.DAG))
+	if err != nil {
+		return nil, err
+	}
+	got, err := io.Copy(ioutil.Discard, r)
+	if err != nil {
+		return nil, err
+	}
+	return []Operation{addOp, connectOp, measure("fetch", uint64(got), 0, start)}, nil
+}
+
+// newFetcher starts the second node of the network suites. It listens on
+// the loopback interface and stores nothing, so that the blocks fetched are
+// only measured.
+func newFetcher(ctx context.Context) (*core.IpfsNode, error) {
+	sk, pk, err := ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	skb, err := sk.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var c config.Config
+	c.Identity.PeerID = id.Pretty()
+	c.Identity.PrivKey = base64.StdEncoding.EncodeToString(skb)
+	c.Addresses.Swarm = []string{"/ip4/127.0.0.1/tcp/0"}
+	return core.NewNode(ctx, &core.BuildCfg{
+		Online:  true,
+		Routing: core.NilRouterOption,
+		Repo: &repo.Mock{
+			C: c,
+			D: dsync.MutexWrap(ds.NewNullDatastore()),
+			K: keystore.NewMemKeystore(),
+		},
+	})
+}
@@ -0,0 +1,55 @@
+package coreperf
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+func countBlocks(t *testing.T, n *core.IpfsNode) int {
+	keys, err := n.Blockstore.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for range keys {
+		count++
+	}
+	return count
+}
+
+func TestSuites(t *testing.T) {
+	ctx := context.Background()
+	n, err := core.NewNode(ctx, &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	before := countBlocks(t, n)
+	for _, name := range []string{"add-cpu", "add-disk", "dag-traversal"} {
+		ops, err := FindSuite(name).Run(ctx, n, 1<<20)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		for _, op := range ops {
+			if op.Bytes == 0 || op.Duration <= 0 {
+				t.Fatalf("%s: unexpected operation %+v", name, op)
+			}
+		}
+		if name == "dag-traversal" && ops[1].Blocks < 256 {
+			t.Fatalf("expected more than 256 blocks of 4KiB to be traversed, got %d", ops[1].Blocks)
+		}
+	}
+	if after := countBlocks(t, n); after != before {
+		t.Fatalf("the blocks added were not removed: %d blocks, expected %d", after, before)
+	}
+
+	if _, err := FindSuite("get-network").Run(ctx, n, 1<<20); err == nil {
+		t.Fatal("get-network should fail on an offline node")
+	}
+	if FindSuite("unknown") != nil {
+		t.Fatal("unexpected suite")
+	}
+}
@@ -0,0 +1,131 @@
+package corerepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// GCScheduleConfigKey is the config key of the GC schedule.
+const GCScheduleConfigKey = "Datastore.GCSchedule"
+
+// gcScheduleAtFormat is the format of the time of day of GCSchedule.At.
+const gcScheduleAtFormat = "15:04"
+
+// GCSchedule is the schedule of the garbage collections run by the daemon.
+type GCSchedule struct {
+	// Interval is the time between two GCs, a Go duration.
+	Interval string
+	// At is the local time of day, as HH:MM, of the first GC. The GCs run
+	// every Interval after it.
+	At string `json:",omitempty"`
+	// MinFreed is the space, like "1GiB", a GC must be able to free to be
+	// run.
+	MinFreed string `json:",omitempty"`
+}
+
+// Validate checks the values of s.
+func (s *GCSchedule) Validate() error {
+	d, err := time.ParseDuration(s.Interval)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid GC interval %q", s.Interval)
+	}
+	if s.At != "" {
+		if _, err := time.Parse(gcScheduleAtFormat, s.At); err != nil {
+			return fmt.Errorf("invalid GC time %q, expected HH:MM", s.At)
+		}
+	}
+	if s.MinFreed != "" {
+		if _, err := humanize.ParseBytes(s.MinFreed); err != nil {
+			return fmt.Errorf("invalid GC minimum freed space %q", s.MinFreed)
+		}
+	}
+	return nil
+}
+
+// Next returns the time of the first GC scheduled after now.
+func (s *GCSchedule) Next(now time.Time) (time.Time, error) {
+	if err := s.Validate(); err != nil {
+		return time.Time{}, err
+	}
+	interval, _ := time.ParseDuration(s.Interval)
+	if s.At == "" {
+		return now.Add(interval), nil
+	}
+
+	at, _ := time.Parse(gcScheduleAtFormat, s.At)
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	// the runs of the previous day may still be before the next At
+	for next.Add(-interval).After(now) {
+		next = next.Add(-interval)
+	}
+	for !next.After(now) {
+		next = next.Add(interval)
+	}
+	return next, nil
+}
+
+// ReadGCSchedule returns the GC schedule in the config of r, or nil if there
+// is none.
+func ReadGCSchedule(r repo.Repo) (*GCSchedule, error) {
+	var s GCSchedule
+	if ok, err := repo.ReadConfigKey(r, GCScheduleConfigKey, &s); err != nil || !ok {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ScheduledGC runs the garbage collections of s, until ctx is done. A GC is
+// skipped when it would free less than s.MinFreed.
+func ScheduledGC(ctx context.Context, node *core.IpfsNode, s GCSchedule) error {
+	var minFreed uint64
+	if s.MinFreed != "" {
+		var err error
+		if minFreed, err = humanize.ParseBytes(s.MinFreed); err != nil {
+			return err
+		}
+	}
+
+	for {
+		next, err := s.Next(time.Now())
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+
+		if minFreed > 0 {
+			roots, err := BestEffortRoots(node.FilesRoot)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			freed, err := gc.Reclaimable(ctx, node.Blockstore, node.Pinning, roots)
+			if err != nil {
+				log.Errorf("estimating the space freed by the scheduled GC: %s", err)
+				continue
+			}
+			if freed < minFreed {
+				log.Infof("skipping the scheduled GC: it would free %s, less than %s",
+					humanize.IBytes(freed), s.MinFreed)
+				continue
+			}
+		}
+
+		log.Info("Starting the scheduled repo GC...")
+		if err := GarbageCollect(node, ctx); err != nil {
+			log.Errorf("scheduled GC: %s", err)
+			continue
+		}
+		log.Info("Scheduled repo GC done.")
+	}
+}
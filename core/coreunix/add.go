@@ -60,28 +60,32 @@ func NewAdder(ctx context.Context, p pin.Pinner, bs bstore.GCLocker, ds ipld.DAG
 
 // Adder holds the switches passed to the `add` command.
 type Adder struct {
-	ctx        context.Context
-	pinning    pin.Pinner
-	gcLocker   bstore.GCLocker
-	dagService ipld.DAGService
-	bufferedDS *ipld.BufferedDAG
-	Out        chan<- interface{}
-	Progress   bool
-	Hidden     bool
-	Pin        bool
-	Trickle    bool
-	RawLeaves  bool
-	Silent     bool
-	Wrap       bool
-	Name       string
-	NoCopy     bool
-	Chunker    string
-	root       ipld.Node
-	mroot      *mfs.Root
-	unlocker   bstore.Unlocker
-	tempRoot   cid.Cid
-	CidBuilder cid.Builder
-	liveNodes  uint64
+	ctx          context.Context
+	pinning      pin.Pinner
+	gcLocker     bstore.GCLocker
+	dagService   ipld.DAGService
+	bufferedDS   *ipld.BufferedDAG
+	Out          chan<- interface{}
+	Progress     bool
+	Hidden       bool
+	Pin          bool
+	Trickle      bool
+	RawLeaves    bool
+	Silent       bool
+	Wrap         bool
+	Name         string
+	NoCopy       bool
+	Chunker      string
+	Checkpoint   *Checkpoint // the progress of a resumable add
+	Metadata     []byte      // custom metadata attached to the file added
+	root         ipld.Node
+	mroot        *mfs.Root
+	unlocker     bstore.Unlocker
+	tempRoot     cid.Cid
+	CidBuilder   cid.Builder
+	liveNodes    uint64
+	checkpointed bool
+	metadataNode ipld.Node
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -121,6 +125,10 @@ func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
 		CidBuilder: adder.CidBuilder,
 	}
 
+	return adder.layout(params, chnk)
+}
+
+func (adder *Adder) layout(params ihelper.DagBuilderParams, chnk chunker.Splitter) (ipld.Node, error) {
 	db, err := params.New(chnk)
 	if err != nil {
 		return nil, err
@@ -132,6 +140,63 @@ func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
 	return balanced.Layout(db)
 }
 
+// addResumable is add, recording its progress in the checkpoint, and
+// resuming from it. input is the file being read by reader.
+func (adder *Adder) addResumable(reader io.Reader, input io.Seeker) (ipld.Node, error) {
+	if adder.checkpointed {
+		return nil, errors.New("resumable adds only support adding a single file")
+	}
+	adder.checkpointed = true
+
+	// the options changing the DAG
+	params := fmt.Sprintf("chunker=%s trickle=%t raw-leaves=%t nocopy=%t cid=%+v",
+		adder.Chunker, adder.Trickle, adder.RawLeaves, adder.NoCopy, adder.CidBuilder)
+	cp := adder.Checkpoint
+	if err := cp.resume(params); err != nil {
+		return nil, err
+	}
+	if len(cp.Chunks) > 0 {
+		log.Infof("resuming add at byte %d", cp.Offset)
+	}
+
+	chnk := &resumableSplitter{
+		ctx:    adder.ctx,
+		cp:     cp,
+		dserv:  adder.dagService,
+		reader: reader,
+		seeker: input,
+		newSplitter: func(r io.Reader) (chunker.Splitter, error) {
+			return chunker.FromString(r, adder.Chunker)
+		},
+	}
+	// check the chunker now rather than after replaying the checkpoint
+	if _, err := chunker.FromString(reader, adder.Chunker); err != nil {
+		return nil, err
+	}
+
+	defer adder.bufferedDS.Commit()
+
+	cd := &checkpointDAG{BufferedDAG: adder.bufferedDS, cp: cp}
+	nd, err := adder.layout(ihelper.DagBuilderParams{
+		Dagserv:    cd,
+		RawLeaves:  adder.RawLeaves,
+		Maxlinks:   ihelper.DefaultLinksPerBlock,
+		NoCopy:     adder.NoCopy,
+		CidBuilder: adder.CidBuilder,
+	}, chnk)
+	if err != nil {
+		// keep the progress made since the last write
+		if ferr := cd.flush(); ferr != nil {
+			log.Errorf("writing the checkpoint: %s", ferr)
+		}
+		return nil, err
+	}
+	if err := adder.bufferedDS.Commit(); err != nil {
+		return nil, err
+	}
+	return nd, nil
+}
+
 // RootNode returns the root node of the Added.
 func (adder *Adder) RootNode() (ipld.Node, error) {
 	// for memoizing
@@ -220,6 +285,13 @@ func (adder *Adder) Finalize() (ipld.Node, error) {
 
 		// Replace root with the first child
 		name = children[0]
+		if adder.metadataNode != nil {
+			// mfs can't load metadata nodes, the single file added is it
+			if err := mr.Close(); err != nil {
+				return nil, err
+			}
+			return adder.metadataNode, nil
+		}
 		root, err = rootdir.Child(name)
 		if err != nil {
 			return nil, err
@@ -251,6 +323,11 @@ func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) error {
 
 		for _, name := range names {
 			child, err := fsn.Child(name)
+			if err == mfs.ErrNotYetImplemented && adder.metadataNode != nil {
+				// the file added with custom metadata, mfs can't load
+				// metadata nodes
+				continue
+			}
 			if err != nil {
 				return err
 			}
@@ -417,11 +494,24 @@ func (adder *Adder) addFile(path string, file files.File) error {
 		}
 	}
 
-	dagnode, err := adder.add(reader)
+	var dagnode ipld.Node
+	var err error
+	if adder.Checkpoint != nil {
+		dagnode, err = adder.addResumable(reader, file)
+	} else {
+		dagnode, err = adder.add(reader)
+	}
 	if err != nil {
 		return err
 	}
 
+	if adder.Metadata != nil {
+		dagnode, err = adder.addMetadata(dagnode)
+		if err != nil {
+			return err
+		}
+	}
+
 	addFileInfo, ok := file.(files.FileInfo)
 	if ok {
 		if addFileInfo.AbsPath() == os.Stdin.Name() && adder.Name != "" {
@@ -433,8 +523,29 @@ func (adder *Adder) addFile(path string, file files.File) error {
 	return adder.addNode(dagnode, path)
 }
 
+// addMetadata wraps the file nd in a metadata node holding the custom
+// metadata of the add.
+func (adder *Adder) addMetadata(nd ipld.Node) (ipld.Node, error) {
+	if adder.metadataNode != nil {
+		return nil, errors.New("custom metadata can only be attached to a single file")
+	}
+
+	mdnode, metanode, err := newCustomMetadataNodes(nd, adder.Metadata, adder.CidBuilder)
+	if err != nil {
+		return nil, err
+	}
+	if err := adder.dagService.AddMany(adder.ctx, []ipld.Node{metanode, mdnode}); err != nil {
+		return nil, err
+	}
+	adder.metadataNode = mdnode
+	return mdnode, nil
+}
+
 func (adder *Adder) addDir(path string, dir files.Directory) error {
 	log.Infof("adding directory: %s", path)
+	if adder.Metadata != nil {
+		return errors.New("custom metadata can only be attached to a single file")
+	}
 
 	mr, err := adder.mfsRoot()
 	if err != nil {
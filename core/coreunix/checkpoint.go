@@ -0,0 +1,408 @@
+package coreunix
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	cid "github.com/ipfs/go-cid"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	posinfo "github.com/ipfs/go-ipfs-posinfo"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
+)
+
+// checkpointInterval is how much file data is added between two writes of
+// a checkpoint: each write flushes the blocks added, which are otherwise
+// written in batches.
+var checkpointInterval int64 = 8 << 20
+
+// A Checkpoint records the progress of the add of a file, so that an
+// interrupted add can resume where it stopped. Write is called every
+// checkpointInterval bytes of the file, and when the add fails, with the
+// chunks added since its last call.
+//
+// The partial DAG is the list of its leaves: the intermediate nodes are
+// rebuilt from them when resuming, only the input after the last leaf is
+// chunked. A stored checkpoint is a log of JSON lines, a header with the
+// add options followed by the leaves, in the order of the file:
+//
+//	{"Params":"chunker=size-262144 ..."}
+//	{"Cid":"Qm...","Size":262144}
+//
+// The leaves are appended to it, a torn last line being dropped when the
+// checkpoint is read.
+type Checkpoint struct {
+	// Params are the add options changing the DAG, a checkpoint can't be
+	// resumed with different ones.
+	Params string
+	Offset int64
+	Chunks []CheckpointChunk
+
+	// Write stores the chunks added since it was last called, it is not
+	// called when nothing was added.
+	Write func(*CheckpointUpdate) error
+
+	resumed int               // the chunks loaded from the stored checkpoint
+	pending []CheckpointChunk // the chunks added since the last write
+}
+
+// CheckpointUpdate are the chunks to append to a stored checkpoint. Params
+// is the header of the checkpoint, written first when it is empty.
+type CheckpointUpdate struct {
+	Params string
+	Chunks []CheckpointChunk
+}
+
+type checkpointHeader struct {
+	Params string
+}
+
+// CheckpointChunk is a leaf of the partial DAG of a checkpoint.
+type CheckpointChunk struct {
+	Cid  string
+	Size int
+}
+
+// ReadCheckpoint reads a stored checkpoint. An empty one starts the add
+// over.
+func ReadCheckpoint(r io.Reader) (*Checkpoint, error) {
+	cp, _, err := readCheckpoint(r)
+	return cp, err
+}
+
+// readCheckpoint reads a stored checkpoint, and the size of its valid part.
+func readCheckpoint(r io.Reader) (*Checkpoint, int64, error) {
+	cp := new(Checkpoint)
+	br := bufio.NewReader(r)
+	var size int64
+	for i := 0; ; i++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if i == 0 && len(line) > 0 {
+				return nil, 0, errors.New("invalid checkpoint: no header, remove it to start over")
+			}
+			// an interrupted write, or the end of the checkpoint
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if i == 0 {
+			var h checkpointHeader
+			if err := json.Unmarshal(line, &h); err != nil {
+				return nil, 0, fmt.Errorf("invalid checkpoint: %s", err)
+			}
+			cp.Params = h.Params
+		} else {
+			var ch CheckpointChunk
+			if err := json.Unmarshal(line, &ch); err != nil {
+				return nil, 0, fmt.Errorf("invalid checkpoint, line %d: %s", i+1, err)
+			}
+			cp.Chunks = append(cp.Chunks, ch)
+			cp.Offset += int64(ch.Size)
+		}
+		size += int64(len(line))
+	}
+	return cp, size, nil
+}
+
+// resume checks the checkpoint was written with params, and makes the add
+// replay its chunks.
+func (cp *Checkpoint) resume(params string) error {
+	if len(cp.Chunks) > 0 && cp.Params != params {
+		return fmt.Errorf("the checkpoint was written with different options (%s), remove it to start over", cp.Params)
+	}
+	cp.Params = params
+	cp.resumed = len(cp.Chunks)
+	return nil
+}
+
+// add records a chunk added since the last write.
+func (cp *Checkpoint) add(ch CheckpointChunk) {
+	cp.pending = append(cp.pending, ch)
+}
+
+// pendingBytes returns the size of the chunks added since the last write.
+func (cp *Checkpoint) pendingBytes() int64 {
+	var n int64
+	for _, ch := range cp.pending {
+		n += int64(ch.Size)
+	}
+	return n
+}
+
+// write stores the chunks added since the last write.
+func (cp *Checkpoint) write() error {
+	if len(cp.pending) == 0 {
+		return nil
+	}
+	if cp.Write == nil {
+		return errors.New("the checkpoint can't be written")
+	}
+	if err := cp.Write(&CheckpointUpdate{Params: cp.Params, Chunks: cp.pending}); err != nil {
+		return err
+	}
+
+	for _, ch := range cp.pending {
+		cp.Chunks = append(cp.Chunks, ch)
+		cp.Offset += int64(ch.Size)
+	}
+	cp.pending = nil
+	return nil
+}
+
+// CheckpointFile is a checkpoint stored in a file, by the client of the add:
+// the daemon only sends it the updates.
+type CheckpointFile struct {
+	path string
+	size int64 // the size of the valid part of the file
+	file *os.File
+}
+
+// OpenCheckpointFile opens the checkpoint stored at path, which is created
+// on the first update if it doesn't exist.
+func OpenCheckpointFile(path string) (*CheckpointFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	_, size, err := readCheckpoint(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return &CheckpointFile{path: path, size: size, file: f}, nil
+}
+
+// Append writes u at the end of the checkpoint.
+func (cf *CheckpointFile) Append(u *CheckpointUpdate) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if cf.size == 0 {
+		if err := enc.Encode(checkpointHeader{Params: u.Params}); err != nil {
+			return err
+		}
+	}
+	for _, ch := range u.Chunks {
+		if err := enc.Encode(ch); err != nil {
+			return err
+		}
+	}
+
+	// drop the torn line of an interrupted write
+	if err := cf.file.Truncate(cf.size); err != nil {
+		return err
+	}
+	if _, err := cf.file.WriteAt(buf.Bytes(), cf.size); err != nil {
+		return err
+	}
+	if err := cf.file.Sync(); err != nil {
+		return err
+	}
+	cf.size += int64(buf.Len())
+	return nil
+}
+
+// Close closes the checkpoint file, removing it if nothing was written in it.
+func (cf *CheckpointFile) Close() error {
+	if cf.file == nil {
+		return nil
+	}
+	if cf.size == 0 {
+		return cf.Remove()
+	}
+	err := cf.file.Close()
+	cf.file = nil
+	return err
+}
+
+// Remove removes the checkpoint file, once the add completed.
+func (cf *CheckpointFile) Remove() error {
+	if cf.file != nil {
+		cf.file.Close()
+		cf.file = nil
+	}
+	if err := os.Remove(cf.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// leafData returns the file data held by a leaf node.
+func leafData(nd ipld.Node) ([]byte, error) {
+	if fn, ok := nd.(*posinfo.FilestoreNode); ok {
+		nd = fn.Node
+	}
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return nd.RawData(), nil
+	case *dag.ProtoNode:
+		fsn, err := unixfs.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return nil, err
+		}
+		return fsn.Data(), nil
+	default:
+		return nil, fmt.Errorf("unexpected leaf node type %T", nd)
+	}
+}
+
+// resumableSplitter replays the chunks recorded in a checkpoint, taking
+// their data from the DAG service, then chunks the rest of the input.
+type resumableSplitter struct {
+	ctx    context.Context
+	cp     *Checkpoint
+	dserv  ipld.DAGService
+	reader io.Reader
+	seeker io.Seeker // the input, if it can be seeked past the replayed chunks
+	seeked bool
+
+	newSplitter func(io.Reader) (chunker.Splitter, error)
+	next        int
+	rest        chunker.Splitter
+}
+
+func (rs *resumableSplitter) Reader() io.Reader {
+	return rs.reader
+}
+
+func (rs *resumableSplitter) NextBytes() ([]byte, error) {
+	if rs.next < rs.cp.resumed {
+		return rs.replay()
+	}
+	if rs.rest == nil {
+		spl, err := rs.newSplitter(rs.reader)
+		if err != nil {
+			return nil, err
+		}
+		rs.rest = spl
+	}
+	return rs.rest.NextBytes()
+}
+
+func (rs *resumableSplitter) chunkData(i int) ([]byte, error) {
+	ch := rs.cp.Chunks[i]
+	c, err := cid.Decode(ch.Cid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %s", err)
+	}
+	nd, err := rs.dserv.Get(rs.ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("block %s of the checkpoint: %s, remove the checkpoint to start over", c, err)
+	}
+	data, err := leafData(nd)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != ch.Size {
+		return nil, fmt.Errorf("block %s of the checkpoint holds %d bytes, expected %d", c, len(data), ch.Size)
+	}
+	return data, nil
+}
+
+func (rs *resumableSplitter) replay() ([]byte, error) {
+	if rs.next == 0 && rs.seeker != nil {
+		// skip the input up to the last replayed chunk, which is still
+		// checked against the input
+		last := rs.cp.Chunks[rs.cp.resumed-1]
+		if _, err := rs.seeker.Seek(rs.cp.Offset-int64(last.Size), io.SeekCurrent); err != nil {
+			log.Debugf("seeking the input to the checkpoint offset: %s", err)
+		} else {
+			data, err := rs.chunkData(rs.cp.resumed - 1)
+			if err != nil {
+				return nil, err
+			}
+			if err := rs.skip(data); err != nil {
+				return nil, err
+			}
+			rs.seeked = true
+		}
+	}
+
+	data, err := rs.chunkData(rs.next)
+	if err != nil {
+		return nil, err
+	}
+	if !rs.seeked {
+		// the input can't be seeked, check it matches as we skip it
+		if err := rs.skip(data); err != nil {
+			return nil, err
+		}
+	}
+	rs.next++
+	return data, nil
+}
+
+// skip reads the next len(data) bytes of the input, which must be data.
+func (rs *resumableSplitter) skip(data []byte) error {
+	buf := make([]byte, len(data))
+	if _, err := io.ReadFull(rs.reader, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.New("the input is shorter than the checkpoint, remove it to start over")
+		}
+		return err
+	}
+	if !bytes.Equal(buf, data) {
+		return errors.New("the input differs from the checkpoint, remove it to start over")
+	}
+	return nil
+}
+
+// checkpointDAG records the leaves added through it in a checkpoint, after
+// committing them.
+type checkpointDAG struct {
+	*ipld.BufferedDAG
+	cp     *Checkpoint
+	leaves int
+}
+
+func (cd *checkpointDAG) Add(ctx context.Context, nd ipld.Node) error {
+	if err := cd.BufferedDAG.Add(ctx, nd); err != nil {
+		return err
+	}
+	if len(nd.Links()) > 0 {
+		return nil
+	}
+	data, err := leafData(nd)
+	if err != nil || len(data) == 0 {
+		// not a leaf of the file
+		return nil
+	}
+
+	cd.leaves++
+	if cd.leaves <= cd.cp.resumed {
+		// replayed
+		return nil
+	}
+
+	cd.cp.add(CheckpointChunk{Cid: nd.Cid().String(), Size: len(data)})
+	if cd.cp.pendingBytes() < checkpointInterval {
+		return nil
+	}
+	return cd.flush()
+}
+
+func (cd *checkpointDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	for _, nd := range nds {
+		if err := cd.Add(ctx, nd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes the leaves added to the checkpoint.
+func (cd *checkpointDAG) flush() error {
+	// the checkpoint must not reference blocks not written yet
+	if err := cd.BufferedDAG.Commit(); err != nil {
+		return err
+	}
+	return cd.cp.write()
+}
@@ -0,0 +1,198 @@
+package coreunix
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/core"
+
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("interrupted") }
+
+func TestAddResumable(t *testing.T) {
+	ctx := context.Background()
+	node, err := core.NewNode(ctx, &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ckpt := filepath.Join(dir, "add.ckpt")
+
+	readCkpt := func() (*Checkpoint, error) {
+		f, err := os.Open(ckpt)
+		if os.IsNotExist(err) {
+			return new(Checkpoint), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ReadCheckpoint(f)
+	}
+	// add adds file, resuming from the checkpoint file if resumable, as
+	// the add command does
+	add := func(resumable bool, file files.Node) (ipld.Node, error) {
+		adder, err := NewAdder(ctx, node.Pinning, node.Blockstore, node.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adder.Pin = false
+		adder.Silent = true
+		adder.Chunker = "size-1024"
+		if !resumable {
+			return adder.AddAllAndPin(file)
+		}
+
+		cp, err := readCkpt()
+		if err != nil {
+			return nil, err
+		}
+		cf, err := OpenCheckpointFile(ckpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cf.Close()
+		cp.Write = cf.Append
+		adder.Checkpoint = cp
+		nd, err := adder.AddAllAndPin(file)
+		if err != nil {
+			return nil, err
+		}
+		return nd, cf.Remove()
+	}
+	interrupt := func(data []byte) {
+		r := io.MultiReader(bytes.NewReader(data[:5500]), failingReader{})
+		if _, err := add(true, files.NewReaderFile(ioutil.NopCloser(r))); err == nil {
+			t.Fatal("expected the add to fail")
+		}
+		cp, err := readCkpt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cp.Chunks) == 0 || cp.Offset > 5500 {
+			t.Fatalf("unexpected checkpoint: %d chunks, offset %d", len(cp.Chunks), cp.Offset)
+		}
+	}
+
+	data := make([]byte, 10*1024+100)
+	rand.New(rand.NewSource(1)).Read(data)
+	expected, err := add(false, files.NewBytesFile(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the checkpoint is also written while adding
+	defer func(interval int64) { checkpointInterval = interval }(checkpointInterval)
+	checkpointInterval = 2048
+
+	for _, seekable := range []bool{false, true} {
+		interrupt(data)
+
+		var file files.Node = files.NewReaderFile(ioutil.NopCloser(bytes.NewReader(data)))
+		if seekable {
+			file = files.NewBytesFile(data)
+		}
+		nd, err := add(true, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !nd.Cid().Equals(expected.Cid()) {
+			t.Fatalf("seekable=%t: expected %s, got %s", seekable, expected.Cid(), nd.Cid())
+		}
+		if _, err := os.Stat(ckpt); !os.IsNotExist(err) {
+			t.Fatal("expected the checkpoint to be removed")
+		}
+	}
+
+	// a torn write is dropped
+	interrupt(data)
+	f, err := os.OpenFile(ckpt, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"Cid":"Qm`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	nd, err := add(true, files.NewBytesFile(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(expected.Cid()) {
+		t.Fatalf("expected %s after a torn write, got %s", expected.Cid(), nd.Cid())
+	}
+
+	// resuming with another file fails
+	interrupt(data)
+	other := append([]byte{}, data...)
+	other[10]++
+	_, err = add(true, files.NewReaderFile(ioutil.NopCloser(bytes.NewReader(other))))
+	if err == nil || !strings.Contains(err.Error(), "differs from the checkpoint") {
+		t.Fatalf("expected the input to differ from the checkpoint, got %v", err)
+	}
+
+	os.Remove(ckpt)
+
+	// a checkpoint written with other options is refused
+	interrupt(data)
+	adder, err := NewAdder(ctx, node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Silent = true
+	adder.Chunker = "size-2048"
+	adder.Checkpoint, err = readCkpt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adder.AddAllAndPin(files.NewBytesFile(data))
+	if err == nil || !strings.Contains(err.Error(), "different options") {
+		t.Fatalf("expected the options to differ from the checkpoint, got %v", err)
+	}
+}
+
+func TestReadCheckpoint(t *testing.T) {
+	for _, c := range []struct {
+		data   string
+		chunks int
+		err    string
+	}{
+		{"", 0, ""},
+		{`{"Params":"a"}` + "\n", 0, ""},
+		{`{"Params":"a"}` + "\n" + `{"Cid":"Qm1","Size":3}` + "\n" + `{"Cid":"Qm`, 1, ""},
+		{`{"Params":"a"`, 0, "no header"},
+		{"not a checkpoint\n", 0, "invalid checkpoint"},
+	} {
+		cp, err := ReadCheckpoint(strings.NewReader(c.data))
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: expected an error with %q, got %v", c.data, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", c.data, err)
+			continue
+		}
+		if len(cp.Chunks) != c.chunks {
+			t.Errorf("%q: expected %d chunks, got %d", c.data, c.chunks, len(cp.Chunks))
+		}
+	}
+}
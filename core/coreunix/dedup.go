@@ -0,0 +1,89 @@
+package coreunix
+
+import (
+	"context"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// DedupEstimate is how much of the data of an add is already stored.
+type DedupEstimate struct {
+	TotalBytes    uint64
+	NewBytes      uint64
+	ExistingBytes uint64
+}
+
+// Ratio returns the share of the data already stored, between 0 and 1.
+func (est *DedupEstimate) Ratio() float64 {
+	if est.TotalBytes == 0 {
+		return 0
+	}
+	return float64(est.ExistingBytes) / float64(est.TotalBytes)
+}
+
+// NewDedupDAG returns a DAG service adding to ds, that records in est
+// whether the leaves added through it are in bs. ds is usually not backed by
+// bs: nothing is written to bs.
+//
+// A leaf added twice counts as existing the second time, the same way it
+// would be deduplicated by the add.
+func NewDedupDAG(ds ipld.DAGService, bs bstore.Blockstore, est *DedupEstimate) ipld.DAGService {
+	return &dedupDAG{DAGService: ds, bs: bs, est: est, seen: cid.NewSet()}
+}
+
+type dedupDAG struct {
+	ipld.DAGService
+	bs  bstore.Blockstore
+	est *DedupEstimate
+
+	mu   sync.Mutex
+	seen *cid.Set
+}
+
+func (dd *dedupDAG) Add(ctx context.Context, nd ipld.Node) error {
+	if err := dd.count(nd); err != nil {
+		return err
+	}
+	return dd.DAGService.Add(ctx, nd)
+}
+
+func (dd *dedupDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	for _, nd := range nds {
+		if err := dd.count(nd); err != nil {
+			return err
+		}
+	}
+	return dd.DAGService.AddMany(ctx, nds)
+}
+
+func (dd *dedupDAG) count(nd ipld.Node) error {
+	if len(nd.Links()) > 0 {
+		return nil
+	}
+	data, err := leafData(nd)
+	if err != nil || len(data) == 0 {
+		// not a leaf of a file
+		return nil
+	}
+
+	existing, err := dd.bs.Has(nd.Cid())
+	if err != nil {
+		return err
+	}
+
+	dd.mu.Lock()
+	defer dd.mu.Unlock()
+	if !dd.seen.Visit(nd.Cid()) {
+		existing = true
+	}
+	dd.est.TotalBytes += uint64(len(data))
+	if existing {
+		dd.est.ExistingBytes += uint64(len(data))
+	} else {
+		dd.est.NewBytes += uint64(len(data))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package coreunix
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/core"
+
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	mdtest "github.com/ipfs/go-merkledag/test"
+)
+
+func TestDedupEstimate(t *testing.T) {
+	ctx := context.Background()
+	node, err := core.NewNode(ctx, &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 20*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	// a chunk repeated in the data is deduplicated too
+	copy(data[15*1024:16*1024], data[14*1024:15*1024])
+
+	add := func(dserv ipld.DAGService, data []byte) {
+		adder, err := NewAdder(ctx, node.Pinning, node.Blockstore, dserv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adder.Pin = false
+		adder.Silent = true
+		adder.Chunker = "size-1024"
+		if _, err := adder.AddAllAndPin(files.NewBytesFile(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(node.DAG, data[:10*1024])
+
+	est := new(DedupEstimate)
+	add(NewDedupDAG(mdtest.Mock(), node.Blockstore, est), data)
+	expected := DedupEstimate{
+		TotalBytes:    20 * 1024,
+		NewBytes:      9 * 1024,
+		ExistingBytes: 11 * 1024,
+	}
+	if *est != expected {
+		t.Fatalf("expected %+v, got %+v", expected, *est)
+	}
+	if r := est.Ratio(); r != 0.55 {
+		t.Fatalf("expected a ratio of 0.55, got %f", r)
+	}
+
+	// nothing was added
+	est = new(DedupEstimate)
+	add(NewDedupDAG(mdtest.Mock(), node.Blockstore, est), data)
+	if est.ExistingBytes != expected.ExistingBytes {
+		t.Fatalf("expected the estimate not to add blocks, got %+v", *est)
+	}
+}
@@ -1,8 +1,12 @@
 package coreunix
 
 import (
+	"context"
+	"errors"
+
 	cid "github.com/ipfs/go-cid"
 	core "github.com/ipfs/go-ipfs/core"
+	ipld "github.com/ipfs/go-ipld-format"
 	dag "github.com/ipfs/go-merkledag"
 	ft "github.com/ipfs/go-unixfs"
 )
@@ -55,3 +59,62 @@ func Metadata(n *core.IpfsNode, skey string) (*ft.Metadata, error) {
 
 	return ft.MetadataFromBytes(pbnd.Data())
 }
+
+// CustomMetadataLinkName is the name of the link from a metadata node to the
+// custom metadata of its file.
+const CustomMetadataLinkName = "metadata"
+
+// ErrNoCustomMetadata is returned by CustomMetadata for the nodes without
+// custom metadata.
+var ErrNoCustomMetadata = errors.New("no custom metadata")
+
+// newCustomMetadataNodes returns a metadata node wrapping the file nd, with
+// the custom metadata meta, and the block holding meta. The metadata node
+// links to the file first, so that it reads as the file.
+func newCustomMetadataNodes(nd ipld.Node, meta []byte, builder cid.Builder) (*dag.ProtoNode, ipld.Node, error) {
+	var metanode ipld.Node
+	if builder == nil {
+		metanode = dag.NewRawNode(meta)
+	} else {
+		raw, err := dag.NewRawNodeWPrefix(meta, builder)
+		if err != nil {
+			return nil, nil, err
+		}
+		metanode = raw
+	}
+
+	mdata, err := ft.BytesForMetadata(new(ft.Metadata))
+	if err != nil {
+		return nil, nil, err
+	}
+	mdnode := dag.NodeWithData(mdata)
+	mdnode.SetCidBuilder(builder)
+	if err := mdnode.AddNodeLink("file", nd); err != nil {
+		return nil, nil, err
+	}
+	if err := mdnode.AddNodeLink(CustomMetadataLinkName, metanode); err != nil {
+		return nil, nil, err
+	}
+	return mdnode, metanode, nil
+}
+
+// CustomMetadata returns the custom metadata attached to the file nd by 'ipfs
+// add --metadata'.
+func CustomMetadata(ctx context.Context, ng ipld.NodeGetter, nd ipld.Node) ([]byte, error) {
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, ErrNoCustomMetadata
+	}
+	if _, err := ft.MetadataFromBytes(pbnd.Data()); err != nil {
+		return nil, ErrNoCustomMetadata
+	}
+	l, err := pbnd.GetNodeLink(CustomMetadataLinkName)
+	if err != nil {
+		return nil, ErrNoCustomMetadata
+	}
+	metanode, err := l.GetNode(ctx, ng)
+	if err != nil {
+		return nil, err
+	}
+	return metanode.RawData(), nil
+}
@@ -19,6 +19,7 @@ import (
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	chunker "github.com/ipfs/go-ipfs-chunker"
 	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	files "github.com/ipfs/go-ipfs-files"
 	u "github.com/ipfs/go-ipfs-util"
 	ipld "github.com/ipfs/go-ipld-format"
 )
@@ -92,3 +93,67 @@ func TestMetadata(t *testing.T) {
 		t.Fatal("read incorrect data")
 	}
 }
+
+func TestCustomMetadata(t *testing.T) {
+	ctx := context.Background()
+	node, err := core.NewNode(ctx, &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 1000)
+	u.NewTimeSeededRand().Read(data)
+	meta := []byte(`{"key":"value"}`)
+
+	adder, err := NewAdder(ctx, node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Metadata = meta
+	nd, err := adder.AddAllAndPin(files.NewBytesFile(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := CustomMetadata(ctx, node.DAG, nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rec, meta) {
+		t.Fatalf("expected the metadata %s, got %s", meta, rec)
+	}
+
+	// the metadata node reads as the file
+	ndr, err := uio.NewDagReader(ctx, nd, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(ndr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("read incorrect data")
+	}
+
+	file, err := nd.Links()[0].GetNode(ctx, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CustomMetadata(ctx, node.DAG, file); err != ErrNoCustomMetadata {
+		t.Fatalf("expected no metadata on the file, got %v", err)
+	}
+
+	adder, err = NewAdder(ctx, node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Metadata = meta
+	_, err = adder.AddAllAndPin(files.NewMapDirectory(map[string]files.Node{
+		"a": files.NewBytesFile(data),
+		"b": files.NewBytesFile(data),
+	}))
+	if err == nil {
+		t.Fatal("expected attaching metadata to several files to fail")
+	}
+}
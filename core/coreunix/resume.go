@@ -0,0 +1,537 @@
+package coreunix
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
+	uio "github.com/ipfs/go-unixfs/io"
+)
+
+// ResumedFile is a file written by ResumeGet.
+type ResumedFile struct {
+	// Path is the path of the file, relative to the output path.
+	Path string
+	// Offset is how much of the data already written is kept, Size is the
+	// full size of the file.
+	Offset int64
+	Size   int64
+}
+
+// The archive of a resumed get is a tar archive, like the one 'ipfs get'
+// extracts, with the entries named after their path relative to the output
+// path, "." for the output path itself. The entry of a file holds its data
+// from the byte the local file is kept up to, preceded by the JSON list of
+// the leaves of the file, {"Cid": ..., "Size": ...} objects in the order of
+// the file, so that the data is checked by hashing it. The PAX records of
+// the entry give the offsets:
+//
+//	IPFS.resume.start   the byte the data starts at
+//	IPFS.resume.size    the size of the file
+//	IPFS.resume.leaves  the length of the list of leaves, "null" when the
+//	                    DAG can't be checked this way
+//
+// The data kept is only what the leaves fully written locally hold, so that
+// it can be checked without fetching them.
+const (
+	resumeStartRecord  = "IPFS.resume.start"
+	resumeSizeRecord   = "IPFS.resume.size"
+	resumeLeavesRecord = "IPFS.resume.leaves"
+)
+
+type resumeLeaf struct {
+	Cid  string
+	Size uint64
+}
+
+// LocalFiles returns the size of the regular files already written at path,
+// the output path of an interrupted get, by their slash separated path
+// relative to it. Symlinks are not followed.
+func LocalFiles(path string) (map[string]int64, error) {
+	local := make(map[string]int64)
+	err := filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if p == path && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		local[filepath.ToSlash(rel)] = fi.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return local, nil
+}
+
+// WriteResumeArchive writes to w the archive completing the local files,
+// as returned by LocalFiles, to the UnixFS DAG root. Only the leaves the
+// local files miss are fetched.
+func WriteResumeArchive(ctx context.Context, dserv ipld.DAGService, root ipld.Node, local map[string]int64, w io.Writer) error {
+	a := &resumeArchiver{ctx: ctx, dserv: dserv, local: local, tw: tar.NewWriter(w)}
+	if err := a.node(root, "."); err != nil {
+		return err
+	}
+	return a.tw.Close()
+}
+
+type resumeArchiver struct {
+	ctx   context.Context
+	dserv ipld.DAGService
+	local map[string]int64
+	tw    *tar.Writer
+}
+
+func (a *resumeArchiver) node(nd ipld.Node, rel string) error {
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return a.file(nd, rel, uint64(len(nd.RawData())))
+	case *dag.ProtoNode:
+		fsn, err := unixfs.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return err
+		}
+		switch fsn.Type() {
+		case unixfs.TDirectory, unixfs.THAMTShard:
+			return a.dir(nd, rel)
+		case unixfs.TSymlink:
+			return a.tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeSymlink,
+				Name:     rel,
+				Linkname: string(fsn.Data()),
+				Mode:     0777,
+				Format:   tar.FormatPAX,
+			})
+		case unixfs.TFile, unixfs.TRaw:
+			return a.file(nd, rel, fsn.FileSize())
+		default:
+			return fmt.Errorf("%s: unsupported UnixFS node type %s", nd.Cid(), fsn.Type())
+		}
+	default:
+		return fmt.Errorf("%s: unsupported node type %T", nd.Cid(), nd)
+	}
+}
+
+func (a *resumeArchiver) dir(nd ipld.Node, rel string) error {
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     rel,
+		Mode:     0755,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+
+	dir, err := uio.NewDirectoryFromNode(a.dserv, nd)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	return dir.ForEachLink(a.ctx, func(l *ipld.Link) error {
+		if l.Name == "" || l.Name == "." || l.Name == ".." || strings.Contains(l.Name, "/") {
+			return fmt.Errorf("%s: invalid directory entry %q", nd.Cid(), l.Name)
+		}
+		if seen[l.Name] {
+			return fmt.Errorf("%s: duplicate directory entry %q", nd.Cid(), l.Name)
+		}
+		seen[l.Name] = true
+		child, err := l.GetNode(a.ctx, a.dserv)
+		if err != nil {
+			return err
+		}
+		return a.node(child, gopath.Join(rel, l.Name))
+	})
+}
+
+func (a *resumeArchiver) file(nd ipld.Node, rel string, size uint64) error {
+	leaves, err := a.leaves(nd)
+	if err != nil {
+		return err
+	}
+	// keep the leaves fully written
+	var start uint64
+	if have, ok := a.local[rel]; ok && have >= 0 && uint64(have) <= size {
+		for _, l := range leaves {
+			if start+l.Size > uint64(have) {
+				break
+			}
+			start += l.Size
+		}
+	}
+
+	list, err := json.Marshal(leaves)
+	if err != nil {
+		return err
+	}
+	err = a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     rel,
+		Mode:     0644,
+		Size:     int64(len(list)) + int64(size-start),
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			resumeStartRecord:  strconv.FormatUint(start, 10),
+			resumeSizeRecord:   strconv.FormatUint(size, 10),
+			resumeLeavesRecord: strconv.Itoa(len(list)),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := a.tw.Write(list); err != nil {
+		return err
+	}
+
+	dr, err := uio.NewDagReader(a.ctx, nd, a.dserv)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+	if _, err := dr.Seek(int64(start), io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(a.tw, dr)
+	return err
+}
+
+// leaves returns the leaves of the file nd, or nil if its DAG holds data
+// outside of leaves the local data can be checked against.
+//
+// The leaves are told apart from the intermediate nodes without fetching
+// them: raw leaves by their CID, the UnixFS ones by their size, which only
+// depends on the size of their data.
+func (a *resumeArchiver) leaves(nd ipld.Node) ([]resumeLeaf, error) {
+	leaves := []resumeLeaf{}
+	ok, err := a.collectLeaves(nd, &leaves)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+func (a *resumeArchiver) collectLeaves(nd ipld.Node, leaves *[]resumeLeaf) (bool, error) {
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		*leaves = append(*leaves, resumeLeaf{Cid: nd.Cid().String(), Size: uint64(len(nd.RawData()))})
+		return true, nil
+	case *dag.ProtoNode:
+		fsn, err := unixfs.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return false, err
+		}
+		if len(nd.Links()) == 0 {
+			data := fsn.Data()
+			if len(data) == 0 {
+				return true, nil
+			}
+			if !leafMatches(nd.Cid(), data) {
+				return false, nil
+			}
+			*leaves = append(*leaves, resumeLeaf{Cid: nd.Cid().String(), Size: uint64(len(data))})
+			return true, nil
+		}
+		if len(fsn.Data()) > 0 || fsn.NumChildren() != len(nd.Links()) {
+			return false, nil
+		}
+
+		for i, l := range nd.Links() {
+			size := fsn.BlockSize(i)
+			if l.Cid.Type() == cid.Raw || (l.Cid.Type() == cid.DagProtobuf && size > 0 && l.Size == protoLeafSize(size)) {
+				*leaves = append(*leaves, resumeLeaf{Cid: l.Cid.String(), Size: size})
+				continue
+			}
+			child, err := l.GetNode(a.ctx, a.dserv)
+			if err != nil {
+				return false, err
+			}
+			if ok, err := a.collectLeaves(child, leaves); err != nil || !ok {
+				return ok, err
+			}
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// protoLeafSize returns the size of the block of a UnixFS leaf holding size
+// bytes: the leaf message holds its type, the data and the size of the data.
+func protoLeafSize(size uint64) uint64 {
+	msg := 2 + 1 + uvarintLen(size) + size + 1 + uvarintLen(size)
+	return 1 + uvarintLen(msg) + msg
+}
+
+func uvarintLen(v uint64) uint64 {
+	var buf [binary.MaxVarintLen64]byte
+	return uint64(binary.PutUvarint(buf[:], v))
+}
+
+// ExtractResumeArchive writes the archive written by WriteResumeArchive to
+// path, completing the files of the interrupted get written there. The data
+// kept is checked against the DAG first, and the whole file once completed.
+// progress, if not nil, is called before each file is written.
+//
+// The entries are only written inside path: the parent of an entry must be
+// a directory of the archive, which also keeps the symlinks of the archive
+// from being followed, and the local files found are never followed when
+// they are symlinks.
+func ExtractResumeArchive(r io.Reader, path string, progress func(ResumedFile)) error {
+	e := &resumeExtractor{
+		path:     path,
+		progress: progress,
+		entries:  make(map[string]byte),
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.entry(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+type resumeExtractor struct {
+	path     string
+	progress func(ResumedFile)
+	entries  map[string]byte // the type of the entries extracted
+}
+
+func (e *resumeExtractor) entry(hdr *tar.Header, tr *tar.Reader) error {
+	name := hdr.Name
+	if len(e.entries) == 0 {
+		if name != "." {
+			return fmt.Errorf("invalid archive: the first entry is %q, expected the output path", name)
+		}
+	} else {
+		if name == "." || gopath.Clean(name) != name || gopath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid archive: entry %q is outside of the output path", name)
+		}
+		if _, ok := e.entries[name]; ok {
+			return fmt.Errorf("invalid archive: duplicate entry %q", name)
+		}
+		if e.entries[gopath.Dir(name)] != tar.TypeDir {
+			return fmt.Errorf("invalid archive: the parent of entry %q is not a directory of the archive", name)
+		}
+	}
+	e.entries[name] = hdr.Typeflag
+
+	target := filepath.Join(e.path, filepath.FromSlash(name))
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return e.dir(target)
+	case tar.TypeSymlink:
+		return e.symlink(hdr.Linkname, target)
+	case tar.TypeReg:
+		return e.file(hdr, tr, name, target)
+	default:
+		return fmt.Errorf("invalid archive: entry %q has the unsupported type %q", name, hdr.Typeflag)
+	}
+}
+
+func (e *resumeExtractor) dir(target string) error {
+	if err := os.Mkdir(target, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	fi, err := os.Lstat(target)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory, remove it to start over", target)
+	}
+	return nil
+}
+
+func (e *resumeExtractor) symlink(link, target string) error {
+	fi, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return os.Symlink(link, target)
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("%s is not a symlink, remove it to start over", target)
+	}
+	cur, err := os.Readlink(target)
+	if err != nil {
+		return err
+	}
+	if cur != link {
+		return fmt.Errorf("%s links to %s instead of %s, remove it to start over", target, cur, link)
+	}
+	return nil
+}
+
+func (e *resumeExtractor) file(hdr *tar.Header, tr *tar.Reader, name, target string) error {
+	start, err := strconv.ParseInt(hdr.PAXRecords[resumeStartRecord], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid archive: entry %q: %s", name, err)
+	}
+	size, err := strconv.ParseInt(hdr.PAXRecords[resumeSizeRecord], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid archive: entry %q: %s", name, err)
+	}
+	listLen, err := strconv.ParseInt(hdr.PAXRecords[resumeLeavesRecord], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid archive: entry %q: %s", name, err)
+	}
+	if start < 0 || start > size || listLen < 0 || hdr.Size != listLen+size-start {
+		return fmt.Errorf("invalid archive: entry %q has inconsistent sizes", name)
+	}
+	var leaves []resumeLeaf
+	if err := json.NewDecoder(io.LimitReader(tr, listLen)).Decode(&leaves); err != nil {
+		return fmt.Errorf("invalid archive: entry %q: %s", name, err)
+	}
+	if leaves == nil && start > 0 {
+		return fmt.Errorf("invalid archive: entry %q keeps data it can't check", name)
+	}
+
+	// the local file is never followed if it is a symlink, nor replaced
+	// while it is opened
+	lfi, err := os.Lstat(target)
+	var f *os.File
+	switch {
+	case os.IsNotExist(err):
+		lfi = nil
+		f, err = os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	case err != nil:
+	case !lfi.Mode().IsRegular():
+		return fmt.Errorf("%s is not a regular file, remove it to start over", target)
+	default:
+		f, err = os.OpenFile(target, os.O_RDWR, 0)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if lfi != nil && !os.SameFile(lfi, fi) {
+		return fmt.Errorf("%s was replaced while resuming, run the get again", target)
+	}
+	offset := fi.Size()
+	if offset > size {
+		return fmt.Errorf("%s is larger than the file it is the output of (%d bytes), remove it to start over", target, size)
+	}
+	if start > offset {
+		return fmt.Errorf("%s changed while resuming, run the get again", target)
+	}
+	if e.progress != nil {
+		rel := name
+		if rel == "." {
+			rel = ""
+		}
+		e.progress(ResumedFile{Path: rel, Offset: start, Size: size})
+	}
+
+	// don't complete a file that doesn't match the DAG so far
+	if err := checkLeaves(f, leaves, target, 0, start); err != nil {
+		return err
+	}
+	if offset == size && start == size {
+		return nil
+	}
+	if err := f.Truncate(start); err != nil {
+		return err
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	n, err := io.Copy(f, tr)
+	if err != nil {
+		return err
+	}
+	if start+n != size {
+		return fmt.Errorf("invalid archive: entry %q is truncated", name)
+	}
+
+	// check the whole file matches the DAG
+	return checkLeaves(f, leaves, target, start, size)
+}
+
+// checkLeaves verifies that the data of the leaves between the offsets from
+// and to matches f.
+func checkLeaves(f io.ReaderAt, leaves []resumeLeaf, target string, from, to int64) error {
+	var offset int64
+	for _, l := range leaves {
+		end := offset + int64(l.Size)
+		if end > to {
+			break
+		}
+		if offset >= from {
+			c, err := cid.Decode(l.Cid)
+			if err != nil {
+				return fmt.Errorf("invalid archive: %s", err)
+			}
+			local, err := readRange(f, offset, end)
+			if err != nil {
+				return err
+			}
+			if !leafMatches(c, local) {
+				return fmt.Errorf("%s differs from the DAG at byte %d, remove it to start over", target, offset)
+			}
+		}
+		offset = end
+	}
+	return nil
+}
+
+func readRange(f io.ReaderAt, start, end int64) ([]byte, error) {
+	buf := make([]byte, end-start)
+	if _, err := f.ReadAt(buf, start); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// leafMatches returns whether c is the CID of a leaf holding data, as raw
+// leaf or as UnixFS leaf.
+func leafMatches(c cid.Cid, data []byte) bool {
+	prefix := c.Prefix()
+	switch prefix.Codec {
+	case cid.Raw:
+		sum, err := prefix.Sum(data)
+		return err == nil && sum.Equals(c)
+	case cid.DagProtobuf:
+		// the leaves are File nodes, or Raw ones for the older DAGs
+		for _, pbdata := range [][]byte{unixfs.FilePBData(data, uint64(len(data))), unixfs.WrapData(data)} {
+			nd := dag.NodeWithData(pbdata)
+			nd.SetCidBuilder(prefix)
+			if nd.Cid().Equals(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
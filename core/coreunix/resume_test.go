@@ -0,0 +1,272 @@
+package coreunix
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/core"
+
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
+)
+
+// fetchCounter records the leaves fetched through it.
+type fetchCounter struct {
+	ipld.DAGService
+	leaves map[cid.Cid]bool
+}
+
+func (fc *fetchCounter) record(nd ipld.Node) {
+	if len(nd.Links()) == 0 {
+		fc.leaves[nd.Cid()] = true
+	}
+}
+
+func (fc *fetchCounter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	nd, err := fc.DAGService.Get(ctx, c)
+	if err == nil {
+		fc.record(nd)
+	}
+	return nd, err
+}
+
+func (fc *fetchCounter) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption)
+	go func() {
+		defer close(out)
+		for opt := range fc.DAGService.GetMany(ctx, cids) {
+			if opt.Err == nil {
+				fc.record(opt.Node)
+			}
+			out <- opt
+		}
+	}()
+	return out
+}
+
+// resumeGet resumes the get of root at path, the way 'ipfs get --resume'
+// does.
+func resumeGet(ctx context.Context, dserv ipld.DAGService, root ipld.Node, path string, progress func(ResumedFile)) error {
+	local, err := LocalFiles(path)
+	if err != nil {
+		return err
+	}
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(WriteResumeArchive(ctx, dserv, root, local, w))
+	}()
+	err = ExtractResumeArchive(r, path, progress)
+	r.CloseWithError(err)
+	return err
+}
+
+func TestResumeGet(t *testing.T) {
+	ctx := context.Background()
+	node, err := core.NewNode(ctx, &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 300*1024+100)
+	rand.New(rand.NewSource(1)).Read(data)
+	const written = 70000
+
+	for _, trickle := range []bool{false, true} {
+		for _, rawLeaves := range []bool{false, true} {
+			adder, err := NewAdder(ctx, node.Pinning, node.Blockstore, node.DAG)
+			if err != nil {
+				t.Fatal(err)
+			}
+			adder.Pin = false
+			adder.Silent = true
+			adder.Chunker = "size-1024"
+			adder.Trickle = trickle
+			adder.RawLeaves = rawLeaves
+			adder.Wrap = true
+			root, err := adder.AddAllAndPin(files.NewMapDirectory(map[string]files.Node{
+				"complete": files.NewBytesFile(data[:5000]),
+				"partial":  files.NewBytesFile(data),
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out := filepath.Join(dir, "out")
+			if err := os.Mkdir(out, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(out, "complete"), data[:5000], 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(out, "partial"), data[:written], 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			fc := &fetchCounter{DAGService: node.DAG, leaves: make(map[cid.Cid]bool)}
+			var resumed []ResumedFile
+			err = resumeGet(ctx, fc, root, out, func(f ResumedFile) {
+				resumed = append(resumed, f)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(filepath.Join(out, "partial"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("trickle=%t raw-leaves=%t: the resumed file differs", trickle, rawLeaves)
+			}
+			kept := int64(written / 1024 * 1024)
+			if len(resumed) != 2 || resumed[1] != (ResumedFile{Path: "partial", Offset: kept, Size: int64(len(data))}) {
+				t.Fatalf("unexpected progress: %v", resumed)
+			}
+			// the leaves already written must not be fetched
+			if missing := (len(data)-1)/1024 + 1 - written/1024; len(fc.leaves) != missing {
+				t.Fatalf("trickle=%t raw-leaves=%t: fetched %d leaves, %d are missing", trickle, rawLeaves, len(fc.leaves), missing)
+			}
+
+			// a partial file not matching the DAG is not completed
+			bad := append([]byte{}, data[:written]...)
+			bad[2000]++
+			if err := ioutil.WriteFile(filepath.Join(out, "partial"), bad, 0644); err != nil {
+				t.Fatal(err)
+			}
+			err = resumeGet(ctx, node.DAG, root, out, nil)
+			if err == nil || !strings.Contains(err.Error(), "differs from the DAG at byte 1024") {
+				t.Fatalf("expected the partial file to differ, got %v", err)
+			}
+
+			if err := os.RemoveAll(out); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestResumeGetSymlink(t *testing.T) {
+	ctx := context.Background()
+	node, err := core.NewNode(ctx, &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	adder, err := NewAdder(ctx, node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Pin = false
+	adder.Silent = true
+	adder.Wrap = true
+	root, err := adder.AddAllAndPin(files.NewMapDirectory(map[string]files.Node{
+		"file": files.NewBytesFile([]byte("resumed")),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a local file linking outside of the output path is not followed
+	outside := filepath.Join(dir, "outside")
+	if err := ioutil.WriteFile(outside, []byte("res"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(out, "file")); err != nil {
+		t.Fatal(err)
+	}
+	err = resumeGet(ctx, node.DAG, root, out, nil)
+	if err == nil || !strings.Contains(err.Error(), "not a regular file") {
+		t.Fatalf("expected the symlink to be refused, got %v", err)
+	}
+	if data, err := ioutil.ReadFile(outside); err != nil || string(data) != "res" {
+		t.Fatalf("the file linked to was modified: %q, %v", data, err)
+	}
+}
+
+func TestExtractResumeArchiveEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive := func(hdrs ...*tar.Header) io.Reader {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range hdrs {
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return &buf
+	}
+	root := &tar.Header{Typeflag: tar.TypeDir, Name: ".", Mode: 0755}
+	dirEntry := func(name string) *tar.Header {
+		return &tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755}
+	}
+	link := &tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: dir, Mode: 0777}
+
+	for _, c := range []struct {
+		hdrs []*tar.Header
+		err  string
+	}{
+		{[]*tar.Header{dirEntry("x")}, "the first entry"},
+		{[]*tar.Header{root, dirEntry("../x")}, "outside of the output path"},
+		{[]*tar.Header{root, dirEntry("/x")}, "outside of the output path"},
+		{[]*tar.Header{root, dirEntry("a/../../x")}, "outside of the output path"},
+		{[]*tar.Header{root, dirEntry("x"), dirEntry("x")}, "duplicate entry"},
+		{[]*tar.Header{root, dirEntry("x/y")}, "not a directory of the archive"},
+		{[]*tar.Header{root, link, dirEntry("link/x")}, "not a directory of the archive"},
+	} {
+		out := filepath.Join(dir, "out")
+		err := ExtractResumeArchive(archive(c.hdrs...), out, nil)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", c.hdrs[len(c.hdrs)-1].Name, c.err, err)
+		}
+		if err := os.RemoveAll(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "x")); !os.IsNotExist(err) {
+		t.Fatal("an entry was written outside of the output path")
+	}
+}
+
+func TestProtoLeafSize(t *testing.T) {
+	for _, size := range []int{1, 127, 128, 1000, 16384, 262144, 1 << 20} {
+		data := make([]byte, size)
+		for _, pbdata := range [][]byte{unixfs.FilePBData(data, uint64(size)), unixfs.WrapData(data)} {
+			nd := dag.NodeWithData(pbdata)
+			if got := protoLeafSize(uint64(size)); got != uint64(len(nd.RawData())) {
+				t.Fatalf("size %d: expected %d, got %d", size, len(nd.RawData()), got)
+			}
+		}
+	}
+}
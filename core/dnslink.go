@@ -0,0 +1,71 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	repo "github.com/ipfs/go-ipfs/repo"
+)
+
+// DNSLinkConfigKey is the config section of the DNSLink resolution.
+const DNSLinkConfigKey = "Gateway.DNSLink"
+
+// DNSLinkConfig is the configuration of the DNSLink resolution.
+type DNSLinkConfig struct {
+	Cache DNSLinkCacheConfig
+}
+
+// DNSLinkCacheConfig is the configuration of the cache of the DNSLink
+// records: the records of up to Size domains are kept for their TTL, at most
+// TTL. A Size of zero disables the cache.
+type DNSLinkCacheConfig struct {
+	Size int
+	TTL  string
+}
+
+// LoadDNSLinkConfig reads the DNSLink configuration from the config of r,
+// using the defaults for the values not set.
+func LoadDNSLinkConfig(r repo.Repo) (DNSLinkConfig, error) {
+	cfg := DNSLinkConfig{
+		Cache: DNSLinkCacheConfig{
+			Size: namesys.DefaultDNSLinkCacheSize,
+			TTL:  namesys.DefaultDNSLinkCacheTTL.String(),
+		},
+	}
+
+	// the fields not set keep their default
+	if _, err := repo.ReadConfigKey(r, DNSLinkConfigKey, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.Cache.Size < 0 {
+		return cfg, fmt.Errorf("invalid value for %s: negative cache size", DNSLinkConfigKey)
+	}
+	if _, err := cfg.Cache.ttl(); err != nil {
+		return cfg, fmt.Errorf("invalid value for %s: %s", DNSLinkConfigKey, err)
+	}
+	return cfg, nil
+}
+
+func (c DNSLinkCacheConfig) ttl() (time.Duration, error) {
+	ttl, err := time.ParseDuration(c.TTL)
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, fmt.Errorf("negative cache TTL %s", c.TTL)
+	}
+	return ttl, nil
+}
+
+// setupDNSLinkResolver constructs the DNSLink resolver of the name system,
+// caching the records as configured.
+func (n *IpfsNode) setupDNSLinkResolver() error {
+	cfg, err := LoadDNSLinkConfig(n.Repo)
+	if err != nil {
+		return err
+	}
+	ttl, _ := cfg.Cache.ttl()
+	n.DNSLink, err = namesys.NewCachingDNSResolver(cfg.Cache.Size, ttl)
+	return err
+}
@@ -0,0 +1,304 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	gonat "github.com/fd/go-nat"
+	autonat "github.com/libp2p/go-libp2p-autonat"
+	p2phost "github.com/libp2p/go-libp2p-host"
+	inat "github.com/libp2p/go-libp2p-nat"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+// NAT status of a node, as reported by NATStatus.
+const (
+	// NATStatusNone means the node is reachable directly, without NAT.
+	NATStatusNone = "none"
+	// NATStatusStatic means the node is behind a NAT, and reachable through
+	// the port mappings made with UPnP or NAT-PMP.
+	NATStatusStatic = "static"
+	// NATStatusDynamic means the node is behind a NAT it couldn't map ports
+	// on, other peers can only reach it through hole punching or relays.
+	NATStatusDynamic = "dynamic"
+	// NATStatusFailed means AutoNAT couldn't find out whether the node is
+	// reachable, usually because no peer running the AutoNAT service was
+	// found.
+	NATStatusFailed = "failed"
+)
+
+// NATWarmup is how long a node must have been online before its NAT status
+// is reported, to give AutoNAT time to complete.
+const NATWarmup = 30 * time.Second
+
+// NATStatus returns the NAT status of the node and the addresses other peers
+// can reach it at. ok is false if the node is offline, or has not been online
+// for NATWarmup yet.
+func (n *IpfsNode) NATStatus() (status string, external []ma.Multiaddr, ok bool) {
+	if !n.IsOnline || n.autoNAT == nil || time.Since(n.onlineSince) < NATWarmup {
+		return "", nil, false
+	}
+
+	switch n.autoNAT.Status() {
+	case autonat.NATStatusPublic:
+		status = NATStatusNone
+		if len(n.natMappings()) > 0 {
+			status = NATStatusStatic
+		}
+	case autonat.NATStatusPrivate:
+		status = NATStatusDynamic
+	default:
+		status = NATStatusFailed
+	}
+
+	// the host addresses include the ones observed by peers through
+	// identify, and the external side of the port mappings
+	seen := make(map[string]bool)
+	addrs := n.PeerHost.Addrs()
+	if a, err := n.autoNAT.PublicAddr(); err == nil {
+		addrs = append(addrs, a)
+	}
+	for _, a := range addrs {
+		if !manet.IsPublicAddr(a) || seen[string(a.Bytes())] {
+			continue
+		}
+		seen[string(a.Bytes())] = true
+		external = append(external, a)
+	}
+	return status, external, true
+}
+
+// natMappings returns the external addresses of the NAT port mappings of the
+// node.
+func (n *IpfsNode) natMappings() []ma.Multiaddr {
+	if n.natManager == nil || n.natManager.NAT() == nil {
+		return nil
+	}
+	return n.natManager.NAT().ExternalAddrs()
+}
+
+// Port mapping states, as reported in NATState.
+const (
+	PortMappingDisabled    = "disabled"
+	PortMappingDiscovering = "discovering"
+	PortMappingUnavailable = "unavailable"
+	PortMappingActive      = "active"
+)
+
+// ErrNoAutoNATPeers is returned when probing the NAT while no connected peer
+// runs the AutoNAT service.
+var ErrNoAutoNATPeers = errors.New("no connected peer runs the AutoNAT service")
+
+var errNATOffline = errors.New("NAT traversal runs on online nodes only")
+
+// NATState is the state of the NAT traversal subsystem of a node.
+type NATState struct {
+	// AutoNAT is the reachability of the node found by AutoNAT, PublicAddr
+	// the address peers dialed back when it is public.
+	AutoNAT    autonat.NATStatus
+	PublicAddr ma.Multiaddr
+
+	// PortMapping is the state of the UPnP/NAT-PMP port mapper, Mappings the
+	// port mappings it made.
+	PortMapping string
+	Mappings    []inat.Mapping
+
+	// Observed are the addresses peers observed the node at, not
+	// including the listen addresses and the external side of the port
+	// mappings.
+	Observed []ma.Multiaddr
+}
+
+// NATState returns the state of the NAT traversal subsystem of the node.
+func (n *IpfsNode) NATState() (*NATState, error) {
+	if !n.IsOnline || n.autoNAT == nil {
+		return nil, errNATOffline
+	}
+
+	st := &NATState{AutoNAT: n.autoNAT.Status()}
+	if a, err := n.autoNAT.PublicAddr(); err == nil {
+		st.PublicAddr = a
+	}
+
+	switch {
+	case n.natManager == nil:
+		st.PortMapping = PortMappingDisabled
+	case !isClosed(n.natManager.Ready()):
+		st.PortMapping = PortMappingDiscovering
+	case n.natManager.NAT() == nil:
+		st.PortMapping = PortMappingUnavailable
+	default:
+		st.PortMapping = PortMappingActive
+		st.Mappings = n.natManager.NAT().Mappings()
+	}
+
+	known := make(map[string]bool)
+	if listen, err := n.PeerHost.Network().InterfaceListenAddresses(); err == nil {
+		for _, a := range listen {
+			known[string(a.Bytes())] = true
+		}
+	}
+	for _, a := range n.natMappings() {
+		known[string(a.Bytes())] = true
+	}
+	for _, a := range n.PeerHost.Addrs() {
+		if !known[string(a.Bytes())] {
+			st.Observed = append(st.Observed, a)
+		}
+	}
+	return st, nil
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// RefreshNAT probes the reachability of the node through AutoNAT again, and
+// renews the port mappings of the listen addresses. It returns the new
+// AutoNAT status.
+func (n *IpfsNode) RefreshNAT(ctx context.Context) (autonat.NATStatus, error) {
+	if !n.IsOnline || n.autoNAT == nil {
+		return autonat.NATStatusUnknown, errNATOffline
+	}
+
+	if n.natManager != nil && n.natManager.NAT() != nil {
+		nat := n.natManager.NAT()
+		for _, m := range nat.Mappings() {
+			m.Close()
+		}
+		// blocks until all the mappings were tried
+		nat.PortMapAddrs(n.PeerHost.Network().ListenAddresses())
+	}
+
+	return n.autoNAT.Probe(ctx)
+}
+
+// DisableNATPortMapping removes the port mappings of the node from the NAT
+// device, and returns how many were removed. Listen addresses added later
+// are mapped again, 'ipfs swarm nat refresh' maps the current ones again.
+func (n *IpfsNode) DisableNATPortMapping() (int, error) {
+	if !n.IsOnline {
+		return 0, errNATOffline
+	}
+	if n.natManager == nil || n.natManager.NAT() == nil {
+		return 0, nil
+	}
+
+	mappings := n.natManager.NAT().Mappings()
+	if len(mappings) == 0 {
+		return 0, nil
+	}
+	// closing a mapping only stops renewing it, delete it from the device
+	// too so that it doesn't linger until its lease expires
+	for _, m := range mappings {
+		m.Close()
+	}
+	gw, err := gonat.DiscoverGateway()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, m := range mappings {
+		if err := gw.DeletePortMapping(m.Protocol(), m.InternalPort()); err != nil {
+			log.Warningf("removing port mapping %s/%d: %s", m.Protocol(), m.InternalPort(), err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// natDetector is the AutoNAT client of a node. It runs the ambient AutoNAT
+// detection of libp2p, and allows probing on demand.
+type natDetector struct {
+	autonat.AutoNAT
+	host p2phost.Host
+
+	mu     sync.Mutex
+	probed time.Time
+	status autonat.NATStatus
+	addr   ma.Multiaddr
+}
+
+// Status returns the result of the last probe if it is more recent than the
+// last ambient detection could be, the ambient one otherwise.
+func (d *natDetector) Status() autonat.NATStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if time.Since(d.probed) < autonat.AutoNATRefreshInterval {
+		return d.status
+	}
+	return d.AutoNAT.Status()
+}
+
+func (d *natDetector) PublicAddr() (ma.Multiaddr, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if time.Since(d.probed) < autonat.AutoNATRefreshInterval {
+		if d.status != autonat.NATStatusPublic {
+			return nil, errors.New("NAT Status is not public")
+		}
+		return d.addr, nil
+	}
+	return d.AutoNAT.PublicAddr()
+}
+
+// Probe asks the connected AutoNAT peers to dial the node back, the same way
+// the ambient detection does.
+func (d *natDetector) Probe(ctx context.Context) (autonat.NATStatus, error) {
+	var peers []peer.ID
+	for _, p := range d.host.Network().Peers() {
+		if d.host.Network().Connectedness(p) != inet.Connected {
+			continue
+		}
+		if protos, err := d.host.Peerstore().SupportsProtocols(p, autonat.AutoNATProto); err == nil && len(protos) > 0 {
+			peers = append(peers, p)
+		}
+	}
+	if len(peers) == 0 {
+		return autonat.NATStatusUnknown, ErrNoAutoNATPeers
+	}
+
+	cli := autonat.NewAutoNATClient(d.host, nil)
+	status := autonat.NATStatusUnknown
+	var addr ma.Multiaddr
+	failures := 0
+	for _, p := range peers {
+		pctx, cancel := context.WithTimeout(ctx, autonat.AutoNATRequestTimeout)
+		a, err := cli.DialBack(pctx, p)
+		cancel()
+		if err == nil {
+			status, addr = autonat.NATStatusPublic, a
+			break
+		}
+		if ctx.Err() != nil {
+			return autonat.NATStatusUnknown, ctx.Err()
+		}
+		if autonat.IsDialError(err) {
+			failures++
+			if failures >= 3 {
+				break
+			}
+		}
+		log.Debugf("AutoNAT dial back through %s: %s", p.Pretty(), err)
+	}
+	if status != autonat.NATStatusPublic && failures > 0 {
+		status = autonat.NATStatusPrivate
+	}
+
+	d.mu.Lock()
+	d.probed = time.Now()
+	d.status, d.addr = status, addr
+	d.mu.Unlock()
+	return status, nil
+}
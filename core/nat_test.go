@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	autonat "github.com/libp2p/go-libp2p-autonat"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	testutil "github.com/libp2p/go-testutil"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// fakeAutoNAT is an ambient AutoNAT that found status and addr.
+type fakeAutoNAT struct {
+	status autonat.NATStatus
+	addr   ma.Multiaddr
+}
+
+func (f *fakeAutoNAT) Status() autonat.NATStatus {
+	return f.status
+}
+
+func (f *fakeAutoNAT) PublicAddr() (ma.Multiaddr, error) {
+	if f.status != autonat.NATStatusPublic {
+		return nil, errors.New("NAT Status is not public")
+	}
+	return f.addr, nil
+}
+
+// newNATTestNode returns an online node listening on a private address only,
+// whose ambient AutoNAT is ambient.
+func newNATTestNode(ctx context.Context, t *testing.T, ambient *fakeAutoNAT) *IpfsNode {
+	sk, _, err := testutil.SeededTestKeyPair(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := mocknet.New(ctx).AddPeer(sk, ma.StringCast("/ip4/192.168.1.2/tcp/4001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &IpfsNode{
+		IsOnline:    true,
+		PeerHost:    h,
+		autoNAT:     &natDetector{AutoNAT: ambient, host: h},
+		onlineSince: time.Now().Add(-NATWarmup),
+	}
+}
+
+func TestNATStatus(t *testing.T) {
+	public := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ambient := &fakeAutoNAT{status: autonat.NATStatusUnknown}
+	n := newNATTestNode(ctx, t, ambient)
+
+	check := func(status string, external ...ma.Multiaddr) {
+		t.Helper()
+		s, ext, ok := n.NATStatus()
+		if !ok {
+			t.Fatal("no NAT status after the warmup")
+		}
+		if s != status {
+			t.Fatalf("expected the status %q, got %q", status, s)
+		}
+		if len(ext) != len(external) {
+			t.Fatalf("expected the external addresses %v, got %v", external, ext)
+		}
+		for i := range ext {
+			if !ext[i].Equal(external[i]) {
+				t.Fatalf("expected the external addresses %v, got %v", external, ext)
+			}
+		}
+	}
+
+	check(NATStatusFailed)
+	ambient.status, ambient.addr = autonat.NATStatusPublic, public
+	check(NATStatusNone, public)
+	ambient.status = autonat.NATStatusPrivate
+	check(NATStatusDynamic)
+
+	// a probe overrides the ambient status until the next ambient
+	// detection could have run
+	n.autoNAT.probed = time.Now()
+	n.autoNAT.status, n.autoNAT.addr = autonat.NATStatusPublic, public
+	check(NATStatusNone, public)
+	n.autoNAT.probed = time.Now().Add(-autonat.AutoNATRefreshInterval)
+	check(NATStatusDynamic)
+}
+
+func TestNATStatusNotReported(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := newNATTestNode(ctx, t, &fakeAutoNAT{status: autonat.NATStatusPublic})
+
+	n.onlineSince = time.Now()
+	if _, _, ok := n.NATStatus(); ok {
+		t.Fatal("NAT status reported during the warmup")
+	}
+
+	n.onlineSince = time.Now().Add(-NATWarmup)
+	n.IsOnline = false
+	if _, _, ok := n.NATStatus(); ok {
+		t.Fatal("NAT status reported on an offline node")
+	}
+
+	n.IsOnline = true
+	n.autoNAT = nil
+	if _, _, ok := n.NATStatus(); ok {
+		t.Fatal("NAT status reported without AutoNAT")
+	}
+}
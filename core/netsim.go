@@ -0,0 +1,15 @@
+//go:build netsim
+// +build netsim
+
+package core
+
+import (
+	netsim "github.com/ipfs/go-ipfs/netsim"
+)
+
+// newNetSim returns the shaper of 'ipfs network simulate'. Built with the
+// netsim tag, the connections of all the nodes go through it, which costs a
+// copy and a goroutine per connection even when no simulation runs.
+func newNetSim() *netsim.Shaper {
+	return netsim.NewShaper()
+}
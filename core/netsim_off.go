@@ -0,0 +1,13 @@
+//go:build !netsim
+// +build !netsim
+
+package core
+
+import (
+	netsim "github.com/ipfs/go-ipfs/netsim"
+)
+
+// newNetSim returns nil: the network simulation needs the netsim build tag.
+func newNetSim() *netsim.Shaper {
+	return nil
+}
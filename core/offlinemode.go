@@ -0,0 +1,237 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	routing "github.com/libp2p/go-libp2p-routing"
+	ropts "github.com/libp2p/go-libp2p-routing/options"
+	swarm "github.com/libp2p/go-libp2p-swarm"
+	ma "github.com/multiformats/go-multiaddr"
+	mafilter "github.com/whyrusleeping/multiaddr-filter"
+)
+
+// ErrOfflineMode is returned by the routing and the block exchange of a node
+// in the offline mode.
+var ErrOfflineMode = errors.New("the node is in offline mode")
+
+var errOfflineModeOffline = errors.New("the offline mode applies to online nodes only")
+
+// offlineModeMasks block all the IPv4 and IPv6 addresses.
+var offlineModeMasks = []string{"/ip4/0.0.0.0/ipcidr/0", "/ip6/::/ipcidr/0"}
+
+// offlineMode is the state of the offline mode of a node.
+type offlineMode struct {
+	enabled int32 // read without the lock by the routing and the exchange
+
+	mu    sync.Mutex
+	added []*net.IPNet // the filters added to the swarm
+}
+
+func (m *offlineMode) isEnabled() bool {
+	return atomic.LoadInt32(&m.enabled) == 1
+}
+
+// OfflineMode tells whether the network activity of the node is paused.
+func (n *IpfsNode) OfflineMode() bool {
+	return n.offline.isEnabled()
+}
+
+// SetOfflineMode pauses or resumes the network activity of an online node.
+// While paused, the connections are closed, including the relayed ones, the
+// swarm doesn't dial any address, and the routing and the block exchange
+// fail at once instead of waiting for peers. Their state is kept, as the
+// bootstrap peers are dialed again when the network activity resumes.
+func (n *IpfsNode) SetOfflineMode(enabled bool) error {
+	if !n.IsOnline || n.PeerHost == nil {
+		return errOfflineModeOffline
+	}
+	swrm, ok := n.PeerHost.Network().(*swarm.Swarm)
+	if !ok {
+		return errors.New("failed to cast network to swarm network")
+	}
+
+	m := &n.offline
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !enabled {
+		if !m.isEnabled() {
+			return nil
+		}
+		for _, mask := range m.added {
+			swrm.Filters.Remove(mask)
+		}
+		m.added = nil
+		atomic.StoreInt32(&m.enabled, 0)
+
+		// don't wait for the next bootstrap round to be connected again
+		return n.Bootstrap(DefaultBootstrapConfig)
+	}
+
+	if !m.isEnabled() {
+		// the filters of the user are left alone when resuming
+		present := make(map[string]bool)
+		for _, f := range swrm.Filters.Filters() {
+			present[f.String()] = true
+		}
+		for _, s := range offlineModeMasks {
+			mask, err := mafilter.NewMask(s)
+			if err != nil {
+				return err
+			}
+			if present[mask.String()] {
+				continue
+			}
+			swrm.Filters.AddDialFilter(mask)
+			m.added = append(m.added, mask)
+		}
+		atomic.StoreInt32(&m.enabled, 1)
+	}
+
+	for _, p := range swrm.Peers() {
+		if err := swrm.ClosePeer(p); err != nil {
+			log.Debugf("closing the connections to %s: %s", p.Pretty(), err)
+		}
+	}
+	return nil
+}
+
+// offlineModeNotifiee closes the connections opened in the offline mode:
+// the filters don't apply to the relayed ones.
+type offlineModeNotifiee struct {
+	mode *offlineMode
+}
+
+func (on offlineModeNotifiee) Connected(n inet.Network, c inet.Conn) {
+	if on.mode.isEnabled() {
+		log.Debugf("closing connection to %s: offline mode", c.RemotePeer().Pretty())
+		// don't block the notification
+		go c.Close()
+	}
+}
+
+func (on offlineModeNotifiee) Disconnected(inet.Network, inet.Conn)   {}
+func (on offlineModeNotifiee) Listen(inet.Network, ma.Multiaddr)      {}
+func (on offlineModeNotifiee) ListenClose(inet.Network, ma.Multiaddr) {}
+func (on offlineModeNotifiee) OpenedStream(inet.Network, inet.Stream) {}
+func (on offlineModeNotifiee) ClosedStream(inet.Network, inet.Stream) {}
+
+// offlineModeRouting is the routing of a node, failing in the offline mode.
+type offlineModeRouting struct {
+	routing.IpfsRouting
+	mode *offlineMode
+}
+
+func (r *offlineModeRouting) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	if r.mode.isEnabled() {
+		return ErrOfflineMode
+	}
+	return r.IpfsRouting.Provide(ctx, c, announce)
+}
+
+func (r *offlineModeRouting) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan pstore.PeerInfo {
+	if r.mode.isEnabled() {
+		ch := make(chan pstore.PeerInfo)
+		close(ch)
+		return ch
+	}
+	return r.IpfsRouting.FindProvidersAsync(ctx, c, count)
+}
+
+func (r *offlineModeRouting) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	if r.mode.isEnabled() {
+		return pstore.PeerInfo{}, ErrOfflineMode
+	}
+	return r.IpfsRouting.FindPeer(ctx, p)
+}
+
+func (r *offlineModeRouting) PutValue(ctx context.Context, key string, value []byte, opts ...ropts.Option) error {
+	if r.mode.isEnabled() {
+		return ErrOfflineMode
+	}
+	return r.IpfsRouting.PutValue(ctx, key, value, opts...)
+}
+
+func (r *offlineModeRouting) GetValue(ctx context.Context, key string, opts ...ropts.Option) ([]byte, error) {
+	if r.mode.isEnabled() {
+		return nil, ErrOfflineMode
+	}
+	return r.IpfsRouting.GetValue(ctx, key, opts...)
+}
+
+func (r *offlineModeRouting) SearchValue(ctx context.Context, key string, opts ...ropts.Option) (<-chan []byte, error) {
+	if r.mode.isEnabled() {
+		return nil, ErrOfflineMode
+	}
+	return r.IpfsRouting.SearchValue(ctx, key, opts...)
+}
+
+func (r *offlineModeRouting) Bootstrap(ctx context.Context) error {
+	if r.mode.isEnabled() {
+		return nil
+	}
+	return r.IpfsRouting.Bootstrap(ctx)
+}
+
+// offlineModeExchange is the block exchange of a node, failing to fetch
+// blocks in the offline mode. The blocks added are still passed to it, they
+// are provided once the network activity resumes.
+type offlineModeExchange struct {
+	exchange.Interface
+	mode *offlineMode
+}
+
+func (e *offlineModeExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if e.mode.isEnabled() {
+		return nil, ErrOfflineMode
+	}
+	return e.Interface.GetBlock(ctx, c)
+}
+
+func (e *offlineModeExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	if e.mode.isEnabled() {
+		return nil, ErrOfflineMode
+	}
+	return e.Interface.GetBlocks(ctx, cids)
+}
+
+func (e *offlineModeExchange) IsOnline() bool {
+	return e.Interface.IsOnline() && !e.mode.isEnabled()
+}
+
+func (e *offlineModeExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	se, ok := e.Interface.(exchange.SessionExchange)
+	if !ok {
+		return e
+	}
+	return &offlineModeFetcher{Fetcher: se.NewSession(ctx), mode: e.mode}
+}
+
+// offlineModeFetcher is a session of the block exchange of a node.
+type offlineModeFetcher struct {
+	exchange.Fetcher
+	mode *offlineMode
+}
+
+func (f *offlineModeFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if f.mode.isEnabled() {
+		return nil, ErrOfflineMode
+	}
+	return f.Fetcher.GetBlock(ctx, c)
+}
+
+func (f *offlineModeFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	if f.mode.isEnabled() {
+		return nil, ErrOfflineMode
+	}
+	return f.Fetcher.GetBlocks(ctx, cids)
+}
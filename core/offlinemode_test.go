@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	offroute "github.com/ipfs/go-ipfs-routing/offline"
+	record "github.com/libp2p/go-libp2p-record"
+)
+
+func TestOfflineModeWrappers(t *testing.T) {
+	ctx := context.Background()
+	mode := new(offlineMode)
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewBlockstore(d)
+	exch := &offlineModeExchange{Interface: offline.Exchange(bs), mode: mode}
+	r := &offlineModeRouting{IpfsRouting: offroute.NewOfflineRouter(d, record.NamespacedValidator{}), mode: mode}
+
+	blk := blocks.NewBlock([]byte("offline mode"))
+	if err := bs.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+	fetch := exch.NewSession(ctx)
+	if _, err := fetch.GetBlock(ctx, blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	mode.enabled = 1
+	if _, err := exch.GetBlock(ctx, blk.Cid()); err != ErrOfflineMode {
+		t.Fatalf("expected ErrOfflineMode from the exchange, got %v", err)
+	}
+	if _, err := fetch.GetBlock(ctx, blk.Cid()); err != ErrOfflineMode {
+		t.Fatalf("expected ErrOfflineMode from the session, got %v", err)
+	}
+	if exch.IsOnline() {
+		t.Fatal("exchange online in the offline mode")
+	}
+	if err := r.Provide(ctx, blk.Cid(), true); err != ErrOfflineMode {
+		t.Fatalf("expected ErrOfflineMode from the routing, got %v", err)
+	}
+	if _, ok := <-r.FindProvidersAsync(ctx, blk.Cid(), 1); ok {
+		t.Fatal("providers found in the offline mode")
+	}
+
+	mode.enabled = 0
+	if _, err := exch.GetBlock(ctx, blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	circuit "github.com/libp2p/go-libp2p-circuit"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// RelayClientConfigKey is the config section of the relay client limits.
+const RelayClientConfigKey = "Swarm.RelayClient"
+
+const (
+	// DefaultMaxCircuits is the default maximum number of open relay
+	// circuits.
+	DefaultMaxCircuits = 16
+
+	// DefaultMaxCircuitsPerRelay is the default maximum number of open
+	// circuits through a single relay.
+	DefaultMaxCircuitsPerRelay = 4
+)
+
+// RelayClientLimits caps the relay circuits a node keeps open, each of them
+// holding a stream to the relay. Zero means no limit.
+type RelayClientLimits struct {
+	MaxCircuits         int
+	MaxCircuitsPerRelay int
+}
+
+// LoadRelayClientLimits reads the relay client limits from the config of r,
+// using the defaults for the ones not set.
+func LoadRelayClientLimits(r repo.Repo) (RelayClientLimits, error) {
+	limits := RelayClientLimits{
+		MaxCircuits:         DefaultMaxCircuits,
+		MaxCircuitsPerRelay: DefaultMaxCircuitsPerRelay,
+	}
+
+	// the fields not set keep their default
+	if _, err := repo.ReadConfigKey(r, RelayClientConfigKey, &limits); err != nil {
+		return limits, err
+	}
+	if limits.MaxCircuits < 0 || limits.MaxCircuitsPerRelay < 0 {
+		return limits, fmt.Errorf("invalid value for %s: negative limits", RelayClientConfigKey)
+	}
+	return limits, nil
+}
+
+// RelayCircuit is a connection to a peer relayed through another one.
+type RelayCircuit struct {
+	Relay     peer.ID
+	Peer      peer.ID
+	Direction inet.Direction
+}
+
+// circuitRelay returns the relay of c, if c is a relayed connection.
+func circuitRelay(c inet.Conn) (peer.ID, bool) {
+	addr := c.RemoteMultiaddr()
+	if _, err := addr.ValueForProtocol(circuit.P_CIRCUIT); err != nil {
+		return "", false
+	}
+	v, err := addr.ValueForProtocol(ma.P_IPFS)
+	if err != nil {
+		return "", false
+	}
+	relay, err := peer.IDB58Decode(v)
+	if err != nil {
+		return "", false
+	}
+	return relay, true
+}
+
+// RelayCircuits returns the relay circuits open on net.
+func RelayCircuits(net inet.Network) []RelayCircuit {
+	var out []RelayCircuit
+	for _, c := range net.Conns() {
+		relay, ok := circuitRelay(c)
+		if !ok {
+			continue
+		}
+		out = append(out, RelayCircuit{
+			Relay:     relay,
+			Peer:      c.RemotePeer(),
+			Direction: c.Stat().Direction,
+		})
+	}
+	return out
+}
+
+// circuitLimiter closes the relay circuits opened over the limits. The relay
+// transport has no way to refuse them beforehand.
+type circuitLimiter struct {
+	limits RelayClientLimits
+
+	mu       sync.Mutex
+	circuits map[inet.Conn]peer.ID
+	perRelay map[peer.ID]int
+}
+
+func newCircuitLimiter(limits RelayClientLimits) *circuitLimiter {
+	return &circuitLimiter{
+		limits:   limits,
+		circuits: make(map[inet.Conn]peer.ID),
+		perRelay: make(map[peer.ID]int),
+	}
+}
+
+func (cl *circuitLimiter) Connected(n inet.Network, c inet.Conn) {
+	relay, ok := circuitRelay(c)
+	if !ok {
+		return
+	}
+
+	cl.mu.Lock()
+	over := (cl.limits.MaxCircuits > 0 && len(cl.circuits) >= cl.limits.MaxCircuits) ||
+		(cl.limits.MaxCircuitsPerRelay > 0 && cl.perRelay[relay] >= cl.limits.MaxCircuitsPerRelay)
+	if !over {
+		cl.circuits[c] = relay
+		cl.perRelay[relay]++
+	}
+	cl.mu.Unlock()
+
+	if over {
+		log.Debugf("closing circuit to %s through %s: too many relay circuits", c.RemotePeer().Pretty(), relay.Pretty())
+		// don't block the notification
+		go c.Close()
+	}
+}
+
+func (cl *circuitLimiter) Disconnected(n inet.Network, c inet.Conn) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	relay, ok := cl.circuits[c]
+	if !ok {
+		return
+	}
+	delete(cl.circuits, c)
+	if cl.perRelay[relay]--; cl.perRelay[relay] <= 0 {
+		delete(cl.perRelay, relay)
+	}
+}
+
+func (cl *circuitLimiter) Listen(inet.Network, ma.Multiaddr)      {}
+func (cl *circuitLimiter) ListenClose(inet.Network, ma.Multiaddr) {}
+func (cl *circuitLimiter) OpenedStream(inet.Network, inet.Stream) {}
+func (cl *circuitLimiter) ClosedStream(inet.Network, inet.Stream) {}
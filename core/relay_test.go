@@ -0,0 +1,174 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+	common "github.com/ipfs/go-ipfs/repo/common"
+
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	testutil "github.com/libp2p/go-testutil"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// configRepo is a repo whose config file holds m.
+type configRepo struct {
+	repo.Mock
+	m map[string]interface{}
+}
+
+func (r *configRepo) GetConfigKey(key string) (interface{}, error) {
+	return common.MapGetKV(r.m, key)
+}
+
+func TestLoadRelayClientLimits(t *testing.T) {
+	limits, err := LoadRelayClientLimits(&configRepo{m: map[string]interface{}{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits != (RelayClientLimits{DefaultMaxCircuits, DefaultMaxCircuitsPerRelay}) {
+		t.Fatalf("expected the default limits, got %+v", limits)
+	}
+
+	relayClient := func(v map[string]interface{}) *configRepo {
+		return &configRepo{m: map[string]interface{}{
+			"Swarm": map[string]interface{}{"RelayClient": v},
+		}}
+	}
+	limits, err = LoadRelayClientLimits(relayClient(map[string]interface{}{"MaxCircuits": 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits != (RelayClientLimits{2, DefaultMaxCircuitsPerRelay}) {
+		t.Fatalf("expected the limits not set to keep their default, got %+v", limits)
+	}
+
+	for _, v := range []map[string]interface{}{
+		{"MaxCircuits": -1},
+		{"MaxCircuitsPerRelay": "4"},
+		{"MaxCircuit": 4},
+	} {
+		if _, err := LoadRelayClientLimits(relayClient(v)); err == nil {
+			t.Errorf("no error for %v", v)
+		}
+	}
+}
+
+// fakeConn is a connection to peer, relayed through relay if set.
+type fakeConn struct {
+	inet.Conn
+	peer   peer.ID
+	addr   ma.Multiaddr
+	closed chan struct{}
+}
+
+func (c *fakeConn) RemotePeer() peer.ID {
+	return c.peer
+}
+
+func (c *fakeConn) RemoteMultiaddr() ma.Multiaddr {
+	return c.addr
+}
+
+func (c *fakeConn) Stat() inet.Stat {
+	return inet.Stat{Direction: inet.DirOutbound}
+}
+
+func (c *fakeConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func (c *fakeConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+// fakeNetwork only implements Conns, the one method RelayCircuits uses.
+type fakeNetwork struct {
+	inet.Network
+	conns []inet.Conn
+}
+
+func (n *fakeNetwork) Conns() []inet.Conn {
+	return n.conns
+}
+
+func newFakeConn(t *testing.T, relay peer.ID) *fakeConn {
+	t.Helper()
+	p, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := "/ip4/1.2.3.4/tcp/4001"
+	if relay != "" {
+		s += "/ipfs/" + relay.Pretty() + "/p2p-circuit"
+	}
+	addr, err := ma.NewMultiaddr(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeConn{peer: p, addr: addr, closed: make(chan struct{})}
+}
+
+func TestCircuitLimiter(t *testing.T) {
+	var relays []peer.ID
+	for i := 0; i < 2; i++ {
+		p, err := testutil.RandPeerID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		relays = append(relays, p)
+	}
+
+	cl := newCircuitLimiter(RelayClientLimits{MaxCircuits: 3, MaxCircuitsPerRelay: 2})
+	open := func(relay peer.ID) *fakeConn {
+		c := newFakeConn(t, relay)
+		cl.Connected(nil, c)
+		return c
+	}
+
+	// direct connections don't count
+	for i := 0; i < 4; i++ {
+		if open("").isClosed() {
+			t.Fatal("expected the direct connection to be kept")
+		}
+	}
+
+	first := open(relays[0])
+	open(relays[0])
+	if !open(relays[0]).isClosed() {
+		t.Fatal("expected the circuit over the per relay limit to be closed")
+	}
+	open(relays[1])
+	if !open(relays[1]).isClosed() {
+		t.Fatal("expected the circuit over the limit to be closed")
+	}
+
+	// closing a circuit makes room for another one
+	cl.Disconnected(nil, first)
+	if open(relays[0]).isClosed() {
+		t.Fatal("expected the circuit to be kept after another one closed")
+	}
+}
+
+func TestRelayCircuits(t *testing.T) {
+	relay, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayed := newFakeConn(t, relay)
+	net := &fakeNetwork{conns: []inet.Conn{newFakeConn(t, ""), relayed}}
+
+	circuits := RelayCircuits(net)
+	expect := RelayCircuit{Relay: relay, Peer: relayed.peer, Direction: inet.DirOutbound}
+	if len(circuits) != 1 || circuits[0] != expect {
+		t.Fatalf("expected %+v, got %+v", expect, circuits)
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"fmt"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// StorageQuotaConfigKey enables the enforcement of Datastore.StorageMax.
+// Datastore.StorageMax is only a GC watermark without it, as in the repos
+// created before the quota.
+const StorageQuotaConfigKey = "Datastore.StorageQuota"
+
+// LoadStorageQuota returns the maximum size of the repo r in bytes, 0 if the
+// quota is not enabled.
+func LoadStorageQuota(r repo.Repo) (uint64, error) {
+	var enabled bool
+	if _, err := repo.ReadConfigKey(r, StorageQuotaConfigKey, &enabled); err != nil || !enabled {
+		return 0, err
+	}
+
+	conf, err := r.Config()
+	if err != nil {
+		return 0, err
+	}
+	if conf.Datastore.StorageMax == "" {
+		return 0, fmt.Errorf("%s is enabled, but Datastore.StorageMax is not set", StorageQuotaConfigKey)
+	}
+	max, err := humanize.ParseBytes(conf.Datastore.StorageMax)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for Datastore.StorageMax: %s", err)
+	}
+	return max, nil
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"fmt"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+	reputation "github.com/ipfs/go-ipfs/reputation"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// TrustedPeersConfigKey is the config section listing the IDs of the trusted
+// peers.
+const TrustedPeersConfigKey = "Trusted.Peers"
+
+// LoadTrustedPeers reads the trusted peers from the config of r.
+func LoadTrustedPeers(r repo.Repo) ([]peer.ID, error) {
+	var ids []string
+	if _, err := repo.ReadConfigKey(r, TrustedPeersConfigKey, &ids); err != nil {
+		return nil, err
+	}
+	peers := make([]peer.ID, 0, len(ids))
+	for _, id := range ids {
+		p, err := peer.IDB58Decode(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer id %q in %s: %s", id, TrustedPeersConfigKey, err)
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+// StoreTrustedPeers writes the trusted peers to the config of r.
+func StoreTrustedPeers(r repo.Repo, peers []peer.ID) error {
+	ids := make([]string, len(peers))
+	for i, p := range peers {
+		ids[i] = p.Pretty()
+	}
+	return r.SetConfigKey(TrustedPeersConfigKey, ids)
+}
+
+// setupReputation starts scoring the peers, the trusted ones of the config
+// aside.
+func (n *IpfsNode) setupReputation() error {
+	trusted, err := LoadTrustedPeers(n.Repo)
+	if err != nil {
+		return err
+	}
+	n.Reputation = reputation.NewTracker()
+	for _, p := range trusted {
+		n.Reputation.Trust(p)
+	}
+	n.Reputation.Protect(n.PeerHost.ConnManager())
+	n.Reputation.Gate(n.PeerHost.Network())
+	return nil
+}
@@ -0,0 +1,157 @@
+package dagutils
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+	uio "github.com/ipfs/go-unixfs/io"
+)
+
+// DiffDirectories returns the set of changes that transform the unixfs
+// directory 'a' into the unixfs directory 'b'. Unlike Diff, it compares
+// directory entries by name and never descends into the chunks of a file: a
+// file whose CID differs is reported as a single Mod change.
+//
+// If recursive is set, entries that are directories on both sides are
+// compared entry by entry as well. Subtrees whose root CIDs match are never
+// fetched.
+func DiffDirectories(ctx context.Context, ds ipld.DAGService, a, b ipld.Node, recursive bool) ([]*Change, error) {
+	if a.Cid().Equals(b.Cid()) {
+		return []*Change{}, nil
+	}
+
+	linksA, err := directoryLinks(ctx, ds, a)
+	if err != nil {
+		return nil, err
+	}
+
+	linksB, err := directoryLinks(ctx, ds, b)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(linksA)+len(linksB))
+	for name := range linksA {
+		names = append(names, name)
+	}
+	for name := range linksB {
+		if _, ok := linksA[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	out := []*Change{}
+	for _, name := range names {
+		la, inA := linksA[name]
+		lb, inB := linksB[name]
+
+		switch {
+		case !inB:
+			out = append(out, &Change{
+				Type:   Remove,
+				Path:   name,
+				Before: la.Cid,
+			})
+		case !inA:
+			out = append(out, &Change{
+				Type:  Add,
+				Path:  name,
+				After: lb.Cid,
+			})
+		case la.Cid.Equals(lb.Cid):
+			// no change... ignore it
+		default:
+			if recursive {
+				sub, ok, err := diffSubdirectories(ctx, ds, la, lb)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					for _, subc := range sub {
+						subc.Path = path.Join(name, subc.Path)
+						out = append(out, subc)
+					}
+					continue
+				}
+			}
+
+			out = append(out, &Change{
+				Type:   Mod,
+				Path:   name,
+				Before: la.Cid,
+				After:  lb.Cid,
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// diffSubdirectories diffs the nodes behind two links if both are
+// directories. It reports false if either of them is not a directory.
+func diffSubdirectories(ctx context.Context, ds ipld.DAGService, la, lb *ipld.Link) ([]*Change, bool, error) {
+	na, err := la.GetNode(ctx, ds)
+	if err != nil {
+		return nil, false, err
+	}
+
+	nb, err := lb.GetNode(ctx, ds)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !IsDirectory(na) || !IsDirectory(nb) {
+		return nil, false, nil
+	}
+
+	sub, err := DiffDirectories(ctx, ds, na, nb, true)
+	return sub, true, err
+}
+
+// IsDirectory returns whether the given node is a unixfs directory, either
+// a basic one or a HAMT shard.
+func IsDirectory(nd ipld.Node) bool {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return false
+	}
+
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return false
+	}
+
+	switch fsn.Type() {
+	case ft.TDirectory, ft.THAMTShard:
+		return true
+	default:
+		return false
+	}
+}
+
+func directoryLinks(ctx context.Context, ds ipld.DAGService, nd ipld.Node) (map[string]*ipld.Link, error) {
+	if !IsDirectory(nd) {
+		return nil, fmt.Errorf("%s is not a unixfs directory", nd.Cid())
+	}
+
+	dir, err := uio.NewDirectoryFromNode(ds, nd)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make(map[string]*ipld.Link)
+	err = dir.ForEachLink(ctx, func(l *ipld.Link) error {
+		links[l.Name] = l
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
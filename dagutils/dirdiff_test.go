@@ -0,0 +1,137 @@
+package dagutils
+
+import (
+	"context"
+	"testing"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+	ft "github.com/ipfs/go-unixfs"
+	uio "github.com/ipfs/go-unixfs/io"
+)
+
+// mkUnixfsDir builds a unixfs directory from desc. String values become
+// files with that content, nested maps become subdirectories.
+func mkUnixfsDir(t *testing.T, ds ipld.DAGService, desc map[string]interface{}) ipld.Node {
+	ctx := context.Background()
+	dir := uio.NewDirectory(ds)
+	for name, v := range desc {
+		var child ipld.Node
+		switch v := v.(type) {
+		case string:
+			child = dag.NodeWithData(ft.FilePBData([]byte(v), uint64(len(v))))
+		case map[string]interface{}:
+			child = mkUnixfsDir(t, ds, v)
+		default:
+			t.Fatalf("unexpected type %T", v)
+		}
+
+		if err := ds.Add(ctx, child); err != nil {
+			t.Fatal(err)
+		}
+		if err := dir.AddChild(ctx, name, child); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nd, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.Add(ctx, nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func TestDiffDirectories(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	a := mkUnixfsDir(t, ds, map[string]interface{}{
+		"same":    "same",
+		"changed": "before",
+		"removed": "gone",
+		"sub": map[string]interface{}{
+			"keep": "keep",
+			"edit": "before",
+		},
+	})
+	b := mkUnixfsDir(t, ds, map[string]interface{}{
+		"same":    "same",
+		"changed": "after",
+		"added":   "new",
+		"sub": map[string]interface{}{
+			"keep": "keep",
+			"edit": "after",
+		},
+	})
+
+	changes, err := DiffDirectories(ctx, ds, a, b, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []struct {
+		typ  int
+		path string
+	}{
+		{Add, "added"},
+		{Mod, "changed"},
+		{Remove, "removed"},
+		{Mod, "sub"},
+	}
+	checkChanges(t, changes, expect)
+
+	changes, err = DiffDirectories(ctx, ds, a, b, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect = []struct {
+		typ  int
+		path string
+	}{
+		{Add, "added"},
+		{Mod, "changed"},
+		{Remove, "removed"},
+		{Mod, "sub/edit"},
+	}
+	checkChanges(t, changes, expect)
+
+	changes, err = DiffDirectories(ctx, ds, a, a, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes between identical directories, got %d", len(changes))
+	}
+}
+
+func TestDiffDirectoriesNotDirectory(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	dir := mkUnixfsDir(t, ds, map[string]interface{}{"a": "a"})
+	file := dag.NodeWithData(ft.FilePBData([]byte("file"), 4))
+
+	if _, err := DiffDirectories(ctx, ds, dir, file, false); err == nil {
+		t.Fatal("expected an error diffing a file")
+	}
+}
+
+func checkChanges(t *testing.T, changes []*Change, expect []struct {
+	typ  int
+	path string
+}) {
+	t.Helper()
+	if len(changes) != len(expect) {
+		t.Fatalf("expected %d changes, got %d", len(expect), len(changes))
+	}
+	for i, c := range changes {
+		if int(c.Type) != expect[i].typ || c.Path != expect[i].path {
+			t.Errorf("change %d: expected (%d, %q), got (%d, %q)", i, expect[i].typ, expect[i].path, c.Type, c.Path)
+		}
+	}
+}
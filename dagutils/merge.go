@@ -0,0 +1,98 @@
+package dagutils
+
+import (
+	"context"
+	"fmt"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+)
+
+// MergeNodes returns the union of the links of the dag-pb nodes 'a' and 'b',
+// by name. On a conflict, the link of 'b' wins; the data of the result is the
+// one of 'a'. The merged node, and the nodes created for it, are added to ds.
+//
+// If recursive is set, conflicting links pointing to unixfs directories on
+// both sides are merged the same way instead.
+//
+// HAMT shards are not supported: their links are not the entries of the
+// directory.
+func MergeNodes(ctx context.Context, ds ipld.DAGService, a, b ipld.Node, recursive bool) (*dag.ProtoNode, error) {
+	pa, ok := a.(*dag.ProtoNode)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s", a.Cid(), dag.ErrNotProtobuf)
+	}
+	pb, ok := b.(*dag.ProtoNode)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s", b.Cid(), dag.ErrNotProtobuf)
+	}
+	for _, pn := range []*dag.ProtoNode{pa, pb} {
+		if isShard(pn) {
+			return nil, fmt.Errorf("%s: merging sharded directories is not supported", pn.Cid())
+		}
+	}
+
+	out := pa.Copy().(*dag.ProtoNode)
+	for _, lb := range pb.Links() {
+		la, err := out.GetNodeLink(lb.Name)
+		if err == nil {
+			if la.Cid.Equals(lb.Cid) {
+				continue
+			}
+			if recursive {
+				sub, ok, err := mergeSubdirectories(ctx, ds, la, lb)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					_ = out.RemoveNodeLink(lb.Name)
+					if err := out.AddNodeLink(lb.Name, sub); err != nil {
+						return nil, err
+					}
+					continue
+				}
+			}
+			_ = out.RemoveNodeLink(lb.Name)
+		}
+
+		if err := out.AddRawLink(lb.Name, &ipld.Link{Name: lb.Name, Size: lb.Size, Cid: lb.Cid}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ds.Add(ctx, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// mergeSubdirectories merges the nodes behind two links if both are basic
+// unixfs directories. It reports false if either of them is not.
+func mergeSubdirectories(ctx context.Context, ds ipld.DAGService, la, lb *ipld.Link) (*dag.ProtoNode, bool, error) {
+	na, err := la.GetNode(ctx, ds)
+	if err != nil {
+		return nil, false, err
+	}
+
+	nb, err := lb.GetNode(ctx, ds)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !IsDirectory(na) || !IsDirectory(nb) || isShard(na) || isShard(nb) {
+		return nil, false, nil
+	}
+
+	sub, err := MergeNodes(ctx, ds, na, nb, true)
+	return sub, true, err
+}
+
+func isShard(nd ipld.Node) bool {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return false
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	return err == nil && fsn.Type() == ft.THAMTShard
+}
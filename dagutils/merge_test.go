@@ -0,0 +1,68 @@
+package dagutils
+
+import (
+	"context"
+	"testing"
+
+	mdtest "github.com/ipfs/go-merkledag/test"
+)
+
+func TestMergeNodes(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+
+	a := mkUnixfsDir(t, ds, map[string]interface{}{
+		"onlya":    "a",
+		"conflict": "from a",
+		"sub": map[string]interface{}{
+			"keep": "keep",
+			"edit": "before",
+		},
+	})
+	b := mkUnixfsDir(t, ds, map[string]interface{}{
+		"onlyb":    "b",
+		"conflict": "from b",
+		"sub": map[string]interface{}{
+			"edit":  "after",
+			"added": "new",
+		},
+	})
+
+	merged, err := MergeNodes(ctx, ds, a, b, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := mkUnixfsDir(t, ds, map[string]interface{}{
+		"onlya":    "a",
+		"onlyb":    "b",
+		"conflict": "from b",
+		"sub": map[string]interface{}{
+			"edit":  "after",
+			"added": "new",
+		},
+	})
+	if !merged.Cid().Equals(expect.Cid()) {
+		t.Fatalf("unexpected merge, expected %s, got %s", expect.Cid(), merged.Cid())
+	}
+
+	merged, err = MergeNodes(ctx, ds, a, b, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = mkUnixfsDir(t, ds, map[string]interface{}{
+		"onlya":    "a",
+		"onlyb":    "b",
+		"conflict": "from b",
+		"sub": map[string]interface{}{
+			"keep":  "keep",
+			"edit":  "after",
+			"added": "new",
+		},
+	})
+	if !merged.Cid().Equals(expect.Cid()) {
+		t.Fatalf("unexpected recursive merge, expected %s, got %s", expect.Cid(), merged.Cid())
+	}
+	if _, err := ds.Get(ctx, merged.Cid()); err != nil {
+		t.Fatalf("merged node not stored: %s", err)
+	}
+}
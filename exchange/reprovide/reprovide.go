@@ -18,6 +18,10 @@ var log = logging.Logger("reprovider")
 type KeyChanFunc func(context.Context) (<-chan cid.Cid, error)
 type doneFunc func(error)
 
+// KeyFilterFunc is called at the start of every reprovide run, and returns the
+// function deciding whether each key of the run is announced.
+type KeyFilterFunc func() func(cid.Cid) bool
+
 type Reprovider struct {
 	ctx     context.Context
 	trigger chan doneFunc
@@ -26,6 +30,7 @@ type Reprovider struct {
 	rsys routing.ContentRouting
 
 	keyProvider KeyChanFunc
+	filter      KeyFilterFunc
 }
 
 // NewReprovider creates new Reprovider instance.
@@ -39,6 +44,12 @@ func NewReprovider(ctx context.Context, rsys routing.ContentRouting, keyProvider
 	}
 }
 
+// SetFilter sets the filter applied to the keys before they are announced. It
+// must be called before Run.
+func (rp *Reprovider) SetFilter(filter KeyFilterFunc) {
+	rp.filter = filter
+}
+
 // Run re-provides keys with 'tick' interval or when triggered
 func (rp *Reprovider) Run(tick time.Duration) {
 	// dont reprovide immediately.
@@ -83,7 +94,15 @@ func (rp *Reprovider) Reprovide() error {
 	if err != nil {
 		return fmt.Errorf("failed to get key chan: %s", err)
 	}
+	announce := func(cid.Cid) bool { return true }
+	if rp.filter != nil {
+		announce = rp.filter()
+	}
 	for c := range keychan {
+		if !announce(c) {
+			continue
+		}
+
 		// hash security
 		if err := verifcid.ValidateCid(c); err != nil {
 			log.Errorf("insecure hash in reprovider, %s (%s)", c, err)
@@ -0,0 +1,124 @@
+// Package trace records the peers the blocks received over bitswap come
+// from, to debug slow or failed fetches.
+//
+// The bitswap sessions don't report where their blocks come from, so the
+// Recorder sits in the bitswap network instead: while a trace is running, it
+// notes the sender and the time of arrival of every block received.
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/go-bitswap/message"
+	bsnet "github.com/ipfs/go-bitswap/network"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// Receipt is the arrival of a block.
+type Receipt struct {
+	// Peer is the first peer which sent the block.
+	Peer peer.ID
+	Time time.Time
+	// Duplicates are the other peers which sent the block after Peer.
+	Duplicates []peer.ID
+}
+
+// Recorder dispatches the blocks received to the running traces.
+type Recorder struct {
+	mu     sync.Mutex
+	traces map[*Trace]struct{}
+}
+
+// NewRecorder returns a recorder without traces.
+func NewRecorder() *Recorder {
+	return &Recorder{traces: make(map[*Trace]struct{})}
+}
+
+// Start starts a trace recording the blocks received until it is stopped.
+func (r *Recorder) Start() *Trace {
+	t := &Trace{recorder: r, receipts: make(map[string]*Receipt)}
+	r.mu.Lock()
+	r.traces[t] = struct{}{}
+	r.mu.Unlock()
+	return t
+}
+
+func (r *Recorder) received(from peer.ID, c cid.Cid, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for t := range r.traces {
+		t.add(from, c, at)
+	}
+}
+
+// WrapBitswapNetwork returns a bitswap network recording the blocks received
+// through n.
+func (r *Recorder) WrapBitswapNetwork(n bsnet.BitSwapNetwork) bsnet.BitSwapNetwork {
+	return &tracingNetwork{BitSwapNetwork: n, recorder: r}
+}
+
+type tracingNetwork struct {
+	bsnet.BitSwapNetwork
+	recorder *Recorder
+}
+
+func (tn *tracingNetwork) SetDelegate(r bsnet.Receiver) {
+	tn.BitSwapNetwork.SetDelegate(&tracingReceiver{Receiver: r, recorder: tn.recorder})
+}
+
+type tracingReceiver struct {
+	bsnet.Receiver
+	recorder *Recorder
+}
+
+func (tr *tracingReceiver) ReceiveMessage(ctx context.Context, sender peer.ID, incoming bsmsg.BitSwapMessage) {
+	now := time.Now()
+	for _, b := range incoming.Blocks() {
+		tr.recorder.received(sender, b.Cid(), now)
+	}
+	tr.Receiver.ReceiveMessage(ctx, sender, incoming)
+}
+
+// Trace is the record of the blocks received while it runs.
+type Trace struct {
+	recorder *Recorder
+
+	mu sync.Mutex
+	// keyed by the multihashes of the blocks
+	receipts map[string]*Receipt
+}
+
+func (t *Trace) add(from peer.ID, c cid.Cid, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.receipts[string(c.Hash())]
+	if !ok {
+		t.receipts[string(c.Hash())] = &Receipt{Peer: from, Time: at}
+		return
+	}
+	r.Duplicates = append(r.Duplicates, from)
+}
+
+// Received returns the arrival of the block c, false if it wasn't received
+// since the trace started.
+func (t *Trace) Received(c cid.Cid) (Receipt, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.receipts[string(c.Hash())]
+	if !ok {
+		return Receipt{}, false
+	}
+	out := *r
+	out.Duplicates = append([]peer.ID(nil), r.Duplicates...)
+	return out, true
+}
+
+// Stop stops recording the blocks received.
+func (t *Trace) Stop() {
+	t.recorder.mu.Lock()
+	delete(t.recorder.traces, t)
+	t.recorder.mu.Unlock()
+}
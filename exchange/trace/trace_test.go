@@ -0,0 +1,71 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	bsmsg "github.com/ipfs/go-bitswap/message"
+	bsnet "github.com/ipfs/go-bitswap/network"
+	blocks "github.com/ipfs/go-block-format"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// fakeNetwork only keeps the delegate of bitswap.
+type fakeNetwork struct {
+	bsnet.BitSwapNetwork
+	delegate bsnet.Receiver
+}
+
+func (fn *fakeNetwork) SetDelegate(r bsnet.Receiver) {
+	fn.delegate = r
+}
+
+type fakeReceiver struct {
+	bsnet.Receiver
+	received int
+}
+
+func (fr *fakeReceiver) ReceiveMessage(ctx context.Context, sender peer.ID, incoming bsmsg.BitSwapMessage) {
+	fr.received += len(incoming.Blocks())
+}
+
+func TestTrace(t *testing.T) {
+	r := NewRecorder()
+	fn := &fakeNetwork{}
+	bs := &fakeReceiver{}
+	r.WrapBitswapNetwork(fn).SetDelegate(bs)
+
+	receive := func(from peer.ID, bl blocks.Block) {
+		msg := bsmsg.New(false)
+		msg.AddBlock(bl)
+		fn.delegate.ReceiveMessage(context.Background(), from, msg)
+	}
+
+	before := blocks.NewBlock([]byte("before"))
+	traced := blocks.NewBlock([]byte("traced"))
+	after := blocks.NewBlock([]byte("after"))
+
+	receive("alice", before)
+	tr := r.Start()
+	receive("alice", traced)
+	receive("bob", traced)
+	tr.Stop()
+	receive("alice", after)
+
+	if bs.received != 4 {
+		t.Fatalf("bitswap received %d blocks, expected 4", bs.received)
+	}
+	if _, ok := tr.Received(before.Cid()); ok {
+		t.Fatal("the blocks received before the trace are not recorded")
+	}
+	if _, ok := tr.Received(after.Cid()); ok {
+		t.Fatal("the blocks received after the trace are not recorded")
+	}
+	rc, ok := tr.Received(traced.Cid())
+	if !ok {
+		t.Fatal("the block received during the trace should be recorded")
+	}
+	if rc.Peer != "alice" || len(rc.Duplicates) != 1 || rc.Duplicates[0] != "bob" {
+		t.Fatalf("unexpected receipt %+v", rc)
+	}
+}
@@ -0,0 +1,94 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	ci "github.com/libp2p/go-libp2p-crypto"
+	pb "github.com/libp2p/go-libp2p-crypto/pb"
+)
+
+// HardenedOffset is added to the index of the hardened derivations. Only
+// hardened derivations are defined for ed25519 keys.
+const HardenedOffset = 0x80000000
+
+// ParseDerivationPath parses a BIP32 derivation path such as m/44'/501'/0'
+// into the indexes of its derivations. All of them must be hardened, marked
+// with a ' or an h.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: it must start with m", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if !hardened {
+			return nil, fmt.Errorf("invalid derivation path %q: %q is not hardened, ed25519 keys only have hardened derivations", path, part)
+		}
+		i, err := strconv.ParseUint(part[:len(part)-1], 10, 32)
+		if err != nil || i >= HardenedOffset {
+			return nil, fmt.Errorf("invalid derivation path %q: invalid index %q", path, part)
+		}
+		indexes = append(indexes, uint32(i)+HardenedOffset)
+	}
+	return indexes, nil
+}
+
+// DeriveEd25519 derives the ed25519 key at path from the ed25519 key parent,
+// following SLIP-0010: the master key is derived from the seed of parent,
+// and the keys of each level from the key and the chain code of the level
+// above. The same parent and path always give the same key.
+func DeriveEd25519(parent ci.PrivKey, path string) (ci.PrivKey, error) {
+	if parent.Type() != pb.KeyType_Ed25519 {
+		return nil, fmt.Errorf("only ed25519 keys can be derived, not %s keys", parent.Type())
+	}
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := parent.Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	// the private key is the seed followed by the public key
+	key, chain := slip10Master(raw[:32])
+	for _, i := range indexes {
+		key, chain = slip10Child(key, chain, i)
+	}
+
+	// ed25519 keys are generated from the 32 bytes of seed they read
+	sk, _, err := ci.GenerateEd25519Key(bytes.NewReader(key))
+	return sk, err
+}
+
+// slip10Master returns the master key and chain code of seed.
+func slip10Master(seed []byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+// slip10Child returns the key and chain code of the hardened child i of the
+// key and chain code given.
+func slip10Child(key, chain []byte, i uint32) ([]byte, []byte) {
+	data := make([]byte, 0, 37)
+	data = append(data, 0)
+	data = append(data, key...)
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], i)
+	data = append(data, index[:]...)
+
+	mac := hmac.New(sha512.New, chain)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
@@ -0,0 +1,86 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"testing"
+
+	ci "github.com/libp2p/go-libp2p-crypto"
+)
+
+// TestSLIP10Vectors checks the derivations of the first ed25519 test vector
+// of SLIP-0010.
+func TestSLIP10Vectors(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	vectors := []struct {
+		path  string
+		key   string
+		chain string
+	}{
+		{"m", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7", "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb"},
+		{"m/0'", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3", "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69"},
+		{"m/0'/1'", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2", ""},
+	}
+
+	for _, v := range vectors {
+		indexes, err := ParseDerivationPath(v.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, chain := slip10Master(seed)
+		for _, i := range indexes {
+			key, chain = slip10Child(key, chain, i)
+		}
+		if hex.EncodeToString(key) != v.key {
+			t.Errorf("%s: expected the key %s, got %x", v.path, v.key, key)
+		}
+		if v.chain != "" && hex.EncodeToString(chain) != v.chain {
+			t.Errorf("%s: expected the chain code %s, got %x", v.path, v.chain, chain)
+		}
+	}
+}
+
+func TestDeriveEd25519(t *testing.T) {
+	parent := privKeyOrFatal(t)
+
+	a, err := DeriveEd25519(parent, "m/44'/501'/0'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := DeriveEd25519(parent, "m/44h/501h/0h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Equals(b) {
+		t.Fatal("expected the same path to derive the same key")
+	}
+	c, err := DeriveEd25519(parent, "m/44'/501'/1'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Equals(c) {
+		t.Fatal("expected different paths to derive different keys")
+	}
+
+	// the derived key signs like any ed25519 key
+	sig, err := a.Sign([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := a.GetPublic().Verify([]byte("data"), sig); err != nil || !ok {
+		t.Fatalf("signature of the derived key not valid: %v", err)
+	}
+
+	for _, path := range []string{"44'/0'", "m/44'/0", "m/x'", "m/2147483648'"} {
+		if _, err := DeriveEd25519(parent, path); err == nil {
+			t.Errorf("expected an error deriving %q", path)
+		}
+	}
+
+	rsa, _, err := ci.GenerateRSAKeyPair(1024, rr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeriveEd25519(rsa, "m/0'"); err == nil {
+		t.Fatal("expected an error deriving an rsa key")
+	}
+}
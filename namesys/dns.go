@@ -5,7 +5,10 @@ import (
 	"errors"
 	"net"
 	"strings"
+	"sync"
+	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	path "github.com/ipfs/go-path"
 	opts "github.com/ipfs/interface-go-ipfs-core/options/namesys"
 	isd "github.com/jbenet/go-is-domain"
@@ -16,8 +19,15 @@ type LookupTXTFunc func(name string) (txt []string, err error)
 // DNSResolver implements a Resolver on DNS domains
 type DNSResolver struct {
 	lookupTXT LookupTXTFunc
-	// TODO: maybe some sort of caching?
-	// cache would need a timeout
+	// lookupTXTTTL, if set, is used instead of lookupTXT
+	lookupTXTTTL LookupTXTTTLFunc
+
+	// the TXT records of the domains, kept for their TTL capped to cacheTTL
+	cache    *lru.Cache
+	cacheTTL time.Duration
+
+	mu      sync.Mutex
+	lookups map[string]*txtLookup
 }
 
 // NewDNSResolver constructs a name resolver using DNS TXT records.
@@ -36,8 +46,10 @@ func (r *DNSResolver) ResolveAsync(ctx context.Context, name string, options ...
 }
 
 type lookupRes struct {
-	path  path.Path
-	error error
+	path   path.Path
+	ttl    time.Duration
+	cached bool
+	error  error
 }
 
 // resolveOnce implements resolver.
@@ -86,7 +98,7 @@ func (r *DNSResolver) resolveOnceAsync(ctx context.Context, name string, options
 				}
 				if subRes.error == nil {
 					p, err := appendPath(subRes.path)
-					emitOnceResult(ctx, out, onceResult{value: p, err: err})
+					emitOnceResult(ctx, out, onceResult{value: p, ttl: subRes.ttl, err: err})
 					return
 				}
 			case rootRes, ok := <-rootChan:
@@ -96,7 +108,7 @@ func (r *DNSResolver) resolveOnceAsync(ctx context.Context, name string, options
 				}
 				if rootRes.error == nil {
 					p, err := appendPath(rootRes.path)
-					emitOnceResult(ctx, out, onceResult{value: p, err: err})
+					emitOnceResult(ctx, out, onceResult{value: p, ttl: rootRes.ttl, err: err})
 				}
 			case <-ctx.Done():
 				return
@@ -113,21 +125,21 @@ func (r *DNSResolver) resolveOnceAsync(ctx context.Context, name string, options
 func workDomain(r *DNSResolver, name string, res chan lookupRes) {
 	defer close(res)
 
-	txt, err := r.lookupTXT(name)
+	txt, ttl, cached, err := r.lookup(name)
 	if err != nil {
 		// Error is != nil
-		res <- lookupRes{"", err}
+		res <- lookupRes{error: err}
 		return
 	}
 
 	for _, t := range txt {
 		p, err := parseEntry(t)
 		if err == nil {
-			res <- lookupRes{p, nil}
+			res <- lookupRes{p, ttl, cached, nil}
 			return
 		}
 	}
-	res <- lookupRes{"", ErrResolveFailed}
+	res <- lookupRes{error: ErrResolveFailed}
 }
 
 func parseEntry(txt string) (path.Path, error) {
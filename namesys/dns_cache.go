@@ -0,0 +1,250 @@
+package namesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	path "github.com/ipfs/go-path"
+	isd "github.com/jbenet/go-is-domain"
+	dns "github.com/miekg/dns"
+)
+
+const (
+	// DefaultDNSLinkCacheSize is the default number of domains of which the
+	// TXT records are cached.
+	DefaultDNSLinkCacheSize = 1000
+
+	// DefaultDNSLinkCacheTTL is the default longest time the TXT records
+	// are cached, shortened to the TTL of the records.
+	DefaultDNSLinkCacheTTL = time.Minute
+)
+
+// maxCNAMEChain is the longest chain of CNAME records followed to the TXT
+// records of a name.
+const maxCNAMEChain = 8
+
+// LookupTXTTTLFunc looks up the TXT records of a name, with the time they
+// may be cached for, 0 if unknown.
+type LookupTXTTTLFunc func(name string) (txt []string, ttl time.Duration, err error)
+
+type txtCacheEntry struct {
+	txt []string
+	eol time.Time
+}
+
+// txtLookup is a lookup of the TXT records of a name in progress, which the
+// concurrent lookups of the same name wait for.
+type txtLookup struct {
+	done chan struct{}
+	txt  []string
+	ttl  time.Duration
+	err  error
+}
+
+// NewCachingDNSResolver constructs a name resolver using DNS TXT records,
+// caching the records of up to size domains for the TTL of the records, at
+// most ttl. The TTLs are read from the DNS servers of /etc/resolv.conf; on
+// the systems without it, the records are looked up with net.LookupTXT and
+// cached for ttl.
+func NewCachingDNSResolver(size int, ttl time.Duration) (*DNSResolver, error) {
+	r := &DNSResolver{
+		lookupTXT: net.LookupTXT,
+		cacheTTL:  ttl,
+	}
+	if conf, err := dns.ClientConfigFromFile("/etc/resolv.conf"); err == nil && len(conf.Servers) > 0 {
+		r.lookupTXTTTL = lookupTXTWithTTL(conf)
+	}
+	if size > 0 && ttl > 0 {
+		cache, err := lru.New(size)
+		if err != nil {
+			return nil, err
+		}
+		r.cache = cache
+	}
+	return r, nil
+}
+
+// DNSLink is the DNSLink record of a domain.
+type DNSLink struct {
+	// Record is the name of the TXT record holding the link, the domain
+	// itself or its _dnslink subdomain.
+	Record string
+	Path   path.Path
+	TTL    time.Duration
+	Cached bool
+}
+
+// LookupDNSLink returns the DNSLink record of domain, without resolving its
+// path further. As for the resolution, the records of the domain and of its
+// _dnslink subdomain are looked up concurrently, and the latter is preferred.
+func (r *DNSResolver) LookupDNSLink(ctx context.Context, domain string) (*DNSLink, error) {
+	if !isd.IsDomain(domain) {
+		return nil, errors.New("not a valid domain name")
+	}
+	fqdn := dns.Fqdn(domain)
+
+	rootChan := make(chan lookupRes, 1)
+	go workDomain(r, fqdn, rootChan)
+	subChan := make(chan lookupRes, 1)
+	go workDomain(r, "_dnslink."+fqdn, subChan)
+
+	var rootRes, subRes lookupRes
+	select {
+	case subRes = <-subChan:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if subRes.error == nil {
+		return &DNSLink{"_dnslink." + fqdn, subRes.path, subRes.ttl, subRes.cached}, nil
+	}
+	select {
+	case rootRes = <-rootChan:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if rootRes.error == nil {
+		return &DNSLink{fqdn, rootRes.path, rootRes.ttl, rootRes.cached}, nil
+	}
+	return nil, ErrResolveFailed
+}
+
+// lookup returns the TXT records of name, from the cache if they are still
+// valid, and how long they are valid for.
+func (r *DNSResolver) lookup(name string) ([]string, time.Duration, bool, error) {
+	if txt, ttl, ok := r.cacheGet(name); ok {
+		return txt, ttl, true, nil
+	}
+
+	r.mu.Lock()
+	if r.lookups == nil {
+		r.lookups = make(map[string]*txtLookup)
+	}
+	l, ok := r.lookups[name]
+	if !ok {
+		l = &txtLookup{done: make(chan struct{})}
+		r.lookups[name] = l
+	}
+	r.mu.Unlock()
+	if ok {
+		<-l.done
+		return l.txt, l.ttl, false, l.err
+	}
+
+	if r.lookupTXTTTL != nil {
+		l.txt, l.ttl, l.err = r.lookupTXTTTL(name)
+		if l.err != nil && r.lookupTXT != nil {
+			// the system resolver may know better, like with a
+			// server not answering in /etc/resolv.conf
+			log.Debugf("looking up the TXT records of %s with their TTL: %s", name, l.err)
+			l.txt, l.err = r.lookupTXT(name)
+			l.ttl = 0
+		}
+	} else {
+		l.txt, l.err = r.lookupTXT(name)
+	}
+	if r.cacheTTL > 0 && (l.ttl <= 0 || l.ttl > r.cacheTTL) {
+		l.ttl = r.cacheTTL
+	}
+	if l.err == nil {
+		r.cacheSet(name, l.txt, l.ttl)
+	}
+
+	r.mu.Lock()
+	delete(r.lookups, name)
+	r.mu.Unlock()
+	close(l.done)
+	return l.txt, l.ttl, false, l.err
+}
+
+func (r *DNSResolver) cacheGet(name string) ([]string, time.Duration, bool) {
+	if r.cache == nil {
+		return nil, 0, false
+	}
+	ientry, ok := r.cache.Get(name)
+	if !ok {
+		return nil, 0, false
+	}
+	entry := ientry.(txtCacheEntry)
+	if ttl := time.Until(entry.eol); ttl > 0 {
+		return entry.txt, ttl, true
+	}
+	r.cache.Remove(name)
+	return nil, 0, false
+}
+
+func (r *DNSResolver) cacheSet(name string, txt []string, ttl time.Duration) {
+	if r.cache == nil || ttl <= 0 {
+		return
+	}
+	r.cache.Add(name, txtCacheEntry{txt: txt, eol: time.Now().Add(ttl)})
+}
+
+// lookupTXTWithTTL returns a lookup of the TXT records asking the servers of
+// conf, which follows the CNAME records the servers don't, and returns the
+// smallest TTL of the records of the chain.
+func lookupTXTWithTTL(conf *dns.ClientConfig) LookupTXTTTLFunc {
+	timeout := time.Duration(conf.Timeout) * time.Second
+	udp := &dns.Client{Timeout: timeout}
+	tcp := &dns.Client{Net: "tcp", Timeout: timeout}
+	return func(name string) ([]string, time.Duration, error) {
+		name = dns.Fqdn(name)
+		var minTTL uint32
+		for i := 0; i < maxCNAMEChain; i++ {
+			resp, err := exchange(udp, conf, name)
+			if err == nil && resp.Truncated {
+				// the records don't fit in a UDP response
+				resp, err = exchange(tcp, conf, name)
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			if resp.Rcode != dns.RcodeSuccess {
+				return nil, 0, fmt.Errorf("lookup %s: %s", name, dns.RcodeToString[resp.Rcode])
+			}
+
+			var txt []string
+			target := ""
+			for _, rr := range resp.Answer {
+				if minTTL == 0 || rr.Header().Ttl < minTTL {
+					minTTL = rr.Header().Ttl
+				}
+				switch rr := rr.(type) {
+				case *dns.TXT:
+					txt = append(txt, strings.Join(rr.Txt, ""))
+				case *dns.CNAME:
+					target = rr.Target
+				}
+			}
+			if len(txt) > 0 {
+				return txt, time.Duration(minTTL) * time.Second, nil
+			}
+			if target == "" {
+				return nil, 0, fmt.Errorf("lookup %s: no TXT records", name)
+			}
+			name = target
+		}
+		return nil, 0, errors.New("lookup: CNAME chain too long")
+	}
+}
+
+// exchange asks the TXT records of name to the servers of conf in turn.
+func exchange(client *dns.Client, conf *dns.ClientConfig, name string) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeTXT)
+	// the DNSLink records, with other TXT records, may not fit in 512 bytes
+	m.SetEdns0(4096, false)
+	var err error
+	for _, server := range conf.Servers {
+		var resp *dns.Msg
+		resp, _, err = client.Exchange(m, net.JoinHostPort(server, conf.Port))
+		if err == nil {
+			return resp, nil
+		}
+	}
+	return nil, err
+}
@@ -1,10 +1,15 @@
 package namesys
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"sync"
 	"testing"
+	"time"
 
 	opts "github.com/ipfs/interface-go-ipfs-core/options/namesys"
+	dns "github.com/miekg/dns"
 )
 
 type mockDNS struct {
@@ -164,3 +169,163 @@ func TestDNSResolution(t *testing.T) {
 	testResolution(t, r, "conflict.example.com", opts.DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjE", nil)
 	testResolution(t, r, "fqdn.example.com.", opts.DefaultDepthLimit, "/ipfs/QmYvMB9yrsSf7RKBghkfwmHJkzJhW2ZgVwq3LxBXXPasFr", nil)
 }
+
+func TestDNSLinkCache(t *testing.T) {
+	var mu sync.Mutex
+	lookups := make(map[string]int)
+	r, err := NewCachingDNSResolver(10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.lookupTXTTTL = func(name string) ([]string, time.Duration, error) {
+		mu.Lock()
+		lookups[name]++
+		mu.Unlock()
+		switch name {
+		case "_dnslink.short.example.com.":
+			return []string{"dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"}, time.Second, nil
+		case "_dnslink.long.example.com.":
+			return []string{"dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"}, time.Hour, nil
+		}
+		return nil, 0, fmt.Errorf("no TXT entry for %s", name)
+	}
+
+	link, err := r.LookupDNSLink(context.Background(), "long.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Cached || link.TTL != time.Minute || link.Record != "_dnslink.long.example.com." {
+		t.Fatalf("unexpected first lookup %+v", link)
+	}
+	link, err = r.LookupDNSLink(context.Background(), "long.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !link.Cached || link.TTL > time.Minute {
+		t.Fatalf("expected the TTL capped to a minute to be cached, got %+v", link)
+	}
+
+	// the short TTL expires
+	if _, err := r.LookupDNSLink(context.Background(), "short.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := r.LookupDNSLink(context.Background(), "short.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if n := lookups["_dnslink.short.example.com."]; n != 2 {
+		t.Fatalf("expected the expired records to be looked up again, got %d lookups", n)
+	}
+}
+
+func TestDNSLinkCNAME(t *testing.T) {
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		name := req.Question[0].Name
+		switch name {
+		case "_dnslink.site.example.com.":
+			rr, _ := dns.NewRR(name + " 300 IN CNAME _dnslink.hosting.example.net.")
+			m.Answer = append(m.Answer, rr)
+		case "_dnslink.hosting.example.net.":
+			rr, _ := dns.NewRR(name + ` 30 IN TXT "dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"`)
+			m.Answer = append(m.Answer, rr)
+		default:
+			m.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(m)
+	})
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: mux}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	host, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	r := &DNSResolver{
+		lookupTXTTTL: lookupTXTWithTTL(&dns.ClientConfig{Servers: []string{host}, Port: port, Timeout: 1}),
+		cacheTTL:     time.Minute,
+	}
+	link, err := r.LookupDNSLink(context.Background(), "site.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Path != "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD" {
+		t.Fatalf("unexpected link %s", link.Path)
+	}
+	if link.TTL != 30*time.Second {
+		t.Fatalf("expected the smallest TTL of the chain, got %s", link.TTL)
+	}
+}
+
+func TestDNSLinkFallback(t *testing.T) {
+	mock := &mockDNS{entries: map[string][]string{
+		"_dnslink.example.com.": {"dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"},
+	}}
+	r := &DNSResolver{
+		lookupTXT: mock.lookupTXT,
+		lookupTXTTTL: func(name string) ([]string, time.Duration, error) {
+			return nil, 0, fmt.Errorf("no server answered")
+		},
+		cacheTTL: time.Minute,
+	}
+	link, err := r.LookupDNSLink(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Path != "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD" || link.TTL != time.Minute {
+		t.Fatalf("expected the record of the system resolver, got %+v", link)
+	}
+}
+
+func TestDNSLinkTruncated(t *testing.T) {
+	const record = "_dnslink.example.com."
+	handler := func(truncate bool) dns.HandlerFunc {
+		return func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			switch {
+			case req.Question[0].Name != record:
+				m.Rcode = dns.RcodeNameError
+			case truncate:
+				m.Truncated = true
+			default:
+				rr, _ := dns.NewRR(record + ` 30 IN TXT "dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"`)
+				m.Answer = append(m.Answer, rr)
+			}
+			w.WriteMsg(m)
+		}
+	}
+
+	// the same port for UDP and TCP
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Skipf("listening on TCP on the port of the UDP server: %s", err)
+	}
+	udp := &dns.Server{PacketConn: pc, Handler: handler(true)}
+	tcp := &dns.Server{Listener: l, Handler: handler(false)}
+	go udp.ActivateAndServe()
+	go tcp.ActivateAndServe()
+	defer udp.Shutdown()
+	defer tcp.Shutdown()
+
+	host, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	lookup := lookupTXTWithTTL(&dns.ClientConfig{Servers: []string{host}, Port: port, Timeout: 1})
+	txt, ttl, err := lookup(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txt) != 1 || ttl != 30*time.Second {
+		t.Fatalf("expected the records over TCP, got %v %s", txt, ttl)
+	}
+}
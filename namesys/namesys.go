@@ -34,13 +34,19 @@ type mpns struct {
 
 // NewNameSystem will construct the IPFS naming system based on Routing
 func NewNameSystem(r routing.ValueStore, ds ds.Datastore, cachesize int) NameSystem {
+	return NewNameSystemWithDNS(r, ds, cachesize, NewDNSResolver())
+}
+
+// NewNameSystemWithDNS constructs the IPFS naming system resolving the DNS
+// domains with dnsResolver.
+func NewNameSystemWithDNS(r routing.ValueStore, ds ds.Datastore, cachesize int, dnsResolver *DNSResolver) NameSystem {
 	var cache *lru.Cache
 	if cachesize > 0 {
 		cache, _ = lru.New(cachesize)
 	}
 
 	return &mpns{
-		dnsResolver:      NewDNSResolver(),
+		dnsResolver:      dnsResolver,
 		proquintResolver: new(ProquintResolver),
 		ipnsResolver:     NewIpnsResolver(r),
 		ipnsPublisher:    NewIpnsPublisher(r, ds),
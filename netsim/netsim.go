@@ -0,0 +1,302 @@
+// Package netsim simulates the latency and the bandwidth of a constrained
+// network on the connections of a node, to test applications locally
+// without traffic shaping in the OS.
+//
+// The Shaper wraps the connections handed by libp2p to the stream muxers.
+// While a simulation runs, the data written is sent after the latency, at
+// the rate of a token bucket shared by all the connections, and the data
+// read is throttled by a second bucket. QUIC connections have their own
+// muxer and are not shaped.
+package netsim
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	smux "github.com/libp2p/go-stream-muxer"
+)
+
+// ErrActive is returned when starting a simulation while one runs.
+var ErrActive = errors.New("a network simulation is already running")
+
+var errClosed = errors.New("connection closed")
+
+// writeQueueLength is the number of writes a connection buffers before
+// blocking.
+const writeQueueLength = 64
+
+// closeSendTimeout bounds the time Close takes to send the data queued.
+const closeSendTimeout = 5 * time.Second
+
+// Shaper applies the simulation to the connections it wraps.
+type Shaper struct {
+	mu        sync.Mutex
+	active    bool
+	latency   time.Duration
+	bandwidth float64
+
+	up   bucket
+	down bucket
+}
+
+// NewShaper returns a shaper without simulation.
+func NewShaper() *Shaper {
+	return &Shaper{}
+}
+
+// Start starts simulating a network with the given latency, added to the
+// data sent, and bandwidth in bytes per second, 0 for no limit.
+func (s *Shaper) Start(latency time.Duration, bandwidth float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active {
+		return ErrActive
+	}
+	s.active = true
+	s.latency = latency
+	s.bandwidth = bandwidth
+	s.up.reset(bandwidth)
+	s.down.reset(bandwidth)
+	return nil
+}
+
+// Stop stops the simulation.
+func (s *Shaper) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = false
+	s.latency = 0
+	s.bandwidth = 0
+	s.up.reset(0)
+	s.down.reset(0)
+}
+
+// Simulation returns the parameters of the running simulation, false if
+// there is none.
+func (s *Shaper) Simulation() (latency time.Duration, bandwidth float64, active bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latency, s.bandwidth, s.active
+}
+
+// sendTime returns when n bytes written now must be sent.
+func (s *Shaper) sendTime(n int) time.Time {
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	return time.Now().Add(s.up.take(n) + latency)
+}
+
+// WrapConn returns c shaped by the simulations of s.
+func (s *Shaper) WrapConn(c net.Conn) net.Conn {
+	sc := &conn{
+		Conn:  c,
+		s:     s,
+		queue: make(chan packet, writeQueueLength),
+		done:  make(chan struct{}),
+		sent:  make(chan struct{}),
+	}
+	go sc.sendLoop()
+	return sc
+}
+
+// WrapMuxer returns a stream muxer transport whose connections are shaped.
+func (s *Shaper) WrapMuxer(t smux.Transport) smux.Transport {
+	return &muxer{Transport: t, s: s}
+}
+
+type muxer struct {
+	smux.Transport
+	s *Shaper
+}
+
+func (m *muxer) NewConn(nc net.Conn, isServer bool) (smux.Conn, error) {
+	return m.Transport.NewConn(m.s.WrapConn(nc), isServer)
+}
+
+// bucket is a token bucket of bytes. It goes into debt rather than
+// splitting the large writes: the next ones wait for the debt to be paid.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) reset(rate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = rate
+	b.tokens = b.burst()
+	b.last = time.Now()
+}
+
+// burst is a tenth of a second of traffic, with at least a few packets.
+func (b *bucket) burst() float64 {
+	if burst := b.rate / 10; burst > 16<<10 {
+		return burst
+	}
+	return 16 << 10
+}
+
+// take takes n tokens and returns how long to wait for them.
+func (b *bucket) take(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	b.tokens += b.rate * now.Sub(b.last).Seconds()
+	if burst := b.burst(); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+type packet struct {
+	data []byte
+	due  time.Time
+}
+
+// conn delays its writes in a queue, sent in order by sendLoop.
+type conn struct {
+	net.Conn
+	s *Shaper
+
+	queue chan packet
+	done  chan struct{} // closed by Close
+	sent  chan struct{} // closed by sendLoop once the queue is sent
+	once  sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *conn) sendLoop() {
+	defer close(c.sent)
+	for {
+		select {
+		case p := <-c.queue:
+			c.send(p)
+		case <-c.done:
+			// the data written before Close is sent at once, without
+			// blocking Close on a peer that doesn't read
+			c.Conn.SetWriteDeadline(time.Now().Add(closeSendTimeout))
+			for {
+				select {
+				case p := <-c.queue:
+					c.send(p)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send writes p once it is due, or at once if the connection is closing.
+// After a failed write, the rest of the queue is dropped.
+func (c *conn) send(p packet) {
+	if d := time.Until(p.due); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-c.done:
+			t.Stop()
+		}
+	}
+
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return
+	}
+	if _, err := c.Conn.Write(p.data); err != nil {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		// the reads fail too, and the muxer closes the connection
+		c.Conn.Close()
+	}
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	select {
+	case <-c.done:
+		return 0, errClosed
+	default:
+	}
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	p := packet{data: append([]byte(nil), b...), due: c.s.sendTime(len(b))}
+	select {
+	case c.queue <- p:
+		return len(b), nil
+	case <-c.done:
+		return 0, errClosed
+	}
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		if d := c.s.down.take(n); d > 0 {
+			time.Sleep(d)
+		}
+	}
+	return n, err
+}
+
+// Close sends the data queued and closes the connection.
+func (c *conn) Close() error {
+	err := errClosed
+	c.once.Do(func() {
+		close(c.done)
+		<-c.sent
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// ParseBandwidth parses a bandwidth in bits per second, like "10Mbps", and
+// returns it in bytes per second. The units are decimal.
+func ParseBandwidth(s string) (float64, error) {
+	units := []struct {
+		suffix string
+		bits   float64
+	}{
+		{"gbps", 1e9},
+		{"mbps", 1e6},
+		{"kbps", 1e3},
+		{"bps", 1},
+	}
+	num, mult := strings.ToLower(strings.TrimSpace(s)), float64(1)
+	for _, u := range units {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.bits
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q, expected a number of bits per second like 10Mbps", s)
+	}
+	return v * mult / 8, nil
+}
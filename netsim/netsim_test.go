@@ -0,0 +1,135 @@
+package netsim
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// transfer writes size bytes to a shaped pipe and returns how long it takes
+// to read them on the other end.
+func transfer(t *testing.T, s *Shaper, size int) time.Duration {
+	a, b := net.Pipe()
+	c := s.WrapConn(a)
+	defer c.Close()
+	defer b.Close()
+
+	start := time.Now()
+	go func() {
+		buf := make([]byte, 1024)
+		for written := 0; written < size; written += len(buf) {
+			if _, err := c.Write(buf); err != nil {
+				return
+			}
+		}
+	}()
+	if _, err := io.CopyN(io.Discard, b, int64(size)); err != nil {
+		t.Fatal(err)
+	}
+	return time.Since(start)
+}
+
+func TestNoSimulation(t *testing.T) {
+	if d := transfer(t, NewShaper(), 1<<20); d > time.Second {
+		t.Fatalf("took %s without simulation", d)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	s := NewShaper()
+	if err := s.Start(100*time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	if d := transfer(t, s, 1024); d < 100*time.Millisecond {
+		t.Fatalf("took %s with a latency of 100ms", d)
+	}
+
+	s.Stop()
+	if d := transfer(t, s, 1024); d >= 100*time.Millisecond {
+		t.Fatalf("took %s after stopping the simulation", d)
+	}
+}
+
+func TestBandwidth(t *testing.T) {
+	s := NewShaper()
+	// 200KB/s, the first 16KiB being the burst
+	if err := s.Start(0, 200e3); err != nil {
+		t.Fatal(err)
+	}
+	d := transfer(t, s, 100<<10)
+	if d < 350*time.Millisecond || d > 2*time.Second {
+		t.Fatalf("took %s to send 100KiB at 200KB/s", d)
+	}
+}
+
+func TestCloseSendsQueue(t *testing.T) {
+	s := NewShaper()
+	if err := s.Start(100*time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	a, b := net.Pipe()
+	c := s.WrapConn(a)
+	defer b.Close()
+
+	data := make([]byte, 10<<10)
+	received := make(chan error)
+	go func() {
+		_, err := io.ReadFull(b, make([]byte, len(data)))
+		received <- err
+	}()
+	for i := 0; i < len(data); i += 1024 {
+		if _, err := c.Write(data[i : i+1024]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-received; err != nil {
+		t.Fatalf("the data written before Close was not sent: %s", err)
+	}
+	if _, err := c.Write(data); err == nil {
+		t.Fatal("expected an error writing after Close")
+	}
+}
+
+func TestStartTwice(t *testing.T) {
+	s := NewShaper()
+	if err := s.Start(time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(time.Millisecond, 0); err != ErrActive {
+		t.Fatalf("expected ErrActive, got %v", err)
+	}
+	if _, _, active := s.Simulation(); !active {
+		t.Fatal("the simulation should run")
+	}
+	s.Stop()
+	if err := s.Start(time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseBandwidth(t *testing.T) {
+	for in, want := range map[string]float64{
+		"8":        1,
+		"800bps":   100,
+		"10kbps":   1250,
+		"10Mbps":   1.25e6,
+		"1.5 Gbps": 1.875e8,
+	} {
+		got, err := ParseBandwidth(in)
+		if err != nil {
+			t.Fatalf("%q: %s", in, err)
+		}
+		if got != want {
+			t.Fatalf("%q: expected %v bytes/s, got %v", in, want, got)
+		}
+	}
+	for _, in := range []string{"", "fast", "-1Mbps", "10MB"} {
+		if _, err := ParseBandwidth(in); err == nil {
+			t.Fatalf("%q: expected an error", in)
+		}
+	}
+}
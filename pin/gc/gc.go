@@ -140,6 +140,49 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 	return output
 }
 
+// Reclaimable returns the size of the blocks a GC would remove now. Unlike
+// GC, it does not take the GC lock: the blocks added meanwhile may be counted.
+func Reclaimable(ctx context.Context, bs bstore.Blockstore, pn pin.Pinner, bestEffortRoots []cid.Cid) (uint64, error) {
+	ds := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	output := make(chan Result, 128)
+	var errs []error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for r := range output {
+			errs = append(errs, r.Error)
+		}
+	}()
+	gcs, err := ColoredSet(ctx, pn, ds, bestEffortRoots, output)
+	close(output)
+	<-done
+	if err != nil {
+		if len(errs) > 0 {
+			return 0, fmt.Errorf("%s: %s", err, errs[0])
+		}
+		return 0, err
+	}
+
+	keychan, err := bs.AllKeysChan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var size uint64
+	for k := range keychan {
+		if gcs.Has(k) {
+			continue
+		}
+		s, err := bs.GetSize(k)
+		if err != nil {
+			// removed meanwhile
+			continue
+		}
+		size += uint64(s)
+	}
+	return size, ctx.Err()
+}
+
 // Descendants recursively finds all the descendants of the given roots and
 // adds them to the given cid.Set, using the provided dag.GetLinks function
 // to walk the tree.
@@ -0,0 +1,133 @@
+package pin
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+var pinTimesPrefix = ds.NewKey("/local/pintimes")
+
+// TimedPinner wraps a Pinner to record when each recursive pin was created.
+// Pins created before it was in use have no time. The subscribers are
+// notified of the new recursive pins.
+type TimedPinner struct {
+	Pinner
+	dstore ds.Datastore
+
+	subsLk sync.Mutex
+	subs   map[chan struct{}]struct{}
+}
+
+// NewTimedPinner returns a TimedPinner storing the pin times in dstore.
+func NewTimedPinner(p Pinner, dstore ds.Datastore) *TimedPinner {
+	return &TimedPinner{Pinner: p, dstore: dstore, subs: make(map[chan struct{}]struct{})}
+}
+
+// Subscribe returns a channel receiving a value after new recursive pins are
+// created, and a function to cancel the subscription. The pins created while
+// a value is pending are notified by the same value.
+func (p *TimedPinner) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	p.subsLk.Lock()
+	p.subs[ch] = struct{}{}
+	p.subsLk.Unlock()
+	return ch, func() {
+		p.subsLk.Lock()
+		delete(p.subs, ch)
+		p.subsLk.Unlock()
+	}
+}
+
+func (p *TimedPinner) notify() {
+	p.subsLk.Lock()
+	defer p.subsLk.Unlock()
+	for ch := range p.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func pinTimeKey(c cid.Cid) ds.Key {
+	return pinTimesPrefix.ChildString(c.String())
+}
+
+// PinTime returns the time c was pinned recursively, if known.
+func (p *TimedPinner) PinTime(c cid.Cid) (time.Time, bool) {
+	val, err := p.dstore.Get(pinTimeKey(c))
+	if err != nil || len(val) != 8 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(val))), true
+}
+
+// recordPinTime keeps the time c was first pinned, a pin of a CID already
+// pinned doesn't reset it.
+func (p *TimedPinner) recordPinTime(c cid.Cid) {
+	k := pinTimeKey(c)
+	if has, err := p.dstore.Has(k); err == nil && has {
+		return
+	}
+	var val [8]byte
+	binary.BigEndian.PutUint64(val[:], uint64(time.Now().UnixNano()))
+	if err := p.dstore.Put(k, val[:]); err != nil {
+		log.Warningf("recording the pin time of %s: %s", c, err)
+	}
+	p.notify()
+}
+
+func (p *TimedPinner) removePinTime(c cid.Cid) {
+	if err := p.dstore.Delete(pinTimeKey(c)); err != nil && err != ds.ErrNotFound {
+		log.Warningf("removing the pin time of %s: %s", c, err)
+	}
+}
+
+func (p *TimedPinner) Pin(ctx context.Context, node ipld.Node, recursive bool) error {
+	if err := p.Pinner.Pin(ctx, node, recursive); err != nil {
+		return err
+	}
+	if recursive {
+		p.recordPinTime(node.Cid())
+	}
+	return nil
+}
+
+func (p *TimedPinner) Unpin(ctx context.Context, c cid.Cid, recursive bool) error {
+	if err := p.Pinner.Unpin(ctx, c, recursive); err != nil {
+		return err
+	}
+	p.removePinTime(c)
+	return nil
+}
+
+func (p *TimedPinner) Update(ctx context.Context, from, to cid.Cid, unpin bool) error {
+	if err := p.Pinner.Update(ctx, from, to, unpin); err != nil {
+		return err
+	}
+	p.recordPinTime(to)
+	if unpin {
+		p.removePinTime(from)
+	}
+	return nil
+}
+
+func (p *TimedPinner) PinWithMode(c cid.Cid, mode Mode) {
+	p.Pinner.PinWithMode(c, mode)
+	if mode == Recursive {
+		p.recordPinTime(c)
+	}
+}
+
+func (p *TimedPinner) RemovePinWithMode(c cid.Cid, mode Mode) {
+	p.Pinner.RemovePinWithMode(c, mode)
+	if mode == Recursive {
+		p.removePinTime(c)
+	}
+}
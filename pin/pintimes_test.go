@@ -0,0 +1,132 @@
+package pin
+
+import (
+	"context"
+	"testing"
+
+	bs "github.com/ipfs/go-blockservice"
+	mdag "github.com/ipfs/go-merkledag"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+)
+
+func TestTimedPinner(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewTimedPinner(NewPinner(dstore, dserv, dserv), dstore)
+
+	a, ak := randNode()
+	b, bk := randNode()
+	for _, nd := range []*mdag.ProtoNode{a, b} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := p.Pin(ctx, a, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.PinTime(ak); ok {
+		t.Fatal("direct pins should have no time")
+	}
+
+	if err := p.Unpin(ctx, ak, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	first, ok := p.PinTime(ak)
+	if !ok {
+		t.Fatal("recursive pin has no time")
+	}
+
+	// pinning again keeps the first time
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := p.PinTime(ak); !again.Equal(first) {
+		t.Fatal("pin time changed by a second pin")
+	}
+
+	if err := p.Update(ctx, ak, bk, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.PinTime(ak); ok {
+		t.Fatal("pin time of the old root not removed by update")
+	}
+	if _, ok := p.PinTime(bk); !ok {
+		t.Fatal("pin time of the new root not recorded by update")
+	}
+
+	if err := p.Unpin(ctx, bk, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.PinTime(bk); ok {
+		t.Fatal("pin time not removed by unpin")
+	}
+}
+
+func TestTimedPinnerSubscribe(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	p := NewTimedPinner(NewPinner(dstore, dserv, dserv), dstore)
+
+	a, _ := randNode()
+	b, _ := randNode()
+	for _, nd := range []*mdag.ProtoNode{a, b} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ch, cancel := p.Subscribe()
+	if err := p.Pin(ctx, a, false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ch:
+		t.Fatal("notified of a direct pin")
+	default:
+	}
+
+	// the second pin is notified with the first one
+	if err := p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, b, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ch:
+	default:
+		t.Fatal("not notified of the recursive pins")
+	}
+	select {
+	case <-ch:
+		t.Fatal("notified twice")
+	default:
+	}
+
+	cancel()
+	if err := p.Unpin(ctx, b.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, b, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ch:
+		t.Fatal("notified after cancelling the subscription")
+	default:
+	}
+}
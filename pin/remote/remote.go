@@ -0,0 +1,209 @@
+// Package remote implements a client of the IPFS Pinning Service API, the
+// HTTP API through which third-party pinning services pin content on behalf
+// of their users (https://ipfs.github.io/pinning-services-api-spec/).
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the status of a pin request.
+type Status string
+
+// The statuses of a pin request, from its creation to its completion.
+const (
+	Queued  Status = "queued"
+	Pinning Status = "pinning"
+	Pinned  Status = "pinned"
+	Failed  Status = "failed"
+)
+
+// maxResponseSize bounds the responses read from a service.
+const maxResponseSize = 10 << 20
+
+// maxPageSize is the largest number of pins a service returns at once.
+const maxPageSize = 1000
+
+// Pin is the object of a pin request.
+type Pin struct {
+	Cid     string            `json:"cid"`
+	Name    string            `json:"name,omitempty"`
+	Origins []string          `json:"origins,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+// PinStatus is the state of a pin request on the service.
+type PinStatus struct {
+	RequestID string            `json:"requestid"`
+	Status    Status            `json:"status"`
+	Created   time.Time         `json:"created"`
+	Pin       Pin               `json:"pin"`
+	Delegates []string          `json:"delegates"`
+	Info      map[string]string `json:"info,omitempty"`
+}
+
+// Error is an error returned by a service.
+type Error struct {
+	StatusCode int
+	Reason     string
+	Details    string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("pinning service: %d %s", e.StatusCode, e.Reason)
+	if e.Details != "" {
+		msg += ": " + e.Details
+	}
+	return msg
+}
+
+// LsOptions selects the pins listed by Ls.
+type LsOptions struct {
+	// Cids, if not empty, lists the pins of these CIDs only.
+	Cids []string
+	// Name, if not empty, lists the pins with this name only.
+	Name string
+	// Status lists the pins with these statuses. The services default to
+	// Pinned.
+	Status []Status
+	// Limit is the maximum number of pins listed, or 0 for no limit.
+	Limit int
+}
+
+// Client is a client of a pinning service.
+type Client struct {
+	endpoint string
+	key      string
+	client   *http.Client
+}
+
+// NewClient returns a Client of the service at endpoint, authenticating
+// with the access token key.
+func NewClient(endpoint, key string) *Client {
+	return &Client{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		key:      key,
+		client:   http.DefaultClient,
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.endpoint+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.key)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var failure struct {
+			Error struct {
+				Reason  string `json:"reason"`
+				Details string `json:"details"`
+			} `json:"error"`
+		}
+		e := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(data, &failure) == nil && failure.Error.Reason != "" {
+			e.Reason, e.Details = failure.Error.Reason, failure.Error.Details
+		} else {
+			e.Reason = http.StatusText(resp.StatusCode)
+		}
+		return e
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("pinning service: invalid response: %s", err)
+	}
+	return nil
+}
+
+// Add asks the service to pin p.
+func (c *Client) Add(ctx context.Context, p Pin) (*PinStatus, error) {
+	var st PinStatus
+	if err := c.do(ctx, "POST", "/pins", p, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Get returns the status of the pin request with the given ID.
+func (c *Client) Get(ctx context.Context, requestID string) (*PinStatus, error) {
+	var st PinStatus
+	if err := c.do(ctx, "GET", "/pins/"+url.PathEscape(requestID), nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Ls returns the pins matching opts, the most recent first.
+func (c *Client) Ls(ctx context.Context, opts LsOptions) ([]PinStatus, error) {
+	q := url.Values{}
+	if len(opts.Cids) > 0 {
+		q.Set("cid", strings.Join(opts.Cids, ","))
+	}
+	if opts.Name != "" {
+		q.Set("name", opts.Name)
+	}
+	if len(opts.Status) > 0 {
+		statuses := make([]string, len(opts.Status))
+		for i, s := range opts.Status {
+			statuses[i] = string(s)
+		}
+		q.Set("status", strings.Join(statuses, ","))
+	}
+
+	var pins []PinStatus
+	for {
+		limit := maxPageSize
+		if opts.Limit > 0 && opts.Limit-len(pins) < limit {
+			limit = opts.Limit - len(pins)
+		}
+		q.Set("limit", strconv.Itoa(limit))
+
+		var page struct {
+			Count   int         `json:"count"`
+			Results []PinStatus `json:"results"`
+		}
+		if err := c.do(ctx, "GET", "/pins?"+q.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		pins = append(pins, page.Results...)
+
+		// the pages are made of the pins created before the last one listed
+		if len(page.Results) == 0 || len(page.Results) < limit || len(pins) >= page.Count ||
+			(opts.Limit > 0 && len(pins) >= opts.Limit) {
+			return pins, nil
+		}
+		q.Set("before", page.Results[len(page.Results)-1].Created.Format(time.RFC3339Nano))
+	}
+}
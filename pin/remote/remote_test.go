@@ -0,0 +1,172 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockService is a pinning service keeping its pins in memory.
+type mockService struct {
+	key  string
+	pins []PinStatus
+}
+
+func (s *mockService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fail := func(code int, reason, details string) {
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"error":{"reason":%q,"details":%q}}`, reason, details)
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.key {
+		fail(http.StatusUnauthorized, "UNAUTHORIZED", "invalid access token")
+		return
+	}
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/pins":
+		var p Pin
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			fail(http.StatusBadRequest, "BAD_REQUEST", err.Error())
+			return
+		}
+		st := PinStatus{
+			RequestID: strconv.Itoa(len(s.pins)),
+			Status:    Queued,
+			Created:   time.Date(2020, 1, 1, 0, 0, len(s.pins), 0, time.UTC),
+			Pin:       p,
+			Delegates: []string{"/ip4/1.2.3.4/tcp/4001/ipfs/QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM"},
+		}
+		s.pins = append(s.pins, st)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(st)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/pins/"):
+		i, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/pins/"))
+		if err != nil || i >= len(s.pins) {
+			fail(http.StatusNotFound, "NOT_FOUND", "")
+			return
+		}
+		json.NewEncoder(w).Encode(s.pins[i])
+	case r.Method == "GET" && r.URL.Path == "/pins":
+		q := r.URL.Query()
+		statuses := strings.Split(q.Get("status"), ",")
+		if q.Get("status") == "" {
+			statuses = []string{string(Pinned)}
+		}
+		before := time.Now()
+		if q.Get("before") != "" {
+			before, _ = time.Parse(time.RFC3339Nano, q.Get("before"))
+		}
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		var matches []PinStatus
+		for _, st := range s.pins {
+			ok := false
+			for _, status := range statuses {
+				ok = ok || string(st.Status) == status
+			}
+			if q.Get("cid") != "" {
+				ok = ok && strings.Contains(","+q.Get("cid")+",", ","+st.Pin.Cid+",")
+			}
+			if ok && st.Created.Before(before) && (q.Get("name") == "" || st.Pin.Name == q.Get("name")) {
+				matches = append(matches, st)
+			}
+		}
+		sort.Slice(matches, func(i, j int) bool { return matches[i].Created.After(matches[j].Created) })
+		count := len(matches)
+		if len(matches) > limit {
+			matches = matches[:limit]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": count, "results": matches})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestClient(t *testing.T) {
+	svc := &mockService{key: "secret"}
+	ts := httptest.NewServer(svc)
+	defer ts.Close()
+
+	ctx := context.Background()
+	c := NewClient(ts.URL+"/", "secret")
+
+	st, err := c.Add(ctx, Pin{Cid: "QmTest", Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != Queued || st.Pin.Cid != "QmTest" || len(st.Delegates) != 1 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+
+	svc.pins[0].Status = Pinned
+	st, err = c.Get(ctx, st.RequestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != Pinned {
+		t.Fatalf("expected the pin to be pinned, got %s", st.Status)
+	}
+
+	if _, err := c.Get(ctx, "42"); err == nil || err.(*Error).StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+
+	_, err = NewClient(ts.URL, "wrong").Add(ctx, Pin{Cid: "QmTest"})
+	if e, ok := err.(*Error); !ok || e.Reason != "UNAUTHORIZED" || e.Details != "invalid access token" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestClientLs(t *testing.T) {
+	svc := &mockService{key: "secret"}
+	ts := httptest.NewServer(svc)
+	defer ts.Close()
+
+	ctx := context.Background()
+	c := NewClient(ts.URL, "secret")
+
+	n := maxPageSize + 5
+	for i := 0; i < n; i++ {
+		if _, err := c.Add(ctx, Pin{Cid: fmt.Sprintf("Qm%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range svc.pins {
+		svc.pins[i].Status = Pinned
+	}
+	svc.pins[0].Status = Failed
+
+	pins, err := c.Ls(ctx, LsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != n-1 {
+		t.Fatalf("expected %d pins, got %d", n-1, len(pins))
+	}
+	if pins[0].Pin.Cid != fmt.Sprintf("Qm%d", n-1) {
+		t.Fatalf("expected the most recent pin first, got %s", pins[0].Pin.Cid)
+	}
+
+	pins, err = c.Ls(ctx, LsOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 3 {
+		t.Fatalf("expected 3 pins, got %d", len(pins))
+	}
+
+	pins, err = c.Ls(ctx, LsOptions{Status: []Status{Failed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || pins[0].Pin.Cid != "Qm0" {
+		t.Fatalf("unexpected failed pins: %+v", pins)
+	}
+}
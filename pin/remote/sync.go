@@ -0,0 +1,134 @@
+package remote
+
+import (
+	"context"
+	"time"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("pin/remote")
+
+// maxCidsPerQuery is the largest number of CIDs the services accept in one
+// query.
+const maxCidsPerQuery = 10
+
+// wakeDelay is how long AutoSync waits after being woken up, for the pins
+// created together, like by a recursive add, to be synced at once.
+var wakeDelay = 5 * time.Second
+
+// Sync asks the service to pin the recursive pins of pinner it doesn't have,
+// and returns them. The pins queued, in progress or pinned on the service are
+// left alone, the failed ones are requested again. The requests have the
+// given origins.
+func Sync(ctx context.Context, c *Client, pinner pin.Pinner, origins []string) ([]cid.Cid, error) {
+	return SyncCids(ctx, c, pinner.RecursiveKeys(), origins)
+}
+
+// SyncCids is Sync for the pins local only.
+func SyncCids(ctx context.Context, c *Client, local []cid.Cid, origins []string) ([]cid.Cid, error) {
+	var missing []cid.Cid
+	for i := 0; i < len(local); i += maxCidsPerQuery {
+		batch := local[i:]
+		if len(batch) > maxCidsPerQuery {
+			batch = batch[:maxCidsPerQuery]
+		}
+		cids := make([]string, len(batch))
+		for j, k := range batch {
+			cids[j] = k.String()
+		}
+
+		pins, err := c.Ls(ctx, LsOptions{Cids: cids, Status: []Status{Queued, Pinning, Pinned}})
+		if err != nil {
+			return nil, err
+		}
+		present := make(map[string]bool, len(pins))
+		for _, st := range pins {
+			present[st.Pin.Cid] = true
+		}
+		for j, k := range batch {
+			if !present[cids[j]] {
+				missing = append(missing, k)
+			}
+		}
+	}
+
+	var added []cid.Cid
+	for _, k := range missing {
+		if _, err := c.Add(ctx, Pin{Cid: k.String(), Origins: origins}); err != nil {
+			return added, err
+		}
+		added = append(added, k)
+	}
+	return added, nil
+}
+
+// pinnedSince returns the recursive pins of pinner created from t on, all of
+// them if pinner doesn't record the pin times.
+func pinnedSince(pinner pin.Pinner, t time.Time) []cid.Cid {
+	keys := pinner.RecursiveKeys()
+	tp, ok := pinner.(*pin.TimedPinner)
+	if !ok {
+		return keys
+	}
+	var pinned []cid.Cid
+	for _, k := range keys {
+		if at, ok := tp.PinTime(k); ok && !at.Before(t) {
+			pinned = append(pinned, k)
+		}
+	}
+	return pinned
+}
+
+// AutoSync runs Sync every interval, which must be positive, until ctx is
+// done. When wake receives a value, the pins created since the last sync are
+// synced, wakeDelay later. The origins of the requests are given by origins,
+// at each sync.
+func AutoSync(ctx context.Context, c *Client, pinner pin.Pinner, origins func() []string, interval time.Duration, wake <-chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	// the pins created from since on are not synced yet
+	var since time.Time
+	for {
+		var added []cid.Cid
+		var err error
+		var start time.Time
+		select {
+		case <-timer.C:
+			start = time.Now()
+			added, err = Sync(ctx, c, pinner, origins())
+			timer.Reset(interval)
+		case <-wake:
+			select {
+			case <-time.After(wakeDelay):
+			case <-ctx.Done():
+				return
+			}
+			// the pins notified until now are synced below, their wake is
+			// dropped
+			start = time.Now()
+			select {
+			case <-wake:
+			default:
+			}
+			added, err = SyncCids(ctx, c, pinnedSince(pinner, since), origins())
+		case <-ctx.Done():
+			return
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			log.Warningf("syncing the pins to %s: %s", c.endpoint, err)
+			continue
+		case len(added) > 0:
+			log.Infof("asked %s to pin %d objects", c.endpoint, len(added))
+		}
+		since = start
+	}
+}
@@ -0,0 +1,154 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	bs "github.com/ipfs/go-blockservice"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	mdag "github.com/ipfs/go-merkledag"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	pinner := pin.NewPinner(dstore, dserv, dserv)
+
+	// more pins than fit in one query
+	n := maxCidsPerQuery + 3
+	var nodes []*mdag.ProtoNode
+	for i := 0; i < n; i++ {
+		nd := mdag.NodeWithData([]byte(fmt.Sprintf("node %d", i)))
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+		if err := pinner.Pin(ctx, nd, true); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, nd)
+	}
+
+	svc := &mockService{key: "secret"}
+	ts := httptest.NewServer(svc)
+	defer ts.Close()
+	c := NewClient(ts.URL, "secret")
+
+	// one pin is already on the service, one failed
+	if _, err := c.Add(ctx, Pin{Cid: nodes[0].Cid().String()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Add(ctx, Pin{Cid: nodes[1].Cid().String()}); err != nil {
+		t.Fatal(err)
+	}
+	svc.pins[1].Status = Failed
+
+	added, err := Sync(ctx, c, pinner, []string{"/ip4/1.2.3.4/tcp/4001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != n-1 {
+		t.Fatalf("expected %d pins added, got %d", n-1, len(added))
+	}
+	for _, k := range added {
+		if k.Equals(nodes[0].Cid()) {
+			t.Fatal("the pin already on the service was added again")
+		}
+	}
+	if origins := svc.pins[len(svc.pins)-1].Pin.Origins; len(origins) != 1 {
+		t.Fatalf("unexpected origins: %v", origins)
+	}
+
+	added, err = Sync(ctx, c, pinner, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 0 {
+		t.Fatalf("expected nothing to sync, got %d pins", len(added))
+	}
+}
+
+func TestAutoSync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+	pinner := pin.NewTimedPinner(pin.NewPinner(dstore, dserv, dserv), dstore)
+
+	var nodes []*mdag.ProtoNode
+	for i := 0; i < 3; i++ {
+		nd := mdag.NodeWithData([]byte(fmt.Sprintf("node %d", i)))
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, nd)
+	}
+	if err := pinner.Pin(ctx, nodes[0], true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the CIDs of each query of the pins on the service
+	queries := make(chan string, 10)
+	svc := &mockService{key: "secret"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/pins" {
+			queries <- r.URL.Query().Get("cid")
+		}
+		svc.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	c := NewClient(ts.URL, "secret")
+
+	defer func(d time.Duration) { wakeDelay = d }(wakeDelay)
+	wakeDelay = 100 * time.Millisecond
+	wake, unsubscribe := pinner.Subscribe()
+	defer unsubscribe()
+	go AutoSync(ctx, c, pinner, func() []string { return nil }, time.Hour, wake)
+
+	next := func() string {
+		select {
+		case q := <-queries:
+			return q
+		case <-time.After(5 * time.Second):
+			t.Fatal("no sync")
+			return ""
+		}
+	}
+	if q := next(); q != nodes[0].Cid().String() {
+		t.Fatalf("expected the first sync to query the pin, got %q", q)
+	}
+
+	// the pins created together are synced at once, without the old one
+	for _, nd := range nodes[1:] {
+		if err := pinner.Pin(ctx, nd, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := strings.Split(next(), ",")
+	sort.Strings(q)
+	expect := []string{nodes[1].Cid().String(), nodes[2].Cid().String()}
+	sort.Strings(expect)
+	if strings.Join(q, ",") != strings.Join(expect, ",") {
+		t.Fatalf("expected the new pins to be synced, got %q", q)
+	}
+	select {
+	case q := <-queries:
+		t.Fatalf("unexpected sync of %q", q)
+	case <-time.After(3 * wakeDelay):
+	}
+}
@@ -9,6 +9,9 @@ import (
 type PluginDatastore interface {
 	Plugin
 
+	// DatastoreTypeName is the value of the "type" field selecting this
+	// datastore in the Datastore.Spec config.
 	DatastoreTypeName() string
+	// DatastoreConfigParser parses the spec of a datastore of this type.
 	DatastoreConfigParser() fsrepo.ConfigFromMap
 }
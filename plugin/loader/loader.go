@@ -8,6 +8,7 @@ import (
 	coredag "github.com/ipfs/go-ipfs/core/coredag"
 	plugin "github.com/ipfs/go-ipfs/plugin"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
@@ -105,6 +106,12 @@ func (loader *PluginLoader) Inject() error {
 				return err
 			}
 		}
+		if pl, ok := pl.(plugin.PluginMigration); ok {
+			err := injectMigrationPlugin(pl)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -148,8 +155,23 @@ func closePlugins(plugins []plugin.Plugin) error {
 	return nil
 }
 
+// injectDatastorePlugin registers the datastore type provided by the plugin,
+// making it available to the "type" field of Datastore.Spec.
 func injectDatastorePlugin(pl plugin.PluginDatastore) error {
-	return fsrepo.AddDatastoreConfigHandler(pl.DatastoreTypeName(), pl.DatastoreConfigParser())
+	err := fsrepo.AddDatastoreConfigHandler(pl.DatastoreTypeName(), pl.DatastoreConfigParser())
+	if err != nil {
+		return fmt.Errorf("plugin %s: %s", pl.Name(), err)
+	}
+	return nil
+}
+
+// injectMigrationPlugin registers the repo migration provided by the plugin.
+func injectMigrationPlugin(pl plugin.PluginMigration) error {
+	err := mfsr.AddMigration(pl)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %s", pl.Name(), err)
+	}
+	return nil
 }
 
 func injectIPLDPlugin(pl plugin.PluginIPLD) error {
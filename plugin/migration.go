@@ -0,0 +1,14 @@
+package plugin
+
+import (
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+)
+
+// PluginMigration is an interface that can be implemented to add a repo
+// migration, for the repo formats of forks diverging from go-ipfs. The
+// migrations of the plugins are run by 'ipfs daemon --migrate' from the
+// versions they start at, fs-repo-migrations running the others.
+type PluginMigration interface {
+	Plugin
+	mfsr.Migration
+}
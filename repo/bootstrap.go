@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+// BootstrapConfigKey is the config key of the bootstrap list.
+const BootstrapConfigKey = "Bootstrap"
+
+// BootstrapEntry is an entry of the bootstrap list of the config file. An
+// entry is either the multiaddr of the peer, or an object tagging it with
+// the region the peer runs in:
+//
+//	{"addr": "/ip4/1.2.3.4/tcp/4001/ipfs/Qm...", "region": "us-east-1"}
+//
+// The config struct only holds the multiaddrs: the repos flatten the
+// objects when reading the config file, and keep them when the list is
+// written back.
+type BootstrapEntry struct {
+	Addr   string `json:"addr"`
+	Region string `json:"region,omitempty"`
+}
+
+// UnmarshalJSON decodes a multiaddr string or an entry object, refusing the
+// objects without an address or with unknown fields.
+func (e *BootstrapEntry) UnmarshalJSON(data []byte) error {
+	var addr string
+	if err := json.Unmarshal(data, &addr); err == nil {
+		*e = BootstrapEntry{Addr: addr}
+		return nil
+	}
+
+	type entry BootstrapEntry
+	var v entry
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid bootstrap entry %s: expected a multiaddr or an object with an \"addr\": %s", data, err)
+	}
+	if v.Addr == "" {
+		return fmt.Errorf("invalid bootstrap entry %s: missing \"addr\"", data)
+	}
+	*e = BootstrapEntry(v)
+	return nil
+}
+
+// MarshalJSON encodes the entries without metadata as a plain multiaddr,
+// like go-ipfs always wrote them.
+func (e BootstrapEntry) MarshalJSON() ([]byte, error) {
+	if e.Region == "" {
+		return json.Marshal(e.Addr)
+	}
+	type entry BootstrapEntry
+	return json.Marshal(entry(e))
+}
+
+// ParseBootstrapEntries decodes the generic value of the bootstrap list of a
+// config map, nil for no list.
+func ParseBootstrapEntries(v interface{}) ([]BootstrapEntry, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var entries []BootstrapEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// BootstrapEntriesValue returns entries as the generic value of a config
+// map, to store them with SetConfigKey.
+func BootstrapEntriesValue(entries []BootstrapEntry) (interface{}, error) {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	var v []interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// FlattenBootstrap returns a copy of the config map cfg with the bootstrap
+// entries replaced by their address, for the config struct.
+func FlattenBootstrap(cfg map[string]interface{}) (map[string]interface{}, error) {
+	entries, err := ParseBootstrapEntries(cfg[BootstrapConfigKey])
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		return cfg, nil
+	}
+
+	flat := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		flat[k] = v
+	}
+	addrs := make([]interface{}, len(entries))
+	for i, e := range entries {
+		addrs[i] = e.Addr
+	}
+	flat[BootstrapConfigKey] = addrs
+	return flat, nil
+}
+
+// KeepBootstrapEntries returns the bootstrap list updated, the multiaddrs of
+// the config struct, with the metadata of the entries of old, the list in
+// the config file, for the addresses it still holds.
+func KeepBootstrapEntries(updated, old interface{}) interface{} {
+	oldEntries, err := ParseBootstrapEntries(old)
+	if err != nil || len(oldEntries) == 0 {
+		// the invalid entries are replaced
+		return updated
+	}
+	list, ok := updated.([]interface{})
+	if !ok {
+		return updated
+	}
+
+	regions := make(map[string]string)
+	for _, e := range oldEntries {
+		if e.Region != "" {
+			regions[bootstrapPeerKey(e.Addr)] = e.Region
+		}
+	}
+	out := make([]interface{}, len(list))
+	for i, v := range list {
+		out[i] = v
+		if addr, ok := v.(string); ok {
+			if region, ok := regions[bootstrapPeerKey(addr)]; ok {
+				out[i] = map[string]interface{}{"addr": addr, "region": region}
+			}
+		}
+	}
+	return out
+}
+
+// bootstrapPeerKey returns the canonical form of a bootstrap address, as
+// written by config.BootstrapPeerStrings.
+func bootstrapPeerKey(addr string) string {
+	bp, err := config.ParseBootstrapPeer(addr)
+	if err != nil {
+		return addr
+	}
+	return bp.String()
+}
@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const (
+	testBootstrapAddr    = "/ip4/1.2.3.4/tcp/4001/ipfs/QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z"
+	testBootstrapP2PAddr = "/ip4/1.2.3.4/tcp/4001/p2p/QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z"
+)
+
+func TestParseBootstrapEntries(t *testing.T) {
+	entries, err := ParseBootstrapEntries([]interface{}{
+		testBootstrapAddr,
+		map[string]interface{}{"addr": testBootstrapAddr, "region": "us-east-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []BootstrapEntry{{Addr: testBootstrapAddr}, {Addr: testBootstrapAddr, Region: "us-east-1"}}
+	if !reflect.DeepEqual(entries, expect) {
+		t.Fatalf("expected %v, got %v", expect, entries)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `["`+testBootstrapAddr+`",{"addr":"`+testBootstrapAddr+`","region":"us-east-1"}]` {
+		t.Fatalf("unexpected encoding: %s", data)
+	}
+
+	for _, v := range []interface{}{
+		map[string]interface{}{"region": "us-east-1"},
+		map[string]interface{}{"addr": testBootstrapAddr, "zone": "a"},
+		42,
+	} {
+		if _, err := ParseBootstrapEntries([]interface{}{v}); err == nil {
+			t.Errorf("no error for the entry %v", v)
+		}
+	}
+}
+
+func TestFlattenBootstrap(t *testing.T) {
+	cfg := map[string]interface{}{
+		"Bootstrap": []interface{}{map[string]interface{}{"addr": testBootstrapAddr, "region": "us-east-1"}},
+		"Other":     true,
+	}
+	flat, err := FlattenBootstrap(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(flat["Bootstrap"], []interface{}{testBootstrapAddr}) || flat["Other"] != true {
+		t.Fatalf("unexpected flattened config: %v", flat)
+	}
+	if _, ok := cfg["Bootstrap"].([]interface{})[0].(map[string]interface{}); !ok {
+		t.Fatal("the config was modified")
+	}
+}
+
+func TestKeepBootstrapEntries(t *testing.T) {
+	old := []interface{}{
+		map[string]interface{}{"addr": testBootstrapP2PAddr, "region": "us-east-1"},
+		"/ip4/5.6.7.8/tcp/4001/ipfs/QmSoLueR4xBeUbY9WZ9xGUUxunbKWcrNFTDAadQJmocnWm",
+	}
+	updated := []interface{}{"/ip4/9.9.9.9/tcp/4001/ipfs/QmSoLueR4xBeUbY9WZ9xGUUxunbKWcrNFTDAadQJmocnWm", testBootstrapAddr}
+
+	out := KeepBootstrapEntries(updated, old)
+	expect := []interface{}{
+		updated[0],
+		map[string]interface{}{"addr": testBootstrapAddr, "region": "us-east-1"},
+	}
+	if !reflect.DeepEqual(out, expect) {
+		t.Fatalf("expected %v, got %v", expect, out)
+	}
+}
@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	common "github.com/ipfs/go-ipfs/repo/common"
+)
+
+// ReadConfigKey decodes the value of the config key of r into v, for the
+// options go-ipfs reads from the config file which are not part of the
+// config struct. It returns false, leaving v untouched, when the key is not
+// set or null. The values are decoded like the config file, except that
+// the fields v doesn't have are refused, so that a typo doesn't go
+// unnoticed. Reading the config file may fail too.
+func ReadConfigKey(r Repo, key string, v interface{}) (bool, error) {
+	val, err := r.GetConfigKey(key)
+	if common.IsKeyNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading %s from the config: %s", key, err)
+	}
+	if val == nil {
+		return false, nil
+	}
+
+	// go through json to get v out of the generic value
+	data, err := json.Marshal(val)
+	if err != nil {
+		return false, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return false, fmt.Errorf("invalid value for %s: %s", key, err)
+	}
+	return true, nil
+}
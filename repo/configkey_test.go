@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	common "github.com/ipfs/go-ipfs/repo/common"
+)
+
+// mapRepo is a repo whose config file holds m.
+type mapRepo struct {
+	Mock
+	m   map[string]interface{}
+	err error
+}
+
+func (r *mapRepo) GetConfigKey(key string) (interface{}, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return common.MapGetKV(r.m, key)
+}
+
+func TestReadConfigKey(t *testing.T) {
+	r := &mapRepo{m: map[string]interface{}{
+		"Section": map[string]interface{}{
+			"Size":  float64(3),
+			"Null":  nil,
+			"Limit": map[string]interface{}{"Max": float64(2)},
+			"Typo":  map[string]interface{}{"Maxx": float64(2)},
+		},
+	}}
+
+	size := 10
+	if ok, err := ReadConfigKey(r, "Section.Size", &size); err != nil || !ok || size != 3 {
+		t.Fatalf("expected the size 3, got %d, %t, %v", size, ok, err)
+	}
+
+	for _, key := range []string{"Section.Missing", "Missing.Size", "Section.Null"} {
+		size := 10
+		if ok, err := ReadConfigKey(r, key, &size); err != nil || ok || size != 10 {
+			t.Fatalf("expected %s to be unset, got %d, %t, %v", key, size, ok, err)
+		}
+	}
+
+	var limit struct{ Max int }
+	if ok, err := ReadConfigKey(r, "Section.Limit", &limit); err != nil || !ok || limit.Max != 2 {
+		t.Fatalf("expected the limit 2, got %d, %t, %v", limit.Max, ok, err)
+	}
+	if _, err := ReadConfigKey(r, "Section.Typo", &limit); err == nil || !strings.Contains(err.Error(), "Maxx") {
+		t.Fatalf("expected an error for the unknown field, got %v", err)
+	}
+
+	var s string
+	if _, err := ReadConfigKey(r, "Section.Size", &s); err == nil {
+		t.Fatal("expected an error for a value of the wrong type")
+	}
+	if _, err := ReadConfigKey(r, "Section.Size.Sub", &s); err == nil {
+		t.Fatal("expected an error for a key under a value which isn't a map")
+	}
+
+	r.err = errors.New("config unreadable")
+	if _, err := ReadConfigKey(r, "Section.Size", &size); err == nil {
+		t.Fatal("expected the error reading the config")
+	}
+}
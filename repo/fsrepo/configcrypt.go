@@ -0,0 +1,101 @@
+package fsrepo
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-ipfs/repo/common"
+	configcrypt "github.com/ipfs/go-ipfs/repo/fsrepo/configcrypt"
+
+	config "github.com/ipfs/go-ipfs-config"
+	serialize "github.com/ipfs/go-ipfs-config/serialize"
+)
+
+// ConfigPassphrase returns the passphrase of the encrypted config fields,
+// set by 'ipfs config encrypt'. It is only called when the config has some.
+// When it is nil, the fields are left encrypted in the config of the repos
+// opened.
+var ConfigPassphrase func() (string, error)
+
+type encryptedField struct {
+	ciphertext string
+	plaintext  string
+}
+
+// decryptConfig returns conf with the encrypted fields of the config file
+// decrypted, and keeps track of them so that they are written back
+// encrypted.
+func (r *FSRepo) decryptConfig(configFilename string, conf *config.Config) (*config.Config, error) {
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(configFilename, &mapconf); err != nil {
+		return nil, err
+	}
+	fields := configcrypt.Fields(mapconf)
+	if len(fields) == 0 {
+		return conf, nil
+	}
+
+	passphrase, err := ConfigPassphrase()
+	if err != nil {
+		return nil, fmt.Errorf("the config has encrypted fields: %s", err)
+	}
+	encrypted := make(map[string]encryptedField, len(fields))
+	for _, f := range fields {
+		v, err := common.MapGetKV(mapconf, f)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := configcrypt.Decrypt(v.(string), passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt the config field %s: %s", f, err)
+		}
+		if err := common.MapSetKV(mapconf, f, plaintext); err != nil {
+			return nil, err
+		}
+		encrypted[f] = encryptedField{ciphertext: v.(string), plaintext: plaintext}
+	}
+
+	conf, err = ConfigFromFileMap(mapconf)
+	if err != nil {
+		return nil, err
+	}
+	r.encrypted = encrypted
+	r.passphrase = passphrase
+	return conf, nil
+}
+
+// trackEncrypted updates the decrypted fields when key is set to value: a
+// new encrypted value must decrypt with the passphrase of the repo.
+func (r *FSRepo) trackEncrypted(key string, value interface{}) error {
+	if r.encrypted == nil {
+		return nil
+	}
+	delete(r.encrypted, key)
+	if !configcrypt.IsEncrypted(value) {
+		return nil
+	}
+	plaintext, err := configcrypt.Decrypt(value.(string), r.passphrase)
+	if err != nil {
+		return fmt.Errorf("the other encrypted fields of the config use another passphrase: %s", err)
+	}
+	r.encrypted[key] = encryptedField{ciphertext: value.(string), plaintext: plaintext}
+	return nil
+}
+
+// decryptFields replaces the encrypted fields of mapconf by their plaintext.
+func (r *FSRepo) decryptFields(mapconf map[string]interface{}) {
+	for f, ef := range r.encrypted {
+		if v, err := common.MapGetKV(mapconf, f); err == nil && v == ef.ciphertext {
+			common.MapSetKV(mapconf, f, ef.plaintext)
+		}
+	}
+}
+
+// encryptFields replaces the decrypted fields of mapconf by their
+// ciphertext, unless they were changed.
+func (r *FSRepo) encryptFields(mapconf map[string]interface{}) {
+	for f, ef := range r.encrypted {
+		if v, err := common.MapGetKV(mapconf, f); err == nil && v == ef.plaintext {
+			common.MapSetKV(mapconf, f, ef.ciphertext)
+		}
+	}
+}
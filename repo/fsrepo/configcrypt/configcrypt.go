@@ -0,0 +1,147 @@
+// Package configcrypt encrypts the sensitive fields of the config file, like
+// API tokens and datastore credentials, with a passphrase.
+//
+// An encrypted field holds "enc:" followed by the base64 encoding of a salt,
+// a nonce and the value sealed with AES-256-GCM. The key is derived from the
+// passphrase and the salt with Argon2id, so that each field has its own.
+package configcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Prefix starts the values of the encrypted fields.
+const Prefix = "enc:"
+
+// EnvPassphrase is the environment variable holding the passphrase, instead
+// of prompting for it.
+const EnvPassphrase = "IPFS_CONFIG_PASSPHRASE"
+
+// ErrWrongPassphrase is returned when a field doesn't decrypt with the
+// passphrase, or was tampered with.
+var ErrWrongPassphrase = errors.New("wrong passphrase, or the encrypted value was modified")
+
+// the second Argon2id parameters recommended by RFC 9106, for when 2 GiB of
+// memory can't be used: 3 passes over 64 MiB, with 4 lanes
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	keyLength    = 32
+	saltLength   = 16
+)
+
+// IsEncrypted returns whether v is the value of an encrypted field.
+func IsEncrypted(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, Prefix)
+}
+
+func newCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, argonTime, argonMemory, argonThreads, keyLength)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt returns the value of the field holding plaintext, encrypted with
+// passphrase.
+func Encrypt(plaintext, passphrase string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	aead, err := newCipher(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	buf := append(salt, nonce...)
+	buf = aead.Seal(buf, nonce, []byte(plaintext), nil)
+	return Prefix + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// Decrypt returns the plaintext of the encrypted field value.
+func Decrypt(value, passphrase string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", errors.New("the value is not encrypted")
+	}
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %s", err)
+	}
+	if len(buf) < saltLength {
+		return "", errors.New("invalid encrypted value: too short")
+	}
+	aead, err := newCipher(passphrase, buf[:saltLength])
+	if err != nil {
+		return "", err
+	}
+	buf = buf[saltLength:]
+	if len(buf) < aead.NonceSize() {
+		return "", errors.New("invalid encrypted value: too short")
+	}
+	plaintext, err := aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return string(plaintext), nil
+}
+
+// Fields returns the paths of the encrypted fields of the config map m, like
+// "API.AuthToken", sorted.
+func Fields(m map[string]interface{}) []string {
+	var fields []string
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				walk(prefix+k+".", v)
+			default:
+				if IsEncrypted(v) {
+					fields = append(fields, prefix+k)
+				}
+			}
+		}
+	}
+	walk("", m)
+	sort.Strings(fields)
+	return fields
+}
+
+// Passphrase returns the passphrase of $IPFS_CONFIG_PASSPHRASE, or prompts
+// for it when the input is a terminal.
+func Passphrase(prompt string) (string, error) {
+	if p, ok := os.LookupEnv(EnvPassphrase); ok {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", fmt.Errorf("no passphrase, set $%s", EnvPassphrase)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	p, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(p), nil
+}
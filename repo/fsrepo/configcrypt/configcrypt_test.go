@@ -0,0 +1,66 @@
+package configcrypt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	ciphertext, err := Encrypt("token", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(ciphertext) || strings.Contains(ciphertext, "token") {
+		t.Fatalf("unexpected encrypted value %q", ciphertext)
+	}
+	other, err := Encrypt("token", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == ciphertext {
+		t.Fatal("encrypting twice should use different salts")
+	}
+
+	plaintext, err := Decrypt(ciphertext, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "token" {
+		t.Fatalf("expected %q, got %q", "token", plaintext)
+	}
+
+	if _, err := Decrypt(ciphertext, "wrong"); err != ErrWrongPassphrase {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+	tampered := ciphertext[:len(ciphertext)-4] + "AAA="
+	if _, err := Decrypt(tampered, "passphrase"); err == nil {
+		t.Fatal("decrypting a modified value should fail")
+	}
+	for _, v := range []string{"token", Prefix + "!!", Prefix + "AAAA"} {
+		if _, err := Decrypt(v, "passphrase"); err == nil {
+			t.Fatalf("decrypting %q should fail", v)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	m := map[string]interface{}{
+		"API": map[string]interface{}{
+			"AuthToken": Prefix + "a",
+			"Other":     "plain",
+		},
+		"Datastore": map[string]interface{}{
+			"Spec": map[string]interface{}{
+				"secretKey": Prefix + "b",
+			},
+			"StorageMax": "10GB",
+		},
+		"Top":    Prefix + "c",
+		"Number": 1.0,
+	}
+	expected := []string{"API.AuthToken", "Datastore.Spec.secretKey", "Top"}
+	if got := Fields(m); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
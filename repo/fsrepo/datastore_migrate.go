@@ -0,0 +1,304 @@
+package fsrepo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	lockfile "github.com/ipfs/go-fs-lock"
+	config "github.com/ipfs/go-ipfs-config"
+	serialize "github.com/ipfs/go-ipfs-config/serialize"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	util "github.com/ipfs/go-ipfs-util"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// blocksMigrateProgressFn lists the flatfs shards already copied by an
+// interrupted block migration.
+const blocksMigrateProgressFn = "blocks-migrate.progress"
+
+// BlocksMigrateProgress reports the progress of MigrateBlocksToBadger after
+// each migrated shard.
+type BlocksMigrateProgress struct {
+	Shard       string
+	ShardsDone  int
+	ShardsTotal int
+	Blocks      int
+	Remaining   time.Duration
+}
+
+// badgerBlocksSpec is the datastore used for the blocks after migration.
+func badgerBlocksSpec() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "badgerds",
+		"path":       "badgerds-blocks",
+		"syncWrites": true,
+		"truncate":   true,
+	}
+}
+
+// MigrateBlocksToBadger copies every block of the flatfs datastore mounted on
+// /blocks into a new badger datastore, verifying each block against its CID,
+// and switches the datastore config of the repo to it once done. The flatfs
+// directory is left untouched.
+//
+// The copy is done one flatfs shard at a time. Completed shards are recorded
+// in the repo, so an interrupted migration resumes where it stopped when run
+// again. The repo must not be in use by another process.
+func MigrateBlocksToBadger(repoPath string, progress func(*BlocksMigrateProgress)) error {
+	repoPath, err := homedir.Expand(filepath.Clean(repoPath))
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockfile.Lock(repoPath, LockFile)
+	if err != nil {
+		return fmt.Errorf("cannot acquire repo lock, is the daemon running? %s", err)
+	}
+	defer lock.Close()
+
+	configFilename, err := config.Filename(repoPath)
+	if err != nil {
+		return err
+	}
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(configFilename, &mapconf); err != nil {
+		return err
+	}
+	dsconf, ok := mapconf["Datastore"].(map[string]interface{})
+	if !ok {
+		return errors.New("required Datastore entry missing from config file")
+	}
+	spec, ok := dsconf["Spec"].(map[string]interface{})
+	if !ok {
+		return errors.New("required Datastore.Spec entry missing from config file")
+	}
+
+	mount, child, err := findBlocksMount(spec)
+	if err != nil {
+		return err
+	}
+	if child["type"] != "flatfs" {
+		return fmt.Errorf("blocks are stored in a %v datastore, expected flatfs", child["type"])
+	}
+	flatfsPath, ok := child["path"].(string)
+	if !ok {
+		return errors.New("'path' field of the flatfs datastore is missing or not string")
+	}
+	if !filepath.IsAbs(flatfsPath) {
+		flatfsPath = filepath.Join(repoPath, flatfsPath)
+	}
+
+	dstSpec := badgerBlocksSpec()
+	dstConf, err := AnyDatastoreConfig(dstSpec)
+	if err != nil {
+		return err
+	}
+	dst, err := dstConf.Create(repoPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	progressFile := filepath.Join(repoPath, blocksMigrateProgressFn)
+	done, err := readMigratedShards(progressFile)
+	if err != nil {
+		return err
+	}
+
+	shards, err := flatfsShards(flatfsPath)
+	if err != nil {
+		return err
+	}
+
+	pf, err := os.OpenFile(progressFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer pf.Close()
+
+	p := &BlocksMigrateProgress{ShardsTotal: len(shards)}
+	start := time.Now()
+	migrated := 0
+	for _, shard := range shards {
+		p.Shard = shard
+		if !done[shard] {
+			n, err := migrateShard(filepath.Join(flatfsPath, shard), dst)
+			if err != nil {
+				return fmt.Errorf("migrating shard %s: %s", shard, err)
+			}
+			if _, err := fmt.Fprintln(pf, shard); err != nil {
+				return err
+			}
+			if err := pf.Sync(); err != nil {
+				return err
+			}
+			p.Blocks += n
+			migrated++
+		}
+		p.ShardsDone++
+
+		if migrated > 0 {
+			perShard := time.Since(start) / time.Duration(migrated)
+			p.Remaining = perShard * time.Duration(p.ShardsTotal-p.ShardsDone)
+		}
+		if progress != nil {
+			progress(p)
+		}
+	}
+
+	// switch the repo over to the new datastore
+	if mount["type"] == "measure" {
+		mount["prefix"] = "badger.datastore"
+		mount["child"] = dstSpec
+	} else {
+		for k := range mount {
+			if k != "mountpoint" {
+				delete(mount, k)
+			}
+		}
+		for k, v := range dstSpec {
+			mount[k] = v
+		}
+	}
+
+	dsc, err := AnyDatastoreConfig(spec)
+	if err != nil {
+		return err
+	}
+	specFile, err := config.Path(repoPath, specFn)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(specFile, dsc.DiskSpec().Bytes(), 0600); err != nil {
+		return err
+	}
+	if err := serialize.WriteConfigFile(configFilename, mapconf); err != nil {
+		return err
+	}
+
+	pf.Close()
+	return os.Remove(progressFile)
+}
+
+// findBlocksMount returns the mount entry of the /blocks datastore in a mount
+// datastore spec, and the datastore spec under any measure wrapper.
+func findBlocksMount(spec map[string]interface{}) (mount, child map[string]interface{}, err error) {
+	if spec["type"] != "mount" {
+		return nil, nil, errors.New("datastore spec is not a mount datastore")
+	}
+	mounts, ok := spec["mounts"].([]interface{})
+	if !ok {
+		return nil, nil, errors.New("'mounts' field of the datastore spec is missing or not an array")
+	}
+
+	for _, m := range mounts {
+		mount, ok := m.(map[string]interface{})
+		if !ok || mount["mountpoint"] != "/blocks" {
+			continue
+		}
+
+		child := mount
+		if mount["type"] == "measure" {
+			child, ok = mount["child"].(map[string]interface{})
+			if !ok {
+				return nil, nil, errors.New("'child' field of the /blocks datastore is missing or not a map")
+			}
+		}
+		return mount, child, nil
+	}
+	return nil, nil, errors.New("no datastore mounted on /blocks")
+}
+
+func readMigratedShards(fn string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	if !util.FileExists(fn) {
+		return done, nil
+	}
+
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		if s := strings.TrimSpace(scan.Text()); s != "" {
+			done[s] = true
+		}
+	}
+	return done, scan.Err()
+}
+
+// flatfsShards lists the shard directories of a flatfs datastore, sorted.
+func flatfsShards(path string) ([]string, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var shards []string
+	for _, e := range entries {
+		if e.IsDir() {
+			shards = append(shards, e.Name())
+		}
+	}
+	sort.Strings(shards)
+	return shards, nil
+}
+
+// migrateShard copies the blocks of a flatfs shard directory into dst in a
+// single batch, and returns the number of blocks copied.
+func migrateShard(dir string, dst ds.Batching) (int, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := dst.Batch()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".data") {
+			continue
+		}
+
+		key := ds.NewKey(strings.TrimSuffix(name, ".data"))
+		c, err := dshelp.DsKeyToCid(key)
+		if err != nil {
+			return 0, fmt.Errorf("%s: not a block: %s", name, err)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return 0, err
+		}
+
+		sum, err := c.Prefix().Sum(data)
+		if err != nil {
+			return 0, err
+		}
+		if !sum.Equals(c) {
+			return 0, fmt.Errorf("block %s is corrupted, its data hashes to %s", c, sum)
+		}
+
+		if err := b.Put(key, data); err != nil {
+			return 0, err
+		}
+		n++
+	}
+
+	return n, b.Commit()
+}
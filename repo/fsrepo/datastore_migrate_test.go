@@ -0,0 +1,105 @@
+package fsrepo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/plugin/loader"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	blocks "github.com/ipfs/go-block-format"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	config "github.com/ipfs/go-ipfs-config"
+)
+
+// loadDatastorePlugins registers the datastore plugins, unless a previous
+// test already did.
+func loadDatastorePlugins(t *testing.T) {
+	if _, err := fsrepo.AnyDatastoreConfig(map[string]interface{}{"type": "badgerds", "path": "x"}); err == nil {
+		return
+	}
+
+	pl, err := loader.NewPluginLoader("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pl.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pl.Inject(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateBlocksToBadger(t *testing.T) {
+	loadDatastorePlugins(t)
+
+	path, err := ioutil.TempDir("", "ipfs-migrate-blocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	if err := fsrepo.Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := fsrepo.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blks []blocks.Block
+	for _, data := range []string{"foo", "bar", "baz"} {
+		b := blocks.NewBlock([]byte(data))
+		if err := bstore.NewBlockstore(r.Datastore()).Put(b); err != nil {
+			t.Fatal(err)
+		}
+		blks = append(blks, b)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var last *fsrepo.BlocksMigrateProgress
+	err = fsrepo.MigrateBlocksToBadger(path, func(p *fsrepo.BlocksMigrateProgress) {
+		cp := *p
+		last = &cp
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last == nil || last.Blocks != len(blks) || last.ShardsDone != last.ShardsTotal {
+		t.Fatalf("unexpected final progress: %+v", last)
+	}
+
+	if _, err := os.Stat(filepath.Join(path, "badgerds-blocks")); err != nil {
+		t.Fatal("badger datastore not created:", err)
+	}
+
+	r, err = fsrepo.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	cfg, err := r.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Datastore.Spec["mounts"].([]interface{})[0].(map[string]interface{})["child"].(map[string]interface{})["type"] != "badgerds" {
+		t.Fatalf("config not updated: %v", cfg.Datastore.Spec)
+	}
+
+	bs := bstore.NewBlockstore(r.Datastore())
+	for _, b := range blks {
+		out, err := bs.Get(b.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out.RawData()) != string(b.RawData()) {
+			t.Fatal("block data does not match")
+		}
+	}
+}
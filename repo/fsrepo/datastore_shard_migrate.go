@@ -0,0 +1,273 @@
+package fsrepo
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	lockfile "github.com/ipfs/go-fs-lock"
+	config "github.com/ipfs/go-ipfs-config"
+	serialize "github.com/ipfs/go-ipfs-config/serialize"
+	util "github.com/ipfs/go-ipfs-util"
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// shardMigrateProgressFn holds the target shard function of an interrupted
+// flatfs shard migration.
+const shardMigrateProgressFn = "blocks-shard-migrate.progress"
+
+// ShardMigrateResult is the outcome of MigrateBlocksShardFunc.
+type ShardMigrateResult struct {
+	From   string
+	To     string
+	Blocks int
+	Moved  int
+}
+
+// ParseShardFunc parses a flatfs shard function, either in its full form
+// ("/repo/flatfs/shard/v1/next-to-last/2") or without the common prefix
+// ("next-to-last/2").
+func ParseShardFunc(s string) (*flatfs.ShardIdV1, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, flatfs.PREFIX) {
+		s = flatfs.PREFIX + "v1/" + strings.TrimPrefix(s, "/")
+	}
+	return flatfs.ParseShardFunc(s)
+}
+
+// MigrateBlocksShardFunc moves the blocks of the flatfs datastore mounted on
+// /blocks to the directory layout of the shard function to, and updates the
+// SHARDING file of the datastore and the datastore config of the repo.
+//
+// Each block is hard linked into its new shard directory before being
+// unlinked from the old one, so a block is never missing from the disk. The
+// target is recorded in the repo until the migration completes; running an
+// interrupted migration again with the same target resumes it. The repo must
+// not be in use by another process.
+func MigrateBlocksShardFunc(repoPath string, to *flatfs.ShardIdV1) (*ShardMigrateResult, error) {
+	repoPath, err := homedir.Expand(filepath.Clean(repoPath))
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := lockfile.Lock(repoPath, LockFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot acquire repo lock, is the daemon running? %s", err)
+	}
+	defer lock.Close()
+
+	configFilename, err := config.Filename(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(configFilename, &mapconf); err != nil {
+		return nil, err
+	}
+	dsconf, ok := mapconf["Datastore"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("required Datastore entry missing from config file")
+	}
+	spec, ok := dsconf["Spec"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("required Datastore.Spec entry missing from config file")
+	}
+
+	_, child, err := findBlocksMount(spec)
+	if err != nil {
+		return nil, err
+	}
+	if child["type"] != "flatfs" {
+		return nil, fmt.Errorf("blocks are stored in a %v datastore, expected flatfs", child["type"])
+	}
+	flatfsPath, ok := child["path"].(string)
+	if !ok {
+		return nil, errors.New("'path' field of the flatfs datastore is missing or not string")
+	}
+	if !filepath.IsAbs(flatfsPath) {
+		flatfsPath = filepath.Join(repoPath, flatfsPath)
+	}
+
+	from, err := flatfs.ReadShardFunc(flatfsPath)
+	if err != nil {
+		return nil, err
+	}
+	res := &ShardMigrateResult{From: from.String(), To: to.String()}
+
+	progressFile := filepath.Join(repoPath, shardMigrateProgressFn)
+	if util.FileExists(progressFile) {
+		buf, err := ioutil.ReadFile(progressFile)
+		if err != nil {
+			return nil, err
+		}
+		if pending := strings.TrimSpace(string(buf)); pending != to.String() {
+			return nil, fmt.Errorf("an interrupted migration to %s must be completed first", pending)
+		}
+	} else {
+		if from.String() == to.String() {
+			return nil, fmt.Errorf("blocks are already sharded with %s", to)
+		}
+		if err := ioutil.WriteFile(progressFile, []byte(to.String()+"\n"), 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	shards, err := flatfsShards(flatfsPath)
+	if err != nil {
+		return nil, err
+	}
+	// an interrupted run may leave a block linked in both its old and its
+	// new shard, it is only counted once
+	blockNames := make(map[string]struct{})
+	for _, shard := range shards {
+		names, err := shardBlocks(filepath.Join(flatfsPath, shard))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			blockNames[name] = struct{}{}
+		}
+	}
+	res.Blocks = len(blockNames)
+
+	fun := to.Func()
+	for _, shard := range shards {
+		moved, err := reshardDir(flatfsPath, shard, fun)
+		if err != nil {
+			return nil, fmt.Errorf("migrating shard %s: %s", shard, err)
+		}
+		res.Moved += moved
+	}
+
+	// verify that every block ended up where the new layout expects it
+	shards, err = flatfsShards(flatfsPath)
+	if err != nil {
+		return nil, err
+	}
+	found := 0
+	for _, shard := range shards {
+		names, err := shardBlocks(filepath.Join(flatfsPath, shard))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if fun(strings.TrimSuffix(name, ".data")) != shard {
+				return nil, fmt.Errorf("verification failed: block %s left in shard %s", name, shard)
+			}
+		}
+		found += len(names)
+		if len(names) == 0 {
+			// drop the shard directories emptied by the migration, the
+			// removal fails harmlessly if other files are left in them
+			os.Remove(filepath.Join(flatfsPath, shard))
+		}
+	}
+	if found != res.Blocks {
+		return nil, fmt.Errorf("verification failed: found %d blocks, expected %d", found, res.Blocks)
+	}
+
+	if err := writeShardFunc(flatfsPath, to); err != nil {
+		return nil, err
+	}
+
+	child["shardFunc"] = to.String()
+	dsc, err := AnyDatastoreConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+	specFile, err := config.Path(repoPath, specFn)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(specFile, dsc.DiskSpec().Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	if err := serialize.WriteConfigFile(configFilename, mapconf); err != nil {
+		return nil, err
+	}
+
+	return res, os.Remove(progressFile)
+}
+
+// shardBlocks lists the block files of a flatfs shard directory.
+func shardBlocks(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".data") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// reshardDir moves the blocks of a shard directory into the directories given
+// by fun, and returns the number of blocks moved.
+func reshardDir(root, shard string, fun flatfs.ShardFunc) (int, error) {
+	names, err := shardBlocks(filepath.Join(root, shard))
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, name := range names {
+		dst := fun(strings.TrimSuffix(name, ".data"))
+		if dst == shard {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Join(root, dst), 0755); err != nil {
+			return 0, err
+		}
+		oldPath := filepath.Join(root, shard, name)
+		newPath := filepath.Join(root, dst, name)
+		if err := os.Link(oldPath, newPath); err != nil && !os.IsExist(err) {
+			// hard links are unsupported on some filesystems, a rename is
+			// still atomic
+			if err := os.Rename(oldPath, newPath); err != nil {
+				return 0, err
+			}
+			moved++
+			continue
+		}
+		// if the link already exists, a previous run was interrupted
+		// before removing the old file; blocks are named by their hash, so
+		// both hold the same data
+		if err := os.Remove(oldPath); err != nil {
+			return 0, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// writeShardFunc atomically replaces the SHARDING file of a flatfs datastore,
+// and the readme that goes with it.
+func writeShardFunc(dir string, id *flatfs.ShardIdV1) error {
+	tmp, err := ioutil.TempDir(dir, ".sharding-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := flatfs.WriteShardFunc(tmp, id); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(tmp, flatfs.SHARDING_FN), filepath.Join(dir, flatfs.SHARDING_FN)); err != nil {
+		return err
+	}
+
+	readme := filepath.Join(dir, flatfs.README_FN)
+	if err := os.Remove(readme); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return flatfs.WriteReadme(dir, id)
+}
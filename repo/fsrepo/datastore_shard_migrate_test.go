@@ -0,0 +1,151 @@
+package fsrepo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	blocks "github.com/ipfs/go-block-format"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	config "github.com/ipfs/go-ipfs-config"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+)
+
+// newShardMigrateRepo creates a repo at path holding a few blocks, sharded
+// with the default shard function.
+func newShardMigrateRepo(t *testing.T, path string) []blocks.Block {
+	t.Helper()
+	if err := fsrepo.Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := fsrepo.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blks []blocks.Block
+	for _, data := range []string{"foo", "bar", "baz", "qux"} {
+		b := blocks.NewBlock([]byte(data))
+		if err := bstore.NewBlockstore(r.Datastore()).Put(b); err != nil {
+			t.Fatal(err)
+		}
+		blks = append(blks, b)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return blks
+}
+
+// checkShardMigrateBlocks checks that the blocks can still be read from the
+// repo at path.
+func checkShardMigrateBlocks(t *testing.T, path string, blks []blocks.Block) {
+	t.Helper()
+	r, err := fsrepo.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	bs := bstore.NewBlockstore(r.Datastore())
+	for _, b := range blks {
+		out, err := bs.Get(b.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out.RawData()) != string(b.RawData()) {
+			t.Fatal("block data does not match")
+		}
+	}
+}
+
+func TestMigrateBlocksShardFunc(t *testing.T) {
+	loadDatastorePlugins(t)
+
+	path, err := ioutil.TempDir("", "ipfs-shard-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	blks := newShardMigrateRepo(t, path)
+
+	to, err := fsrepo.ParseShardFunc("prefix/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := fsrepo.MigrateBlocksShardFunc(path, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != len(blks) || res.Moved != len(blks) {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	fun, err := flatfs.ReadShardFunc(filepath.Join(path, "blocks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fun.String() != to.String() {
+		t.Fatalf("SHARDING not updated: %s", fun)
+	}
+
+	if _, err := fsrepo.MigrateBlocksShardFunc(path, to); err == nil {
+		t.Fatal("expected an error migrating to the current shard function")
+	}
+
+	checkShardMigrateBlocks(t, path, blks)
+}
+
+func TestMigrateBlocksShardFuncResume(t *testing.T) {
+	loadDatastorePlugins(t)
+
+	path, err := ioutil.TempDir("", "ipfs-shard-migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	blks := newShardMigrateRepo(t, path)
+
+	to, err := fsrepo.ParseShardFunc("prefix/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a run interrupted after linking a block into its new shard, before
+	// unlinking it from the old one
+	flatfsPath := filepath.Join(path, "blocks")
+	from, err := flatfs.ReadShardFunc(flatfsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := dshelp.CidToDsKey(blks[0].Cid()).String()[1:]
+	oldPath := filepath.Join(flatfsPath, from.Func()(key), key+".data")
+	newDir := filepath.Join(flatfsPath, to.Func()(key))
+	if err := os.MkdirAll(newDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(oldPath, filepath.Join(newDir, key+".data")); err != nil {
+		t.Fatal(err)
+	}
+	progress := to.String() + "\n"
+	if err := ioutil.WriteFile(filepath.Join(path, "blocks-shard-migrate.progress"), []byte(progress), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := fsrepo.MigrateBlocksShardFunc(path, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != len(blks) {
+		t.Fatalf("expected %d blocks, got %+v", len(blks), res)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the block to be removed from its old shard: %v", err)
+	}
+
+	checkShardMigrateBlocks(t, path, blks)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/ipfs/go-ipfs/repo"
 
@@ -74,6 +75,17 @@ func AddDatastoreConfigHandler(name string, dsc ConfigFromMap) error {
 	return nil
 }
 
+// DatastoreTypes returns the sorted names of all registered datastore types,
+// including the ones added by plugins.
+func DatastoreTypes() []string {
+	types := make([]string, 0, len(datastores))
+	for name := range datastores {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
 // AnyDatastoreConfig returns a DatastoreConfig from a spec based on
 // the "type" parameter
 func AnyDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error) {
@@ -83,7 +95,10 @@ func AnyDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error)
 	}
 	fun, ok := datastores[which]
 	if !ok {
-		return nil, fmt.Errorf("unknown datastore type: %s", which)
+		// types other than the builtin ones are provided by plugins, which
+		// may simply not be installed
+		return nil, fmt.Errorf("unknown datastore type: %s (is the plugin providing it installed? known types: %s)",
+			which, strings.Join(DatastoreTypes(), ", "))
 	}
 	return fun(params)
 }
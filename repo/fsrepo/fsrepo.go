@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -103,6 +104,10 @@ type FSRepo struct {
 	ds       repo.Datastore
 	keystore keystore.Keystore
 	filemgr  *filestore.FileManager
+	// encrypted are the config fields decrypted when opening the repo, nil
+	// if it was opened without passphrase.
+	encrypted  map[string]encryptedField
+	passphrase string
 }
 
 var _ repo.Repo = (*FSRepo)(nil)
@@ -218,7 +223,31 @@ func ConfigAt(repoPath string) (*config.Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	return serialize.Load(configFilename)
+	return loadConfig(configFilename)
+}
+
+// loadConfig reads the config file like serialize.Load, flattening the
+// bootstrap entries with metadata.
+func loadConfig(configFilename string) (*config.Config, error) {
+	// if nothing is there, fail. User must run 'ipfs init'
+	if !util.FileExists(configFilename) {
+		return nil, errors.New("ipfs not initialized, please run 'ipfs init'")
+	}
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(configFilename, &mapconf); err != nil {
+		return nil, err
+	}
+	return ConfigFromFileMap(mapconf)
+}
+
+// ConfigFromFileMap converts the map of a config file to the config struct,
+// like config.FromMap, flattening the bootstrap entries with metadata.
+func ConfigFromFileMap(mapconf map[string]interface{}) (*config.Config, error) {
+	flat, err := repo.FlattenBootstrap(mapconf)
+	if err != nil {
+		return nil, fmt.Errorf("failure to decode config: %s", err)
+	}
+	return config.FromMap(flat)
 }
 
 // configIsInitialized returns true if the repo is initialized at
@@ -375,10 +404,15 @@ func (r *FSRepo) openConfig() error {
 	if err != nil {
 		return err
 	}
-	conf, err := serialize.Load(configFilename)
+	conf, err := loadConfig(configFilename)
 	if err != nil {
 		return err
 	}
+	if ConfigPassphrase != nil {
+		if conf, err = r.decryptConfig(configFilename, conf); err != nil {
+			return err
+		}
+	}
 	r.config = conf
 	return nil
 }
@@ -543,8 +577,15 @@ func (r *FSRepo) setConfigUnsynced(updated *config.Config) error {
 		return err
 	}
 	for k, v := range m {
+		if sub, ok := v.(map[string]interface{}); ok {
+			preserveUnknownKeys(sub, mapconf[k], configFieldType(k))
+		}
+		if k == repo.BootstrapConfigKey {
+			v = repo.KeepBootstrapEntries(v, mapconf[k])
+		}
 		mapconf[k] = v
 	}
+	r.encryptFields(mapconf)
 	if err := serialize.WriteConfigFile(configFilename, mapconf); err != nil {
 		return err
 	}
@@ -554,6 +595,44 @@ func (r *FSRepo) setConfigUnsynced(updated *config.Config) error {
 	return nil
 }
 
+// configFieldType returns the type of the top level config section with the
+// given name, or nil if there is no such section.
+func configFieldType(name string) reflect.Type {
+	f, ok := reflect.TypeOf(config.Config{}).FieldByName(name)
+	if !ok {
+		return nil
+	}
+	return f.Type
+}
+
+// preserveUnknownKeys copies into updated the keys of old that don't match a
+// field of the struct type t, recursing into nested structs. This keeps the
+// custom keys of a config section, like 'Datastore.BlockCacheSize', when the
+// section is rewritten from the config struct. Maps and other values are
+// replaced as a whole so that removing an entry still works.
+func preserveUnknownKeys(updated map[string]interface{}, old interface{}, t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	oldMap, ok := old.(map[string]interface{})
+	if !ok || t == nil || t.Kind() != reflect.Struct {
+		return
+	}
+
+	for k, v := range oldMap {
+		f, known := t.FieldByName(k)
+		if !known {
+			if _, exists := updated[k]; !exists {
+				updated[k] = v
+			}
+			continue
+		}
+		if sub, ok := updated[k].(map[string]interface{}); ok {
+			preserveUnknownKeys(sub, v, f.Type)
+		}
+	}
+}
+
 // SetConfig updates the FSRepo's config. The user must not modify the config
 // object after calling this method.
 func (r *FSRepo) SetConfig(updated *config.Config) error {
@@ -582,6 +661,7 @@ func (r *FSRepo) GetConfigKey(key string) (interface{}, error) {
 	if err := serialize.ReadConfigFile(filename, &cfg); err != nil {
 		return nil, err
 	}
+	r.decryptFields(cfg)
 	return common.MapGetKV(cfg, key)
 }
 
@@ -656,15 +736,29 @@ func (r *FSRepo) SetConfigKey(key string, value interface{}) error {
 		return err
 	}
 
+	if err := r.trackEncrypted(key, value); err != nil {
+		return err
+	}
+	// the fields within value, like a section read with GetConfigKey, are
+	// written back encrypted
+	r.encryptFields(mapconf)
+
 	// This step doubles as to validate the map against the struct
 	// before serialization
-	conf, err := config.FromMap(mapconf)
+	conf, err := ConfigFromFileMap(mapconf)
 	if err != nil {
 		return err
 	}
 	if err := serialize.WriteConfigFile(filename, mapconf); err != nil {
 		return err
 	}
+	if len(r.encrypted) > 0 {
+		// keep the decrypted values in the config of the repo
+		r.decryptFields(mapconf)
+		if conf, err = ConfigFromFileMap(mapconf); err != nil {
+			return err
+		}
+	}
 	return r.setConfigUnsynced(conf) // TODO roll this into this method
 }
 
@@ -7,10 +7,14 @@ import (
 	"path/filepath"
 	"testing"
 
+	repo "github.com/ipfs/go-ipfs/repo"
+	common "github.com/ipfs/go-ipfs/repo/common"
+	configcrypt "github.com/ipfs/go-ipfs/repo/fsrepo/configcrypt"
 	"github.com/ipfs/go-ipfs/thirdparty/assert"
 
 	datastore "github.com/ipfs/go-datastore"
 	config "github.com/ipfs/go-ipfs-config"
+	serialize "github.com/ipfs/go-ipfs-config/serialize"
 )
 
 // swap arg order
@@ -116,3 +120,188 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+func TestSetConfigPreservesUnknownKeys(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("", t)
+	assert.Nil(Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}), t)
+
+	r, err := Open(path)
+	assert.Nil(err, t)
+	defer r.Close()
+
+	cfg, err := r.Config()
+	assert.Nil(err, t)
+	updated, err := cfg.Clone()
+	assert.Nil(err, t)
+	updated.Gateway.HTTPHeaders = map[string][]string{"X-A": {"a"}}
+	assert.Nil(r.SetConfig(updated), t)
+
+	// add a key unknown to the config struct by hand
+	filename, err := config.Filename(path)
+	assert.Nil(err, t)
+	var mapconf map[string]interface{}
+	assert.Nil(serialize.ReadConfigFile(filename, &mapconf), t)
+	mapconf["Datastore"].(map[string]interface{})["CustomKey"] = "custom"
+	assert.Nil(serialize.WriteConfigFile(filename, mapconf), t)
+
+	updated, err = updated.Clone()
+	assert.Nil(err, t)
+	updated.Datastore.StorageMax = "1GB"
+	updated.Gateway.HTTPHeaders = map[string][]string{}
+	assert.Nil(r.SetConfig(updated), t)
+
+	v, err := r.GetConfigKey("Datastore.CustomKey")
+	assert.Nil(err, t, "custom key should survive SetConfig")
+	assert.True(v == "custom", t, "custom key should keep its value")
+
+	v, err = r.GetConfigKey("Datastore.StorageMax")
+	assert.Nil(err, t)
+	assert.True(v == "1GB", t, "known keys should be updated")
+
+	_, err = r.GetConfigKey("Gateway.HTTPHeaders.X-A")
+	assert.Err(err, t, "entries removed from a map should not come back")
+}
+
+func TestBootstrapEntries(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("", t)
+	assert.Nil(Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}), t)
+
+	const (
+		bp1 = "/ip4/1.2.3.4/tcp/4001/ipfs/QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z"
+		bp2 = "/ip4/5.6.7.8/tcp/4001/ipfs/QmSoLueR4xBeUbY9WZ9xGUUxunbKWcrNFTDAadQJmocnWm"
+	)
+	filename, err := config.Filename(path)
+	assert.Nil(err, t)
+	var mapconf map[string]interface{}
+	assert.Nil(serialize.ReadConfigFile(filename, &mapconf), t)
+	mapconf["Bootstrap"] = []interface{}{
+		map[string]interface{}{"addr": bp1, "region": "us-east-1"},
+		bp2,
+	}
+	assert.Nil(serialize.WriteConfigFile(filename, mapconf), t)
+
+	r, err := Open(path)
+	assert.Nil(err, t, "entries with metadata should be read")
+	defer r.Close()
+	cfg, err := r.Config()
+	assert.Nil(err, t)
+	assert.True(len(cfg.Bootstrap) == 2 && cfg.Bootstrap[0] == bp1, t, "the config struct should hold the addresses")
+
+	updated, err := cfg.Clone()
+	assert.Nil(err, t)
+	updated.Bootstrap = updated.Bootstrap[:1]
+	assert.Nil(r.SetConfig(updated), t)
+
+	v, err := r.GetConfigKey("Bootstrap")
+	assert.Nil(err, t)
+	entries, err := repo.ParseBootstrapEntries(v)
+	assert.Nil(err, t)
+	assert.True(len(entries) == 1, t, "removed entries should not come back")
+	assert.True(entries[0] == repo.BootstrapEntry{Addr: bp1, Region: "us-east-1"}, t, "the region should survive SetConfig")
+
+	mapconf["Bootstrap"] = []interface{}{map[string]interface{}{"region": "us-east-1"}}
+	assert.Nil(serialize.WriteConfigFile(filename, mapconf), t)
+	_, err = ConfigAt(path)
+	assert.Err(err, t, "entries without an address should be refused")
+}
+
+func TestEncryptedConfig(t *testing.T) {
+	path := testRepoPath("", t)
+	memDatastore := config.Datastore{Spec: map[string]interface{}{"type": "mem"}}
+	identity := config.Identity{PeerID: "peer", PrivKey: "key"}
+	assert.Nil(Init(path, &config.Config{Identity: identity, Datastore: memDatastore}), t)
+
+	ciphertext, err := configcrypt.Encrypt("secret", "passphrase")
+	assert.Nil(err, t)
+	r, err := Open(path)
+	assert.Nil(err, t)
+	assert.Nil(r.SetConfigKey("Gateway.RootRedirect", ciphertext), t)
+	cfg, err := r.Config()
+	assert.Nil(err, t)
+	assert.True(cfg.Gateway.RootRedirect == ciphertext, t, "fields should stay encrypted without passphrase")
+	assert.Nil(r.Close(), t)
+
+	defer func() { ConfigPassphrase = nil }()
+	ConfigPassphrase = func() (string, error) { return "wrong", nil }
+	_, err = Open(path)
+	assert.Err(err, t, "opening with the wrong passphrase should fail")
+
+	ConfigPassphrase = func() (string, error) { return "passphrase", nil }
+	r, err = Open(path)
+	assert.Nil(err, t)
+	defer r.Close()
+	cfg, err = r.Config()
+	assert.Nil(err, t)
+	assert.True(cfg.Gateway.RootRedirect == "secret", t, "fields should be decrypted with the passphrase")
+
+	// writing the config keeps the field encrypted
+	updated, err := cfg.Clone()
+	assert.Nil(err, t)
+	updated.Datastore.StorageMax = "1GB"
+	assert.Nil(r.SetConfig(updated), t)
+	assert.Nil(r.SetConfigKey("Datastore.StorageMax", "2GB"), t)
+	assert.True(storedConfigKey(t, path, "Gateway.RootRedirect") == ciphertext, t, "the field should be written encrypted")
+	v, err := r.GetConfigKey("Gateway.RootRedirect")
+	assert.Nil(err, t)
+	assert.True(v == "secret", t, "GetConfigKey should decrypt the field")
+	cfg, err = r.Config()
+	assert.Nil(err, t)
+	assert.True(cfg.Gateway.RootRedirect == "secret", t, "the field should stay decrypted in memory")
+
+	other, err := configcrypt.Encrypt("other", "other passphrase")
+	assert.Nil(err, t)
+	assert.Err(r.SetConfigKey("Gateway.RootRedirect", other), t, "fields should use the same passphrase")
+
+	// once set in plaintext, the field is not encrypted again
+	assert.Nil(r.SetConfigKey("Gateway.RootRedirect", "plain"), t)
+	assert.Nil(r.SetConfig(updated), t)
+	assert.True(storedConfigKey(t, path, "Gateway.RootRedirect") == "secret", t, "the field should be stored in plaintext")
+}
+
+func TestEncryptedConfigSection(t *testing.T) {
+	path := testRepoPath("", t)
+	memDatastore := config.Datastore{Spec: map[string]interface{}{"type": "mem"}}
+	identity := config.Identity{PeerID: "peer", PrivKey: "key"}
+	assert.Nil(Init(path, &config.Config{Identity: identity, Datastore: memDatastore}), t)
+
+	const keyField = "Pinning.RemoteServices.svc.API.Key"
+	ciphertext, err := configcrypt.Encrypt("token", "passphrase")
+	assert.Nil(err, t)
+	r, err := Open(path)
+	assert.Nil(err, t)
+	assert.Nil(r.SetConfigKey(keyField, ciphertext), t)
+	assert.Nil(r.Close(), t)
+
+	defer func() { ConfigPassphrase = nil }()
+	ConfigPassphrase = func() (string, error) { return "passphrase", nil }
+	r, err = Open(path)
+	assert.Nil(err, t)
+	defer r.Close()
+	v, err := r.GetConfigKey(keyField)
+	assert.Nil(err, t)
+	assert.True(v == "token", t, "GetConfigKey should decrypt the fields outside of the config struct")
+
+	// rewriting the section keeps the field encrypted
+	services, err := r.GetConfigKey("Pinning.RemoteServices")
+	assert.Nil(err, t)
+	services.(map[string]interface{})["other"] = map[string]interface{}{"API": map[string]interface{}{"Key": "plain"}}
+	assert.Nil(r.SetConfigKey("Pinning.RemoteServices", services), t)
+	assert.True(storedConfigKey(t, path, keyField) == ciphertext, t, "the field should be written encrypted")
+	v, err = r.GetConfigKey(keyField)
+	assert.Nil(err, t)
+	assert.True(v == "token", t, "the field should still be decrypted")
+}
+
+// storedConfigKey returns the value of key in the config file of the repo
+// at path.
+func storedConfigKey(t *testing.T, path, key string) interface{} {
+	filename, err := config.Filename(path)
+	assert.Nil(err, t)
+	var cfg map[string]interface{}
+	assert.Nil(serialize.ReadConfigFile(filename, &cfg), t)
+	v, err := common.MapGetKV(cfg, key)
+	assert.Nil(err, t)
+	return v
+}
@@ -34,7 +34,55 @@ func migrationsBinName() string {
 	}
 }
 
-func RunMigration(newv int) error {
+// RunMigration migrates the repo at repoPath to version newv. The
+// migrations of the plugins are applied from the versions they start at,
+// and fs-repo-migrations in between.
+func RunMigration(repoPath string, newv int) error {
+	rp := RepoPath(repoPath)
+	cur, err := rp.Version()
+	if err != nil {
+		return err
+	}
+
+	var migrateBin string
+	for cur < newv {
+		if m := pluginMigration(cur, newv); m != nil {
+			fmt.Printf("  => Running the plugin migration from version %d to %d.\n", cur, m.ToVersion())
+			if err := ApplyMigration(repoPath, m); err != nil {
+				fmt.Printf("  => Failed: plugin migration from version %d to %d\n", cur, m.ToVersion())
+				return fmt.Errorf("migration failed: %s", err)
+			}
+			cur = m.ToVersion()
+			continue
+		}
+
+		if migrateBin == "" {
+			migrateBin, err = findMigrationsBin(newv)
+			if err != nil {
+				return err
+			}
+		}
+		to := nextPluginVersion(cur, newv)
+		if err := runMigrationsBin(migrateBin, repoPath, to); err != nil {
+			return err
+		}
+		if cur, err = rp.Version(); err != nil {
+			return err
+		}
+		if cur != to {
+			return fmt.Errorf("migration failed: the repo is at version %d instead of %d", cur, to)
+		}
+	}
+
+	fmt.Printf("  => Success: fs-repo has been migrated to version %d.\n", newv)
+
+	return nil
+}
+
+// findMigrationsBin returns the path of a fs-repo-migrations binary
+// supporting version newv, downloading it if needed. It is a var, like
+// runMigrationsBin, for testing purposes.
+var findMigrationsBin = func(newv int) (string, error) {
 	migrateBin := migrationsBinName()
 
 	fmt.Println("  => Looking for suitable fs-repo-migrations binary.")
@@ -52,31 +100,33 @@ func RunMigration(newv int) error {
 		loc, err := GetMigrations()
 		if err != nil {
 			fmt.Println("  => Failed to download fs-repo-migrations.")
-			return err
+			return "", err
 		}
 
 		err = verifyMigrationSupportsVersion(loc, newv)
 		if err != nil {
-			return fmt.Errorf("no fs-repo-migration binary found for version %d: %s", newv, err)
+			return "", fmt.Errorf("no fs-repo-migration binary found for version %d: %s", newv, err)
 		}
 
 		migrateBin = loc
 	}
+	return migrateBin, nil
+}
 
-	cmd := exec.Command(migrateBin, "-to", fmt.Sprint(newv), "-y")
+// runMigrationsBin runs fs-repo-migrations on the repo up to version to.
+var runMigrationsBin = func(migrateBin, repoPath string, to int) error {
+	cmd := exec.Command(migrateBin, "-to", fmt.Sprint(to), "-y")
+	cmd.Env = append(os.Environ(), "IPFS_PATH="+repoPath)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	fmt.Printf("  => Running: %s -to %d -y\n", migrateBin, newv)
+	fmt.Printf("  => Running: %s -to %d -y\n", migrateBin, to)
 
-	err = cmd.Run()
+	err := cmd.Run()
 	if err != nil {
-		fmt.Printf("  => Failed: %s -to %d -y\n", migrateBin, newv)
+		fmt.Printf("  => Failed: %s -to %d -y\n", migrateBin, to)
 		return fmt.Errorf("migration failed: %s", err)
 	}
-
-	fmt.Printf("  => Success: fs-repo has been migrated to version %d.\n", newv)
-
 	return nil
 }
 
@@ -0,0 +1,41 @@
+// Package migrationtest is a test harness for the repo migrations of
+// plugins.
+package migrationtest
+
+import (
+	"testing"
+
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+)
+
+// NewRepo returns the path of a temporary repo at the given version, whose
+// content is written by setup.
+func NewRepo(t testing.TB, version int, setup func(repoPath string) error) string {
+	t.Helper()
+	repoPath := t.TempDir()
+	if err := mfsr.RepoPath(repoPath).WriteVersion(version); err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		if err := setup(repoPath); err != nil {
+			t.Fatalf("setting up the repo: %s", err)
+		}
+	}
+	return repoPath
+}
+
+// Run applies m to a new repo at m.FromVersion(), set up by setup, the way
+// the migration runner does. It fails t unless the migration succeeds and
+// the repo ends at m.ToVersion(), and returns the path of the repo for the
+// test to check the migrated content.
+func Run(t testing.TB, m mfsr.Migration, setup func(repoPath string) error) string {
+	t.Helper()
+	repoPath := NewRepo(t, m.FromVersion(), setup)
+	if err := mfsr.ApplyMigration(repoPath, m); err != nil {
+		t.Fatalf("migration from version %d to %d: %s", m.FromVersion(), m.ToVersion(), err)
+	}
+	if err := mfsr.RepoPath(repoPath).CheckVersion(m.ToVersion()); err != nil {
+		t.Fatal(err)
+	}
+	return repoPath
+}
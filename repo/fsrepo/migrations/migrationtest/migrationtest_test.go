@@ -0,0 +1,36 @@
+package migrationtest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// renameMigration renames the file old of the repo to new.
+type renameMigration struct {
+	from, to int
+	old, new string
+}
+
+func (m *renameMigration) FromVersion() int { return m.from }
+func (m *renameMigration) ToVersion() int   { return m.to }
+
+func (m *renameMigration) Migrate(repoPath string) error {
+	return os.Rename(filepath.Join(repoPath, m.old), filepath.Join(repoPath, m.new))
+}
+
+func TestRun(t *testing.T) {
+	m := &renameMigration{from: 7, to: 8, old: "store", new: "blocks"}
+	repoPath := Run(t, m, func(repoPath string) error {
+		return ioutil.WriteFile(filepath.Join(repoPath, "store"), []byte("data"), 0644)
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join(repoPath, "blocks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Fatalf("unexpected content %q", data)
+	}
+}
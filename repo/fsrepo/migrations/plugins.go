@@ -0,0 +1,81 @@
+package mfsr
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Migration is a repo migration provided by a plugin, for the repo formats
+// fs-repo-migrations doesn't know about.
+type Migration interface {
+	// FromVersion is the version of the repos the migration applies to.
+	FromVersion() int
+	// ToVersion is the version of the repos once migrated, greater than
+	// FromVersion.
+	ToVersion() int
+	// Migrate migrates the repo at repoPath. The version file is written by
+	// the caller once it succeeds.
+	Migrate(repoPath string) error
+}
+
+var (
+	pluginMigrationsMu sync.Mutex
+	// pluginMigrations are the migrations of the plugins, by FromVersion.
+	pluginMigrations = make(map[int]Migration)
+)
+
+// AddMigration registers a plugin migration, to be run by RunMigration.
+// There can be only one migration from a given version.
+func AddMigration(m Migration) error {
+	from, to := m.FromVersion(), m.ToVersion()
+	if to <= from {
+		return fmt.Errorf("invalid migration from version %d to %d", from, to)
+	}
+
+	pluginMigrationsMu.Lock()
+	defer pluginMigrationsMu.Unlock()
+	if other, ok := pluginMigrations[from]; ok {
+		return fmt.Errorf("a migration from version %d to %d is already registered", from, other.ToVersion())
+	}
+	pluginMigrations[from] = m
+	return nil
+}
+
+// pluginMigration returns the plugin migration from version cur, nil if
+// there is none not going beyond version newv.
+func pluginMigration(cur, newv int) Migration {
+	pluginMigrationsMu.Lock()
+	defer pluginMigrationsMu.Unlock()
+	m, ok := pluginMigrations[cur]
+	if !ok || m.ToVersion() > newv {
+		return nil
+	}
+	return m
+}
+
+// nextPluginVersion returns the first version after cur from which a plugin
+// migration applies, newv if there is none before.
+func nextPluginVersion(cur, newv int) int {
+	pluginMigrationsMu.Lock()
+	defer pluginMigrationsMu.Unlock()
+	next := newv
+	for from, m := range pluginMigrations {
+		if from > cur && from < next && m.ToVersion() <= newv {
+			next = from
+		}
+	}
+	return next
+}
+
+// ApplyMigration runs m on the repo at repoPath, which must be at version
+// m.FromVersion(), and writes its new version.
+func ApplyMigration(repoPath string, m Migration) error {
+	rp := RepoPath(repoPath)
+	if err := rp.CheckVersion(m.FromVersion()); err != nil {
+		return err
+	}
+	if err := m.Migrate(repoPath); err != nil {
+		return err
+	}
+	return rp.WriteVersion(m.ToVersion())
+}
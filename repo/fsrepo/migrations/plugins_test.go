@@ -0,0 +1,132 @@
+package mfsr
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type testMigration struct {
+	from, to int
+	steps    *[]string
+}
+
+func (m *testMigration) FromVersion() int { return m.from }
+func (m *testMigration) ToVersion() int   { return m.to }
+
+func (m *testMigration) Migrate(string) error {
+	*m.steps = append(*m.steps, fmt.Sprintf("plugin %d-%d", m.from, m.to))
+	return nil
+}
+
+// withPluginMigrations registers the migrations between from and to pairs,
+// and fakes fs-repo-migrations, for the duration of the test. The steps run
+// are appended to steps.
+func withPluginMigrations(t *testing.T, steps *[]string, versions ...int) {
+	oldMigrations, oldFind, oldRun := pluginMigrations, findMigrationsBin, runMigrationsBin
+	t.Cleanup(func() {
+		pluginMigrations, findMigrationsBin, runMigrationsBin = oldMigrations, oldFind, oldRun
+	})
+
+	pluginMigrations = make(map[int]Migration)
+	for i := 0; i < len(versions); i += 2 {
+		if err := AddMigration(&testMigration{from: versions[i], to: versions[i+1], steps: steps}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	findMigrationsBin = func(int) (string, error) {
+		return "fs-repo-migrations", nil
+	}
+	runMigrationsBin = func(bin, repoPath string, to int) error {
+		*steps = append(*steps, fmt.Sprintf("builtin %d", to))
+		return RepoPath(repoPath).WriteVersion(to)
+	}
+}
+
+func testRepo(t *testing.T, version int) string {
+	dir, err := ioutil.TempDir("", "mfsr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := RepoPath(dir).WriteVersion(version); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestAddMigration(t *testing.T) {
+	var steps []string
+	withPluginMigrations(t, &steps, 6, 7)
+
+	if err := AddMigration(&testMigration{from: 8, to: 8}); err == nil {
+		t.Fatal("expected an error for a migration to the same version")
+	}
+	if err := AddMigration(&testMigration{from: 6, to: 9}); err == nil {
+		t.Fatal("expected an error for a second migration from version 6")
+	}
+}
+
+func TestRunMigrationInterleaved(t *testing.T) {
+	var steps []string
+	withPluginMigrations(t, &steps, 6, 8, 9, 10)
+
+	repo := testRepo(t, 5)
+	if err := RunMigration(repo, 12); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"builtin 6", "plugin 6-8", "builtin 9", "plugin 9-10", "builtin 12"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("expected the steps %v, got %v", expected, steps)
+	}
+	if err := RepoPath(repo).CheckVersion(12); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunMigrationPluginsOnly(t *testing.T) {
+	var steps []string
+	withPluginMigrations(t, &steps, 7, 8, 8, 9)
+	findMigrationsBin = func(int) (string, error) {
+		return "", errors.New("fs-repo-migrations should not be needed")
+	}
+
+	repo := testRepo(t, 7)
+	if err := RunMigration(repo, 9); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"plugin 7-8", "plugin 8-9"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("expected the steps %v, got %v", expected, steps)
+	}
+}
+
+func TestRunMigrationBeyondTarget(t *testing.T) {
+	var steps []string
+	// the plugin migration goes past the version of the program
+	withPluginMigrations(t, &steps, 8, 11)
+
+	repo := testRepo(t, 7)
+	if err := RunMigration(repo, 10); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"builtin 10"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("expected the steps %v, got %v", expected, steps)
+	}
+}
+
+func TestRunMigrationStuck(t *testing.T) {
+	var steps []string
+	withPluginMigrations(t, &steps)
+	runMigrationsBin = func(string, string, int) error {
+		return nil
+	}
+
+	if err := RunMigration(testRepo(t, 7), 8); err == nil {
+		t.Fatal("expected an error when fs-repo-migrations doesn't migrate the repo")
+	}
+}
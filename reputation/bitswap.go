@@ -0,0 +1,141 @@
+package reputation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	bsmsg "github.com/ipfs/go-bitswap/message"
+	bsnet "github.com/ipfs/go-bitswap/network"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// WantTTL is how long a peer the node asked for a block may send it, even
+// after the want was cancelled: the block may have been on its way as it
+// arrived from another peer.
+const WantTTL = 10 * time.Minute
+
+// WrapBitswapNetwork returns a bitswap network scoring the peers of t by the
+// blocks they send through n. A block is valid if the node asked the peer
+// for it, through n, in the last WantTTL. The blocks has returns true for,
+// the ones already stored, are neither rewarded nor penalized, and the
+// messages of banned peers are dropped.
+func (t *Tracker) WrapBitswapNetwork(n bsnet.BitSwapNetwork, has func(cid.Cid) bool) bsnet.BitSwapNetwork {
+	return &scoringNetwork{
+		BitSwapNetwork: n,
+		tracker:        t,
+		has:            has,
+		wants:          make(map[peer.ID]map[cid.Cid]time.Time),
+	}
+}
+
+type scoringNetwork struct {
+	bsnet.BitSwapNetwork
+	tracker *Tracker
+	has     func(cid.Cid) bool
+
+	mu        sync.Mutex
+	wants     map[peer.ID]map[cid.Cid]time.Time // when the blocks were asked for
+	lastPrune time.Time
+}
+
+// recordWants remembers the blocks asked for in a message sent to p.
+func (sn *scoringNetwork) recordWants(p peer.ID, msg bsmsg.BitSwapMessage) {
+	entries := msg.Wantlist()
+	if len(entries) == 0 {
+		return
+	}
+
+	now := time.Now()
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+
+	if now.Sub(sn.lastPrune) >= WantTTL {
+		sn.lastPrune = now
+		for pw, wants := range sn.wants {
+			for c, at := range wants {
+				if now.Sub(at) >= WantTTL {
+					delete(wants, c)
+				}
+			}
+			if len(wants) == 0 {
+				delete(sn.wants, pw)
+			}
+		}
+	}
+
+	wants, ok := sn.wants[p]
+	if !ok {
+		wants = make(map[cid.Cid]time.Time)
+		sn.wants[p] = wants
+	}
+	for _, e := range entries {
+		// a cancel restarts the grace period of the block
+		if _, asked := wants[e.Cid]; asked || !e.Cancel {
+			wants[e.Cid] = now
+		}
+	}
+}
+
+// asked returns whether p was asked for c in the last WantTTL.
+func (sn *scoringNetwork) asked(p peer.ID, c cid.Cid) bool {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+	at, ok := sn.wants[p][c]
+	return ok && time.Since(at) < WantTTL
+}
+
+func (sn *scoringNetwork) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	sn.recordWants(p, msg)
+	return sn.BitSwapNetwork.SendMessage(ctx, p, msg)
+}
+
+func (sn *scoringNetwork) NewMessageSender(ctx context.Context, p peer.ID) (bsnet.MessageSender, error) {
+	ms, err := sn.BitSwapNetwork.NewMessageSender(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &scoringSender{MessageSender: ms, net: sn, peer: p}, nil
+}
+
+func (sn *scoringNetwork) SetDelegate(r bsnet.Receiver) {
+	sn.BitSwapNetwork.SetDelegate(&scoringReceiver{Receiver: r, net: sn})
+}
+
+type scoringSender struct {
+	bsnet.MessageSender
+	net  *scoringNetwork
+	peer peer.ID
+}
+
+func (ss *scoringSender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	ss.net.recordWants(ss.peer, msg)
+	return ss.MessageSender.SendMsg(ctx, msg)
+}
+
+type scoringReceiver struct {
+	bsnet.Receiver
+	net *scoringNetwork
+}
+
+func (sr *scoringReceiver) ReceiveMessage(ctx context.Context, sender peer.ID, incoming bsmsg.BitSwapMessage) {
+	t := sr.net.tracker
+	if _, banned := t.Banned(sender); banned {
+		return
+	}
+	for _, b := range incoming.Blocks() {
+		switch {
+		case sr.net.has(b.Cid()):
+			// a duplicate, not worth anything but not harmful either
+		case sr.net.asked(sender, b.Cid()):
+			t.Reward(sender)
+		default:
+			t.Penalize(sender, InvalidBlockPenalty)
+		}
+	}
+	if _, banned := t.Banned(sender); banned {
+		return
+	}
+	sr.Receiver.ReceiveMessage(ctx, sender, incoming)
+}
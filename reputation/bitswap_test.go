@@ -0,0 +1,87 @@
+package reputation
+
+import (
+	"context"
+	"testing"
+
+	bsmsg "github.com/ipfs/go-bitswap/message"
+	bsnet "github.com/ipfs/go-bitswap/network"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+	testutil "github.com/libp2p/go-testutil"
+)
+
+// fakeNetwork only implements the methods the scoring network uses.
+type fakeNetwork struct {
+	bsnet.BitSwapNetwork
+	receiver bsnet.Receiver
+}
+
+func (n *fakeNetwork) SetDelegate(r bsnet.Receiver) {
+	n.receiver = r
+}
+
+func (n *fakeNetwork) SendMessage(context.Context, peer.ID, bsmsg.BitSwapMessage) error {
+	return nil
+}
+
+type fakeReceiver struct {
+	bsnet.Receiver
+	received int
+}
+
+func (r *fakeReceiver) ReceiveMessage(context.Context, peer.ID, bsmsg.BitSwapMessage) {
+	r.received++
+}
+
+func TestScoringNetwork(t *testing.T) {
+	p, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	asked := blocks.NewBlock([]byte("asked"))
+	cancelled := blocks.NewBlock([]byte("cancelled"))
+	stored := blocks.NewBlock([]byte("stored"))
+	unsolicited := blocks.NewBlock([]byte("unsolicited"))
+
+	tr := NewTracker()
+	fn := &fakeNetwork{}
+	has := func(c cid.Cid) bool { return c.Equals(stored.Cid()) }
+	sn := tr.WrapBitswapNetwork(fn, has)
+	r := &fakeReceiver{}
+	sn.SetDelegate(r)
+
+	want := bsmsg.New(false)
+	want.AddEntry(asked.Cid(), 1)
+	want.AddEntry(cancelled.Cid(), 1)
+	if err := sn.SendMessage(context.Background(), p, want); err != nil {
+		t.Fatal(err)
+	}
+	cancel := bsmsg.New(false)
+	cancel.Cancel(cancelled.Cid())
+	if err := sn.SendMessage(context.Background(), p, cancel); err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func(bs ...blocks.Block) {
+		msg := bsmsg.New(false)
+		for _, b := range bs {
+			msg.AddBlock(b)
+		}
+		fn.receiver.ReceiveMessage(context.Background(), p, msg)
+	}
+
+	tr.Penalize(p, 2*BlockReward)
+	receive(asked, cancelled, stored)
+	if s := tr.Score(p); s != InitialScore {
+		t.Fatalf("expected the asked blocks to be rewarded only, got a score of %d", s)
+	}
+	receive(unsolicited)
+	if s := tr.Score(p); s != InitialScore-InvalidBlockPenalty {
+		t.Fatalf("expected the unsolicited block to be penalized, got a score of %d", s)
+	}
+	if r.received != 2 {
+		t.Fatalf("expected the messages to be passed on, got %d", r.received)
+	}
+}
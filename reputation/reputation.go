@@ -0,0 +1,328 @@
+// Package reputation scores the peers of a node by their behaviour, and bans
+// the ones that misbehave for a while.
+//
+// Every peer starts with InitialScore points. A peer sending blocks the node
+// didn't ask for loses InvalidBlockPenalty points, a block transferred
+// successfully earns it BlockReward points, up to MaxScore. A peer whose score
+// goes below zero is banned: its connections are closed, and new ones refused,
+// until the ban expires. Each ban lasts twice as long as the previous one.
+//
+// The peers are forgotten ForgetAfter after their score last changed, unless
+// they are banned, so that the tracker doesn't grow with every peer seen.
+//
+// The trusted peers are never scored nor banned, and their connections are
+// kept by the connection manager.
+package reputation
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	ifconnmgr "github.com/libp2p/go-libp2p-interface-connmgr"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("reputation")
+
+const (
+	// InitialScore is the score of a peer never seen before.
+	InitialScore = 100
+
+	// MaxScore is the score a peer can't go over.
+	MaxScore = 100
+
+	// InvalidBlockPenalty is deducted from the score of a peer for each
+	// invalid block it sends.
+	InvalidBlockPenalty = 10
+
+	// BlockReward is added to the score of a peer for each block it sends
+	// successfully.
+	BlockReward = 1
+
+	// BaseBanDuration is the duration of the first ban of a peer.
+	BaseBanDuration = time.Minute
+
+	// MaxBanDuration caps the duration of a ban.
+	MaxBanDuration = 24 * time.Hour
+
+	// ForgetAfter is how long the state of a peer is kept after its score
+	// last changed, when it isn't banned.
+	ForgetAfter = 24 * time.Hour
+
+	// pruneInterval is how often the peers to forget are looked for.
+	pruneInterval = time.Minute
+
+	// TrustedTag is the connection manager tag of the trusted peers.
+	TrustedTag = "trusted"
+
+	// TrustedTagValue is the value of TrustedTag, high enough for the
+	// connections of the trusted peers to be trimmed last.
+	TrustedTagValue = 1000
+)
+
+type peerScore struct {
+	score       int
+	bans        uint
+	bannedUntil time.Time
+	updated     time.Time
+}
+
+// PeerScore is the state of a peer in a Tracker.
+type PeerScore struct {
+	Peer        peer.ID
+	Score       int
+	BannedUntil time.Time // zero if the peer isn't banned
+}
+
+// Tracker keeps the scores of the peers, and bans the ones going below zero.
+type Tracker struct {
+	mu      sync.Mutex
+	peers   map[peer.ID]*peerScore
+	trusted map[peer.ID]bool
+	net     inet.Network
+	cm      ifconnmgr.ConnManager
+
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewTracker returns a Tracker where all the peers have the initial score.
+func NewTracker() *Tracker {
+	return &Tracker{
+		peers:   make(map[peer.ID]*peerScore),
+		trusted: make(map[peer.ID]bool),
+		now:     time.Now,
+	}
+}
+
+// lookup returns the state of p, lifting its ban if it expired. The state of
+// the peers without one is not stored: get must be used to change it. t.mu
+// must be held.
+func (t *Tracker) lookup(p peer.ID) *peerScore {
+	ps, ok := t.peers[p]
+	if !ok {
+		return &peerScore{score: InitialScore}
+	}
+	if !ps.bannedUntil.IsZero() && !t.now().Before(ps.bannedUntil) {
+		// served its sentence, the next ban will be longer
+		ps.bannedUntil = time.Time{}
+		ps.score = InitialScore
+		ps.updated = t.now()
+	}
+	return ps
+}
+
+// get returns the state of p to change it, like lookup. t.mu must be held.
+func (t *Tracker) get(p peer.ID) *peerScore {
+	t.prune()
+	ps := t.lookup(p)
+	if _, ok := t.peers[p]; !ok {
+		t.peers[p] = ps
+	}
+	ps.updated = t.now()
+	return ps
+}
+
+// settle forgets the state of p when it is back to the one of a peer never
+// seen before. t.mu must be held.
+func (t *Tracker) settle(p peer.ID, ps *peerScore) {
+	if ps.score == InitialScore && ps.bans == 0 && ps.bannedUntil.IsZero() {
+		delete(t.peers, p)
+	}
+}
+
+// prune forgets the peers not banned whose score didn't change for
+// ForgetAfter, at most every pruneInterval. t.mu must be held.
+func (t *Tracker) prune() {
+	now := t.now()
+	if now.Sub(t.lastPrune) < pruneInterval {
+		return
+	}
+	t.lastPrune = now
+	for p, ps := range t.peers {
+		if ps.bannedUntil.IsZero() || !now.Before(ps.bannedUntil) {
+			if now.Sub(ps.updated) >= ForgetAfter {
+				delete(t.peers, p)
+			}
+		}
+	}
+}
+
+// Score returns the current score of p.
+func (t *Tracker) Score(p peer.ID) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lookup(p).score
+}
+
+// Banned returns whether p is banned, and until when. A trusted peer is
+// never banned.
+func (t *Tracker) Banned(p peer.ID) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.trusted[p] {
+		return time.Time{}, false
+	}
+	ps := t.lookup(p)
+	return ps.bannedUntil, !ps.bannedUntil.IsZero()
+}
+
+// Reward adds BlockReward points to the score of p.
+func (t *Tracker) Reward(p peer.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ps := t.get(p)
+	if !ps.bannedUntil.IsZero() {
+		return
+	}
+	ps.score += BlockReward
+	if ps.score > MaxScore {
+		ps.score = MaxScore
+	}
+	t.settle(p, ps)
+}
+
+// Penalize deducts points from the score of p, banning it if the score goes
+// below zero. The trusted peers are not penalized.
+func (t *Tracker) Penalize(p peer.ID, points int) {
+	t.mu.Lock()
+	if t.trusted[p] {
+		t.mu.Unlock()
+		return
+	}
+	ps := t.get(p)
+	if !ps.bannedUntil.IsZero() {
+		t.mu.Unlock()
+		return
+	}
+	ps.score -= points
+	if ps.score >= 0 {
+		t.mu.Unlock()
+		return
+	}
+
+	d := MaxBanDuration
+	if ps.bans < 16 {
+		if d = BaseBanDuration << ps.bans; d > MaxBanDuration {
+			d = MaxBanDuration
+		}
+	}
+	ps.bans++
+	ps.bannedUntil = t.now().Add(d)
+	net := t.net
+	t.mu.Unlock()
+
+	log.Infof("banning peer %s for %s", p.Pretty(), d)
+	if net != nil {
+		if err := net.ClosePeer(p); err != nil {
+			log.Debugf("closing connections to banned peer %s: %s", p.Pretty(), err)
+		}
+	}
+}
+
+// Unban lifts the ban of p, if any, and resets its score and ban history.
+func (t *Tracker) Unban(p peer.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.peers, p)
+}
+
+// Trust marks p as trusted, lifting its ban if any.
+func (t *Tracker) Trust(p peer.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trusted[p] = true
+	delete(t.peers, p)
+	if t.cm != nil {
+		t.cm.TagPeer(p, TrustedTag, TrustedTagValue)
+	}
+}
+
+// Untrust makes p an ordinary peer again, with the initial score.
+func (t *Tracker) Untrust(p peer.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.trusted[p] {
+		return
+	}
+	delete(t.trusted, p)
+	if t.cm != nil {
+		t.cm.UntagPeer(p, TrustedTag)
+	}
+}
+
+// Trusted returns whether p is trusted.
+func (t *Tracker) Trusted(p peer.ID) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trusted[p]
+}
+
+// TrustedPeers returns the trusted peers, sorted by peer ID.
+func (t *Tracker) TrustedPeers() []peer.ID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]peer.ID, 0, len(t.trusted))
+	for p := range t.trusted {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Scores returns the peers with a known state, sorted by peer ID.
+func (t *Tracker) Scores() []PeerScore {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]PeerScore, 0, len(t.peers))
+	for p := range t.peers {
+		ps := t.lookup(p)
+		out = append(out, PeerScore{Peer: p, Score: ps.score, BannedUntil: ps.bannedUntil})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Peer < out[j].Peer })
+	return out
+}
+
+// Gate makes t refuse the connections of banned peers on n, and close the
+// connections of the peers it bans.
+func (t *Tracker) Gate(n inet.Network) {
+	t.mu.Lock()
+	t.net = n
+	t.mu.Unlock()
+	n.Notify((*gate)(t))
+}
+
+// Protect makes t tag the trusted peers in cm, so that their connections are
+// trimmed last.
+func (t *Tracker) Protect(cm ifconnmgr.ConnManager) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cm = cm
+	for p := range t.trusted {
+		cm.TagPeer(p, TrustedTag, TrustedTagValue)
+	}
+}
+
+// gate is the network notifiee closing the connections of banned peers.
+type gate Tracker
+
+func (g *gate) Connected(n inet.Network, c inet.Conn) {
+	t := (*Tracker)(g)
+	if _, banned := t.Banned(c.RemotePeer()); !banned {
+		return
+	}
+	log.Debugf("refusing connection from banned peer %s", c.RemotePeer().Pretty())
+	// don't block the notification
+	go c.Close()
+}
+
+func (g *gate) Listen(inet.Network, ma.Multiaddr)      {}
+func (g *gate) ListenClose(inet.Network, ma.Multiaddr) {}
+func (g *gate) Disconnected(inet.Network, inet.Conn)   {}
+func (g *gate) OpenedStream(inet.Network, inet.Stream) {}
+func (g *gate) ClosedStream(inet.Network, inet.Stream) {}
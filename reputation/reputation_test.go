@@ -0,0 +1,165 @@
+package reputation
+
+import (
+	"testing"
+	"time"
+
+	testutil "github.com/libp2p/go-testutil"
+)
+
+func TestTrackerBans(t *testing.T) {
+	p, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tr := NewTracker()
+	tr.now = func() time.Time { return now }
+
+	tr.Reward(p)
+	if s := tr.Score(p); s != MaxScore {
+		t.Fatalf("expected the score to be capped at %d, got %d", MaxScore, s)
+	}
+
+	tr.Penalize(p, InvalidBlockPenalty)
+	tr.Reward(p)
+	if s := tr.Score(p); s != InitialScore-InvalidBlockPenalty+BlockReward {
+		t.Fatalf("unexpected score %d", s)
+	}
+
+	ban := func() time.Time {
+		for i := 0; i <= InitialScore/InvalidBlockPenalty; i++ {
+			tr.Penalize(p, InvalidBlockPenalty)
+		}
+		until, banned := tr.Banned(p)
+		if !banned {
+			t.Fatal("expected the peer to be banned")
+		}
+		return until
+	}
+
+	if until := ban(); until.Sub(now) != BaseBanDuration {
+		t.Fatalf("expected a first ban of %s, got %s", BaseBanDuration, until.Sub(now))
+	}
+
+	// the ban expires, the next one is twice as long
+	now = now.Add(BaseBanDuration)
+	if _, banned := tr.Banned(p); banned {
+		t.Fatal("expected the ban to expire")
+	}
+	if s := tr.Score(p); s != InitialScore {
+		t.Fatalf("expected the score to be reset after the ban, got %d", s)
+	}
+	if until := ban(); until.Sub(now) != 2*BaseBanDuration {
+		t.Fatalf("expected a second ban of %s, got %s", 2*BaseBanDuration, until.Sub(now))
+	}
+
+	scores := tr.Scores()
+	if len(scores) != 1 || scores[0].Peer != p || scores[0].BannedUntil.IsZero() {
+		t.Fatalf("unexpected scores: %v", scores)
+	}
+
+	tr.Unban(p)
+	if _, banned := tr.Banned(p); banned {
+		t.Fatal("expected the peer to be unbanned")
+	}
+	if until := ban(); until.Sub(now) != BaseBanDuration {
+		t.Fatalf("expected the ban history to be reset, got a ban of %s", until.Sub(now))
+	}
+}
+
+func TestTrackerTrusted(t *testing.T) {
+	p, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := NewTracker()
+	for i := 0; i <= InitialScore/InvalidBlockPenalty; i++ {
+		tr.Penalize(p, InvalidBlockPenalty)
+	}
+	if _, banned := tr.Banned(p); !banned {
+		t.Fatal("expected the peer to be banned")
+	}
+
+	// trusting a peer lifts its ban, and it isn't penalized anymore
+	tr.Trust(p)
+	if _, banned := tr.Banned(p); banned {
+		t.Fatal("expected the trusted peer not to be banned")
+	}
+	for i := 0; i <= InitialScore/InvalidBlockPenalty; i++ {
+		tr.Penalize(p, InvalidBlockPenalty)
+	}
+	if _, banned := tr.Banned(p); banned {
+		t.Fatal("expected the trusted peer not to be banned")
+	}
+	if s := tr.Score(p); s != InitialScore {
+		t.Fatalf("expected the trusted peer to keep the initial score, got %d", s)
+	}
+	if peers := tr.TrustedPeers(); len(peers) != 1 || peers[0] != p {
+		t.Fatalf("unexpected trusted peers: %v", peers)
+	}
+
+	tr.Untrust(p)
+	if tr.Trusted(p) {
+		t.Fatal("expected the peer not to be trusted anymore")
+	}
+	tr.Penalize(p, InvalidBlockPenalty)
+	if s := tr.Score(p); s != InitialScore-InvalidBlockPenalty {
+		t.Fatalf("expected the peer to be scored again, got %d", s)
+	}
+}
+
+func TestTrackerForgets(t *testing.T) {
+	p, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tr := NewTracker()
+	tr.now = func() time.Time { return now }
+
+	// the peers with the initial score take no room
+	tr.Reward(p)
+	if tr.Score(p) != InitialScore || len(tr.Scores()) != 0 {
+		t.Fatalf("expected no stored state, got %v", tr.Scores())
+	}
+
+	tr.Penalize(p, InvalidBlockPenalty)
+	if len(tr.Scores()) != 1 {
+		t.Fatalf("expected the penalized peer to be stored, got %v", tr.Scores())
+	}
+	for i := 0; i < InvalidBlockPenalty; i++ {
+		tr.Reward(p)
+	}
+	if len(tr.Scores()) != 0 {
+		t.Fatalf("expected the peer back to the initial score to be forgotten, got %v", tr.Scores())
+	}
+
+	tr.Penalize(p, InvalidBlockPenalty)
+	now = now.Add(ForgetAfter)
+	tr.Penalize(other, InvalidBlockPenalty)
+	scores := tr.Scores()
+	if len(scores) != 1 || scores[0].Peer != other {
+		t.Fatalf("expected the peer to be forgotten, got %v", scores)
+	}
+
+	// the ban history is kept after the ban expired
+	for i := 0; i <= InitialScore/InvalidBlockPenalty; i++ {
+		tr.Penalize(other, InvalidBlockPenalty)
+	}
+	now = now.Add(BaseBanDuration + pruneInterval)
+	tr.Penalize(p, InvalidBlockPenalty)
+	for i := 0; i <= InitialScore/InvalidBlockPenalty; i++ {
+		tr.Penalize(other, InvalidBlockPenalty)
+	}
+	if until, _ := tr.Banned(other); until.Sub(now) != 2*BaseBanDuration {
+		t.Fatalf("expected a second ban of %s, got %s", 2*BaseBanDuration, until.Sub(now))
+	}
+}
@@ -0,0 +1,263 @@
+// Package delegated implements a router that delegates content, peer and IPNS
+// routing to a remote server speaking the HTTP routing API
+// (/routing/v1/providers, /routing/v1/peers and /routing/v1/ipns).
+//
+// The router never announces content: servers like cid.contact index the
+// content of the providers on their own, so Provide is not supported.
+package delegated
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	record "github.com/libp2p/go-libp2p-record"
+	routing "github.com/libp2p/go-libp2p-routing"
+	ropts "github.com/libp2p/go-libp2p-routing/options"
+	ma "github.com/multiformats/go-multiaddr"
+	mbase "github.com/multiformats/go-multibase"
+)
+
+var log = logging.Logger("routing/delegated")
+
+// DefaultEndpoint is the public delegated routing server used when none is
+// configured.
+const DefaultEndpoint = "https://delegated-ipfs.dev"
+
+// libp2pKeyCodec is the multicodec of the CIDs used for IPNS names.
+const libp2pKeyCodec = 0x72
+
+const (
+	ipnsPrefix        = "/ipns/"
+	ipnsRecordMime    = "application/vnd.ipfs.ipns-record"
+	maxIpnsRecordSize = 10 << 10
+	maxResponseSize   = 10 << 20
+)
+
+// Router is a routing.IpfsRouting querying a delegated routing server.
+type Router struct {
+	endpoint  string
+	client    *http.Client
+	validator record.Validator
+}
+
+var _ routing.IpfsRouting = (*Router)(nil)
+
+// New returns a Router querying the server at endpoint. The IPNS records it
+// fetches are checked with validator.
+func New(endpoint string, validator record.Validator) *Router {
+	return &Router{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		client:    http.DefaultClient,
+		validator: validator,
+	}
+}
+
+// peerRecord is a provider or peer record returned by the server. Records of
+// the legacy "bitswap" schema have the same fields.
+type peerRecord struct {
+	Schema string
+	ID     string
+	Addrs  []string
+}
+
+func (r *Router) do(req *http.Request, accept string) ([]byte, error) {
+	req.Header.Set("Accept", accept)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, routing.ErrNotFound
+	default:
+		return nil, fmt.Errorf("delegated routing: %s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
+
+func (r *Router) getRecords(ctx context.Context, path, field string) ([]pstore.PeerInfo, error) {
+	req, err := http.NewRequest("GET", r.endpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.do(req.WithContext(ctx), "application/json")
+	if err != nil {
+		return nil, err
+	}
+
+	var out map[string][]peerRecord
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("delegated routing: invalid response: %s", err)
+	}
+
+	var pis []pstore.PeerInfo
+	for _, rec := range out[field] {
+		pi, err := rec.peerInfo()
+		if err != nil {
+			log.Debugf("skipping invalid record from %s: %s", r.endpoint, err)
+			continue
+		}
+		pis = append(pis, pi)
+	}
+	return pis, nil
+}
+
+func (rec peerRecord) peerInfo() (pstore.PeerInfo, error) {
+	id, err := peer.IDB58Decode(rec.ID)
+	if err != nil {
+		// the server may use the CID form of the peer ID
+		c, cerr := cid.Decode(rec.ID)
+		if cerr != nil {
+			return pstore.PeerInfo{}, err
+		}
+		if id, err = peer.IDFromBytes(c.Hash()); err != nil {
+			return pstore.PeerInfo{}, err
+		}
+	}
+
+	pi := pstore.PeerInfo{ID: id}
+	for _, s := range rec.Addrs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			continue
+		}
+		pi.Addrs = append(pi.Addrs, a)
+	}
+	return pi, nil
+}
+
+// FindProviders returns the providers of c known to the server. Unlike
+// FindProvidersAsync, it reports the errors of the server.
+func (r *Router) FindProviders(ctx context.Context, c cid.Cid) ([]pstore.PeerInfo, error) {
+	return r.getRecords(ctx, "/routing/v1/providers/"+c.String(), "Providers")
+}
+
+// FindProvidersAsync returns the providers of c known to the server.
+func (r *Router) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan pstore.PeerInfo {
+	out := make(chan pstore.PeerInfo)
+	go func() {
+		defer close(out)
+		pis, err := r.FindProviders(ctx, c)
+		if err != nil {
+			if err != routing.ErrNotFound {
+				log.Warningf("finding providers of %s: %s", c, err)
+			}
+			return
+		}
+		for i, pi := range pis {
+			if count > 0 && i >= count {
+				return
+			}
+			select {
+			case out <- pi:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Provide is not supported, the server indexes content on its own.
+func (r *Router) Provide(context.Context, cid.Cid, bool) error {
+	return routing.ErrNotSupported
+}
+
+// FindPeer returns the addresses of p known to the server.
+func (r *Router) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	pis, err := r.getRecords(ctx, "/routing/v1/peers/"+p.Pretty(), "Peers")
+	if err != nil {
+		return pstore.PeerInfo{}, err
+	}
+	for _, pi := range pis {
+		if pi.ID == p {
+			return pi, nil
+		}
+	}
+	return pstore.PeerInfo{}, routing.ErrNotFound
+}
+
+// ipnsPath returns the API path of an IPNS routing key, or false if the key
+// is not an IPNS one.
+func ipnsPath(key string) (string, bool) {
+	if !strings.HasPrefix(key, ipnsPrefix) {
+		return "", false
+	}
+	id, err := peer.IDFromBytes([]byte(key[len(ipnsPrefix):]))
+	if err != nil {
+		return "", false
+	}
+	name, err := cid.NewCidV1(libp2pKeyCodec, []byte(id)).StringOfBase(mbase.Base32)
+	if err != nil {
+		return "", false
+	}
+	return "/routing/v1/ipns/" + name, true
+}
+
+// PutValue publishes an IPNS record. Other records are not supported.
+func (r *Router) PutValue(ctx context.Context, key string, value []byte, opts ...ropts.Option) error {
+	path, ok := ipnsPath(key)
+	if !ok {
+		return routing.ErrNotSupported
+	}
+	req, err := http.NewRequest("PUT", r.endpoint+path, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ipnsRecordMime)
+	_, err = r.do(req.WithContext(ctx), ipnsRecordMime)
+	return err
+}
+
+// GetValue fetches an IPNS record. Other records are not supported.
+func (r *Router) GetValue(ctx context.Context, key string, opts ...ropts.Option) ([]byte, error) {
+	path, ok := ipnsPath(key)
+	if !ok {
+		return nil, routing.ErrNotSupported
+	}
+	req, err := http.NewRequest("GET", r.endpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.do(req.WithContext(ctx), ipnsRecordMime)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxIpnsRecordSize {
+		return nil, fmt.Errorf("delegated routing: IPNS record of %d bytes is too large", len(data))
+	}
+	if err := r.validator.Validate(key, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// SearchValue returns the single value GetValue finds.
+func (r *Router) SearchValue(ctx context.Context, key string, opts ...ropts.Option) (<-chan []byte, error) {
+	val, err := r.GetValue(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan []byte, 1)
+	out <- val
+	close(out)
+	return out, nil
+}
+
+// Bootstrap does nothing, there is no connection to maintain.
+func (r *Router) Bootstrap(context.Context) error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package delegated
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	routing "github.com/libp2p/go-libp2p-routing"
+	testutil "github.com/libp2p/go-testutil"
+	mbase "github.com/multiformats/go-multibase"
+)
+
+type acceptAll struct{}
+
+func (acceptAll) Validate(key string, value []byte) error         { return nil }
+func (acceptAll) Select(key string, values [][]byte) (int, error) { return 0, nil }
+
+func TestRouter(t *testing.T) {
+	pid, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: 0x12, MhLength: -1}.Sum([]byte("delegated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipnsKey := "/ipns/" + string(pid)
+	name, err := cid.NewCidV1(libp2pKeyCodec, []byte(pid)).StringOfBase(mbase.Base32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := make(map[string][]byte)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := fmt.Sprintf(`{"Schema":"peer","ID":%q,"Addrs":["/ip4/1.2.3.4/tcp/4001"]}`, pid.Pretty())
+		switch {
+		case r.URL.Path == "/routing/v1/providers/"+c.String():
+			fmt.Fprintf(w, `{"Providers":[%s,{"Schema":"peer","ID":"invalid"}]}`, rec)
+		case r.URL.Path == "/routing/v1/peers/"+pid.Pretty():
+			fmt.Fprintf(w, `{"Peers":[%s]}`, rec)
+		case r.URL.Path == "/routing/v1/ipns/"+name && r.Method == "PUT":
+			if r.Header.Get("Content-Type") != ipnsRecordMime {
+				http.Error(w, "bad content type", http.StatusBadRequest)
+				return
+			}
+			records[name], _ = ioutil.ReadAll(r.Body)
+		case r.URL.Path == "/routing/v1/ipns/"+name && records[name] != nil:
+			w.Write(records[name])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	r := New(ts.URL+"/", acceptAll{})
+
+	var provs []string
+	for pi := range r.FindProvidersAsync(ctx, c, 0) {
+		provs = append(provs, pi.ID.Pretty())
+	}
+	if len(provs) != 1 || provs[0] != pid.Pretty() {
+		t.Fatalf("unexpected providers: %v", provs)
+	}
+	if _, err := r.FindProviders(ctx, cid.NewCidV1(cid.Raw, c.Hash()[:2])); err != routing.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	pi, err := r.FindPeer(ctx, pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pi.Addrs) != 1 || pi.Addrs[0].String() != "/ip4/1.2.3.4/tcp/4001" {
+		t.Fatalf("unexpected addresses: %v", pi.Addrs)
+	}
+
+	if _, err := r.GetValue(ctx, ipnsKey); err != routing.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := r.PutValue(ctx, ipnsKey, []byte("record")); err != nil {
+		t.Fatal(err)
+	}
+	val, err := r.GetValue(ctx, ipnsKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "record" {
+		t.Fatalf("unexpected value: %q", val)
+	}
+
+	if _, err := r.GetValue(ctx, "/pk/"+string(pid)); err != routing.ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if err := r.Provide(ctx, c, true); err != routing.ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
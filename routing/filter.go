@@ -0,0 +1,161 @@
+// Package routing holds the routing policies of go-ipfs, the routers
+// themselves living in the subpackages.
+package routing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	merkledag "github.com/ipfs/go-merkledag"
+)
+
+var log = logging.Logger("routing")
+
+// AnnounceFilterConfigKey is the config section of the announce filter.
+const AnnounceFilterConfigKey = "Routing.AnnounceFilter"
+
+// PinTimes gives the time a CID was pinned recursively, if known.
+type PinTimes interface {
+	PinTime(cid.Cid) (time.Time, bool)
+}
+
+// AnnounceFilter limits the CIDs the reprovider announces, for nodes with
+// more pins than the DHT can take announcements for.
+type AnnounceFilter struct {
+	// OnlyRoots announces the roots of the recursive pins only.
+	OnlyRoots bool
+
+	// MinPinAge skips the CIDs of the recursive pins created less than
+	// MinPinAge ago, unless an older pin holds them too. Pins of unknown
+	// age are considered old enough.
+	MinPinAge time.Duration
+
+	// MaxAnnounce caps the number of CIDs announced by a reprovide run,
+	// zero meaning no limit.
+	MaxAnnounce int
+
+	Pinner   pin.Pinner
+	PinTimes PinTimes
+	// DAG gets the nodes of the pinned DAGs to find the CIDs of the young
+	// pins. It should not fetch missing blocks from the network.
+	DAG ipld.NodeGetter
+}
+
+// announceFilterConfig is the config representation of an AnnounceFilter.
+type announceFilterConfig struct {
+	OnlyRoots   bool
+	MinPinAge   string
+	MaxAnnounce int
+}
+
+// LoadAnnounceFilter reads the announce filter from the config of r. It
+// returns nil if no filter is configured.
+func LoadAnnounceFilter(r repo.Repo) (*AnnounceFilter, error) {
+	var cfg announceFilterConfig
+	if ok, err := repo.ReadConfigKey(r, AnnounceFilterConfigKey, &cfg); err != nil || !ok {
+		return nil, err
+	}
+
+	f := &AnnounceFilter{OnlyRoots: cfg.OnlyRoots, MaxAnnounce: cfg.MaxAnnounce}
+	if cfg.MinPinAge != "" {
+		var err error
+		if f.MinPinAge, err = time.ParseDuration(cfg.MinPinAge); err != nil {
+			return nil, fmt.Errorf("invalid value for %s.MinPinAge: %s", AnnounceFilterConfigKey, err)
+		}
+	}
+	if f.MinPinAge < 0 || f.MaxAnnounce < 0 {
+		return nil, fmt.Errorf("invalid value for %s: negative limits", AnnounceFilterConfigKey)
+	}
+	if !f.OnlyRoots && f.MinPinAge == 0 && f.MaxAnnounce == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// NewRun returns the function deciding whether each CID is announced during
+// one reprovide run. It is not safe for concurrent use.
+func (f *AnnounceFilter) NewRun() func(cid.Cid) bool {
+	var roots, young *cid.Set
+	if f.OnlyRoots || f.MinPinAge > 0 {
+		roots = cid.NewSet()
+		var youngRoots, oldRoots []cid.Cid
+		now := time.Now()
+		for _, c := range f.Pinner.RecursiveKeys() {
+			roots.Add(c)
+			if f.MinPinAge == 0 || f.PinTimes == nil {
+				continue
+			}
+			if t, ok := f.PinTimes.PinTime(c); ok && now.Sub(t) < f.MinPinAge {
+				youngRoots = append(youngRoots, c)
+			} else {
+				oldRoots = append(oldRoots, c)
+			}
+		}
+		if len(youngRoots) > 0 {
+			young = f.youngCids(youngRoots, oldRoots)
+		}
+	}
+
+	announced := 0
+	return func(c cid.Cid) bool {
+		if f.MaxAnnounce > 0 && announced >= f.MaxAnnounce {
+			return false
+		}
+		if f.OnlyRoots && !roots.Has(c) {
+			return false
+		}
+		if young != nil && young.Has(c) {
+			return false
+		}
+		announced++
+		return true
+	}
+}
+
+// youngCids returns the CIDs of the DAGs of the young roots which are not
+// held by the old roots or the direct pins.
+func (f *AnnounceFilter) youngCids(youngRoots, oldRoots []cid.Cid) *cid.Set {
+	ctx := context.Background()
+	getLinks := merkledag.GetLinksWithDAG(f.DAG)
+
+	// walk the DAGs of the young roots as far as possible, the blocks
+	// missing from the repo can't be announced anyway
+	young := cid.NewSet()
+	for _, c := range youngRoots {
+		if !young.Visit(c) {
+			continue
+		}
+		if err := merkledag.EnumerateChildren(ctx, getLinks, c, young.Visit); err != nil {
+			log.Debugf("announce filter: walking the young pin %s: %s", c, err)
+		}
+	}
+
+	held := cid.NewSet()
+	for _, c := range oldRoots {
+		if !held.Visit(c) {
+			continue
+		}
+		if err := merkledag.EnumerateChildren(ctx, getLinks, c, held.Visit); err != nil {
+			log.Debugf("announce filter: walking the pin %s: %s", c, err)
+		}
+	}
+	for _, c := range f.Pinner.DirectKeys() {
+		held.Add(c)
+	}
+
+	out := cid.NewSet()
+	young.ForEach(func(c cid.Cid) error {
+		if !held.Has(c) {
+			out.Add(c)
+		}
+		return nil
+	})
+	return out
+}
@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	merkledag "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+)
+
+// fakePinner only implements the methods the filter uses.
+type fakePinner struct {
+	pin.Pinner
+	roots  []cid.Cid
+	direct []cid.Cid
+}
+
+func (p *fakePinner) RecursiveKeys() []cid.Cid {
+	return p.roots
+}
+
+func (p *fakePinner) DirectKeys() []cid.Cid {
+	return p.direct
+}
+
+type fakePinTimes map[cid.Cid]time.Time
+
+func (pt fakePinTimes) PinTime(c cid.Cid) (time.Time, bool) {
+	t, ok := pt[c]
+	return t, ok
+}
+
+func TestAnnounceFilter(t *testing.T) {
+	dag := mdtest.Mock()
+	node := func(data string, links ...ipld.Node) ipld.Node {
+		nd := merkledag.NodeWithData([]byte(data))
+		for _, l := range links {
+			if err := nd.AddNodeLink(data, l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := dag.Add(context.Background(), nd); err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+	// the young pin shares a leaf with the old one
+	leafYoung, leafShared, leafOld := node("young"), node("shared"), node("old")
+	rootOld := node("root old", leafShared, leafOld)
+	rootYoung := node("root young", leafYoung, leafShared)
+	rootUnknown := node("root unknown")
+	cids := []cid.Cid{rootOld.Cid(), rootYoung.Cid(), rootUnknown.Cid(), leafYoung.Cid(), leafShared.Cid(), leafOld.Cid()}
+
+	// the first three are roots, the second one pinned a minute ago
+	pinner := &fakePinner{roots: cids[:3]}
+	times := fakePinTimes{
+		rootOld.Cid():   time.Now().Add(-time.Hour),
+		rootYoung.Cid(): time.Now().Add(-time.Minute),
+	}
+
+	check := func(f *AnnounceFilter, expected ...bool) {
+		t.Helper()
+		f.Pinner = pinner
+		f.PinTimes = times
+		f.DAG = dag
+		announce := f.NewRun()
+		for i, c := range cids {
+			if announce(c) != expected[i] {
+				t.Fatalf("%+v: expected %v for CID %d", f, expected[i], i)
+			}
+		}
+	}
+
+	check(&AnnounceFilter{OnlyRoots: true}, true, true, true, false, false, false)
+	check(&AnnounceFilter{MinPinAge: 10 * time.Minute}, true, false, true, false, true, true)
+	check(&AnnounceFilter{OnlyRoots: true, MinPinAge: 10 * time.Minute}, true, false, true, false, false, false)
+	check(&AnnounceFilter{MaxAnnounce: 2}, true, true, false, false, false, false)
+	check(&AnnounceFilter{OnlyRoots: true, MaxAnnounce: 1}, true, false, false, false, false, false)
+
+	// a direct pin holds the CID too
+	pinner.direct = []cid.Cid{leafYoung.Cid()}
+	check(&AnnounceFilter{MinPinAge: 10 * time.Minute}, true, false, true, true, true, true)
+}
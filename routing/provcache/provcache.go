@@ -0,0 +1,244 @@
+// Package provcache keeps the provider records a node announced in its
+// datastore, so that they can be announced again as soon as the node
+// restarts, instead of waiting for the first reprovide run.
+//
+// Records are stored under /local/provcache/<cid>/providers/<peer-id>, with
+// the time of the last announcement as value. They expire after a TTL, and
+// the cache is bounded: the records announced the longest time ago are
+// evicted first.
+package provcache
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
+	routing "github.com/libp2p/go-libp2p-routing"
+)
+
+var log = logging.Logger("provcache")
+
+const (
+	// DefaultSize is the default maximum number of records in the cache.
+	DefaultSize = 100000
+
+	// DefaultTTL is the default time a record stays in the cache after its
+	// last announcement. It matches the validity of DHT provider records.
+	DefaultTTL = 24 * time.Hour
+)
+
+var cachePrefix = ds.NewKey("/local/provcache")
+
+type record struct {
+	c        cid.Cid
+	provided time.Time
+}
+
+// Cache is a size bounded, persistent cache of the provider records of a
+// node.
+type Cache struct {
+	dstore ds.Datastore
+	self   peer.ID
+	size   int
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List // of *record, most recently provided first
+	records map[string]*list.Element
+}
+
+// New loads the records of self from dstore and returns the cache. Expired
+// records, and the ones over size, are removed.
+func New(dstore ds.Datastore, self peer.ID, size int, ttl time.Duration) (*Cache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid provider cache size %d", size)
+	}
+
+	c := &Cache{
+		dstore:  dstore,
+		self:    self,
+		size:    size,
+		ttl:     ttl,
+		lru:     list.New(),
+		records: make(map[string]*list.Element),
+	}
+
+	res, err := dstore.Query(dsq.Query{Prefix: cachePrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded []*record
+	now := time.Now()
+	for _, e := range entries {
+		k, p, err := parseKey(e.Key)
+		if err != nil {
+			log.Warningf("removing invalid provider cache entry %s: %s", e.Key, err)
+			c.remove(ds.NewKey(e.Key))
+			continue
+		}
+		if p != self {
+			// left by an earlier identity of the node
+			c.remove(ds.NewKey(e.Key))
+			continue
+		}
+		if len(e.Value) != 8 {
+			c.remove(ds.NewKey(e.Key))
+			continue
+		}
+		provided := time.Unix(0, int64(binary.BigEndian.Uint64(e.Value)))
+		if now.Sub(provided) >= ttl {
+			c.remove(ds.NewKey(e.Key))
+			continue
+		}
+		loaded = append(loaded, &record{c: k, provided: provided})
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].provided.After(loaded[j].provided) })
+	for _, r := range loaded {
+		c.records[r.c.KeyString()] = c.lru.PushBack(r)
+	}
+	c.evict()
+	return c, nil
+}
+
+func (c *Cache) key(k cid.Cid) ds.Key {
+	return cachePrefix.ChildString(k.String()).ChildString("providers").ChildString(c.self.Pretty())
+}
+
+func parseKey(key string) (cid.Cid, peer.ID, error) {
+	parts := strings.Split(strings.TrimPrefix(key, cachePrefix.String()+"/"), "/")
+	if len(parts) != 3 || parts[1] != "providers" {
+		return cid.Cid{}, "", fmt.Errorf("unexpected key")
+	}
+	k, err := cid.Decode(parts[0])
+	if err != nil {
+		return cid.Cid{}, "", err
+	}
+	p, err := peer.IDB58Decode(parts[2])
+	if err != nil {
+		return cid.Cid{}, "", err
+	}
+	return k, p, nil
+}
+
+func (c *Cache) remove(k ds.Key) {
+	if err := c.dstore.Delete(k); err != nil {
+		log.Warningf("removing provider cache entry %s: %s", k, err)
+	}
+}
+
+// evict removes the records over the size of the cache. c.mu must be held,
+// or c not shared yet.
+func (c *Cache) evict() {
+	for c.lru.Len() > c.size {
+		r := c.lru.Remove(c.lru.Back()).(*record)
+		delete(c.records, r.c.KeyString())
+		c.remove(c.key(r.c))
+	}
+}
+
+// Add records that k was just announced.
+func (c *Cache) Add(k cid.Cid) error {
+	now := time.Now()
+	var val [8]byte
+	binary.BigEndian.PutUint64(val[:], uint64(now.UnixNano()))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.dstore.Put(c.key(k), val[:]); err != nil {
+		return err
+	}
+	if e, ok := c.records[k.KeyString()]; ok {
+		e.Value.(*record).provided = now
+		c.lru.MoveToFront(e)
+		return nil
+	}
+	c.records[k.KeyString()] = c.lru.PushFront(&record{c: k, provided: now})
+	c.evict()
+	return nil
+}
+
+// Len returns the number of records in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Keys returns the CIDs of the records that have not expired, the most
+// recently announced first.
+func (c *Cache) Keys() []cid.Cid {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	keys := make([]cid.Cid, 0, c.lru.Len())
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		r := e.Value.(*record)
+		if now.Sub(r.provided) >= c.ttl {
+			// the following ones are older
+			break
+		}
+		keys = append(keys, r.c)
+	}
+	return keys
+}
+
+// Republish announces the cached records through r again, and returns how
+// many were announced.
+func (c *Cache) Republish(ctx context.Context, r routing.ContentRouting) (int, error) {
+	n := 0
+	for _, k := range c.Keys() {
+		if err := r.Provide(ctx, k, true); err != nil {
+			if ctx.Err() != nil {
+				return n, ctx.Err()
+			}
+			log.Debugf("republishing provider record of %s: %s", k, err)
+			continue
+		}
+		n++
+		if err := c.Add(k); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Wrap returns a content router that records in c the announcements made
+// successfully through r.
+func (c *Cache) Wrap(r routing.ContentRouting) routing.ContentRouting {
+	return &cachingRouter{ContentRouting: r, cache: c}
+}
+
+type cachingRouter struct {
+	routing.ContentRouting
+	cache *Cache
+}
+
+func (cr *cachingRouter) Provide(ctx context.Context, k cid.Cid, announce bool) error {
+	if err := cr.ContentRouting.Provide(ctx, k, announce); err != nil {
+		return err
+	}
+	if announce {
+		if err := cr.cache.Add(k); err != nil {
+			log.Warningf("caching provider record of %s: %s", k, err)
+		}
+	}
+	return nil
+}
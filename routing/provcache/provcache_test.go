@@ -0,0 +1,142 @@
+package provcache
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	pstore "github.com/libp2p/go-libp2p-peerstore"
+	testutil "github.com/libp2p/go-testutil"
+)
+
+type countingRouter struct {
+	provided []cid.Cid
+}
+
+func (r *countingRouter) Provide(ctx context.Context, k cid.Cid, announce bool) error {
+	r.provided = append(r.provided, k)
+	return nil
+}
+
+func (r *countingRouter) FindProvidersAsync(context.Context, cid.Cid, int) <-chan pstore.PeerInfo {
+	out := make(chan pstore.PeerInfo)
+	close(out)
+	return out
+}
+
+func testCids(n int) []cid.Cid {
+	var cids []cid.Cid
+	for i := 0; i < n; i++ {
+		cids = append(cids, blocks.NewBlock([]byte(fmt.Sprint(i))).Cid())
+	}
+	return cids
+}
+
+func TestCachePersists(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	self, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(dstore, self, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &countingRouter{}
+	wrapped := c.Wrap(r)
+	cids := testCids(4)
+	for _, k := range cids {
+		if err := wrapped.Provide(context.Background(), k, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// not announced
+	if err := wrapped.Provide(context.Background(), blocks.NewBlock([]byte("local")).Cid(), false); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 3 {
+		t.Fatalf("expected the cache to hold 3 records, got %d", c.Len())
+	}
+
+	// reload, the first record was evicted
+	c, err = New(dstore, self, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := c.Keys()
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 records after reloading, got %d", len(keys))
+	}
+	for i, k := range keys {
+		if !k.Equals(cids[3-i]) {
+			t.Fatalf("record %d: expected %s, got %s", i, cids[3-i], k)
+		}
+	}
+
+	r = &countingRouter{}
+	n, err := c.Republish(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(r.provided) != 3 {
+		t.Fatalf("expected 3 records to be republished, got %d", n)
+	}
+
+	// records of another identity are dropped
+	other, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = New(dstore, other, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("expected no records for another peer, got %d", c.Len())
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	self, err := testutil.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(dstore, self, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cids := testCids(2)
+	for _, k := range cids {
+		if err := c.Add(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// make the first record older than the TTL
+	var val [8]byte
+	binary.BigEndian.PutUint64(val[:], uint64(time.Now().Add(-2*time.Hour).UnixNano()))
+	if err := dstore.Put(c.key(cids[0]), val[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = New(dstore, self, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := c.Keys()
+	if len(keys) != 1 || !keys[0].Equals(cids[1]) {
+		t.Fatalf("expected only the fresh record, got %v", keys)
+	}
+	if has, _ := dstore.Has(c.key(cids[0])); has {
+		t.Fatal("expired record not removed from the datastore")
+	}
+}
@@ -0,0 +1,290 @@
+// Package search indexes the names of the files of the pinned directories,
+// to find them by the words of their names.
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	dagutils "github.com/ipfs/go-ipfs/dagutils"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	namespace "github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	uio "github.com/ipfs/go-unixfs/io"
+)
+
+var log = logging.Logger("search")
+
+// The index is stored under /search: /search/built is set once the pins have
+// been indexed, /search/roots/<cid> lists the terms of an indexed pin, and
+// /search/terms/<term> the entries named with a term.
+var (
+	prefixKey = ds.NewKey("/search")
+	builtKey  = ds.NewKey("/built")
+	rootsKey  = ds.NewKey("/roots")
+	termsKey  = ds.NewKey("/terms")
+)
+
+// mu serializes the updates of the index.
+var mu sync.Mutex
+
+// Result is an entry of a pinned directory matching a query. Score is the
+// number of words of the query in its name.
+type Result struct {
+	Cid   cid.Cid
+	Path  string
+	Score int
+}
+
+// entry is an entry of a pinned directory, at Path in the pin Root.
+type entry struct {
+	Root cid.Cid
+	Cid  cid.Cid
+	Path string
+}
+
+// Index is the index of the names of the entries of the pinned directories.
+type Index struct {
+	ds  ds.Datastore
+	dag ipld.DAGService
+}
+
+// NewIndex returns the index stored in dstore, of the directories read from
+// dag.
+func NewIndex(dstore ds.Datastore, dag ipld.DAGService) *Index {
+	return &Index{
+		ds:  namespace.Wrap(dstore, prefixKey),
+		dag: dag,
+	}
+}
+
+// Tokenize returns the lowercase words of s, split at spaces and
+// punctuation.
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Built returns whether the index has been built.
+func (ix *Index) Built() (bool, error) {
+	return ix.ds.Has(builtKey)
+}
+
+// Update indexes the recursive pins of pinner not indexed yet, and removes
+// the ones unpinned since the last update. It builds the index the first
+// time.
+func (ix *Index) Update(ctx context.Context, pinner pin.Pinner) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	pinned := cid.NewSet()
+	for _, c := range pinner.RecursiveKeys() {
+		pinned.Add(c)
+	}
+
+	res, err := ix.ds.Query(dsq.Query{Prefix: rootsKey.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+	indexed := cid.NewSet()
+	for _, e := range entries {
+		c, err := cid.Decode(ds.RawKey(e.Key).BaseNamespace())
+		if err != nil {
+			continue
+		}
+		if pinned.Has(c) {
+			indexed.Add(c)
+			continue
+		}
+		if err := ix.removeRoot(c); err != nil {
+			return err
+		}
+	}
+
+	err = pinned.ForEach(func(c cid.Cid) error {
+		if indexed.Has(c) {
+			return nil
+		}
+		return ix.addRoot(ctx, c)
+	})
+	if err != nil {
+		return err
+	}
+	return ix.ds.Put(builtKey, []byte{})
+}
+
+// Search returns the entries named with words of query, the best matches
+// first. The index is updated first, and built if needed.
+func (ix *Index) Search(ctx context.Context, pinner pin.Pinner, query string) ([]Result, error) {
+	if err := ix.Update(ctx, pinner); err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	results := make(map[string]*Result)
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		entries, err := ix.termEntries(term)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			r, ok := results[e.Path]
+			if !ok {
+				r = &Result{Cid: e.Cid, Path: e.Path}
+				results[e.Path] = r
+			}
+			r.Score++
+		}
+	}
+
+	out := make([]Result, 0, len(results))
+	for _, r := range results {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out, nil
+}
+
+// addRoot indexes the entries of the directory root, if it is one.
+func (ix *Index) addRoot(ctx context.Context, root cid.Cid) error {
+	nd, err := ix.dag.Get(ctx, root)
+	if err != nil {
+		return err
+	}
+
+	terms := make(map[string][]entry)
+	if err := ix.walk(ctx, root, nd, "/ipfs/"+root.String(), terms); err != nil {
+		// an incomplete pin is indexed at the next update
+		log.Warningf("indexing %s: %s", root, err)
+		return nil
+	}
+
+	names := make([]string, 0, len(terms))
+	for term, entries := range terms {
+		names = append(names, term)
+		old, err := ix.termEntries(term)
+		if err != nil {
+			return err
+		}
+		if err := ix.putTermEntries(term, append(old, entries...)); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	return ix.ds.Put(rootsKey.ChildString(root.String()), data)
+}
+
+// walk adds the entries of the directory nd at p to terms, recursively.
+func (ix *Index) walk(ctx context.Context, root cid.Cid, nd ipld.Node, p string, terms map[string][]entry) error {
+	if !dagutils.IsDirectory(nd) {
+		return nil
+	}
+	dir, err := uio.NewDirectoryFromNode(ix.dag, nd)
+	if err != nil {
+		return err
+	}
+	return dir.ForEachLink(ctx, func(l *ipld.Link) error {
+		lp := path.Join(p, l.Name)
+		seen := make(map[string]bool)
+		for _, term := range Tokenize(l.Name) {
+			if !seen[term] {
+				seen[term] = true
+				terms[term] = append(terms[term], entry{Root: root, Cid: l.Cid, Path: lp})
+			}
+		}
+
+		child, err := l.GetNode(ctx, ix.dag)
+		if err != nil {
+			return err
+		}
+		return ix.walk(ctx, root, child, lp, terms)
+	})
+}
+
+// removeRoot removes the entries of the pin root from the index.
+func (ix *Index) removeRoot(root cid.Cid) error {
+	key := rootsKey.ChildString(root.String())
+	data, err := ix.ds.Get(key)
+	if err != nil {
+		return err
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+
+	for _, term := range names {
+		entries, err := ix.termEntries(term)
+		if err != nil {
+			return err
+		}
+		kept := entries[:0]
+		for _, e := range entries {
+			if !e.Root.Equals(root) {
+				kept = append(kept, e)
+			}
+		}
+		if err := ix.putTermEntries(term, kept); err != nil {
+			return err
+		}
+	}
+	return ix.ds.Delete(key)
+}
+
+func (ix *Index) termEntries(term string) ([]entry, error) {
+	data, err := ix.ds.Get(termsKey.ChildString(term))
+	if err == ds.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (ix *Index) putTermEntries(term string, entries []entry) error {
+	key := termsKey.ChildString(term)
+	if len(entries) == 0 {
+		return ix.ds.Delete(key)
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return ix.ds.Put(key, data)
+}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	mdag "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+	ft "github.com/ipfs/go-unixfs"
+	uio "github.com/ipfs/go-unixfs/io"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("My_Holiday-Photos 2019.JPG")
+	want := []string{"my", "holiday", "photos", "2019", "jpg"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+// makeDir adds a directory with the given entries, files or directories.
+func makeDir(t *testing.T, dserv ipld.DAGService, entries map[string]ipld.Node) ipld.Node {
+	ctx := context.Background()
+	dir := uio.NewDirectory(dserv)
+	for name, nd := range entries {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+		if err := dir.AddChild(ctx, name, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nd, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dserv.Add(ctx, nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func makeFile(data string) ipld.Node {
+	return mdag.NodeWithData(ft.FilePBData([]byte(data), uint64(len(data))))
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	dserv := mdtest.Mock()
+	pinner := pin.NewPinner(dstore, dserv, dserv)
+
+	photos := makeDir(t, dserv, map[string]ipld.Node{
+		"holiday-beach.jpg": makeFile("beach"),
+		"holiday.txt":       makeFile("notes"),
+	})
+	root := makeDir(t, dserv, map[string]ipld.Node{
+		"photos":     photos,
+		"report.pdf": makeFile("report"),
+	})
+	if err := pinner.Pin(ctx, root, true); err != nil {
+		t.Fatal(err)
+	}
+
+	ix := NewIndex(dstore, dserv)
+	if built, err := ix.Built(); err != nil || built {
+		t.Fatalf("the index is built before the first search: %v, %v", built, err)
+	}
+
+	results, err := ix.Search(ctx, pinner, "Holiday beach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "/ipfs/" + root.Cid().String()
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v", results)
+	}
+	if results[0].Path != prefix+"/photos/holiday-beach.jpg" || results[0].Score != 2 {
+		t.Fatalf("unexpected best result %v", results[0])
+	}
+	if results[1].Path != prefix+"/photos/holiday.txt" || results[1].Score != 1 {
+		t.Fatalf("unexpected second result %v", results[1])
+	}
+	if built, err := ix.Built(); err != nil || !built {
+		t.Fatalf("the index isn't built after a search: %v, %v", built, err)
+	}
+
+	// the unpinned content is removed from the index
+	if err := pinner.Unpin(ctx, root.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	results, err = ix.Search(ctx, pinner, "holiday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no results after unpinning, got %v", results)
+	}
+}
@@ -0,0 +1,316 @@
+package securechannel
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// noiseProtocolName is the name of the Noise protocol of the handshake, 32
+// bytes long: it is the initial handshake hash as is.
+const noiseProtocolName = "Noise_XX_25519_ChaChaPoly_SHA256"
+
+var errBadDH = errors.New("invalid diffie-hellman public key")
+
+// keypair is a curve25519 keypair.
+type keypair struct {
+	private [32]byte
+	public  [32]byte
+}
+
+func newKeypair() (*keypair, error) {
+	kp := new(keypair)
+	if _, err := rand.Read(kp.private[:]); err != nil {
+		return nil, err
+	}
+	curve25519.ScalarBaseMult(&kp.public, &kp.private)
+	return kp, nil
+}
+
+// dh is the X25519 function. The low order public keys, which give an all
+// zero shared secret, are rejected.
+func dh(kp *keypair, public []byte) ([]byte, error) {
+	var pub, out [32]byte
+	if len(public) != len(pub) {
+		return nil, errBadDH
+	}
+	copy(pub[:], public)
+	curve25519.ScalarMult(&out, &kp.private, &pub)
+	var zero [32]byte
+	if subtle.ConstantTimeCompare(out[:], zero[:]) == 1 {
+		return nil, errBadDH
+	}
+	return out[:], nil
+}
+
+// cipherState is the CipherState of the Noise specification: a key and the
+// nonce of the next message.
+type cipherState struct {
+	aead  cipher.AEAD
+	nonce uint64
+}
+
+func newCipherState(key []byte) *cipherState {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		// the keys are the 32 bytes outputs of hkdf
+		panic(err)
+	}
+	return &cipherState{aead: aead}
+}
+
+func (cs *cipherState) nonceBytes() []byte {
+	var n [chacha20poly1305.NonceSize]byte
+	binary.LittleEndian.PutUint64(n[4:], cs.nonce)
+	return n[:]
+}
+
+func (cs *cipherState) encrypt(ad, plaintext []byte) []byte {
+	out := cs.aead.Seal(nil, cs.nonceBytes(), plaintext, ad)
+	cs.nonce++
+	return out
+}
+
+func (cs *cipherState) decrypt(ad, ciphertext []byte) ([]byte, error) {
+	out, err := cs.aead.Open(nil, cs.nonceBytes(), ciphertext, ad)
+	if err != nil {
+		return nil, err
+	}
+	cs.nonce++
+	return out, nil
+}
+
+// symmetricState is the SymmetricState of the Noise specification: the
+// chaining key and the hash of the handshake, and the cipher once a key is
+// mixed in.
+type symmetricState struct {
+	ck []byte
+	h  []byte
+	cs *cipherState
+}
+
+func newSymmetricState() *symmetricState {
+	h := []byte(noiseProtocolName)
+	ss := &symmetricState{ck: h, h: h}
+	// empty prologue
+	ss.mixHash(nil)
+	return ss
+}
+
+func (ss *symmetricState) mixHash(data []byte) {
+	sum := sha256.New()
+	sum.Write(ss.h)
+	sum.Write(data)
+	ss.h = sum.Sum(nil)
+}
+
+func (ss *symmetricState) mixKey(ikm []byte) {
+	var k []byte
+	ss.ck, k = hkdf(ss.ck, ikm)
+	ss.cs = newCipherState(k)
+}
+
+func (ss *symmetricState) encryptAndHash(plaintext []byte) []byte {
+	out := plaintext
+	if ss.cs != nil {
+		out = ss.cs.encrypt(ss.h, plaintext)
+	}
+	ss.mixHash(out)
+	return out
+}
+
+func (ss *symmetricState) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	out := ciphertext
+	if ss.cs != nil {
+		var err error
+		if out, err = ss.cs.decrypt(ss.h, ciphertext); err != nil {
+			return nil, err
+		}
+	}
+	ss.mixHash(ciphertext)
+	return out, nil
+}
+
+// split returns the ciphers of the messages sent by the initiator and of the
+// ones sent by the responder.
+func (ss *symmetricState) split() (*cipherState, *cipherState) {
+	k1, k2 := hkdf(ss.ck, nil)
+	return newCipherState(k1), newCipherState(k2)
+}
+
+// token is a token of a Noise handshake pattern.
+type token int
+
+const (
+	tokenE token = iota
+	tokenS
+	tokenEE
+	tokenES
+	tokenSE
+)
+
+// patternXX is the XX handshake pattern:
+//
+//	-> e
+//	<- e, ee, s, es
+//	-> s, se
+var patternXX = [][]token{
+	{tokenE},
+	{tokenE, tokenEE, tokenS, tokenES},
+	{tokenS, tokenSE},
+}
+
+// handshakeState is the HandshakeState of the Noise specification, running
+// patternXX. Its methods are the ones of the HandshakeState of
+// github.com/flynn/noise, which isn't a dependency of go-ipfs yet: it can
+// replace it as is.
+type handshakeState struct {
+	ss        *symmetricState
+	s, e      *keypair
+	rs, re    []byte
+	initiator bool
+	// msg is the index of the next message in the pattern
+	msg int
+}
+
+func newHandshakeState(initiator bool, static *keypair) *handshakeState {
+	return &handshakeState{
+		ss:        newSymmetricState(),
+		s:         static,
+		initiator: initiator,
+	}
+}
+
+// PeerStatic returns the static key of the peer, once received.
+func (hs *handshakeState) PeerStatic() []byte {
+	return hs.rs
+}
+
+// WriteMessage appends the next handshake message, carrying payload, to
+// out. After the last message of the handshake, it returns the ciphers of
+// the messages sent by the initiator and of the ones sent by the responder.
+func (hs *handshakeState) WriteMessage(out, payload []byte) ([]byte, *cipherState, *cipherState, error) {
+	if hs.msg >= len(patternXX) || (hs.msg%2 == 0) != hs.initiator {
+		return nil, nil, nil, errors.New("noise: unexpected handshake message to write")
+	}
+	for _, t := range patternXX[hs.msg] {
+		switch t {
+		case tokenE:
+			e, err := newKeypair()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			hs.e = e
+			out = append(out, e.public[:]...)
+			hs.ss.mixHash(e.public[:])
+		case tokenS:
+			out = append(out, hs.ss.encryptAndHash(hs.s.public[:])...)
+		default:
+			if err := hs.mixDH(t); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	out = append(out, hs.ss.encryptAndHash(payload)...)
+	cs1, cs2 := hs.next()
+	return out, cs1, cs2, nil
+}
+
+// ReadMessage appends the payload of the handshake message msg to out.
+// After the last message of the handshake, it returns the ciphers as
+// WriteMessage.
+func (hs *handshakeState) ReadMessage(out, msg []byte) ([]byte, *cipherState, *cipherState, error) {
+	if hs.msg >= len(patternXX) || (hs.msg%2 == 0) == hs.initiator {
+		return nil, nil, nil, errors.New("noise: unexpected handshake message to read")
+	}
+	errShort := errors.New("noise: handshake message too short")
+	for _, t := range patternXX[hs.msg] {
+		switch t {
+		case tokenE:
+			if len(msg) < dhLen {
+				return nil, nil, nil, errShort
+			}
+			hs.re = append([]byte{}, msg[:dhLen]...)
+			msg = msg[dhLen:]
+			hs.ss.mixHash(hs.re)
+		case tokenS:
+			l := dhLen
+			if hs.ss.cs != nil {
+				l += tagLen
+			}
+			if len(msg) < l {
+				return nil, nil, nil, errShort
+			}
+			rs, err := hs.ss.decryptAndHash(msg[:l])
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			hs.rs = rs
+			msg = msg[l:]
+		default:
+			if err := hs.mixDH(t); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	payload, err := hs.ss.decryptAndHash(msg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	out = append(out, payload...)
+	cs1, cs2 := hs.next()
+	return out, cs1, cs2, nil
+}
+
+// mixDH mixes the key of the DH token t into the handshake.
+func (hs *handshakeState) mixDH(t token) error {
+	// es is the DH of the ephemeral key of the initiator and of the static
+	// key of the responder, se the converse
+	local, remote := hs.e, hs.re
+	switch {
+	case t == tokenES && hs.initiator, t == tokenSE && !hs.initiator:
+		remote = hs.rs
+	case t == tokenES, t == tokenSE:
+		local = hs.s
+	}
+	if local == nil || remote == nil {
+		return errors.New("noise: missing handshake key")
+	}
+	secret, err := dh(local, remote)
+	if err != nil {
+		return err
+	}
+	hs.ss.mixKey(secret)
+	return nil
+}
+
+// next moves to the next message of the handshake. It returns the ciphers
+// once the handshake is complete.
+func (hs *handshakeState) next() (*cipherState, *cipherState) {
+	hs.msg++
+	if hs.msg < len(patternXX) {
+		return nil, nil
+	}
+	return hs.ss.split()
+}
+
+// hkdf is the HKDF function of the Noise specification, with two outputs.
+func hkdf(ck, ikm []byte) ([]byte, []byte) {
+	tmp := hmacSHA256(ck, ikm)
+	out1 := hmacSHA256(tmp, []byte{1})
+	out2 := hmacSHA256(tmp, append(append([]byte{}, out1...), 2))
+	return out1, out2
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,366 @@
+// Package securechannel opens end-to-end encrypted channels to peers, over a
+// dedicated libp2p protocol.
+//
+// The streams of libp2p are already encrypted between two connected peers,
+// but a channel relayed by other nodes, through a circuit relay for
+// instance, is only encrypted hop by hop. A secure channel runs a Noise XX
+// handshake (Noise_XX_25519_ChaChaPoly_SHA256) over the stream, between the
+// two ends only. The Noise static keys are generated for each channel, and
+// bound to the identities of the peers: the payload of the handshake
+// messages carrying them is the public key of the peer and its signature of
+// the static key.
+//
+// Every handshake message and every message of the channel is prefixed with
+// its length as a 2 bytes big endian integer.
+package securechannel
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	ci "github.com/libp2p/go-libp2p-crypto"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+)
+
+var log = logging.Logger("securechannel")
+
+// ProtocolID is the libp2p protocol of the secure channels.
+const ProtocolID protocol.ID = "/ipfs/secure-channel/1.0.0"
+
+// HandshakeTimeout is how long the handshake of a channel may take.
+const HandshakeTimeout = 30 * time.Second
+
+// signaturePrefix is prepended to the static key signed by the peers.
+const signaturePrefix = "ipfs-secure-channel-static-key:"
+
+const (
+	maxMessageSize = 65535
+	// maxPlaintextSize is the size of the largest chunk of data encrypted
+	// in a message, less the poly1305 tag.
+	maxPlaintextSize = maxMessageSize - tagLen
+	dhLen            = 32
+	tagLen           = 16
+)
+
+// ErrClosed is returned by Accept once the listener is closed.
+var ErrClosed = errors.New("secure channel listener closed")
+
+// Channel is an end-to-end encrypted channel to a peer.
+type Channel struct {
+	s      inet.Stream
+	remote peer.ID
+	send   *cipherState
+	recv   *cipherState
+
+	// rbuf is the decrypted data not read yet
+	rbuf []byte
+	wmu  sync.Mutex
+}
+
+// RemotePeer returns the peer at the other end of the channel, authenticated
+// by the handshake.
+func (c *Channel) RemotePeer() peer.ID {
+	return c.remote
+}
+
+// Read reads the data sent by the peer. It returns io.EOF once the peer has
+// closed the channel.
+func (c *Channel) Read(p []byte) (int, error) {
+	for len(c.rbuf) == 0 {
+		msg, err := readMessage(c.s)
+		if err != nil {
+			return 0, err
+		}
+		if c.rbuf, err = c.recv.decrypt(nil, msg); err != nil {
+			c.s.Reset()
+			return 0, fmt.Errorf("failed to decrypt message: %s", err)
+		}
+	}
+	n := copy(p, c.rbuf)
+	c.rbuf = c.rbuf[n:]
+	return n, nil
+}
+
+// Write encrypts p and sends it to the peer.
+func (c *Channel) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxPlaintextSize {
+			chunk = chunk[:maxPlaintextSize]
+		}
+		if err := writeMessage(c.s, c.send.encrypt(nil, chunk)); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Close closes the channel for writing, the peer can still send data.
+func (c *Channel) Close() error {
+	return c.s.Close()
+}
+
+// Reset closes both ends of the channel.
+func (c *Channel) Reset() error {
+	return c.s.Reset()
+}
+
+// Open opens a channel to the peer p, authenticated with the private key of
+// the host, sk.
+func Open(ctx context.Context, h host.Host, sk ci.PrivKey, p peer.ID) (*Channel, error) {
+	s, err := h.NewStream(ctx, p, ProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := handshake(ctx, s, sk, true)
+	if err != nil {
+		s.Reset()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Listener accepts the channels opened by peers.
+type Listener struct {
+	host     host.Host
+	sk       ci.PrivKey
+	channels chan *Channel
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Listen handles the secure channel protocol on h until the listener is
+// closed. The host has a single handler of the protocol.
+func Listen(h host.Host, sk ci.PrivKey) *Listener {
+	l := &Listener{
+		host:     h,
+		sk:       sk,
+		channels: make(chan *Channel),
+		closed:   make(chan struct{}),
+	}
+	h.SetStreamHandler(ProtocolID, l.handleStream)
+	return l
+}
+
+// Accept returns the next channel opened by a peer.
+func (l *Listener) Accept(ctx context.Context) (*Channel, error) {
+	select {
+	case c := <-l.channels:
+		return c, nil
+	case <-l.closed:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops accepting channels. The channels already accepted are not
+// closed.
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() {
+		l.host.RemoveStreamHandler(ProtocolID)
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *Listener) handleStream(s inet.Stream) {
+	ctx, cancel := context.WithTimeout(context.Background(), HandshakeTimeout)
+	defer cancel()
+	c, err := handshake(ctx, s, l.sk, false)
+	if err != nil {
+		log.Debugf("secure channel handshake with %s failed: %s", s.Conn().RemotePeer(), err)
+		s.Reset()
+		return
+	}
+	select {
+	case l.channels <- c:
+	case <-l.closed:
+		s.Reset()
+	case <-ctx.Done():
+		// nobody accepted the channel
+		s.Reset()
+	}
+}
+
+// handshake runs the Noise XX handshake on s, as the initiator or the
+// responder.
+func handshake(ctx context.Context, s inet.Stream, sk ci.PrivKey, initiator bool) (*Channel, error) {
+	ctx, cancel := context.WithTimeout(ctx, HandshakeTimeout)
+	defer cancel()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-stop:
+		}
+	}()
+
+	c, err := runHandshake(s, sk, initiator)
+	close(stop)
+	<-stopped
+	// the stream may have been reset as the handshake completed
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return c, err
+}
+
+func runHandshake(s inet.Stream, sk ci.PrivKey, initiator bool) (*Channel, error) {
+	remote := s.Conn().RemotePeer()
+	static, err := newKeypair()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := identityPayload(sk, static.public[:])
+	if err != nil {
+		return nil, err
+	}
+	hs := newHandshakeState(initiator, static)
+
+	// the payload of the first message is empty, the ones of the others
+	// are the identities of the peers
+	var send, recv *cipherState
+	for i := range patternXX {
+		if (i%2 == 0) == initiator {
+			var p []byte
+			if i > 0 {
+				p = payload
+			}
+			msg, cs1, cs2, err := hs.WriteMessage(nil, p)
+			if err != nil {
+				return nil, err
+			}
+			if err := writeMessage(s, msg); err != nil {
+				return nil, err
+			}
+			send, recv = cs1, cs2
+		} else {
+			msg, err := readMessage(s)
+			if err != nil {
+				return nil, err
+			}
+			rpayload, cs1, cs2, err := hs.ReadMessage(nil, msg)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				if err := verifyPayload(rpayload, hs.PeerStatic(), remote); err != nil {
+					return nil, err
+				}
+			}
+			send, recv = cs1, cs2
+		}
+	}
+	if !initiator {
+		send, recv = recv, send
+	}
+	return &Channel{s: s, remote: remote, send: send, recv: recv}, nil
+}
+
+// identityPayload is the public key of sk and its signature of the static
+// key, each prefixed with its length as an unsigned varint.
+func identityPayload(sk ci.PrivKey, static []byte) ([]byte, error) {
+	pub, err := sk.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sk.Sign(append([]byte(signaturePrefix), static...))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	out := append([]byte{}, buf[:binary.PutUvarint(buf[:], uint64(len(pub)))]...)
+	out = append(out, pub...)
+	out = append(out, buf[:binary.PutUvarint(buf[:], uint64(len(sig)))]...)
+	return append(out, sig...), nil
+}
+
+// verifyPayload checks that the payload is signed by the remote peer, and
+// that the signed static key is the one of the handshake.
+func verifyPayload(payload, static []byte, remote peer.ID) error {
+	pub, rest, err := splitField(payload)
+	if err != nil {
+		return err
+	}
+	sig, rest, err := splitField(rest)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("invalid handshake payload")
+	}
+
+	pk, err := ci.UnmarshalPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	if !remote.MatchesPublicKey(pk) {
+		return fmt.Errorf("the handshake payload is not the key of %s", remote.Pretty())
+	}
+	ok, err := pk.Verify(append([]byte(signaturePrefix), static...), sig)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("invalid static key signature of %s", remote.Pretty())
+	}
+	return nil
+}
+
+func splitField(data []byte) ([]byte, []byte, error) {
+	l, n := binary.Uvarint(data)
+	if n <= 0 || l > uint64(len(data)-n) {
+		return nil, nil, errors.New("invalid handshake payload")
+	}
+	return data[n : n+int(l)], data[n+int(l):], nil
+}
+
+func writeMessage(w io.Writer, msg []byte) error {
+	if len(msg) > maxMessageSize {
+		return fmt.Errorf("message of %d bytes too large", len(msg))
+	}
+	var hdr [2]byte
+	binary.BigEndian.PutUint16(hdr[:], uint16(len(msg)))
+	if _, err := w.Write(append(hdr[:], msg...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readMessage reads a message. It returns io.EOF if the stream is closed
+// before the message, and io.ErrUnexpectedEOF in the middle of it.
+func readMessage(r io.Reader) ([]byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(hdr[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,144 @@
+package securechannel
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	ci "github.com/libp2p/go-libp2p-crypto"
+	host "github.com/libp2p/go-libp2p-host"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	testutil "github.com/libp2p/go-testutil"
+)
+
+// newHosts returns two connected hosts, with real keys: the keys of the
+// peers generated by mocknet can't sign.
+func newHosts(t *testing.T, ctx context.Context) (host.Host, host.Host) {
+	mn := mocknet.New(ctx)
+	for i := 0; i < 2; i++ {
+		sk, _, err := ci.GenerateEd25519Key(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mn.AddPeer(sk, testutil.RandLocalTCPAddress()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	return hosts[0], hosts[1]
+}
+
+func TestChannel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	a, b := newHosts(t, ctx)
+
+	l := Listen(b, b.Peerstore().PrivKey(b.ID()))
+	defer l.Close()
+
+	// larger than a message, to be split
+	sent := make([]byte, 3*maxMessageSize)
+	rand.Read(sent)
+	reply := []byte("received")
+
+	errs := make(chan error, 1)
+	go func() {
+		c, err := l.Accept(ctx)
+		if err != nil {
+			errs <- err
+			return
+		}
+		if c.RemotePeer() != a.ID() {
+			t.Errorf("channel from %s, expected %s", c.RemotePeer(), a.ID())
+		}
+		got := make([]byte, len(sent))
+		if _, err := io.ReadFull(c, got); err != nil {
+			errs <- err
+			return
+		}
+		if !bytes.Equal(got, sent) {
+			t.Error("received data differs from the sent data")
+		}
+		if _, err := c.Write(reply); err != nil {
+			errs <- err
+			return
+		}
+		// the peer closes the channel once it has the reply
+		if rest, err := ioutil.ReadAll(c); err != nil || len(rest) != 0 {
+			t.Errorf("expected the end of the channel, got %q, %v", rest, err)
+		}
+		errs <- c.Close()
+	}()
+
+	c, err := Open(ctx, a, a.Peerstore().PrivKey(a.ID()), b.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RemotePeer() != b.ID() {
+		t.Fatalf("channel to %s, expected %s", c.RemotePeer(), b.ID())
+	}
+	if _, err := c.Write(sent); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(reply))
+	if _, err := io.ReadFull(c, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, reply) {
+		t.Fatalf("got reply %q, expected %q", got, reply)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChannelWrongKey(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	a, b := newHosts(t, ctx)
+
+	// b signs its static key with the key of a: the payload doesn't match
+	// the peer of the stream
+	l := Listen(b, a.Peerstore().PrivKey(a.ID()))
+	defer l.Close()
+
+	if _, err := Open(ctx, a, a.Peerstore().PrivKey(a.ID()), b.ID()); err == nil {
+		t.Fatal("expected the handshake to fail")
+	}
+}
+
+func TestChannelNotListening(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	a, b := newHosts(t, ctx)
+
+	if _, err := Open(ctx, a, a.Peerstore().PrivKey(a.ID()), b.ID()); err == nil {
+		t.Fatal("expected the channel to be refused")
+	}
+}
+
+func TestHKDF(t *testing.T) {
+	// the outputs are chained: out2 is the HMAC of out1 || 0x02
+	ck := bytes.Repeat([]byte{7}, 32)
+	out1, out2 := hkdf(ck, []byte("ikm"))
+	tmp := hmacSHA256(ck, []byte("ikm"))
+	if !bytes.Equal(out1, hmacSHA256(tmp, []byte{1})) {
+		t.Fatal("wrong first output")
+	}
+	if !bytes.Equal(out2, hmacSHA256(tmp, append(out1, 2))) {
+		t.Fatal("wrong second output")
+	}
+}
@@ -148,7 +148,7 @@ test_init_ipfs() {
 
   test_expect_success "ipfs init succeeds" '
     export IPFS_PATH="$(pwd)/.ipfs" &&
-    ipfs init --profile=test -b=1024 > /dev/null
+    ipfs init --profile=test --key-type=rsa -b=1024 > /dev/null
   '
 
   test_expect_success "prepare config -- mounting" '
@@ -423,8 +423,9 @@ directory_size() {
 }
 
 test_check_peerid() {
+  # RSA peer ids are 46 characters long, ed25519 ones 52
   peeridlen=$(echo "$1" | tr -dC "[:alnum:]" | wc -c | tr -d " ") &&
-  test "$peeridlen" = "46" || {
+  { test "$peeridlen" = "46" || test "$peeridlen" = "52"; } || {
     echo "Bad peerid '$1' with len '$peeridlen'"
     return 1
   }
@@ -132,6 +132,16 @@ test_expect_success "'ipfs commands --flags' output looks good" '
   grep "ipfs repo gc --quiet / ipfs repo gc -q" commands.txt
 '
 
+test_expect_success "'ipfs commands completion bash' writes a valid script" '
+  ipfs commands completion bash > completion.bash &&
+  bash -n completion.bash &&
+  grep "complete -o default -F _ipfs ipfs" completion.bash
+'
+
+test_expect_success "'ipfs commands completion' rejects unknown shells" '
+  test_must_fail ipfs commands completion tcsh
+'
+
 
 
 test_done
@@ -54,8 +54,7 @@ test_expect_success "ipfs cat no repo message looks good" '
 test_expect_success "ipfs init succeeds" '
   export IPFS_PATH="$(pwd)/.ipfs" &&
   echo "IPFS_PATH: \"$IPFS_PATH\"" &&
-  BITS="2048" &&
-  ipfs init --bits="$BITS" >actual_init ||
+  ipfs init >actual_init ||
   test_fsh cat actual_init
 '
 
@@ -82,7 +81,7 @@ test_expect_success "ipfs peer id looks good" '
 test_expect_success "ipfs init output looks good" '
   STARTFILE="ipfs cat /ipfs/$HASH_WELCOME_DOCS/readme" &&
   echo "initializing IPFS node at $IPFS_PATH" >expected &&
-  echo "generating $BITS-bit RSA keypair...done" >>expected &&
+  echo "generating ED25519 keypair...done" >>expected &&
   echo "peer identity: $PEERID" >>expected &&
   echo "to get started, enter:" >>expected &&
   printf "\\n\\t$STARTFILE\\n\\n" >>expected &&
@@ -99,7 +98,7 @@ test_expect_success "clean up ipfs dir" '
 
 test_expect_success "'ipfs init --empty-repo' succeeds" '
   BITS="1024" &&
-  ipfs init --bits="$BITS" --empty-repo >actual_init
+  ipfs init --key-type=rsa --bits="$BITS" --empty-repo >actual_init
 '
 
 test_expect_success "ipfs peer id looks good" '
@@ -126,6 +125,23 @@ test_expect_success "clean up ipfs dir" '
   rm -rf "$IPFS_PATH"
 '
 
+test_expect_success "'ipfs init --key-type' with an unknown type fails" '
+  test_must_fail ipfs init --key-type=dsa 2> key_type_out &&
+  grep "unknown key type: dsa" key_type_out
+'
+
+test_expect_success "'ipfs init --bits' generates an RSA key" '
+  ipfs init --bits=1024 --empty-repo >bits_out &&
+  grep "generating 1024-bit RSA keypair...done" bits_out &&
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init --bits' fails with an ed25519 key" '
+  test_must_fail ipfs init --key-type=ed25519 --bits=1024 2> bits_out &&
+  grep "\-\-bits only applies to RSA keys" bits_out &&
+  test ! -e "$IPFS_PATH/config"
+'
+
 # test init profiles
 test_expect_success "'ipfs init --profile' with invalid profile fails" '
   BITS="1024" &&
@@ -181,6 +197,60 @@ test_expect_success "clean up ipfs dir" '
   rm -rf "$IPFS_PATH"
 '
 
+test_expect_success "'ipfs init' with an invalid profile in a list fails" '
+  BITS="1024" &&
+  test_must_fail ipfs init --bits="$BITS" --profile=server,nonexistent_profile 2> invalid_profile_out &&
+  grep "Error: invalid configuration profile: nonexistent_profile" invalid_profile_out &&
+  test ! -f "$IPFS_PATH/config"
+'
+
+test_expect_success "'ipfs init --profile=server,lowpower' succeeds" '
+  BITS="1024" &&
+  ipfs init --bits="$BITS" --profile=server,lowpower > init_out
+'
+
+test_expect_success "'ipfs init' prints the settings changed by each profile" '
+  grep "applied profile server: changed .*Swarm.AddrFilters" init_out &&
+  grep "applied profile lowpower: changed .*Routing.Type" init_out
+'
+
+test_expect_success "both profiles were applied" '
+  ipfs config Swarm.AddrFilters > actual_config &&
+  test $(cat actual_config | wc -l) = 17 &&
+  ipfs config Routing.Type > actual_config &&
+  test $(cat actual_config) = "dhtclient"
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init --from-config' succeeds" '
+  BITS="1024" &&
+  echo "{\"Bootstrap\": [], \"Addresses\": {\"API\": \"/ip4/127.0.0.1/tcp/5555\"}}" > partial_config &&
+  ipfs init --bits="$BITS" --profile=test --from-config=partial_config
+'
+
+test_expect_success "'ipfs init --from-config' merged the config" '
+  ipfs config Addresses.API > actual_config &&
+  test $(cat actual_config) = "/ip4/127.0.0.1/tcp/5555" &&
+  ipfs config Addresses.Gateway > actual_config &&
+  test $(cat actual_config) = "/ip4/127.0.0.1/tcp/0" &&
+  ipfs config Bootstrap > actual_config &&
+  test $(cat actual_config) = "[]"
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init --from-config' rejects an identity" '
+  echo "{\"Identity\": {\"PeerID\": \"QmFoo\"}}" > identity_config &&
+  test_must_fail ipfs init --bits=1024 --from-config=identity_config 2> identity_out &&
+  grep "identity of a node cannot be set" identity_out &&
+  test ! -f "$IPFS_PATH/config"
+'
+
 test_init_ipfs
 
 test_launch_ipfs_daemon
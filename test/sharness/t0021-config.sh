@@ -169,6 +169,19 @@ test_config_cmd() {
     grep "\"PrivKey\":" "$IPFS_PATH/config" | grep -e ": \".\+\"" >/dev/null
   '
 
+  test_expect_success "'ipfs config show' redacts the pinning service keys" '
+    ipfs config --json Pinning.RemoteServices "{\"svc\": {\"API\": {\"Endpoint\": \"https://pin.example\", \"Key\": \"secret-key\"}}}" &&
+    ipfs config show > show_pinning &&
+    test_expect_code 1 grep secret-key show_pinning &&
+    grep "\"Key\": \"REDACTED\"" show_pinning
+  '
+
+  test_expect_success "'ipfs config replace' keeps the pinning service keys" '
+    ipfs config replace show_pinning &&
+    grep secret-key "$IPFS_PATH/config" &&
+    ipfs config --json Pinning "{}"
+  '
+
   test_expect_success "'ipfs config replace' with privkey errors out" '
     cp "$IPFS_PATH/config" real_config &&
     test_expect_code 1 ipfs config replace - < real_config 2> replace_out
@@ -279,6 +292,21 @@ test_config_cmd() {
   test_expect_success "cleanup config backups" '
     find "$IPFS_PATH" -name "config-*" -exec rm {} \;
   '
+
+  test_expect_success "'ipfs config validate' accepts the default config" '
+    ipfs config validate > validate_out &&
+    grep "Config is valid" validate_out
+  '
+
+  test_expect_success "'ipfs config validate' reports invalid values" '
+    cp "$IPFS_PATH/config" config_backup &&
+    ipfs config Ipns.RepublishPeriod 1d &&
+    ipfs config --json Swarm.ConnMgr.LowWater -- -1 &&
+    test_must_fail ipfs config validate 2> validate_err &&
+    grep "Ipns.RepublishPeriod: invalid duration" validate_err &&
+    grep "Swarm.ConnMgr.LowWater: must not be negative" validate_err &&
+    cp config_backup "$IPFS_PATH/config"
+  '
 }
 
 test_init_ipfs
@@ -289,7 +317,34 @@ test_config_cmd
 # should work online
 test_launch_ipfs_daemon
 test_config_cmd
+
+test_expect_success "'--config-override' is rejected for commands run by the daemon" '
+  test_must_fail ipfs --config-override Datastore.StorageMax=1GB repo stat 2>override_err &&
+  grep "can.t be applied to the commands run by the daemon" override_err
+'
 test_kill_ipfs_daemon
 
+test_expect_success "'--config-override' applies to the invocation" '
+  ipfs config Datastore.StorageMax 10GB &&
+  ipfs --config-override Datastore.StorageMax=1GB repo stat >stat_out &&
+  grep "StorageMax: 1000000000$" stat_out
+'
+
+test_expect_success "'--config-override' can be repeated" '
+  ipfs repo stat --config-override Datastore.StorageMax=1GB --config-override=Datastore.StorageMax=2GB >stat_out &&
+  grep "StorageMax: 2000000000$" stat_out
+'
+
+test_expect_success "'--config-override' leaves the config unchanged" '
+  echo 10GB >expected &&
+  ipfs config Datastore.StorageMax >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "'--config-override' rejects unknown keys" '
+  test_must_fail ipfs --config-override Datastore.Nope=1 repo stat 2>override_err &&
+  grep "the config has no Datastore.Nope key" override_err
+'
+
 
 test_done
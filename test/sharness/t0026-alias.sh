@@ -0,0 +1,60 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test alias command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "'ipfs alias add' succeeds" '
+  ipfs alias add add-nocopy "add --only-hash --raw-leaves"
+'
+
+test_expect_success "'ipfs alias list' output looks good" '
+  printf "add-nocopy\tadd --only-hash --raw-leaves\n" >expected &&
+  ipfs alias list >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "the alias is stored in the config" '
+  echo "add --only-hash --raw-leaves" >expected &&
+  ipfs config Aliases.add-nocopy >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "running an alias runs its command" '
+  echo "hello aliases" >file &&
+  ipfs add -q --only-hash --raw-leaves file >expected &&
+  ipfs add-nocopy -q file >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "aliases can't shadow commands" '
+  test_must_fail ipfs alias add cat "add -q" 2>err &&
+  grep "is a command" err
+'
+
+test_expect_success "aliases can't refer to aliases" '
+  test_must_fail ipfs alias add nested "add-nocopy -q" 2>err &&
+  grep "aliases can.t refer to aliases" err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "running an alias works with the daemon" '
+  ipfs add-nocopy -q file >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "'ipfs alias add' works with the daemon" '
+  ipfs alias add pinned "pin ls --type=recursive" &&
+  ipfs alias list >actual &&
+  grep pinned actual
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,63 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test batch command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_batch() {
+  test_expect_success "add some files" '
+    HASH_A=$(echo "batch a" | ipfs add -q --pin=false) &&
+    HASH_B=$(echo "batch b" | ipfs add -q --pin=false)
+  '
+
+  test_expect_success "'ipfs batch' succeeds" '
+    cat >batch.json <<-EOB &&
+	[
+	  {"cmd": "pin add", "args": ["$HASH_A", "$HASH_B"]},
+	  {"cmd": "pin ls", "args": ["$HASH_A"], "opts": {"type": "recursive"}},
+	  {"cmd": "frob"},
+	  {"cmd": "repo fsck"},
+	  {"cmd": "stats bw", "opts": {"poll": true}},
+	  {"cmd": "cat", "args": ["$HASH_A"]},
+	  {"cmd": "pin ls", "opts": {"type": ["recursive", "direct"]}}
+	]
+	EOB
+    ipfs batch --enc=json batch.json >actual
+  '
+
+  test_expect_success "'ipfs batch' output looks good" '
+    grep "\"Cmd\":\"pin add\",\"Output\":\[{\"Pins\":\[\"$HASH_A\",\"$HASH_B\"\]}\],\"Error\":\"\"" actual &&
+    grep "\"Cmd\":\"frob\",\"Output\":null,\"Error\":\"unknown command \\\\\"frob\\\\\"\"" actual &&
+    grep "\"Cmd\":\"repo fsck\",\"Output\":null,\"Error\":\".ipfs repo fsck. can.t be batched\"" actual &&
+    grep "\"Cmd\":\"stats bw\",\"Output\":null,\"Error\":\".ipfs stats bw. can.t be batched with --poll\"" actual &&
+    grep "\"Cmd\":\"cat\",\"Output\":null,\"Error\":\".ipfs cat. can.t be batched\"" actual &&
+    grep "\"Cmd\":\"pin ls\",\"Output\":null,\"Error\":\"option \\\\\"type\\\\\" takes a string" actual
+  '
+
+  test_expect_success "the batched calls were run" '
+    ipfs pin ls --type=recursive $HASH_B
+  '
+
+  test_expect_success "'ipfs batch' fails on invalid input" '
+    echo "{" | test_must_fail ipfs batch
+  '
+
+  test_expect_success "cleanup" '
+    ipfs pin rm $HASH_A $HASH_B
+  '
+}
+
+test_batch
+
+test_launch_ipfs_daemon
+
+test_batch
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,38 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the global timeout option"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+FICTIONAL_HASH="QmXV4f9v8a56MxWKBhP3ETsz4EaafudU1cKfPaaJnenc48"
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs --timeout' cancels a stuck command" '
+  test_expect_code 1 ipfs --timeout 2s cat $FICTIONAL_HASH 2>err
+'
+
+test_expect_success "the timeout error looks good" '
+  echo "Error: context deadline exceeded: the command did not complete within 2s (--timeout)" >expected &&
+  test_cmp expected err
+'
+
+test_expect_success "commands completing in time succeed" '
+  echo "in time" >file &&
+  HASH=$(ipfs --timeout 10s add -q file) &&
+  ipfs --timeout 10s cat $HASH >actual &&
+  test_cmp file actual
+'
+
+test_expect_success "'ipfs --timeout' rejects invalid durations" '
+  test_must_fail ipfs --timeout bogus cat $HASH
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -193,6 +193,11 @@ test_add_cat_file() {
     test_expect_code 1 ipfs add -Q --chunker rabin-12-512-1024 mountdir/hello.txt
   '
 
+  test_expect_success "ipfs add --chunker-profile fails with --chunker" '
+    test_expect_code 1 ipfs add -Q --chunker=size-262144 --chunker-profile=default mountdir/hello.txt 2>add_err &&
+    grep "can.t be used with --chunker" add_err
+  '
+
   test_expect_success "ipfs add on hidden file succeeds" '
     echo "Hello Worlds!" >mountdir/.hello.txt &&
     ipfs add mountdir/.hello.txt >actual
@@ -42,6 +42,43 @@ test_expect_success "test ping 0" '
   ! ipfsi 1 ping -n0 -- "$PEERID_0"
 '
 
+test_expect_success "test ping summary" '
+  ipfsi 0 ping -n2 -- "$PEERID_1" >ping_out &&
+  grep "^2 pings sent, 2 received, 0% packet loss$" ping_out &&
+  grep "^Average latency: .*ms (min .*ms, max .*ms)$" ping_out
+'
+
+test_expect_success "test ping quiet" '
+  ipfsi 0 ping -q -n2 -- "$PEERID_1" >ping_quiet_out &&
+  test_line_count = 2 ping_quiet_out &&
+  grep "^2 pings sent, 2 received, 0% packet loss$" ping_quiet_out
+'
+
+test_expect_success "test ping with a bad timeout" '
+  test_must_fail ipfsi 0 ping -n2 --ping-timeout=0s -- "$PEERID_1" &&
+  test_must_fail ipfsi 0 ping -n2 --ping-timeout=soon -- "$PEERID_1"
+'
+
+test_expect_success "test ping -2" '
+  test_must_fail ipfsi 0 ping -n-2 -- "$PEERID_1"
+'
+
+test_expect_success "test ping --persistent needs an output file" '
+  test_must_fail ipfsi 0 ping --persistent -- "$PEERID_1" &&
+  test_must_fail ipfsi 0 ping -n2 --output=ping.csv -- "$PEERID_1"
+'
+
+test_expect_success "test ping --persistent writes a csv file" '
+  ipfsi 0 ping --persistent --output=ping.csv --interval=100ms -- "$PEERID_1" &
+  PING_PID=$! &&
+  sleep 2 &&
+  kill $PING_PID &&
+  head -n1 ping.csv >ping_csv_header &&
+  echo "timestamp,latency_ms,success" >ping_csv_header_exp &&
+  test_cmp ping_csv_header_exp ping_csv_header &&
+  grep -E "^[0-9T:+Z-]+,[0-9]+\.[0-9]{3},true$" ping.csv
+'
+
 test_expect_success 'stop iptb' '
   iptb stop
 '
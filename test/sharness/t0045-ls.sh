@@ -252,12 +252,38 @@ test_ls_object() {
   '
 }
 
+test_ls_metadata() {
+  test_expect_success "ipfs add a directory with custom metadata" '
+    mkdir -p metadir &&
+    echo hi > metadir/a &&
+    echo yo > withmeta &&
+    DIR=$(ipfs add -rQ metadir) &&
+    META=$(ipfs add -Q --metadata "{\"k\": \"v\"}" withmeta) &&
+    METADIR=$(ipfs object patch add-link $DIR m $META)
+  '
+
+  test_expect_success "'ipfs ls --show-metadata' prints the metadata columns" '
+    ipfs ls -v --show-metadata $METADIR > ls-actual &&
+    grep "^Hash  *Size Mtime Mode Metadata  *Name$" ls-actual &&
+    grep "^QmZLRFWaz9Kypt2ACNMDzA5uzACDRiCqwdkNSP1UZsu56D 3  *a$" ls-actual &&
+    grep "^$META -  *{\"k\":\"v\"} m/$" ls-actual
+  '
+
+  test_expect_success "'ipfs ls' does not print the metadata columns" '
+    ipfs ls $METADIR > ls-actual &&
+    echo "QmZLRFWaz9Kypt2ACNMDzA5uzACDRiCqwdkNSP1UZsu56D 3 a" > ls-expect &&
+    echo "$META - m/" >> ls-expect &&
+    test_cmp ls-expect ls-actual
+  '
+}
+
 # should work offline
 test_ls_cmd
 test_ls_cmd_streaming
 test_ls_cmd_raw_leaves
 test_ls_cmd_raw_leaves --size
 test_ls_object
+test_ls_metadata
 
 # should work online
 test_launch_ipfs_daemon
@@ -0,0 +1,56 @@
+#!/usr/bin/env bash
+
+test_description="Test content-id verify command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create test files" '
+  random 3000000 41 >bigfile &&
+  echo "small file" >smallfile &&
+  cp bigfile otherfile &&
+  echo "appended" >>otherfile
+'
+
+test_content_id_verify() {
+  ARGS=$1
+
+  test_expect_success "content-id verify matches files added with '$ARGS'" '
+    BIG=$(ipfs add -Q $ARGS bigfile) &&
+    SMALL=$(ipfs add -Q $ARGS smallfile) &&
+    echo MATCH >expected_match &&
+    ipfs content-id verify $BIG bigfile >actual_big &&
+    test_cmp expected_match actual_big &&
+    ipfs content-id verify $SMALL smallfile >actual_small &&
+    test_cmp expected_match actual_small
+  '
+
+  test_expect_success "content-id verify reports the CID of other files with '$ARGS'" '
+    OTHER=$(ipfs add -Q --only-hash $ARGS otherfile) &&
+    echo "MISMATCH $OTHER" >expected_mismatch &&
+    ipfs content-id verify $BIG otherfile >actual_mismatch &&
+    test_cmp expected_mismatch actual_mismatch
+  '
+}
+
+test_content_id_verify ''
+test_content_id_verify '--trickle'
+test_content_id_verify '--raw-leaves'
+test_content_id_verify '--cid-version=1'
+test_content_id_verify '--chunker=size-1000 --trickle'
+test_content_id_verify '--hash=blake2b-256'
+
+test_expect_success "content-id verify does not add the file" '
+  test_must_fail ipfs block stat $OTHER
+'
+
+test_expect_success "content-id verify rejects directories" '
+  mkdir dir &&
+  cp smallfile dir &&
+  DIR=$(ipfs add -Qr dir) &&
+  test_must_fail ipfs content-id verify $DIR smallfile 2>verify_err &&
+  grep "is not a file" verify_err
+'
+
+test_done
@@ -183,6 +183,36 @@ test_expect_success "multi-block 'ipfs block rm -q' produces no output" '
   test ! -s block_rm_out
 '
 
+test_expect_success "'add some blocks' succeeds" '
+  echo "Hello Mars!" | ipfs block put &&
+  echo "Hello Venus!" | ipfs block put
+'
+
+test_expect_success "'ipfs block rm' does not read stdin without --stdin-cids" '
+  printf "%s\n" $HASH $RANDOMHASH $HASH2 > block_rm_cids &&
+  test_must_fail ipfs block rm < block_rm_cids 2> block_rm_err &&
+  grep "use --stdin-cids" block_rm_err &&
+  ipfs block stat $HASH
+'
+
+test_expect_success "'ipfs block rm --stdin-cids' fails on non existent blocks" '
+  test_must_fail ipfs block rm --stdin-cids < block_rm_cids 2> block_rm_err &&
+  grep "cannot remove $RANDOMHASH" block_rm_err &&
+  test_must_fail ipfs block stat $HASH &&
+  test_must_fail ipfs block stat $HASH2
+'
+
+test_expect_success "'add some blocks' succeeds" '
+  echo "Hello Mars!" | ipfs block put &&
+  echo "Hello Venus!" | ipfs block put
+'
+
+test_expect_success "'ipfs block rm -f --stdin-cids' with non existent blocks succeeds" '
+  ipfs block rm -f --stdin-cids < block_rm_cids &&
+  test_must_fail ipfs block stat $HASH &&
+  test_must_fail ipfs block stat $HASH2
+'
+
 test_expect_success "can set cid format on block put" '
   HASH=$(ipfs block put --format=protobuf ../t0051-object-data/testPut.pb)
 '
@@ -198,6 +228,28 @@ test_expect_success "block get output looks right" '
   test_cmp pb_block_out ../t0051-object-data/testPut.pb
 '
 
+test_expect_success "'ipfs block get --decode=dag-pb' prints the node" '
+  ipfs block get --decode=dag-pb $HASH > pb_decode_out &&
+  cat <<-\EOF > pb_decode_exp &&
+{
+  "Data": "dGVzdCBqc29uIGZvciBzaGFybmVzcyB0ZXN0",
+  "Links": []
+}
+EOF
+  test_cmp pb_decode_exp pb_decode_out
+'
+
+test_expect_success "'ipfs block get --decode=raw' prints the data in base64" '
+  ipfs block get --decode=raw $HASH > raw_decode_out &&
+  echo "\"$(base64 < ../t0051-object-data/testPut.pb)\"" > raw_decode_exp &&
+  test_cmp raw_decode_exp raw_decode_out
+'
+
+test_expect_success "'ipfs block get --decode' rejects unknown codecs" '
+  test_must_fail ipfs block get --decode=foo $HASH 2> decode_err &&
+  grep "unsupported codec" decode_err
+'
+
 test_expect_success "can set multihash type and length on block put" '
   HASH=$(echo "foooo" | ipfs block put --format=raw --mhtype=sha3 --mhlen=20)
 '
@@ -211,6 +211,36 @@ test_object_cmd() {
     ipfs refs -r --timeout=2s $HASH > /dev/null
   '
 
+  test_expect_success "'ipfs object new --type dag-cbor' creates an empty map" '
+    ipfs object new --type dag-cbor > cbor_new &&
+    echo zdpuAyTBnYSugBZhqJuLsNpzjmAjSmxDqBbtAqXMtsvxiN2v3 > cbor_exp &&
+    test_cmp cbor_exp cbor_new &&
+    ipfs dag get $(cat cbor_new) > cbor_get &&
+    echo "{}" > cbor_exp &&
+    test_cmp cbor_exp cbor_get
+  '
+
+  test_expect_success "'ipfs object new --type dag-cbor --data' works" '
+    CBOR=$(ipfs object new --type dag-cbor --data "{\"key\":\"value\"}") &&
+    ipfs dag get $CBOR/key > cbor_get &&
+    echo "\"value\"" > cbor_exp &&
+    test_cmp cbor_exp cbor_get
+  '
+
+  test_expect_success "'ipfs object links' lists the CIDs of a dag-cbor node" '
+    EMPTY=$(ipfs object new) &&
+    CBOR=$(ipfs object new --type dag-cbor --data "{\"a\":{\"/\":\"$EMPTY\"},\"b\":{\"c\":{\"/\":\"$EMPTY\"}},\"n\":1}") &&
+    ipfs object links $CBOR > cbor_links &&
+    echo "$EMPTY 0 a" > cbor_exp &&
+    echo "$EMPTY 0 b/c" >> cbor_exp &&
+    test_cmp cbor_exp cbor_links
+  '
+
+  test_expect_success "'ipfs object new --data' fails without dag-cbor" '
+    test_must_fail ipfs object new --data "{}" 2> cbor_err &&
+    grep "requires --type dag-cbor" cbor_err
+  '
+
   test_expect_success "'ipfs object patch' should work (no unixfs-dir)" '
     EMPTY_DIR=$(ipfs object new) &&
     OUTPUT=$(ipfs object patch $EMPTY_DIR add-link foo $EMPTY_DIR) &&
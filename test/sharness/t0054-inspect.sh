@@ -0,0 +1,47 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test inspect command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "'ipfs inspect' summarizes UnixFS files" '
+  random 600000 41 >bigfile &&
+  HASH=$(ipfs add -q bigfile) &&
+  ipfs inspect $HASH >actual &&
+  grep "^Codec:      dag-pb$" actual &&
+  grep "^UnixFS:     file, 600000 bytes in 3 blocks$" actual &&
+  grep "^Links:      3$" actual
+'
+
+test_expect_success "'ipfs inspect' shows the custom metadata" '
+  echo "with metadata" >mdfile &&
+  HASH=$(ipfs add -q --metadata="{\"author\":\"alice\"}" mdfile) &&
+  ipfs inspect $HASH >actual &&
+  grep "^Metadata:   {\"author\":\"alice\"}$" actual
+'
+
+test_expect_success "'ipfs inspect' decodes dag-cbor nodes" '
+  HASH=$(echo "{\"count\":1}" | ipfs dag put) &&
+  ipfs inspect $HASH >actual &&
+  grep "^Codec:      dag-cbor$" actual &&
+  grep "\"count\": 1" actual
+'
+
+test_expect_success "'ipfs inspect' dumps raw blocks" '
+  HASH=$(echo "raw data" | ipfs add -q --raw-leaves) &&
+  ipfs inspect $HASH >actual &&
+  grep "^Codec:      raw$" actual &&
+  grep "^00000000  72 61 77 20 64 61 74 61  0a  *|raw data.|$" actual
+'
+
+test_expect_success "'ipfs inspect --enc=json' output looks good" '
+  ipfs inspect --enc=json $HASH >actual &&
+  grep "\"Codec\":\"raw\",\"Multihash\":\"sha2-256\",\"Size\":9,\"Links\":\[\],\"Hex\":\"72617720646174610a\"" actual
+'
+
+test_done
@@ -0,0 +1,46 @@
+#!/usr/bin/env bash
+
+test_description="Test object merge command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create two directories to merge" '
+  mkdir -p a/sub b/sub merged/sub &&
+  echo "only in a" >a/x &&
+  echo "from a" >a/conflict &&
+  echo "keep" >a/sub/keep &&
+  echo "only in b" >b/y &&
+  echo "from b" >b/conflict &&
+  echo "new" >b/sub/new &&
+  A=$(ipfs add -rQ a) &&
+  B=$(ipfs add -rQ b)
+'
+
+test_expect_success "object merge unions the links, b overriding a" '
+  cp a/x b/y b/conflict merged/ &&
+  cp b/sub/new merged/sub/ &&
+  EXPECTED=$(ipfs add -rQ --only-hash merged) &&
+  ipfs object merge $A $B >actual &&
+  echo $EXPECTED >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "object merge --recursive merges the subdirectories" '
+  cp a/sub/keep merged/sub/ &&
+  EXPECTED=$(ipfs add -rQ --only-hash merged) &&
+  ipfs object merge --recursive $A $B >actual &&
+  echo $EXPECTED >expected &&
+  test_cmp expected actual &&
+  ipfs cat $EXPECTED/sub/keep >actual &&
+  test_cmp a/sub/keep actual
+'
+
+test_expect_success "object merge fails on non dag-pb objects" '
+  RAW=$(ipfs add -Q --raw-leaves --cid-version=1 a/x) &&
+  test_must_fail ipfs object merge $A $RAW 2>err &&
+  grep "expected protobuf dag node" err
+'
+
+test_done
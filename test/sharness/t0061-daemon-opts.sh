@@ -40,6 +40,34 @@ test_expect_success 'gateway should work in offline mode' '
 
 test_kill_ipfs_daemon
 
+test_expect_success "'ipfs config profile apply offline' works" '
+  ipfs config profile apply offline &&
+  test "$(ipfs config Routing.Type)" = "offline"
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success 'daemon does not listen on swarm with offline profile' '
+  grep "Swarm not listening, running in offline mode." actual_daemon
+'
+
+test_expect_success 'api works with offline profile' '
+  echo "hello local storage" >expected &&
+  HASH=$(ipfs add -q expected) &&
+  ipfs cat "$HASH" >actual2 &&
+  test_cmp expected actual2
+'
+
+test_expect_success 'swarm is unavailable with offline profile' '
+  test_must_fail ipfs swarm peers
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success 'reset routing type' '
+  ipfs config Routing.Type dht
+'
+
 test_expect_success 'daemon should not start with bad dht opt' '
   test_must_fail ipfs daemon --routing=fdsfdsfds > daemon_output 2>&1
 '
@@ -58,4 +86,22 @@ test_expect_success 'output contains info about supernode dht option' '
   test_fsh cat daemon_output2
 '
 
+test_expect_success 'daemon should not start with a bad routing backend' '
+  test_must_fail ipfs daemon --routing-backend=dhtclient > daemon_output3 2>&1 &&
+  grep "unrecognized routing backend: dhtclient" daemon_output3
+'
+
+test_expect_success 'daemon should not start with both routing flags' '
+  test_must_fail ipfs daemon --routing=dht --routing-backend=none > daemon_output4 2>&1 &&
+  grep "cannot be used together" daemon_output4
+'
+
+test_launch_ipfs_daemon --routing-backend=none
+
+test_expect_success '--routing-backend does not change the config' '
+  test "$(ipfs config Routing.Type)" = "dht"
+'
+
+test_kill_ipfs_daemon
+
 test_done
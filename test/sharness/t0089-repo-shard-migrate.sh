@@ -0,0 +1,51 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs repo shard-migrate"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add some content" '
+  random 100000 41 > afile &&
+  HASH=$(ipfs add -q afile)
+'
+
+test_expect_success "'ipfs repo shard-migrate' rejects unknown functions" '
+  test_must_fail ipfs repo shard-migrate --shard-func foo/2 2> err &&
+  grep "invalid sharding function" err
+'
+
+test_expect_success "'ipfs repo shard-migrate' succeeds" '
+  ipfs repo shard-migrate --shard-func prefix/3 > out &&
+  grep "/repo/flatfs/shard/v1/prefix/3" out
+'
+
+test_expect_success "SHARDING and config were updated" '
+  grep "/repo/flatfs/shard/v1/prefix/3" "$IPFS_PATH/blocks/SHARDING" &&
+  grep "prefix/3" "$IPFS_PATH/datastore_spec" &&
+  ipfs config Datastore.Spec | grep "prefix/3"
+'
+
+test_expect_success "content is still available" '
+  ipfs repo verify &&
+  ipfs cat $HASH > afile.out &&
+  test_cmp afile afile.out
+'
+
+test_expect_success "migrating to the current function fails" '
+  test_must_fail ipfs repo shard-migrate --shard-func /repo/flatfs/shard/v1/prefix/3
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs repo shard-migrate' cannot run with the daemon" '
+  test_must_fail ipfs repo shard-migrate --shard-func next-to-last/2
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -153,6 +153,44 @@ test_get_cmd() {
     ipfs get -o out_medium $(cat hash_medium) &&
     test_cmp medium out_medium
   '
+
+  test_expect_success "create a large file" '
+    random 1000000 42 > large &&
+    ipfs add -q --trickle large > hash_large
+  '
+
+  test_expect_success "ipfs get --resume completes a partial file" '
+    head -c 300000 large > out_large &&
+    ipfs get --resume out_large $(cat hash_large) > resume_out &&
+    test_cmp large out_large &&
+    grep "out_large: resuming at byte 262144 of 1000000" resume_out
+  '
+
+  test_expect_success "ipfs get --resume keeps a complete file" '
+    ipfs get --resume out_large $(cat hash_large) > resume_out &&
+    test_cmp large out_large &&
+    grep "out_large: complete" resume_out
+  '
+
+  test_expect_success "ipfs get --resume fails on a file not matching the object" '
+    head -c 300000 medium > out_large &&
+    test_must_fail ipfs get --resume out_large $(cat hash_large) 2> resume_err &&
+    grep "differs from the DAG" resume_err
+  '
+
+  test_expect_success "ipfs get --resume fails with --archive" '
+    test_must_fail ipfs get --resume out_large -a $(cat hash_large)
+  '
+
+  test_expect_success "ipfs get --resume does not follow a symlink" '
+    head -c 300000 large > outside_large &&
+    rm -f out_large &&
+    ln -s outside_large out_large &&
+    test_must_fail ipfs get --resume out_large $(cat hash_large) 2> resume_err &&
+    grep "not a regular file" resume_err &&
+    head -c 300000 large | test_cmp - outside_large &&
+    rm out_large outside_large
+  '
 }
 
 test_get_fail() {
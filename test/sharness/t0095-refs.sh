@@ -176,10 +176,44 @@ EOF
     ipfs refs $ARGS -r --unique --max-depth=2 $refsroot > refsr.txt
     test_cmp refsr.txt expected.txt
   '
+
+  test_expect_success "ipfs refs $ARGS -r --include-root" '
+    (echo $refsroot | $FILTER && ipfs refs $ARGS -r $refsroot) > expected.txt &&
+    ipfs refs $ARGS -r --include-root $refsroot > refsr.txt &&
+    test_cmp refsr.txt expected.txt
+  '
+
+  test_expect_success "ipfs refs $ARGS --include-root --edges fails" '
+    test_must_fail ipfs refs $ARGS --include-root --edges $refsroot
+  '
 }
 
 test_refs_output '' 'cat'
 
 test_refs_output '--cid-base=base32' 'ipfs cid base32'
 
+test_expect_success "ipfs refs local --format prints sizes and pin types" '
+  UNPINNED=$(echo "refs local unpinned" | ipfs add -q --pin=false) &&
+  DIRECT=$(echo "refs local direct" | ipfs add -q --pin=false) &&
+  ipfs pin add -r=false $DIRECT &&
+  RECURSIVE=$(echo "refs local recursive" | ipfs add -q) &&
+  ipfs refs local --format="{{.PinType}} {{.Size}} {{.Ref}}" >refs_local &&
+  grep "^none 28 $UNPINNED$" refs_local &&
+  grep "^direct 26 $DIRECT$" refs_local &&
+  grep "^recursive 29 $RECURSIVE$" refs_local
+'
+
+test_expect_success "ipfs refs local prints the refs only by default" '
+  ipfs refs local >refs_local_default &&
+  grep "^$UNPINNED$" refs_local_default &&
+  test_line_count = $(wc -l <refs_local) refs_local_default
+'
+
+test_expect_success "ipfs refs local rejects invalid formats" '
+  test_must_fail ipfs refs local --format="{{" 2>refs_local_err &&
+  grep "invalid format" refs_local_err
+'
+
+test_kill_ipfs_daemon
+
 test_done
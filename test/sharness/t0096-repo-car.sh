@@ -0,0 +1,165 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test importing and exporting CAR files"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+CAR_ROOT=QmZ68UN5f7S8XUhSntE5T5YTQKvXuTQneS1TvzrU4EjoG8
+
+test_repo_import() {
+  test_expect_success "'ipfs repo import' succeeds" '
+    ipfs repo import ../t0096-repo-car-data/single.car > import_out
+  '
+
+  test_expect_success "'ipfs repo import' output looks good" '
+    echo "imported 1 blocks" > import_exp &&
+    echo "pinned root $CAR_ROOT" >> import_exp &&
+    test_cmp import_exp import_out
+  '
+
+  test_expect_success "the root is pinned and readable" '
+    ipfs pin ls --type=recursive $CAR_ROOT &&
+    echo "car test content" > cat_exp &&
+    ipfs cat $CAR_ROOT > cat_out &&
+    test_cmp cat_exp cat_out
+  '
+
+  test_expect_success "'ipfs repo import --no-pin' does not pin" '
+    ipfs pin rm $CAR_ROOT &&
+    ipfs repo import --no-pin < ../t0096-repo-car-data/single.car > import_out &&
+    grep "^root $CAR_ROOT" import_out &&
+    test_must_fail ipfs pin ls $CAR_ROOT
+  '
+
+  test_expect_success "'ipfs repo import --cid-base' encodes the roots" '
+    ipfs repo import --no-pin --cid-base=base32 ../t0096-repo-car-data/single.car > import_out &&
+    grep "^root $(ipfs cid base32 $CAR_ROOT)" import_out
+  '
+
+  test_expect_success "'ipfs repo import' rejects truncated files" '
+    head -c 20 ../t0096-repo-car-data/single.car > truncated.car &&
+    test_must_fail ipfs repo import truncated.car
+  '
+}
+
+test_repo_export() {
+  test_expect_success "add a directory" '
+    mkdir -p dir &&
+    random 400000 42 > dir/big &&
+    echo small > dir/small &&
+    DIR_HASH=$(ipfs add -rQ dir)
+  '
+
+  test_expect_success "'ipfs repo export' writes a CAR file" '
+    ipfs repo export $DIR_HASH -o dir.car &&
+    test -s dir.car
+  '
+
+  test_expect_success "'ipfs repo export' defaults to <ipfs-path>.car" '
+    ipfs repo export $DIR_HASH &&
+    test_cmp dir.car $DIR_HASH.car
+  '
+
+  test_expect_success "'ipfs repo export --output=-' writes to stdout" '
+    ipfs repo export $DIR_HASH -o - > dir_stdout.car &&
+    test_cmp dir.car dir_stdout.car
+  '
+
+  test_expect_success "'ipfs repo export --version=2' writes a CARv2 file" '
+    ipfs repo export --version=2 $DIR_HASH -o dir2.car &&
+    printf "\x0a\xa1\x67version\x02" > pragma_exp &&
+    head -c 11 dir2.car > pragma_out &&
+    test_cmp pragma_exp pragma_out
+  '
+
+  test_expect_success "'ipfs repo export' rejects unknown versions" '
+    test_must_fail ipfs repo export --version=3 $DIR_HASH -o dir3.car
+  '
+
+  test_expect_success "exported files can be imported again" '
+    ipfs pin rm $DIR_HASH &&
+    ipfs repo gc &&
+    ipfs repo import dir2.car > import_out &&
+    grep "pinned root $DIR_HASH" import_out &&
+    rm -rf dir_out &&
+    ipfs get -o dir_out $DIR_HASH &&
+    test_cmp dir/big dir_out/big &&
+    test_cmp dir/small dir_out/small
+  '
+}
+
+test_repo_pack() {
+  test_expect_success "'ipfs repo pack' writes the same file as 'ipfs repo export'" '
+    ipfs repo pack $DIR_HASH pack.car &&
+    test_cmp dir.car pack.car
+  '
+
+  test_expect_success "'ipfs repo pack --compress' writes a zstd file" '
+    ipfs repo pack --compress $DIR_HASH pack.car.zst &&
+    printf "\050\265\057\375" > zstd_magic &&
+    head -c 4 pack.car.zst > pack_magic &&
+    test_cmp zstd_magic pack_magic
+  '
+
+  test_expect_success "'ipfs repo pack --exclude-already-local' leaves out pinned DAGs" '
+    BIG_HASH=$(ipfs add -Q dir/big) &&
+    echo "$BIG_HASH recursive" > manifest &&
+    ipfs repo pack --exclude-already-local=manifest $DIR_HASH pack_excl.car &&
+    test $(wc -c < pack_excl.car) -lt 1000
+  '
+
+  test_expect_success "compressed bundles can be imported" '
+    ipfs repo import pack.car.zst > import_out &&
+    grep "pinned root $DIR_HASH" import_out
+  '
+}
+
+test_dag_car() {
+  test_expect_success "'ipfs dag export' writes the same file as 'ipfs repo export'" '
+    ipfs dag export $DIR_HASH > dag.car &&
+    test_cmp dir.car dag.car
+  '
+
+  test_expect_success "'ipfs dag export --version=2' writes a CARv2 file" '
+    ipfs dag export --version=2 $DIR_HASH > dag2.car &&
+    test_cmp dir2.car dag2.car
+  '
+
+  test_expect_success "'ipfs dag import' imports without pinning" '
+    ipfs pin rm $DIR_HASH &&
+    ipfs repo gc &&
+    ipfs dag import dag2.car > import_out &&
+    echo "imported 5 blocks" > import_exp &&
+    echo "root $DIR_HASH" >> import_exp &&
+    test_cmp import_exp import_out &&
+    test_must_fail ipfs pin ls $DIR_HASH &&
+    ipfs cat $DIR_HASH/small > small_out &&
+    test_cmp dir/small small_out
+  '
+
+  test_expect_success "'ipfs dag import' reads CARv1 files from stdin" '
+    ipfs dag import < dag.car > import_out &&
+    test_cmp import_exp import_out
+  '
+}
+
+# should work offline
+test_repo_import
+test_repo_export
+test_repo_pack
+test_dag_car
+
+# should work online
+test_launch_ipfs_daemon
+test_repo_import
+test_repo_export
+test_repo_pack
+test_dag_car
+test_kill_ipfs_daemon
+
+test_done
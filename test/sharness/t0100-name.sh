@@ -33,6 +33,34 @@ test_expect_success "resolve output looks good" '
   test_cmp expected2 output
 '
 
+# test listing published names
+
+test_expect_success "'ipfs name list --offline' succeeds" '
+  ipfs name list --offline >list_out
+'
+
+test_expect_success "list output looks good" '
+  printf "self\t%s\t/ipfs/%s\n" "$PEERID" "$HASH_WELCOME_DOCS" >expected_list &&
+  test_cmp expected_list list_out
+'
+
+# test retracting a published name
+
+test_expect_success "'ipfs name cancel --allow-offline' succeeds" '
+  ipfs name cancel --allow-offline self >cancel_out
+'
+
+test_expect_success "cancel output looks good" '
+  echo "Published to ${PEERID}: /ipfs/$HASH_EMPTY_DIR" >expected_cancel &&
+  test_cmp expected_cancel cancel_out
+'
+
+test_expect_success "'ipfs name resolve' returns the tombstone" '
+  ipfs name resolve "$PEERID" >output &&
+  printf "/ipfs/%s\n" "$HASH_EMPTY_DIR" >expected_cancel &&
+  test_cmp expected_cancel output
+'
+
 # test publishing with -Q option
 
 
@@ -171,9 +171,69 @@ test_bootstrap_cmd() {
 # should work offline
 test_bootstrap_cmd
 
+test_expect_success "'ipfs bootstrap add --region' tags the peers" '
+  ipfs bootstrap add --region=us-east-1 $BP1 $BP2 &&
+  ipfs bootstrap add --region=eu-west-1 $BP3 &&
+  ipfs config Bootstrap > bootstrap_entries &&
+  test "$(grep -c "\"region\": \"us-east-1\"" bootstrap_entries)" = 2 &&
+  grep "\"addr\": \"$BP3\"" bootstrap_entries
+'
+
+test_expect_success "'ipfs bootstrap list --region' filters the peers" '
+  echo $BP3 > list_region_exp &&
+  ipfs bootstrap list --region=eu-west-1 > list_region_actual &&
+  test_cmp list_region_exp list_region_actual
+'
+
+test_expect_success "the regions survive other config changes" '
+  ipfs bootstrap add $BP4 &&
+  ipfs config Addresses.Announce --json "[]" &&
+  ipfs bootstrap list --region=eu-west-1 > list_region_actual &&
+  test_cmp list_region_exp list_region_actual &&
+  ipfs config validate
+'
+
+test_expect_success "invalid bootstrap entries are refused" '
+  cp "$IPFS_PATH/config" config_backup &&
+  test_must_fail ipfs config --json Bootstrap "[{\"region\": \"us-east-1\"}]" 2> entry_err &&
+  grep "missing \"addr\"" entry_err &&
+  cp config_backup "$IPFS_PATH/config"
+'
+
+test_expect_success "'ipfs bootstrap rm' removes the tagged entries" '
+  ipfs bootstrap rm all &&
+  ipfs config Bootstrap > bootstrap_entries &&
+  echo "[]" > bootstrap_exp &&
+  test_cmp bootstrap_exp bootstrap_entries
+'
+
+test_expect_success "'ipfs bootstrap test' needs the daemon" '
+  test_must_fail ipfs bootstrap test 2> test_offline_err &&
+  grep "online mode" test_offline_err
+'
+
 # should work online
 test_launch_ipfs_daemon
 test_bootstrap_cmd
+
+# nothing listens on port 1
+BP_DOWN=/ip4/127.0.0.1/tcp/1/ipfs/QmNSYxZAiJHeLdkBg38roksAR9So7Y5eojks1yjEcUtZ7i
+
+test_expect_success "'ipfs bootstrap test' reports unreachable peers" '
+  ipfs bootstrap add $BP_DOWN &&
+  ipfs bootstrap test --remove-unreachable --threshold=2 > bootstrap_test_out &&
+  grep "⚠ $BP_DOWN unreachable (1 consecutive failures)" bootstrap_test_out &&
+  grep "^0 of 1 bootstrap peers reachable$" bootstrap_test_out
+'
+
+test_expect_success "'ipfs bootstrap test --remove-unreachable' removes peers past the threshold" '
+  ipfs bootstrap test --remove-unreachable --threshold=2 > bootstrap_test_out &&
+  grep "(2 consecutive failures)" bootstrap_test_out &&
+  grep "^removed $BP_DOWN$" bootstrap_test_out
+'
+
+test_bootstrap_list_cmd
+
 test_kill_ipfs_daemon
 
 
@@ -38,6 +38,22 @@ test_expect_success "output looks good" '
   grep PublicKey output
 '
 
+test_expect_success "disconnected: nat status is unknown" '
+  ipfs swarm nat status >actual &&
+  grep "^AutoNAT: unknown$" actual &&
+  grep "^Port mapping: " actual
+'
+
+test_expect_success "disconnected: nat refresh needs AutoNAT peers" '
+  test_must_fail ipfs swarm nat refresh 2>err &&
+  grep "no connected peer runs the AutoNAT service" err
+'
+
+test_expect_success "nat disable works" '
+  ipfs swarm nat disable >actual &&
+  grep "^removed [0-9]* port mappings$" actual
+'
+
 addr="/ip4/127.0.0.1/tcp/9898/ipfs/QmUWKoHbjsqsSMesRC2Zoscs8edyFz6F77auBB1YBBhgpX"
 
 test_expect_success "cant trigger a dial backoff with swarm connect" '
@@ -123,6 +139,36 @@ test_expect_success "/p2p addresses work" '
   [ $(ipfsi 0 swarm peers | wc -l) -eq 1 ]
 '
 
+test_expect_success "peers --scores shows the initial score" '
+  ipfsi 0 swarm peers --scores >actual &&
+  grep "score=100$" actual
+'
+
+test_expect_success "unban resets a peer" '
+  echo "unban $(iptb attr get 1 id) success" >expected &&
+  ipfsi 0 swarm unban "$(iptb attr get 1 id)" >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "unban rejects invalid peer ids" '
+  test_must_fail ipfsi 0 swarm unban foo
+'
+
+test_expect_success "trust add trusts a peer" '
+  echo "trust $(iptb attr get 1 id) success" >expected &&
+  ipfsi 0 trust add "$(iptb attr get 1 id)" >actual &&
+  test_cmp expected actual &&
+  iptb attr get 1 id >expected &&
+  ipfsi 0 trust list >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "trust remove stops trusting a peer" '
+  ipfsi 0 trust remove "$(iptb attr get 1 id)" &&
+  ipfsi 0 trust list >actual &&
+  test_must_be_empty actual
+'
+
 test_expect_success "stopping cluster" '
   iptb stop
 '
@@ -74,6 +74,62 @@ test_key_cmd() {
     test_must_fail ipfs key rename -f fooed self 2>&1 | tee key_rename_out &&
     grep -q "Error: cannot overwrite key with name" key_rename_out
   '
+
+  test_expect_success "key sign signs with a key" '
+    echo "signed statement" >statement &&
+    edsig=$(ipfs key sign fooed statement) &&
+    rsasig=$(ipfs key sign key2 statement) &&
+    selfsig=$(ipfs key sign self statement)
+  '
+
+  test_expect_success "key verify checks the signatures" '
+    echo "signature valid for $edhash" >verify_exp &&
+    ipfs key verify $edhash statement $edsig >verify_out &&
+    test_cmp verify_exp verify_out &&
+    ipfs key verify $key_content statement $rsasig &&
+    ipfs key verify "$PeerID" statement $selfsig
+  '
+
+  test_expect_success "key verify reads the data from stdin" '
+    ipfs key verify $edhash - $edsig <statement
+  '
+
+  test_expect_success "key verify rejects a wrong signature" '
+    echo "another statement" >other &&
+    test_must_fail ipfs key verify $edhash other $edsig 2>verify_err &&
+    grep "signature is not valid for $edhash" verify_err &&
+    test_must_fail ipfs key verify $key_content statement $edsig
+  '
+
+  test_expect_success "key derive derives the same key from a parent and path" '
+    child=$(ipfs key derive --parent=fooed --path="m/44'"'"'/501'"'"'/0'"'"'" child) &&
+    again=$(ipfs key derive --parent=fooed --path="m/44h/501h/0h" again) &&
+    test "$child" = "$again" &&
+    test "$child" != "$edhash"
+  '
+
+  test_expect_success "key derive derives from a derived key" '
+    grandchild=$(ipfs key derive --parent=child --path="m/1'"'"'" grandchild) &&
+    ipfs key list | grep grandchild
+  '
+
+  test_expect_success "key derive rejects a non-hardened path" '
+    test_must_fail ipfs key derive --parent=fooed --path="m/0" soft 2>derive_err &&
+    grep "is not hardened" derive_err
+  '
+
+  test_expect_success "key derive rejects a rsa parent" '
+    test_must_fail ipfs key derive --parent=key2 --path="m/0'"'"'" fromrsa
+  '
+
+  test_expect_success "key derive --list shows the derivation tree" '
+    ipfs key rm again &&
+    echo fooed >derive_exp &&
+    echo "  child m/44'"'"'/501'"'"'/0'"'"' $child" >>derive_exp &&
+    echo "    grandchild m/1'"'"' $grandchild" >>derive_exp &&
+    ipfs key derive --list >derive_out &&
+    test_cmp derive_exp derive_out
+  '
 }
 
 test_key_cmd
@@ -0,0 +1,31 @@
+#!/usr/bin/env bash
+
+test_description="Test routing http command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "'ipfs routing http add' sets the delegated endpoint" '
+  ipfs routing http add http://127.0.0.1:1/ &&
+  echo "http://127.0.0.1:1" >expected_endpoint &&
+  ipfs config Routing.DelegatedEndpoint >actual_endpoint &&
+  test_cmp expected_endpoint actual_endpoint
+'
+
+test_expect_success "'ipfs routing http add' rejects invalid endpoints" '
+  test_must_fail ipfs routing http add ftp://example.com 2>add_err &&
+  grep "invalid routing endpoint" add_err
+'
+
+test_expect_success "'ipfs routing http find' rejects invalid cids" '
+  test_must_fail ipfs routing http find notacid 2>find_err &&
+  grep "invalid cid" find_err
+'
+
+test_expect_success "'ipfs routing http find' reports unreachable servers" '
+  test_must_fail ipfs routing http find bafkqaaa 2>find_err &&
+  grep "connection refused" find_err
+'
+
+test_done
@@ -0,0 +1,60 @@
+#!/usr/bin/env bash
+
+test_description="Test mirror command"
+
+. lib/test-lib.sh
+
+# start iptb + wait for peering
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb testbed create -type localipfs -count $NUM_NODES -init
+'
+
+startup_cluster $NUM_NODES
+
+test_expect_success 'add content on node 0' '
+  PEERID_0=$(iptb attr get 0 id) &&
+  mkdir -p dir/sub &&
+  random 300000 42 >dir/sub/file &&
+  echo hello >dir/hello &&
+  HASH=$(ipfsi 0 add -rQ dir)
+'
+
+test_expect_success 'mirror the root only' '
+  ipfsi 1 mirror --recursive=false "$PEERID_0" "$HASH" >mirror_out &&
+  grep "mirrored $HASH from $PEERID_0: 1 blocks" mirror_out &&
+  test_must_fail ipfsi 1 pin ls "$HASH"
+'
+
+test_expect_success 'mirror and pin the whole DAG' '
+  ipfsi 1 mirror --pin "$PEERID_0" "$HASH" >mirror_out &&
+  grep "mirrored $HASH from $PEERID_0" mirror_out &&
+  grep "pinned $HASH" mirror_out &&
+  ipfsi 1 pin ls --type=recursive "$HASH"
+'
+
+test_expect_success 'the mirrored content is stored on node 1' '
+  ipfsi 1 cat "$HASH/sub/file" >actual &&
+  test_cmp dir/sub/file actual
+'
+
+test_expect_success 'mirror fails for content the peer does not have' '
+  MISSING=$(echo missing | ipfsi 1 add -q) &&
+  test_must_fail ipfsi 1 mirror "$PEERID_0" "$MISSING" 2>mirror_err &&
+  grep "incomplete mirror" mirror_err
+'
+
+test_expect_success 'mirror fails for a DAG larger than --max-size' '
+  test_must_fail ipfsi 1 mirror --max-size=1KiB "$PEERID_0" "$HASH" 2>mirror_err &&
+  grep "mirror larger than 1024 bytes" mirror_err
+'
+
+test_expect_success 'mirror fails for an invalid cid' '
+  test_must_fail ipfsi 1 mirror "$PEERID_0" notacid
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
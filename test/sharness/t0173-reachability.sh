@@ -0,0 +1,40 @@
+#!/usr/bin/env bash
+
+test_description="Test reachability command"
+
+. lib/test-lib.sh
+
+# start iptb + wait for peering
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb testbed create -type localipfs -count $NUM_NODES -init
+'
+
+test_expect_success 'enable the AutoNAT service on node 0' '
+  ipfsi 0 config --json Swarm.EnableAutoNATService true
+'
+
+startup_cluster $NUM_NODES
+
+test_expect_success 'the local addresses are not checked' '
+  ADDR_0=$(ipfsi 0 id -f "<addrs>" | grep 127.0.0.1 | grep tcp | head -1) &&
+  ipfsi 1 reachability test "$ADDR_0" >reach_out &&
+  grep "not a public address, not checked" reach_out &&
+  test_must_fail grep "^reachable" reach_out
+'
+
+test_expect_success 'reachability test fails for an invalid --expected-addr' '
+  test_must_fail ipfsi 1 reachability test --expected-addr notanaddr "$ADDR_0" 2>reach_err &&
+  grep "invalid --expected-addr" reach_err
+'
+
+test_expect_success 'reachability test fails without checker' '
+  test_must_fail ipfsi 0 reachability test 2>reach_err &&
+  grep "no peer runs the AutoNAT service" reach_err
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
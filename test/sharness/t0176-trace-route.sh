@@ -0,0 +1,48 @@
+#!/usr/bin/env bash
+
+test_description="Test trace route command"
+
+. lib/test-lib.sh
+
+# start iptb + wait for peering
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb testbed create -type localipfs -count $NUM_NODES -init
+'
+
+startup_cluster $NUM_NODES
+
+test_expect_success 'add content on node 0' '
+  PEERID_0=$(iptb attr get 0 id) &&
+  mkdir -p dir/sub &&
+  random 300000 42 >dir/sub/file &&
+  echo hello >dir/hello &&
+  HASH=$(ipfsi 0 add -rQ dir) &&
+  HELLO=$(ipfsi 0 add -Q dir/hello)
+'
+
+test_expect_success 'trace route fetches the DAG from node 0' '
+  ipfsi 1 trace route "$HASH" >trace_out &&
+  head -1 trace_out | grep "Source" &&
+  grep "$HASH" trace_out | grep "$PEERID_0" &&
+  ipfsi 1 cat "$HASH/sub/file" >actual &&
+  test_cmp dir/sub/file actual
+'
+
+test_expect_success 'the blocks already stored are local' '
+  ipfsi 1 trace route "$HELLO" >trace_out &&
+  grep "$HELLO" trace_out | grep "local"
+'
+
+test_expect_success 'trace route fails for missing blocks' '
+  MISSING=$(echo missing | ipfsi 0 add -q --only-hash) &&
+  test_must_fail ipfsi 1 trace route --timeout=2s "$MISSING" >trace_out 2>trace_err &&
+  grep "$MISSING" trace_out | grep "failed" &&
+  grep "1 blocks could not be fetched" trace_err
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
@@ -0,0 +1,57 @@
+#!/usr/bin/env bash
+
+test_description="Test experiment wasm-exec command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'add a file which is not a module' '
+  HASH=$(echo "not wasm" | ipfs add -Q)
+'
+
+test_expect_success 'wasm-exec is disabled by default' '
+  test_must_fail ipfs experiment wasm-exec "$HASH" 2>exec_err &&
+  grep "wasm-exec is not enabled" exec_err
+'
+
+test_expect_success 'graph walk is disabled by default' '
+  test_must_fail ipfs graph walk --visitor="$HASH" "$HASH" 2>walk_err &&
+  grep "wasm-exec is not enabled" walk_err
+'
+
+test_expect_success 'enable wasm-exec' '
+  ipfs config --json Experimental.WasmExec true
+'
+
+# the WASM runtime is only built with 'make wasmexec'
+ipfs experiment wasm-exec "$HASH" 2>&1 | grep -q "wasm-exec is not built" ||
+  test_set_prereq WASMEXEC
+
+test_expect_success !WASMEXEC 'wasm-exec needs the wasmexec build' '
+  test_must_fail ipfs experiment wasm-exec "$HASH" 2>exec_err &&
+  grep "make wasmexec" exec_err
+'
+
+test_expect_success WASMEXEC 'wasm-exec rejects invalid modules' '
+  test_must_fail ipfs experiment wasm-exec "$HASH" 2>exec_err &&
+  grep "invalid magic number" exec_err
+'
+
+test_expect_success WASMEXEC 'wasm-exec rejects directories' '
+  mkdir -p dir && echo a >dir/a &&
+  DIR=$(ipfs add -rQ dir) &&
+  test_must_fail ipfs experiment wasm-exec "$DIR"
+'
+
+test_expect_success WASMEXEC 'graph walk requires a visitor' '
+  test_must_fail ipfs graph walk "$HASH" 2>walk_err &&
+  grep "the --visitor option is required" walk_err
+'
+
+test_expect_success WASMEXEC 'graph walk rejects invalid visitors' '
+  test_must_fail ipfs graph walk --visitor="$HASH" "$HASH" 2>walk_err &&
+  grep "invalid visitor" walk_err
+'
+
+test_done
@@ -0,0 +1,41 @@
+#!/usr/bin/env bash
+
+test_description="Test storage quota command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'there is no quota by default' '
+  ipfs storage quota get >quota_out &&
+  grep "no quota" quota_out
+'
+
+test_expect_success 'set a quota just above the repo size' '
+  USAGE=$(ipfs storage quota get --enc=json | sed -e "s/.*\"Usage\":\([0-9]*\).*/\1/") &&
+  ipfs storage quota set $((USAGE + 300000)) &&
+  test "$(ipfs config Datastore.StorageQuota)" = "true"
+'
+
+test_expect_success 'add content within the quota' '
+  random 200000 1 >file1 &&
+  ipfs add -q file1
+'
+
+test_expect_success 'add fails once the quota is reached' '
+  random 200000 2 >file2 &&
+  test_must_fail ipfs add -q file2 2>add_err &&
+  grep "storage full" add_err
+'
+
+test_expect_success 'unset the quota' '
+  ipfs storage quota unset &&
+  ipfs add -q file2
+'
+
+test_expect_success 'set rejects invalid sizes' '
+  test_must_fail ipfs storage quota set foo &&
+  test_must_fail ipfs storage quota set 0
+'
+
+test_done
@@ -0,0 +1,37 @@
+#!/usr/bin/env bash
+
+test_description="Test perf benchmark command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'the suite is required' '
+  test_must_fail ipfs perf benchmark 2>perf_err &&
+  grep "the --suite option is required" perf_err
+'
+
+test_expect_success 'unknown suites are rejected' '
+  test_must_fail ipfs perf benchmark --suite=unknown
+'
+
+test_expect_success 'run add-cpu' '
+  ipfs perf benchmark --suite=add-cpu --size=1MiB >perf_out &&
+  grep "\"Suite\": \"add-cpu\"" perf_out &&
+  grep "\"Name\": \"add\"" perf_out &&
+  grep "\"Bytes\": 1048576" perf_out
+'
+
+test_expect_success 'dag-traversal removes its blocks' '
+  ipfs refs local | sort >refs_before &&
+  ipfs perf benchmark --suite=dag-traversal --size=1MiB >perf_out &&
+  grep "\"Name\": \"traverse\"" perf_out &&
+  ipfs refs local | sort >refs_after &&
+  test_cmp refs_before refs_after
+'
+
+test_expect_success 'network suites need the daemon' '
+  test_must_fail ipfs perf benchmark --suite=get-network --size=1MiB
+'
+
+test_done
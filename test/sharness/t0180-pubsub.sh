@@ -96,6 +96,80 @@ run_pubsub_tests() {
 
 startup_cluster $NUM_NODES --enable-pubsub-experiment
 run_pubsub_tests
+
+# Test the encodings of payloads and topics.
+
+test_expect_success 'subscribe with --base64' '
+  ipfsi 0 pubsub sub --count 1 --base64 --enc=ndpayload binTopic > b64_actual &
+  SUB_PID=$! &&
+  go-sleep 500ms
+'
+
+test_expect_success 'publish a binary payload with --base64' '
+  ipfsi 1 pubsub pub --base64 binTopic "/wBh" &&
+  wait $SUB_PID
+'
+
+test_expect_success 'the payload is received base64 encoded' '
+  echo "/wBh" > b64_expected &&
+  test_cmp b64_expected b64_actual
+'
+
+test_expect_success 'publish fails with an invalid base64 payload' '
+  test_must_fail ipfsi 1 pubsub pub --base64 binTopic "not base64!" 2> b64_err &&
+  grep "invalid base64 payload" b64_err
+'
+
+test_expect_success 'subscribe to a binary topic with --topic-encoding=base64' '
+  ipfsi 0 pubsub sub --count 1 --topic-encoding=base64 --enc=json /wBh > topic_actual &
+  SUB_PID=$! &&
+  go-sleep 500ms
+'
+
+test_expect_success 'the binary topic is listed base64 encoded' '
+  echo "/wBh" > topic_ls_expected &&
+  ipfsi 0 pubsub ls --topic-encoding=base64 > topic_ls_actual &&
+  test_cmp topic_ls_expected topic_ls_actual
+'
+
+test_expect_success 'publish to the binary topic' '
+  ipfsi 1 pubsub pub --topic-encoding=base64 /wBh "testOK" &&
+  wait $SUB_PID
+'
+
+test_expect_success 'the topic of the message is base64 encoded' '
+  grep "\"topicIDs\":\[\"/wBh\"\]" topic_actual
+'
+
+test_expect_success 'an unknown topic encoding is refused' '
+  test_must_fail ipfsi 1 pubsub pub --topic-encoding=hex binTopic "testOK"
+'
+
+# Test exiting after a number of messages.
+
+test_expect_success 'subscribe with --count 2' '
+  ipfsi 0 pubsub sub --count 2 --enc=ndpayload countTopic > count_actual &
+  SUB_PID=$! &&
+  go-sleep 500ms
+'
+
+test_expect_success 'the sub exits once two messages are received' '
+  ipfsi 1 pubsub pub countTopic "one" &&
+  ipfsi 1 pubsub pub countTopic "two" &&
+  wait $SUB_PID &&
+  printf "one\ntwo\n" > count_expected &&
+  test_cmp count_expected count_actual
+'
+
+test_expect_success 'sub --count fails when the messages do not come within --timeout' '
+  test_must_fail ipfsi 0 pubsub sub --count 1 --timeout 1s countTopic 2> count_err &&
+  grep "did not complete within 1s" count_err
+'
+
+test_expect_success 'a negative --count is refused' '
+  test_must_fail ipfsi 0 pubsub sub --count -1 countTopic
+'
+
 test_expect_success 'stop iptb' '
   iptb stop
 '
@@ -64,6 +64,23 @@ test_expect_success 'peers for B look good' '
   test_cmp peers_exp peers_out
 '
 
+test_expect_success 'relay circuits for A look good' '
+  ipfsi 0 swarm relay ls > relay_out &&
+  echo "$PEERID_1 $PEERID_2 outbound" > relay_exp &&
+  test_cmp relay_exp relay_out
+'
+
+test_expect_success 'relay circuits for B look good' '
+  ipfsi 2 swarm relay ls > relay_out &&
+  echo "$PEERID_1 $PEERID_0 inbound" > relay_exp &&
+  test_cmp relay_exp relay_out
+'
+
+test_expect_success 'the relay has no circuit of its own' '
+  ipfsi 1 swarm relay ls > relay_out &&
+  test_must_be_empty relay_out
+'
+
 test_expect_success 'add an object in A' '
   echo "hello relay" | ipfsi 0 add > peers_out
 '
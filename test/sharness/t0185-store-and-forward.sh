@@ -0,0 +1,70 @@
+#!/usr/bin/env bash
+
+test_description="Test store-and-forward command"
+
+. lib/test-lib.sh
+
+# start iptb + wait for peering
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb testbed create -type localipfs -count $NUM_NODES -init
+'
+
+startup_cluster $NUM_NODES --enable-pubsub-experiment
+
+test_expect_success 'subscribe on node 0' '
+  IPFS_PATH="$IPTB_ROOT/testbeds/default/0" ipfs store-and-forward subscribe testTopic > actual &
+  SUB_PID=$!
+'
+
+test_expect_success "wait until the subscription is ready" '
+  go-sleep 500ms
+'
+
+test_expect_success 'publish from node 1' '
+  ipfsi 1 store-and-forward publish testTopic "testOK" > pub_out &&
+  CID=$(cut -d" " -f2 < pub_out) &&
+  printf "testOK" | ipfsi 1 block put --format=raw > cid_exp &&
+  echo $CID > cid_out &&
+  test_cmp cid_exp cid_out
+'
+
+test_expect_success 'the message block is pinned' '
+  ipfsi 1 pin ls --type=recursive $CID
+'
+
+test_expect_success 'republish the message and publish another one' '
+  go-sleep 500ms &&
+  ipfsi 1 store-and-forward publish testTopic "testOK" &&
+  echo "testOK2" | ipfsi 1 store-and-forward publish testTopic
+'
+
+test_expect_success 'node 0 got each message once' '
+  go-sleep 500ms &&
+  kill $SUB_PID &&
+  { wait $SUB_PID || true; } &&
+  printf "testOK\ntestOK2\n" > expected &&
+  test_cmp expected actual
+'
+
+test_expect_success 'subscribe again on node 0' '
+  IPFS_PATH="$IPTB_ROOT/testbeds/default/0" ipfs store-and-forward subscribe testTopic > actual2 &
+  SUB_PID=$! &&
+  go-sleep 500ms
+'
+
+test_expect_success 'processed messages are skipped' '
+  ipfsi 1 store-and-forward publish testTopic "testOK" &&
+  ipfsi 1 store-and-forward publish testTopic "testOK3" &&
+  go-sleep 500ms &&
+  kill $SUB_PID &&
+  { wait $SUB_PID || true; } &&
+  echo "testOK3" > expected2 &&
+  test_cmp expected2 actual2
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
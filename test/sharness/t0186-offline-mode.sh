@@ -0,0 +1,65 @@
+#!/usr/bin/env bash
+
+test_description="Test offline-mode command"
+
+. lib/test-lib.sh
+
+# start iptb + wait for peering
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb testbed create -type localipfs -count $NUM_NODES -init
+'
+
+startup_cluster $NUM_NODES
+
+test_expect_success 'enable offline mode on node 0' '
+  ipfsi 0 offline-mode enable
+'
+
+test_expect_success 'node 0 has no peers' '
+  ipfsi 0 swarm peers >peers_out &&
+  test_must_be_empty peers_out
+'
+
+test_expect_success 'node 1 cannot connect to node 0' '
+  test_must_fail iptb connect --timeout=2s 1 0
+'
+
+test_expect_success 'node 0 cannot connect to node 1' '
+  test_must_fail iptb connect --timeout=2s 0 1
+'
+
+test_expect_success 'node 0 fails at once to fetch remote content' '
+  HASH_REMOTE=$(echo "remote content" | ipfsi 1 add -q) &&
+  test_must_fail ipfsi 0 cat $HASH_REMOTE 2>cat_err &&
+  grep "offline mode" cat_err
+'
+
+test_expect_success 'node 0 still serves its local content' '
+  HASH=$(echo "offline mode" | ipfsi 0 add -q) &&
+  echo "offline mode" >expected &&
+  ipfsi 0 cat $HASH >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success 'disable offline mode on node 0' '
+  ipfsi 0 offline-mode disable &&
+  ipfsi 0 swarm filters >filters_out &&
+  test_must_be_empty filters_out
+'
+
+test_expect_success 'node 1 can connect to node 0 again' '
+  iptb connect 1 0 &&
+  ipfsi 1 cat $HASH >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_expect_success 'offline mode needs a running daemon' '
+  test_must_fail ipfsi 0 offline-mode enable
+'
+
+test_done
@@ -0,0 +1,57 @@
+#!/usr/bin/env bash
+
+test_description="Test export-state command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'add a remote pinning service with a key' '
+  ipfs config --json Pinning.RemoteServices "{\"svc\": {\"API\": {\"Endpoint\": \"https://pin.example\", \"Key\": \"secret-key\"}}}"
+'
+
+test_expect_success 'export-state succeeds offline' '
+  ipfs export-state state >export_out
+'
+
+test_expect_success 'export-state writes the files and the bundle' '
+  for f in id.json config.json swarm-peers.json bitswap-stat.json stats-bw.json repo-stat.json diag-sys.json daemon.log; do
+    test -f "state/$f" || return 1
+  done &&
+  tar tzf state.tar.gz >bundle_out &&
+  grep "^state/id.json$" bundle_out &&
+  grep "^state/daemon.log$" bundle_out
+'
+
+test_expect_success 'export-state redacts the config' '
+  grep "pin.example" state/config.json &&
+  grep "REDACTED" state/config.json &&
+  test_must_fail grep "secret-key" state/config.json &&
+  test_must_fail grep "PrivKey" state/config.json
+'
+
+test_expect_success 'failing commands have their error exported' '
+  grep "Error" state/swarm-peers.json
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success 'export-state exports the daemon log' '
+  ipfs log output "$(pwd)/daemon_log" &&
+  ipfs export-state state2 >export_out &&
+  grep "Bundle: $(pwd)/state2.tar.gz" export_out &&
+  grep "Peers" state2/swarm-peers.json &&
+  test_must_fail grep "ipfs log output" state2/daemon.log
+'
+
+test_expect_success 'export-state writes the files on the client side' '
+  mkdir sub &&
+  (cd sub && ipfs export-state state3 >../export_out) &&
+  test -f sub/state3/id.json &&
+  tar tzf sub/state3.tar.gz >bundle_out &&
+  grep "^state3/id.json$" bundle_out
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,43 @@
+#!/usr/bin/env bash
+
+test_description="Test watch command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'watch rejects an invalid interval' '
+  test_must_fail ipfs watch --interval=0s /docs 2>watch_err &&
+  grep "invalid interval" watch_err
+'
+
+test_expect_success 'watch fails on a path that does not exist' '
+  test_must_fail ipfs watch /docs
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success 'watch prints the current CID of an MFS path' '
+  ipfs files mkdir /docs &&
+  ipfs files stat --hash /docs >expected &&
+  test_expect_code 124 timeout 2 ipfs watch /docs >actual &&
+  head -n 1 actual >first &&
+  test_cmp expected first
+'
+
+test_expect_success 'start watching an MFS path with a command' '
+  (timeout 10 ipfs watch --interval=200ms --debounce=1s /docs -- sh -c "echo \$IPFS_CID >>runs" &) &&
+  sleep 1
+'
+
+test_expect_success 'changes run the command once debounced' '
+  echo a | ipfs files write --create /docs/a &&
+  echo b | ipfs files write --create /docs/b &&
+  ipfs files stat --hash /docs >expected &&
+  sleep 3 &&
+  test_cmp expected runs
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,44 @@
+#!/usr/bin/env bash
+
+test_description="Test checkpoint command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'create an MFS directory' '
+  ipfs files mkdir /docs &&
+  echo "checkpoint" | ipfs files write --create /docs/file &&
+  DOCS=$(ipfs files stat --hash /docs) &&
+  PEERID=$(ipfs config Identity.PeerID)
+'
+
+test_expect_success 'checkpoint fails offline without --allow-offline' '
+  test_must_fail ipfs checkpoint /docs 2>checkpoint_err &&
+  grep "allow-offline" checkpoint_err
+'
+
+test_expect_success 'checkpoint publishes the MFS path' '
+  ipfs checkpoint --allow-offline /docs >actual &&
+  echo "Published /ipfs/$DOCS to $PEERID" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success 'the name resolves to the checkpoint' '
+  ipfs name resolve --offline >actual &&
+  echo "/ipfs/$DOCS" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success 'checkpoint publishes with another key' '
+  KEYID=$(ipfs key gen --type=ed25519 backups) &&
+  ipfs checkpoint --allow-offline --key=backups /docs >actual &&
+  echo "Published /ipfs/$DOCS to $KEYID" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success 'checkpoint fails on a missing path' '
+  test_must_fail ipfs checkpoint --allow-offline /missing
+'
+
+test_done
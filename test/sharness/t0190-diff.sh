@@ -0,0 +1,53 @@
+#!/usr/bin/env bash
+
+test_description="Test diff command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'add two directory trees' '
+  mkdir -p a/docs a/same b/docs b/same b/newdir &&
+  echo old >a/old.txt &&
+  echo readme >a/docs/readme.md &&
+  echo "longer readme" >b/docs/readme.md &&
+  echo new >b/docs/new.txt &&
+  echo same >a/same/file &&
+  echo same >b/same/file &&
+  echo z >b/newdir/z &&
+  A=$(ipfs add -rQ a) &&
+  B=$(ipfs add -rQ b)
+'
+
+test_expect_success 'diff reports the changed paths' '
+  ipfs diff $A $B >actual &&
+  cat >expected <<-\EOF &&
+	 docs/new.txt   | added, 4 B
+	 docs/readme.md | modified, 7 B -> 14 B (+7 B)
+	 newdir         | added, directory
+	 old.txt        | removed, 4 B
+	4 paths changed: 2 added, 1 removed, 1 modified (+7 B)
+	EOF
+  test_cmp expected actual
+'
+
+test_expect_success 'diff of identical DAGs is empty' '
+  ipfs diff $A /ipfs/$A >actual &&
+  echo "no changes" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success 'diff compares two files' '
+  ipfs diff /ipfs/$A/docs/readme.md /ipfs/$B/docs/readme.md >actual &&
+  cat >expected <<-\EOF &&
+	 . | modified, 7 B -> 14 B (+7 B)
+	1 path changed: 0 added, 0 removed, 1 modified (+7 B)
+	EOF
+  test_cmp expected actual
+'
+
+test_expect_success 'diff fails on an invalid path' '
+  test_must_fail ipfs diff $A not-a-cid
+'
+
+test_done
@@ -0,0 +1,55 @@
+#!/usr/bin/env bash
+
+test_description="Test gc schedule command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'there is no GC schedule by default' '
+  ipfs gc schedule show >actual &&
+  echo "No GC schedule" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success 'gc schedule rejects invalid values' '
+  test_must_fail ipfs gc schedule --interval=0s &&
+  test_must_fail ipfs gc schedule --at=25:00 &&
+  test_must_fail ipfs gc schedule --min-freed=lots
+'
+
+test_expect_success 'gc schedule sets the schedule' '
+  ipfs gc schedule --at=02:00 --min-freed=1GiB &&
+  ipfs config Datastore.GCSchedule.At >actual &&
+  echo "02:00" >expected &&
+  test_cmp expected actual &&
+  ipfs gc schedule show >actual &&
+  printf "Interval:  24h\nAt:        02:00\nMin freed: 1GiB\n" >expected &&
+  test_cmp expected actual
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success 'the daemon runs the schedule' '
+  ipfs gc schedule show >actual &&
+  grep "Next GC:" actual
+'
+
+test_expect_success 'a scheduled GC removes the unpinned blocks' '
+  HASH=$(echo "collect me" | ipfs add -q --pin=false) &&
+  ipfs gc schedule --interval=1s &&
+  sleep 3 &&
+  ipfs refs local >refs &&
+  test_must_fail grep "$HASH" refs
+'
+
+test_expect_success 'gc schedule disable removes the schedule' '
+  ipfs gc schedule disable &&
+  ipfs gc schedule show >actual &&
+  echo "No GC schedule" >expected &&
+  test_cmp expected actual
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,39 @@
+#!/usr/bin/env bash
+
+test_description="Test content-type detect command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_content_type() {
+  test_expect_success "content-type detect $1" '
+    ipfs content-type detect '"$2"' >actual &&
+    echo "'"$3"'" >expected &&
+    test_cmp expected actual
+  '
+}
+
+test_expect_success 'add content of several types' '
+  echo "<html><body>hello</body></html>" >page.html &&
+  printf "\211PNG\r\n\032\n" >image.png &&
+  random 100000 42 >>image.png &&
+  mkdir dir &&
+  HTML=$(ipfs add -q page.html) &&
+  PNG=$(ipfs add -q image.png) &&
+  PNG_RAW=$(ipfs add -q --raw-leaves image.png) &&
+  DIR=$(ipfs add -rQ dir) &&
+  CBOR=$(echo "{\"a\": 1}" | ipfs dag put)
+'
+
+test_content_type "html" '$HTML' "text/html; charset=utf-8"
+test_content_type "png" '/ipfs/$PNG' "image/png"
+test_content_type "png with raw leaves" '$PNG_RAW' "image/png"
+test_content_type "directory" '$DIR' "inode/directory"
+test_content_type "cbor" '$CBOR' "application/cbor"
+
+test_expect_success 'content-type detect fails on an invalid path' '
+  test_must_fail ipfs content-type detect not-a-cid
+'
+
+test_done
@@ -0,0 +1,43 @@
+#!/usr/bin/env bash
+
+test_description="Test search local command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'add a directory with named files' '
+  mkdir -p dir/photos &&
+  echo beach >dir/photos/holiday-beach.jpg &&
+  echo notes >dir/photos/holiday.txt &&
+  echo report >dir/report.pdf &&
+  DIR=$(ipfs add -rQ dir) &&
+  BEACH=$(ipfs add -q dir/photos/holiday-beach.jpg) &&
+  NOTES=$(ipfs add -q dir/photos/holiday.txt)
+'
+
+test_expect_success 'search local finds the best match first' '
+  ipfs search local "Holiday beach" >actual &&
+  echo "$BEACH  /ipfs/$DIR/photos/holiday-beach.jpg  2" >expected &&
+  echo "$NOTES  /ipfs/$DIR/photos/holiday.txt  1" >>expected &&
+  test_cmp expected actual
+'
+
+test_expect_success 'search local prints nothing without a match' '
+  ipfs search local "nothing" >actual &&
+  test_must_be_empty actual
+'
+
+test_expect_success 'unpinned directories are not searched' '
+  ipfs pin rm "$DIR" &&
+  ipfs search local holiday >actual &&
+  test_must_be_empty actual
+'
+
+test_expect_success 'pinned directories are indexed again' '
+  ipfs pin add "$DIR" &&
+  ipfs search local report >actual &&
+  grep "/ipfs/$DIR/report.pdf" actual
+'
+
+test_done
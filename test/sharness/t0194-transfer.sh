@@ -0,0 +1,71 @@
+#!/usr/bin/env bash
+
+test_description="Test transfer command"
+
+. lib/test-lib.sh
+
+# start iptb + wait for peering
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb testbed create -type localipfs -count $NUM_NODES -init
+'
+
+startup_cluster $NUM_NODES
+
+test_expect_success 'add content on node 0' '
+  PEERID_1=$(iptb attr get 1 id) &&
+  mkdir -p dir/sub &&
+  random 300000 41 >dir/sub/file &&
+  echo hello >dir/hello &&
+  HASH=$(ipfsi 0 add -rQ dir)
+'
+
+test_expect_success 'push fails when the peer is not receiving' '
+  test_must_fail ipfsi 0 transfer push "$PEERID_1" "$HASH" 2>push_err &&
+  grep "not receiving transfers" push_err
+'
+
+test_expect_success 'receive refuses to accept the transfers of any peer' '
+  test_must_fail ipfsi 1 transfer receive 2>receive_err &&
+  grep "refusing to accept the transfers of any peer" receive_err
+'
+
+test_expect_success 'start receiving on node 1' '
+  (timeout 20 ipfsi 1 transfer receive --require-auth=secret >received &) &&
+  go-sleep 1s
+'
+
+test_expect_success 'only one receive runs at a time' '
+  test_must_fail ipfsi 1 transfer receive --allow "$(iptb attr get 0 id)" 2>receive_err &&
+  grep "already receiving transfers" receive_err
+'
+
+test_expect_success 'push without the secret is rejected' '
+  test_must_fail ipfsi 0 transfer push "$PEERID_1" "$HASH" 2>push_err &&
+  grep "transfer not authorized" push_err &&
+  test_must_fail ipfsi 1 pin ls "$HASH"
+'
+
+test_expect_success 'push with the secret succeeds' '
+  ipfsi 0 transfer push --auth=secret "$PEERID_1" "$HASH" >push_out &&
+  grep "pushed $HASH to $PEERID_1" push_out
+'
+
+test_expect_success 'the pushed content is pinned on node 1' '
+  ipfsi 1 pin ls --type=recursive "$HASH" &&
+  ipfsi 1 cat "$HASH/sub/file" >actual &&
+  test_cmp dir/sub/file actual &&
+  grep "rejected $HASH" received &&
+  grep "received $HASH" received
+'
+
+test_expect_success 'push fails for content not stored locally' '
+  MISSING=$(echo missing | ipfsi 1 add -q) &&
+  test_must_fail ipfsi 0 transfer push --auth=secret "$PEERID_1" "$MISSING"
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
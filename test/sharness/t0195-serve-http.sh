@@ -0,0 +1,85 @@
+#!/usr/bin/env bash
+
+test_description="Test serve http command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+PORT=10195
+ADDR="127.0.0.1:$PORT"
+
+serve_http() {
+  ipfs serve http --addr "$ADDR" "$1" >serve_out &
+  SERVE_PID=$! &&
+  test_wait_output_n_lines_60_sec serve_out 1
+}
+
+stop_serve() {
+  kill "$SERVE_PID" && wait "$SERVE_PID"
+  true
+}
+
+test_expect_success 'add a site' '
+  mkdir -p site/css site/notes &&
+  echo "<h1>home</h1>" >site/index.html &&
+  echo "body {}" >site/css/style.css &&
+  echo "first" >site/notes/first.txt &&
+  SITE=$(ipfs add -rQ site)
+'
+
+test_expect_success 'serve http serves a CID' '
+  serve_http "$SITE" &&
+  grep "Serving $SITE at http://$ADDR" serve_out
+'
+
+test_expect_success 'directories are served with their index.html' '
+  curl -sf "http://$ADDR/" >actual &&
+  test_cmp site/index.html actual
+'
+
+test_expect_success 'directories without index.html are listed' '
+  curl -sf "http://$ADDR/notes/" >actual &&
+  grep "first.txt" actual
+'
+
+test_expect_success 'files are served with their MIME type' '
+  curl -sfi "http://$ADDR/css/style.css" >actual &&
+  grep -i "^Content-Type: text/css" actual
+'
+
+test_expect_success 'missing files are not found' '
+  test_expect_code 22 curl -sf "http://$ADDR/missing.html"
+'
+
+test_expect_success 'no CORS headers are sent' '
+  curl -sfi -H "Origin: http://example.com" "http://$ADDR/" >actual &&
+  test_must_fail grep -i "Access-Control" actual
+'
+
+test_expect_success 'stop serving' '
+  stop_serve
+'
+
+# the daemon lets 'ipfs files' change MFS while it is served
+test_launch_ipfs_daemon
+
+test_expect_success 'serve http serves the current content of an MFS path' '
+  ipfs files cp "/ipfs/$SITE" /site &&
+  serve_http /site &&
+  echo "<h1>changed</h1>" | ipfs files write --truncate /site/index.html &&
+  curl -sf "http://$ADDR/" >actual &&
+  echo "<h1>changed</h1>" >expected &&
+  test_cmp expected actual &&
+  stop_serve
+'
+
+test_expect_success 'serve http refuses IPNS names' '
+  PEERID=$(ipfs config Identity.PeerID) &&
+  test_must_fail ipfs serve http --addr "$ADDR" "/ipns/$PEERID" 2>err &&
+  grep "IPNS names are not resolved" err
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,74 @@
+#!/usr/bin/env bash
+
+test_description="Test pack command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'create a directory with an .ipfsignore' '
+  mkdir -p src/docs src/build &&
+  echo "a" >src/a.txt &&
+  echo "b" >src/b.log &&
+  echo "keep" >src/keep.log &&
+  echo "secret" >src/.secret &&
+  echo "out" >src/build/out &&
+  echo "d" >src/docs/d.txt &&
+  printf "*.log\n!keep.log\nbuild/\n" >src/.ipfsignore
+'
+
+test_expect_success 'pack fails with an unknown strategy' '
+  test_must_fail ipfs pack --strategy=rar src 2>err &&
+  grep "unknown strategy" err
+'
+
+test_expect_success 'pack fails on a file' '
+  test_must_fail ipfs pack src/a.txt 2>err &&
+  grep "not a directory" err
+'
+
+test_expect_success 'pack --strategy=tar writes a tar archive' '
+  ipfs pack --strategy=tar src >out &&
+  grep "to $(pwd)/src.tar" out &&
+  tar tf src.tar | sort >actual &&
+  printf "src\nsrc/a.txt\nsrc/docs\nsrc/docs/d.txt\nsrc/keep.log\n" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success 'pack pins the directory' '
+  ROOT=$(cut -d" " -f2 out) &&
+  ipfs pin ls --type=recursive $ROOT
+'
+
+test_expect_success 'pack --hidden includes the hidden files' '
+  ipfs pack --strategy=tar --hidden -o hidden.tar src &&
+  tar tf hidden.tar | sort >actual &&
+  printf "src\nsrc/.ipfsignore\nsrc/.secret\nsrc/a.txt\nsrc/docs\nsrc/docs/d.txt\nsrc/keep.log\n" >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success 'pack --strategy=zip writes a zip archive' '
+  ipfs pack --strategy=zip src &&
+  mkdir unzipped &&
+  (cd unzipped && unzip -q ../src.zip) &&
+  test_cmp src/docs/d.txt unzipped/src/docs/d.txt &&
+  test_path_is_missing unzipped/src/b.log
+'
+
+test_expect_success 'the comment of the zip archive is the root CID' '
+  unzip -z src.zip >comment &&
+  grep "$ROOT" comment
+'
+
+test_expect_success 'pack writes a car file by default' '
+  ipfs pack src >out &&
+  ROOT=$(cut -d" " -f2 out) &&
+  test -s src.car
+'
+
+test_expect_success 'the car file can be imported' '
+  ipfs dag import src.car >out &&
+  grep "root $ROOT" out
+'
+
+test_done
@@ -0,0 +1,58 @@
+#!/usr/bin/env bash
+
+test_description="Test content verify command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'add some content' '
+  echo "pinned content" >pinned &&
+  echo "raw content to break" >raw &&
+  echo "unpinned content" >unpinned &&
+  PINNED=$(ipfs add -Q pinned) &&
+  RAW=$(ipfs add -Q --raw-leaves --cid-version=1 raw) &&
+  UNPINNED=$(ipfs add -Q --pin=false unpinned)
+'
+
+test_expect_success 'content verify passes for pinned content' '
+  echo "[{\"cid\": \"$PINNED\", \"description\": \"pinned file\"}, {\"cid\": \"$RAW\", \"size\": 21}]" >set.json &&
+  ipfs content verify --pin-set set.json >actual &&
+  grep "^$PINNED *pass *1 .*pinned file$" actual &&
+  grep "^$RAW *pass *1 *21" actual
+'
+
+test_expect_success 'content verify fails for unpinned content' '
+  echo "[{\"cid\": \"$PINNED\"}, {\"cid\": \"$UNPINNED\"}]" >set.json &&
+  test_expect_code 1 ipfs content verify --pin-set set.json >actual 2>err &&
+  grep "^$PINNED *pass" actual &&
+  grep "^$UNPINNED *fail: not pinned" actual &&
+  grep "1 of 2 CIDs failed the verification" err
+'
+
+test_expect_success 'content verify checks the size' '
+  echo "[{\"cid\": \"$RAW\", \"size\": 1000}]" >set.json &&
+  test_expect_code 1 ipfs content verify --pin-set set.json >actual &&
+  grep "fail: size is 21, expected 1000" actual
+'
+
+test_expect_success 'content verify detects corrupted blocks' '
+  to_break=$(grep -rl "raw content to break" "$IPFS_PATH/blocks") &&
+  cp "$to_break" backup_block &&
+  echo "this is super broken" >"$to_break" &&
+  echo "[{\"cid\": \"$RAW\"}]" >set.json &&
+  test_expect_code 1 ipfs content verify --pin-set set.json >actual &&
+  grep "fail: block $RAW corrupted" actual &&
+  rm "$to_break" &&
+  test_expect_code 1 ipfs content verify --pin-set set.json >actual &&
+  grep "fail: block $RAW missing" actual &&
+  cp backup_block "$to_break" &&
+  ipfs content verify --pin-set set.json
+'
+
+test_expect_success 'content verify requires a pin set' '
+  test_must_fail ipfs content verify 2>err &&
+  grep "the --pin-set option is required" err
+'
+
+test_done
@@ -0,0 +1,55 @@
+#!/usr/bin/env bash
+
+test_description="Test secure-channel command"
+
+. lib/test-lib.sh
+
+# start iptb + wait for peering
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb testbed create -type localipfs -count $NUM_NODES -init
+'
+
+startup_cluster $NUM_NODES
+
+test_expect_success 'open fails when the peer is not accepting' '
+  PEERID_0=$(iptb attr get 0 id) &&
+  PEERID_1=$(iptb attr get 1 id) &&
+  test_must_fail ipfsi 0 secure-channel open "$PEERID_1" </dev/null 2>open_err &&
+  grep "failed to open a secure channel to $PEERID_1" open_err
+'
+
+test_expect_success 'start accepting on node 1' '
+  random 300000 42 >sent &&
+  echo reply >reply &&
+  (timeout 20 ipfsi 1 secure-channel accept --allow "$PEERID_0" <reply >received 2>accept_err &) &&
+  go-sleep 1s
+'
+
+test_expect_success 'only one accept runs at a time' '
+  test_must_fail ipfsi 1 secure-channel accept </dev/null
+'
+
+test_expect_success 'open forwards stdin and stdout' '
+  ipfsi 0 secure-channel open "$PEERID_1" <sent >replied 2>open_err &&
+  test_cmp reply replied &&
+  grep "secure channel with $PEERID_1 open" open_err
+'
+
+test_expect_success 'the peer received the data' '
+  go-sleep 1s &&
+  test_cmp sent received &&
+  grep "secure channel with $PEERID_0 open" accept_err
+'
+
+test_expect_success 'accept refuses the peers not allowed' '
+  (timeout 5 ipfsi 1 secure-channel accept --allow "$PEERID_1" </dev/null >/dev/null 2>&1 &) &&
+  go-sleep 1s &&
+  test_must_fail ipfsi 0 secure-channel open "$PEERID_1" </dev/null
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
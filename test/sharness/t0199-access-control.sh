@@ -0,0 +1,80 @@
+#!/usr/bin/env bash
+
+test_description="Test access-control command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success 'add content' '
+  mkdir -p dir &&
+  random 300000 43 >dir/file &&
+  HASH=$(ipfs add -rQ dir) &&
+  FILE=$(ipfs resolve -r "/ipfs/$HASH/file" | cut -d/ -f3) &&
+  PEER=$(ipfs key gen --type=ed25519 allowed)
+'
+
+test_expect_success 'access-control add requires --allow' '
+  test_must_fail ipfs access-control add "$HASH" 2>add_err &&
+  grep "the --allow option is required" add_err
+'
+
+test_expect_success 'access-control add creates an access list' '
+  echo "$HASH $PEER" >expected &&
+  ipfs access-control add --allow="$PEER" "$HASH" >actual &&
+  test_cmp expected actual &&
+  ipfs access-control list >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success 'access-control add -r covers the blocks of the DAG' '
+  ipfs access-control add -r --allow="$PEER" "$HASH" >actual &&
+  grep "^$FILE $PEER" actual &&
+  test $(ipfs access-control list | wc -l) -gt 2
+'
+
+test_expect_success 'access-control remove --allow removes a peer' '
+  echo "removed $PEER from the access list of $HASH" >expected &&
+  ipfs access-control remove --allow="$PEER" "$HASH" >actual &&
+  test_cmp expected actual &&
+  ipfs access-control list | grep "^$HASH\$"
+'
+
+test_expect_success 'access-control remove -r removes the access lists' '
+  ipfs access-control remove -r "$HASH" >actual &&
+  grep "removed the access list of $HASH" actual &&
+  ipfs access-control list >actual &&
+  test_must_be_empty actual
+'
+
+# start iptb + wait for peering
+NUM_NODES=2
+test_expect_success 'init iptb' '
+  iptb testbed create -type localipfs -count $NUM_NODES -init
+'
+
+startup_cluster $NUM_NODES
+
+test_expect_success 'restrict content on node 0' '
+  PEERID_1=$(iptb attr get 1 id) &&
+  OTHER=$(ipfsi 0 key gen --type=ed25519 other) &&
+  echo restricted >restricted &&
+  RHASH=$(ipfsi 0 add -q restricted) &&
+  ipfsi 0 access-control add --allow="$OTHER" "$RHASH"
+'
+
+test_expect_success 'node 1 is not sent the restricted block' '
+  test_must_fail ipfsi 1 block get --timeout=5s "$RHASH"
+'
+
+test_expect_success 'node 1 gets the block once allowed' '
+  ipfsi 0 access-control add --allow="$PEERID_1" "$RHASH" &&
+  ipfsi 1 cat "$RHASH" >actual &&
+  test_cmp restricted actual
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
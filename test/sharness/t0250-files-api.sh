@@ -519,6 +519,12 @@ test_files_api() {
     ipfs files flush /
   '
 
+  test_expect_success "flush --all prints the root hash $EXTRA" '
+    ipfs files flush --all > flush_out &&
+    ipfs files stat --hash / > flush_exp &&
+    test_cmp flush_exp flush_out
+  '
+
   # test mv
   test_expect_success "can mv dir $EXTRA" '
     ipfs files mv /cats/this/is /cats/
@@ -677,6 +683,141 @@ test_files_api() {
     ipfs files rm --force /forcibly-dir &&
     verify_dir_contents /
   '
+
+  # test symlink
+
+  test_expect_success "can create a symlink $EXTRA" '
+    echo "link me" | ipfs files write $ARGS $RAW_LEAVES --create /symtarget &&
+    ipfs files symlink /symtarget /symlink &&
+    verify_dir_contents / symlink symtarget &&
+    ipfs files stat /symlink | grep -q "^Type: symlink"
+  '
+
+  test_expect_success "cannot create a dangling symlink $EXTRA" '
+    test_must_fail ipfs files symlink /nonexistent /dangling
+  '
+
+  test_expect_success "can create a dangling symlink with --allow-dangling $EXTRA" '
+    ipfs files symlink --allow-dangling /nonexistent /dangling &&
+    verify_dir_contents / dangling symlink symtarget
+  '
+
+  test_expect_success "cannot overwrite with a symlink $EXTRA" '
+    test_must_fail ipfs files symlink /symtarget /symlink
+  '
+
+  test_expect_success "cleanup symlinks $EXTRA" '
+    ipfs files rm /symlink &&
+    ipfs files rm /dangling &&
+    ipfs files rm /symtarget &&
+    verify_dir_contents /
+  '
+
+  # test mv --no-overwrite
+
+  test_expect_success "create files to move $EXTRA" '
+    echo "src" | ipfs files write $ARGS $RAW_LEAVES --create /mvsrc &&
+    echo "dst" | ipfs files write $ARGS $RAW_LEAVES --create /mvdst
+  '
+
+  test_expect_success "mv --no-overwrite refuses to overwrite $EXTRA" '
+    test_must_fail ipfs files mv --no-overwrite /mvsrc /mvdst &&
+    test_must_fail ipfs files mv -n /mvsrc /mvdst
+  '
+
+  test_expect_success "move refuses to overwrite by default $EXTRA" '
+    test_must_fail ipfs files move /mvsrc /mvdst &&
+    echo "dst" > mvdst_exp &&
+    ipfs files read /mvdst > mvdst_out &&
+    test_cmp mvdst_exp mvdst_out
+  '
+
+  test_expect_success "move can overwrite when asked $EXTRA" '
+    ipfs files move --no-overwrite=false /mvsrc /mvdst &&
+    echo "src" > mvdst_exp &&
+    ipfs files read /mvdst > mvdst_out &&
+    test_cmp mvdst_exp mvdst_out &&
+    verify_dir_contents / mvdst
+  '
+
+  test_expect_success "cleanup mv --no-overwrite $EXTRA" '
+    ipfs files rm /mvdst &&
+    verify_dir_contents /
+  '
+
+  # test find
+
+  test_expect_success "create files to find $EXTRA" '
+    ipfs files mkdir $ARGS -p /findme/sub &&
+    echo "same" | ipfs files write $ARGS $RAW_LEAVES --create /findme/a.txt &&
+    echo "same" | ipfs files write $ARGS $RAW_LEAVES --create /findme/sub/b.txt &&
+    echo "other" | ipfs files write $ARGS $RAW_LEAVES --create /findme/sub/c.dat
+  '
+
+  test_expect_success "find lists everything depth-first $EXTRA" '
+    printf "/findme\n/findme/a.txt\n/findme/sub\n/findme/sub/b.txt\n/findme/sub/c.dat\n" > find_exp &&
+    ipfs files find /findme > find_out &&
+    test_cmp find_exp find_out
+  '
+
+  test_expect_success "find --name works $EXTRA" '
+    printf "/findme/a.txt\n/findme/sub/b.txt\n" > find_exp &&
+    ipfs files find /findme --name "*.txt" > find_out &&
+    test_cmp find_exp find_out
+  '
+
+  test_expect_success "find --type works $EXTRA" '
+    printf "/findme\n/findme/sub\n" > find_exp &&
+    ipfs files find /findme --type dir > find_out &&
+    test_cmp find_exp find_out
+  '
+
+  test_expect_success "find --cid works $EXTRA" '
+    printf "/findme/a.txt\n/findme/sub/b.txt\n" > find_exp &&
+    ipfs files find /findme --cid "$(ipfs files stat --hash /findme/a.txt)" > find_out &&
+    test_cmp find_exp find_out
+  '
+
+  test_expect_success "cleanup find $EXTRA" '
+    ipfs files rm -r /findme &&
+    verify_dir_contents /
+  '
+
+  # test diff
+
+  test_expect_success "create directories to diff $EXTRA" '
+    ipfs files mkdir $ARGS -p /diffa/sub &&
+    echo "same" | ipfs files write $ARGS $RAW_LEAVES --create /diffa/same &&
+    echo "gone" | ipfs files write $ARGS $RAW_LEAVES --create /diffa/gone &&
+    echo "before" | ipfs files write $ARGS $RAW_LEAVES --create /diffa/sub/edit &&
+    ipfs files cp /diffa /diffb &&
+    ipfs files rm /diffb/gone &&
+    echo "new" | ipfs files write $ARGS $RAW_LEAVES --create /diffb/new &&
+    echo "after" | ipfs files write $ARGS $RAW_LEAVES --truncate /diffb/sub/edit
+  '
+
+  test_expect_success "diff works $EXTRA" '
+    printf -- "-gone\n+new\nMsub\n" > diff_exp &&
+    ipfs files diff /diffa /diffb > diff_out &&
+    test_cmp diff_exp diff_out
+  '
+
+  test_expect_success "diff --recursive works $EXTRA" '
+    printf -- "-gone\n+new\nMsub/edit\n" > diff_exp &&
+    ipfs files diff --recursive /diffa /diffb > diff_out &&
+    test_cmp diff_exp diff_out
+  '
+
+  test_expect_success "diff of identical directories is empty $EXTRA" '
+    ipfs files diff -r /diffa "$(ipfs files stat --hash /diffa)" > diff_out &&
+    test_must_be_empty diff_out
+  '
+
+  test_expect_success "cleanup diff $EXTRA" '
+    ipfs files rm -r /diffa &&
+    ipfs files rm -r /diffb &&
+    verify_dir_contents /
+  '
 }
 
 # test offline and online
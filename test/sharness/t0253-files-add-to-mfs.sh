@@ -0,0 +1,89 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="test ipfs add --to-mfs and ipfs files import"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "setup files" '
+  echo "hello" > hello.txt &&
+  mkdir -p dir &&
+  echo "a" > dir/a &&
+  ipfs files mkdir /docs
+'
+
+test_expect_success "add --to-mfs into a directory uses the file name" '
+  HASH=$(ipfs add -Q --to-mfs=/docs hello.txt) &&
+  ipfs files stat --hash /docs/hello.txt > actual &&
+  echo $HASH > expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "add --to-mfs to a new path" '
+  ipfs add -Q --to-mfs=/docs/renamed hello.txt &&
+  ipfs files read /docs/renamed > actual &&
+  test_cmp hello.txt actual
+'
+
+test_expect_success "add --to-mfs replaces the entry already there" '
+  echo "other" > hello.txt &&
+  ipfs add -Q --to-mfs=/docs/renamed hello.txt &&
+  ipfs files read /docs/renamed > actual &&
+  test_cmp hello.txt actual
+'
+
+test_expect_success "add -r --to-mfs links the directory" '
+  ipfs add -r -Q --to-mfs=/docs/ dir &&
+  ipfs files read /docs/dir/a > actual &&
+  test_cmp dir/a actual
+'
+
+test_expect_success "add --to-mfs fails when the parent is missing" '
+  echo "fresh" > fresh.txt &&
+  test_must_fail ipfs add --to-mfs=/missing/fresh.txt fresh.txt 2> err &&
+  grep "invalid mfs path" err
+'
+
+test_expect_success "nothing was added" '
+  ipfs pin ls --type=recursive > pins &&
+  test_must_fail grep $(ipfs add -nQ fresh.txt) pins
+'
+
+test_expect_success "add --to-mfs fails with a trailing slash on a file" '
+  test_must_fail ipfs add --to-mfs=/docs/renamed/ hello.txt
+'
+
+test_expect_success "add --to-mfs fails with --only-hash" '
+  test_must_fail ipfs add -n --to-mfs=/docs hello.txt
+'
+
+test_expect_success "files import defaults to the name of the file" '
+  ipfs files import hello.txt > actual &&
+  echo /hello.txt > expected &&
+  test_cmp expected actual &&
+  ipfs files read /hello.txt > actual &&
+  test_cmp hello.txt actual
+'
+
+test_expect_success "files import into a directory" '
+  ipfs files import -r --mfs-dest=/docs/ dir > actual &&
+  echo /docs/dir > expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "files import honours the add options" '
+  ipfs files import --mfs-dest=/docs/chunked --chunker=size-2 --raw-leaves hello.txt &&
+  ipfs add -nQ --chunker=size-2 --raw-leaves hello.txt > expected &&
+  ipfs files stat --hash /docs/chunked > actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "files import fails when the parent is missing" '
+  test_must_fail ipfs files import --mfs-dest=/missing/hello.txt hello.txt
+'
+
+test_done
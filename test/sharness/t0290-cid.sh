@@ -228,4 +228,41 @@ test_expect_success "cid hashes --numeric" '
   test_cmp hashes_expect actual
 '
 
+test_expect_success "content-id decode explains a cidv0" '
+  cat <<EOF >expect &&
+CID:        QmQhSPuGxxxtujcnZFsfWvZeKeqbPu8F4SqhZdQ5Ty5GNM
+Version:    0 (implied)
+Multibase:  base58btc (implied)
+Codec:      protobuf (0x70) (implied)
+Multihash:  sha2-256 (0x12)
+Digest:     32 bytes, 230aa5c2dfb6ca167298e335f1b39412fea0e102d0bad8bcdc9c0f9679975ed2
+A CIDv0 is a base58btc sha2-256 multihash of a protobuf (dag-pb) node.
+EOF
+  ipfs content-id decode QmQhSPuGxxxtujcnZFsfWvZeKeqbPu8F4SqhZdQ5Ty5GNM >actual &&
+  test_cmp expect actual
+'
+
+test_expect_success "cid explain explains a cidv1" '
+  cat <<EOF >expect &&
+CID:        zb2rhgDszCuZFTTLgwNeL1qPNjCLevgHbybKGrc7ZrT9sb1nk
+Version:    1
+Multibase:  base58btc (prefix z)
+Codec:      raw (0x55)
+Multihash:  sha2-256 (0x12)
+Digest:     32 bytes, 8e54b0ca18020275e4aef1ca0eb5e197e066c065c1864817652a8a39c55402cd
+EOF
+  ipfs cid explain zb2rhgDszCuZFTTLgwNeL1qPNjCLevgHbybKGrc7ZrT9sb1nk >actual &&
+  test_cmp expect actual
+'
+
+test_expect_success "content-id decode --json" '
+  ipfs content-id decode --json bafkqaaa >actual &&
+  grep "\"Multibase\":{\"Code\":98,\"Name\":\"base32\"}" actual &&
+  grep "\"Multihash\":{\"Code\":0,\"Name\":\"id\"}" actual
+'
+
+test_expect_success "content-id decode rejects invalid cids" '
+  test_must_fail ipfs content-id decode nope
+'
+
 test_done
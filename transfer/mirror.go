@@ -0,0 +1,251 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+)
+
+// MirrorProtocolID is the libp2p protocol of the mirrors.
+//
+// The mirror sends the root CID of the DAG and whether it wants all of it.
+// Once the provider accepts, it writes the blocks it has, framed like the
+// transfers, an empty frame, and the number of blocks it didn't send. The
+// blocks are streamed without waiting for the mirror to ask for the next
+// ones, unlike with bitswap.
+const MirrorProtocolID protocol.ID = "/ipfs/mirror/1.0.0"
+
+type mirrorRequest struct {
+	Root      string
+	Recursive bool `json:",omitempty"`
+}
+
+type mirrorResponse struct {
+	Blocks  int    `json:",omitempty"`
+	Size    uint64 `json:",omitempty"`
+	Missing int    `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+// Mirror fetches the block root from the peer p, and all the DAG under it
+// if recursive, and stores them in bs. Like for the transfers, the blocks are
+// checked against their CIDs, the ones not linked from the root are dropped
+// and the mirror fails once the peer sent more than maxSize bytes of blocks,
+// DefaultMaxTransferSize if 0. The DAG is incomplete when the peer doesn't
+// have all of it, in which case the blocks received are stored but an error
+// is returned.
+func Mirror(ctx context.Context, h host.Host, bs bstore.Blockstore, p peer.ID, root cid.Cid, recursive bool, maxSize uint64) (*Result, error) {
+	if maxSize == 0 {
+		maxSize = DefaultMaxTransferSize
+	}
+	s, err := h.NewStream(ctx, p, MirrorProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("opening a mirror stream to %s: %s", p.Pretty(), err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	w := bufio.NewWriter(s)
+	r := bufio.NewReader(s)
+	if err := writeMessage(w, &mirrorRequest{Root: root.String(), Recursive: recursive}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	var resp mirrorResponse
+	if err := readMessage(r, &resp); err != nil {
+		// the protocol is only negotiated with the first read
+		s.Reset()
+		return nil, fmt.Errorf("%s is not serving mirrors: %s", p.Pretty(), ctxErr(ctx, err))
+	}
+	if resp.Error != "" {
+		s.Close()
+		return nil, fmt.Errorf("mirror rejected: %s", resp.Error)
+	}
+
+	res := &Result{Peer: p, Root: root}
+	wanted := cid.NewSet()
+	wanted.Add(root)
+	var read uint64
+	for {
+		key, err := readFrame(r, maxMessageSize)
+		if err != nil {
+			s.Reset()
+			return res, ctxErr(ctx, err)
+		}
+		if len(key) == 0 {
+			break
+		}
+		data, err := readFrame(r, MaxBlockSize)
+		if err != nil {
+			s.Reset()
+			return res, ctxErr(ctx, err)
+		}
+		read += uint64(len(data))
+		if read > maxSize {
+			s.Reset()
+			return res, fmt.Errorf("mirror larger than %d bytes", maxSize)
+		}
+		blk, err := storeBlock(bs, wanted, key, data, recursive)
+		if err != nil {
+			s.Reset()
+			return res, err
+		}
+		if blk == nil {
+			continue
+		}
+		res.Blocks++
+		res.Size += uint64(len(data))
+	}
+
+	if err := readMessage(r, &resp); err != nil {
+		s.Reset()
+		return res, ctxErr(ctx, err)
+	}
+	s.Close()
+	if resp.Missing > 0 {
+		return res, fmt.Errorf("incomplete mirror: %s doesn't have %d blocks of the DAG", p.Pretty(), resp.Missing)
+	}
+	return res, nil
+}
+
+// MirrorProvider serves the local DAGs to the peers mirroring them.
+type MirrorProvider struct {
+	ctx     context.Context
+	host    host.Host
+	ng      ipld.NodeGetter
+	allowed func(cid.Cid, peer.ID) bool
+}
+
+// NewMirrorProvider returns a provider serving the blocks of bs until ctx is
+// done. The blocks allowed returns false for are not sent, as if they were
+// missing.
+func NewMirrorProvider(ctx context.Context, h host.Host, bs bstore.Blockstore, allowed func(cid.Cid, peer.ID) bool) *MirrorProvider {
+	return &MirrorProvider{
+		ctx:     ctx,
+		host:    h,
+		ng:      dag.NewDAGService(bserv.New(bs, offline.Exchange(bs))),
+		allowed: allowed,
+	}
+}
+
+// Start starts serving the mirrors.
+func (mp *MirrorProvider) Start() {
+	mp.host.SetStreamHandler(MirrorProtocolID, mp.handleStream)
+}
+
+// Close stops serving the mirrors. The ones started are finished, unless the
+// context of the provider is done.
+func (mp *MirrorProvider) Close() {
+	mp.host.RemoveStreamHandler(MirrorProtocolID)
+}
+
+func (mp *MirrorProvider) handleStream(s inet.Stream) {
+	if mp.ctx.Err() != nil {
+		s.Reset()
+		return
+	}
+	from := s.Conn().RemotePeer()
+	w := bufio.NewWriter(s)
+	r := bufio.NewReader(s)
+
+	var req mirrorRequest
+	setReadDeadline(s)
+	if err := readMessage(r, &req); err != nil {
+		log.Debugf("reading a mirror request from %s: %s", from, err)
+		s.Reset()
+		return
+	}
+	root, err := cid.Decode(req.Root)
+	if err != nil {
+		if writeMessage(w, &mirrorResponse{Error: err.Error()}) != nil {
+			s.Reset()
+			return
+		}
+		s.Close()
+		return
+	}
+	if err := writeMessage(w, &mirrorResponse{}); err != nil {
+		s.Reset()
+		return
+	}
+
+	// the mirrors stop with the node
+	ctx, cancel := context.WithCancel(mp.ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		if mp.ctx.Err() != nil {
+			s.Reset()
+		}
+	}()
+
+	var resp mirrorResponse
+	seen := cid.NewSet()
+	var walk func(c cid.Cid) error
+	walk = func(c cid.Cid) error {
+		if !seen.Visit(c) {
+			return nil
+		}
+		if mp.allowed != nil && !mp.allowed(c, from) {
+			resp.Missing++
+			return nil
+		}
+		nd, err := mp.ng.Get(ctx, c)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			resp.Missing++
+			return nil
+		}
+		if err := writeFrame(w, c.Bytes()); err != nil {
+			return err
+		}
+		if err := writeFrame(w, nd.RawData()); err != nil {
+			return err
+		}
+		resp.Blocks++
+		resp.Size += uint64(len(nd.RawData()))
+		if !req.Recursive {
+			return nil
+		}
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		log.Debugf("mirroring %s to %s: %s", root, from, err)
+		s.Reset()
+		return
+	}
+	if err := writeFrame(w, nil); err != nil {
+		s.Reset()
+		return
+	}
+	if err := writeMessage(w, &resp); err != nil {
+		s.Reset()
+		return
+	}
+	s.Close()
+}
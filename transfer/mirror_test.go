@@ -0,0 +1,169 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	dag "github.com/ipfs/go-merkledag"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+func TestMirror(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	provider, mirror := newNodes(t, ctx)
+
+	mp := NewMirrorProvider(ctx, provider.host, provider.bs, nil)
+	mp.Start()
+	defer mp.Close()
+
+	root := makeDAG(t, provider.dag)
+	res, err := Mirror(ctx, mirror.host, mirror.bs, provider.host.ID(), root.Cid(), true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != 3 || res.Peer != provider.host.ID() || !res.Root.Equals(root.Cid()) {
+		t.Fatalf("unexpected mirror %+v", res)
+	}
+	if err := dag.FetchGraph(ctx, root.Cid(), mirror.dag); err != nil {
+		t.Fatalf("the mirrored DAG isn't stored: %s", err)
+	}
+}
+
+func TestMirrorNotRecursive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	provider, mirror := newNodes(t, ctx)
+
+	mp := NewMirrorProvider(ctx, provider.host, provider.bs, nil)
+	mp.Start()
+	defer mp.Close()
+
+	root := makeDAG(t, provider.dag)
+	res, err := Mirror(ctx, mirror.host, mirror.bs, provider.host.ID(), root.Cid(), false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != 1 {
+		t.Fatalf("expected only the root to be mirrored, got %d blocks", res.Blocks)
+	}
+	if has, _ := mirror.bs.Has(root.Links()[0].Cid); has {
+		t.Fatal("a child of the root was mirrored")
+	}
+}
+
+func TestMirrorIncomplete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	provider, mirror := newNodes(t, ctx)
+
+	// the provider doesn't send the child it doesn't allow, nor the one it
+	// doesn't have
+	denied := makeDAG(t, provider.dag).Links()[1].Cid
+	mp := NewMirrorProvider(ctx, provider.host, provider.bs, func(c cid.Cid, p peer.ID) bool {
+		return !c.Equals(denied)
+	})
+	mp.Start()
+	defer mp.Close()
+
+	root := makeDAG(t, provider.dag)
+	if err := provider.dag.Remove(ctx, root.Links()[0].Cid); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Mirror(ctx, mirror.host, mirror.bs, provider.host.ID(), root.Cid(), true, 0)
+	if err == nil {
+		t.Fatal("expected the mirror of an incomplete DAG to fail")
+	}
+	if res == nil || res.Blocks != 1 {
+		t.Fatalf("expected the root to be mirrored alone, got %+v", res)
+	}
+	if has, _ := mirror.bs.Has(denied); has {
+		t.Fatal("the denied block was mirrored")
+	}
+}
+
+func TestMirrorTooLarge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	provider, mirror := newNodes(t, ctx)
+
+	mp := NewMirrorProvider(ctx, provider.host, provider.bs, nil)
+	mp.Start()
+	defer mp.Close()
+
+	root := makeDAG(t, provider.dag)
+	if _, err := Mirror(ctx, mirror.host, mirror.bs, provider.host.ID(), root.Cid(), true, 8); err == nil {
+		t.Fatal("expected the mirror of a DAG larger than the limit to fail")
+	}
+}
+
+func TestMirrorUnlinked(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	provider, mirror := newNodes(t, ctx)
+
+	// the provider sends a block not linked from the root with it
+	root := dag.NodeWithData([]byte("root"))
+	extra := dag.NodeWithData([]byte("extra"))
+	provider.host.SetStreamHandler(MirrorProtocolID, func(s inet.Stream) {
+		w := bufio.NewWriter(s)
+		r := bufio.NewReader(s)
+		var req mirrorRequest
+		if err := readMessage(r, &req); err != nil {
+			s.Reset()
+			return
+		}
+		writeMessage(w, &mirrorResponse{})
+		for _, nd := range []*dag.ProtoNode{root, extra} {
+			writeFrame(w, nd.Cid().Bytes())
+			writeFrame(w, nd.RawData())
+		}
+		writeFrame(w, nil)
+		writeMessage(w, &mirrorResponse{Blocks: 2})
+		s.Close()
+	})
+
+	res, err := Mirror(ctx, mirror.host, mirror.bs, provider.host.ID(), root.Cid(), true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != 1 {
+		t.Fatalf("expected 1 block stored, got %d", res.Blocks)
+	}
+	if has, _ := mirror.bs.Has(extra.Cid()); has {
+		t.Fatal("the block not linked from the root was stored")
+	}
+}
+
+func TestMirrorProviderStopped(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	provider, mirror := newNodes(t, ctx)
+
+	// the provider stops with its context, not waiting for the request
+	pctx, pcancel := context.WithCancel(ctx)
+	mp := NewMirrorProvider(pctx, provider.host, provider.bs, nil)
+	mp.Start()
+	defer mp.Close()
+	pcancel()
+
+	root := makeDAG(t, provider.dag)
+	if _, err := Mirror(ctx, mirror.host, mirror.bs, provider.host.ID(), root.Cid(), true, 0); err == nil {
+		t.Fatal("expected the mirror to fail once the provider is stopped")
+	}
+}
+
+func TestMirrorNotServing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	provider, mirror := newNodes(t, ctx)
+
+	root := makeDAG(t, provider.dag)
+	if _, err := Mirror(ctx, mirror.host, mirror.bs, provider.host.ID(), root.Cid(), true, 0); err == nil {
+		t.Fatal("expected the mirror to fail without provider")
+	}
+}
@@ -0,0 +1,470 @@
+// Package transfer pushes DAGs directly to a peer over a dedicated libp2p
+// protocol, instead of letting the peer fetch them with bitswap, and mirrors
+// the DAGs of a peer the same way (see Mirror).
+//
+// The sender opens a stream and sends the root CID of the DAG, with an
+// HMAC of it when the receiver requires a secret. Once the receiver accepts
+// the transfer, the sender writes every block of the DAG, as the CID then
+// the data, and an empty frame. The receiver checks the blocks against their
+// CIDs, drops the ones not linked from the root, checks it has the whole DAG,
+// pins it and replies with the result. Every frame is prefixed with its
+// length as an unsigned varint.
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	blocks "github.com/ipfs/go-block-format"
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	dag "github.com/ipfs/go-merkledag"
+	host "github.com/libp2p/go-libp2p-host"
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+	protocol "github.com/libp2p/go-libp2p-protocol"
+)
+
+var log = logging.Logger("transfer")
+
+// ProtocolID is the libp2p protocol of the transfers.
+const ProtocolID protocol.ID = "/ipfs/transfer/1.0.0"
+
+// MaxBlockSize is the size of the largest block a receiver accepts.
+const MaxBlockSize = 2 << 20
+
+// DefaultMaxTransferSize is the largest size of the blocks of a transfer a
+// receiver accepts, unless configured otherwise.
+const DefaultMaxTransferSize = 1 << 30
+
+// maxMessageSize is the size of the largest request or response.
+const maxMessageSize = 4 << 10
+
+// frameTimeout bounds the wait of the receiver for each frame.
+var frameTimeout = time.Minute
+
+// ErrUnauthorized is returned by the receiver when a transfer doesn't have
+// the HMAC of its secret, or comes from a peer not allowed.
+var ErrUnauthorized = errors.New("transfer not authorized")
+
+// ErrNoAuth is returned by NewReceiver when it would accept the transfers of
+// any peer.
+var ErrNoAuth = errors.New("the transfers of any peer would be accepted, require a secret or allow some peers")
+
+// errTooLarge is returned by the receiver when a transfer has more data than
+// it accepts.
+var errTooLarge = errors.New("transfer larger than the receiver accepts")
+
+// Result is the outcome of a transfer of the DAG Root.
+type Result struct {
+	Peer   peer.ID
+	Root   cid.Cid
+	Blocks int
+	Size   uint64
+	Error  error
+}
+
+type request struct {
+	Root string
+	Auth string `json:",omitempty"`
+}
+
+type response struct {
+	Blocks int    `json:",omitempty"`
+	Size   uint64 `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+// auth is the hex HMAC-SHA256 with secret of the transfer of root by from.
+func auth(secret string, from peer.ID, root cid.Cid) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(from))
+	mac.Write(root.Bytes())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Push sends the DAG root, read from ng, to the peer p, which pins it. The
+// secret must be the one the receiver requires, if any.
+func Push(ctx context.Context, h host.Host, ng ipld.NodeGetter, p peer.ID, root cid.Cid, secret string) (*Result, error) {
+	s, err := h.NewStream(ctx, p, ProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("opening a transfer stream to %s: %s", p.Pretty(), err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	req := request{Root: root.String()}
+	if secret != "" {
+		req.Auth = auth(secret, h.ID(), root)
+	}
+	w := bufio.NewWriter(s)
+	r := bufio.NewReader(s)
+	if err := writeMessage(w, &req); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	var resp response
+	if err := readMessage(r, &resp); err != nil {
+		// the protocol is only negotiated with the first read
+		s.Reset()
+		return nil, fmt.Errorf("%s is not receiving transfers: %s", p.Pretty(), ctxErr(ctx, err))
+	}
+	if resp.Error != "" {
+		s.Close()
+		return nil, fmt.Errorf("transfer rejected: %s", resp.Error)
+	}
+
+	seen := cid.NewSet()
+	var walk func(c cid.Cid) error
+	walk = func(c cid.Cid) error {
+		if !seen.Visit(c) {
+			return nil
+		}
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := writeFrame(w, c.Bytes()); err != nil {
+			return err
+		}
+		if err := writeFrame(w, nd.RawData()); err != nil {
+			return err
+		}
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		s.Reset()
+		return nil, ctxErr(ctx, err)
+	}
+	if err := writeFrame(w, nil); err != nil {
+		s.Reset()
+		return nil, ctxErr(ctx, err)
+	}
+
+	if err := readMessage(r, &resp); err != nil {
+		s.Reset()
+		return nil, ctxErr(ctx, err)
+	}
+	s.Close()
+	if resp.Error != "" {
+		return nil, fmt.Errorf("transfer failed: %s", resp.Error)
+	}
+	return &Result{Peer: p, Root: root, Blocks: resp.Blocks, Size: resp.Size}, nil
+}
+
+// ReceiverConfig is which transfers a Receiver accepts. A transfer must pass
+// every check configured.
+type ReceiverConfig struct {
+	// Secret, if not empty, is the secret whose HMAC the transfers must
+	// have.
+	Secret string
+	// Allow, if not empty, are the only peers the transfers are accepted
+	// from.
+	Allow []peer.ID
+	// MaxSize is the largest size of the blocks of a transfer,
+	// DefaultMaxTransferSize if 0.
+	MaxSize uint64
+}
+
+// Receiver stores and pins the DAGs pushed to a host.
+type Receiver struct {
+	host    host.Host
+	bs      bstore.GCBlockstore
+	pinner  pin.Pinner
+	secret  string
+	allow   map[peer.ID]bool
+	maxSize uint64
+	handle  func(*Result)
+}
+
+// NewReceiver returns a receiver storing the pushed DAGs in bs and pinning
+// them with pinner, accepting the transfers as configured by cfg, which must
+// require a secret or allow some peers. handle is called with the result of
+// each transfer.
+func NewReceiver(h host.Host, bs bstore.GCBlockstore, pinner pin.Pinner, cfg ReceiverConfig, handle func(*Result)) (*Receiver, error) {
+	if cfg.Secret == "" && len(cfg.Allow) == 0 {
+		return nil, ErrNoAuth
+	}
+	rcv := &Receiver{
+		host:    h,
+		bs:      bs,
+		pinner:  pinner,
+		secret:  cfg.Secret,
+		maxSize: cfg.MaxSize,
+		handle:  handle,
+	}
+	if rcv.maxSize == 0 {
+		rcv.maxSize = DefaultMaxTransferSize
+	}
+	if len(cfg.Allow) > 0 {
+		rcv.allow = make(map[peer.ID]bool, len(cfg.Allow))
+		for _, p := range cfg.Allow {
+			rcv.allow[p] = true
+		}
+	}
+	return rcv, nil
+}
+
+// Start starts receiving the transfers.
+func (rcv *Receiver) Start() {
+	rcv.host.SetStreamHandler(ProtocolID, rcv.handleStream)
+}
+
+// Close stops receiving the transfers. The ones started are finished.
+func (rcv *Receiver) Close() {
+	rcv.host.RemoveStreamHandler(ProtocolID)
+}
+
+func (rcv *Receiver) handleStream(s inet.Stream) {
+	from := s.Conn().RemotePeer()
+	w := bufio.NewWriter(s)
+	r := bufio.NewReader(s)
+
+	var req request
+	setReadDeadline(s)
+	if err := readMessage(r, &req); err != nil {
+		log.Debugf("reading a transfer request from %s: %s", from, err)
+		s.Reset()
+		return
+	}
+	res := &Result{Peer: from}
+	reply := func(resp *response) {
+		if err := writeMessage(w, resp); err != nil {
+			log.Debugf("replying to %s: %s", from, err)
+			s.Reset()
+			return
+		}
+		s.Close()
+	}
+
+	root, err := cid.Decode(req.Root)
+	if err != nil {
+		reply(&response{Error: err.Error()})
+		return
+	}
+	res.Root = root
+	if (rcv.allow != nil && !rcv.allow[from]) ||
+		(rcv.secret != "" && !hmac.Equal([]byte(req.Auth), []byte(auth(rcv.secret, from, root)))) {
+		res.Error = ErrUnauthorized
+		rcv.handle(res)
+		reply(&response{Error: ErrUnauthorized.Error()})
+		return
+	}
+	if err := writeMessage(w, &response{}); err != nil {
+		s.Reset()
+		return
+	}
+
+	res.Blocks, res.Size, res.Error = rcv.receive(context.Background(), s, r, root)
+	rcv.handle(res)
+	if res.Error == errTooLarge {
+		// the sender is still writing the blocks
+		s.Reset()
+		return
+	}
+	if res.Error != nil {
+		reply(&response{Error: res.Error.Error()})
+		return
+	}
+	reply(&response{Blocks: res.Blocks, Size: res.Size})
+}
+
+// receive stores the blocks of the DAG root read from r, then pins it. The
+// blocks not linked from the blocks received before are dropped. After an
+// invalid block, the rest of the blocks are read and dropped, for the sender
+// to get the error once it has sent them.
+func (rcv *Receiver) receive(ctx context.Context, s inet.Stream, r *bufio.Reader, root cid.Cid) (int, uint64, error) {
+	var count int
+	var size, read uint64
+	var invalid error
+	wanted := cid.NewSet()
+	wanted.Add(root)
+	for {
+		setReadDeadline(s)
+		key, err := readFrame(r, maxMessageSize)
+		if err != nil {
+			return count, size, err
+		}
+		if len(key) == 0 {
+			break
+		}
+		setReadDeadline(s)
+		data, err := readFrame(r, MaxBlockSize)
+		if err != nil {
+			return count, size, err
+		}
+		read += uint64(len(data))
+		if read > rcv.maxSize {
+			return count, size, errTooLarge
+		}
+		if invalid != nil {
+			continue
+		}
+		var blk blocks.Block
+		blk, invalid = storeBlock(rcv.bs, wanted, key, data, true)
+		if blk == nil {
+			continue
+		}
+		count++
+		size += uint64(len(data))
+	}
+	if invalid != nil {
+		return count, size, invalid
+	}
+
+	// the blocks can be collected until the lock is taken, the DAG is then
+	// incomplete
+	defer rcv.bs.PinLock().Unlock()
+
+	// the DAG is only read from the blocks received or stored already
+	ds := dag.NewDAGService(bserv.New(rcv.bs, offline.Exchange(rcv.bs)))
+	nd, err := ds.Get(ctx, root)
+	if err != nil {
+		return count, size, fmt.Errorf("incomplete transfer: %s", err)
+	}
+	if err := dag.EnumerateChildren(ctx, dag.GetLinksWithDAG(ds), root, cid.NewSet().Visit); err != nil {
+		return count, size, fmt.Errorf("incomplete transfer: %s", err)
+	}
+	if err := rcv.pinner.Pin(ctx, nd, true); err != nil {
+		return count, size, err
+	}
+	return count, size, rcv.pinner.Flush()
+}
+
+// storeBlock stores the block data with the CID key in bs if it is in wanted,
+// and adds its links to wanted if links. It returns the block stored, nil if
+// it was dropped.
+func storeBlock(bs bstore.Blockstore, wanted *cid.Set, key, data []byte, links bool) (blocks.Block, error) {
+	c, err := cid.Cast(key)
+	if err != nil {
+		return nil, err
+	}
+	if !wanted.Has(c) {
+		log.Debugf("dropping the block %s, not linked from the DAG", c)
+		return nil, nil
+	}
+	blk, err := checkBlock(c, data)
+	if err != nil {
+		return nil, err
+	}
+	var nd ipld.Node
+	if links {
+		if nd, err = ipld.Decode(blk); err != nil {
+			return nil, err
+		}
+	}
+	if err := bs.Put(blk); err != nil {
+		return nil, err
+	}
+	if nd != nil {
+		for _, l := range nd.Links() {
+			wanted.Add(l.Cid)
+		}
+	}
+	return blk, nil
+}
+
+// setReadDeadline bounds the wait for the next frame of s. The streams not
+// supporting deadlines are read without one.
+func setReadDeadline(s inet.Stream) {
+	if err := s.SetReadDeadline(time.Now().Add(frameTimeout)); err != nil {
+		log.Debugf("setting a read deadline: %s", err)
+	}
+}
+
+// checkBlock returns the block data with the CID c, if they match.
+func checkBlock(c cid.Cid, data []byte) (blocks.Block, error) {
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("the data of block %s doesn't match its CID", c)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// ctxErr returns the error of ctx if it is done, since err is then only the
+// reset of the stream.
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func writeFrame(w *bufio.Writer, data []byte) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(data)))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return w.Flush()
+	}
+	return nil
+}
+
+func readFrame(r *bufio.Reader, max int) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(max) {
+		return nil, fmt.Errorf("frame of %d bytes larger than %d", n, max)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func writeMessage(w *bufio.Writer, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := writeFrame(w, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func readMessage(r *bufio.Reader, msg interface{}) error {
+	data, err := readFrame(r, maxMessageSize)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, msg)
+}
@@ -0,0 +1,248 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	bserv "github.com/ipfs/go-blockservice"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	host "github.com/libp2p/go-libp2p-host"
+	peer "github.com/libp2p/go-libp2p-peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+type node struct {
+	host   host.Host
+	bs     bstore.GCBlockstore
+	dag    ipld.DAGService
+	pinner pin.Pinner
+}
+
+func newNodes(t *testing.T, ctx context.Context) (*node, *node) {
+	mn, err := mocknet.FullMeshConnected(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes []*node
+	for _, h := range mn.Hosts() {
+		dstore := dssync.MutexWrap(ds.NewMapDatastore())
+		bs := bstore.NewGCBlockstore(bstore.NewBlockstore(dstore), bstore.NewGCLocker())
+		dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+		nodes = append(nodes, &node{
+			host:   h,
+			bs:     bs,
+			dag:    dserv,
+			pinner: pin.NewPinner(dstore, dserv, dserv),
+		})
+	}
+	return nodes[0], nodes[1]
+}
+
+func newReceiver(t *testing.T, nd *node, cfg ReceiverConfig, handle func(*Result)) *Receiver {
+	t.Helper()
+	rcv, err := NewReceiver(nd.host, nd.bs, nd.pinner, cfg, handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rcv
+}
+
+// makeDAG adds a node with two children to dserv.
+func makeDAG(t *testing.T, dserv ipld.DAGService) ipld.Node {
+	ctx := context.Background()
+	root := dag.NodeWithData([]byte("root"))
+	for _, data := range []string{"a", "b"} {
+		child := dag.NodeWithData([]byte(data))
+		if err := dserv.Add(ctx, child); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLink(data, child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dserv.Add(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestPush(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sender, receiver := newNodes(t, ctx)
+
+	results := make(chan *Result, 1)
+	rcv := newReceiver(t, receiver, ReceiverConfig{Allow: []peer.ID{sender.host.ID()}}, func(r *Result) { results <- r })
+	rcv.Start()
+	defer rcv.Close()
+
+	root := makeDAG(t, sender.dag)
+	res, err := Push(ctx, sender.host, sender.dag, receiver.host.ID(), root.Cid(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != 3 {
+		t.Fatalf("expected 3 blocks pushed, got %d", res.Blocks)
+	}
+
+	received := <-results
+	if received.Error != nil || received.Peer != sender.host.ID() || !received.Root.Equals(root.Cid()) {
+		t.Fatalf("unexpected received transfer %+v", received)
+	}
+	if _, pinned, err := receiver.pinner.IsPinned(root.Cid()); err != nil || !pinned {
+		t.Fatalf("the pushed DAG isn't pinned: %v", err)
+	}
+	if err := dag.FetchGraph(ctx, root.Cid(), receiver.dag); err != nil {
+		t.Fatalf("the pushed DAG isn't stored: %s", err)
+	}
+}
+
+func TestPushAuth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sender, receiver := newNodes(t, ctx)
+
+	rcv := newReceiver(t, receiver, ReceiverConfig{Secret: "secret"}, func(*Result) {})
+	rcv.Start()
+	defer rcv.Close()
+
+	root := makeDAG(t, sender.dag)
+	for _, secret := range []string{"", "wrong"} {
+		_, err := Push(ctx, sender.host, sender.dag, receiver.host.ID(), root.Cid(), secret)
+		if err == nil || !strings.Contains(err.Error(), ErrUnauthorized.Error()) {
+			t.Fatalf("expected a push with the secret %q to be rejected, got %v", secret, err)
+		}
+	}
+	if has, _ := receiver.bs.Has(root.Cid()); has {
+		t.Fatal("the rejected DAG was stored")
+	}
+
+	if _, err := Push(ctx, sender.host, sender.dag, receiver.host.ID(), root.Cid(), "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPushIncomplete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sender, receiver := newNodes(t, ctx)
+
+	rcv := newReceiver(t, receiver, ReceiverConfig{Allow: []peer.ID{sender.host.ID()}}, func(*Result) {})
+	rcv.Start()
+	defer rcv.Close()
+
+	root := makeDAG(t, sender.dag)
+	if err := sender.dag.Remove(ctx, root.Links()[0].Cid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Push(ctx, sender.host, sender.dag, receiver.host.ID(), root.Cid(), ""); err == nil {
+		t.Fatal("expected the push of an incomplete DAG to fail")
+	}
+	if _, pinned, _ := receiver.pinner.IsPinned(root.Cid()); pinned {
+		t.Fatal("the incomplete DAG was pinned")
+	}
+}
+
+func TestNewReceiverNoAuth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, receiver := newNodes(t, ctx)
+
+	if _, err := NewReceiver(receiver.host, receiver.bs, receiver.pinner, ReceiverConfig{}, func(*Result) {}); err != ErrNoAuth {
+		t.Fatalf("expected a receiver accepting any peer to be refused, got %v", err)
+	}
+}
+
+func TestPushNotAllowed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sender, receiver := newNodes(t, ctx)
+
+	rcv := newReceiver(t, receiver, ReceiverConfig{Allow: []peer.ID{receiver.host.ID()}}, func(*Result) {})
+	rcv.Start()
+	defer rcv.Close()
+
+	root := makeDAG(t, sender.dag)
+	_, err := Push(ctx, sender.host, sender.dag, receiver.host.ID(), root.Cid(), "")
+	if err == nil || !strings.Contains(err.Error(), ErrUnauthorized.Error()) {
+		t.Fatalf("expected the push of a peer not allowed to be rejected, got %v", err)
+	}
+}
+
+func TestPushTooLarge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sender, receiver := newNodes(t, ctx)
+
+	results := make(chan *Result, 1)
+	rcv := newReceiver(t, receiver, ReceiverConfig{Allow: []peer.ID{sender.host.ID()}, MaxSize: 8}, func(r *Result) { results <- r })
+	rcv.Start()
+	defer rcv.Close()
+
+	root := makeDAG(t, sender.dag)
+	if _, err := Push(ctx, sender.host, sender.dag, receiver.host.ID(), root.Cid(), ""); err == nil {
+		t.Fatal("expected the push of a DAG larger than the limit to fail")
+	}
+	if r := <-results; r.Error != errTooLarge {
+		t.Fatalf("expected the transfer to be too large, got %v", r.Error)
+	}
+}
+
+func TestPushUnlinked(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sender, receiver := newNodes(t, ctx)
+
+	rcv := newReceiver(t, receiver, ReceiverConfig{Allow: []peer.ID{sender.host.ID()}}, func(*Result) {})
+	rcv.Start()
+	defer rcv.Close()
+
+	// the block not linked from the root is sent with it
+	root := dag.NodeWithData([]byte("root"))
+	extra := dag.NodeWithData([]byte("extra"))
+	s, err := sender.host.NewStream(ctx, receiver.host.ID(), ProtocolID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(s)
+	r := bufio.NewReader(s)
+	var resp response
+	if err := writeMessage(w, &request{Root: root.Cid().String()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := readMessage(r, &resp); err != nil || resp.Error != "" {
+		t.Fatalf("transfer not accepted: %v %s", err, resp.Error)
+	}
+	for _, nd := range []*dag.ProtoNode{root, extra} {
+		if err := writeFrame(w, nd.Cid().Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFrame(w, nd.RawData()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeFrame(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := readMessage(r, &resp); err != nil || resp.Error != "" {
+		t.Fatalf("transfer failed: %v %s", err, resp.Error)
+	}
+	s.Close()
+
+	if resp.Blocks != 1 {
+		t.Fatalf("expected 1 block stored, got %d", resp.Blocks)
+	}
+	if has, _ := receiver.bs.Has(extra.Cid()); has {
+		t.Fatal("the block not linked from the root was stored")
+	}
+}
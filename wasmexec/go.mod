@@ -0,0 +1,5 @@
+module github.com/ipfs/go-ipfs/wasmexec
+
+go 1.22.0
+
+require github.com/tetratelabs/wazero v1.9.0
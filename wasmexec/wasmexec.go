@@ -0,0 +1,96 @@
+// Package wasmexec runs the WASI command modules stored in IPFS.
+//
+// The modules are run in a sandbox: they don't see the filesystem, the
+// environment or the network of the node, and their clocks and random source
+// are fake, so that a module run with the same arguments gives the same
+// output.
+package wasmexec
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// ErrNotCommand is returned for the modules without a _start function.
+var ErrNotCommand = errors.New("the module is not a WASI command: it doesn't export a _start function")
+
+// MaxMemoryPages limits the memory of a module to 256MiB, in pages of 64KiB.
+const MaxMemoryPages = 4096
+
+// Module is a compiled WASI command module, which can be run many times.
+type Module struct {
+	rt  wazero.Runtime
+	mod wazero.CompiledModule
+}
+
+// Compile compiles the WASI command module bin. The module must be closed
+// once it is not run anymore.
+func Compile(ctx context.Context, bin []byte) (*Module, error) {
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(MaxMemoryPages))
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		rt.Close(ctx)
+		return nil, err
+	}
+	mod, err := rt.CompileModule(ctx, bin)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, err
+	}
+	if _, ok := mod.ExportedFunctions()["_start"]; !ok {
+		rt.Close(ctx)
+		return nil, ErrNotCommand
+	}
+	return &Module{rt: rt, mod: mod}, nil
+}
+
+// Run runs the module with the arguments args, name being its argv[0], and
+// returns its exit code. The module reads stdin, which may be nil for an
+// empty input, and its outputs are written to stdout and stderr. The module
+// is stopped when ctx is done.
+func (m *Module) Run(ctx context.Context, name string, args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	// every run is a new instance, with its own memory
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(append([]string{name}, args...)...).
+		WithStdout(stdout).
+		WithStderr(stderr)
+	if stdin != nil {
+		cfg = cfg.WithStdin(stdin)
+	}
+	inst, err := m.rt.InstantiateModule(ctx, m.mod, cfg)
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	if exit, ok := err.(*sys.ExitError); ok {
+		return int(exit.ExitCode()), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// the module returned from _start without calling proc_exit
+	inst.Close(ctx)
+	return 0, nil
+}
+
+// Close releases the module.
+func (m *Module) Close(ctx context.Context) error {
+	return m.rt.Close(ctx)
+}
+
+// Run compiles bin and runs it once with an empty stdin, see Module.Run.
+func Run(ctx context.Context, bin []byte, name string, args []string, stdout, stderr io.Writer) (int, error) {
+	m, err := Compile(ctx, bin)
+	if err != nil {
+		return 0, err
+	}
+	defer m.Close(ctx)
+	return m.Run(ctx, name, args, nil, stdout, stderr)
+}
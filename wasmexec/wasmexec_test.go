@@ -0,0 +1,148 @@
+package wasmexec
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// section encodes a section of a WASM module, the contents being shorter
+// than 128 bytes.
+func section(id byte, contents ...byte) []byte {
+	return append([]byte{id, byte(len(contents))}, contents...)
+}
+
+func name(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func concat(bs ...[]byte) []byte {
+	var out []byte
+	for _, b := range bs {
+		out = append(out, b...)
+	}
+	return out
+}
+
+// module returns a WASI command exporting its memory, whose _start function
+// has the instructions code. It imports fd_write as function 0, proc_exit as
+// function 1 and fd_read as function 2, and its memory starts with data.
+func module(code []byte, data []byte) []byte {
+	i32 := byte(0x7f)
+	types := section(1, concat(
+		[]byte{3},
+		[]byte{0x60, 4, i32, i32, i32, i32, 1, i32}, // fd_write, fd_read
+		[]byte{0x60, 1, i32, 0},                     // proc_exit
+		[]byte{0x60, 0, 0},                          // _start
+	)...)
+	imports := section(2, concat(
+		[]byte{3},
+		name("wasi_snapshot_preview1"), name("fd_write"), []byte{0, 0},
+		name("wasi_snapshot_preview1"), name("proc_exit"), []byte{0, 1},
+		name("wasi_snapshot_preview1"), name("fd_read"), []byte{0, 0},
+	)...)
+	funcs := section(3, 1, 2)
+	memory := section(5, 1, 0, 1)
+	exports := section(7, concat(
+		[]byte{2},
+		name("memory"), []byte{2, 0},
+		name("_start"), []byte{0, 3},
+	)...)
+	body := append([]byte{0}, append(code, 0x0b)...)
+	codes := section(10, concat([]byte{1, byte(len(body))}, body)...)
+	datas := section(11, concat([]byte{1, 0, 0x41, 0, 0x0b, byte(len(data))}, data)...)
+	return concat([]byte("\x00asm\x01\x00\x00\x00"), types, imports, funcs, memory, exports, codes, datas)
+}
+
+func TestRun(t *testing.T) {
+	code := []byte{
+		0x41, 2, // i32.const 2: stderr
+		0x41, 0, // i32.const 0: the iovec
+		0x41, 1, // i32.const 1: one iovec
+		0x41, 20, // i32.const 20: nwritten
+		0x10, 0, // call fd_write
+		0x1a,    // drop
+		0x41, 3, // i32.const 3
+		0x10, 1, // call proc_exit
+	}
+	// the iovec of "hello\n" at 8
+	data := append([]byte{8, 0, 0, 0, 6, 0, 0, 0}, "hello\n"...)
+
+	var stdout, stderr bytes.Buffer
+	exit, err := Run(context.Background(), module(code, data), "hello", nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exit != 3 {
+		t.Fatalf("exit code %d, expected 3", exit)
+	}
+	if stdout.Len() != 0 || stderr.String() != "hello\n" {
+		t.Fatalf("unexpected outputs %q, %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestModule(t *testing.T) {
+	// echoes the first 32 bytes of stdin
+	echo := []byte{
+		0x41, 0, // i32.const 0: stdin
+		0x41, 0, // i32.const 0: the iovec
+		0x41, 1, // i32.const 1: one iovec
+		0x41, 20, // i32.const 20: nread
+		0x10, 2, // call fd_read
+		0x1a,    // drop
+		0x41, 4, // i32.const 4: the length of the iovec
+		0x41, 20, // i32.const 20
+		0x28, 2, 0, // i32.load: nread
+		0x36, 2, 0, // i32.store
+		0x41, 1, // i32.const 1: stdout
+		0x41, 0, // i32.const 0: the iovec
+		0x41, 1, // i32.const 1: one iovec
+		0x41, 24, // i32.const 24: nwritten
+		0x10, 0, // call fd_write
+		0x1a, // drop
+	}
+	// a 32 bytes buffer at 32
+	data := []byte{32, 0, 0, 0, 32, 0, 0, 0}
+
+	ctx := context.Background()
+	m, err := Compile(ctx, module(echo, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close(ctx)
+
+	for _, in := range []string{"first", "second"} {
+		var stdout, stderr bytes.Buffer
+		exit, err := m.Run(ctx, "echo", nil, strings.NewReader(in), &stdout, &stderr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exit != 0 || stdout.String() != in {
+			t.Fatalf("unexpected run of %q: exit code %d, output %q", in, exit, stdout.String())
+		}
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	loop := []byte{
+		0x03, 0x40, // loop
+		0x0c, 0, // br 0
+		0x0b, // end
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var out bytes.Buffer
+	if _, err := Run(ctx, module(loop, nil), "loop", nil, &out, &out); err != context.DeadlineExceeded {
+		t.Fatalf("expected the module to be stopped, got %v", err)
+	}
+}
+
+func TestRunNotCommand(t *testing.T) {
+	lib := []byte("\x00asm\x01\x00\x00\x00")
+	var out bytes.Buffer
+	if _, err := Run(context.Background(), lib, "lib", nil, &out, &out); err != ErrNotCommand {
+		t.Fatalf("expected %v, got %v", ErrNotCommand, err)
+	}
+}